@@ -0,0 +1,174 @@
+package bloom
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+)
+
+// Number of addresses in the filter of a large wallet, like an exchange hot wallet
+const benchAddresses = 10000
+
+func benchFilter() (*Filter, []Uint168) {
+	filter := NewFilter(benchAddresses, 0, 0.00003)
+	addrs := make([]Uint168, benchAddresses)
+	for i := range addrs {
+		addrs[i][0] = 33
+		copy(addrs[i][1:], randHash()[:])
+		filter.Add(addrs[i][:])
+	}
+	return filter, addrs
+}
+
+// A transfer transaction with two inputs and two outputs, the most common transaction
+func benchTx(payTo Uint168) *tx.Transaction {
+	change := Uint168{33}
+	copy(change[1:], randHash()[:])
+	return &tx.Transaction{
+		TxType:  tx.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Inputs: []*tx.Input{
+			{ReferTxID: *randHash(), ReferTxOutputIndex: 0},
+			{ReferTxID: *randHash(), ReferTxOutputIndex: 1},
+		},
+		Outputs: []*tx.Output{
+			{Value: 100000000, ProgramHash: payTo},
+			{Value: 200000000, ProgramHash: change},
+		},
+	}
+}
+
+func BenchmarkFilter_MatchTxAndUpdate(b *testing.B) {
+	filter, addrs := benchFilter()
+	txs := make([]*tx.Transaction, 1000)
+	for i := range txs {
+		var payTo Uint168
+		if i%100 == 0 {
+			// One percent of the transactions are related to the wallet
+			payTo = addrs[i]
+		} else {
+			payTo[0] = 33
+			copy(payTo[1:], randHash()[:])
+		}
+		txs[i] = benchTx(payTo)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.MatchTxAndUpdate(txs[i%len(txs)])
+	}
+}
+
+func BenchmarkCheckMerkleBlock(b *testing.B) {
+	// A block with 2000 transactions and 20 of them matched
+	const txs = 2000
+	mBlock := MBlock{
+		NumTx:       txs,
+		AllHashes:   make([]*Uint256, 0, txs),
+		MatchedBits: make([]byte, 0, txs),
+	}
+	for i := 0; i < txs; i++ {
+		mBlock.AllHashes = append(mBlock.AllHashes, randHash())
+		if i%100 == 0 {
+			mBlock.MatchedBits = append(mBlock.MatchedBits, 0x01)
+		} else {
+			mBlock.MatchedBits = append(mBlock.MatchedBits, 0x00)
+		}
+	}
+	mBlock.TraverseAndBuild(treeDepth(txs), 0)
+
+	merkleBlock := MerkleBlock{
+		BlockHeader: core.Header{
+			MerkleRoot: *mBlock.CalcHash(treeDepth(txs), 0),
+		},
+		Transactions: mBlock.NumTx,
+		Hashes:       mBlock.FinalHashes,
+		Flags:        make([]byte, (len(mBlock.Bits)+7)/8),
+	}
+	for i := uint32(0); i < uint32(len(mBlock.Bits)); i++ {
+		merkleBlock.Flags[i/8] |= mBlock.Bits[i] << (i % 8)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		txIds, err := CheckMerkleBlock(merkleBlock)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(txIds) != txs/100 {
+			b.Fatalf("matched %d transactions, expect %d", len(txIds), txs/100)
+		}
+	}
+}
+
+// A block with 4096 transactions and a quarter of them matched, like a block paying many deposit addresses
+func benchMatchedBlock() (MerkleBlock, []*Uint256) {
+	const txs = 4096
+	mBlock := MBlock{
+		NumTx:       txs,
+		AllHashes:   make([]*Uint256, 0, txs),
+		MatchedBits: make([]byte, 0, txs),
+	}
+	var matched []*Uint256
+	for i := 0; i < txs; i++ {
+		hash := randHash()
+		mBlock.AllHashes = append(mBlock.AllHashes, hash)
+		if i%4 == 0 {
+			mBlock.MatchedBits = append(mBlock.MatchedBits, 0x01)
+			matched = append(matched, hash)
+		} else {
+			mBlock.MatchedBits = append(mBlock.MatchedBits, 0x00)
+		}
+	}
+	mBlock.TraverseAndBuild(treeDepth(txs), 0)
+
+	merkleBlock := MerkleBlock{
+		BlockHeader: core.Header{
+			MerkleRoot: *mBlock.CalcHash(treeDepth(txs), 0),
+		},
+		Transactions: mBlock.NumTx,
+		Hashes:       mBlock.FinalHashes,
+		Flags:        make([]byte, (len(mBlock.Bits)+7)/8),
+	}
+	for i := uint32(0); i < uint32(len(mBlock.Bits)); i++ {
+		merkleBlock.Flags[i/8] |= mBlock.Bits[i] << (i % 8)
+	}
+	return merkleBlock, matched
+}
+
+// Extract the branches of the matched transactions one by one, the tree is traversed per transaction
+func BenchmarkGetTxMerkleBranch(b *testing.B) {
+	merkleBlock, matched := benchMatchedBlock()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, txId := range matched {
+			if _, err := merkleBlock.GetTxMerkleBranch(txId); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// Extract the branches of the matched transactions in one traversal
+func BenchmarkGetTxMerkleBranches(b *testing.B) {
+	merkleBlock, matched := benchMatchedBlock()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		branches, err := merkleBlock.GetTxMerkleBranches(matched)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(branches) != len(matched) {
+			b.Fatalf("got %d branches, expect %d", len(branches), len(matched))
+		}
+	}
+}
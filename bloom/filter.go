@@ -246,7 +246,9 @@ func (bf *Filter) AddOutPoint(outpoint *tx.OutPoint) {
 func (bf *Filter) matchTxAndUpdate(txn *tx.Transaction) bool {
 	// Check if the filter matches the hash of the tx.
 	// This is useful for finding transactions when they appear in a block.
-	matched := bf.matches(txn.Hash()[:])
+	// The hash is calculated once as serializing the tx is the most expensive part.
+	txId := txn.Hash()
+	matched := bf.matches(txId[:])
 
 	for i, txOut := range txn.Outputs {
 		if !bf.matches(txOut.ProgramHash[:]) {
@@ -254,7 +256,7 @@ func (bf *Filter) matchTxAndUpdate(txn *tx.Transaction) bool {
 		}
 
 		matched = true
-		bf.addOutPoint(tx.NewOutPoint(*txn.Hash(), uint16(i)))
+		bf.addOutPoint(tx.NewOutPoint(*txId, uint16(i)))
 		break
 	}
 
@@ -0,0 +1,97 @@
+package bloom
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+)
+
+// merkleBlockInfo is the JSON view of a merkle block, hashes are reversed hex strings
+// and flags is hex string
+type merkleBlockInfo struct {
+	Header       core.Header `json:"header"`
+	Transactions uint32      `json:"transactions"`
+	Hashes       []string    `json:"hashes"`
+	Flags        string      `json:"flags"`
+}
+
+func (msg MerkleBlock) MarshalJSON() ([]byte, error) {
+	info := &merkleBlockInfo{
+		Header:       msg.BlockHeader,
+		Transactions: msg.Transactions,
+		Hashes:       make([]string, 0, len(msg.Hashes)),
+		Flags:        BytesToHexString(msg.Flags),
+	}
+	for _, hash := range msg.Hashes {
+		info.Hashes = append(info.Hashes, BytesToHexString(hash.BytesReverse()))
+	}
+	return json.Marshal(info)
+}
+
+func (msg *MerkleBlock) UnmarshalJSON(data []byte) error {
+	info := new(merkleBlockInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	flags, err := HexStringToBytes(info.Flags)
+	if err != nil {
+		return errors.New("invalid merkle block flags hex string")
+	}
+	hashes := make([]*Uint256, 0, len(info.Hashes))
+	for _, str := range info.Hashes {
+		hash, err := Uint256FromReversedHex(str)
+		if err != nil {
+			return errors.New("invalid merkle block hash " + str)
+		}
+		hashes = append(hashes, hash)
+	}
+	*msg = MerkleBlock{BlockHeader: info.Header, Transactions: info.Transactions, Hashes: hashes, Flags: flags}
+	return nil
+}
+
+// merkleProofInfo is the JSON view of a merkle proof, hashes are reversed hex strings
+type merkleProofInfo struct {
+	BlockHash    string   `json:"blockhash"`
+	Height       uint32   `json:"height"`
+	Transactions uint32   `json:"transactions"`
+	Index        uint32   `json:"index"`
+	Branches     []string `json:"branches"`
+}
+
+func (p MerkleProof) MarshalJSON() ([]byte, error) {
+	info := &merkleProofInfo{
+		BlockHash:    BytesToHexString(p.BlockHash.BytesReverse()),
+		Height:       p.Height,
+		Transactions: p.Transactions,
+		Index:        p.Index,
+		Branches:     make([]string, 0, len(p.Branches)),
+	}
+	for _, branch := range p.Branches {
+		info.Branches = append(info.Branches, BytesToHexString(branch.BytesReverse()))
+	}
+	return json.Marshal(info)
+}
+
+func (p *MerkleProof) UnmarshalJSON(data []byte) error {
+	info := new(merkleProofInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	blockHash, err := Uint256FromReversedHex(info.BlockHash)
+	if err != nil {
+		return errors.New("invalid merkle proof block hash " + info.BlockHash)
+	}
+	branches := make([]Uint256, 0, len(info.Branches))
+	for _, str := range info.Branches {
+		branch, err := Uint256FromReversedHex(str)
+		if err != nil {
+			return errors.New("invalid merkle proof branch " + str)
+		}
+		branches = append(branches, *branch)
+	}
+	*p = MerkleProof{BlockHash: *blockHash, Height: info.Height, Transactions: info.Transactions,
+		Index: info.Index, Branches: branches}
+	return nil
+}
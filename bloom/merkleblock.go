@@ -56,8 +56,21 @@ func (msg *MerkleBlock) Deserialize(body []byte) error {
 		return err
 	}
 
+	// Not more hashes than the transactions, and the flag bits of the partial merkle tree
+	// are not more than its nodes, 2 * transactions - 1
+	if hashes > msg.Transactions {
+		return fmt.Errorf("merkleblock of %d transactions has %d hashes", msg.Transactions, hashes)
+	}
+	if int64(hashes)*UINT256SIZE > int64(buf.Len()) {
+		return serialization.ErrEof
+	}
 	msg.Hashes = make([]*Uint256, hashes)
-	return serialization.ReadElements(buf, &msg.Hashes, &msg.Flags)
+	err = serialization.ReadElements(buf, &msg.Hashes)
+	if err != nil {
+		return err
+	}
+	msg.Flags, err = serialization.ReadVarBytesWithLimit(buf, uint64(msg.Transactions)/4+1)
+	return err
 }
 
 func MakeMerkleParent(left *Uint256, right *Uint256) (*Uint256, error) {
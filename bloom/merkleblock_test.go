@@ -0,0 +1,138 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+)
+
+func TestFilterLoadFixture(t *testing.T) {
+	filterLoad := &FilterLoad{Filter: []byte{0xff, 0x01}, HashFuncs: 1, Tweak: 2}
+	wire, _ := hex.DecodeString("02ff01" + "01000000" + "02000000")
+
+	body, err := filterLoad.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, wire) {
+		t.Errorf("serialized %x, expect %x", body, wire)
+	}
+
+	decoded := new(FilterLoad)
+	if err := decoded.Deserialize(wire); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, filterLoad) {
+		t.Errorf("decoded %+v, expect %+v", decoded, filterLoad)
+	}
+}
+
+func TestMerkleBlockFixture(t *testing.T) {
+	// A block of a single transaction, the merkle root is the transaction hash
+	txId := Uint256{0x01, 0x02}
+	block := &MerkleBlock{
+		BlockHeader:  core.Header{Version: 1, MerkleRoot: txId, Timestamp: 1514764800, Height: 1},
+		Transactions: 1,
+		Hashes:       []*Uint256{&txId},
+		Flags:        []byte{0x01},
+	}
+
+	body, err := block.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Transactions, hashes count, hashes and flags follow the header
+	tail, _ := hex.DecodeString("01000000" + "01000000" + hex.EncodeToString(txId[:]) + "0101")
+	if !bytes.HasSuffix(body, tail) {
+		t.Errorf("serialized %x, expect suffix %x", body, tail)
+	}
+
+	decoded := new(MerkleBlock)
+	if err := decoded.Deserialize(body); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.BlockHeader.Hash().IsEqual(block.BlockHeader.Hash()) {
+		t.Errorf("decoded block hash %s, expect %s", decoded.BlockHeader.Hash().String(),
+			block.BlockHeader.Hash().String())
+	}
+	txIds, err := CheckMerkleBlock(*decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txIds) != 1 || !txIds[0].IsEqual(&txId) {
+		t.Errorf("unexpected matched transactions %v", txIds)
+	}
+}
+
+func TestMerkleBlockOversized(t *testing.T) {
+	txId := Uint256{0x01, 0x02}
+	header, err := (&MerkleBlock{BlockHeader: core.Header{Version: 1, MerkleRoot: txId}}).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header = header[:len(header)-9]
+
+	for _, c := range []struct {
+		name string
+		tail string
+	}{
+		{"hashes over transactions", "01000000" + "02000000" + hex.EncodeToString(txId[:]) + "0101"},
+		{"hashes over length", "ffffffff" + "ffffffff" + hex.EncodeToString(txId[:]) + "0101"},
+		{"flags over transactions", "01000000" + "01000000" + hex.EncodeToString(txId[:]) + "020101"},
+	} {
+		tail, _ := hex.DecodeString(c.tail)
+		if err := new(MerkleBlock).Deserialize(append(header, tail...)); err == nil {
+			t.Errorf("%s: oversized merkle block decoded", c.name)
+		}
+	}
+}
+
+func TestMerkleBlockJSON(t *testing.T) {
+	txId := Uint256{0x01, 0x02}
+	block := MerkleBlock{
+		BlockHeader:  core.Header{Version: 1, Previous: Uint256{0x03}, MerkleRoot: txId, Timestamp: 1514764800, Height: 1},
+		Transactions: 1,
+		Hashes:       []*Uint256{&txId},
+		Flags:        []byte{0x01},
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hashes are reversed hex strings as shown by block explorers
+	for _, expect := range []string{
+		`"merkleroot":"` + BytesToHexString(txId.BytesReverse()) + `"`,
+		`"previous":"` + BytesToHexString(block.BlockHeader.Previous.BytesReverse()) + `"`,
+		`"hash":"` + BytesToHexString(block.BlockHeader.Hash().BytesReverse()) + `"`,
+		`"hashes":["` + BytesToHexString(txId.BytesReverse()) + `"]`,
+		`"flags":"01"`,
+	} {
+		if !strings.Contains(string(data), expect) {
+			t.Errorf("marshalled %s without %s", data, expect)
+		}
+	}
+
+	decoded := new(MerkleBlock)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := block.Serialize()
+	decodedBody, err := decoded.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decodedBody, body) {
+		t.Errorf("unmarshalled block serialized %x, expect %x", decodedBody, body)
+	}
+
+	if err := json.Unmarshal([]byte(`{"hashes":["xx"]}`), decoded); err == nil {
+		t.Error("merkle block of invalid hash unmarshalled")
+	}
+}
@@ -13,6 +13,16 @@ type MerkleBranch struct {
 }
 
 func (msg MerkleBlock) GetTxMerkleBranch(txId *Uint256) (*MerkleBranch, error) {
+	return msg.newMerkleNodes().GetMerkleBranch(txId)
+}
+
+// Get the merkle branches of the transactions in order, the partial merkle tree is traversed
+// once and the interior nodes are shared by the branches, instead of once per transaction
+func (msg MerkleBlock) GetTxMerkleBranches(txIds []*Uint256) ([]*MerkleBranch, error) {
+	return msg.newMerkleNodes().GetMerkleBranches(txIds)
+}
+
+func (msg *MerkleBlock) newMerkleNodes() *merkleNodes {
 	mNodes := &merkleNodes{
 		root:     msg.BlockHeader.MerkleRoot,
 		numTxs:   msg.Transactions,
@@ -21,8 +31,7 @@ func (msg MerkleBlock) GetTxMerkleBranch(txId *Uint256) (*MerkleBranch, error) {
 
 	mNodes.SetHashes(msg.Hashes)
 	mNodes.SetBits(msg.Flags)
-
-	return mNodes.GetMerkleBranch(txId)
+	return mNodes
 }
 
 type merkleNodes struct {
@@ -33,6 +42,8 @@ type merkleNodes struct {
 	txIndex  uint32
 	route    []uint32
 	allNodes map[uint32]merkleNode
+	// Positions of the transactions by hash
+	leaves map[Uint256]uint32
 }
 
 func (m *merkleNodes) GetMerkleBranch(txId *Uint256) (mb *MerkleBranch, err error) {
@@ -41,10 +52,48 @@ func (m *merkleNodes) GetMerkleBranch(txId *Uint256) (mb *MerkleBranch, err erro
 		return nil, err
 	}
 
-	m.calcTxIndex(txId)
+	err = m.calcTxIndex(txId)
+	if err != nil {
+		return nil, err
+	}
 	m.calcBranchRoute()
 
-	mb = new(MerkleBranch)
+	return m.getBranch(), nil
+}
+
+func (m *merkleNodes) GetMerkleBranches(txIds []*Uint256) ([]*MerkleBranch, error) {
+	var err error
+	m.allNodes, err = m.getNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	// Index the leaves by hash, so a transaction is found without scanning all nodes
+	width := m.calcTreeWidth(0)
+	m.leaves = make(map[Uint256]uint32)
+	for _, node := range m.allNodes {
+		if node.p < width {
+			m.leaves[*node.h] = node.p
+		}
+	}
+
+	branches := make([]*MerkleBranch, 0, len(txIds))
+	for _, txId := range txIds {
+		index, ok := m.leaves[*txId]
+		if !ok {
+			return nil, fmt.Errorf("tx %s index not found", txId.String())
+		}
+		m.txIndex = index
+		m.route = m.route[:0]
+		m.calcBranchRoute()
+		branches = append(branches, m.getBranch())
+	}
+	return branches, nil
+}
+
+// Get the branch of the nodes on the route
+func (m *merkleNodes) getBranch() *MerkleBranch {
+	mb := new(MerkleBranch)
 	mb.Branches = make([]Uint256, 0, len(m.route))
 	for i, index := range m.route {
 		mb.Branches = append(mb.Branches, *m.allNodes[index].h)
@@ -52,8 +101,7 @@ func (m *merkleNodes) GetMerkleBranch(txId *Uint256) (mb *MerkleBranch, err erro
 			mb.Index += 1 << uint32(i)
 		}
 	}
-
-	return mb, nil
+	return mb
 }
 
 func (m *merkleNodes) SetHashes(hashes []*Uint256) {
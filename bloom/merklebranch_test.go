@@ -77,7 +77,7 @@ func run(txs uint32) {
 		calcRoot := auxpow.GetMerkleRoot(*txIds[i], mb.Branches, mb.Index)
 		if merkleRoot == calcRoot {
 		} else {
-			fmt.Println("Merkle root not match, expect %s result %s",
+			fmt.Printf("Merkle root not match, expect %s result %s\n",
 				merkleRoot.String(), calcRoot.String())
 			os.Exit(0)
 		}
@@ -0,0 +1,151 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+)
+
+// Max branches of a merkle proof, the depth of a tree of 2^32 transactions
+const maxProofBranches = 32
+
+/*
+MerkleProof is the compact proof of one transaction in a block, the position of the transaction
+and the branch hashes from it up to the merkle root. It carries the block hash instead of the
+header, so the proof is verified with the header of the block from the local chain.
+*/
+type MerkleProof struct {
+	BlockHash    Uint256
+	Height       uint32
+	Transactions uint32
+	Index        uint32
+	Branches     []Uint256
+}
+
+// Get the compact proof of the transaction matched in the merkle block, the merkle block is checked
+// against the merkle root of it's header first
+func (msg *MerkleBlock) GetProof(txId Uint256) (*MerkleProof, error) {
+	proofs, err := msg.GetProofs([]*Uint256{&txId})
+	if err != nil {
+		return nil, err
+	}
+	return proofs[0], nil
+}
+
+// Get the compact proofs of the transactions matched in the merkle block in order, the branches
+// are extracted in one traversal of the partial merkle tree
+func (msg *MerkleBlock) GetProofs(txIds []*Uint256) ([]*MerkleProof, error) {
+	matched, err := CheckMerkleBlock(*msg)
+	if err != nil {
+		return nil, err
+	}
+	matchedIds := make(map[Uint256]bool, len(matched))
+	for _, id := range matched {
+		matchedIds[*id] = true
+	}
+	for _, txId := range txIds {
+		if !matchedIds[*txId] {
+			return nil, fmt.Errorf("transaction %s not matched in merkleblock", txId.String())
+		}
+	}
+
+	mNodes := msg.newMerkleNodes()
+	branches, err := mNodes.GetMerkleBranches(txIds)
+	if err != nil {
+		return nil, err
+	}
+
+	blockHash := *msg.BlockHeader.Hash()
+	proofs := make([]*MerkleProof, 0, len(branches))
+	for i, branch := range branches {
+		proofs = append(proofs, &MerkleProof{
+			BlockHash:    blockHash,
+			Height:       msg.BlockHeader.Height,
+			Transactions: msg.Transactions,
+			Index:        mNodes.leaves[*txIds[i]],
+			Branches:     branch.Branches,
+		})
+	}
+	return proofs, nil
+}
+
+/*
+Verify the transaction is in the block of the header by the proof. The branches must be as many as
+the depth of the tree, and a branch equal to the node is only accepted for the last node of a level
+which is hashed with itself, so a proof can not take an inner node or a duplicated subtree for a
+transaction.
+*/
+func (p *MerkleProof) Verify(header *core.Header, txId Uint256) error {
+	if *header.Hash() != p.BlockHash {
+		return errors.New("merkle proof not of the block")
+	}
+	if p.Index >= p.Transactions {
+		return fmt.Errorf("merkle proof index %d out of %d transactions", p.Index, p.Transactions)
+	}
+	if uint32(len(p.Branches)) != treeDepth(p.Transactions) {
+		return fmt.Errorf("merkle proof has %d branches, expect %d", len(p.Branches), treeDepth(p.Transactions))
+	}
+
+	hash := &txId
+	pos := p.Index
+	for height := range p.Branches {
+		branch := &p.Branches[height]
+		width := (p.Transactions + (1 << uint32(height)) - 1) >> uint32(height)
+		switch {
+		case pos%2 == 0 && pos == width-1:
+			// The last node without a sibling is hashed with itself
+			if *branch != *hash {
+				return errors.New("merkle proof branch of the last node not the node")
+			}
+			hash = HashMerkleBranches(hash, hash)
+		case *branch == *hash:
+			return errors.New("merkle proof branch duplicates the node")
+		case pos%2 == 1:
+			hash = HashMerkleBranches(branch, hash)
+		default:
+			hash = HashMerkleBranches(hash, branch)
+		}
+		pos >>= 1
+	}
+	if *hash != header.MerkleRoot {
+		return fmt.Errorf("computed root %s but expect %s", hash.String(), header.MerkleRoot.String())
+	}
+	return nil
+}
+
+func (p *MerkleProof) Serialize(w io.Writer) error {
+	return serialization.WriteElements(w,
+		p.BlockHash,
+		p.Height,
+		p.Transactions,
+		p.Index,
+		uint8(len(p.Branches)),
+		p.Branches,
+	)
+}
+
+func (p *MerkleProof) Deserialize(r io.Reader) error {
+	err := serialization.ReadElements(r,
+		&p.BlockHash,
+		&p.Height,
+		&p.Transactions,
+		&p.Index,
+	)
+	if err != nil {
+		return err
+	}
+
+	branches, err := serialization.ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	if branches > maxProofBranches {
+		return fmt.Errorf("merkle proof has %d branches, more than %d", branches, maxProofBranches)
+	}
+	p.Branches = make([]Uint256, branches)
+	return serialization.ReadElements(r, &p.Branches)
+}
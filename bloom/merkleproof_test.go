@@ -0,0 +1,175 @@
+package bloom
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+)
+
+// Build the merkle block of the transaction hashes matching the transactions at the even positions
+func evenMerkleBlock(hashes []*Uint256) MerkleBlock {
+	mBlock := MBlock{NumTx: uint32(len(hashes)), AllHashes: hashes}
+	for i := range hashes {
+		mBlock.MatchedBits = append(mBlock.MatchedBits, byte(1-i%2))
+	}
+	height := uint32(0)
+	for mBlock.CalcTreeWidth(height) > 1 {
+		height++
+	}
+	mBlock.TraverseAndBuild(height, 0)
+
+	block := MerkleBlock{
+		BlockHeader:  core.Header{Version: 1, MerkleRoot: *mBlock.CalcHash(height, 0), Height: 100},
+		Transactions: mBlock.NumTx,
+		Hashes:       mBlock.FinalHashes,
+		Flags:        make([]byte, (len(mBlock.Bits)+7)/8),
+	}
+	for i, bit := range mBlock.Bits {
+		block.Flags[i/8] |= bit << (uint(i) % 8)
+	}
+	return block
+}
+
+func TestMerkleProof(t *testing.T) {
+	for _, txs := range []int{1, 2, 3, 5, 7, 8, 13, 32, 33} {
+		hashes := make([]*Uint256, 0, txs)
+		for i := 0; i < txs; i++ {
+			hashes = append(hashes, randHash())
+		}
+		block := evenMerkleBlock(hashes)
+
+		for i, hash := range hashes {
+			proof, err := block.GetProof(*hash)
+			if i%2 == 1 {
+				if err == nil {
+					t.Errorf("%d txs: proof of unmatched transaction %d", txs, i)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%d txs: get proof of transaction %d failed, %s", txs, i, err)
+			}
+			if proof.Index != uint32(i) || proof.Height != 100 || proof.BlockHash != *block.BlockHeader.Hash() {
+				t.Errorf("%d txs: proof of transaction %d is %+v", txs, i, proof)
+			}
+			if err := proof.Verify(&block.BlockHeader, *hash); err != nil {
+				t.Errorf("%d txs: verify proof of transaction %d failed, %s", txs, i, err)
+			}
+			if err := proof.Verify(&block.BlockHeader, *randHash()); err == nil {
+				t.Errorf("%d txs: proof of transaction %d verified another transaction", txs, i)
+			}
+
+			buf := new(bytes.Buffer)
+			if err := proof.Serialize(buf); err != nil {
+				t.Fatal(err)
+			}
+			decoded := new(MerkleProof)
+			if err := decoded.Deserialize(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			if err := decoded.Verify(&block.BlockHeader, *hash); err != nil {
+				t.Errorf("%d txs: verify decoded proof of transaction %d failed, %s", txs, i, err)
+			}
+
+			data, err := json.Marshal(proof)
+			if err != nil {
+				t.Fatal(err)
+			}
+			unmarshalled := new(MerkleProof)
+			if err := json.Unmarshal(data, unmarshalled); err != nil {
+				t.Fatal(err)
+			}
+			if err := unmarshalled.Verify(&block.BlockHeader, *hash); err != nil {
+				t.Errorf("%d txs: verify unmarshalled proof %s failed, %s", txs, data, err)
+			}
+		}
+	}
+}
+
+func TestMerkleProofTampered(t *testing.T) {
+	hashes := []*Uint256{randHash(), randHash(), randHash(), randHash(), randHash()}
+	block := evenMerkleBlock(hashes)
+	proof, err := block.GetProof(*hashes[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		tamper func(p *MerkleProof)
+	}{
+		{"index", func(p *MerkleProof) { p.Index = 3 }},
+		{"index out of range", func(p *MerkleProof) { p.Index = 5 }},
+		{"tree depth", func(p *MerkleProof) { p.Transactions = 3 }},
+		{"short branches", func(p *MerkleProof) { p.Branches = p.Branches[1:] }},
+		{"branch", func(p *MerkleProof) { p.Branches[0] = *randHash() }},
+		{"duplicated node", func(p *MerkleProof) { p.Branches[0] = *hashes[2] }},
+		{"block hash", func(p *MerkleProof) { p.BlockHash = *randHash() }},
+	}
+	for _, c := range cases {
+		tampered := *proof
+		tampered.Branches = append([]Uint256(nil), proof.Branches...)
+		c.tamper(&tampered)
+		if err := tampered.Verify(&block.BlockHeader, *hashes[2]); err == nil {
+			t.Errorf("%s: tampered proof verified", c.name)
+		}
+	}
+
+	// The last transaction of an odd level is hashed with itself, the proof of an inner node is rejected
+	last, err := block.GetProof(*hashes[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := last.Verify(&block.BlockHeader, *hashes[4]); err != nil {
+		t.Errorf("verify proof of the last transaction failed, %s", err)
+	}
+	inner := &MerkleProof{BlockHash: proof.BlockHash, Transactions: 2, Index: 0,
+		Branches: []Uint256{*HashMerkleBranches(hashes[4], hashes[4])}}
+	if err := inner.Verify(&block.BlockHeader, *HashMerkleBranches(hashes[0], hashes[1])); err == nil {
+		t.Error("proof of an inner node verified")
+	}
+}
+
+func TestGetTxMerkleBranches(t *testing.T) {
+	hashes := make([]*Uint256, 0, 21)
+	for i := 0; i < 21; i++ {
+		hashes = append(hashes, randHash())
+	}
+	block := evenMerkleBlock(hashes)
+	var matched []*Uint256
+	for i := 0; i < len(hashes); i += 2 {
+		matched = append(matched, hashes[i])
+	}
+
+	branches, err := block.GetTxMerkleBranches(matched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs, err := block.GetProofs(matched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, txId := range matched {
+		branch, err := block.GetTxMerkleBranch(txId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(branches[i], branch) {
+			t.Errorf("branch of transaction %d is %v, expect %v", i*2, branches[i], branch)
+		}
+		if err := proofs[i].Verify(&block.BlockHeader, *txId); err != nil || proofs[i].Index != uint32(i*2) {
+			t.Errorf("proof of transaction %d at %d, %v", i*2, proofs[i].Index, err)
+		}
+	}
+
+	if _, err := block.GetTxMerkleBranches([]*Uint256{matched[0], randHash()}); err == nil {
+		t.Error("got branch of a transaction not in the block")
+	}
+	if _, err := block.GetProofs([]*Uint256{matched[0], hashes[1]}); err == nil {
+		t.Error("got proof of a transaction not matched")
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/cli/account"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/cli/chain"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/cli/message"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/cli/transaction"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/cli/wallet"
 
@@ -14,7 +19,13 @@ import (
 var Version string
 
 func init() {
+	// Settings in config file can be overridden by environment variables
+	if err := spvwallet.Setup(config.Values()); err != nil {
+		fmt.Println("Setup failed,", err)
+		os.Exit(1)
+	}
 	log.Init()
+	spvwallet.SetAuditSource(spvwallet.AuditSourceCLI)
 }
 
 func main() {
@@ -33,6 +44,15 @@ func main() {
 		wallet.NewResetCommand(),
 		account.NewCommand(),
 		transaction.NewCommand(),
+		transaction.NewSendCommand(),
+		transaction.NewDecodeCommand(),
+		transaction.NewEncodeCommand(),
+		chain.NewGetInfoCommand(),
+		chain.NewGetPeersCommand(),
+		chain.NewGetPendingCommand(),
+		chain.NewGetHeaderCommand(),
+		message.NewSignCommand(),
+		message.NewVerifyCommand(),
 	}
 
 	app.Run(os.Args)
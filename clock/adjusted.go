@@ -0,0 +1,137 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+)
+
+const (
+	// Max offset of a peer time sample, samples further off are dropped,
+	// like the version timestamps of the peers not sending seconds
+	MaxOffset = 70 * time.Minute
+
+	// Min samples to adjust the time, the time is not adjusted with fewer peers
+	MinSamples = 5
+
+	// Max samples kept, the oldest ones are dropped
+	MaxSamples = 200
+)
+
+/*
+Network is the network adjusted clock, the time of the underlying clock corrected by the median
+offset of the peer times, so the header timestamps are validated against the time the network
+agrees on, not a wrong local clock. The timers are not adjusted, they are of the underlying clock.
+*/
+type Network struct {
+	base    Clock
+	lock    sync.Mutex
+	samples map[uint64]time.Duration
+	order   []uint64
+	offset  time.Duration
+}
+
+// Create a network adjusted clock of the base clock, a nil base is the process wide clock
+func NewNetwork(base Clock) *Network {
+	return &Network{base: base, samples: make(map[uint64]time.Duration)}
+}
+
+// The network adjusted clock of the process, fed by the version timestamps of the peers
+var Adjusted = NewNetwork(nil)
+
+func init() {
+	metrics.NewGaugeFunc("spv_clock_offset_seconds", "Offset of the network adjusted time to the local time", func() float64 {
+		return Adjusted.Offset().Seconds()
+	})
+}
+
+// Add the time reported by the peer of the id, a peer reported again replaces it's sample
+func (n *Network) AddSample(id uint64, remote time.Time) {
+	offset := remote.Sub(n.clock().Now()).Truncate(time.Second)
+	if offset > MaxOffset || offset < -MaxOffset {
+		log.Debugf("Drop time sample of peer %d, offset %s out of range", id, offset)
+		return
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if _, ok := n.samples[id]; !ok {
+		n.order = append(n.order, id)
+	}
+	n.samples[id] = offset
+	if len(n.order) > MaxSamples {
+		delete(n.samples, n.order[0])
+		n.order = n.order[1:]
+	}
+	n.adjust()
+}
+
+// Get the offset of the network adjusted time to the underlying clock
+func (n *Network) Offset() time.Duration {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.offset
+}
+
+func (n *Network) Now() time.Time {
+	return n.clock().Now().Add(n.Offset())
+}
+
+func (n *Network) Since(t time.Time) time.Duration {
+	return n.Now().Sub(t)
+}
+
+func (n *Network) Sleep(d time.Duration) {
+	n.clock().Sleep(d)
+}
+
+func (n *Network) After(d time.Duration) <-chan time.Time {
+	return n.clock().After(d)
+}
+
+func (n *Network) NewTimer(d time.Duration) Timer {
+	return n.clock().NewTimer(d)
+}
+
+func (n *Network) NewTicker(d time.Duration) Ticker {
+	return n.clock().NewTicker(d)
+}
+
+func (n *Network) AfterFunc(d time.Duration, f func()) Timer {
+	return n.clock().AfterFunc(d, f)
+}
+
+func (n *Network) clock() Clock {
+	if n.base != nil {
+		return n.base
+	}
+	return Get()
+}
+
+// Set the offset to the median of the samples, must be called with the lock held
+func (n *Network) adjust() {
+	if len(n.samples) < MinSamples {
+		n.offset = 0
+		return
+	}
+	offsets := make([]time.Duration, 0, len(n.samples))
+	for _, offset := range n.samples {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+
+	middle := len(offsets) / 2
+	offset := offsets[middle]
+	if len(offsets)%2 == 0 {
+		offset = (offsets[middle-1] + offsets[middle]) / 2
+	}
+	if offset != n.offset {
+		log.Infof("Network adjusted time offset %s from %d peers", offset, len(offsets))
+	}
+	n.offset = offset
+}
@@ -0,0 +1,135 @@
+/*
+Package clock is the source of time of the timeouts, the schedulers and the header timestamp
+validation. The process wide clock is the system clock, tests replace it with a Mock by Set()
+to run the time based code deterministically. Adjusted is the network adjusted time, the
+system time corrected by the median offset of the peers, the headers are validated against it.
+*/
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates the timers
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event timer, C() is nil for the timers created by AfterFunc()
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker delivers the ticks at intervals
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// The system clock
+var System Clock = systemClock{}
+
+var (
+	lock    sync.RWMutex
+	current = System
+)
+
+// Set the process wide clock, it's the system clock by default, set a Mock for tests.
+// The clock should be set before the service started, the running timers are not moved
+func Set(c Clock) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	current = c
+}
+
+// Get the process wide clock
+func Get() Clock {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return current
+}
+
+func Now() time.Time {
+	return Get().Now()
+}
+
+func Since(t time.Time) time.Duration {
+	return Get().Since(t)
+}
+
+func Sleep(d time.Duration) {
+	Get().Sleep(d)
+}
+
+func After(d time.Duration) <-chan time.Time {
+	return Get().After(d)
+}
+
+func NewTimer(d time.Duration) Timer {
+	return Get().NewTimer(d)
+}
+
+func NewTicker(d time.Duration) Ticker {
+	return Get().NewTicker(d)
+}
+
+func AfterFunc(d time.Duration, f func()) Timer {
+	return Get().AfterFunc(d, f)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (systemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return &systemTicker{time.NewTicker(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t *systemTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
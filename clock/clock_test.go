@@ -0,0 +1,106 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+func TestMock(t *testing.T) {
+	start := time.Unix(1514764800, 0)
+	mock := NewMock(start)
+
+	var fired []string
+	mock.AfterFunc(time.Second*2, func() { fired = append(fired, "func") })
+	timer := mock.NewTimer(time.Second)
+	ticker := mock.NewTicker(time.Second * 3)
+	stopped := mock.NewTimer(time.Second)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("unexpected result of stopping the timer")
+	}
+
+	mock.Add(time.Second * 2)
+	if len(fired) != 1 || mock.Since(start) != time.Second*2 {
+		t.Fatalf("fired %v at %s, expect the func fired", fired, mock.Since(start))
+	}
+	select {
+	case now := <-timer.C():
+		if !now.Equal(start.Add(time.Second)) {
+			t.Errorf("timer fired at %s, expect at 1s", now.Sub(start))
+		}
+	default:
+		t.Fatal("timer not fired")
+	}
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	// The ticker keeps ticking until stopped
+	for i := 0; i < 2; i++ {
+		mock.Add(time.Second * 3)
+		select {
+		case <-ticker.C():
+		default:
+			t.Fatalf("ticker not ticked %d", i)
+		}
+	}
+	ticker.Stop()
+	if mock.Timers() != 0 {
+		t.Errorf("%d timers pending, expect none", mock.Timers())
+	}
+
+	// Sleep returns when the clock is moved
+	done := make(chan struct{})
+	go func() {
+		mock.Sleep(time.Minute)
+		close(done)
+	}()
+	for mock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	mock.Add(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sleep not returned")
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	log.Init()
+
+	now := time.Unix(1514764800, 0)
+	network := NewNetwork(NewMock(now))
+
+	for i := 1; i < MinSamples; i++ {
+		network.AddSample(uint64(i), now.Add(time.Minute))
+	}
+	if network.Offset() != 0 {
+		t.Fatal("time adjusted with too few samples")
+	}
+
+	// Median of 1m, 1m, 1m, 1m, 5m
+	network.AddSample(MinSamples, now.Add(time.Minute*5))
+	if network.Offset() != time.Minute || !network.Now().Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected offset %s", network.Offset())
+	}
+
+	// A peer reported again replaces it's sample, median of 1m, 1m, 1m, 3m, 5m, 5m
+	network.AddSample(MinSamples+1, now.Add(time.Minute*5))
+	network.AddSample(1, now.Add(time.Minute*3))
+	if network.Offset() != time.Minute*2 {
+		t.Fatalf("unexpected offset %s of the even samples", network.Offset())
+	}
+
+	// Samples out of range are dropped
+	network.AddSample(MinSamples+2, now.Add(MaxOffset+time.Second))
+	network.AddSample(MinSamples+3, now.Add(-MaxOffset-time.Second))
+	if network.Offset() != time.Minute*2 {
+		t.Fatalf("offset %s changed by the samples out of range", network.Offset())
+	}
+}
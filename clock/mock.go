@@ -0,0 +1,173 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+Mock is a clock moves only when told, for the deterministic tests of the time based code.
+The timers are fired in time order by Add() or Set(), the functions of AfterFunc() are called
+in the goroutine moving the clock, so they are done when Add() returns.
+*/
+type Mock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*mockTimer
+}
+
+// Create a mock clock starts at the time
+func NewMock(now time.Time) *Mock {
+	return &Mock{now: now}
+}
+
+func (m *Mock) Now() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.now
+}
+
+func (m *Mock) Since(t time.Time) time.Duration {
+	return m.Now().Sub(t)
+}
+
+// Sleep blocks until the clock is moved d forward by another goroutine
+func (m *Mock) Sleep(d time.Duration) {
+	<-m.After(d)
+}
+
+func (m *Mock) After(d time.Duration) <-chan time.Time {
+	return m.NewTimer(d).C()
+}
+
+func (m *Mock) NewTimer(d time.Duration) Timer {
+	return m.add(&mockTimer{mock: m, ch: make(chan time.Time, 1)}, d)
+}
+
+func (m *Mock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return &mockTicker{m.add(&mockTimer{mock: m, ch: make(chan time.Time, 1), period: d}, d)}
+}
+
+func (m *Mock) AfterFunc(d time.Duration, f func()) Timer {
+	return m.add(&mockTimer{mock: m, f: f}, d)
+}
+
+// Move the clock d forward and fire the timers due
+func (m *Mock) Add(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Move the clock to the time and fire the timers due, a time before now sets the clock back without firing
+func (m *Mock) Set(t time.Time) {
+	for {
+		m.lock.Lock()
+		next := m.next(t)
+		if next == nil {
+			m.now = t
+			m.lock.Unlock()
+			return
+		}
+		if next.when.After(m.now) {
+			m.now = next.when
+		}
+		now := m.now
+		if next.period > 0 {
+			next.when = next.when.Add(next.period)
+		} else {
+			m.remove(next)
+		}
+		m.lock.Unlock()
+
+		if next.f != nil {
+			next.f()
+			continue
+		}
+		select {
+		case next.ch <- now:
+		default:
+		}
+	}
+}
+
+// Get the count of the pending timers, tests wait on it for a goroutine to start waiting
+func (m *Mock) Timers() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.timers)
+}
+
+func (m *Mock) add(timer *mockTimer, d time.Duration) *mockTimer {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	timer.when = m.now.Add(d)
+	m.timers = append(m.timers, timer)
+	return timer
+}
+
+// Get the earliest timer due at the time, must be called with the lock held
+func (m *Mock) next(t time.Time) *mockTimer {
+	var next *mockTimer
+	for _, timer := range m.timers {
+		if timer.when.After(t) {
+			continue
+		}
+		if next == nil || timer.when.Before(next.when) {
+			next = timer
+		}
+	}
+	return next
+}
+
+// Remove the timer, return false if it's not pending, must be called with the lock held
+func (m *Mock) remove(timer *mockTimer) bool {
+	for i, t := range m.timers {
+		if t == timer {
+			m.timers = append(m.timers[:i], m.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type mockTimer struct {
+	mock   *Mock
+	when   time.Time
+	period time.Duration
+	ch     chan time.Time
+	f      func()
+}
+
+func (t *mockTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *mockTimer) Stop() bool {
+	t.mock.lock.Lock()
+	defer t.mock.lock.Unlock()
+
+	return t.mock.remove(t)
+}
+
+func (t *mockTimer) Reset(d time.Duration) bool {
+	t.mock.lock.Lock()
+	defer t.mock.lock.Unlock()
+
+	pending := t.mock.remove(t)
+	t.when = t.mock.now.Add(d)
+	t.mock.timers = append(t.mock.timers, t)
+	return pending
+}
+
+type mockTicker struct {
+	*mockTimer
+}
+
+func (t *mockTicker) Stop() {
+	t.mockTimer.Stop()
+}
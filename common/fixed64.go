@@ -23,8 +23,8 @@ func (f *Fixed64) Serialize(w io.Writer) error {
 
 func (f *Fixed64) Deserialize(r io.Reader) error {
 	p := make([]byte, 8)
-	n, err := r.Read(p)
-	if n <= 0 || err != nil {
+	_, err := io.ReadFull(r, p)
+	if err != nil {
 		return err
 	}
 	buf := bytes.NewBuffer(p)
@@ -0,0 +1,24 @@
+package serialization
+
+import (
+	"crypto/sha256"
+	"hash"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+// HashWriter computes the SHA256D hash of the data written to it, so a data
+// can be hashed while it's serialized instead of buffering the bytes first
+type HashWriter struct {
+	hash.Hash
+}
+
+func NewHashWriter() *HashWriter {
+	return &HashWriter{Hash: sha256.New()}
+}
+
+// Get the SHA256D hash of the data written, the same as Sha256D() of the bytes
+func (w *HashWriter) Sum256D() Uint256 {
+	var once [sha256.Size]byte
+	return Uint256(sha256.Sum256(w.Sum(once[:0])))
+}
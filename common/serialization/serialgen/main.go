@@ -0,0 +1,248 @@
+/*
+Serialgen generates the Serialize and Deserialize methods of the structs annotated with a
+"+serialgen" line in the doc comment, the fields are serialized in order without reflection.
+
+	//go:generate go run ../../common/serialization/serialgen/main.go
+
+The basic types are written by the functions of the serialization package, []byte and string
+as var bytes, and the other types by their own Serialize and Deserialize methods. A field with
+the tag `serialgen:"-"` is skipped. The methods of a package are written to serialize_gen.go.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// The line in the doc comment of a struct to generate the methods for
+	marker = "+serialgen"
+
+	// File of the generated methods in the package directory
+	output = "serialize_gen.go"
+
+	serializationImport = "github.com/elastos/Elastos.ELA.SPV/common/serialization"
+)
+
+// The statements to write and read a field, %s is replaced by the field
+type codec struct {
+	write string
+	read  string
+}
+
+var codecs = map[string]codec{
+	"uint8":  {"err = serialization.WriteUint8(w, %s)", "%s, err = serialization.ReadUint8(r)"},
+	"byte":   {"err = serialization.WriteUint8(w, %s)", "%s, err = serialization.ReadUint8(r)"},
+	"uint16": {"err = serialization.WriteUint16(w, %s)", "%s, err = serialization.ReadUint16(r)"},
+	"uint32": {"err = serialization.WriteUint32(w, %s)", "%s, err = serialization.ReadUint32(r)"},
+	"uint64": {"err = serialization.WriteUint64(w, %s)", "%s, err = serialization.ReadUint64(r)"},
+	"bool":   {"err = serialization.WriteBool(w, %s)", "%s, err = serialization.ReadBool(r)"},
+	"[]byte": {"err = serialization.WriteVarBytes(w, %s)", "%s, err = serialization.ReadVarBytes(r)"},
+	"string": {"err = serialization.WriteVarString(w, %s)", "%s, err = serialization.ReadVarString(r)"},
+	// Serialize of Uint168 returns the length written
+	"Uint168": {"_, err = %s.Serialize(w)", "err = %s.Deserialize(r)"},
+}
+
+// The codec of the other types with the Serialize and Deserialize methods
+var serializable = codec{"err = %s.Serialize(w)", "err = %s.Deserialize(r)"}
+
+type field struct {
+	name  string
+	codec codec
+}
+
+type structType struct {
+	name   string
+	fields []field
+}
+
+func main() {
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	code, err := generate(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if code == nil {
+		log.Fatal("no struct annotated with ", marker, " in ", dir)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, output), code, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Generate the methods of the annotated structs in the package directory, nil if there is none
+func generate(dir string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%d packages in %s, expect 1", len(pkgs), dir)
+	}
+
+	for name, pkg := range pkgs {
+		structs, err := annotatedStructs(pkg)
+		if err != nil {
+			return nil, err
+		}
+		if len(structs) == 0 {
+			return nil, nil
+		}
+		return render(name, structs)
+	}
+	return nil, nil
+}
+
+// Get the annotated structs of the package sorted by name, so the output is stable
+func annotatedStructs(pkg *ast.Package) ([]*structType, error) {
+	var structs []*structType
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				doc := spec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				if !annotated(doc) {
+					continue
+				}
+				st, ok := spec.Type.(*ast.StructType)
+				if !ok {
+					return nil, fmt.Errorf("%s annotated with %s is not a struct", spec.Name.Name, marker)
+				}
+				s, err := newStructType(spec.Name.Name, st)
+				if err != nil {
+					return nil, err
+				}
+				structs = append(structs, s)
+			}
+		}
+	}
+	sort.Slice(structs, func(i, j int) bool { return structs[i].name < structs[j].name })
+	return structs, nil
+}
+
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, comment := range doc.List {
+		if strings.TrimSpace(strings.TrimPrefix(comment.Text, "//")) == marker {
+			return true
+		}
+	}
+	return false
+}
+
+func newStructType(name string, st *ast.StructType) (*structType, error) {
+	s := &structType{name: name}
+	for _, f := range st.Fields.List {
+		if f.Tag != nil {
+			tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+			if tag.Get("serialgen") == "-" {
+				continue
+			}
+		}
+		if len(f.Names) == 0 {
+			return nil, fmt.Errorf("embedded field in %s is not supported", name)
+		}
+		c, err := fieldCodec(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s.%s: %v", name, f.Names[0].Name, err)
+		}
+		for _, n := range f.Names {
+			s.fields = append(s.fields, field{name: n.Name, codec: c})
+		}
+	}
+	if len(s.fields) == 0 {
+		return nil, fmt.Errorf("%s has no field to serialize", name)
+	}
+	return s, nil
+}
+
+func fieldCodec(expr ast.Expr) (codec, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if c, ok := codecs[t.Name]; ok {
+			return c, nil
+		}
+		return serializable, nil
+	case *ast.SelectorExpr:
+		// The types of other packages, like common.Uint168
+		if t.Sel.Name == "Uint168" {
+			return codecs[t.Sel.Name], nil
+		}
+		return serializable, nil
+	case *ast.ArrayType:
+		if elt, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && (elt.Name == "byte" || elt.Name == "uint8") {
+			return codecs["[]byte"], nil
+		}
+	}
+	return codec{}, fmt.Errorf("type %T is not supported", expr)
+}
+
+func render(pkg string, structs []*structType) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by serialgen. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if usesSerialization(structs) {
+		fmt.Fprintf(&buf, "import (\n\t\"io\"\n\n\t%q\n)\n", serializationImport)
+	} else {
+		fmt.Fprintf(&buf, "import \"io\"\n")
+	}
+	for _, s := range structs {
+		recv := strings.ToLower(s.name[:1])
+		fmt.Fprintf(&buf, "\nfunc (%s *%s) Serialize(w io.Writer) error {\n\tvar err error\n", recv, s.name)
+		for _, f := range s.fields {
+			writeStatement(&buf, fmt.Sprintf(f.codec.write, recv+"."+f.name))
+		}
+		fmt.Fprintf(&buf, "\treturn nil\n}\n")
+
+		fmt.Fprintf(&buf, "\nfunc (%s *%s) Deserialize(r io.Reader) error {\n\tvar err error\n", recv, s.name)
+		for _, f := range s.fields {
+			writeStatement(&buf, fmt.Sprintf(f.codec.read, recv+"."+f.name))
+		}
+		fmt.Fprintf(&buf, "\treturn nil\n}\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+func usesSerialization(structs []*structType) bool {
+	for _, s := range structs {
+		for _, f := range s.fields {
+			if strings.Contains(f.codec.write, "serialization.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeStatement(buf *bytes.Buffer, statement string) {
+	fmt.Fprintf(buf, "\tif %s; err != nil {\n\t\treturn err\n\t}\n", statement)
+}
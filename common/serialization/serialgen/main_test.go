@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const source = `package sample
+
+import "github.com/elastos/Elastos.ELA.SPV/common"
+
+// +serialgen
+type Sample struct {
+	Flag   bool
+	Height uint32
+	Data   []byte
+	Hash   common.Uint168
+	Value  common.Fixed64
+	Cached int ` + "`serialgen:\"-\"`" + `
+}
+
+type Skipped struct {
+	Height uint32
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "serialgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "sample.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := generate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		"func (s *Sample) Serialize(w io.Writer) error",
+		"func (s *Sample) Deserialize(r io.Reader) error",
+		"serialization.WriteBool(w, s.Flag)",
+		"s.Height, err = serialization.ReadUint32(r)",
+		"s.Data, err = serialization.ReadVarBytes(r)",
+		"_, err = s.Hash.Serialize(w)",
+		"err = s.Value.Deserialize(r)",
+	} {
+		if !strings.Contains(string(code), expect) {
+			t.Errorf("generated code without %s:\n%s", expect, code)
+		}
+	}
+	if strings.Contains(string(code), "Cached") || strings.Contains(string(code), "Skipped") {
+		t.Errorf("generated code of the skipped field or struct:\n%s", code)
+	}
+}
+
+// The generated methods in the repository are up to date with the annotated structs
+func TestGeneratedUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", "core", "transaction")
+	code, err := generate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := ioutil.ReadFile(filepath.Join(dir, output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(code, generated) {
+		t.Errorf("%s is outdated, run go generate in %s", output, dir)
+	}
+}
@@ -11,6 +11,10 @@ import (
 var ErrRange = errors.New("value out of range")
 var ErrEof = errors.New("got EOF, can not get the next byte")
 
+// Max length of the var bytes read by ReadVarBytes, the same as the max message
+// a peer can send, so the length read from a peer can not make a large allocation
+const MaxVarBytesLen = 32 * 1024 * 1024
+
 //Serializable describe the data need be serialized.
 type Serializable interface {
 	//Write data to writer
@@ -47,9 +51,17 @@ type Serializable interface {
  * 7. GetVarUintSize func, this func will return the length of a uint when it
  *    serialized by the WriteVarUint func.
  * 8. ReadBytes func, this func will read the specify lenth's bytes and retun.
- * 9. ReadUint8,16,32,64 read uint with fixed length
+ * 9. ReadUint8,16,32,64 read uint with fixed length, the readers of fixed
+ *    length read until the bytes are full, ErrEof if the reader ends before
  * 10.WriteUint8,16,32,64 Write uint with fixed length
  * 11.ToArray Serializable to ToArray() func.
+ * 12.ReadVarBytesWithLimit func, the same as ReadVarBytes with the max
+ *    length given, ReadVarBytes and a max of 0 limit it to MaxVarBytesLen.
+ * 13.ReadCount func, read the count of the elements following it and check
+ *    the elements can be in the data left before they are allocated.
+ * 14.ReadBool and WriteBool read and write a bool in 1 byte, as binary.Write.
+ * 15.WriteElements and ReadElements write and read the elements in order,
+ *    the basic types are done directly and the others by binary reflection.
  ******************************************************************************
  */
 
@@ -82,26 +94,22 @@ func ReadVarUint(reader io.Reader, maxint uint64) (uint64, error) {
 		maxint = math.MaxUint64
 	}
 	var fb [9]byte
-	_, err := reader.Read(fb[:1])
-	if err != nil {
+	if err := readFull(reader, fb[:1]); err != nil {
 		return 0, err
 	}
 
 	if fb[0] == byte(0xfd) {
-		_, err := reader.Read(fb[1:3])
-		if err != nil {
+		if err := readFull(reader, fb[1:3]); err != nil {
 			return 0, err
 		}
 		res = uint64(binary.LittleEndian.Uint16(fb[1:3]))
 	} else if fb[0] == byte(0xfe) {
-		_, err := reader.Read(fb[1:5])
-		if err != nil {
+		if err := readFull(reader, fb[1:5]); err != nil {
 			return 0, err
 		}
 		res = uint64(binary.LittleEndian.Uint32(fb[1:5]))
 	} else if fb[0] == byte(0xff) {
-		_, err := reader.Read(fb[1:9])
-		if err != nil {
+		if err := readFull(reader, fb[1:9]); err != nil {
 			return 0, err
 		}
 		res = uint64(binary.LittleEndian.Uint64(fb[1:9]))
@@ -136,10 +144,23 @@ func WriteVarString(writer io.Writer, value string) error {
 }
 
 func ReadVarBytes(reader io.Reader) ([]byte, error) {
-	val, err := ReadVarUint(reader, 0)
+	return ReadVarBytesWithLimit(reader, MaxVarBytesLen)
+}
+
+// Read the var bytes not longer than max, the length is checked before the bytes allocated.
+// ErrRange is returned if it's longer, and ErrEof if it's longer than the reader has left.
+// A max of 0 is MaxVarBytesLen, not unlimited as ReadVarUint takes it
+func ReadVarBytesWithLimit(reader io.Reader, max uint64) ([]byte, error) {
+	if max == 0 {
+		max = MaxVarBytesLen
+	}
+	val, err := ReadVarUint(reader, max)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkRemaining(reader, val); err != nil {
+		return nil, err
+	}
 	str, err := byteXReader(reader, val)
 	if err != nil {
 		return nil, err
@@ -155,6 +176,32 @@ func ReadVarString(reader io.Reader) (string, error) {
 	return string(val), nil
 }
 
+// Read the count of the elements not more than max, for the elements serialized in at least size
+// bytes the count is checked against the length the reader has left, ErrEof if it's not enough
+func ReadCount(reader io.Reader, max uint64, size uint64) (uint64, error) {
+	count, err := ReadVarUint(reader, max)
+	if err != nil {
+		return 0, err
+	}
+	if count > MaxVarBytesLen/size {
+		return 0, ErrRange
+	}
+	if err := checkRemaining(reader, count*size); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Check the length is not more than the reader has left, if the reader knows it like a bytes.Reader
+func checkRemaining(reader io.Reader, length uint64) error {
+	if r, ok := reader.(interface {
+		Len() int
+	}); ok && length > uint64(r.Len()) {
+		return ErrEof
+	}
+	return nil
+}
+
 func ReadBytes(reader io.Reader, length uint64) ([]byte, error) {
 	str, err := byteXReader(reader, length)
 	if err != nil {
@@ -165,40 +212,44 @@ func ReadBytes(reader io.Reader, length uint64) ([]byte, error) {
 
 func ReadUint8(reader io.Reader) (uint8, error) {
 	var p [1]byte
-	n, err := reader.Read(p[:])
-	if n <= 0 || err != nil {
-		return 0, ErrEof
+	if err := readFull(reader, p[:]); err != nil {
+		return 0, err
 	}
 	return uint8(p[0]), nil
 }
 
 func ReadUint16(reader io.Reader) (uint16, error) {
 	var p [2]byte
-	n, err := reader.Read(p[:])
-	if n <= 0 || err != nil {
-		return 0, ErrEof
+	if err := readFull(reader, p[:]); err != nil {
+		return 0, err
 	}
 	return binary.LittleEndian.Uint16(p[:]), nil
 }
 
 func ReadUint32(reader io.Reader) (uint32, error) {
 	var p [4]byte
-	n, err := reader.Read(p[:])
-	if n <= 0 || err != nil {
-		return 0, ErrEof
+	if err := readFull(reader, p[:]); err != nil {
+		return 0, err
 	}
 	return binary.LittleEndian.Uint32(p[:]), nil
 }
 
 func ReadUint64(reader io.Reader) (uint64, error) {
 	var p [8]byte
-	n, err := reader.Read(p[:])
-	if n <= 0 || err != nil {
-		return 0, ErrEof
+	if err := readFull(reader, p[:]); err != nil {
+		return 0, err
 	}
 	return binary.LittleEndian.Uint64(p[:]), nil
 }
 
+func ReadBool(reader io.Reader) (bool, error) {
+	val, err := ReadUint8(reader)
+	if err != nil {
+		return false, err
+	}
+	return val != 0, nil
+}
+
 func WriteUint8(writer io.Writer, val uint8) error {
 	var p [1]byte
 	p[0] = byte(val)
@@ -230,17 +281,33 @@ func WriteUint64(writer io.Writer, val uint64) error {
 //**************************************************************************
 //**    internal func                                                    ***
 //**************************************************************************
+//** 1.readFull: fill the buffer, ErrEof if the reader ends before it's full.
 //** 2.byteXReader: read x byte and return []byte.
-//** 3.byteToUint8: change byte -> uint8 and return.
 //**************************************************************************
 
+// A single Read may return less bytes than asked, like from a network
+// connection, so the buffer is filled until it's full or the reader fails
+func readFull(reader io.Reader, p []byte) error {
+	_, err := io.ReadFull(reader, p)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrEof
+	}
+	return err
+}
+
 func byteXReader(reader io.Reader, x uint64) ([]byte, error) {
 	p := make([]byte, x)
-	n, err := reader.Read(p)
-	if n > 0 {
-		return p[:], nil
+	if err := readFull(reader, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func WriteBool(writer io.Writer, val bool) error {
+	if val {
+		return WriteUint8(writer, 1)
 	}
-	return p, err
+	return WriteUint8(writer, 0)
 }
 
 func WriteElements(writer io.Writer, elements ...interface{}) error {
@@ -273,7 +340,20 @@ func WriteElement(writer io.Writer, element interface{}) (err error) {
 		}
 	case []byte:
 		err = WriteVarBytes(writer, e)
+	case uint8:
+		err = WriteUint8(writer, e)
+	case uint16:
+		err = WriteUint16(writer, e)
+	case uint32:
+		err = WriteUint32(writer, e)
+	case uint64:
+		err = WriteUint64(writer, e)
+	case bool:
+		err = WriteBool(writer, e)
+	case Uint168:
+		_, err = e.Serialize(writer)
 	default:
+		// Reflection of binary.Write is slow and allocates, add the common types above
 		err = binary.Write(writer, binary.LittleEndian, e)
 	}
 	return err
@@ -312,6 +392,18 @@ func ReadElement(reader io.Reader, element interface{}) (err error) {
 		}
 	case *[]byte:
 		*e, err = ReadVarBytes(reader)
+	case *uint8:
+		*e, err = ReadUint8(reader)
+	case *uint16:
+		*e, err = ReadUint16(reader)
+	case *uint32:
+		*e, err = ReadUint32(reader)
+	case *uint64:
+		*e, err = ReadUint64(reader)
+	case *bool:
+		*e, err = ReadBool(reader)
+	case *Uint168:
+		err = e.Deserialize(reader)
 	default:
 		err = binary.Read(reader, binary.LittleEndian, e)
 	}
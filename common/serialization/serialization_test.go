@@ -0,0 +1,194 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+// The readers returning the bytes in chunks, like a network connection
+var chunkedReaders = map[string]func(io.Reader) io.Reader{
+	"one byte": iotest.OneByteReader,
+	"half":     iotest.HalfReader,
+	"data err": iotest.DataErrReader,
+}
+
+// The values of each reader, serialized in order
+func serializeValues(t *testing.T) []byte {
+	var buf bytes.Buffer
+	WriteUint8(&buf, 0x01)
+	WriteUint16(&buf, 0x0203)
+	WriteUint32(&buf, 0x04050607)
+	WriteUint64(&buf, 0x08090a0b0c0d0e0f)
+	for _, value := range []uint64{0xfc, 0xfd, 0xffff, 0x10000, 0x100000000} {
+		if err := WriteVarUint(&buf, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	WriteVarBytes(&buf, bytes.Repeat([]byte{0x10}, 300))
+	WriteVarBytes(&buf, nil)
+	WriteVarString(&buf, "string")
+	buf.Write(bytes.Repeat([]byte{0x11}, 40))
+	programHash, value := Uint168{0x12}, Fixed64(100)
+	programHash.Serialize(&buf)
+	value.Serialize(&buf)
+	return buf.Bytes()
+}
+
+func deserializeValues(r io.Reader) ([]interface{}, error) {
+	var values []interface{}
+	u8, err := ReadUint8(r)
+	if err != nil {
+		return nil, err
+	}
+	u16, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+	u32, err := ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	u64, err := ReadUint64(r)
+	if err != nil {
+		return nil, err
+	}
+	values = append(values, u8, u16, u32, u64)
+	for i := 0; i < 5; i++ {
+		value, err := ReadVarUint(r, 0)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	for i := 0; i < 2; i++ {
+		bytes, err := ReadVarBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, len(bytes))
+	}
+	str, err := ReadVarString(r)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := ReadBytes(r, 40)
+	if err != nil {
+		return nil, err
+	}
+	var programHash Uint168
+	if err := programHash.Deserialize(r); err != nil {
+		return nil, err
+	}
+	var value Fixed64
+	if err := value.Deserialize(r); err != nil {
+		return nil, err
+	}
+	return append(values, str, bytes[39], programHash, value), nil
+}
+
+func TestShortRead(t *testing.T) {
+	data := serializeValues(t)
+	expect, err := deserializeValues(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The values read in chunks are the same as read at once
+	for name, chunked := range chunkedReaders {
+		values, err := deserializeValues(chunked(bytes.NewReader(data)))
+		if err != nil {
+			t.Errorf("%s reader error %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, expect) {
+			t.Errorf("%s reader read %v, expect %v", name, values, expect)
+		}
+	}
+
+	// The data ended in a value is not read as a value with the bytes missing,
+	// the values of this package return ErrEof and the common types io errors
+	end := len(data) - UINT168SIZE - 8
+	for i := 0; i < len(data); i++ {
+		for name, chunked := range chunkedReaders {
+			_, err := deserializeValues(chunked(bytes.NewReader(data[:i])))
+			if err == nil || i < end && err != ErrEof {
+				t.Fatalf("%s reader of %d bytes error %v, expect %v", name, i, err, ErrEof)
+			}
+		}
+	}
+}
+
+func TestShortReadError(t *testing.T) {
+	// The error of the reader is returned as it is, not as the end of the data
+	failure := errors.New("connection reset")
+	r := io.MultiReader(iotest.OneByteReader(bytes.NewReader([]byte{0x01, 0x02})), &errReader{failure})
+	if _, err := ReadUint32(r); err != failure {
+		t.Errorf("read uint32 error %v, expect %v", err, failure)
+	}
+}
+
+func TestReadVarBytesWithLimit(t *testing.T) {
+	var buf bytes.Buffer
+	WriteVarBytes(&buf, []byte("limit"))
+	data := buf.Bytes()
+
+	if _, err := ReadVarBytesWithLimit(bytes.NewReader(data), 4); err != ErrRange {
+		t.Errorf("read 5 bytes limited to 4 error %v, expect %v", err, ErrRange)
+	}
+	if value, err := ReadVarBytesWithLimit(bytes.NewReader(data), 5); err != nil || string(value) != "limit" {
+		t.Errorf("read 5 bytes limited to 5 got %q, error %v", value, err)
+	}
+
+	// A max of 0 is not unlimited
+	buf.Reset()
+	WriteVarUint(&buf, MaxVarBytesLen+1)
+	if _, err := ReadVarBytesWithLimit(bytes.NewReader(buf.Bytes()), 0); err != ErrRange {
+		t.Errorf("read %d bytes limited to 0 error %v, expect %v", MaxVarBytesLen+1, err, ErrRange)
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestElements(t *testing.T) {
+	u8, u16, u32, u64, b, hash := uint8(1), uint16(0x0203), uint32(0x04050607), uint64(0x08090a0b0c0d0e0f), true,
+		Uint168{0x21, 0x01}
+	var buf bytes.Buffer
+	if err := WriteElements(&buf, u8, u16, u32, u64, b, hash); err != nil {
+		t.Fatal(err)
+	}
+
+	// The basic types are written the same as binary.Write without reflection
+	var expect bytes.Buffer
+	for _, element := range []interface{}{u8, u16, u32, u64, b, hash} {
+		binary.Write(&expect, binary.LittleEndian, element)
+	}
+	if !bytes.Equal(buf.Bytes(), expect.Bytes()) {
+		t.Fatalf("elements written %x, expect %x", buf.Bytes(), expect.Bytes())
+	}
+
+	var ru8 uint8
+	var ru16 uint16
+	var ru32 uint32
+	var ru64 uint64
+	var rb bool
+	var rhash Uint168
+	if err := ReadElements(&buf, &ru8, &ru16, &ru32, &ru64, &rb, &rhash); err != nil {
+		t.Fatal(err)
+	}
+	if ru8 != u8 || ru16 != u16 || ru32 != u32 || ru64 != u64 || rb != b || rhash != hash {
+		t.Errorf("elements read %d %d %d %d %v %v", ru8, ru16, ru32, ru64, rb, rhash)
+	}
+}
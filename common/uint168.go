@@ -52,11 +52,7 @@ func (self *Uint168) ToArrayReverse() []byte {
 }
 
 func (self *Uint168) Serialize(w io.Writer) (int, error) {
-	b_buf := bytes.NewBuffer([]byte{})
-	binary.Write(b_buf, binary.LittleEndian, self)
-
-	len, err := w.Write(b_buf.Bytes())
-
+	len, err := w.Write(self[:])
 	if err != nil {
 		return 0, err
 	}
@@ -66,9 +62,8 @@ func (self *Uint168) Serialize(w io.Writer) (int, error) {
 
 func (self *Uint168) Deserialize(r io.Reader) error {
 	p := make([]byte, UINT168SIZE)
-	n, err := r.Read(p)
-
-	if n <= 0 || err != nil {
+	_, err := io.ReadFull(r, p)
+	if err != nil {
 		return err
 	}
 
@@ -3,7 +3,6 @@ package common
 import (
 	"io"
 	"errors"
-	"encoding/binary"
 )
 
 const UINT256SIZE = 32
@@ -48,11 +47,13 @@ func (u *Uint256) BytesReverse() []byte {
 }
 
 func (u *Uint256) Serialize(w io.Writer) error {
-	return binary.Write(w, binary.LittleEndian, u)
+	_, err := w.Write(u[:])
+	return err
 }
 
 func (u *Uint256) Deserialize(r io.Reader) error {
-	return binary.Read(r, binary.LittleEndian, u)
+	_, err := io.ReadFull(r, u[:])
+	return err
 }
 
 func (u *Uint256) String() string {
@@ -69,3 +70,12 @@ func Uint256FromBytes(f []byte) (*Uint256, error) {
 
 	return &hash, nil
 }
+
+// Parse the hash from the reversed hex string, as it's shown by the block explorers
+func Uint256FromReversedHex(value string) (*Uint256, error) {
+	data, err := HexStringToBytesReverse(value)
+	if err != nil {
+		return nil, err
+	}
+	return Uint256FromBytes(data)
+}
@@ -10,6 +10,9 @@ import (
 	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 )
 
+// Max hashes in a merkle branch, a merkle tree of uint32 leaves is not deeper
+const MaxMerkleBranchLen = 32
+
 var (
 	AuxPowChainID         = 1
 	pchMergedMiningHeader = []byte{0xfa, 0xbe, 'm', 'm'}
@@ -112,7 +115,7 @@ func (ap *AuxPow) Deserialize(r io.Reader) error {
 	}
 	ap.AuxMerkleIndex = int(temp)
 
-	count, err := serialization.ReadVarUint(r, 0)
+	count, err := serialization.ReadCount(r, MaxMerkleBranchLen, UINT256SIZE)
 	if err != nil {
 		return err
 	}
@@ -133,7 +136,7 @@ func (ap *AuxPow) Deserialize(r io.Reader) error {
 	}
 	ap.ParMerkleIndex = int(temp)
 
-	count, err = serialization.ReadVarUint(r, 0)
+	count, err = serialization.ReadCount(r, MaxMerkleBranchLen, UINT256SIZE)
 	if err != nil {
 		return err
 	}
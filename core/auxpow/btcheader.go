@@ -1,7 +1,6 @@
 package auxpow
 
 import (
-	"bytes"
 	"io"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
@@ -93,7 +92,7 @@ func (bh *BtcHeader) Deserialize(r io.Reader) error {
 }
 
 func (bh *BtcHeader) Hash() Uint256 {
-	buf := new(bytes.Buffer)
-	bh.Serialize(buf)
-	return Sha256D(buf.Bytes())
+	w := serialization.NewHashWriter()
+	bh.Serialize(w)
+	return w.Sum256D()
 }
@@ -1,8 +1,6 @@
 package auxpow
 
 import (
-	"bytes"
-	"crypto/sha256"
 	"encoding/binary"
 	"io"
 
@@ -10,6 +8,12 @@ import (
 	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 )
 
+// Min serialized size of an input, the outpoint, an empty script and the sequence, and of an output
+const (
+	minTxInSize  = UINT256SIZE + 4 + 1 + 4
+	minTxOutSize = 8 + 1
+)
+
 type BtcOutPoint struct {
 	Hash  Uint256
 	Index uint32
@@ -168,7 +172,7 @@ func (tx *Tx) Deserialize(r io.Reader) error {
 	}
 	tx.Version = int32(binary.LittleEndian.Uint32(buf[:]))
 
-	count, err := serialization.ReadVarUint(r, 0)
+	count, err := serialization.ReadCount(r, 0, minTxInSize)
 	if err != nil {
 		return err
 	}
@@ -183,7 +187,7 @@ func (tx *Tx) Deserialize(r io.Reader) error {
 		tx.TxIn[i] = &ti
 	}
 
-	count, err = serialization.ReadVarUint(r, 0)
+	count, err = serialization.ReadCount(r, 0, minTxOutSize)
 	if err != nil {
 		return err
 	}
@@ -208,10 +212,9 @@ func (tx *Tx) Deserialize(r io.Reader) error {
 }
 
 func (tx *Tx) Hash() Uint256 {
-	b_buf := new(bytes.Buffer)
-	tx.Serialize(b_buf)
-	temp := sha256.Sum256(b_buf.Bytes())
-	return Uint256(sha256.Sum256(temp[:]))
+	w := serialization.NewHashWriter()
+	tx.Serialize(w)
+	return w.Sum256D()
 }
 
 func NewBtcTx(txIn []*TxIn, txOut []*TxOut) *Tx {
@@ -18,6 +18,8 @@ type Header struct {
 	Nonce      uint32
 	Height     uint32
 	AuxPow     AuxPow
+	// Proof of work of a sidechain header, it's serialized instead of the AuxPow if not nil
+	SideAuxPow *SideAuxPow
 }
 
 func (header *Header) SerializeWithoutAux(w io.Writer) error {
@@ -38,7 +40,11 @@ func (header *Header) Serialize(w io.Writer) error {
 		return err
 	}
 
-	err = header.AuxPow.Serialize(w)
+	if header.SideAuxPow != nil {
+		err = header.SideAuxPow.Serialize(w)
+	} else {
+		err = header.AuxPow.Serialize(w)
+	}
 	if err != nil {
 		return err
 	}
@@ -60,14 +66,24 @@ func (header *Header) DeserializeWithoutAux(r io.Reader) error {
 	)
 }
 
+// Deserialize the header, with the SideAuxPow if it's set to decode the sidechain headers by SetSideChain()
 func (header *Header) Deserialize(r io.Reader) error {
+	return header.deserialize(r, IsSideChain())
+}
+
+func (header *Header) deserialize(r io.Reader, side bool) error {
 	err := header.DeserializeWithoutAux(r)
 	if err != nil {
 		return err
 	}
 
 	// AuxPow
-	err = header.AuxPow.Deserialize(r)
+	if side {
+		header.SideAuxPow = new(SideAuxPow)
+		err = header.SideAuxPow.Deserialize(r)
+	} else {
+		err = header.AuxPow.Deserialize(r)
+	}
 	if err != nil {
 		return err
 	}
@@ -78,9 +94,9 @@ func (header *Header) Deserialize(r io.Reader) error {
 }
 
 func (header *Header) Hash() *Uint256 {
-	buf := new(bytes.Buffer)
-	header.SerializeWithoutAux(buf)
-	hash := Uint256(Sha256D(buf.Bytes()))
+	w := NewHashWriter()
+	header.SerializeWithoutAux(w)
+	hash := w.Sum256D()
 	return &hash
 }
 
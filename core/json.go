@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// headerInfo is the JSON view of a header, hashes are reversed hex strings and the proof of work
+// is the hex string of the serialized AuxPow, or the SideAuxPow of a sidechain header.
+// Hash is informational and ignored when unmarshalled
+type headerInfo struct {
+	Hash       string `json:"hash"`
+	Version    uint32 `json:"version"`
+	Previous   string `json:"previous"`
+	MerkleRoot string `json:"merkleroot"`
+	Timestamp  uint32 `json:"timestamp"`
+	Bits       uint32 `json:"bits"`
+	Nonce      uint32 `json:"nonce"`
+	Height     uint32 `json:"height"`
+	AuxPow     string `json:"auxpow,omitempty"`
+	SideAuxPow string `json:"sideauxpow,omitempty"`
+}
+
+func (header Header) MarshalJSON() ([]byte, error) {
+	info := &headerInfo{
+		Hash:       BytesToHexString(header.Hash().BytesReverse()),
+		Version:    header.Version,
+		Previous:   BytesToHexString(header.Previous.BytesReverse()),
+		MerkleRoot: BytesToHexString(header.MerkleRoot.BytesReverse()),
+		Timestamp:  header.Timestamp,
+		Bits:       header.Bits,
+		Nonce:      header.Nonce,
+		Height:     header.Height,
+	}
+	buf := new(bytes.Buffer)
+	if header.SideAuxPow != nil {
+		if err := header.SideAuxPow.Serialize(buf); err != nil {
+			return nil, err
+		}
+		info.SideAuxPow = BytesToHexString(buf.Bytes())
+	} else {
+		if err := header.AuxPow.Serialize(buf); err != nil {
+			return nil, err
+		}
+		info.AuxPow = BytesToHexString(buf.Bytes())
+	}
+	return json.Marshal(info)
+}
+
+func (header *Header) UnmarshalJSON(data []byte) error {
+	info := new(headerInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	previous, err := Uint256FromReversedHex(info.Previous)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "invalid header previous "+info.Previous)
+	}
+	merkleRoot, err := Uint256FromReversedHex(info.MerkleRoot)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "invalid header merkle root "+info.MerkleRoot)
+	}
+
+	decoded := Header{
+		Version:    info.Version,
+		Previous:   *previous,
+		MerkleRoot: *merkleRoot,
+		Timestamp:  info.Timestamp,
+		Bits:       info.Bits,
+		Nonce:      info.Nonce,
+		Height:     info.Height,
+	}
+	if info.SideAuxPow != "" {
+		sideAuxPow, err := HexStringToBytes(info.SideAuxPow)
+		if err != nil {
+			return errors.Wrap(errors.ErrInvalid, "invalid header side auxpow hex string")
+		}
+		decoded.SideAuxPow = new(SideAuxPow)
+		if err := decoded.SideAuxPow.Deserialize(bytes.NewReader(sideAuxPow)); err != nil {
+			return errors.Wrap(errors.ErrInvalid, "invalid header side auxpow")
+		}
+	} else {
+		auxPow, err := HexStringToBytes(info.AuxPow)
+		if err != nil {
+			return errors.Wrap(errors.ErrInvalid, "invalid header auxpow hex string")
+		}
+		if err := decoded.AuxPow.Deserialize(bytes.NewReader(auxPow)); err != nil {
+			return errors.Wrap(errors.ErrInvalid, "invalid header auxpow")
+		}
+	}
+	*header = decoded
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"io"
+	"sync/atomic"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/core/auxpow"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+)
+
+/*
+SideAuxPow is the proof of work of a sidechain block merge mined with the main chain, it's carried
+by the sidechain headers instead of the AuxPow. The side mining transaction on the main chain commits
+the sidechain block hash, it's in the main chain block by the merkle branch, and the main chain header
+is the proof of work.
+*/
+type SideAuxPow struct {
+	SideAuxMerkleBranch []Uint256
+	SideAuxMerkleIndex  int
+	SideAuxBlockTx      tx.Transaction
+	MainBlockHeader     Header
+}
+
+func (sap *SideAuxPow) Serialize(w io.Writer) error {
+	err := sap.SideAuxBlockTx.Serialize(w)
+	if err != nil {
+		return err
+	}
+
+	err = serialization.WriteVarUint(w, uint64(len(sap.SideAuxMerkleBranch)))
+	if err != nil {
+		return err
+	}
+	for _, branch := range sap.SideAuxMerkleBranch {
+		err = branch.Serialize(w)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = serialization.WriteUint32(w, uint32(sap.SideAuxMerkleIndex))
+	if err != nil {
+		return err
+	}
+
+	return sap.MainBlockHeader.Serialize(w)
+}
+
+func (sap *SideAuxPow) Deserialize(r io.Reader) error {
+	// The side mining transaction is a main chain one
+	err := sap.SideAuxBlockTx.DeserializeWithPayloads(r, tx.MainChainPayloads)
+	if err != nil {
+		return err
+	}
+
+	count, err := serialization.ReadCount(r, auxpow.MaxMerkleBranchLen, UINT256SIZE)
+	if err != nil {
+		return err
+	}
+	sap.SideAuxMerkleBranch = nil
+	for i := uint64(0); i < count; i++ {
+		var branch Uint256
+		err = branch.Deserialize(r)
+		if err != nil {
+			return err
+		}
+		sap.SideAuxMerkleBranch = append(sap.SideAuxMerkleBranch, branch)
+	}
+
+	index, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	sap.SideAuxMerkleIndex = int(index)
+
+	return sap.MainBlockHeader.deserialize(r, false)
+}
+
+// Check the side mining transaction commits the sidechain block hash and it's in the main chain block
+func (sap *SideAuxPow) Check(hashAuxBlock *Uint256) bool {
+	txHash := sap.SideAuxBlockTx.Hash()
+	if auxpow.GetMerkleRoot(*txHash, sap.SideAuxMerkleBranch, sap.SideAuxMerkleIndex) != sap.MainBlockHeader.MerkleRoot {
+		return false
+	}
+
+	sideMining, ok := sap.SideAuxBlockTx.Payload.(*payload.SideMining)
+	if !ok {
+		return false
+	}
+	return sideMining.SideBlockHash.IsEqual(hashAuxBlock)
+}
+
+var sideChain int32
+
+// Set the headers decoded to carry the SideAuxPow of a sidechain instead of the AuxPow of the main chain,
+// it's process wide and should be set before any header decoded
+func SetSideChain(side bool) {
+	var value int32
+	if side {
+		value = 1
+	}
+	atomic.StoreInt32(&sideChain, value)
+}
+
+// Check if the headers decoded are sidechain headers
+func IsSideChain() bool {
+	return atomic.LoadInt32(&sideChain) == 1
+}
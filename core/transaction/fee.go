@@ -0,0 +1,129 @@
+package transaction
+
+import (
+	"errors"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+const (
+	// Serialized size of an input, refer txid 32 bytes, output index 2 bytes and sequence 4 bytes
+	InputSize = 38
+
+	// Serialized size of an output, asset id 32 bytes, value 8 bytes,
+	// output lock 4 bytes and program hash 21 bytes
+	OutputSize = 65
+
+	// Max serialized size of the nonce attribute of a transfer transaction,
+	// usage 1 byte and the decimal string of a random int64 with it's length
+	MaxNonceAttributeSize = 1 + 1 + 19
+)
+
+// UTXOResolver finds the output referred by an input, usually from the wallet database
+type UTXOResolver func(op *OutPoint) (*Output, error)
+
+// SigType is the program signing a transaction, M signatures of N public keys
+type SigType struct {
+	Type byte
+	M, N int
+}
+
+// The standard program signed by a single public key
+var StandardSig = SigType{Type: STANDARD, M: 1, N: 1}
+
+// The M of N multi-sign program
+func MultiSig(m, n int) SigType {
+	return SigType{Type: MULTISIG, M: m, N: n}
+}
+
+// Size of the redeem script of the program
+func (s SigType) codeSize() int {
+	if s.Type == MULTISIG {
+		// M, N public keys with length prefix, N and CHECKMULTISIG
+		return 1 + s.N*(1+33) + 2
+	}
+	return PublicKeyScriptLength
+}
+
+// Size of the serialized program when all signatures are present
+func (s SigType) programSize() int {
+	parameter := s.M * SignatureScriptLength
+	code := s.codeSize()
+	return varUintSize(uint64(parameter)) + parameter + varUintSize(uint64(code)) + code
+}
+
+/*
+Estimate the signed size of a transfer transaction with the given count of inputs and outputs,
+signed by the given program. The transaction has a nonce attribute as the wallet creates,
+the estimated size is the max size the transaction can be.
+*/
+func EstimateSize(inputs, outputs int, sigType SigType) int {
+	size := 1 + 1 // TxType and PayloadVersion, transfer asset payload is empty
+	size += varUintSize(1) + MaxNonceAttributeSize
+	size += varUintSize(uint64(inputs)) + inputs*InputSize
+	size += varUintSize(uint64(outputs)) + outputs*OutputSize
+	size += 4 // LockTime
+	size += varUintSize(1) + sigType.programSize()
+	return size
+}
+
+// Get the size of the transaction after it fully signed, the missing signatures
+// are counted as if they were appended to the program
+func (tx *Transaction) EstimateSignedSize() int {
+	size := tx.GetSize()
+	haveSign, needSign, err := tx.GetSignStatus()
+	if err != nil || haveSign >= needSign {
+		return size
+	}
+
+	parameter := len(tx.Programs[0].Parameter)
+	signed := parameter + (needSign-haveSign)*SignatureScriptLength
+	return size - varUintSize(uint64(parameter)) + varUintSize(uint64(signed)) + signed - parameter
+}
+
+// Get the fee of the transaction, the total value of the inputs minus the outputs
+func (tx *Transaction) Fee(resolver UTXOResolver) (Fixed64, error) {
+	var fee Fixed64
+	for _, input := range tx.Inputs {
+		output, err := resolver(NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex))
+		if err != nil {
+			return 0, errors.New("refer output of input " + input.ReferTxID.String() + " not found")
+		}
+		fee += output.Value
+	}
+	for _, output := range tx.Outputs {
+		fee -= output.Value
+	}
+	if fee < 0 {
+		return 0, errors.New("outputs value is more than inputs")
+	}
+	return fee, nil
+}
+
+// Get the fee per KB of the signed transaction size
+func (tx *Transaction) FeeRate(resolver UTXOResolver) (Fixed64, error) {
+	fee, err := tx.Fee(resolver)
+	if err != nil {
+		return 0, err
+	}
+	return fee * 1000 / Fixed64(tx.EstimateSignedSize()), nil
+}
+
+// Calculate the fee of the given size in bytes by the fee rate per KB, rounded up
+// so the fee rate of the transaction is never less than the given fee rate
+func FeeBySize(feeRate Fixed64, size int) Fixed64 {
+	return (feeRate*Fixed64(size) + 999) / 1000
+}
+
+func varUintSize(value uint64) int {
+	switch {
+	case value < 0xFD:
+		return 1
+	case value <= 0xFFFF:
+		return 3
+	case value <= 0xFFFFFFFF:
+		return 5
+	default:
+		return 9
+	}
+}
@@ -1,13 +1,13 @@
 package transaction
 
 import (
-	"io"
 	"fmt"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
-	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 )
 
+// Input spends the output of a previous transaction
+// +serialgen
 type Input struct {
 	//Indicate the previous Tx which include the UTXO output for usage
 	ReferTxID Uint256
@@ -27,35 +27,6 @@ func (self Input) String() string {
 		"}"
 }
 
-func (ui *Input) Serialize(w io.Writer) {
-	ui.ReferTxID.Serialize(w)
-	serialization.WriteUint16(w, ui.ReferTxOutputIndex)
-	serialization.WriteUint32(w, ui.Sequence)
-}
-
-func (ui *Input) Deserialize(r io.Reader) error {
-	//referTxID
-	err := ui.ReferTxID.Deserialize(r)
-	if err != nil {
-		return err
-	}
-
-	//Output Index
-	temp, err := serialization.ReadUint16(r)
-	ui.ReferTxOutputIndex = uint16(temp)
-	if err != nil {
-		return err
-	}
-
-	temp2, err := serialization.ReadUint32(r)
-	ui.Sequence = uint32(temp2)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (ui *Input) Equals(other *Input) bool {
 	if ui == other {
 		return true
@@ -0,0 +1,258 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core/contract/program"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+/*
+TransactionInfo is the structured JSON view of a transaction. Hashes like the transaction id,
+referred transaction id and asset id are reversed hex strings as shown by block explorers,
+the payload, attribute data and programs are hex strings of their serialized bytes.
+TxID, Size, TypeName, UsageName and Record are informational and ignored when encoding.
+*/
+type TransactionInfo struct {
+	TxID           string           `json:"txid"`
+	Size           int              `json:"size"`
+	TxType         uint8            `json:"type"`
+	TypeName       string           `json:"typename"`
+	PayloadVersion uint8            `json:"payloadversion"`
+	Payload        string           `json:"payload"`
+	Attributes     []*AttributeInfo `json:"attributes"`
+	Inputs         []*InputInfo     `json:"inputs"`
+	Outputs        []*OutputInfo    `json:"outputs"`
+	LockTime       uint32           `json:"locktime"`
+	Programs       []*ProgramInfo   `json:"programs"`
+	Record         *RecordInfo      `json:"record,omitempty"`
+}
+
+// RecordInfo is the record carried by a record transaction, data is hex string
+type RecordInfo struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+type AttributeInfo struct {
+	Usage     uint8  `json:"usage"`
+	UsageName string `json:"usagename"`
+	Data      string `json:"data"`
+}
+
+type InputInfo struct {
+	TxID     string `json:"txid"`
+	Index    uint16 `json:"index"`
+	Sequence uint32 `json:"sequence"`
+}
+
+type OutputInfo struct {
+	AssetID    string `json:"assetid"`
+	Value      string `json:"value"`
+	OutputLock uint32 `json:"outputlock"`
+	Address    string `json:"address"`
+}
+
+type ProgramInfo struct {
+	Code      string `json:"code"`
+	Parameter string `json:"parameter"`
+}
+
+// outPointInfo is the JSON view of an outpoint, txid is reversed hex string
+type outPointInfo struct {
+	TxID  string `json:"txid"`
+	Index uint16 `json:"index"`
+}
+
+// Marshal the transaction as the TransactionInfo view
+func (tx Transaction) MarshalJSON() ([]byte, error) {
+	info, err := NewTransactionInfo(&tx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(info)
+}
+
+// Unmarshal the transaction from the TransactionInfo view
+func (tx *Transaction) UnmarshalJSON(data []byte) error {
+	info := new(TransactionInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	txn, err := info.ToTransaction()
+	if err != nil {
+		return err
+	}
+	*tx = *txn
+	return nil
+}
+
+func (op OutPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&outPointInfo{TxID: BytesToHexString(op.TxID.BytesReverse()), Index: op.Index})
+}
+
+func (op *OutPoint) UnmarshalJSON(data []byte) error {
+	info := new(outPointInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	txId, err := Uint256FromReversedHex(info.TxID)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "invalid outpoint txid "+info.TxID)
+	}
+	op.TxID, op.Index = *txId, info.Index
+	return nil
+}
+
+// Get the structured view of the transaction
+func NewTransactionInfo(txn *Transaction) (*TransactionInfo, error) {
+	payload := new(bytes.Buffer)
+	err := txn.Payload.Serialize(payload, txn.PayloadVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &TransactionInfo{
+		TxID:           BytesToHexString(txn.Hash().BytesReverse()),
+		Size:           txn.GetSize(),
+		TxType:         uint8(txn.TxType),
+		TypeName:       txn.TxType.Name(),
+		PayloadVersion: txn.PayloadVersion,
+		Payload:        BytesToHexString(payload.Bytes()),
+		LockTime:       txn.LockTime,
+	}
+
+	for _, attr := range txn.Attributes {
+		info.Attributes = append(info.Attributes, &AttributeInfo{
+			Usage:     uint8(attr.Usage),
+			UsageName: attr.Usage.Name(),
+			Data:      BytesToHexString(attr.Data),
+		})
+	}
+
+	for _, input := range txn.Inputs {
+		info.Inputs = append(info.Inputs, &InputInfo{
+			TxID:     BytesToHexString(input.ReferTxID.BytesReverse()),
+			Index:    input.ReferTxOutputIndex,
+			Sequence: input.Sequence,
+		})
+	}
+
+	for _, output := range txn.Outputs {
+		address, err := output.ProgramHash.ToAddress()
+		if err != nil {
+			return nil, err
+		}
+		info.Outputs = append(info.Outputs, &OutputInfo{
+			AssetID:    BytesToHexString(output.AssetID.BytesReverse()),
+			Value:      output.Value.String(),
+			OutputLock: output.OutputLock,
+			Address:    address,
+		})
+	}
+
+	for _, p := range txn.Programs {
+		info.Programs = append(info.Programs, &ProgramInfo{
+			Code:      BytesToHexString(p.Code),
+			Parameter: BytesToHexString(p.Parameter),
+		})
+	}
+	info.Record = GetRecord(txn)
+
+	return info, nil
+}
+
+// Get the record carried by the transaction, nil if it's not a record transaction
+func GetRecord(txn *Transaction) *RecordInfo {
+	record, ok := txn.Payload.(*payload.Record)
+	if txn.TxType != Record || !ok {
+		return nil
+	}
+	return &RecordInfo{Type: record.RecordType, Data: BytesToHexString(record.RecordData)}
+}
+
+// Get the transaction of the structured view
+func (info *TransactionInfo) ToTransaction() (*Transaction, error) {
+	txn := &Transaction{
+		TxType:         TransactionType(info.TxType),
+		PayloadVersion: info.PayloadVersion,
+		LockTime:       info.LockTime,
+	}
+
+	var err error
+	txn.Payload, err = NewPayload(txn.TxType)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := HexStringToBytes(info.Payload)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid payload hex string")
+	}
+	err = txn.Payload.Deserialize(bytes.NewReader(payload), txn.PayloadVersion)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid payload of transaction type "+txn.TxType.Name())
+	}
+
+	for _, attr := range info.Attributes {
+		data, err := HexStringToBytes(attr.Data)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid attribute data hex string")
+		}
+		usage := AttributeUsage(attr.Usage)
+		if !IsValidAttributeType(usage) {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid attribute usage")
+		}
+		attribute := NewAttribute(usage, data)
+		txn.Attributes = append(txn.Attributes, &attribute)
+	}
+
+	for _, input := range info.Inputs {
+		txId, err := Uint256FromReversedHex(input.TxID)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid input txid "+input.TxID)
+		}
+		txn.Inputs = append(txn.Inputs, &Input{
+			ReferTxID:          *txId,
+			ReferTxOutputIndex: input.Index,
+			Sequence:           input.Sequence,
+		})
+	}
+
+	for _, output := range info.Outputs {
+		assetId, err := Uint256FromReversedHex(output.AssetID)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid output asset id "+output.AssetID)
+		}
+		value, err := StringToFixed64(output.Value)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid output value "+output.Value)
+		}
+		programHash, err := Uint168FromAddress(output.Address)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid output address "+output.Address)
+		}
+		txn.Outputs = append(txn.Outputs, &Output{
+			AssetID:     *assetId,
+			Value:       *value,
+			OutputLock:  output.OutputLock,
+			ProgramHash: *programHash,
+		})
+	}
+
+	for _, p := range info.Programs {
+		code, err := HexStringToBytes(p.Code)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid program code hex string")
+		}
+		parameter, err := HexStringToBytes(p.Parameter)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid program parameter hex string")
+		}
+		txn.Programs = append(txn.Programs, &program.Program{Code: code, Parameter: parameter})
+	}
+
+	return txn, nil
+}
@@ -1,40 +1,25 @@
 package transaction
 
 import (
-	"io"
 	"bytes"
+	"encoding/binary"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
-	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 )
 
+// OutPoint refers to an output by the txid and the index
+// +serialgen
 type OutPoint struct {
 	TxID  Uint256
 	Index uint16
 }
 
-func (op *OutPoint) Serialize(w io.Writer) error {
-	err := serialization.WriteElements(w, op.TxID, op.Index)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (op *OutPoint) Deserialize(r io.Reader) error {
-	err := serialization.ReadElements(r, &op.TxID, &op.Index)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
+// Bytes is the serialized outpoint, it's on the bloom filter hot path so build it without reflection
 func (op *OutPoint) Bytes() []byte {
-	buf := new(bytes.Buffer)
-	op.Serialize(buf)
-	return buf.Bytes()
+	buf := make([]byte, UINT256SIZE+2)
+	copy(buf, op.TxID[:])
+	binary.LittleEndian.PutUint16(buf[UINT256SIZE:], op.Index)
+	return buf
 }
 
 func NewOutPoint(txId Uint256, index uint16) *OutPoint {
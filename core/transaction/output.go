@@ -1,13 +1,13 @@
 package transaction
 
 import (
-	"io"
 	"fmt"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
-	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 )
 
+// Output is the value paid to the program hash, it can be spent after the output lock height
+// +serialgen
 type Output struct {
 	AssetID     Uint256
 	Value       Fixed64
@@ -23,18 +23,3 @@ func (self Output) String() string {
 		"ProgramHash: " + self.ProgramHash.String() + "\n\t\t" +
 		"}"
 }
-
-func (o *Output) Serialize(w io.Writer) {
-	o.AssetID.Serialize(w)
-	o.Value.Serialize(w)
-	serialization.WriteUint32(w, o.OutputLock)
-	o.ProgramHash.Serialize(w)
-}
-
-func (o *Output) Deserialize(r io.Reader) {
-	o.AssetID.Deserialize(r)
-	o.Value.Deserialize(r)
-	temp, _ := serialization.ReadUint32(r)
-	o.OutputLock = uint32(temp)
-	o.ProgramHash.Deserialize(r)
-}
@@ -60,6 +60,7 @@ func (dc *DeployCode) Serialize(w io.Writer, version byte) error {
 }
 
 func (dc *DeployCode) Deserialize(r io.Reader, version byte) error {
+	dc.Code = new(FunctionCode)
 	err := dc.Code.Deserialize(r)
 	if err != nil {
 		return err
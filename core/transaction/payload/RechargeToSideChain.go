@@ -0,0 +1,48 @@
+package payload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+)
+
+const RechargeToSideChainPayloadVersion byte = 0x00
+
+// RechargeToSideChain is the payload of a sidechain transaction recharging a deposit on the main chain
+type RechargeToSideChain struct {
+	// Merkle proof of the deposit transaction in the main chain block
+	MerkleProof []byte
+	// Serialized deposit transaction on the main chain
+	MainChainTransaction []byte
+}
+
+func (a *RechargeToSideChain) Data(version byte) []byte {
+	buf := new(bytes.Buffer)
+	if err := a.Serialize(buf, version); err != nil {
+		return []byte{0}
+	}
+	return buf.Bytes()
+}
+
+func (a *RechargeToSideChain) Serialize(w io.Writer, version byte) error {
+	if err := serialization.WriteVarBytes(w, a.MerkleProof); err != nil {
+		return errors.New("[RechargeToSideChain], MerkleProof serialize failed.")
+	}
+	if err := serialization.WriteVarBytes(w, a.MainChainTransaction); err != nil {
+		return errors.New("[RechargeToSideChain], MainChainTransaction serialize failed.")
+	}
+	return nil
+}
+
+func (a *RechargeToSideChain) Deserialize(r io.Reader, version byte) error {
+	var err error
+	if a.MerkleProof, err = serialization.ReadVarBytes(r); err != nil {
+		return errors.New("[RechargeToSideChain], MerkleProof deserialize failed.")
+	}
+	if a.MainChainTransaction, err = serialization.ReadVarBytes(r); err != nil {
+		return errors.New("[RechargeToSideChain], MainChainTransaction deserialize failed.")
+	}
+	return nil
+}
@@ -9,11 +9,29 @@ import (
 
 const RecordPayloadVersion byte = 0x00
 
+const (
+	// Max bytes of the record type
+	MaxRecordTypeSize = 64
+	// Max bytes of the record data
+	MaxRecordDataSize = 4096
+)
+
 type Record struct {
 	RecordType string
 	RecordData []byte
 }
 
+// Create a record payload, the type and data must be in the size limits
+func NewRecord(recordType string, recordData []byte) (*Record, error) {
+	if len(recordType) == 0 || len(recordType) > MaxRecordTypeSize {
+		return nil, errors.New("[Record], Invalid record type size")
+	}
+	if len(recordData) == 0 || len(recordData) > MaxRecordDataSize {
+		return nil, errors.New("[Record], Invalid record data size")
+	}
+	return &Record{RecordType: recordType, RecordData: recordData}, nil
+}
+
 func (a *Record) Data(version byte) []byte {
 	//TODO: implement RegisterRecord.Data()
 	return []byte{0}
@@ -0,0 +1,70 @@
+package transaction
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+)
+
+// PayloadType is a transaction type of a chain, the name and how to create it's payload
+type PayloadType struct {
+	Name string
+	New  func() Payload
+}
+
+// PayloadSet is the transaction types of a chain
+type PayloadSet map[TransactionType]PayloadType
+
+// Transaction types of the main chain
+var MainChainPayloads = PayloadSet{
+	CoinBase:                {"CoinBase", func() Payload { return new(payload.CoinBase) }},
+	RegisterAsset:           {"RegisterAsset", func() Payload { return new(payload.RegisterAsset) }},
+	TransferAsset:           {"TransferAsset", func() Payload { return new(payload.TransferAsset) }},
+	Record:                  {"Record", func() Payload { return new(payload.Record) }},
+	Deploy:                  {"Deploy", func() Payload { return new(payload.DeployCode) }},
+	SideMining:              {"SideMining", func() Payload { return new(payload.SideMining) }},
+	IssueToken:              {"IssueToken", func() Payload { return new(payload.IssueToken) }},
+	TransferCrossChainAsset: {"TransferCrossChainAsset", newTransferCrossChainAsset},
+}
+
+// Transaction types of the sidechains merge mined with the main chain
+var SideChainPayloads = PayloadSet{
+	CoinBase:                {"CoinBase", func() Payload { return new(payload.CoinBase) }},
+	RegisterAsset:           {"RegisterAsset", func() Payload { return new(payload.RegisterAsset) }},
+	TransferAsset:           {"TransferAsset", func() Payload { return new(payload.TransferAsset) }},
+	Record:                  {"Record", func() Payload { return new(payload.Record) }},
+	Deploy:                  {"Deploy", func() Payload { return new(payload.DeployCode) }},
+	RechargeToSideChain:     {"RechargeToSideChain", func() Payload { return new(payload.RechargeToSideChain) }},
+	TransferCrossChainAsset: {"TransferCrossChainAsset", newTransferCrossChainAsset},
+}
+
+func newTransferCrossChainAsset() Payload {
+	return &payload.TransferCrossChainAsset{PublicKeys: make(map[string]uint64)}
+}
+
+// Create an empty payload of the transaction type to deserialize into
+func (set PayloadSet) NewPayload(txType TransactionType) (Payload, error) {
+	payloadType, ok := set[txType]
+	if !ok {
+		return nil, errors.New("[Transaction], invalid transaction type.")
+	}
+	return payloadType.New(), nil
+}
+
+var payloads atomic.Value
+
+func init() {
+	payloads.Store(MainChainPayloads)
+}
+
+// Set the process wide payload set the transactions are decoded with, it's the main chain one by default.
+// Set it before any transaction decoded, like the SideChainPayloads to follow a sidechain
+func SetPayloadSet(set PayloadSet) {
+	payloads.Store(set)
+}
+
+// Get the payload set in use
+func GetPayloadSet() PayloadSet {
+	return payloads.Load().(PayloadSet)
+}
@@ -0,0 +1,93 @@
+// Code generated by serialgen. DO NOT EDIT.
+
+package transaction
+
+import (
+	"io"
+
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+)
+
+func (i *Input) Serialize(w io.Writer) error {
+	var err error
+	if err = i.ReferTxID.Serialize(w); err != nil {
+		return err
+	}
+	if err = serialization.WriteUint16(w, i.ReferTxOutputIndex); err != nil {
+		return err
+	}
+	if err = serialization.WriteUint32(w, i.Sequence); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (i *Input) Deserialize(r io.Reader) error {
+	var err error
+	if err = i.ReferTxID.Deserialize(r); err != nil {
+		return err
+	}
+	if i.ReferTxOutputIndex, err = serialization.ReadUint16(r); err != nil {
+		return err
+	}
+	if i.Sequence, err = serialization.ReadUint32(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *OutPoint) Serialize(w io.Writer) error {
+	var err error
+	if err = o.TxID.Serialize(w); err != nil {
+		return err
+	}
+	if err = serialization.WriteUint16(w, o.Index); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *OutPoint) Deserialize(r io.Reader) error {
+	var err error
+	if err = o.TxID.Deserialize(r); err != nil {
+		return err
+	}
+	if o.Index, err = serialization.ReadUint16(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *Output) Serialize(w io.Writer) error {
+	var err error
+	if err = o.AssetID.Serialize(w); err != nil {
+		return err
+	}
+	if err = o.Value.Serialize(w); err != nil {
+		return err
+	}
+	if err = serialization.WriteUint32(w, o.OutputLock); err != nil {
+		return err
+	}
+	if _, err = o.ProgramHash.Serialize(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *Output) Deserialize(r io.Reader) error {
+	var err error
+	if err = o.AssetID.Deserialize(r); err != nil {
+		return err
+	}
+	if err = o.Value.Deserialize(r); err != nil {
+		return err
+	}
+	if o.OutputLock, err = serialization.ReadUint32(r); err != nil {
+		return err
+	}
+	if err = o.ProgramHash.Deserialize(r); err != nil {
+		return err
+	}
+	return nil
+}
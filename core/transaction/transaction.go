@@ -1,5 +1,7 @@
 package transaction
 
+//go:generate go run ../../common/serialization/serialgen/main.go
+
 import (
 	"bytes"
 	"errors"
@@ -9,8 +11,8 @@ import (
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 	"github.com/elastos/Elastos.ELA.SPV/core/contract/program"
-	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
 	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	"github.com/elastos/Elastos.ELA.SPV/crypto/ecc"
 )
 
 //for different transaction types with different payload format
@@ -27,6 +29,9 @@ const (
 	IssueToken              TransactionType = 0x06
 	TransferCrossChainAsset TransactionType = 0x07
 
+	// Transaction type of the sidechains, it's IssueToken on the main chain
+	RechargeToSideChain TransactionType = 0x06
+
 	PUSH1 = 0x51
 
 	STANDARD   = 0xAC
@@ -34,27 +39,12 @@ const (
 	CROSSCHAIN = 0xAF
 )
 
+// Get the name of the transaction type in the payload set in use
 func (self TransactionType) Name() string {
-	switch self {
-	case CoinBase:
-		return "CoinBase"
-	case RegisterAsset:
-		return "RegisterAsset"
-	case TransferAsset:
-		return "TransferAsset"
-	case Record:
-		return "Record"
-	case Deploy:
-		return "Deploy"
-	case SideMining:
-		return "SideMining"
-	case IssueToken:
-		return "IssueToken"
-	case TransferCrossChainAsset:
-		return "TransferCrossChainAsset"
-	default:
-		return "Unknown"
+	if payloadType, ok := GetPayloadSet()[self]; ok {
+		return payloadType.Name
 	}
+	return "Unknown"
 }
 
 const (
@@ -93,6 +83,9 @@ type Transaction struct {
 	Outputs        []*Output
 	LockTime       uint32
 	Programs       []*program.Program
+
+	// payload set of DeserializeWithPayloads(), nil means the one in use
+	payloads PayloadSet
 }
 
 func (tx *Transaction) String() string {
@@ -190,7 +183,7 @@ func (tx *Transaction) Deserialize(r io.Reader) error {
 	}
 
 	// tx program
-	lens, err := serialization.ReadVarUint(r, 0)
+	lens, err := serialization.ReadCount(r, 0, 2)
 	if err != nil {
 		return errors.New("transaction tx program Deserialize error")
 	}
@@ -199,7 +192,9 @@ func (tx *Transaction) Deserialize(r io.Reader) error {
 	if lens > 0 {
 		for i := 0; i < int(lens); i++ {
 			outputHashes := new(program.Program)
-			outputHashes.Deserialize(r)
+			if err := outputHashes.Deserialize(r); err != nil {
+				return err
+			}
 			programHashes = append(programHashes, outputHashes)
 		}
 		tx.Programs = programHashes
@@ -207,6 +202,15 @@ func (tx *Transaction) Deserialize(r io.Reader) error {
 	return nil
 }
 
+// Deserialize the transaction with the payload set instead of the one in use,
+// like a main chain transaction carried by a sidechain header
+func (tx *Transaction) DeserializeWithPayloads(r io.Reader, payloads PayloadSet) error {
+	tx.payloads = payloads
+	defer func() { tx.payloads = nil }()
+
+	return tx.Deserialize(r)
+}
+
 func (tx *Transaction) DeserializeUnsigned(r io.Reader) error {
 	var txType [1]byte
 	_, err := io.ReadFull(r, txType[:])
@@ -225,32 +229,20 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 		return err
 	}
 
-	switch tx.TxType {
-	case CoinBase:
-		tx.Payload = new(payload.CoinBase)
-	case RegisterAsset:
-		tx.Payload = new(payload.RegisterAsset)
-	case TransferAsset:
-		tx.Payload = new(payload.TransferAsset)
-	case Record:
-		tx.Payload = new(payload.Record)
-	case Deploy:
-		tx.Payload = new(payload.DeployCode)
-	case SideMining:
-		tx.Payload = new(payload.SideMining)
-	case IssueToken:
-		tx.Payload = new(payload.IssueToken)
-	case TransferCrossChainAsset:
-		tx.Payload = new(payload.TransferCrossChainAsset)
-	default:
-		return errors.New("[Transaction], invalid transaction type.")
+	payloads := tx.payloads
+	if payloads == nil {
+		payloads = GetPayloadSet()
+	}
+	tx.Payload, err = payloads.NewPayload(tx.TxType)
+	if err != nil {
+		return err
 	}
 	err = tx.Payload.Deserialize(r, tx.PayloadVersion)
 	if err != nil {
 		return errors.New("Payload Parse error")
 	}
 	//attributes
-	Len, err := serialization.ReadVarUint(r, 0)
+	Len, err := serialization.ReadCount(r, 0, 2)
 	if err != nil {
 		return err
 	}
@@ -265,7 +257,7 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 		}
 	}
 	//Inputs
-	Len, err = serialization.ReadVarUint(r, 0)
+	Len, err = serialization.ReadCount(r, 0, UINT256SIZE+2+4)
 	if err != nil {
 		return err
 	}
@@ -281,14 +273,16 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 	}
 	//TODO balanceInputs
 	//Outputs
-	Len, err = serialization.ReadVarUint(r, 0)
+	Len, err = serialization.ReadCount(r, 0, UINT256SIZE+8+4+UINT168SIZE)
 	if err != nil {
 		return err
 	}
 	if Len > uint64(0) {
 		for i := uint64(0); i < Len; i++ {
 			output := new(Output)
-			output.Deserialize(r)
+			if err := output.Deserialize(r); err != nil {
+				return err
+			}
 
 			tx.Outputs = append(tx.Outputs, output)
 		}
@@ -303,6 +297,11 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 	return nil
 }
 
+// Create an empty payload of the transaction type in the payload set in use to deserialize into
+func NewPayload(txType TransactionType) (Payload, error) {
+	return GetPayloadSet().NewPayload(txType)
+}
+
 func (tx *Transaction) GetSize() int {
 	var buffer bytes.Buffer
 	if err := tx.Serialize(&buffer); err != nil {
@@ -321,10 +320,9 @@ func (tx *Transaction) GetPrograms() []*program.Program {
 }
 
 func (tx *Transaction) Hash() *Uint256 {
-	var hash Uint256
-	buf := new(bytes.Buffer)
-	tx.SerializeUnsigned(buf)
-	hash = Sha256D(buf.Bytes())
+	w := serialization.NewHashWriter()
+	tx.SerializeUnsigned(w)
+	hash := w.Sum256D()
 	return &hash
 }
 
@@ -469,7 +467,7 @@ func (tx *Transaction) AppendSignature(signerIndex int, signature []byte) error
 			if err != nil {
 				return err
 			}
-			err = crypto.Verify(*pubKey, buf.Bytes(), sign)
+			err = ecc.Verify(pubKey, buf.Bytes(), sign)
 			if err == nil {
 				return errors.New("signer already signed")
 			}
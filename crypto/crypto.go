@@ -67,6 +67,7 @@ func GenerateSubKeyPair(index int, chainCode, parentPrivateKey []byte) ([]byte,
 	return digest, publicKey, nil
 }
 
+// Deprecated: the signature is not deterministic, use ecc.Sign instead
 func Sign(priKey []byte, data []byte) ([]byte, error) {
 
 	digest := sha256.Sum256(data)
@@ -75,6 +76,8 @@ func Sign(priKey []byte, data []byte) ([]byte, error) {
 	privateKey.Curve = algSet.Curve
 	privateKey.D = big.NewInt(0)
 	privateKey.D.SetBytes(priKey)
+	privateKey.PublicKey.Curve = algSet.Curve
+	privateKey.PublicKey.X, privateKey.PublicKey.Y = algSet.Curve.ScalarBaseMult(priKey)
 
 	r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
 	if err != nil {
@@ -91,6 +94,7 @@ func Sign(priKey []byte, data []byte) ([]byte, error) {
 	return signature, nil
 }
 
+// Deprecated: use ecc.Verify instead
 func Verify(publicKey PublicKey, data []byte, signature []byte) error {
 	len := len(signature)
 	if len != SignatureLength {
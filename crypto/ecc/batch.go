@@ -0,0 +1,57 @@
+package ecc
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+// BatchItem is a signature to verify in a batch
+type BatchItem struct {
+	PublicKey *crypto.PublicKey
+	Data      []byte
+	Signature []byte
+}
+
+/*
+Verify a batch of signatures, return nil if all of them are valid, otherwise the error of the
+first invalid signature in the batch order. ECDSA signatures can not be combined and verified at
+once, the batch is verified in parallel on all CPUs instead, which is how multi-sign transactions
+and blocks of signed messages are verified faster.
+*/
+func VerifyBatch(items []*BatchItem) error {
+	errs := make([]error, len(items))
+
+	workers := runtime.NumCPU()
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	var wg sync.WaitGroup
+	indexes := make(chan int, len(items))
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				item := items[i]
+				errs[i] = Verify(item.PublicKey, item.Data, item.Signature)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("signature %d verify failed, %s", i, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Package ecc is the ECDSA signing and verification over the curve used by Elastos (NIST P-256)
+with SHA-256 digests. Signatures are deterministic as described in RFC6979, the nonce is derived
+from the private key and the digest, so signing never depends on a random source and the same
+data always gets the same signature. Signatures are in the 64 bytes compact format r || s used
+by transaction programs, use CompactToDER and DERToCompact to convert from or to DER encoding.
+*/
+package ecc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+const (
+	// Length of the private key and each of r and s
+	KeyLength = 32
+
+	// Length of a compact signature r || s
+	SignatureLength = crypto.SignatureLength
+)
+
+// The curve used by Elastos
+var Curve = elliptic.P256()
+
+// Sign the SHA-256 digest of data with the private key
+func Sign(privateKey []byte, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return SignDigest(privateKey, digest[:])
+}
+
+// Sign the digest with the private key, return the compact signature r || s
+func SignDigest(privateKey []byte, digest []byte) ([]byte, error) {
+	params := Curve.Params()
+	d := new(big.Int).SetBytes(privateKey)
+	if d.Sign() == 0 || d.Cmp(params.N) >= 0 {
+		return nil, errors.New("invalid private key")
+	}
+	e := hashToInt(digest)
+
+	nonces := newNonceGenerator(d, digest)
+	for {
+		k := nonces.next()
+
+		// r = (k * G).x mod n
+		x, _ := Curve.ScalarBaseMult(k.Bytes())
+		r := new(big.Int).Mod(x, params.N)
+		if r.Sign() == 0 {
+			continue
+		}
+
+		// s = k^-1 * (e + r * d) mod n
+		s := new(big.Int).Mul(r, d)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, params.N))
+		s.Mod(s, params.N)
+		if s.Sign() == 0 {
+			continue
+		}
+
+		return toCompact(r, s), nil
+	}
+}
+
+// Verify the compact signature of the SHA-256 digest of data
+func Verify(publicKey *crypto.PublicKey, data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	return VerifyDigest(publicKey, digest[:], signature)
+}
+
+// Verify the compact signature of the digest
+func VerifyDigest(publicKey *crypto.PublicKey, digest []byte, signature []byte) error {
+	if publicKey == nil || publicKey.X == nil || publicKey.Y == nil ||
+		!Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return errors.New("invalid public key")
+	}
+	if len(signature) != SignatureLength {
+		return errors.New("invalid signature length")
+	}
+
+	pub := &ecdsa.PublicKey{Curve: Curve, X: publicKey.X, Y: publicKey.Y}
+	r := new(big.Int).SetBytes(signature[:KeyLength])
+	s := new(big.Int).SetBytes(signature[KeyLength:])
+	if !ecdsa.Verify(pub, digest, r, s) {
+		return errors.New("[Validation], Verify failed.")
+	}
+	return nil
+}
+
+// Convert the digest to an integer as ECDSA does, use the leftmost bits if it's longer than the order
+func hashToInt(digest []byte) *big.Int {
+	orderBits := Curve.Params().N.BitLen()
+	orderBytes := (orderBits + 7) / 8
+	if len(digest) > orderBytes {
+		digest = digest[:orderBytes]
+	}
+
+	ret := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - orderBits; excess > 0 {
+		ret.Rsh(ret, uint(excess))
+	}
+	return ret
+}
+
+// Get the bytes of the integer left padded to length
+func intToBytes(v *big.Int, length int) []byte {
+	buf := make([]byte, length)
+	b := v.Bytes()
+	copy(buf[length-len(b):], b)
+	return buf
+}
+
+// nonceGenerator generates the nonces of RFC6979 section 3.2 with HMAC-SHA256
+type nonceGenerator struct {
+	n    *big.Int
+	k, v []byte
+	used bool
+}
+
+func newNonceGenerator(d *big.Int, digest []byte) *nonceGenerator {
+	n := Curve.Params().N
+
+	// bits2octets(h1), the digest reduced mod n
+	z := hashToInt(digest)
+	if z.Cmp(n) >= 0 {
+		z.Sub(z, n)
+	}
+	seed := append(intToBytes(d, KeyLength), intToBytes(z, KeyLength)...)
+
+	g := &nonceGenerator{
+		n: n,
+		k: make([]byte, sha256.Size),
+		v: make([]byte, sha256.Size),
+	}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	g.k = g.mac(g.k, g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.k, g.v)
+	g.k = g.mac(g.k, g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.k, g.v)
+	return g
+}
+
+func (g *nonceGenerator) mac(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// Get the next nonce in [1, n-1], following calls continue the generation
+// when the previous nonce gave an invalid signature
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		if g.used {
+			g.k = g.mac(g.k, g.v, []byte{0x00})
+			g.v = g.mac(g.k, g.v)
+		}
+		g.used = true
+
+		// The order length equals to the HMAC output length, one round is enough
+		g.v = g.mac(g.k, g.v)
+		k := hashToInt(g.v)
+		if k.Sign() > 0 && k.Cmp(g.n) < 0 {
+			return k
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ecc
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+// Test vector of RFC6979 A.2.5, ECDSA with P-256 and SHA-256, message "sample"
+func TestSignRFC6979(t *testing.T) {
+	privateKey, _ := HexStringToBytes("c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721")
+	expect, _ := HexStringToBytes("efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716" +
+		"f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8")
+
+	signature, err := Sign(privateKey, []byte("sample"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(signature, expect) {
+		t.Fatalf("signature %x, expect %x", signature, expect)
+	}
+
+	publicKey := crypto.NewPubKey(privateKey)
+	if err := Verify(publicKey, []byte("sample"), signature); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(publicKey, []byte("samples"), signature); err == nil {
+		t.Fatal("signature of other data verified")
+	}
+}
+
+func TestDER(t *testing.T) {
+	privateKey, publicKey, _ := crypto.GenerateKeyPair()
+	signature, _ := Sign(privateKey, []byte("data"))
+
+	der, err := CompactToDER(signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := DERToCompact(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(compact, signature) {
+		t.Fatal("DER round trip failed")
+	}
+	if err := Verify(publicKey, []byte("data"), compact); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DERToCompact(append(der, 0)); err == nil {
+		t.Fatal("DER signature with trailing data accepted")
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	var items []*BatchItem
+	for i := 0; i < 10; i++ {
+		privateKey, publicKey, _ := crypto.GenerateKeyPair()
+		data := []byte{byte(i)}
+		signature, _ := Sign(privateKey, data)
+		items = append(items, &BatchItem{PublicKey: publicKey, Data: data, Signature: signature})
+	}
+	if err := VerifyBatch(items); err != nil {
+		t.Fatal(err)
+	}
+
+	items[3].Data = []byte{0xff}
+	if err := VerifyBatch(items); err == nil {
+		t.Fatal("batch with invalid signature verified")
+	}
+}
@@ -0,0 +1,48 @@
+package ecc
+
+import (
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// The DER structure of an ECDSA signature
+type derSignature struct {
+	R, S *big.Int
+}
+
+func toCompact(r, s *big.Int) []byte {
+	return append(intToBytes(r, KeyLength), intToBytes(s, KeyLength)...)
+}
+
+// Convert the compact signature r || s into DER encoding
+func CompactToDER(signature []byte) ([]byte, error) {
+	if len(signature) != SignatureLength {
+		return nil, errors.New("invalid signature length")
+	}
+
+	r := new(big.Int).SetBytes(signature[:KeyLength])
+	s := new(big.Int).SetBytes(signature[KeyLength:])
+	if r.Sign() == 0 || s.Sign() == 0 {
+		return nil, errors.New("invalid signature, r or s is zero")
+	}
+	return asn1.Marshal(derSignature{R: r, S: s})
+}
+
+// Convert the DER encoded signature into the compact signature r || s
+func DERToCompact(der []byte) ([]byte, error) {
+	var sig derSignature
+	rest, err := asn1.Unmarshal(der, &sig)
+	if err != nil {
+		return nil, errors.New("invalid DER signature, " + err.Error())
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("invalid DER signature, trailing data")
+	}
+
+	n := Curve.Params().N
+	if sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.Cmp(n) >= 0 || sig.S.Cmp(n) >= 0 {
+		return nil, errors.New("invalid DER signature, r or s out of range")
+	}
+	return toCompact(sig.R, sig.S), nil
+}
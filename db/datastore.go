@@ -2,19 +2,20 @@ package db
 
 import (
 	"github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 )
 
 type DataStore interface {
 	// Save a header to database
 	PutHeader(header *StoreHeader, newTip bool) error
 
-	// Get previous block of the given header
+	// Get previous block of the given header, an error of errors.ErrNotFound if not exist
 	GetPrevious(header *StoreHeader) (*StoreHeader, error)
 
-	// Get full header with it's hash
+	// Get full header with it's hash, an error of errors.ErrNotFound if not exist
 	GetHeader(hash common.Uint256) (*StoreHeader, error)
 
-	// Get the header on chain tip
+	// Get the header on chain tip, an error of errors.ErrNotFound if no header saved
 	GetChainTip() (*StoreHeader, error)
 
 	// Save chain height to database
@@ -35,3 +36,16 @@ type DataStore interface {
 	// Close the database
 	Close()
 }
+
+// HeightIndex is the optional interface of a DataStore to get the headers on the best chain by height
+type HeightIndex interface {
+	// Get the header on the best chain at the height, an error of errors.ErrNotFound if not exist
+	GetHeaderByHeight(height uint32) (*StoreHeader, error)
+}
+
+// OutputValues is the optional interface of a DataStore to get the values of the wallet outputs,
+// the summaries of the processed blocks count the values received and spent by the wallet with it
+type OutputValues interface {
+	// Get the value of the unspent output of the wallet, false if it's not an unspent wallet output
+	GetOutputValue(op *tx.OutPoint) (common.Fixed64, bool)
+}
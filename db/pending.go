@@ -0,0 +1,86 @@
+package db
+
+import (
+	"bytes"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Max transactions matched in a pending block, a block can not have more
+const MaxPendingTxs = 100000
+
+/*
+PendingBlock is a merkleblock received with the transactions matched by the bloom filter not fetched
+yet. It's saved until the block is committed, so if the process stops in the middle the transactions
+are fetched again after restart instead of lost.
+*/
+type PendingBlock struct {
+	Block bloom.MerkleBlock
+	TxIds []common.Uint256
+}
+
+// PendingStore is the optional interface of a DataStore to persist the pending blocks
+type PendingStore interface {
+	// Save a pending block, replace the one of the same block hash
+	PutPendingBlock(block *PendingBlock) error
+
+	// Delete the pending block of the block hash, it's not an error if not exist
+	DelPendingBlock(hash common.Uint256) error
+
+	// Get all pending blocks saved
+	GetPendingBlocks() ([]*PendingBlock, error)
+}
+
+func (b *PendingBlock) Serialize() ([]byte, error) {
+	block, err := b.Block.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = serialization.WriteVarBytes(buf, block)
+	if err != nil {
+		return nil, err
+	}
+	err = serialization.WriteVarUint(buf, uint64(len(b.TxIds)))
+	if err != nil {
+		return nil, err
+	}
+	for _, txId := range b.TxIds {
+		err = txId.Serialize(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *PendingBlock) Deserialize(data []byte) error {
+	r := bytes.NewReader(data)
+	block, err := serialization.ReadVarBytes(r)
+	if err != nil {
+		return err
+	}
+	err = b.Block.Deserialize(block)
+	if err != nil {
+		return err
+	}
+
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > MaxPendingTxs {
+		return errors.Wrap(errors.ErrCorrupted, "too many transactions in pending block")
+	}
+	b.TxIds = make([]common.Uint256, count)
+	for i := range b.TxIds {
+		err = b.TxIds[i].Deserialize(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Max block locator entries in a snapshot
+const MaxLocatorEntries = 500
+
+// LocatorEntry is a block hash in the block locator with it's height
+type LocatorEntry struct {
+	Height uint32
+	Hash   common.Uint256
+}
+
+/*
+ChainSnapshot is the compact state of the best chain, the tip, the cumulative work of it and
+the block locator. It's saved every time the chain tip changed, so on restart the sync can start
+from the saved locator without walking back the headers to build it.
+*/
+type ChainSnapshot struct {
+	Tip       common.Uint256
+	Height    uint32
+	TotalWork *big.Int
+	Locator   []LocatorEntry
+}
+
+// SnapshotStore is the optional interface of a DataStore to persist the chain snapshot
+type SnapshotStore interface {
+	// Save the chain snapshot, replace the previous one
+	PutChainSnapshot(snapshot *ChainSnapshot) error
+
+	// Get the saved chain snapshot
+	GetChainSnapshot() (*ChainSnapshot, error)
+}
+
+// Get the block hashes of the locator
+func (s *ChainSnapshot) LocatorHashes() []*common.Uint256 {
+	hashes := make([]*common.Uint256, 0, len(s.Locator))
+	for i := range s.Locator {
+		hash := s.Locator[i].Hash
+		hashes = append(hashes, &hash)
+	}
+	return hashes
+}
+
+func (s *ChainSnapshot) Serialize() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := s.Tip.Serialize(buf)
+	if err != nil {
+		return nil, err
+	}
+	err = serialization.WriteUint32(buf, s.Height)
+	if err != nil {
+		return nil, err
+	}
+	err = serialization.WriteVarBytes(buf, s.TotalWork.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	err = serialization.WriteVarUint(buf, uint64(len(s.Locator)))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range s.Locator {
+		err = serialization.WriteUint32(buf, entry.Height)
+		if err != nil {
+			return nil, err
+		}
+		err = entry.Hash.Serialize(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *ChainSnapshot) Deserialize(b []byte) error {
+	r := bytes.NewReader(b)
+	err := s.Tip.Deserialize(r)
+	if err != nil {
+		return err
+	}
+	s.Height, err = serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	work, err := serialization.ReadVarBytes(r)
+	if err != nil {
+		return err
+	}
+	s.TotalWork = new(big.Int).SetBytes(work)
+
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > MaxLocatorEntries {
+		return errors.Wrap(errors.ErrCorrupted, "too many block locator entries in snapshot")
+	}
+	s.Locator = make([]LocatorEntry, count)
+	for i := range s.Locator {
+		s.Locator[i].Height, err = serialization.ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		err = s.Locator[i].Hash.Deserialize(r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
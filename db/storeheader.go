@@ -2,6 +2,8 @@ package db
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"math/big"
 
 	"github.com/elastos/Elastos.ELA.SPV/core"
@@ -43,3 +45,31 @@ func (sh *StoreHeader) Deserialize(b []byte) error {
 
 	return nil
 }
+
+// storeHeaderInfo is the JSON view of a stored header, total work is a decimal string
+type storeHeaderInfo struct {
+	Header    core.Header `json:"header"`
+	TotalWork string      `json:"totalwork"`
+}
+
+// The methods of the embedded header would marshal the total work away, it's added here
+func (sh StoreHeader) MarshalJSON() ([]byte, error) {
+	info := &storeHeaderInfo{Header: sh.Header, TotalWork: "0"}
+	if sh.TotalWork != nil {
+		info.TotalWork = sh.TotalWork.String()
+	}
+	return json.Marshal(info)
+}
+
+func (sh *StoreHeader) UnmarshalJSON(data []byte) error {
+	info := new(storeHeaderInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	totalWork, ok := new(big.Int).SetString(info.TotalWork, 10)
+	if !ok {
+		return errors.New("invalid header total work " + info.TotalWork)
+	}
+	sh.Header, sh.TotalWork = info.Header, totalWork
+	return nil
+}
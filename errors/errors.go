@@ -0,0 +1,165 @@
+/*
+Package errors is the error taxonomy of the SPV packages. The errors returned by db, sdk, p2p,
+interface and the wallet are of the kinds below where it applies, so a caller can branch on the
+kind by Is() rather than matching the error messages, like
+
+	if errors.Is(err, errors.ErrInsufficientFunds) {
+		// ask for a smaller amount
+	}
+
+An error of a kind is created by Wrap(), Wrapf() or WrapErr() with it's own message, the messages
+are not changed by the kind. Is(), As() and Unwrap() follow the Is, As and Unwrap methods of the
+errors in the chain the same as the errors package of the Go 1.13 standard library, which is not
+required to build, and New() is the same as the standard one, so the package can replace the
+standard errors package in the imports.
+*/
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// The error kinds
+var (
+	// The requested data, like a header, transaction, proof or address, does not exist
+	ErrNotFound = errors.New("not found")
+
+	// The stored data can not be decoded or is inconsistent
+	ErrCorrupted = errors.New("data corrupted")
+
+	// The peer sent invalid data or violated the protocol, it should be disconnected
+	ErrPeerMisbehaving = errors.New("peer misbehaving")
+
+	// The available balance does not cover the amount and the fee
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// The transaction spends an output already spent
+	ErrDoubleSpend = errors.New("double spend")
+
+	// The argument, like an address, transaction or block, is invalid or not allowed by the rules
+	ErrInvalid = errors.New("invalid")
+
+	// The request did not complete in time
+	ErrTimeout = errors.New("timeout")
+
+	// The service is not started
+	ErrNotStarted = errors.New("not started")
+
+	// A write to the database failed, like the disk is full or the database is damaged,
+	// the stored data may be inconsistent
+	ErrStorage = errors.New("storage failure")
+
+	// The wallet is locked, the keys are not available to sign until it's unlocked by the password
+	ErrLocked = errors.New("wallet locked")
+
+	// Too many failed attempts, the request is refused until the retry delay passed
+	ErrThrottled = errors.New("throttled")
+)
+
+// An error of a kind with it's own message, and the cause if any
+type kindError struct {
+	kind  error
+	msg   string
+	cause error
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+// Match the kind, an error of a kind wrapping another kind matches both of them
+func (e *kindError) Is(target error) bool {
+	return Is(e.kind, target)
+}
+
+func (e *kindError) Unwrap() error {
+	return e.cause
+}
+
+// Create an error of the kind with the message
+func Wrap(kind error, message string) error {
+	return &kindError{kind: kind, msg: message}
+}
+
+// Create an error of the kind with the formatted message
+func Wrapf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+// Create an error of the kind caused by err, the message is the formatted message
+// followed by the message of err, and err is returned by Unwrap()
+func WrapErr(kind error, err error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...) + ", " + err.Error(), cause: err}
+}
+
+// Create an error without kind, the same as errors.New() of the standard package
+func New(text string) error {
+	return errors.New(text)
+}
+
+// Get the error wrapped by err, or nil if err has no Unwrap method
+func Unwrap(err error) error {
+	u, ok := err.(interface {
+		Unwrap() error
+	})
+	if !ok {
+		return nil
+	}
+	return u.Unwrap()
+}
+
+// Report if any error in the chain of err, err followed by the errors got by
+// repeatedly calling Unwrap(), equals the target or matches it by an Is method
+func Is(err, target error) bool {
+	if target == nil {
+		return err == target
+	}
+	comparable := reflect.TypeOf(target).Comparable()
+	for err != nil {
+		if comparable && err == target {
+			return true
+		}
+		if x, ok := err.(interface {
+			Is(error) bool
+		}); ok && x.Is(target) {
+			return true
+		}
+		err = Unwrap(err)
+	}
+	return false
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Find the first error in the chain of err assignable to the value target points to,
+// set the target to it and return true, or return false if not found.
+// It panics if target is not a non-nil pointer to an interface or a type implementing error
+func As(err error, target interface{}) bool {
+	if target == nil {
+		panic("errors: target cannot be nil")
+	}
+	val := reflect.ValueOf(target)
+	typ := val.Type()
+	if typ.Kind() != reflect.Ptr || val.IsNil() {
+		panic("errors: target must be a non-nil pointer")
+	}
+	targetType := typ.Elem()
+	if targetType.Kind() != reflect.Interface && !targetType.Implements(errorType) {
+		panic("errors: *target must be interface or implement error")
+	}
+	for err != nil {
+		if reflect.TypeOf(err).AssignableTo(targetType) {
+			val.Elem().Set(reflect.ValueOf(err))
+			return true
+		}
+		if x, ok := err.(interface {
+			As(interface{}) bool
+		}); ok && x.As(target) {
+			return true
+		}
+		err = Unwrap(err)
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package errors
+
+import (
+	"testing"
+)
+
+// An error wrapping another one, like the RequestError of the sdk
+type wrapper struct {
+	err error
+}
+
+func (w *wrapper) Error() string {
+	return "request failed, " + w.err.Error()
+}
+
+func (w *wrapper) Unwrap() error {
+	return w.err
+}
+
+func TestKinds(t *testing.T) {
+	err := Wrap(ErrNotFound, "header not found")
+	if err.Error() != "header not found" {
+		t.Errorf("error message %q changed by the kind", err.Error())
+	}
+	if !Is(err, ErrNotFound) || Is(err, ErrCorrupted) {
+		t.Error("kind not matched")
+	}
+
+	// Kinds nest, an error of a kind wrapping a kind matches both
+	blockNotFound := Wrap(ErrNotFound, "block not found")
+	err = Wrapf(blockNotFound, "block %d of the confirm not found", 100)
+	if !Is(err, blockNotFound) || !Is(err, ErrNotFound) {
+		t.Error("nested kind not matched")
+	}
+
+	// The cause is unwrapped
+	cause := New("disk failure")
+	err = WrapErr(ErrCorrupted, cause, "read header %d failed", 100)
+	if err.Error() != "read header 100 failed, disk failure" {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+	if Unwrap(err) != cause || !Is(err, cause) || !Is(err, ErrCorrupted) {
+		t.Error("cause not wrapped")
+	}
+
+	// The kind is found through the errors wrapping it
+	err = &wrapper{Wrap(ErrDoubleSpend, "input spent")}
+	if !Is(err, ErrDoubleSpend) || Is(err, ErrNotFound) {
+		t.Error("kind not matched through the wrapping error")
+	}
+	var kindErr *kindError
+	if !As(err, &kindErr) || kindErr.kind != ErrDoubleSpend {
+		t.Error("kind error not found by As")
+	}
+	var w *wrapper
+	if !As(err, &w) || w != err {
+		t.Error("wrapping error not found by As")
+	}
+	if Is(nil, ErrNotFound) || !Is(nil, nil) {
+		t.Error("nil error matched")
+	}
+}
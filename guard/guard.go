@@ -0,0 +1,149 @@
+/*
+Package guard isolates the callbacks supplied by the integrators, like the transaction listeners
+and the message handlers, from the sync loop. A panic from a callback run by a Guard is recovered
+and reported with the stack, then handled by the process wide policy: logged, or the callback
+disabled, or the process crashed as if it's not recovered.
+*/
+package guard
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+)
+
+// Policy is how a panic recovered from a callback is handled
+type Policy int32
+
+const (
+	// Log the panic and keep calling the callback
+	Log Policy = iota
+
+	// Log the panic and disable the callback, it's not called anymore
+	Disable
+
+	// Log the panic and panic again, the process crashes
+	Crash
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Log:
+		return "log"
+	case Disable:
+		return "disable"
+	case Crash:
+		return "crash"
+	}
+	return fmt.Sprintf("Policy(%d)", int32(p))
+}
+
+// Parse the policy of the name, log, disable or crash, an empty name is log
+func ParsePolicy(name string) (Policy, error) {
+	switch name {
+	case "", "log":
+		return Log, nil
+	case "disable":
+		return Disable, nil
+	case "crash":
+		return Crash, nil
+	}
+	return Log, errors.New("unknown panic policy " + name + ", should be log, disable or crash")
+}
+
+var (
+	policy int32
+
+	panics = metrics.NewCounter("spv_callback_panics_total", "Panics recovered from the listener callbacks and message handlers")
+)
+
+// Set the policy of the panics recovered from now on, it's Log by default
+func SetPolicy(p Policy) {
+	atomic.StoreInt32(&policy, int32(p))
+}
+
+// Get the policy of the panics
+func GetPolicy() Policy {
+	return Policy(atomic.LoadInt32(&policy))
+}
+
+// PanicError is the error reported for a panic recovered from a callback
+type PanicError struct {
+	// Name of the callback
+	Name  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s panicked, %v", e.Name, e.Value)
+}
+
+/*
+Guard runs a callback with the panics recovered, create one for each listener or handler,
+so a listener disabled by the Disable policy does not affect the others.
+*/
+type Guard struct {
+	name     string
+	canStop  bool
+	disabled int32
+}
+
+// Create the guard of a listener of the name, it can be disabled by the Disable policy
+func New(name string) *Guard {
+	return &Guard{name: name, canStop: true}
+}
+
+// Create the guard of a handler the sync depends on, it's never disabled,
+// under the Disable policy the panic is logged the same as the Log policy
+func NewHandler(name string) *Guard {
+	return &Guard{name: name}
+}
+
+// Check if the callback is disabled after a panic
+func (g *Guard) Disabled() bool {
+	return atomic.LoadInt32(&g.disabled) == 1
+}
+
+// Run the callback, return the PanicError if the callback panicked, a disabled callback is not run
+func (g *Guard) Run(callback func()) (err error) {
+	if g.Disabled() {
+		return nil
+	}
+	defer func() {
+		err = g.handle(recover())
+	}()
+
+	callback()
+	return nil
+}
+
+// Recover the panic of the function defers it, like defer guard.Recover()
+func (g *Guard) Recover() {
+	g.handle(recover())
+}
+
+// Report the recovered panic and handle it by the policy
+func (g *Guard) handle(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	err := &PanicError{Name: g.name, Value: value, Stack: debug.Stack()}
+	panics.Inc()
+	log.Errorf("%s\n%s", err.Error(), err.Stack)
+
+	switch GetPolicy() {
+	case Disable:
+		if g.canStop {
+			atomic.StoreInt32(&g.disabled, 1)
+			log.Error(g.name, " disabled after panic")
+		}
+	case Crash:
+		panic(value)
+	}
+	return err
+}
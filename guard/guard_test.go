@@ -0,0 +1,77 @@
+package guard
+
+import (
+	"testing"
+)
+
+func TestGuard(t *testing.T) {
+	defer SetPolicy(Log)
+
+	calls := 0
+	callback := func() {
+		calls++
+		panic("listener bug")
+	}
+
+	// Log keeps calling the callback
+	SetPolicy(Log)
+	g := New("listener")
+	for i := 0; i < 2; i++ {
+		err := g.Run(callback)
+		if panicErr, ok := err.(*PanicError); !ok || panicErr.Value != "listener bug" || len(panicErr.Stack) == 0 {
+			t.Fatalf("unexpected error %v of the panic", err)
+		}
+	}
+	if calls != 2 || g.Disabled() {
+		t.Fatalf("callback called %d times, disabled %v, expect 2 times", calls, g.Disabled())
+	}
+	if err := g.Run(func() {}); err != nil {
+		t.Error("error without panic,", err)
+	}
+
+	// Disable stops calling the listener, the handler is kept
+	SetPolicy(Disable)
+	calls = 0
+	g = New("listener")
+	g.Run(callback)
+	if err := g.Run(callback); err != nil || calls != 1 || !g.Disabled() {
+		t.Errorf("listener called %d times after panic, expect disabled", calls)
+	}
+	calls = 0
+	handler := NewHandler("handler")
+	handler.Run(callback)
+	handler.Run(callback)
+	if calls != 2 || handler.Disabled() {
+		t.Errorf("handler called %d times, expect never disabled", calls)
+	}
+
+	// Recover works deferred
+	func() {
+		defer handler.Recover()
+		panic("handler bug")
+	}()
+
+	// Crash panics again
+	SetPolicy(Crash)
+	defer func() {
+		if recover() != "listener bug" {
+			t.Error("panic not raised again by the crash policy")
+		}
+	}()
+	New("listener").Run(callback)
+	t.Error("callback panic recovered by the crash policy")
+}
+
+func TestParsePolicy(t *testing.T) {
+	for _, policy := range []Policy{Log, Disable, Crash} {
+		if parsed, err := ParsePolicy(policy.String()); err != nil || parsed != policy {
+			t.Errorf("parse policy %s got %v, %v", policy, parsed, err)
+		}
+	}
+	if policy, err := ParsePolicy(""); err != nil || policy != Log {
+		t.Error("empty policy is not log")
+	}
+	if _, err := ParsePolicy("ignore"); err == nil {
+		t.Error("unknown policy parsed")
+	}
+}
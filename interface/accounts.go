@@ -0,0 +1,286 @@
+package _interface
+
+import (
+	"sort"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// The account of the addresses registered by RegisterAccount()
+const DefaultAccount = ""
+
+// Priority of a registered address decides how the transactions paid to it are delivered
+type Priority int
+
+const (
+	// Delivered in batches to a BatchTransactionListener
+	PriorityNormal Priority = iota
+
+	// Notified at once by Notify(), bypassing the batches of a BatchTransactionListener
+	PriorityHigh
+)
+
+/*
+accounts groups the registered addresses under named accounts, like the user IDs of an exchange
+deposit system watching the deposit address of each user. An address belongs to one account only,
+so a transaction paid to it is notified to the listeners of that account.
+*/
+type accounts struct {
+	sync.RWMutex
+	addrs      map[string]map[Uint168]struct{}
+	owners     map[Uint168]string
+	priorities map[Uint168]Priority
+}
+
+func newAccounts() *accounts {
+	return &accounts{
+		addrs:      make(map[string]map[Uint168]struct{}),
+		owners:     make(map[Uint168]string),
+		priorities: make(map[Uint168]Priority),
+	}
+}
+
+// Add the address to the account, return if it's new to the account
+func (a *accounts) add(account string, addr Uint168) (bool, error) {
+	a.Lock()
+	defer a.Unlock()
+
+	if owner, ok := a.owners[addr]; ok {
+		if owner != account {
+			return false, errors.Wrapf(errors.ErrInvalid, "address already registered in account %q", owner)
+		}
+		return false, nil
+	}
+	addrs, ok := a.addrs[account]
+	if !ok {
+		addrs = make(map[Uint168]struct{})
+		a.addrs[account] = addrs
+	}
+	addrs[addr] = struct{}{}
+	a.owners[addr] = account
+	return true, nil
+}
+
+// Remove the account and return the addresses it had, or false if no such account
+func (a *accounts) remove(account string) ([]*Uint168, bool) {
+	a.Lock()
+	defer a.Unlock()
+
+	if _, ok := a.addrs[account]; !ok {
+		return nil, false
+	}
+	addrs := a.get(account)
+	for _, addr := range addrs {
+		delete(a.owners, *addr)
+		delete(a.priorities, *addr)
+	}
+	delete(a.addrs, account)
+	return addrs, true
+}
+
+// Get the addresses of the account in a stable order, the lock must be held
+func (a *accounts) get(account string) []*Uint168 {
+	addrs := make([]*Uint168, 0, len(a.addrs[account]))
+	for addr := range a.addrs[account] {
+		addr := addr
+		addrs = append(addrs, &addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].CompareTo(addrs[j]) < 0 })
+	return addrs
+}
+
+// Get the addresses of the account
+func (a *accounts) addresses(account string) []*Uint168 {
+	a.RLock()
+	defer a.RUnlock()
+
+	return a.get(account)
+}
+
+// Get the addresses of all accounts
+func (a *accounts) all() []*Uint168 {
+	a.RLock()
+	defer a.RUnlock()
+
+	addrs := make([]*Uint168, 0, len(a.owners))
+	for addr := range a.owners {
+		addr := addr
+		addrs = append(addrs, &addr)
+	}
+	return addrs
+}
+
+// Get the account of each registered address
+func (a *accounts) owned() map[Uint168]string {
+	a.RLock()
+	defer a.RUnlock()
+
+	owners := make(map[Uint168]string, len(a.owners))
+	for addr, account := range a.owners {
+		owners[addr] = account
+	}
+	return owners
+}
+
+// Set the priority of the registered address, return false if it's not registered
+func (a *accounts) setPriority(addr Uint168, priority Priority) bool {
+	a.Lock()
+	defer a.Unlock()
+
+	if _, ok := a.owners[addr]; !ok {
+		return false
+	}
+	if priority == PriorityNormal {
+		delete(a.priorities, addr)
+	} else {
+		a.priorities[addr] = priority
+	}
+	return true
+}
+
+// Check if any output of the transaction pays to a high priority address
+func (a *accounts) isPriority(txn *tx.Transaction) bool {
+	a.RLock()
+	defer a.RUnlock()
+
+	for _, output := range txn.Outputs {
+		if a.priorities[output.ProgramHash] == PriorityHigh {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if any output of the transaction pays to an address of the account
+func (a *accounts) paidTo(account string, txn *tx.Transaction) bool {
+	a.RLock()
+	defer a.RUnlock()
+
+	addrs := a.addrs[account]
+	for _, output := range txn.Outputs {
+		if _, ok := addrs[output.ProgramHash]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Register the address under the account, the address is watched immediately and persisted
+// with the current height if the service is started, or persisted when the service starts
+func (service *SPVServiceImpl) RegisterAccountAddress(account, address string) error {
+	addr, err := Uint168FromAddress(address)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "Invalid address format")
+	}
+
+	added, err := service.accounts.add(account, *addr)
+	if err != nil || !added || service.SPVWallet == nil {
+		return err
+	}
+
+	err = service.registry.Put(account, addr, service.Blockchain().Height())
+	if err != nil {
+		return err
+	}
+	err = service.DataStore().Addrs().Put(addr, RegisteredAccountScript, db.TypeNotify)
+	if err != nil {
+		return err
+	}
+	service.addrFilter.AddAddr(addr)
+	return service.NotifyNewAddress(addr.ToArray())
+}
+
+// Get the addresses registered under the account
+func (service *SPVServiceImpl) GetAccountAddresses(account string) []string {
+	var addresses []string
+	for _, addr := range service.accounts.addresses(account) {
+		address, err := addr.ToAddress()
+		if err != nil {
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// Get the transactions received of all addresses under the account in height order,
+// a transaction paid to several addresses of the account is included once
+func (service *SPVServiceImpl) GetAccountTransactions(account string) ([]*AddrTx, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	var addrTxs []*AddrTx
+	included := make(map[Uint256]struct{})
+	for _, addr := range service.accounts.addresses(account) {
+		txs, err := service.addrTxs.GetAll(addr)
+		if err != nil {
+			return nil, err
+		}
+		for _, addrTx := range txs {
+			hash := *addrTx.Tx.Hash()
+			if _, ok := included[hash]; ok {
+				continue
+			}
+			included[hash] = struct{}{}
+			addrTxs = append(addrTxs, addrTx)
+		}
+	}
+	sort.SliceStable(addrTxs, func(i, j int) bool { return addrTxs[i].Height < addrTxs[j].Height })
+	return addrTxs, nil
+}
+
+// Register the TransactionListener notified only of the transactions paid to the account,
+// and accepted by the PayloadFilter if it's not nil
+func (service *SPVServiceImpl) RegisterAccountListener(account string, listener TransactionListener, filter PayloadFilter) {
+	service.RegisterFilteredTransactionListener(listener, func(txn tx.Transaction) bool {
+		return service.accounts.paidTo(account, &txn) && (filter == nil || filter(txn))
+	})
+}
+
+// Set the priority of the registered address, like PriorityHigh for the cross chain lock address.
+// Return an error of ErrNotFound if the address is not registered, the priority is not persisted
+func (service *SPVServiceImpl) SetAddressPriority(address string, priority Priority) error {
+	addr, err := Uint168FromAddress(address)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "Invalid address format")
+	}
+	if !service.accounts.setPriority(*addr, priority) {
+		return errors.Wrapf(errors.ErrNotFound, "address %s not registered", address)
+	}
+	return nil
+}
+
+// Remove the account with all its addresses and their received transactions, transactions of
+// the account are not notified once it returns. Return an error of ErrNotFound if no such account
+func (service *SPVServiceImpl) RemoveAccount(account string) error {
+	addrs, ok := service.accounts.remove(account)
+	if !ok {
+		return errors.Wrapf(errors.ErrNotFound, "account %q not registered", account)
+	}
+	if service.SPVWallet == nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		service.addrFilter.DeleteAddr(*addr)
+		if err := service.registry.Delete(addr); err != nil {
+			return err
+		}
+		if err := service.DataStore().Addrs().Delete(addr); err != nil {
+			return err
+		}
+		if err := service.addrTxs.Delete(addr); err != nil {
+			return err
+		}
+	}
+	log.Infof("Account %q removed with %d addresses", account, len(addrs))
+
+	// Reload the wallet filter without the removed addresses
+	return service.NotifyNewAddress(nil)
+}
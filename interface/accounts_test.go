@@ -0,0 +1,64 @@
+package _interface
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+func TestAccounts(t *testing.T) {
+	service := newSPVServiceImpl(0, nil)
+
+	const alice, bob = "ETBBrgotZy3993o9bH75KxjLDgQxBCib6u", "EUyNwnAh5SzzTtAPV1HkXzjUEbw2YqKsUM"
+	if err := service.RegisterAccountAddress("user-1", alice); err != nil {
+		t.Fatal("register account address error,", err)
+	}
+	if err := service.RegisterAccountAddress("user-1", alice); err != nil {
+		t.Error("register the same address again error,", err)
+	}
+	if err := service.RegisterAccountAddress("user-2", alice); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of registering the address in another account", err)
+	}
+	if err := service.RegisterAccount(bob); err != nil {
+		t.Fatal("register account error,", err)
+	}
+
+	if addrs := service.GetAccountAddresses("user-1"); len(addrs) != 1 || addrs[0] != alice {
+		t.Errorf("unexpected addresses %v of the account", addrs)
+	}
+	if addrs := service.GetAccountAddresses(DefaultAccount); len(addrs) != 1 || addrs[0] != bob {
+		t.Errorf("unexpected addresses %v of the default account", addrs)
+	}
+
+	// Only the transactions paid to the account are accepted by the account listener
+	aliceHash, _ := Uint168FromAddress(alice)
+	bobHash, _ := Uint168FromAddress(bob)
+	listener := &UnconfirmedListener{txType: tx.TransferAsset}
+	service.RegisterAccountListener("user-1", listener, nil)
+	filter := service.listeners[tx.TransferAsset][0].filter
+	if !filter(tx.Transaction{Outputs: []*tx.Output{{ProgramHash: *aliceHash}}}) {
+		t.Error("transaction paid to the account not accepted")
+	}
+	if filter(tx.Transaction{Outputs: []*tx.Output{{ProgramHash: *bobHash}}}) {
+		t.Error("transaction paid to another account accepted")
+	}
+
+	// The removed account and its addresses are gone at once
+	if err := service.RemoveAccount("user-1"); err != nil {
+		t.Fatal("remove account error,", err)
+	}
+	if filter(tx.Transaction{Outputs: []*tx.Output{{ProgramHash: *aliceHash}}}) {
+		t.Error("transaction paid to the removed account accepted")
+	}
+	if addrs := service.GetAccountAddresses("user-1"); len(addrs) != 0 {
+		t.Errorf("unexpected addresses %v of the removed account", addrs)
+	}
+	if err := service.RemoveAccount("user-1"); !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("unexpected error %v of removing the account again", err)
+	}
+	if err := service.RegisterAccountAddress("user-2", alice); err != nil {
+		t.Error("register the address of the removed account error,", err)
+	}
+}
@@ -0,0 +1,267 @@
+package _interface
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+
+	"github.com/boltdb/bolt"
+)
+
+// AddrTx is a transaction matched a registered address, with the merkle proof
+// of the block it was packed in
+type AddrTx struct {
+	Height uint32
+	Tx     tx.Transaction
+	Proof  Proof
+}
+
+func (a *AddrTx) Serialize(w io.Writer) error {
+	err := binary.Write(w, binary.LittleEndian, a.Height)
+	if err != nil {
+		return err
+	}
+	err = a.Tx.Serialize(w)
+	if err != nil {
+		return err
+	}
+	return a.Proof.Serialize(w)
+}
+
+func (a *AddrTx) Deserialize(r io.Reader) error {
+	err := binary.Read(r, binary.LittleEndian, &a.Height)
+	if err != nil {
+		return err
+	}
+	err = a.Tx.Deserialize(r)
+	if err != nil {
+		return err
+	}
+	return a.Proof.Deserialize(r)
+}
+
+/*
+AddrTxs stores the transactions and proofs matched the registered addresses.
+Registered addresses are not owned by the wallet keys, so the records are kept here
+instead of the wallet database, and the records out of retention limits will be pruned.
+*/
+type AddrTxs interface {
+	// Put a matched transaction under the registered address
+	Put(addr *Uint168, addrTx *AddrTx) error
+
+	// Get all transactions matched the registered address
+	GetAll(addr *Uint168) ([]*AddrTx, error)
+
+	// Delete all transactions of the registered address
+	Delete(addr *Uint168) error
+
+	// Delete transactions exceed the retention limits on the given chain height
+	Prune(height uint32) error
+
+	// Delete transactions on the given height
+	Rollback(height uint32) error
+
+	// Reset database, clear all data
+	Reset() error
+
+	// Close the address transactions db
+	Close()
+}
+
+const AddrTxsDBName = "addrtxs.bin"
+
+// AddrTxsDB implements AddrTxs using bolt DB, each registered address has it's own bucket
+// and the records are keyed by height and transaction hash, so they are in height order
+type AddrTxsDB struct {
+	*sync.RWMutex
+	*bolt.DB
+	// Max transactions kept for each address, 0 means no limit
+	maxTxs int
+	// Max depth in blocks a transaction kept, 0 means no limit
+	maxDepth uint32
+}
+
+func NewAddrTxsDB(dir string, maxTxs int, maxDepth uint32) (AddrTxs, error) {
+	db, err := bolt.Open(filepath.Join(dir, AddrTxsDBName), 0644, &bolt.Options{InitialMmapSize: 5000000})
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddrTxsDB{
+		RWMutex:  new(sync.RWMutex),
+		DB:       db,
+		maxTxs:   maxTxs,
+		maxDepth: maxDepth,
+	}, nil
+}
+
+// Put a matched transaction under the registered address
+func (db *AddrTxsDB) Put(addr *Uint168, addrTx *AddrTx) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(btx *bolt.Tx) error {
+		bucket, err := btx.CreateBucketIfNotExists(addr.ToArray())
+		if err != nil {
+			return err
+		}
+
+		buf := new(bytes.Buffer)
+		err = addrTx.Serialize(buf)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Put(addrTxKey(addrTx.Height, addrTx.Tx.Hash()), buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		// Remove the oldest transactions exceed the limit
+		if db.maxTxs > 0 {
+			exceeds := -db.maxTxs
+			cursor := bucket.Cursor()
+			for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+				exceeds++
+			}
+			for k, _ := cursor.First(); k != nil && exceeds > 0; k, _ = cursor.First() {
+				err = bucket.Delete(k)
+				if err != nil {
+					return err
+				}
+				exceeds--
+			}
+		}
+
+		return nil
+	})
+}
+
+// Get all transactions matched the registered address
+func (db *AddrTxsDB) GetAll(addr *Uint168) (addrTxs []*AddrTx, err error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	err = db.View(func(btx *bolt.Tx) error {
+		bucket := btx.Bucket(addr.ToArray())
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var addrTx AddrTx
+			err := addrTx.Deserialize(bytes.NewReader(v))
+			if err != nil {
+				return err
+			}
+			addrTxs = append(addrTxs, &addrTx)
+			return nil
+		})
+	})
+
+	return addrTxs, err
+}
+
+// Delete all transactions of the registered address
+func (db *AddrTxsDB) Delete(addr *Uint168) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(btx *bolt.Tx) error {
+		err := btx.DeleteBucket(addr.ToArray())
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// Delete transactions exceed the retention limits on the given chain height
+func (db *AddrTxsDB) Prune(height uint32) error {
+	if db.maxDepth == 0 || height <= db.maxDepth {
+		return nil
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	// Keys are ordered by height, delete from the first one to the limit
+	limit := height - db.maxDepth
+	return db.Update(func(btx *bolt.Tx) error {
+		return btx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			cursor := bucket.Cursor()
+			for k, _ := cursor.First(); k != nil && addrTxHeight(k) < limit; k, _ = cursor.First() {
+				err := bucket.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Delete transactions on the given height
+func (db *AddrTxsDB) Rollback(height uint32) error {
+	db.Lock()
+	defer db.Unlock()
+
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, height)
+	return db.Update(func(btx *bolt.Tx) error {
+		return btx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			cursor := bucket.Cursor()
+			for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Seek(prefix) {
+				err := bucket.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func (db *AddrTxsDB) Reset() error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(btx *bolt.Tx) error {
+		var names [][]byte
+		btx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			names = append(names, name)
+			return nil
+		})
+		for _, name := range names {
+			err := btx.DeleteBucket(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close db
+func (db *AddrTxsDB) Close() {
+	db.Lock()
+	db.DB.Close()
+	log.Debug("Address transactions DB closed")
+}
+
+// Key of a record is big endian height followed by the transaction hash
+func addrTxKey(height uint32, txHash *Uint256) []byte {
+	key := make([]byte, 4, 4+UINT256SIZE)
+	binary.BigEndian.PutUint32(key, height)
+	return append(key, txHash.Bytes()...)
+}
+
+func addrTxHeight(key []byte) uint32 {
+	return binary.BigEndian.Uint32(key[:4])
+}
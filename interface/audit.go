@@ -0,0 +1,135 @@
+package _interface
+
+import (
+	"fmt"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+)
+
+// Recent blocks downloaded in full by an address audit if the depth is not set
+const DefaultAuditDepth = 100
+
+var auditMismatches = metrics.NewCounter("spv_audit_mismatches_total",
+	"Transactions of the registered addresses found by the audits but not matched before")
+
+// A transaction paid to a registered address found by an audit but not matched before
+type AuditMismatch struct {
+	Address string
+	TxHash  Uint256
+	Height  uint32
+}
+
+// AuditReport is the result of an address audit
+type AuditReport struct {
+	// Heights of the audited blocks
+	From, To uint32
+	// Blocks audited, and blocks skipped for the peer did not return all transactions of them
+	Blocks  int
+	Skipped int
+	// Transactions missed by the bloom filter, they are stored when found
+	Mismatches []*AuditMismatch
+}
+
+/*
+Audit the registered addresses by downloading the recent blocks of the depth in full, and reconcile them with the
+matched transactions. Bloom filters have no false negatives, but a bug or a stale filter on the peer can miss a
+transaction, a missed transaction found is stored and raised as an AlertAuditMismatch alert of the Blockchain.
+*/
+func (service *SPVServiceImpl) AuditAddresses(depth uint32) (*AuditReport, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+	if depth == 0 {
+		depth = DefaultAuditDepth
+	}
+
+	blocks, err := service.AuditBlocks(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(AuditReport)
+	if len(blocks) > 0 {
+		report.From, report.To = blocks[0].Height, blocks[len(blocks)-1].Height
+	}
+	// Transactions stored of the addresses by hash, loaded when an address is matched first
+	stored := make(map[Uint168]map[Uint256]bool)
+	for _, block := range blocks {
+		if !block.Complete {
+			report.Skipped++
+			continue
+		}
+		report.Blocks++
+
+		proof := Proof{
+			BlockHash:    *block.Block.BlockHeader.Hash(),
+			Height:       block.Height,
+			Transactions: block.Block.Transactions,
+			Hashes:       block.Block.Hashes,
+			Flags:        block.Block.Flags,
+		}
+		for _, txn := range block.Txs {
+			match := service.addrFilter.MatchTx(&txn)
+			for _, index := range match.Outputs {
+				programHash := txn.Outputs[index].ProgramHash
+				txHashes, ok := stored[programHash]
+				if !ok {
+					txHashes, err = service.storedTxs(&programHash)
+					if err != nil {
+						return nil, err
+					}
+					stored[programHash] = txHashes
+				}
+				if txHashes[*txn.Hash()] {
+					continue
+				}
+				txHashes[*txn.Hash()] = true
+
+				// Reconcile the missed transaction
+				address, _ := programHash.ToAddress()
+				report.Mismatches = append(report.Mismatches, &AuditMismatch{
+					Address: address,
+					TxHash:  *txn.Hash(),
+					Height:  block.Height,
+				})
+				err = service.addrTxs.Put(&programHash, &AddrTx{
+					Height: block.Height,
+					Tx:     txn,
+					Proof:  *getTransactionProof(&proof, *txn.Hash()),
+				})
+				if err != nil {
+					log.Error("Store audited address transaction failed,", err)
+				}
+			}
+		}
+	}
+
+	log.Infof("Address audit of heights %d to %d finished, %d blocks audited, %d skipped, %d mismatches",
+		report.From, report.To, report.Blocks, report.Skipped, len(report.Mismatches))
+	for _, mismatch := range report.Mismatches {
+		auditMismatches.Inc()
+		service.Blockchain().RaiseAlert(&sdk.Alert{
+			Type:    sdk.AlertAuditMismatch,
+			Height:  mismatch.Height,
+			Message: fmt.Sprintf("transaction %s to %s was not matched", mismatch.TxHash.String(), mismatch.Address),
+		})
+	}
+	return report, nil
+}
+
+// Get the hashes of the transactions stored of the address
+func (service *SPVServiceImpl) storedTxs(addr *Uint168) (map[Uint256]bool, error) {
+	addrTxs, err := service.addrTxs.GetAll(addr)
+	if err != nil {
+		return nil, err
+	}
+	txHashes := make(map[Uint256]bool, len(addrTxs))
+	for _, addrTx := range addrTxs {
+		txHashes[*addrTx.Tx.Hash()] = true
+	}
+	return txHashes, nil
+}
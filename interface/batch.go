@@ -0,0 +1,80 @@
+package _interface
+
+import (
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
+)
+
+const (
+	// Transactions in a batch if the BatchSize() of the listener is not positive
+	DefaultBatchSize = 100
+
+	// Max batches waiting to be delivered to a batch listener,
+	// the block commit waits when it's full so no transaction is dropped
+	BatchQueueSize = 100
+)
+
+// BlockContext is the block a batch of transactions notified on
+type BlockContext struct {
+	Hash      Uint256
+	Height    uint32
+	Timestamp uint32
+	// Index of the batch in the block from 0, and the number of batches of the block
+	Batch   int
+	Batches int
+}
+
+// Notification is a transaction notified with the merkle proof to verify it
+type Notification struct {
+	Proof Proof
+	Tx    tx.Transaction
+}
+
+// Start delivering the batches of the batch listener one at a time
+func (listener *registeredListener) startBatches() {
+	listener.batches = make(chan func(), BatchQueueSize)
+	supervisor.Go(fmt.Sprintf("batch transaction listener %T", listener.TransactionListener), func() {
+		for callback := range listener.batches {
+			listener.guard.Run(callback)
+		}
+	})
+}
+
+// Split the pending notifications of each batch listener into batches and queue them
+func notifyBatches(header *core.Header, pending map[*registeredListener][]*Notification) {
+	for listener, notifications := range pending {
+		batchListener := listener.TransactionListener.(BatchTransactionListener)
+		size := DefaultBatchSize
+		listener.guard.Run(func() {
+			if batchSize := batchListener.BatchSize(); batchSize > 0 {
+				size = batchSize
+			}
+		})
+
+		batches := (len(notifications) + size - 1) / size
+		for i := 0; i < batches; i++ {
+			end := (i + 1) * size
+			if end > len(notifications) {
+				end = len(notifications)
+			}
+			batch := notifications[i*size : end]
+			context := BlockContext{
+				Hash:      *header.Hash(),
+				Height:    header.Height,
+				Timestamp: header.Timestamp,
+				Batch:     i,
+				Batches:   batches,
+			}
+			listener.batches <- func() {
+				start := clock.Now()
+				batchListener.NotifyBatch(context, batch)
+				notifyLatency.Observe(clock.Since(start).Seconds())
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package _interface
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+type batchListener struct {
+	UnconfirmedListener
+	batches  chan []*Notification
+	blocks   chan BlockContext
+	notified chan tx.Transaction
+}
+
+func (l *batchListener) Notify(proof Proof, txn tx.Transaction) { l.notified <- txn }
+
+func (l *batchListener) BatchSize() int { return 2 }
+
+func (l *batchListener) NotifyBatch(context BlockContext, batch []*Notification) {
+	l.blocks <- context
+	l.batches <- batch
+}
+
+func TestNotifyBatches(t *testing.T) {
+	service := newSPVServiceImpl(0, nil)
+	listener := &batchListener{
+		UnconfirmedListener: UnconfirmedListener{txType: tx.TransferAsset},
+		batches:             make(chan []*Notification, 10),
+		blocks:              make(chan BlockContext, 10),
+	}
+	service.RegisterTransactionListener(listener)
+
+	header := &core.Header{Height: 100, Timestamp: 1500000000}
+	pending := make(map[*registeredListener][]*Notification)
+	for i := 0; i < 5; i++ {
+		txn := tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset), LockTime: uint32(i)}
+		if !service.notifyListeners(Proof{Height: 100}, txn, false, pending) {
+			t.Fatal("batch listener not notified")
+		}
+	}
+	notifyBatches(header, pending)
+
+	var lockTime uint32
+	for i, size := range []int{2, 2, 1} {
+		select {
+		case context := <-listener.blocks:
+			if context.Batch != i || context.Batches != 3 || context.Height != 100 || !context.Hash.IsEqual(header.Hash()) {
+				t.Errorf("unexpected block context %+v", context)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("batch not delivered")
+		}
+		batch := <-listener.batches
+		if len(batch) != size {
+			t.Errorf("unexpected size %d of batch %d", len(batch), i)
+		}
+		// Transactions are delivered in the notified order
+		for _, notification := range batch {
+			if notification.Tx.LockTime != lockTime {
+				t.Errorf("unexpected transaction %d delivered, expected %d", notification.Tx.LockTime, lockTime)
+			}
+			lockTime++
+		}
+	}
+}
+
+func TestPriorityBypassesBatches(t *testing.T) {
+	service := newSPVServiceImpl(0, nil)
+	listener := &batchListener{
+		UnconfirmedListener: UnconfirmedListener{txType: tx.TransferAsset},
+		notified:            make(chan tx.Transaction, 1),
+	}
+	service.RegisterTransactionListener(listener)
+
+	const lockAddress, userAddress = "ETBBrgotZy3993o9bH75KxjLDgQxBCib6u", "EUyNwnAh5SzzTtAPV1HkXzjUEbw2YqKsUM"
+	service.RegisterAccount(lockAddress)
+	service.RegisterAccount(userAddress)
+	if err := service.SetAddressPriority(lockAddress, PriorityHigh); err != nil {
+		t.Fatal("set address priority error,", err)
+	}
+	if err := service.SetAddressPriority("ERpTjzeVnyuCyddRLPK2ednuSK3rdNKjHP", PriorityHigh); !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("unexpected error %v of setting the priority of an address not registered", err)
+	}
+
+	lockHash, _ := Uint168FromAddress(lockAddress)
+	userHash, _ := Uint168FromAddress(userAddress)
+	pending := make(map[*registeredListener][]*Notification)
+	deposit := tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset), Outputs: []*tx.Output{{ProgramHash: *lockHash}}}
+	service.notifyListeners(Proof{}, deposit, false, pending)
+	transfer := tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset), Outputs: []*tx.Output{{ProgramHash: *userHash}}}
+	service.notifyListeners(Proof{}, transfer, false, pending)
+
+	select {
+	case txn := <-listener.notified:
+		if !txn.Hash().IsEqual(deposit.Hash()) {
+			t.Error("unexpected transaction notified at once")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("high priority transaction not notified at once")
+	}
+	for _, notifications := range pending {
+		if len(notifications) != 1 || !notifications[0].Tx.Hash().IsEqual(transfer.Hash()) {
+			t.Errorf("unexpected pending notifications %v", notifications)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package _interface
+
+import (
+	"bytes"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+/*
+DepositBundle is a cross chain deposit on the main chain with everything the recharging transaction
+on the sidechain needs, the transaction, proof and header are serialized in the format the sidechain
+expects, so a sidechain integration builds the recharge from it without decoding anything.
+*/
+type DepositBundle struct {
+	TxHash Uint256
+	// Serialized deposit transaction
+	Transaction []byte
+	// Serialized merkle proof of the transaction in the block
+	MerkleProof []byte
+	// Serialized header of the block the transaction is in
+	Header []byte
+	// Height of the block and the confirmations of it on the current chain
+	Height        uint32
+	Confirmations uint32
+}
+
+// Create the bundle of the deposit transaction in the block of the proof and header
+func NewDepositBundle(txn *tx.Transaction, proof *Proof, header *core.Header, chainHeight uint32) (*DepositBundle, error) {
+	if txn.TxType != tx.TransferCrossChainAsset {
+		return nil, errors.Wrapf(errors.ErrInvalid, "transaction %s is not a cross chain deposit", txn.Hash().String())
+	}
+	if !proof.BlockHash.IsEqual(header.Hash()) {
+		return nil, errors.Wrapf(errors.ErrInvalid, "proof of block %s does not match the header", proof.BlockHash.String())
+	}
+
+	bundle := &DepositBundle{TxHash: *txn.Hash(), Height: header.Height}
+	if chainHeight >= header.Height {
+		bundle.Confirmations = chainHeight - header.Height + 1
+	}
+
+	buf := new(bytes.Buffer)
+	if err := txn.Serialize(buf); err != nil {
+		return nil, err
+	}
+	bundle.Transaction = buf.Bytes()
+
+	buf = new(bytes.Buffer)
+	if err := proof.Serialize(buf); err != nil {
+		return nil, err
+	}
+	bundle.MerkleProof = buf.Bytes()
+
+	buf = new(bytes.Buffer)
+	if err := header.Serialize(buf); err != nil {
+		return nil, err
+	}
+	bundle.Header = buf.Bytes()
+
+	return bundle, nil
+}
+
+// Get the payload of the recharging transaction on the sidechain
+func (b *DepositBundle) RechargePayload() *payload.RechargeToSideChain {
+	return &payload.RechargeToSideChain{
+		MerkleProof:          b.MerkleProof,
+		MainChainTransaction: b.Transaction,
+	}
+}
+
+// Get the bundle of the received cross chain deposit, return an error of ErrNotFound if it's not received,
+// check the Confirmations before recharging, the deposit is not irreversible on fewer confirmations
+func (service *SPVServiceImpl) GetDepositBundle(txHash Uint256) (*DepositBundle, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	storeTx, err := service.DataStore().Txs().Get(&txHash)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := service.GetTransactionProof(txHash)
+	if err != nil {
+		return nil, err
+	}
+	header, err := service.Blockchain().GetHeader(proof.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDepositBundle(&storeTx.Data, proof, &header.Header, service.Blockchain().ChainTip().Height)
+}
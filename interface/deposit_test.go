@@ -0,0 +1,54 @@
+package _interface
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+func TestDepositBundle(t *testing.T) {
+	deposit := &tx.Transaction{
+		TxType:  tx.TransferCrossChainAsset,
+		Payload: &payload.TransferCrossChainAsset{PublicKeys: map[string]uint64{"EKn3UGyEoRr5bTsoPZhTKrrd1A9LtDRjN3": 0}},
+		Outputs: []*tx.Output{{Value: 100000000}},
+	}
+	header := &core.Header{Height: 100, MerkleRoot: *deposit.Hash()}
+	proof := &Proof{BlockHash: *header.Hash(), Height: 100, Transactions: 1, Hashes: []*Uint256{deposit.Hash()}, Flags: []byte{1}}
+
+	bundle, err := NewDepositBundle(deposit, proof, header, 105)
+	if err != nil {
+		t.Fatal("create deposit bundle error,", err)
+	}
+	if bundle.Confirmations != 6 || bundle.Height != 100 || !bundle.TxHash.IsEqual(deposit.Hash()) {
+		t.Errorf("unexpected bundle %+v", bundle)
+	}
+
+	// The recharge payload carries the transaction and proof as they serialized
+	recharge := bundle.RechargePayload()
+	var decodedTx tx.Transaction
+	if err := decodedTx.Deserialize(bytes.NewReader(recharge.MainChainTransaction)); err != nil || !decodedTx.Hash().IsEqual(deposit.Hash()) {
+		t.Error("deposit transaction not decoded from the recharge payload,", err)
+	}
+	var decodedProof Proof
+	if err := decodedProof.Deserialize(bytes.NewReader(recharge.MerkleProof)); err != nil || !decodedProof.BlockHash.IsEqual(&proof.BlockHash) {
+		t.Error("merkle proof not decoded from the recharge payload,", err)
+	}
+	var decodedHeader core.Header
+	if err := decodedHeader.Deserialize(bytes.NewReader(bundle.Header)); err != nil || !decodedHeader.Hash().IsEqual(header.Hash()) {
+		t.Error("header not decoded from the bundle,", err)
+	}
+
+	// Only a deposit of the block is bundled
+	transfer := &tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset)}
+	if _, err := NewDepositBundle(transfer, proof, header, 105); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of bundling a transfer", err)
+	}
+	if _, err := NewDepositBundle(deposit, proof, &core.Header{Height: 101}, 105); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of bundling with another header", err)
+	}
+}
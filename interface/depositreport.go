@@ -0,0 +1,138 @@
+package _interface
+
+import (
+	"bytes"
+	"sort"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Deposit is an output of a transaction paid to a registered address, found by a deposit report
+type Deposit struct {
+	Address string
+	Account string
+	TxHash  Uint256
+	// Index of the output and the amount credited to the address by it
+	Index  uint16
+	Amount Fixed64
+	// Height of the block and the confirmations of it on the chain height of the report
+	Height        uint32
+	Confirmations uint32
+	// True if the deposit reached the confirmations the listeners are notified on,
+	// or its block is confirmed by the DPoS arbiters
+	Confirmed bool
+	// Merkle proof of the transaction in the block, verified by VerifyTransaction()
+	Proof Proof
+}
+
+// DepositReport is the deposits of the addresses in a range of heights
+type DepositReport struct {
+	// Heights of the blocks reported, and the chain height the confirmations are counted on
+	From, To    uint32
+	ChainHeight uint32
+	// Deposits in the order of height, transaction hash and output index
+	Deposits []*Deposit
+	// Total amount credited to each address in the range, by the address
+	Totals map[string]Fixed64
+	// False if the records of the addresses below the retention depth were pruned,
+	// deposits in the pruned heights are not reported
+	Complete bool
+}
+
+/*
+Report the deposits to the registered addresses in the blocks from height from to height to, inclusive, 0 to
+means the chain tip. The deposits are ordered deterministically by height, transaction hash and output index,
+so a reconciliation job of an exchange compares the reports of two runs line by line. An address not registered
+is an error of ErrNotFound, the records older than MaxAddrTxsDepth are pruned and not reported.
+*/
+func (service *SPVServiceImpl) GetDepositReport(addresses []string, from, to uint32) (*DepositReport, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	chainHeight := service.Blockchain().ChainTip().Height
+	if to == 0 {
+		to = chainHeight
+	}
+	if from > to {
+		return nil, errors.Wrapf(errors.ErrInvalid, "invalid height range %d to %d", from, to)
+	}
+
+	owners := service.accounts.owned()
+	report := &DepositReport{
+		From:        from,
+		To:          to,
+		ChainHeight: chainHeight,
+		Totals:      make(map[string]Fixed64),
+		Complete:    chainHeight <= MaxAddrTxsDepth || from >= chainHeight-MaxAddrTxsDepth,
+	}
+	for _, address := range addresses {
+		addr, err := Uint168FromAddress(address)
+		if err != nil {
+			return nil, errors.Wrapf(errors.ErrInvalid, "invalid address format %s", address)
+		}
+		account, ok := owners[*addr]
+		if !ok {
+			return nil, errors.Wrapf(errors.ErrNotFound, "address %s not registered", address)
+		}
+		if _, ok := report.Totals[address]; ok {
+			continue
+		}
+		report.Totals[address] = 0
+
+		addrTxs, err := service.addrTxs.GetAll(addr)
+		if err != nil {
+			return nil, err
+		}
+		for _, addrTx := range addrTxs {
+			if addrTx.Height < from || addrTx.Height > to {
+				continue
+			}
+			confirmed := service.isConfirmed(addrTx.Tx, addrTx.Height, chainHeight)
+			for _, deposit := range outputDeposits(addr, addrTx) {
+				deposit.Address = address
+				deposit.Account = account
+				deposit.Confirmations = chainHeight - addrTx.Height + 1
+				deposit.Confirmed = confirmed
+				report.Totals[address] += deposit.Amount
+				report.Deposits = append(report.Deposits, deposit)
+			}
+		}
+	}
+
+	sortDeposits(report.Deposits)
+	return report, nil
+}
+
+// Get the deposits of the outputs of the transaction paid to the address
+func outputDeposits(addr *Uint168, addrTx *AddrTx) []*Deposit {
+	var deposits []*Deposit
+	for index, output := range addrTx.Tx.Outputs {
+		if output.ProgramHash != *addr {
+			continue
+		}
+		deposits = append(deposits, &Deposit{
+			TxHash: *addrTx.Tx.Hash(),
+			Index:  uint16(index),
+			Amount: output.Value,
+			Height: addrTx.Height,
+			Proof:  addrTx.Proof,
+		})
+	}
+	return deposits
+}
+
+// Sort the deposits by height, transaction hash and output index
+func sortDeposits(deposits []*Deposit) {
+	sort.Slice(deposits, func(i, j int) bool {
+		a, b := deposits[i], deposits[j]
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		if c := bytes.Compare(a.TxHash[:], b.TxHash[:]); c != 0 {
+			return c < 0
+		}
+		return a.Index < b.Index
+	})
+}
@@ -0,0 +1,53 @@
+package _interface
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+)
+
+func TestDepositReport(t *testing.T) {
+	addr, other := Uint168{1}, Uint168{2}
+	txn := tx.Transaction{
+		TxType:  tx.TransferAsset,
+		Payload: new(payload.TransferAsset),
+		Outputs: []*tx.Output{
+			{ProgramHash: addr, Value: 100},
+			{ProgramHash: other, Value: 200},
+			{ProgramHash: addr, Value: 300},
+		},
+	}
+	addrTx := &AddrTx{Height: 100, Tx: txn, Proof: Proof{Height: 100}}
+
+	// Only the outputs paid to the address are deposits
+	deposits := outputDeposits(&addr, addrTx)
+	if len(deposits) != 2 || deposits[0].Index != 0 || deposits[0].Amount != 100 ||
+		deposits[1].Index != 2 || deposits[1].Amount != 300 {
+		t.Fatalf("unexpected deposits %+v", deposits)
+	}
+	if !deposits[0].TxHash.IsEqual(txn.Hash()) || deposits[0].Height != 100 || deposits[0].Proof.Height != 100 {
+		t.Errorf("unexpected deposit %+v", deposits[0])
+	}
+
+	// Ordered by height, transaction hash and output index
+	unordered := []*Deposit{
+		{Height: 101, TxHash: Uint256{1}},
+		{Height: 100, TxHash: Uint256{2}, Index: 1},
+		{Height: 100, TxHash: Uint256{2}, Index: 0},
+		{Height: 100, TxHash: Uint256{1}, Index: 5},
+	}
+	sortDeposits(unordered)
+	expected := []struct {
+		height uint32
+		hash   byte
+		index  uint16
+	}{{100, 1, 5}, {100, 2, 0}, {100, 2, 1}, {101, 1, 0}}
+	for i, e := range expected {
+		d := unordered[i]
+		if d.Height != e.height || d.TxHash[0] != e.hash || d.Index != e.index {
+			t.Errorf("deposit %d is %d/%x/%d, expect %d/%x/%d", i, d.Height, d.TxHash[0], d.Index, e.height, e.hash, e.index)
+		}
+	}
+}
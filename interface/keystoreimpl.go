@@ -2,23 +2,24 @@ package _interface
 
 import (
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 )
 
 type KeystoreImpl struct {
 	keystore spvwallet.Keystore
 }
 
-// This method will open or create a keystore with the given password
+// This method will open or create a keystore in the data directory with the given password
 func (impl *KeystoreImpl) Open(password string) (Keystore, error) {
 	var err error
 	// Try to open keystore first
-	impl.keystore, err = spvwallet.OpenKeystore([]byte(password))
+	impl.keystore, err = spvwallet.OpenKeystore(config.Values().DataDir, []byte(password))
 	if err == nil {
 		return impl, nil
 	}
 
 	// Try to create a keystore
-	impl.keystore, err = spvwallet.CreateKeystore([]byte(password))
+	impl.keystore, err = spvwallet.CreateKeystore(config.Values().DataDir, []byte(password))
 	if err != nil {
 		return nil, err
 	}
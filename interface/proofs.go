@@ -2,29 +2,39 @@ package _interface
 
 import (
 	"bytes"
+	"encoding/binary"
+	"path/filepath"
 	"sync"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/boltdb/bolt"
-	"fmt"
 	"encoding/hex"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
-	"errors"
 )
 
 type Proofs interface {
-	// Put a merkle proof of the block
-	Put(proof *Proof) error
+	// Put a merkle proof of the block, with the matched transactions in it
+	Put(proof *Proof, txHashes ...*Uint256) error
 
 	// Get a merkle proof of a block
 	Get(blockHash *Uint256) (*Proof, error)
 
+	// Get the merkle proof of the block which the transaction was packed in
+	GetByTx(txHash *Uint256) (*Proof, error)
+
 	// Get all merkle proofs in database
 	GetAll() ([]*Proof, error)
 
 	// Delete a merkle proof of a block
 	Delete(blockHash *Uint256) error
 
+	// Delete merkle proofs of blocks below the given height, except the proofs of the blocks kept
+	Prune(height uint32, keep ...*Uint256) error
+
+	// Delete merkle proofs of blocks on the given height
+	Rollback(height uint32) error
+
 	// Reset database, clear all data
 	Reset() error
 
@@ -37,29 +47,83 @@ type ProofsDB struct {
 	*bolt.DB
 }
 
+const ProofsDBName = "proofs.bin"
+
 var (
-	BKTProofs = []byte("Proofs")
+	BKTProofs   = []byte("Proofs")
+	BKTTxProofs = []byte("TxProofs")
+	// Proofs indexed by the height key, the value is the hashes of the transactions indexed to the proof
+	BKTHeightProofs = []byte("HeightProofs")
 )
 
-func NewProofsDB() (Proofs, error) {
-	db, err := bolt.Open("proofs.bin", 0644, &bolt.Options{InitialMmapSize: 5000000})
+func NewProofsDB(dir string) (Proofs, error) {
+	db, err := bolt.Open(filepath.Join(dir, ProofsDBName), 0644, &bolt.Options{InitialMmapSize: 5000000})
 	if err != nil {
 		return nil, err
 	}
 
-	db.Update(func(btx *bolt.Tx) error {
-		_, err := btx.CreateBucketIfNotExists(BKTProofs)
+	err = db.Update(func(btx *bolt.Tx) error {
+		err := createBuckets(btx)
 		if err != nil {
 			return err
 		}
-		return nil
+		return indexHeights(btx)
 	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return &ProofsDB{RWMutex: new(sync.RWMutex), DB: db}, nil
 }
 
-// Put a merkle proof of the block
-func (db *ProofsDB) Put(proof *Proof) error {
+func createBuckets(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(BKTProofs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.CreateBucketIfNotExists(BKTTxProofs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.CreateBucketIfNotExists(BKTHeightProofs)
+	return err
+}
+
+// Index the proofs stored by an older version by the height
+func indexHeights(tx *bolt.Tx) error {
+	heights := tx.Bucket(BKTHeightProofs)
+	if k, _ := heights.Cursor().First(); k != nil {
+		return nil
+	}
+
+	txHashes := make(map[string][]byte)
+	err := tx.Bucket(BKTTxProofs).ForEach(func(k, v []byte) error {
+		txHashes[string(v)] = append(txHashes[string(v)], k...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(BKTProofs).ForEach(func(k, v []byte) error {
+		proof, err := deserializeProof(v)
+		if err != nil {
+			return err
+		}
+		return heights.Put(heightKey(proof.Height, k), txHashes[string(k)])
+	})
+}
+
+// The key of the height index, the height in big endian followed by the block hash, so the
+// proofs of a height range are next to each other in order
+func heightKey(height uint32, blockHash []byte) []byte {
+	key := make([]byte, 4, 4+len(blockHash))
+	binary.BigEndian.PutUint32(key, height)
+	return append(key, blockHash...)
+}
+
+// Put a merkle proof of the block, with the matched transactions in it
+func (db *ProofsDB) Put(proof *Proof, txHashes ...*Uint256) error {
 	db.Lock()
 	defer db.Unlock()
 
@@ -75,7 +139,20 @@ func (db *ProofsDB) Put(proof *Proof) error {
 			return err
 		}
 
-		return nil
+		// Index block hash by transaction hash, and the transactions by the height
+		key := heightKey(proof.Height, proof.BlockHash.Bytes())
+		indexed := tx.Bucket(BKTHeightProofs).Get(key)
+		index := make([]byte, len(indexed), len(indexed)+len(txHashes)*UINT256SIZE)
+		copy(index, indexed)
+		for _, txHash := range txHashes {
+			err = tx.Bucket(BKTTxProofs).Put(txHash.Bytes(), proof.BlockHash.Bytes())
+			if err != nil {
+				return err
+			}
+			index = append(index, txHash.Bytes()...)
+		}
+
+		return tx.Bucket(BKTHeightProofs).Put(key, index)
 	})
 }
 
@@ -101,6 +178,33 @@ func (db *ProofsDB) Get(blockHash *Uint256) (proof *Proof, err error) {
 	return proof, err
 }
 
+// Get the merkle proof of the block which the transaction was packed in
+func (db *ProofsDB) GetByTx(txHash *Uint256) (proof *Proof, err error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	err = db.View(func(tx *bolt.Tx) error {
+
+		blockHash := tx.Bucket(BKTTxProofs).Get(txHash.Bytes())
+		if blockHash == nil {
+			return errors.Wrapf(errors.ErrNotFound, "Proof of transaction %s does not exist in database", txHash.String())
+		}
+
+		proof, err = getProof(tx, blockHash)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return proof, err
+}
+
 // Get all merkle proofs in database
 func (db *ProofsDB) GetAll() (proofs []*Proof, err error) {
 	db.RLock()
@@ -140,12 +244,42 @@ func (db *ProofsDB) Delete(blockHash *Uint256) error {
 	})
 }
 
+// Delete merkle proofs of blocks below the given height, except the proofs of the blocks kept
+func (db *ProofsDB) Prune(height uint32, keep ...*Uint256) error {
+	db.Lock()
+	defer db.Unlock()
+
+	kept := make(map[string]bool, len(keep))
+	for _, blockHash := range keep {
+		kept[string(blockHash.Bytes())] = true
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return deleteProofs(tx, 0, height, kept)
+	})
+}
+
+// Delete merkle proofs of blocks on the given height
+func (db *ProofsDB) Rollback(height uint32) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		return deleteProofs(tx, height, height+1, nil)
+	})
+}
+
 func (db *ProofsDB) Reset() error {
 	db.Lock()
 	defer db.Unlock()
 
 	return db.Update(func(tx *bolt.Tx) error {
-		return tx.DeleteBucket(BKTProofs)
+		for _, bucket := range [][]byte{BKTProofs, BKTTxProofs, BKTHeightProofs} {
+			err := tx.DeleteBucket(bucket)
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return createBuckets(tx)
 	})
 }
 
@@ -159,12 +293,51 @@ func (db *ProofsDB) Close() {
 func getProof(tx *bolt.Tx, key []byte) (*Proof, error) {
 	proofBytes := tx.Bucket(BKTProofs).Get(key)
 	if proofBytes == nil {
-		return nil, errors.New(fmt.Sprintf("Proof %s does not exist in database", hex.EncodeToString(key)))
+		return nil, errors.Wrapf(errors.ErrNotFound, "Proof %s does not exist in database", hex.EncodeToString(key))
 	}
 
 	return deserializeProof(proofBytes)
 }
 
+// Delete proofs of the blocks from the start height to below the end height by the height index,
+// and the transaction index of them, the proofs of the kept blocks are skipped
+func deleteProofs(tx *bolt.Tx, start, end uint32, kept map[string]bool) error {
+	if end <= start {
+		return nil
+	}
+	var keys [][]byte
+	heights := tx.Bucket(BKTHeightProofs)
+	limit := heightKey(end, nil)
+	c := heights.Cursor()
+	for k, _ := c.Seek(heightKey(start, nil)); k != nil && bytes.Compare(k, limit) < 0; k, _ = c.Next() {
+		if !kept[string(k[4:])] {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+	}
+
+	proofs := tx.Bucket(BKTProofs)
+	txProofs := tx.Bucket(BKTTxProofs)
+	for _, k := range keys {
+		index := heights.Get(k)
+		for i := 0; i+UINT256SIZE <= len(index); i += UINT256SIZE {
+			err := txProofs.Delete(index[i : i+UINT256SIZE])
+			if err != nil {
+				return err
+			}
+		}
+		err := proofs.Delete(k[4:])
+		if err != nil {
+			return err
+		}
+		err = heights.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func serializeProof(proof *Proof) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	err := proof.Serialize(buf)
@@ -2,6 +2,11 @@ package _interface
 
 const (
 	DefaultConfirmations = 6
+
+	// Max transactions kept for each registered address
+	MaxAddrTxs = 1000
+	// Transactions deeper than this blocks will be pruned, about 30 days
+	MaxAddrTxsDepth = 21600
 )
 
 var (
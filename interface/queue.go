@@ -3,9 +3,11 @@ package _interface
 import (
 	"sync"
 	"database/sql"
+	"path/filepath"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"fmt"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 )
 
@@ -16,7 +18,16 @@ type Queue interface {
 	// Get all items in queue
 	GetAll() ([]*QueueItem, error)
 
-	// Delete confirmed item in queue
+	// Get items in the given state
+	GetByState(state QueueState) ([]*QueueItem, error)
+
+	// Update the state of a queued item
+	UpdateState(txHash *Uint256, state QueueState) error
+
+	// Delete items on the given height
+	Rollback(height uint32) error
+
+	// Delete an item done with in queue
 	Delete(txHash *Uint256) error
 }
 
@@ -27,8 +38,15 @@ const (
 	CreateQueueDB = `CREATE TABLE IF NOT EXISTS Queue(
 				TxHash BLOB NOT NULL PRIMARY KEY,
 				BlockHash BLOB NOT NULL,
-				Height INTEGER NOT NULL
+				Height INTEGER NOT NULL,
+				State INTEGER NOT NULL DEFAULT 0
 			);`
+
+	// Queue created by older versions do not have the State column
+	AddQueueStateColumn = `ALTER TABLE Queue ADD COLUMN State INTEGER NOT NULL DEFAULT 0;`
+
+	CreateQueueIndexes = `CREATE INDEX IF NOT EXISTS QueueState ON Queue(State);
+				CREATE INDEX IF NOT EXISTS QueueHeight ON Queue(Height);`
 )
 
 type QueueDB struct {
@@ -36,8 +54,8 @@ type QueueDB struct {
 	*sql.DB
 }
 
-func NewQueueDB() (Queue, error) {
-	db, err := sql.Open(DriverName, DBName)
+func NewQueueDB(dir string) (Queue, error) {
+	db, err := sql.Open(DriverName, filepath.Join(dir, DBName))
 	if err != nil {
 		fmt.Println("Open sqlite db error:", err)
 		return nil, err
@@ -47,6 +65,19 @@ func NewQueueDB() (Queue, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	// Add the State column if the queue was created by an older version
+	if _, err = db.Exec("SELECT State FROM Queue LIMIT 0"); err != nil {
+		_, err = db.Exec(AddQueueStateColumn)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = db.Exec(CreateQueueIndexes)
+	if err != nil {
+		return nil, err
+	}
 	return &QueueDB{RWMutex: new(sync.RWMutex), DB: db}, nil
 }
 
@@ -56,12 +87,12 @@ func (db *QueueDB) Put(item *QueueItem) error {
 	db.Lock()
 	defer db.Unlock()
 
-	sql := "INSERT OR REPLACE INTO Queue(TxHash, BlockHash, Height) VALUES(?,?,?)"
+	sql := "INSERT OR REPLACE INTO Queue(TxHash, BlockHash, Height, State) VALUES(?,?,?,?)"
 	stmt, err := db.Prepare(sql)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(item.TxHash.Bytes(), item.BlockHash.Bytes(), item.Height)
+	_, err = stmt.Exec(item.TxHash.Bytes(), item.BlockHash.Bytes(), item.Height, item.State)
 	if err != nil {
 		return err
 	}
@@ -75,17 +106,69 @@ func (db *QueueDB) GetAll() ([]*QueueItem, error) {
 	db.RLock()
 	defer db.RUnlock()
 
-	rows, err := db.Query("SELECT TxHash, BlockHash, Height FROM Queue")
+	rows, err := db.Query("SELECT TxHash, BlockHash, Height, State FROM Queue ORDER BY Height")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return getQueueItems(rows)
+}
+
+// Get items in the given state
+func (db *QueueDB) GetByState(state QueueState) ([]*QueueItem, error) {
+	log.Debug("Queue db GetByState: ", state)
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query("SELECT TxHash, BlockHash, Height, State FROM Queue WHERE State=? ORDER BY Height", state)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	return getQueueItems(rows)
+}
+
+// Update the state of a queued item
+func (db *QueueDB) UpdateState(txHash *Uint256, state QueueState) error {
+	log.Debug("Queue db UpdateState: ", txHash.String(), state)
+	db.Lock()
+	defer db.Unlock()
+
+	result, err := db.Exec("UPDATE Queue SET State=? WHERE TxHash=?", state, txHash.Bytes())
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errors.Wrap(errors.ErrNotFound, "queue item not found, tx hash: "+txHash.String())
+	}
+
+	return nil
+}
+
+// Delete items on the given height
+func (db *QueueDB) Rollback(height uint32) error {
+	log.Debug("Queue db Rollback: ", height)
+	db.Lock()
+	defer db.Unlock()
+
+	_, err := db.Exec("DELETE FROM Queue WHERE Height=?", height)
+	return err
+}
 
+func getQueueItems(rows *sql.Rows) ([]*QueueItem, error) {
 	var items []*QueueItem
 	for rows.Next() {
 		var txHashBytes []byte
 		var blockHashBytes []byte
 		var height uint32
-		err = rows.Scan(&txHashBytes, &blockHashBytes, &height)
+		var state QueueState
+		err := rows.Scan(&txHashBytes, &blockHashBytes, &height, &state)
 		if err != nil {
 			return nil, err
 		}
@@ -98,14 +181,14 @@ func (db *QueueDB) GetAll() ([]*QueueItem, error) {
 		if err != nil {
 			return nil, err
 		}
-		item := &QueueItem{TxHash: *txHash, BlockHash: *blockHash, Height: height}
+		item := &QueueItem{TxHash: *txHash, BlockHash: *blockHash, Height: height, State: state}
 		items = append(items, item)
 	}
 
 	return items, nil
 }
 
-// Delete confirmed item in queue
+// Delete an item done with in queue
 func (db *QueueDB) Delete(txHash *Uint256) error {
 	log.Debug("Queue db Delete: ", txHash.String())
 	db.Lock()
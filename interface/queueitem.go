@@ -4,8 +4,39 @@ import (
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 )
 
+// The lifecycle state of a queued transaction
+type QueueState uint8
+
+const (
+	// Transaction matched a registered account and was put into queue
+	QueueSeen QueueState = iota
+	// Transaction was sent to the listeners, will be sent again until acked
+	QueueNotified
+	// Transaction receipt was submitted by the listener
+	QueueAcked
+	// Acked transaction in a block confirmed by the arbiters, the item is deleted once
+	// the transaction reached the confirmations
+	QueueConfirmed
+)
+
+func (state QueueState) String() string {
+	switch state {
+	case QueueSeen:
+		return "seen"
+	case QueueNotified:
+		return "notified"
+	case QueueAcked:
+		return "acked"
+	case QueueConfirmed:
+		return "confirmed"
+	default:
+		return "unknown"
+	}
+}
+
 type QueueItem struct {
-	TxHash        Uint256
-	BlockHash     Uint256
-	Height        uint32
+	TxHash    Uint256
+	BlockHash Uint256
+	Height    uint32
+	State     QueueState
 }
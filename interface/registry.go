@@ -0,0 +1,168 @@
+package _interface
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+
+	"github.com/boltdb/bolt"
+)
+
+// An address registered to the SPV service with the account and the chain height it was registered on
+type RegisteredAddr struct {
+	Account string
+	Address Uint168
+	Height  uint32
+}
+
+// The address is the key of the record, so it's not serialized
+func (a *RegisteredAddr) Serialize(w io.Writer) error {
+	err := serialization.WriteUint32(w, a.Height)
+	if err != nil {
+		return err
+	}
+	return serialization.WriteVarString(w, a.Account)
+}
+
+func (a *RegisteredAddr) Deserialize(r io.Reader) error {
+	var err error
+	a.Height, err = serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	a.Account, err = serialization.ReadVarString(r)
+	return err
+}
+
+/*
+Registry persists the registered addresses, so they are reloaded when the SPV service starts
+instead of being registered again on every start.
+*/
+type Registry interface {
+	// Put the address registered under the account on the given height, an address
+	// registered again under the same account keeps the height it was registered on
+	Put(account string, addr *Uint168, height uint32) error
+
+	// Get all registered addresses
+	GetAll() ([]*RegisteredAddr, error)
+
+	// Delete the registered address
+	Delete(addr *Uint168) error
+
+	// Reset database, clear all data
+	Reset() error
+
+	// Close the registry db
+	Close()
+}
+
+const RegistryDBName = "registry.bin"
+
+var BKTRegistered = []byte("Registered")
+
+// RegistryDB implements Registry using bolt DB, keyed by the address
+type RegistryDB struct {
+	*sync.RWMutex
+	*bolt.DB
+}
+
+func NewRegistryDB(dir string) (Registry, error) {
+	db, err := bolt.Open(filepath.Join(dir, RegistryDBName), 0644, &bolt.Options{InitialMmapSize: 5000000})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(btx *bolt.Tx) error {
+		_, err := btx.CreateBucketIfNotExists(BKTRegistered)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistryDB{RWMutex: new(sync.RWMutex), DB: db}, nil
+}
+
+// Put the address registered under the account on the given height
+func (db *RegistryDB) Put(account string, addr *Uint168, height uint32) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(btx *bolt.Tx) error {
+		bucket := btx.Bucket(BKTRegistered)
+		if value := bucket.Get(addr.ToArray()); value != nil {
+			var registered RegisteredAddr
+			err := registered.Deserialize(bytes.NewReader(value))
+			if err == nil && registered.Account == account {
+				return nil
+			}
+		}
+
+		buf := new(bytes.Buffer)
+		registered := RegisteredAddr{Account: account, Address: *addr, Height: height}
+		if err := registered.Serialize(buf); err != nil {
+			return err
+		}
+		return bucket.Put(addr.ToArray(), buf.Bytes())
+	})
+}
+
+// Get all registered addresses
+func (db *RegistryDB) GetAll() (addrs []*RegisteredAddr, err error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	err = db.View(func(btx *bolt.Tx) error {
+		return btx.Bucket(BKTRegistered).ForEach(func(k, v []byte) error {
+			address, err := Uint168FromBytes(k)
+			if err != nil {
+				return err
+			}
+			registered := RegisteredAddr{Address: *address}
+			err = registered.Deserialize(bytes.NewReader(v))
+			if err != nil {
+				return err
+			}
+			addrs = append(addrs, &registered)
+			return nil
+		})
+	})
+
+	return addrs, err
+}
+
+// Delete the registered address
+func (db *RegistryDB) Delete(addr *Uint168) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(btx *bolt.Tx) error {
+		return btx.Bucket(BKTRegistered).Delete(addr.ToArray())
+	})
+}
+
+func (db *RegistryDB) Reset() error {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.Update(func(btx *bolt.Tx) error {
+		err := btx.DeleteBucket(BKTRegistered)
+		if err != nil {
+			return err
+		}
+		_, err = btx.CreateBucket(BKTRegistered)
+		return err
+	})
+}
+
+// Close db
+func (db *RegistryDB) Close() {
+	db.Lock()
+	db.DB.Close()
+	log.Debug("Registry DB closed")
+}
@@ -0,0 +1,56 @@
+package _interface
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+func TestRegistryDB(t *testing.T) {
+	os.Remove(RegistryDBName)
+	defer os.Remove(RegistryDBName)
+
+	registry, err := NewRegistryDB("")
+	if err != nil {
+		t.Fatal("open registry error,", err)
+	}
+
+	alice, _ := Uint168FromAddress("ETBBrgotZy3993o9bH75KxjLDgQxBCib6u")
+	bob, _ := Uint168FromAddress("EUyNwnAh5SzzTtAPV1HkXzjUEbw2YqKsUM")
+	registry.Put("user-1", alice, 100)
+	registry.Put("user-2", bob, 100)
+
+	// Registered again under the same account keeps the height, under another account moves it
+	registry.Put("user-1", alice, 200)
+	registry.Put("user-3", bob, 300)
+	registry.Close()
+
+	// Reloaded after reopened
+	registry, err = NewRegistryDB("")
+	if err != nil {
+		t.Fatal("reopen registry error,", err)
+	}
+	defer registry.Close()
+	registered, err := registry.GetAll()
+	if err != nil || len(registered) != 2 {
+		t.Fatalf("unexpected registered addresses %v, error %v", registered, err)
+	}
+	for _, r := range registered {
+		switch r.Address {
+		case *alice:
+			if r.Account != "user-1" || r.Height != 100 {
+				t.Errorf("unexpected registered address %+v", r)
+			}
+		case *bob:
+			if r.Account != "user-3" || r.Height != 300 {
+				t.Errorf("unexpected registered address %+v", r)
+			}
+		}
+	}
+
+	registry.Delete(alice)
+	if registered, _ := registry.GetAll(); len(registered) != 1 || registered[0].Address != *bob {
+		t.Errorf("unexpected registered addresses %v after deleted", registered)
+	}
+}
@@ -1,9 +1,12 @@
 package _interface
 
 import (
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/sdk/address"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 )
 
 /*
@@ -12,16 +15,46 @@ service implementation running background, you can register specific accounts th
 interested in and receive transaction notifications of these accounts.
 */
 type SPVService interface {
-	// Register the account address that you are interested in
+	// Register the account address that you are interested in, under the DefaultAccount.
+	// Registered addresses are persisted and reloaded when the service starts
 	RegisterAccount(address string) error
 
+	// Register the address under the named account, like the ID of a user or tenant,
+	// an address belongs to one account only. It can be called after the service started
+	RegisterAccountAddress(account, address string) error
+
+	// Get the addresses registered under the account
+	GetAccountAddresses(account string) []string
+
+	// Get the transactions and proofs received of all addresses under the account
+	GetAccountTransactions(account string) ([]*AddrTx, error)
+
+	// Register the TransactionListener notified only of the transactions paid to the account,
+	// the PayloadFilter can be nil
+	RegisterAccountListener(account string, listener TransactionListener, filter PayloadFilter)
+
+	// Get the registered addresses with the account and height they were registered on
+	GetRegisteredAddresses() ([]*RegisteredAddr, error)
+
+	// Set the priority of the registered address, transactions paid to a PriorityHigh address
+	// are notified at once by Notify(), bypassing the batches of a BatchTransactionListener
+	SetAddressPriority(address string, priority Priority) error
+
+	// Remove the account with all its addresses and received transactions at once,
+	// the transactions of the account are not notified after it returns
+	RemoveAccount(account string) error
+
 	// Register the TransactionListener to receive transaction notifications
 	// when a transaction related with the registered accounts is received
 	RegisterTransactionListener(TransactionListener)
 
+	// Register the TransactionListener with a PayloadFilter, the listener is only
+	// notified of the transactions accepted by the filter
+	RegisterFilteredTransactionListener(TransactionListener, PayloadFilter)
+
 	// After receive the transaction callback, call this method
 	// to confirm that the transaction with the given ID was handled
-	// so the transaction will be removed from the notify queue
+	// so the transaction will not be notified again
 	SubmitTransactionReceipt(txId Uint256) error
 
 	// To verify if a transaction is valid
@@ -31,6 +64,37 @@ type SPVService interface {
 	// Send a transaction to the P2P network
 	SendTransaction(tx.Transaction) error
 
+	// Send a transaction with an idempotency key, a call retried with the same key
+	// and transaction does not send it again, the key can not be used by another transaction
+	SendTransactionWithKey(key string, txn tx.Transaction) error
+
+	// Get the merkle proof of a received transaction from local database,
+	// the proof can be verified offline with VerifyTransaction()
+	GetTransactionProof(txHash Uint256) (*Proof, error)
+
+	// Get the compact merkle proof of a received transaction, the branch hashes of the
+	// transaction only, it's verified with VerifyMerkleProof()
+	GetMerkleProof(txHash Uint256) (*bloom.MerkleProof, error)
+
+	// Verify the transaction is in a block of the main chain by the compact merkle proof
+	VerifyMerkleProof(*bloom.MerkleProof, tx.Transaction) error
+
+	// Get the cross chain deposit received with the proof and header serialized
+	// in the format of the recharging transaction on the sidechain
+	GetDepositBundle(txHash Uint256) (*DepositBundle, error)
+
+	// Get the deposits to the registered addresses in the blocks of the height range, 0 to means
+	// the chain tip, in a deterministic order for reconciliation, with the proofs and confirmations
+	GetDepositReport(addresses []string, from, to uint32) (*DepositReport, error)
+
+	// Get the transactions and proofs received of the registered address,
+	// records out of the retention limits are not included
+	GetAddressTransactions(address string) ([]*AddrTx, error)
+
+	// Download the recent blocks of the depth in full to audit the registered addresses, the transactions
+	// missed by the bloom filter are stored and raised as alerts. 0 depth means DefaultAuditDepth
+	AuditAddresses(depth uint32) (*AuditReport, error)
+
 	// Get the Blockchain instance.
 	// Blockchain will handle block and transaction commits,
 	// verify and store the block and transactions.
@@ -38,8 +102,40 @@ type SPVService interface {
 	// use Blockchain.AddStateListener() to register chain state callbacks
 	Blockchain() *sdk.Blockchain
 
-	// Start the SPV service
+	// Check if the SPV service has enough connected peers,
+	// a service not healthy for a long time should be restarted
+	IsHealthy() bool
+
+	// Check if the SPV service is healthy and synced up with the network,
+	// transactions are not notified in time when the service is not ready
+	IsReady() bool
+
+	// Signal the connection is metered or not, on a metered connection the service runs
+	// in the low bandwidth mode and historical rescans are deferred until it's not
+	SetMetered(metered bool)
+
+	// Set the public keys of the DPoS arbiters, a block confirmed by the supermajority
+	// of the arbiters is irreversible. Confirms are ignored if not set
+	SetArbiters(publicKeys [][]byte)
+
+	// Get the current arbiter set, register an ArbitersListener to it to be notified of the changes
+	Arbiters() *sdk.ArbiterSet
+
+	// Register the IdleListener to know when the service synced up with the network
+	RegisterIdleListener(sdk.IdleListener)
+
+	// Stop the network activity when the app is in background, the sync
+	// continues from the last committed block when resumed
+	Pause()
+
+	// Continue the network activity after paused
+	Resume()
+
+	// Start the SPV service, it blocks until the service stopped
 	Start() error
+
+	// Stop the SPV service, Start() returns after stopped
+	Stop()
 }
 
 /*
@@ -51,7 +147,7 @@ type TransactionListener interface {
 	Type() tx.TransactionType
 
 	// Confirmed() indicates if this transaction should be callback after reach the confirmed height,
-	// by default 6 confirmations are needed according to the protocol
+	// by default 6 confirmations are needed according to the protocol, or the block is confirmed by the DPoS arbiters
 	Confirmed() bool
 
 	// Notify() is the method to callback the received transaction
@@ -59,6 +155,56 @@ type TransactionListener interface {
 	Notify(Proof, tx.Transaction)
 }
 
+/*
+BatchTransactionListener is a TransactionListener notified of the transactions on a block in batches
+with the block context, rather than a Notify() call for each, for the listeners receiving thousands of
+transactions in a block like an exchange sweeping it's hot wallet. Batches are delivered one at a time
+in the block order, the block commit waits if BatchQueueSize batches are not delivered yet.
+*/
+type BatchTransactionListener interface {
+	TransactionListener
+
+	// BatchSize() is the max number of transactions in a batch, DefaultBatchSize is used if it's not positive
+	BatchSize() int
+
+	// NotifyBatch() is the method to callback a batch of the transactions notified on the block,
+	// Notify() is only called for the transactions paid to a PriorityHigh address
+	NotifyBatch(BlockContext, []*Notification)
+}
+
+/*
+PayloadFilter is evaluated before a TransactionListener registered with it is notified,
+return false to skip the transaction, so a listener only receives the traffic it's interested in.
+*/
+type PayloadFilter func(tx.Transaction) bool
+
+// Accept only the cross chain deposits to the side chain of the given genesis hash,
+// the hash is in byte order as address.FromGenesisHash()
+func CrossChainDepositFilter(genesisHash Uint256) PayloadFilter {
+	programHash, err := address.ProgramHash(address.CrossChainRedeemScript(genesisHash))
+	if err != nil {
+		return func(tx.Transaction) bool { return false }
+	}
+	return func(txn tx.Transaction) bool {
+		if txn.TxType != tx.TransferCrossChainAsset {
+			return false
+		}
+		for _, output := range txn.Outputs {
+			if output.ProgramHash == *programHash {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func NewSPVService(clientId uint64, seeds []string) SPVService {
-	return newSPVServiceImpl(clientId, seeds)
+	cfg := *config.Values()
+	cfg.SeedList = seeds
+	return newSPVServiceImpl(clientId, &cfg)
+}
+
+// Create SPV service with the given config instead of the config file
+func NewSPVServiceWithConfig(clientId uint64, cfg *config.Config) SPVService {
+	return newSPVServiceImpl(clientId, cfg)
 }
@@ -1,190 +1,469 @@
 package _interface
 
 import (
+	"fmt"
 	"os"
-	"errors"
 	"os/signal"
+	"time"
 
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/guard"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
 	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
+	"github.com/elastos/Elastos.ELA.SPV/verifier"
 )
 
+var notifyLatency = metrics.NewHistogram("spv_notify_latency_seconds",
+	"Seconds from a transaction to notify until the listener returned", metrics.DefaultBuckets)
+
 type SPVServiceImpl struct {
 	*spvwallet.SPVWallet
 	clientId   uint64
-	seeds      []string
-	accounts   []*Uint168
+	config     *config.Config
+	accounts   *accounts
 	proofs     Proofs
+	addrTxs    AddrTxs
+	registry   Registry
 	queue      Queue
 	addrFilter *sdk.AddrFilter
-	listeners  map[tx.TransactionType][]TransactionListener
+	listeners  map[tx.TransactionType][]*registeredListener
+	idle       []sdk.IdleListener
+	arbiters   *sdk.ArbiterSet
+	metered    bool
+	paused     bool
+	stop       chan int
 }
 
-func newSPVServiceImpl(clientId uint64, seeds []string) *SPVServiceImpl {
+func newSPVServiceImpl(clientId uint64, cfg *config.Config) *SPVServiceImpl {
 	return &SPVServiceImpl{
 		clientId:  clientId,
-		seeds:     seeds,
-		listeners: make(map[tx.TransactionType][]TransactionListener),
+		config:    cfg,
+		accounts:  newAccounts(),
+		listeners: make(map[tx.TransactionType][]*registeredListener),
+		arbiters:  sdk.NewArbiterSet(),
+		stop:      make(chan int, 1),
 	}
 }
 
 func (service *SPVServiceImpl) RegisterAccount(address string) error {
-	account, err := Uint168FromAddress(address)
-	if err != nil {
-		return errors.New("Invalid address format")
-	}
-	service.accounts = append(service.accounts, account)
-	return nil
+	return service.RegisterAccountAddress(DefaultAccount, address)
 }
 
 func (service *SPVServiceImpl) RegisterTransactionListener(listener TransactionListener) {
-	listeners := service.listeners[listener.Type()]
-	listeners = append(listeners, listener)
+	service.RegisterFilteredTransactionListener(listener, nil)
+}
+
+func (service *SPVServiceImpl) RegisterFilteredTransactionListener(listener TransactionListener, filter PayloadFilter) {
+	registered := &registeredListener{
+		TransactionListener: listener,
+		filter:              filter,
+		guard:               guard.New(fmt.Sprintf("transaction listener %T", listener)),
+	}
+	if _, ok := listener.(BatchTransactionListener); ok {
+		registered.startBatches()
+	}
+	listeners := append(service.listeners[listener.Type()], registered)
 	service.listeners[listener.Type()] = listeners
 	log.Debug("Listener registered:", listeners)
 }
 
+// A listener registered with the payload filter if any, the guard of the callbacks,
+// and the queue of the batches if it's a BatchTransactionListener
+type registeredListener struct {
+	TransactionListener
+	filter  PayloadFilter
+	guard   *guard.Guard
+	batches chan func()
+}
+
 func (service *SPVServiceImpl) SubmitTransactionReceipt(txHash Uint256) error {
-	return service.queue.Delete(&txHash)
+	return service.queue.UpdateState(&txHash, QueueAcked)
 }
 
 func (service *SPVServiceImpl) VerifyTransaction(proof Proof, tx tx.Transaction) error {
 	if service.SPVWallet == nil {
-		return errors.New("SPV service not started")
+		return errors.Wrap(errors.ErrNotStarted, "SPV service not started")
 	}
 
 	// Get Header from main chain
 	header, err := service.Headers().GetHeader(proof.BlockHash)
 	if err != nil {
-		return errors.New("can not get block from main chain")
+		return errors.Wrap(errors.ErrNotFound, "can not get block from main chain")
 	}
 
-	// Check if merkleroot is match
-	merkleBlock := bloom.MerkleBlock{
-		BlockHeader:  header.Header,
+	// Check if merkleroot and transaction hash are match
+	return verifier.VerifyProof(&header.Header, &verifier.Proof{
 		Transactions: proof.Transactions,
 		Hashes:       proof.Hashes,
 		Flags:        proof.Flags,
+	}, *tx.Hash())
+}
+
+func (service *SPVServiceImpl) VerifyMerkleProof(proof *bloom.MerkleProof, tx tx.Transaction) error {
+	if service.SPVWallet == nil {
+		return errors.Wrap(errors.ErrNotStarted, "SPV service not started")
 	}
-	txIds, err := bloom.CheckMerkleBlock(merkleBlock)
+
+	header, err := service.Headers().GetHeader(proof.BlockHash)
 	if err != nil {
-		return errors.New("check merkle branch failed, " + err.Error())
-	}
-	if len(txIds) == 0 {
-		return errors.New("invalid transaction proof, no transactions found")
+		return errors.Wrap(errors.ErrNotFound, "can not get block from main chain")
 	}
 
-	// Check if transaction hash is match
-	match := false
-	for _, txId := range txIds {
-		if *txId == *tx.Hash() {
-			match = true
-			break
-		}
-	}
-	if !match {
-		return errors.New("transaction hash not match proof")
+	err = proof.Verify(&header.Header, *tx.Hash())
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, err.Error())
 	}
-
 	return nil
 }
 
 func (service *SPVServiceImpl) SendTransaction(tx tx.Transaction) error {
 	if service.SPVWallet == nil {
-		return errors.New("SPV service not started")
+		return errors.Wrap(errors.ErrNotStarted, "SPV service not started")
 	}
 
 	return service.SPVWallet.SendTransaction(tx)
 }
 
+func (service *SPVServiceImpl) SendTransactionWithKey(key string, txn tx.Transaction) error {
+	if service.SPVWallet == nil {
+		return errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	return service.SPVWallet.SendTransactionWithKey(key, txn)
+}
+
+func (service *SPVServiceImpl) GetTransactionProof(txHash Uint256) (*Proof, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	proof, err := service.proofs.GetByTx(&txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTransactionProof(proof, txHash), nil
+}
+
+// Pick out the compact proof of the transaction from the merkle proof of the block it was packed in
+func (service *SPVServiceImpl) GetMerkleProof(txHash Uint256) (*bloom.MerkleProof, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	proof, err := service.proofs.GetByTx(&txHash)
+	if err != nil {
+		return nil, err
+	}
+	header, err := service.Headers().GetHeader(proof.BlockHash)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrNotFound, "can not get block from main chain")
+	}
+
+	block := bloom.MerkleBlock{
+		BlockHeader:  header.Header,
+		Transactions: proof.Transactions,
+		Hashes:       proof.Hashes,
+		Flags:        proof.Flags,
+	}
+	merkleProof, err := block.GetProof(txHash)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, err.Error())
+	}
+	return merkleProof, nil
+}
+
+func (service *SPVServiceImpl) GetAddressTransactions(address string) ([]*AddrTx, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+
+	addr, err := Uint168FromAddress(address)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "Invalid address format")
+	}
+
+	return service.addrTxs.GetAll(addr)
+}
+
+// Get the registered addresses with the account and height they were registered on
+func (service *SPVServiceImpl) GetRegisteredAddresses() ([]*RegisteredAddr, error) {
+	if service.SPVWallet == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "SPV service not started")
+	}
+	return service.registry.GetAll()
+}
+
 func (service *SPVServiceImpl) Start() error {
 	if service.SPVWallet != nil {
 		return errors.New("SPV service already started")
 	}
 
 	var err error
-	service.SPVWallet, err = spvwallet.Init(service.clientId, service.seeds)
+	service.SPVWallet, err = spvwallet.InitWithConfig(service.clientId, service.config)
 	if err != nil {
 		return err
 	}
+	if service.metered {
+		service.SPVWallet.SetMetered(true)
+	}
+	for _, listener := range service.idle {
+		service.SPVWallet.AddIdleListener(listener)
+	}
+	// Arbiters set before started take place of the config
+	if service.arbiters.Len() > 0 {
+		service.SPVWallet.SetArbiters(service.arbiters.Keys())
+	} else {
+		service.arbiters.Set(service.SPVWallet.Arbiters().Keys())
+	}
+	service.SPVWallet.Arbiters().AddListener(service)
 
 	// Initialize proofs db
-	service.proofs, err = NewProofsDB()
+	service.proofs, err = NewProofsDB(service.config.DataDir)
+	if err != nil {
+		return err
+	}
+
+	// Initialize registered address transactions db
+	service.addrTxs, err = NewAddrTxsDB(service.config.DataDir, MaxAddrTxs, MaxAddrTxsDepth)
 	if err != nil {
 		return err
 	}
 
-	service.queue, err = NewQueueDB()
+	service.queue, err = NewQueueDB(service.config.DataDir)
 	if err != nil {
 		return err
 	}
 
+	// Persist the addresses registered before start, and reload the addresses registered before
+	service.registry, err = NewRegistryDB(service.config.DataDir)
+	if err != nil {
+		return err
+	}
+	height := service.Blockchain().Height()
+	for addr, account := range service.accounts.owned() {
+		addr := addr
+		err = service.registry.Put(account, &addr, height)
+		if err != nil {
+			return err
+		}
+	}
+	registered, err := service.registry.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, r := range registered {
+		service.accounts.add(r.Account, r.Address)
+	}
+	log.Infof("%d registered addresses loaded", len(registered))
+
 	// Register accounts
-	if len(service.accounts) == 0 {
+	addrs := service.accounts.all()
+	if len(addrs) == 0 {
 		return errors.New("No account registered")
 	}
-	for _, account := range service.accounts {
-		service.DataStore().Addrs().Put(account, RegisteredAccountScript, db.TypeNotify)
+	for _, addr := range addrs {
+		service.DataStore().Addrs().Put(addr, RegisteredAccountScript, db.TypeNotify)
 	}
 
 	// Create address filter by accounts
-	service.addrFilter = sdk.NewAddrFilter(service.accounts)
+	service.addrFilter = sdk.NewAddrFilter(addrs)
+
+	// Audit the registered addresses periodically if enabled, skipped while syncing
+	if service.config.AuditInterval > 0 {
+		interval := time.Minute * time.Duration(service.config.AuditInterval)
+		service.Scheduler().Add("audit", interval, spvwallet.DefaultJitter, func() error {
+			if service.Blockchain().IsSyncing() {
+				return nil
+			}
+			_, err := service.AuditAddresses(service.config.AuditDepth)
+			return err
+		})
+	}
 
 	// Set callback
 	service.SPVWallet.Blockchain().AddStateListener(service)
 
+	// Sizes of the databases created by SPV service
+	for name, file := range map[string]string{"proofs": ProofsDBName, "addrtxs": AddrTxsDBName, "registry": RegistryDBName, "queue": DBName} {
+		file := file
+		metrics.NewGaugeFunc(`spv_db_size_bytes{db="`+name+`"}`, "Size of the database files", func() float64 {
+			return metrics.FileSize(file)
+		})
+	}
+
 	// Handle interrupt signal
-	stop := make(chan int, 1)
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt)
-	go func() {
+	supervisor.Go("interrupt handler", func() {
 		for range signals {
 			log.Trace("SPV service shutting down...")
 			service.Stop()
-			stop <- 1
 		}
-	}()
+	})
 
 	// Start SPV service
 	service.SPVWallet.Start()
+	if service.paused {
+		service.SPVWallet.Pause()
+	}
 
-	<-stop
+	<-service.stop
 
 	return nil
 }
 
+func (service *SPVServiceImpl) Stop() {
+	if service.SPVWallet == nil {
+		return
+	}
+	service.SPVWallet.Stop()
+	select {
+	case service.stop <- 1:
+	default:
+	}
+}
+
+func (service *SPVServiceImpl) SetMetered(metered bool) {
+	// Applied when the service started
+	service.metered = metered
+	if service.SPVWallet != nil {
+		service.SPVWallet.SetMetered(metered)
+	}
+}
+
+func (service *SPVServiceImpl) SetArbiters(publicKeys [][]byte) {
+	service.arbiters.Set(publicKeys)
+	if service.SPVWallet != nil {
+		service.SPVWallet.SetArbiters(publicKeys)
+	}
+}
+
+// The arbiter set is kept by the service, so listeners can be registered before started
+func (service *SPVServiceImpl) Arbiters() *sdk.ArbiterSet {
+	return service.arbiters
+}
+
+// Follow the arbiters of the wallet, they're changed when the config file reloaded
+func (service *SPVServiceImpl) OnArbitersChanged(arbiters [][]byte) {
+	service.arbiters.Set(arbiters)
+}
+
+func (service *SPVServiceImpl) RegisterIdleListener(listener sdk.IdleListener) {
+	service.idle = append(service.idle, listener)
+}
+
+func (service *SPVServiceImpl) Pause() {
+	// Applied when the service started
+	service.paused = true
+	if service.SPVWallet != nil {
+		service.SPVWallet.Pause()
+	}
+}
+
+func (service *SPVServiceImpl) Resume() {
+	service.paused = false
+	if service.SPVWallet != nil {
+		service.SPVWallet.Resume()
+	}
+}
+
+// Get the Blockchain instance, nil if the service not started
+func (service *SPVServiceImpl) Blockchain() *sdk.Blockchain {
+	if service.SPVWallet == nil {
+		return nil
+	}
+	return service.SPVWallet.Blockchain()
+}
+
+func (service *SPVServiceImpl) IsHealthy() bool {
+	return service.SPVWallet != nil && service.SPVWallet.IsHealthy()
+}
+
+func (service *SPVServiceImpl) IsReady() bool {
+	return service.SPVWallet != nil && service.SPVWallet.IsReady()
+}
+
 func (service *SPVServiceImpl) OnTxCommitted(tx tx.Transaction, height uint32) {}
-func (service *SPVServiceImpl) OnChainRollback(height uint32)                  {}
+func (service *SPVServiceImpl) OnChainRollback(height uint32) {
+	err := service.addrTxs.Rollback(height)
+	if err != nil {
+		log.Error("Rollback address transactions failed,", err)
+	}
+	err = service.queue.Rollback(height)
+	if err != nil {
+		log.Error("Rollback queue failed,", err)
+	}
+	err = service.proofs.Rollback(height)
+	if err != nil {
+		log.Error("Rollback proofs failed,", err)
+	}
+}
+
 func (service *SPVServiceImpl) OnBlockCommitted(block bloom.MerkleBlock, txs []tx.Transaction) {
 	header := block.BlockHeader
 
-	// Store merkle proof
-	service.proofs.Put(&Proof{
+	// Merkle proof of the block
+	proof := Proof{
 		BlockHash:    *header.Hash(),
 		Height:       header.Height,
 		Transactions: block.Transactions,
 		Hashes:       block.Hashes,
 		Flags:        block.Flags,
-	})
+	}
+
+	// Prune address transactions and proofs out of retention
+	err := service.addrTxs.Prune(header.Height)
+	if err != nil {
+		log.Error("Prune address transactions failed,", err)
+	}
+	if header.Height > MaxAddrTxsDepth {
+		service.pruneProofs(header.Height - MaxAddrTxsDepth)
+	}
 
 	// If no transactions return
 	if len(txs) == 0 {
 		return
 	}
 
+	// Store merkle proof of the matched transactions
+	txHashes := make([]*Uint256, 0, len(txs))
+	for _, tx := range txs {
+		txHashes = append(txHashes, tx.Hash())
+	}
+	err = service.proofs.Put(&proof, txHashes...)
+	if err != nil {
+		log.Error("Store merkle proof failed,", err)
+	}
+
 	// Find transactions matches registered accounts
 	var matchedTxs []tx.Transaction
 	for _, tx := range txs {
-		for _, output := range tx.Outputs {
-			if service.addrFilter.ContainAddr(output.ProgramHash) {
-				matchedTxs = append(matchedTxs, tx)
+		match := service.addrFilter.MatchTx(&tx)
+		for _, index := range match.Outputs {
+			// Store transaction under the registered address
+			programHash := tx.Outputs[index].ProgramHash
+			err := service.addrTxs.Put(&programHash, &AddrTx{
+				Height: header.Height,
+				Tx:     tx,
+				Proof:  *getTransactionProof(&proof, *tx.Hash()),
+			})
+			if err != nil {
+				log.Error("Store address transaction failed,", err)
 			}
 		}
+		if len(match.Outputs) > 0 {
+			matchedTxs = append(matchedTxs, tx)
+		}
 	}
 
 	// Queue matched transactions
@@ -199,43 +478,143 @@ func (service *SPVServiceImpl) OnBlockCommitted(block bloom.MerkleBlock, txs []t
 		service.queue.Put(item)
 	}
 
-	// Look up for queued transactions
-	items, err := service.queue.GetAll()
+	// Look up for queued transactions not acked yet, they will be notified again
+	// on every block until the receipt submitted
+	seen, err := service.queue.GetByState(QueueSeen)
+	if err != nil {
+		log.Error("Query queue failed,", err)
+		return
+	}
+	notified, err := service.queue.GetByState(QueueNotified)
 	if err != nil {
+		log.Error("Query queue failed,", err)
 		return
 	}
-	for _, item := range items {
+	pending := make(map[*registeredListener][]*Notification)
+	for _, item := range append(seen, notified...) {
 		//	Get proof from db
 		proof, err := service.proofs.Get(&item.BlockHash)
 		if err != nil {
 			log.Error("Query merkle proof failed, block hash:", item.BlockHash.String())
-			return
+			continue
 		}
 		//	Get transaction from db
 		storeTx, err := service.DataStore().Txs().Get(&item.TxHash)
 		if err != nil {
 			log.Error("Query transaction failed, tx hash:", item.TxHash.String())
-			return
+			continue
 		}
 		// Prune the proof by the given transaction id
 		proof = getTransactionProof(proof, storeTx.TxId)
 
 		// Notify listeners
-		service.notifyListeners(*proof, storeTx.Data, header.Height-item.Height)
+		confirmed := service.isConfirmed(storeTx.Data, item.Height, header.Height)
+		if service.notifyListeners(*proof, storeTx.Data, confirmed, pending) && item.State == QueueSeen {
+			service.queue.UpdateState(&item.TxHash, QueueNotified)
+		}
+	}
+	notifyBatches(&header, pending)
+
+	// Mark acked transactions confirmed when they are confirmed, and delete the confirmed
+	// ones from queue once they reach the confirmations
+	acked, err := service.queue.GetByState(QueueAcked)
+	if err != nil {
+		log.Error("Query queue failed,", err)
+		return
+	}
+	for _, item := range acked {
+		storeTx, err := service.DataStore().Txs().Get(&item.TxHash)
+		if err != nil {
+			log.Error("Query transaction failed, tx hash:", item.TxHash.String())
+			continue
+		}
+		if service.isConfirmed(storeTx.Data, item.Height, header.Height) {
+			service.queue.UpdateState(&item.TxHash, QueueConfirmed)
+		}
+	}
+	confirmed, err := service.queue.GetByState(QueueConfirmed)
+	if err != nil {
+		log.Error("Query queue failed,", err)
+		return
+	}
+	for _, item := range confirmed {
+		storeTx, err := service.DataStore().Txs().Get(&item.TxHash)
+		if err != nil {
+			log.Error("Query transaction failed, tx hash:", item.TxHash.String())
+			continue
+		}
+		if header.Height-item.Height >= getConfirmations(storeTx.Data) {
+			err = service.queue.Delete(&item.TxHash)
+			if err != nil {
+				log.Error("Delete queue item failed,", err)
+			}
+		}
 	}
 }
 
-func (service *SPVServiceImpl) notifyListeners(proof Proof, tx tx.Transaction, confirmations uint32) {
+// Prune the proofs below the height, except the proofs of the queued transactions not acked yet,
+// they are notified again until the receipts are submitted
+func (service *SPVServiceImpl) pruneProofs(height uint32) {
+	var keep []*Uint256
+	for _, state := range []QueueState{QueueSeen, QueueNotified} {
+		items, err := service.queue.GetByState(state)
+		if err != nil {
+			log.Error("Query queue failed,", err)
+			return
+		}
+		for _, item := range items {
+			if item.Height < height {
+				keep = append(keep, &item.BlockHash)
+			}
+		}
+	}
+
+	err := service.proofs.Prune(height, keep...)
+	if err != nil {
+		log.Error("Prune proofs failed,", err)
+	}
+}
+
+// Notify listeners of the transaction type, return if any listener was notified.
+// Transactions to the batch listeners are appended to the pending notifications,
+// unless they pay to a high priority address
+func (service *SPVServiceImpl) notifyListeners(proof Proof, tx tx.Transaction, confirmed bool,
+	pending map[*registeredListener][]*Notification) bool {
+	notified := false
 	listeners := service.listeners[tx.TxType]
 	for _, listener := range listeners {
-		if listener.Confirmed() {
-			if confirmations >= getConfirmations(tx) {
-				go listener.Notify(proof, tx)
-			}
+		// The filter is supplied by the integrator too, a disabled listener accepts nothing
+		var accepted, confirmedOnly bool
+		err := listener.guard.Run(func() {
+			accepted = listener.filter == nil || listener.filter(tx)
+			confirmedOnly = listener.Confirmed()
+		})
+		if err != nil || !accepted || confirmedOnly && !confirmed {
+			continue
+		}
+		if listener.batches != nil && !service.accounts.isPriority(&tx) {
+			pending[listener] = append(pending[listener], &Notification{Proof: proof, Tx: tx})
 		} else {
-			go listener.Notify(proof, tx)
+			go notify(listener, proof, tx)
 		}
+		notified = true
+	}
+	return notified
+}
+
+func notify(listener *registeredListener, proof Proof, tx tx.Transaction) {
+	start := clock.Now()
+	listener.guard.Run(func() { listener.TransactionListener.Notify(proof, tx) })
+	notifyLatency.Observe(clock.Since(start).Seconds())
+}
+
+// Check if the transaction reached the confirmations, or it's block is confirmed by the DPoS arbiters
+func (service *SPVServiceImpl) isConfirmed(tx tx.Transaction, height, chainHeight uint32) bool {
+	if chainHeight-height >= getConfirmations(tx) {
+		return true
 	}
+	confirmed := service.Blockchain().ConfirmedHeight()
+	return confirmed > 0 && height <= confirmed
 }
 
 func getConfirmations(tx tx.Transaction) uint32 {
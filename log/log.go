@@ -4,7 +4,9 @@ import (
 	"io"
 	"os"
 	"log"
+	"path/filepath"
 	"fmt"
+	"sync/atomic"
 	"time"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 )
@@ -29,14 +31,22 @@ const (
 	LevelFile  = 5
 )
 
-var level uint8
+// The print level, changed at runtime by SetLevel() while logging, read by getLevel()
+var level uint32
 var logger *log.Logger
 
+// Initiate the log with the config read from config file
 func Init() {
+	InitWithConfig(config.Values())
+}
+
+// Initiate the log with the given config, the log files are written to PATH in the data directory
+func InitWithConfig(cfg *config.Config) {
 	writers := []io.Writer{}
-	level = config.Values().PrintLevel
-	if level >= LevelFile {
-		logFile, err := OpenLogFile()
+	SetLevel(cfg.PrintLevel)
+	if cfg.PrintLevel >= LevelFile {
+		dir := filepath.Join(cfg.DataDir, PATH)
+		logFile, err := NewRotateWriter(dir, cfg.LogMaxSize, cfg.LogMaxAge, cfg.LogMaxBackups, cfg.LogCompress)
 		if err != nil {
 			fmt.Println("error: open log file failed")
 			os.Exit(1)
@@ -47,13 +57,24 @@ func Init() {
 	logger = log.New(io.MultiWriter(writers...), "", log.Ldate|log.Lmicroseconds)
 }
 
-func OpenLogFile() (*os.File, error) {
-	if fi, err := os.Stat(PATH); err == nil {
+// Change the print level at runtime
+func SetLevel(printLevel uint8) {
+	atomic.StoreUint32(&level, uint32(printLevel))
+}
+
+func getLevel() uint8 {
+	return uint8(atomic.LoadUint32(&level))
+}
+
+// Open a new log file in the directory named by the current time, a sequence number is appended
+// to the name if a log file of the same second exists
+func OpenLogFile(dir string) (*os.File, error) {
+	if fi, err := os.Stat(dir); err == nil {
 		if !fi.IsDir() {
-			return nil, fmt.Errorf("open %s: not a directory", PATH)
+			return nil, fmt.Errorf("open %s: not a directory", dir)
 		}
 	} else if os.IsNotExist(err) {
-		if err := os.MkdirAll(PATH, 0766); err != nil {
+		if err := os.MkdirAll(dir, 0766); err != nil {
 			return nil, err
 		}
 	} else {
@@ -61,11 +82,18 @@ func OpenLogFile() (*os.File, error) {
 	}
 
 	current := time.Now().Format("2006-01-02_15.04.05")
-	logfile, err := os.OpenFile(PATH+current+"_LOG.log", os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
-		return nil, err
+	name := current
+	for seq := 1; ; seq++ {
+		path := filepath.Join(dir, name+LogFileSuffix)
+		// The compressed file of the same name is overwritten when the new one is compressed
+		if _, err := os.Stat(path + GzipSuffix); os.IsNotExist(err) {
+			logfile, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0666)
+			if !os.IsExist(err) {
+				return logfile, err
+			}
+		}
+		name = fmt.Sprintf("%s_%03d", current, seq)
 	}
-	return logfile, nil
 }
 
 func Info(msg ...interface{}) {
@@ -81,7 +109,7 @@ func Trace(msg ...interface{}) {
 }
 
 func Tracef(format string, msg ...interface{}) {
-	if level >= LevelTrace {
+	if getLevel() >= LevelTrace {
 		logger.Output(CallDepth, color(BLUE, "[TRACE]", fmt.Sprintf(format, msg...)))
 	}
 }
@@ -91,7 +119,7 @@ func Warn(msg ...interface{}) {
 }
 
 func Warnf(format string, msg ...interface{}) {
-	if level >= LevelWarn {
+	if getLevel() >= LevelWarn {
 		logger.Output(CallDepth, color(YELLOW, "[WARN]", fmt.Sprintf(format, msg...)))
 	}
 }
@@ -101,17 +129,20 @@ func Error(msg ...interface{}) {
 }
 
 func Errorf(format string, msg ...interface{}) {
-	if level >= LevelError {
+	if getLevel() >= LevelError {
 		logger.Output(CallDepth, color(RED, "[ERROR]", fmt.Sprintf(format, msg...)))
 	}
 }
 
+// Debug logs are on the sync hot path, skip formatting the message if they are not printed
 func Debug(msg ...interface{}) {
-	Debugf("%s", fmt.Sprint(msg...))
+	if getLevel() >= LevelDebug {
+		Debugf("%s", fmt.Sprint(msg...))
+	}
 }
 
 func Debugf(format string, msg ...interface{}) {
-	if level >= LevelDebug {
+	if getLevel() >= LevelDebug {
 		logger.Output(CallDepth, color(GREEN, "[DEBUG]", fmt.Sprintf(format, msg...)))
 	}
 }
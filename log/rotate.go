@@ -0,0 +1,201 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LogFileSuffix = "_LOG.log"
+	GzipSuffix    = ".gz"
+)
+
+// RotateWriter writes logs to file in the directory, when the file size reaches the limit,
+// a new log file will be created and the old ones are cleaned up by the limits
+type RotateWriter struct {
+	sync.Mutex
+	dir  string
+	file *os.File
+	size int64
+	// Clean up runs one at a time
+	cleanLock sync.Mutex
+
+	// Max bytes of a log file, 0 means no limit
+	maxSize int64
+	// Max age of the rotated log files, 0 means no limit
+	maxAge time.Duration
+	// Max rotated log files to keep, 0 means no limit
+	maxBackups int
+	// Compress the rotated log files with gzip
+	compress bool
+}
+
+func NewRotateWriter(dir string, maxSizeMB, maxAgeDays, maxBackups int, compress bool) (*RotateWriter, error) {
+	writer := &RotateWriter{
+		dir:        dir,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+
+	err := writer.openNew()
+	if err != nil {
+		return nil, err
+	}
+
+	// Clean up the log files left by last running
+	go writer.cleanup()
+
+	return writer, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		err := w.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close the current log file
+func (w *RotateWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.file.Close()
+}
+
+func (w *RotateWriter) openNew() error {
+	file, err := OpenLogFile(w.dir)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *RotateWriter) rotate() error {
+	err := w.file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = w.openNew()
+	if err != nil {
+		return err
+	}
+
+	go w.cleanup()
+	return nil
+}
+
+// Remove the rotated log files exceed the limits and compress the rest
+func (w *RotateWriter) cleanup() {
+	w.cleanLock.Lock()
+	defer w.cleanLock.Unlock()
+
+	w.Lock()
+	current := w.file.Name()
+	w.Unlock()
+
+	infos, err := ioutil.ReadDir(w.dir)
+	if err != nil {
+		return
+	}
+
+	// Log file names are the created time and the sequence in the second, sort them from the newest
+	var backups []os.FileInfo
+	for _, info := range infos {
+		name := info.Name()
+		if filepath.Join(w.dir, name) == current || info.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(name, LogFileSuffix) || strings.HasSuffix(name, LogFileSuffix+GzipSuffix) {
+			backups = append(backups, info)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return logFileKey(backups[i].Name()) > logFileKey(backups[j].Name())
+	})
+
+	var remains []os.FileInfo
+	for i, info := range backups {
+		expired := w.maxAge > 0 && time.Since(info.ModTime()) > w.maxAge
+		exceeded := w.maxBackups > 0 && i >= w.maxBackups
+		if expired || exceeded {
+			os.Remove(filepath.Join(w.dir, info.Name()))
+			continue
+		}
+		remains = append(remains, info)
+	}
+
+	if !w.compress {
+		return
+	}
+	for _, info := range remains {
+		if strings.HasSuffix(info.Name(), LogFileSuffix) {
+			err := compressFile(filepath.Join(w.dir, info.Name()))
+			if err != nil {
+				Error("Compress log file failed,", err)
+			}
+		}
+	}
+}
+
+// The name of the log file without the suffixes, a name with the sequence sorts after the same second without
+func logFileKey(name string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(name, GzipSuffix), LogFileSuffix)
+}
+
+// Compress the file with gzip and remove the original one
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+GzipSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(name + GzipSuffix)
+		return err
+	}
+
+	return os.Remove(name)
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenLogFileSameSecond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spvlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Log files opened in the same second have different names, sorted by the order opened
+	var names []string
+	for i := 0; i < 3; i++ {
+		file, err := OpenLogFile(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		name := filepath.Base(file.Name())
+		for _, prev := range names {
+			if name == prev {
+				t.Fatalf("log file %s opened again", name)
+			}
+			if logFileKey(name) < logFileKey(prev) && logFileKey(name)[:19] == logFileKey(prev)[:19] {
+				t.Errorf("log file %s sorted before %s of the same second", name, prev)
+			}
+		}
+		names = append(names, name)
+	}
+
+	// A compressed log file of the name is not overwritten
+	first := filepath.Join(dir, names[0])
+	if err := compressFile(first); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenLogFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if file.Name() == first {
+		t.Errorf("log file %s opened with the compressed one", file.Name())
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
 	"encoding/binary"
@@ -11,10 +12,21 @@ import (
 )
 
 func main() {
+	// Load config, settings in config file can be overridden by environment variables and flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println("Load config failed,", err)
+		os.Exit(1)
+	}
+	if err := spvwallet.Setup(cfg); err != nil {
+		fmt.Println("Setup failed,", err)
+		os.Exit(1)
+	}
+
 	// Initiate log
 	log.Init()
 
-	file, err := spvwallet.OpenKeystoreFile()
+	file, err := spvwallet.OpenKeystoreFile(cfg.DataDir)
 	if err != nil {
 		log.Error("Keystore.dat file not found, please create your wallet using ela-wallet first")
 		os.Exit(0)
@@ -28,6 +40,9 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Reload config on SIGHUP or config file changed
+	config.Watch(func(err error) { log.Error("Reload config file error, ", err) })
+
 	// Handle interrupt signal
 	stop := make(chan int, 1)
 	c := make(chan os.Signal, 1)
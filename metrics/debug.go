@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+}
+
+// Start the HTTP debug server serving pprof profiles on /debug/pprof/ and expvar on /debug/vars,
+// goroutine dumps are on /debug/pprof/goroutine?debug=2. The endpoints expose internal state of the
+// process, so the server should listen on a local address like "127.0.0.1:20879". It's served with TLS if
+// the TLS config is not nil.
+func StartDebug(addr string, tlsConfig *tls.Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{Addr: addr, Handler: mux, TLSConfig: tlsConfig}
+	serve(server, "Debug")
+	log.Info("Debug server started on", addr)
+	return server
+}
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+
+	// Path of the metrics endpoint
+	MetricsPath = "/metrics"
+)
+
+// Default latency buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Get count buckets starting from start, each bucket is factor times the previous one,
+// so the relative precision is the same over a wide range of values
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Counter is a value only goes up
+type Counter struct {
+	value uint64
+}
+
+func (c *Counter) Add(delta uint64) {
+	atomic.AddUint64(&c.value, delta)
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+func (c *Counter) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %d\n", name, c.Value())
+}
+
+// Gauge is a value can go up and down
+type Gauge struct {
+	bits uint64
+}
+
+func (g *Gauge) Set(value float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(value))
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %v\n", name, g.Value())
+}
+
+// GaugeFunc is a gauge the value is got when metrics collected
+type GaugeFunc func() float64
+
+func (f GaugeFunc) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %v\n", name, f())
+}
+
+// Histogram counts observed values in buckets
+type Histogram struct {
+	sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.Lock()
+	defer h.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.Lock()
+	defer h.Unlock()
+
+	// The labels of the series are put before the le label of the buckets
+	base, labels, bucketLabels := name, "", ""
+	if index := strings.Index(name, "{"); index > 0 {
+		base, labels = name[:index], name[index:]
+		bucketLabels = name[index+1:len(name)-1] + ","
+	}
+
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%v\"} %d\n", base, bucketLabels, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", base, bucketLabels, h.count)
+	fmt.Fprintf(w, "%s_sum%s %v\n", base, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", base, labels, h.count)
+}
+
+type metric struct {
+	help  string
+	typ   string
+	value interface {
+		write(w io.Writer, name string)
+	}
+}
+
+var (
+	lock sync.RWMutex
+	// Metrics keyed by the series name, a series name is the metric name with optional labels
+	metrics = make(map[string]*metric)
+)
+
+// Register a metric with the series name, like "spv_db_size_bytes{db=\"headers\"}",
+// a registered one with the same series name will be replaced
+func register(name, help, typ string, value interface {
+	write(w io.Writer, name string)
+}) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	metrics[name] = &metric{help: help, typ: typ, value: value}
+}
+
+func NewCounter(name, help string) *Counter {
+	counter := new(Counter)
+	register(name, help, TypeCounter, counter)
+	return counter
+}
+
+func NewGauge(name, help string) *Gauge {
+	gauge := new(Gauge)
+	register(name, help, TypeGauge, gauge)
+	return gauge
+}
+
+func NewGaugeFunc(name, help string, f func() float64) {
+	register(name, help, TypeGauge, GaugeFunc(f))
+}
+
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	histogram := &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	register(name, help, TypeHistogram, histogram)
+	return histogram
+}
+
+// Get the file size in bytes, used by the gauges of database sizes
+func FileSize(path string) float64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return float64(info.Size())
+}
+
+// Write all registered metrics in the Prometheus text format
+func Write(w io.Writer) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var last string
+	for _, name := range names {
+		m := metrics[name]
+		// HELP and TYPE are written once for the series of a metric
+		base := name
+		if index := strings.Index(name, "{"); index > 0 {
+			base = name[:index]
+		}
+		if base != last {
+			fmt.Fprintf(w, "# HELP %s %s\n", base, m.help)
+			fmt.Fprintf(w, "# TYPE %s %s\n", base, m.typ)
+			last = base
+		}
+		m.value.write(w, name)
+	}
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	Write(w)
+}
+
+// Start the HTTP server serving metrics on the given address, with TLS if the TLS config is not nil
+func Start(addr string, tlsConfig *tls.Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(MetricsPath, handle)
+	server := &http.Server{Addr: addr, Handler: mux, TLSConfig: tlsConfig}
+	serve(server, "Metrics")
+	log.Info("Metrics server started on", addr+MetricsPath)
+	return server
+}
+
+// Serve the server in a goroutine, with TLS if the server has the TLS config
+func serve(server *http.Server, name string) {
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			// The certificate is served by the TLS config, so it's reloaded when replaced
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Error(name+" server stopped,", err)
+		}
+	}()
+}
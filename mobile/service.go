@@ -0,0 +1,250 @@
+package mobile
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/interface"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
+)
+
+/*
+Listener is the callbacks of the SPV service events, implemented by the app in Java or Objective-C.
+The callbacks are called on the service goroutines, switch to the UI thread if needed.
+*/
+type Listener interface {
+	// A transaction of the registered accounts received. txId is the hex string of the transaction hash,
+	// rawTx is the serialized transaction and proof is the serialized merkle proof to verify it.
+	// Call SubmitTransactionReceipt() after it's handled, or it will be notified again
+	OnTransaction(txId string, rawTx []byte, proof []byte, confirmed bool)
+
+	// The service synced up with the network, the app can pause it when in background
+	OnIdle()
+
+	// The service failed to start or stopped with an error
+	OnError(err string)
+}
+
+/*
+Service is the SPV service binding for gomobile, only basic types and byte slices are used
+across the boundary. Transactions are exchanged in the serialized form, hashes in hex strings
+and addresses in base58 strings.
+*/
+type Service struct {
+	service  _interface.SPVService
+	listener Listener
+}
+
+// Create the SPV service, magic 0 means the main net, seeds are the comma separated
+// full node addresses, databases and logs are stored in dataDir
+func NewService(clientId int64, magic int64, seeds string, dataDir string) (*Service, error) {
+	cfg := *config.Values()
+	cfg.Magic = uint32(magic)
+	cfg.SeedList = nil
+	for _, seed := range strings.Split(seeds, ",") {
+		if seed = strings.TrimSpace(seed); seed != "" {
+			cfg.SeedList = append(cfg.SeedList, seed)
+		}
+	}
+	cfg.DataDir = dataDir
+	if err := spvwallet.Setup(&cfg); err != nil {
+		return nil, err
+	}
+	log.InitWithConfig(&cfg)
+
+	s := &Service{service: _interface.NewSPVServiceWithConfig(uint64(clientId), &cfg)}
+	s.service.RegisterIdleListener(&idleListener{service: s})
+	return s, nil
+}
+
+// Set the listener of the service events, it must be set before start
+func (s *Service) SetListener(listener Listener) {
+	s.listener = listener
+}
+
+// Register the account address to receive transaction notifications
+func (s *Service) RegisterAccount(address string) error {
+	return s.service.RegisterAccount(address)
+}
+
+// Receive notifications of the transactions in the type, confirmed is true to
+// be notified after the transaction confirmed, false to be notified at once
+func (s *Service) ListenTransactions(txType int, confirmed bool) {
+	s.service.RegisterTransactionListener(&txListener{
+		service:   s,
+		txType:    tx.TransactionType(txType),
+		confirmed: confirmed,
+	})
+}
+
+// Start the service in background, errors are reported by Listener.OnError()
+func (s *Service) Start() {
+	go func() {
+		if err := s.service.Start(); err != nil {
+			log.Error("SPV service start failed, ", err)
+			if s.listener != nil {
+				s.listener.OnError(err.Error())
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.service.Stop()
+}
+
+// Stop the network activity when the app goes background, the sync continues from the last block after resumed
+func (s *Service) Pause() {
+	s.service.Pause()
+}
+
+func (s *Service) Resume() {
+	s.service.Resume()
+}
+
+// Run in the low bandwidth mode on a metered connection
+func (s *Service) SetMetered(metered bool) {
+	s.service.SetMetered(metered)
+}
+
+// Get the height of the synced chain, 0 if the service not started
+func (s *Service) Height() int64 {
+	if chain := s.service.Blockchain(); chain != nil {
+		return int64(chain.Height())
+	}
+	return 0
+}
+
+func (s *Service) IsReady() bool {
+	return s.service.IsReady()
+}
+
+// Confirm the notified transaction was handled, so it's not notified again
+func (s *Service) SubmitTransactionReceipt(txId string) error {
+	hash, err := hashFromString(txId)
+	if err != nil {
+		return err
+	}
+	return s.service.SubmitTransactionReceipt(*hash)
+}
+
+// Verify the serialized transaction with the serialized merkle proof
+func (s *Service) VerifyTransaction(proof []byte, rawTx []byte) error {
+	var p _interface.Proof
+	if err := p.Deserialize(bytes.NewReader(proof)); err != nil {
+		return err
+	}
+	txn, err := deserializeTx(rawTx)
+	if err != nil {
+		return err
+	}
+	return s.service.VerifyTransaction(p, *txn)
+}
+
+// Get the serialized merkle proof of a received transaction
+func (s *Service) GetTransactionProof(txId string) ([]byte, error) {
+	hash, err := hashFromString(txId)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := s.service.GetTransactionProof(*hash)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := proof.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Send the serialized signed transaction to the network
+func (s *Service) SendTransaction(rawTx []byte) error {
+	txn, err := deserializeTx(rawTx)
+	if err != nil {
+		return err
+	}
+	return s.service.SendTransaction(*txn)
+}
+
+// Send the serialized signed transaction with an idempotency key, retry with the same key
+// after an error so the transaction is not sent twice
+func (s *Service) SendTransactionWithKey(key string, rawTx []byte) error {
+	txn, err := deserializeTx(rawTx)
+	if err != nil {
+		return err
+	}
+	return s.service.SendTransactionWithKey(key, *txn)
+}
+
+// idleListener forwards the idle event to the Listener
+type idleListener struct {
+	service *Service
+}
+
+func (l *idleListener) OnIdle() {
+	if l.service.listener != nil {
+		l.service.listener.OnIdle()
+	}
+}
+
+// txListener forwards the transaction notifications to the Listener
+type txListener struct {
+	service   *Service
+	txType    tx.TransactionType
+	confirmed bool
+}
+
+func (l *txListener) Type() tx.TransactionType {
+	return l.txType
+}
+
+func (l *txListener) Confirmed() bool {
+	return l.confirmed
+}
+
+func (l *txListener) Notify(proof _interface.Proof, txn tx.Transaction) {
+	if l.service.listener == nil {
+		return
+	}
+	rawTx, err := serializeTx(&txn)
+	if err != nil {
+		log.Error("Serialize notified transaction failed, ", err)
+		return
+	}
+	buf := new(bytes.Buffer)
+	if err := proof.Serialize(buf); err != nil {
+		log.Error("Serialize transaction proof failed, ", err)
+		return
+	}
+	l.service.listener.OnTransaction(txn.Hash().String(), rawTx, buf.Bytes(), l.confirmed)
+}
+
+func hashFromString(str string) (*Uint256, error) {
+	data, err := HexStringToBytes(str)
+	if err != nil {
+		return nil, errors.New("invalid hash string " + str)
+	}
+	return Uint256FromBytes(data)
+}
+
+func serializeTx(txn *tx.Transaction) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := txn.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func deserializeTx(rawTx []byte) (*tx.Transaction, error) {
+	txn := new(tx.Transaction)
+	if err := txn.Deserialize(bytes.NewReader(rawTx)); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
@@ -0,0 +1,133 @@
+package mobile
+
+import (
+	"time"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+)
+
+// StringList is a list of strings, slices other than byte slices can not be bound by gomobile
+type StringList struct {
+	items []string
+}
+
+func (l *StringList) Size() int {
+	return len(l.items)
+}
+
+func (l *StringList) Get(index int) string {
+	return l.items[index]
+}
+
+/*
+Wallet is the SPV wallet binding for gomobile. The keystore and database are in the data directory
+set by NewService(), transactions are created and signed in the serialized form, send them by
+Service.SendTransaction(). Amounts are in the smallest unit, 1 ELA is 100000000.
+*/
+type Wallet struct {
+	wallet spvwallet.Wallet
+}
+
+// Create a new wallet protected by the password
+func CreateWallet(password []byte) (*Wallet, error) {
+	wallet, err := spvwallet.Create(password)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{wallet: wallet}, nil
+}
+
+// Open the wallet created before
+func OpenWallet() (*Wallet, error) {
+	wallet, err := spvwallet.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{wallet: wallet}, nil
+}
+
+func (w *Wallet) VerifyPassword(password []byte) error {
+	return w.wallet.VerifyPassword(password)
+}
+
+func (w *Wallet) ChangePassword(oldPassword, newPassword []byte) error {
+	return w.wallet.ChangePassword(oldPassword, newPassword)
+}
+
+// Unlock the wallet by the password for the seconds, while unlocked the signing methods
+// sign with an empty password
+func (w *Wallet) Unlock(password []byte, seconds int64) error {
+	return w.wallet.Unlock(password, time.Duration(seconds)*time.Second)
+}
+
+// Lock the wallet before the unlock duration expires
+func (w *Wallet) Lock() {
+	w.wallet.Lock()
+}
+
+func (w *Wallet) IsLocked() bool {
+	return w.wallet.IsLocked()
+}
+
+// Create a new sub account and return the address of it
+func (w *Wallet) NewAccount(password []byte) (string, error) {
+	programHash, err := w.wallet.NewSubAccount(password)
+	if err != nil {
+		return "", err
+	}
+	return programHash.ToAddress()
+}
+
+// Get the addresses of the accounts in the wallet
+func (w *Wallet) Addresses() (*StringList, error) {
+	addrs, err := w.wallet.GetAddrs()
+	if err != nil {
+		return nil, err
+	}
+	list := new(StringList)
+	for _, addr := range addrs {
+		list.items = append(list.items, addr.String())
+	}
+	return list, nil
+}
+
+// Get the balance of the address, including the locked and unconfirmed UTXOs
+func (w *Wallet) Balance(address string) (int64, error) {
+	programHash, err := Uint168FromAddress(address)
+	if err != nil {
+		return 0, err
+	}
+	utxos, err := w.wallet.GetAddressUTXOs(programHash)
+	if err != nil {
+		return 0, err
+	}
+	var balance Fixed64
+	for _, utxo := range utxos {
+		balance += utxo.Value
+	}
+	return int64(balance), nil
+}
+
+// Create a serialized unsigned transaction to transfer the amount from one address to another
+func (w *Wallet) CreateTransaction(fromAddress, toAddress string, amount, fee int64) ([]byte, error) {
+	value, txFee := Fixed64(amount), Fixed64(fee)
+	txn, err := w.wallet.CreateTransaction(fromAddress, toAddress, &value, &txFee)
+	if err != nil {
+		return nil, err
+	}
+	return serializeTx(txn)
+}
+
+// Sign the serialized transaction and return the serialized signed transaction
+func (w *Wallet) Sign(password []byte, rawTx []byte) ([]byte, error) {
+	txn, err := deserializeTx(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	txn, err = w.wallet.Sign(password, txn)
+	if err != nil {
+		return nil, err
+	}
+	return serializeTx(txn)
+}
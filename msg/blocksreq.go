@@ -34,7 +34,7 @@ func (msg *BlocksReq) Deserialize(body []byte) error {
 		return err
 	}
 
-	locator := make([]*Uint256, msg.Count)
+	msg.BlockLocator = nil
 	for i := uint32(0); i < msg.Count; i++ {
 		var hash Uint256
 		err := hash.Deserialize(buf)
@@ -42,7 +42,7 @@ func (msg *BlocksReq) Deserialize(body []byte) error {
 			return err
 		}
 
-		locator = append(locator, &hash)
+		msg.BlockLocator = append(msg.BlockLocator, &hash)
 	}
 
 	err = msg.HashStop.Deserialize(buf)
@@ -0,0 +1,44 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Capability flags of a peer in the capabilities message
+const (
+	// The peer serves the merkleblock messages of the blocks filtered by a filterload
+	CapBloomFilter uint64 = 1 << iota
+	// The peer serves the compact block filters
+	CapCompactFilter
+	// The peer responds a notfound message to the data requests it can not serve
+	CapNotFound
+)
+
+// The version of the bloom filter protocol, the filterload, merkleblock and the filter matching rules
+const FilterVersion = 1
+
+// Capabilities is exchanged after the handshake by the peers of the negotiated protocol version
+// supporting it, so the SPV client knows which features the peer serves
+type Capabilities struct {
+	Capabilities  uint64
+	FilterVersion uint32
+}
+
+func (msg *Capabilities) CMD() string {
+	return "capabilities"
+}
+
+func (msg *Capabilities) Serialize() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.LittleEndian, msg)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msg *Capabilities) Deserialize(body []byte) error {
+	buf := bytes.NewReader(body)
+	return binary.Read(buf, binary.LittleEndian, msg)
+}
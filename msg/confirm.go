@@ -0,0 +1,156 @@
+package msg
+
+import (
+	"bytes"
+	"io"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+)
+
+// Max votes of a confirm message, more than the arbiters of any DPoS round
+const MaxConfirmVotes = 1024
+
+// Min serialized size of a vote, the proposal hash, empty signer and sign, and the accept flag
+const minVoteSize = UINT256SIZE + 1 + 1 + 1
+
+// DPoSProposal is a block proposed by the on duty arbiter, signed by it's public key
+type DPoSProposal struct {
+	Sponsor    []byte
+	BlockHash  Uint256
+	ViewOffset uint32
+	Sign       []byte
+}
+
+// DPoSVote is an arbiter accepting or rejecting the proposal, signed by it's public key
+type DPoSVote struct {
+	ProposalHash Uint256
+	Signer       []byte
+	Accept       bool
+	Sign         []byte
+}
+
+/*
+Confirm is the DPoS confirm message of a block, the proposal of the block
+with the votes of the arbiters, a block confirmed by the supermajority of
+the arbiters is irreversible.
+*/
+type Confirm struct {
+	Proposal DPoSProposal
+	Votes    []DPoSVote
+}
+
+func (p *DPoSProposal) SerializeUnsigned(w io.Writer) error {
+	if err := serialization.WriteVarBytes(w, p.Sponsor); err != nil {
+		return err
+	}
+	if err := p.BlockHash.Serialize(w); err != nil {
+		return err
+	}
+	return serialization.WriteUint32(w, p.ViewOffset)
+}
+
+func (p *DPoSProposal) Serialize(w io.Writer) error {
+	if err := p.SerializeUnsigned(w); err != nil {
+		return err
+	}
+	return serialization.WriteVarBytes(w, p.Sign)
+}
+
+func (p *DPoSProposal) Deserialize(r io.Reader) (err error) {
+	if p.Sponsor, err = serialization.ReadVarBytes(r); err != nil {
+		return err
+	}
+	if err = p.BlockHash.Deserialize(r); err != nil {
+		return err
+	}
+	if p.ViewOffset, err = serialization.ReadUint32(r); err != nil {
+		return err
+	}
+	p.Sign, err = serialization.ReadVarBytes(r)
+	return err
+}
+
+// Get the hash of the proposal, the votes are signed on it
+func (p *DPoSProposal) Hash() Uint256 {
+	w := serialization.NewHashWriter()
+	p.SerializeUnsigned(w)
+	return w.Sum256D()
+}
+
+func (v *DPoSVote) SerializeUnsigned(w io.Writer) error {
+	if err := v.ProposalHash.Serialize(w); err != nil {
+		return err
+	}
+	if err := serialization.WriteVarBytes(w, v.Signer); err != nil {
+		return err
+	}
+	var accept uint8
+	if v.Accept {
+		accept = 1
+	}
+	return serialization.WriteUint8(w, accept)
+}
+
+func (v *DPoSVote) Serialize(w io.Writer) error {
+	if err := v.SerializeUnsigned(w); err != nil {
+		return err
+	}
+	return serialization.WriteVarBytes(w, v.Sign)
+}
+
+func (v *DPoSVote) Deserialize(r io.Reader) (err error) {
+	if err = v.ProposalHash.Deserialize(r); err != nil {
+		return err
+	}
+	if v.Signer, err = serialization.ReadVarBytes(r); err != nil {
+		return err
+	}
+	accept, err := serialization.ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	v.Accept = accept == 1
+	v.Sign, err = serialization.ReadVarBytes(r)
+	return err
+}
+
+func (msg *Confirm) CMD() string {
+	return "confirm"
+}
+
+func (msg *Confirm) Serialize() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := msg.Proposal.Serialize(buf); err != nil {
+		return nil, err
+	}
+	if err := serialization.WriteVarUint(buf, uint64(len(msg.Votes))); err != nil {
+		return nil, err
+	}
+	for i := range msg.Votes {
+		if err := msg.Votes[i].Serialize(buf); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (msg *Confirm) Deserialize(body []byte) error {
+	buf := bytes.NewReader(body)
+	if err := msg.Proposal.Deserialize(buf); err != nil {
+		return err
+	}
+	count, err := serialization.ReadCount(buf, MaxConfirmVotes, minVoteSize)
+	if err != nil {
+		return err
+	}
+	msg.Votes = make([]DPoSVote, count)
+	for i := range msg.Votes {
+		if err := msg.Votes[i].Deserialize(buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -7,7 +7,7 @@ import (
 )
 
 type DataReq struct {
-	Type uint8
+	Type InvType
 	Hash Uint256
 }
 
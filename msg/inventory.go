@@ -3,12 +3,17 @@ package msg
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
 )
 
+// Max hashes in an inventory message
+const MaxInvPerMsg = 50000
+
 type Inventory struct {
-	Type  uint8
+	Type  InvType
 	Count uint32
 	Data  []byte
 }
@@ -19,7 +24,13 @@ func (msg *Inventory) CMD() string {
 
 func (msg *Inventory) Serialize() ([]byte, error) {
 	buf := new(bytes.Buffer)
-	err := serialization.WriteElements(buf, msg.Type, msg.Count, msg.Data)
+	err := serialization.WriteElements(buf, msg.Type, msg.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hashes are not var bytes, the length is given by the count
+	_, err = buf.Write(msg.Data)
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +45,12 @@ func (msg *Inventory) Deserialize(body []byte) error {
 		return err
 	}
 
+	if msg.Count > MaxInvPerMsg {
+		return fmt.Errorf("inventory of %d hashes exceeds the max %d", msg.Count, MaxInvPerMsg)
+	}
+	if int(msg.Count)*UINT256SIZE > buf.Len() {
+		return serialization.ErrEof
+	}
 	msg.Data = make([]byte, msg.Count*UINT256SIZE)
 	err = binary.Read(buf, binary.LittleEndian, &msg.Data)
 	if err != nil {
@@ -0,0 +1,36 @@
+package msg
+
+import "fmt"
+
+// InvType is the type of the data announced by inv, requested by getdata
+// and missed by notfound messages
+type InvType uint8
+
+const (
+	// A transaction, returned by a tx message
+	InvTypeTx InvType = 0x01
+
+	// A block, when a bloom filter is loaded to the peer it returns a merkleblock message
+	// with the matched transactions following as tx messages
+	InvTypeBlock InvType = 0x02
+
+	// A block filtered by the loaded bloom filter, for peers requesting merkleblock explicitly
+	InvTypeFilteredBlock InvType = 0x03
+
+	// A compact block filter, reserved for peers supporting compact filters
+	InvTypeCFilter InvType = 0x04
+)
+
+var invTypeStrings = map[InvType]string{
+	InvTypeTx:            "tx",
+	InvTypeBlock:         "block",
+	InvTypeFilteredBlock: "filtered block",
+	InvTypeCFilter:       "cfilter",
+}
+
+func (t InvType) String() string {
+	if s, ok := invTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown inv type %d", t)
+}
@@ -0,0 +1,184 @@
+package msg
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core/contract/program"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+)
+
+// A message with it's wire encoding as sent by the ELA full node
+type fixture struct {
+	name    string
+	message interface {
+		Serialize() ([]byte, error)
+		Deserialize([]byte) error
+	}
+	decoded interface {
+		Deserialize([]byte) error
+	}
+	wire string
+}
+
+var fixtureHash = Uint256{0x01, 0x02}
+
+const fixtureHashHex = "0102000000000000000000000000000000000000000000000000000000000000"
+
+func fixtures() []fixture {
+	return []fixture{
+		{
+			name:    "ping",
+			message: &Ping{Height: 100},
+			decoded: new(Ping),
+			wire:    "6400000000000000",
+		},
+		{
+			name:    "pong",
+			message: &Pong{Ping{Height: 100}},
+			decoded: new(Pong),
+			wire:    "6400000000000000",
+		},
+		{
+			name:    "getdata",
+			message: &DataReq{Type: InvTypeBlock, Hash: fixtureHash},
+			decoded: new(DataReq),
+			wire:    "02" + fixtureHashHex,
+		},
+		{
+			name:    "notfound",
+			message: &NotFound{Hash: fixtureHash},
+			decoded: new(NotFound),
+			wire:    fixtureHashHex,
+		},
+		{
+			name:    "capabilities",
+			message: &Capabilities{Capabilities: CapBloomFilter | CapNotFound, FilterVersion: FilterVersion},
+			decoded: new(Capabilities),
+			wire:    "0500000000000000" + "01000000",
+		},
+		{
+			name:    "inv",
+			message: &Inventory{Type: InvTypeBlock, Count: 2, Data: append(fixtureHash[:], fixtureHash[:]...)},
+			decoded: new(Inventory),
+			wire:    "02" + "02000000" + fixtureHashHex + fixtureHashHex,
+		},
+		{
+			name:    "getblocks",
+			message: &BlocksReq{Count: 1, BlockLocator: []*Uint256{&fixtureHash}, HashStop: Uint256{}},
+			decoded: new(BlocksReq),
+			wire:    "01000000" + fixtureHashHex + hex.EncodeToString(make([]byte, UINT256SIZE)),
+		},
+	}
+}
+
+func TestMessageFixtures(t *testing.T) {
+	for _, f := range fixtures() {
+		wire, err := hex.DecodeString(f.wire)
+		if err != nil {
+			t.Fatalf("%s: invalid fixture %v", f.name, err)
+		}
+
+		body, err := f.message.Serialize()
+		if err != nil {
+			t.Errorf("%s: serialize error %v", f.name, err)
+			continue
+		}
+		if !bytes.Equal(body, wire) {
+			t.Errorf("%s: serialized %x, expect %x", f.name, body, wire)
+		}
+
+		if err := f.decoded.Deserialize(wire); err != nil {
+			t.Errorf("%s: deserialize error %v", f.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(f.decoded, f.message) {
+			t.Errorf("%s: decoded %+v, expect %+v", f.name, f.decoded, f.message)
+		}
+	}
+}
+
+func TestMessageTruncated(t *testing.T) {
+	for _, f := range fixtures() {
+		wire, _ := hex.DecodeString(f.wire)
+		if err := f.decoded.Deserialize(wire[:len(wire)-1]); err == nil {
+			t.Errorf("%s: truncated message decoded", f.name)
+		}
+	}
+}
+
+func TestMessageOversized(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		message interface {
+			Deserialize([]byte) error
+		}
+		wire string
+	}{
+		// Hashes over the max, and more hashes than the message has
+		{"inv over max", new(Inventory), "02" + "51c30000"},
+		{"inv over length", new(Inventory), "02" + "02000000" + fixtureHashHex},
+		// A transaction with more outputs than the message has
+		{"tx outputs", new(Txn), "02" + "00" + "00" + "00" + "fe00000001"},
+	} {
+		wire, _ := hex.DecodeString(c.wire)
+		if err := c.message.Deserialize(wire); err == nil {
+			t.Errorf("%s: oversized message decoded", c.name)
+		}
+	}
+}
+
+func TestTxnJSON(t *testing.T) {
+	attr := tx.NewAttribute(tx.Nonce, []byte{0x01, 0x02})
+	txn := Txn{tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    new(payload.TransferAsset),
+		Attributes: []*tx.Attribute{&attr},
+		Inputs:     []*tx.Input{{ReferTxID: fixtureHash, ReferTxOutputIndex: 1}},
+		Outputs:    []*tx.Output{{AssetID: fixtureHash, Value: 150000000, ProgramHash: Uint168{0x21, 0x01}}},
+		Programs:   []*program.Program{{Code: []byte{0x21, 0xac}, Parameter: []byte{0x40}}},
+	}}
+
+	data, err := json.Marshal(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Hashes are reversed hex strings and values are in ELA
+	reversed := BytesToHexString(fixtureHash.BytesReverse())
+	for _, expect := range []string{
+		`"txid":"` + BytesToHexString(txn.Hash().BytesReverse()) + `"`,
+		`"inputs":[{"txid":"` + reversed + `","index":1,"sequence":0}]`,
+		`"value":"1.50000000"`,
+	} {
+		if !strings.Contains(string(data), expect) {
+			t.Errorf("marshalled %s without %s", data, expect)
+		}
+	}
+
+	decoded := new(Txn)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Hash().IsEqual(txn.Hash()) {
+		t.Errorf("unmarshalled txid %s, expect %s", decoded.Hash().String(), txn.Hash().String())
+	}
+
+	op := tx.NewOutPoint(fixtureHash, 1)
+	data, err = json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"txid":"`+reversed+`","index":1}` {
+		t.Errorf("marshalled outpoint %s", data)
+	}
+	decodedOp := new(tx.OutPoint)
+	if err := json.Unmarshal(data, decodedOp); err != nil || *decodedOp != *op {
+		t.Errorf("unmarshalled outpoint %v error %v, expect %v", decodedOp, err, op)
+	}
+}
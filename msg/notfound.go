@@ -2,10 +2,12 @@ package msg
 
 import (
 	"bytes"
-	
+
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 )
 
+// NotFound is returned by the peer when the data requested by a getdata message can not be found,
+// the hash is the hash of the requested transaction or block
 type NotFound struct {
 	Hash Uint256
 }
@@ -2,8 +2,9 @@ package p2p
 
 import (
 	"fmt"
-	"time"
 	"net"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 )
 
 type Addr struct {
@@ -16,7 +17,7 @@ type Addr struct {
 
 func NewPeerAddr(services uint64, ip [16]byte, port uint16, id uint64) *Addr {
 	return &Addr{
-		Time:     time.Now().UnixNano(),
+		Time:     clock.Now().UnixNano(),
 		Services: services,
 		IP:       ip,
 		Port:     port,
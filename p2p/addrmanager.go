@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -16,17 +18,22 @@ const (
 
 type AddrManager struct {
 	sync.RWMutex
-	seeds     []string
-	cached    []string
-	connected map[string]byte
+	// Path of the cached addresses file
+	cachedFile  string
+	seeds       []string
+	cached      []string
+	connected   map[string]byte
+	reputations *Reputations
 }
 
-func newAddrManager(seeds []string) *AddrManager {
+func newAddrManager(seeds []string, dataDir string) *AddrManager {
 	am := &AddrManager{
-		seeds:     make([]string, 0),
-		cached:    make([]string, 0),
-		connected: make(map[string]byte),
+		cachedFile: filepath.Join(dataDir, CachedAddrsFile),
+		seeds:      make([]string, 0),
+		cached:     make([]string, 0),
+		connected:  make(map[string]byte),
 	}
+	am.reputations = newReputations(filepath.Join(dataDir, ReputationFile))
 
 	// Read seed list from config file
 	for _, addr := range seeds {
@@ -34,7 +41,7 @@ func newAddrManager(seeds []string) *AddrManager {
 	}
 
 	// Read cached addresses from file
-	data, err := ioutil.ReadFile(CachedAddrsFile)
+	data, err := ioutil.ReadFile(am.cachedFile)
 	if err != nil {
 		return am
 	}
@@ -49,7 +56,18 @@ func newAddrManager(seeds []string) *AddrManager {
 	return am
 }
 
+// Replace the seed addresses
+func (am *AddrManager) SetSeeds(seeds []string) {
+	am.Lock()
+	defer am.Unlock()
+
+	am.seeds = append(make([]string, 0, len(seeds)), seeds...)
+}
+
 func (am *AddrManager) GetIdleAddrs(count int) []string {
+	am.RLock()
+	defer am.RUnlock()
+
 	addrMap := make(map[string]string)
 
 	for _, seed := range am.seeds {
@@ -66,21 +84,35 @@ func (am *AddrManager) GetIdleAddrs(count int) []string {
 		addrMap[cache] = cache
 	}
 
-	totalAddrs := len(addrMap)
-	if count > totalAddrs {
-		count = totalAddrs
+	// Addresses in random order, then the better reputation first
+	addrs := make([]string, 0, len(addrMap))
+	for addr := range addrMap {
+		addrs = append(addrs, addr)
+	}
+	scores := make(map[string]float64, len(addrs))
+	for _, addr := range addrs {
+		scores[addr] = am.reputations.Score(addr)
+	}
+	sort.SliceStable(addrs, func(i, j int) bool { return scores[addrs[i]] > scores[addrs[j]] })
+
+	if count > len(addrs) {
+		count = len(addrs)
 	}
+	return addrs[:count]
+}
 
-	randAddrs := make([]string, count)
-	for addr := range addrMap {
-		count--
-		randAddrs[count] = addr
-		if count == 0 {
-			break
+// Get the addresses not connected in the given addresses
+func (am *AddrManager) FilterIdleAddrs(addrs []string) []string {
+	am.RLock()
+	defer am.RUnlock()
+
+	var idle []string
+	for _, addr := range addrs {
+		if !am.isConnected(addr) {
+			idle = append(idle, addr)
 		}
 	}
-
-	return randAddrs
+	return idle
 }
 
 func (am *AddrManager) AddAddr(addr string) {
@@ -146,7 +178,7 @@ func (am *AddrManager) saveCached() {
 		cached += "\n"
 	}
 
-	file, err := os.OpenFile(CachedAddrsFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	file, err := os.OpenFile(am.cachedFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
 	if err != nil {
 		fmt.Println("Open cached addresses failed")
 		return
@@ -3,9 +3,14 @@ package p2p
 import (
 	"bytes"
 	"encoding/binary"
+
 	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
+// Serialized size of an Addr
+const addrSize = 8 + 8 + 16 + 2 + 8
+
 type Addrs struct {
 	Count uint64
 	Addrs []Addr
@@ -39,6 +44,11 @@ func (msg *Addrs) Deserialize(body []byte) error {
 		return err
 	}
 
+	// The addresses must be in the message before they are allocated
+	if msg.Count > uint64(buf.Len()/addrSize) {
+		return errors.Wrapf(errors.ErrPeerMisbehaving, "addr message of %d addresses in %d bytes", msg.Count,
+			len(body))
+	}
 	msg.Addrs = make([]Addr, msg.Count)
 	err = binary.Read(buf, binary.LittleEndian, &msg.Addrs)
 	if err != nil {
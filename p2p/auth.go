@@ -0,0 +1,164 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	"github.com/elastos/Elastos.ELA.SPV/crypto/ecc"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	// Seconds to wait for the auth response of a pinned peer
+	AuthTimeout = 10
+
+	// Length of the random challenge sent to a pinned peer
+	AuthChallengeLength = 32
+)
+
+// Prefix of the signed auth data, so the signature can not be taken for any other data signed by the node key
+var authDomain = []byte("ELA SPV peer auth")
+
+/*
+AuthChallenge is sent to a pinned peer after the version handshake. The peer must respond
+an AuthResponse signed with the private key of the public key it's pinned to, until then
+the peer is not established and no other messages are handled.
+*/
+type AuthChallenge struct {
+	Challenge [AuthChallengeLength]byte
+}
+
+func (msg *AuthChallenge) CMD() string {
+	return "authchal"
+}
+
+func (msg *AuthChallenge) Serialize() ([]byte, error) {
+	return msg.Challenge[:], nil
+}
+
+func (msg *AuthChallenge) Deserialize(body []byte) error {
+	if len(body) != AuthChallengeLength {
+		return errors.Wrap(errors.ErrPeerMisbehaving, "invalid auth challenge length")
+	}
+	copy(msg.Challenge[:], body)
+	return nil
+}
+
+// AuthResponse is the signature of the auth data built by AuthData, in compact r || s form
+type AuthResponse struct {
+	Signature []byte
+}
+
+// Create the response to the challenge with the private key of the pinned public key, challengerID is the
+// nonce in the version message of the challenger and responderID is the nonce of the responder
+func NewAuthResponse(privateKey []byte, magic uint32, challenge *AuthChallenge, challengerID, responderID uint64) (*AuthResponse, error) {
+	signature, err := ecc.Sign(privateKey, AuthData(magic, challenge.Challenge, challengerID, responderID))
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{Signature: signature}, nil
+}
+
+func (msg *AuthResponse) CMD() string {
+	return "authresp"
+}
+
+func (msg *AuthResponse) Serialize() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := serialization.WriteVarBytes(buf, msg.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msg *AuthResponse) Deserialize(body []byte) error {
+	signature, err := serialization.ReadVarBytes(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if len(signature) != crypto.SignatureLength {
+		return errors.Wrap(errors.ErrPeerMisbehaving, "invalid auth signature length")
+	}
+	msg.Signature = signature
+	return nil
+}
+
+// Get the data signed in the auth response, it's bound to the network and both the peer IDs
+// exchanged in the version handshake, so a response can not be replayed on another connection
+func AuthData(magic uint32, challenge [AuthChallengeLength]byte, challengerID, responderID uint64) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(authDomain)
+	binary.Write(buf, binary.LittleEndian, magic)
+	buf.Write(challenge[:])
+	binary.Write(buf, binary.LittleEndian, challengerID)
+	binary.Write(buf, binary.LittleEndian, responderID)
+	return buf.Bytes()
+}
+
+// Get the public key the peer with the IP is pinned to, the port is not checked like AllowIP
+func (c *Config) PinnedKey(ip net.IP) ([]byte, bool) {
+	for host, key := range c.PinnedPeers {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if hostHasIP(host, ip) {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+// Send the auth challenge to the pinned peer finished the version handshake in the state,
+// and disconnect it if not authenticated in AuthTimeout
+func (pm *PeerManager) challengePeer(peer *Peer, state int, key []byte) error {
+	challenge := new(AuthChallenge)
+	if _, err := rand.Read(challenge.Challenge[:]); err != nil {
+		pm.rejectPeer(peer)
+		return err
+	}
+	peer.authChallenge = challenge
+	peer.authKey = key
+	if err := peer.transit(state, AUTHENTICATING); err != nil {
+		return err
+	}
+
+	clock.AfterFunc(time.Second*AuthTimeout, func() {
+		if peer.State() == AUTHENTICATING {
+			log.Error("Pinned peer auth timeout, disconnect peer ", peer.Addr().String())
+			pm.rejectPeer(peer)
+		}
+	})
+
+	go peer.Send(challenge)
+	return nil
+}
+
+// Verify the auth response of the pinned peer against the pinned public key
+func (pm *PeerManager) verifyAuthResponse(peer *Peer, resp *AuthResponse) error {
+	publicKey, err := crypto.DecodePoint(peer.authKey)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "invalid pinned public key of peer "+peer.Addr().String())
+	}
+	data := AuthData(pm.Config().Magic, peer.authChallenge.Challenge, pm.Local().ID(), peer.ID())
+	if err := ecc.Verify(publicKey, data, resp.Signature); err != nil {
+		return errors.WrapErr(errors.ErrPeerMisbehaving, err, "invalid auth signature of peer %s", peer.Addr().String())
+	}
+	return nil
+}
+
+// Close the connection of the pinned peer failed to authenticate and discard it's address,
+// the peer is not in the connected peers yet
+func (pm *PeerManager) rejectPeer(peer *Peer) {
+	addr := peer.Addr().String()
+	peer.Disconnect()
+	pm.connManager.removeAddrFromConnectingList(addr)
+	pm.OnDiscardAddr(addr)
+}
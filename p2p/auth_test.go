@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+type authTestHandler struct {
+	established chan *Peer
+}
+
+func (h *authTestHandler) MakeMessage(cmd string) (Message, error) { return nil, nil }
+func (h *authTestHandler) OnHandshake(v *Version) error            { return nil }
+func (h *authTestHandler) OnPeerEstablish(peer *Peer)              { h.established <- peer }
+func (h *authTestHandler) HandleMessage(*Peer, Message) error      { return nil }
+
+// Handshake with a pinned peer and return the challenge received on the remote end of the connection
+func pinnedPeer(t *testing.T, pm *PeerManager, id uint64) (*Peer, net.Conn, *AuthChallenge) {
+	local, remote := net.Pipe()
+	peer := &Peer{pm: pm, conn: local, ip16: [16]byte{10: 0xff, 11: 0xff, 12: 127, 15: 1}, port: 20866}
+	peer.SetID(id)
+	peer.SetState(HANDSHAKED)
+
+	if err := pm.OnVerAck(peer, new(VerAck)); err != nil {
+		t.Fatal(err)
+	}
+	if peer.State() != AUTHENTICATING {
+		t.Fatalf("pinned peer state %s, expect AUTHENTICATING", peer.PeerState.String())
+	}
+
+	buf := make([]byte, HEADERLEN+AuthChallengeLength)
+	if _, err := io.ReadFull(remote, buf); err != nil {
+		t.Fatal(err)
+	}
+	challenge := new(AuthChallenge)
+	if err := challenge.Deserialize(buf[HEADERLEN:]); err != nil {
+		t.Fatal(err)
+	}
+	return peer, remote, challenge
+}
+
+func TestPinnedPeerAuth(t *testing.T) {
+
+	privateKey, publicKey, _ := crypto.GenerateKeyPair()
+	key, _ := publicKey.EncodePoint(true)
+	otherKey, _, _ := crypto.GenerateKeyPair()
+
+	config := NewConfig(1, nil)
+	config.PinnedPeers = map[string][]byte{"127.0.0.1:20866": key}
+	localPeer := new(Peer)
+	localPeer.SetID(100)
+	pm := NewPeerManager(config, localPeer)
+	handler := &authTestHandler{established: make(chan *Peer, 1)}
+	pm.SetMessageHandler(handler)
+
+	// Signed by the pinned key
+	peer, remote, challenge := pinnedPeer(t, pm, 1)
+	defer remote.Close()
+	resp, err := NewAuthResponse(privateKey, config.Magic, challenge, localPeer.ID(), peer.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.OnAuthResponse(peer, resp); err != nil {
+		t.Fatal(err)
+	}
+	if peer.State() != ESTABLISH || <-handler.established != peer {
+		t.Fatal("authenticated peer not established")
+	}
+
+	// Signed by another key
+	peer, remote, challenge = pinnedPeer(t, pm, 2)
+	defer remote.Close()
+	resp, _ = NewAuthResponse(otherKey, config.Magic, challenge, localPeer.ID(), peer.ID())
+	if err := pm.OnAuthResponse(peer, resp); err == nil || peer.State() != INACTIVITY {
+		t.Fatal("peer signed by another key not disconnected")
+	}
+
+	// Response of another connection
+	peer, remote, challenge = pinnedPeer(t, pm, 3)
+	defer remote.Close()
+	resp, _ = NewAuthResponse(privateKey, config.Magic, challenge, localPeer.ID(), 4)
+	if err := pm.OnAuthResponse(peer, resp); err == nil || peer.State() != INACTIVITY {
+		t.Fatal("replayed auth response accepted")
+	}
+}
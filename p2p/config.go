@@ -0,0 +1,46 @@
+package p2p
+
+// Config is the parameters of a peer to peer network, each PeerManager
+// holds it's own config so multiple networks can run in one process
+type Config struct {
+	// Magic number to identify the peer to peer network
+	Magic uint32
+
+	// Seed peer addresses to start connections
+	SeedList []string
+
+	// Keep connecting peers until connected peers reach this count
+	MinConnCount int
+
+	// Max peer addresses to connect or share at one time
+	MaxOutboundCount int
+
+	// Connect only to these peer addresses if not empty, seeds and
+	// addresses shared by other peers are ignored
+	TrustedPeers []string
+
+	// Peers in these subnets, like "10.0.0.0/8", or IP addresses are never connected
+	BannedSubnets []string
+
+	// Never accept inbound connections, so the peers can not connect back to learn the address
+	OutboundOnly bool
+
+	// Peers on these hosts must prove they own the private key of the public key they're pinned to,
+	// the key is the peer address "host:port" or host and the value is the encoded public key
+	PinnedPeers map[string][]byte
+
+	// Directory of the cached peer addresses and the peer reputations files,
+	// empty means the work directory
+	DataDir string
+}
+
+// Create a config of the network with the given magic and seeds,
+// other parameters are set to the default values
+func NewConfig(magic uint32, seeds []string) *Config {
+	return &Config{
+		Magic:            magic,
+		SeedList:         seeds,
+		MinConnCount:     MinConnCount,
+		MaxOutboundCount: MaxOutboundCount,
+	}
+}
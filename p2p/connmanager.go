@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 )
 
@@ -16,6 +17,7 @@ const (
 
 type ConnManager struct {
 	sync.Mutex
+	pm *PeerManager
 
 	connList  []string
 	retryList map[string]int
@@ -23,8 +25,9 @@ type ConnManager struct {
 	OnDiscardAddr func(add string)
 }
 
-func newConnManager(onDiscardAddr func(add string)) *ConnManager {
+func newConnManager(pm *PeerManager, onDiscardAddr func(add string)) *ConnManager {
 	cm := new(ConnManager)
+	cm.pm = pm
 	cm.retryList = make(map[string]int)
 	cm.OnDiscardAddr = onDiscardAddr
 	return cm
@@ -63,6 +66,12 @@ func (cm *ConnManager) removeAddrFromConnectingList(addr string) {
 }
 
 func (cm *ConnManager) connectPeer(addr string) {
+	// Stop connecting and retrying when the peer manager paused
+	if cm.pm.IsPaused() {
+		cm.removeAddrFromConnectingList(addr)
+		return
+	}
+
 	conn, err := net.DialTimeout("tcp", addr, time.Second*ConnTimeOut)
 	if err != nil {
 		log.Error("Connect to addr ", addr, " failed, err", err)
@@ -71,12 +80,13 @@ func (cm *ConnManager) connectPeer(addr string) {
 	}
 
 	// Start read msg from remote peer
-	remote := NewPeer(conn)
+	remote := cm.pm.NewPeer(conn)
 	remote.SetState(HAND)
-	go remote.Read()
+	remote.startRead()
+	cm.pm.handshakeDeadline(remote)
 
 	// Send version message to remote peer
-	go remote.Send(pm.local.NewVersionMsg())
+	go remote.Send(cm.pm.local.NewVersionMsg())
 }
 
 func (cm *ConnManager) retry(addr string) {
@@ -99,6 +109,6 @@ func (cm *ConnManager) retry(addr string) {
 	cm.Unlock()
 
 	log.Info("Wait for retry ", addr)
-	time.Sleep(time.Second * RetryDuration)
+	clock.Sleep(time.Second * RetryDuration)
 	cm.connectPeer(addr)
 }
@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+)
+
+const (
+	// Seconds a peer has to finish the version handshake after connected
+	HandshakeTimeout = 10
+
+	// Max length of a message before the peer established, the handshake messages are much smaller
+	MaxHandshakeMsgLen = 1024
+)
+
+var handshakeFailures = metrics.NewCounter("spv_p2p_handshake_failures_total",
+	"Peers disconnected for failing the handshake, not finished in time or messages out of the handshake")
+
+/*
+The handshake state machine. An outbound peer starts in HAND with the version message sent, an inbound
+peer starts in INIT, they are established after both the version and verack messages are exchanged,
+and a pinned peer must be authenticated before established. Any state can go to INACTIVITY when the
+peer is disconnected, INACTIVITY is final.
+
+	INIT  --version-->  HANDSHAKE  --verack-->  ESTABLISH / AUTHENTICATING
+	HAND  --version-->  HANDSHAKED --verack-->  ESTABLISH / AUTHENTICATING
+	AUTHENTICATING  --authresp-->  ESTABLISH
+*/
+var peerTransitions = map[int][]int{
+	INIT:           {HANDSHAKE},
+	HAND:           {HANDSHAKED},
+	HANDSHAKE:      {AUTHENTICATING, ESTABLISH},
+	HANDSHAKED:     {AUTHENTICATING, ESTABLISH},
+	AUTHENTICATING: {ESTABLISH},
+}
+
+// Messages accepted from the peer in the states before established, others are rejected
+var handshakeMessages = map[int]string{
+	INIT:           "version",
+	HAND:           "version",
+	HANDSHAKE:      "verack",
+	HANDSHAKED:     "verack",
+	AUTHENTICATING: "authresp",
+}
+
+// Move the state to the next one if the transition is allowed by the state machine,
+// it's checked and set atomically so two messages can not move the same state
+func (ps *PeerState) transit(from, to int) error {
+	ps.Lock()
+	defer ps.Unlock()
+
+	if ps.state != from {
+		return errors.Wrapf(errors.ErrPeerMisbehaving, "peer state %s, expect %s", stateString(ps.state),
+			stateString(from))
+	}
+	for _, next := range peerTransitions[from] {
+		if next == to {
+			ps.state = to
+			return nil
+		}
+	}
+	return errors.Wrapf(errors.ErrPeerMisbehaving, "peer state can not go from %s to %s",
+		stateString(from), stateString(to))
+}
+
+// Check if the message can be handled in the current state of the peer. Before the peer is established
+// only the next handshake message is accepted, other messages are misbehaving except the ones sent by
+// a pinned peer considering itself established, they are ignored while it's authenticating.
+func acceptMessage(peer *Peer, cmd string) error {
+	state := peer.State()
+	switch {
+	case state == ESTABLISH || handshakeMessages[state] == cmd:
+		return nil
+	case state == INACTIVITY:
+		return errors.New("peer disconnected")
+	case state == AUTHENTICATING:
+		return errors.New("peer not authenticated")
+	}
+	return errors.Wrapf(errors.ErrPeerMisbehaving, "message %s received in handshake state %s",
+		cmd, stateString(state))
+}
+
+// Disconnect the peer if it has not finished the version handshake in HandshakeTimeout,
+// a pinned peer authenticating has it's own AuthTimeout
+func (pm *PeerManager) handshakeDeadline(peer *Peer) {
+	clock.AfterFunc(time.Second*HandshakeTimeout, func() {
+		switch peer.State() {
+		case INIT, HAND, HANDSHAKE, HANDSHAKED:
+			log.Error("Peer handshake timeout, disconnect peer ", peer.Addr().String())
+			pm.failHandshake(peer)
+		}
+	})
+}
+
+// Close the connection of a peer failed the handshake, the peer is not in the connected peers yet
+func (pm *PeerManager) failHandshake(peer *Peer) {
+	handshakeFailures.Inc()
+	addr := peer.Addr().String()
+	peer.Disconnect()
+	pm.connManager.removeAddrFromConnectingList(addr)
+}
@@ -0,0 +1,204 @@
+package p2p
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+func TestMain(m *testing.M) {
+	log.Init()
+	os.Exit(m.Run())
+}
+
+func TestPeerTransitions(t *testing.T) {
+	for _, c := range []struct {
+		from, to int
+		allowed  bool
+	}{
+		{INIT, HANDSHAKE, true},
+		{HAND, HANDSHAKED, true},
+		{HANDSHAKE, ESTABLISH, true},
+		{HANDSHAKED, AUTHENTICATING, true},
+		{AUTHENTICATING, ESTABLISH, true},
+		{INIT, ESTABLISH, false},
+		{HAND, HANDSHAKE, false},
+		{HANDSHAKED, HANDSHAKE, false},
+		{ESTABLISH, HANDSHAKED, false},
+		{INACTIVITY, ESTABLISH, false},
+	} {
+		var ps PeerState
+		ps.SetState(c.from)
+		err := ps.transit(c.from, c.to)
+		if (err == nil) != c.allowed {
+			t.Errorf("transit from %s to %s error %v, expect allowed %v", stateString(c.from),
+				stateString(c.to), err, c.allowed)
+		}
+	}
+
+	// The state changed in the meantime is not moved
+	var ps PeerState
+	ps.SetState(INACTIVITY)
+	if ps.transit(HANDSHAKED, ESTABLISH) == nil || ps.State() != INACTIVITY {
+		t.Error("disconnected peer established")
+	}
+}
+
+// Create a peer manager not saving the reputations in the working directory
+func handshakePeerManager(t *testing.T, dir string) (*PeerManager, *authTestHandler) {
+	localPeer := new(Peer)
+	localPeer.SetID(100)
+	pm := NewPeerManager(NewConfig(1, nil), localPeer)
+	pm.addrManager.reputations = newReputations(filepath.Join(dir, ReputationFile))
+	handler := &authTestHandler{established: make(chan *Peer, 1)}
+	pm.SetMessageHandler(handler)
+	return pm, handler
+}
+
+// Peers of a handshake test, the remote ends of the connections are drained until closed
+type handshakePeers struct {
+	t          *testing.T
+	pm         *PeerManager
+	goroutines int
+	conns      []net.Conn
+	drains     sync.WaitGroup
+}
+
+func newHandshakePeers(t *testing.T, pm *PeerManager) *handshakePeers {
+	return &handshakePeers{t: t, pm: pm, goroutines: runtime.NumGoroutine()}
+}
+
+// Create a peer in the state with the remote end of the connection drained
+func (hp *handshakePeers) peer(state int) *Peer {
+	local, remote := net.Pipe()
+	hp.conns = append(hp.conns, local)
+	hp.drains.Add(1)
+	go func() {
+		defer hp.drains.Done()
+		io.Copy(ioutil.Discard, remote)
+	}()
+	peer := &Peer{pm: hp.pm, conn: local, ip16: [16]byte{10: 0xff, 11: 0xff, 12: 127, 15: 1}, port: 20866}
+	peer.SetState(state)
+	return peer
+}
+
+// Close the connections, and wait for the drains and the messages sending to the peers to finish
+func (hp *handshakePeers) close() {
+	for _, conn := range hp.conns {
+		conn.Close()
+	}
+	hp.drains.Wait()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for runtime.NumGoroutine() > hp.goroutines {
+		if time.Now().After(deadline) {
+			hp.t.Errorf("%d goroutines of the test not finished", runtime.NumGoroutine()-hp.goroutines)
+			return
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func buildMessage(t *testing.T, msgs ...Message) []byte {
+	var buf []byte
+	for _, msg := range msgs {
+		data, err := BuildMessageWithMagic(1, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = append(buf, data...)
+	}
+	return buf
+}
+
+func TestHandshake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "handshake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pm, handler := handshakePeerManager(t, dir)
+	peers := newHandshakePeers(t, pm)
+	defer peers.close()
+
+	// Version and verack received together are handled in order
+	peer := peers.peer(HAND)
+	peer.unpackMessage(buildMessage(t, &Version{Version: 1, Nonce: 1}, new(VerAck)))
+	if peer.State() != ESTABLISH || <-handler.established != peer {
+		t.Fatalf("peer state %s, expect ESTABLISH", stateString(peer.State()))
+	}
+
+	// Messages other than the next handshake message are rejected
+	for _, c := range []struct {
+		state int
+		msgs  []Message
+	}{
+		{INIT, []Message{new(AddrsReq)}},
+		{INIT, []Message{new(VerAck)}},
+		{HAND, []Message{new(AddrsReq)}},
+		{HANDSHAKE, []Message{&Version{Version: 1, Nonce: 2}}},
+		{HAND, []Message{&Version{Version: 1, Nonce: 3}, &Version{Version: 1, Nonce: 3}}},
+	} {
+		peer := peers.peer(c.state)
+		peer.unpackMessage(buildMessage(t, c.msgs...))
+		if peer.State() != INACTIVITY {
+			t.Errorf("peer in state %s not disconnected for %s", stateString(c.state), c.msgs[len(c.msgs)-1].CMD())
+		}
+	}
+
+	// A pinned peer considering itself established is not disconnected while authenticating
+	peer = peers.peer(AUTHENTICATING)
+	peer.unpackMessage(buildMessage(t, new(AddrsReq)))
+	if peer.State() != AUTHENTICATING {
+		t.Errorf("authenticating peer state %s", stateString(peer.State()))
+	}
+
+	// Large messages are not buffered before established
+	peer = peers.peer(HAND)
+	header, _ := NewHeaderWithMagic(1, "version", make([]byte, CHECKSUMLEN), MaxHandshakeMsgLen+1).Serialize()
+	peer.unpackMessage(header)
+	if peer.State() != INACTIVITY {
+		t.Error("peer sent a large message in handshake not disconnected")
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	mock := clock.NewMock(time.Unix(1514764800, 0))
+	clock.Set(mock)
+	defer clock.Set(clock.System)
+
+	dir, err := ioutil.TempDir("", "handshake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pm, _ := handshakePeerManager(t, dir)
+	peers := newHandshakePeers(t, pm)
+	defer peers.close()
+
+	slow := peers.peer(HANDSHAKED)
+	pm.handshakeDeadline(slow)
+	established := peers.peer(ESTABLISH)
+	pm.handshakeDeadline(established)
+
+	mock.Add(time.Second * (HandshakeTimeout - 1))
+	if slow.State() != HANDSHAKED {
+		t.Fatal("peer disconnected before handshake timeout")
+	}
+	mock.Add(time.Second)
+	if slow.State() != INACTIVITY {
+		t.Error("peer not finished handshake in time not disconnected")
+	}
+	if established.State() != ESTABLISH {
+		t.Error("established peer disconnected by handshake timeout")
+	}
+}
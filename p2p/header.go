@@ -4,10 +4,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"errors"
 	"fmt"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 )
 
@@ -18,6 +18,8 @@ const (
 	HEADERLEN   = 24
 )
 
+// Magic number used by InitPeerManager(), it's a compatible setting for the
+// old callers, create PeerManager with NewPeerManager() and Config instead
 var Magic uint32
 
 type Header struct {
@@ -27,10 +29,15 @@ type Header struct {
 	Checksum [CHECKSUMLEN]byte
 }
 
+// Create a header with the magic number set in p2p.Magic, use NewHeaderWithMagic() instead
 func NewHeader(cmd string, checksum []byte, length int) *Header {
+	return NewHeaderWithMagic(Magic, cmd, checksum, length)
+}
+
+func NewHeaderWithMagic(magic uint32, cmd string, checksum []byte, length int) *Header {
 	header := new(Header)
 	// Write Magic
-	header.Magic = Magic
+	header.Magic = magic
 	// Write CMD
 	copy(header.CMD[:len(cmd)], cmd)
 	// Write length
@@ -41,18 +48,28 @@ func NewHeader(cmd string, checksum []byte, length int) *Header {
 	return header
 }
 
+// Build a header with the magic number set in p2p.Magic, use BuildHeaderWithMagic() instead
 func BuildHeader(cmd string, body []byte) *Header {
+	return BuildHeaderWithMagic(Magic, cmd, body)
+}
+
+func BuildHeaderWithMagic(magic uint32, cmd string, body []byte) *Header {
 	// Calculate checksum
 	checksum := Sha256D(body)
-	return NewHeader(cmd, checksum[:], len(body))
+	return NewHeaderWithMagic(magic, cmd, checksum[:], len(body))
 }
 
+// Build a message with the magic number set in p2p.Magic, use BuildMessageWithMagic() instead
 func BuildMessage(msg Message) ([]byte, error) {
+	return BuildMessageWithMagic(Magic, msg)
+}
+
+func BuildMessageWithMagic(magic uint32, msg Message) ([]byte, error) {
 	body, err := msg.Serialize()
 	if err != nil {
 		return nil, err
 	}
-	hdr, err := BuildHeader(msg.CMD(), body).Serialize()
+	hdr, err := BuildHeaderWithMagic(magic, msg.CMD(), body).Serialize()
 	if err != nil {
 		return nil, err
 	}
@@ -62,19 +79,24 @@ func BuildMessage(msg Message) ([]byte, error) {
 	return append(hdr, body...), nil
 }
 
+// Verify the header with the magic number set in p2p.Magic, use VerifyMagic() instead
 func (header *Header) Verify(buf []byte) error {
+	return header.VerifyMagic(Magic, buf)
+}
+
+// Verify the header is of the network of the magic number and the checksum of the message body
+func (header *Header) VerifyMagic(magic uint32, buf []byte) error {
 	// Verify magic
-	if header.Magic != Magic {
-		return errors.New(fmt.Sprint("Unmatched magic number ", header.Magic))
+	if header.Magic != magic {
+		return errors.Wrap(errors.ErrPeerMisbehaving, fmt.Sprint("Unmatched magic number ", header.Magic))
 	}
 
 	sum := Sha256D(buf)
 	checksum := sum[:CHECKSUMLEN]
 	if !bytes.Equal(header.Checksum[:], checksum) {
-		return errors.New(
-			fmt.Sprintf("Unmatched checksum, expecting %s get $s",
-				hex.EncodeToString(checksum),
-				hex.EncodeToString(header.Checksum[:])))
+		return errors.Wrapf(errors.ErrPeerMisbehaving, "Unmatched checksum, expecting %s get %s",
+			hex.EncodeToString(checksum),
+			hex.EncodeToString(header.Checksum[:]))
 	}
 
 	return nil
@@ -95,7 +117,7 @@ func (header *Header) Deserialize(buf []byte) error {
 	cmd := buf[CMDOFFSET:CMDOFFSET+CMDLEN]
 	end := bytes.IndexByte(cmd, 0)
 	if end < 0 || end >= CMDLEN {
-		return errors.New("Unexpected length of CMD")
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Unexpected length of CMD")
 	}
 
 	hdr := bytes.NewReader(buf[:HEADERLEN])
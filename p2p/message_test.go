@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+)
+
+func TestVersionFixture(t *testing.T) {
+	version := &Version{
+		Version:   1,
+		Services:  4,
+		TimeStamp: 1514764800,
+		Port:      20866,
+		Nonce:     7,
+		Height:    100,
+		Relay:     1,
+	}
+	wire, _ := hex.DecodeString("01000000" + "0400000000000000" + "007a495a" + "8251" +
+		"0700000000000000" + "6400000000000000" + "01")
+
+	body, err := version.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(body, wire) {
+		t.Errorf("serialized %x, expect %x", body, wire)
+	}
+
+	decoded := new(Version)
+	if err := decoded.Deserialize(wire); err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *version {
+		t.Errorf("decoded %+v, expect %+v", decoded, version)
+	}
+	if err := decoded.Deserialize(wire[:len(wire)-1]); err == nil {
+		t.Error("truncated version decoded")
+	}
+}
+
+func TestMessageHeaderFixture(t *testing.T) {
+	const magic = 7630401 // the main net
+	// The checksum of an empty body is the first 4 bytes of sha256d("")
+	wire, _ := hex.DecodeString("416e7400" + "76657261636b000000000000" + "00000000" + "5df6e0e2")
+
+	buf, err := BuildMessageWithMagic(magic, new(VerAck))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, wire) {
+		t.Errorf("built %x, expect %x", buf, wire)
+	}
+
+	header, err := verifyHeader(magic, wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.GetCMD() != "verack" || header.Length != 0 {
+		t.Errorf("unexpected header %+v", header)
+	}
+	if _, err := verifyHeader(magic+1, wire); err == nil {
+		t.Error("header of another network verified")
+	}
+
+	// The old callers build and verify with the magic number set in p2p.Magic
+	Magic = magic
+	defer func() { Magic = 0 }()
+	buf, err = BuildMessage(new(VerAck))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, wire) {
+		t.Errorf("built %x by the default magic, expect %x", buf, wire)
+	}
+	if err := header.Verify(wire[HEADERLEN:]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewPeerWithoutManager(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+	if peer := NewPeer(local); peer != nil {
+		t.Error("peer created without InitPeerManager()")
+	}
+	if _, err := local.Write([]byte{0}); err == nil {
+		t.Error("connection not closed")
+	}
+}
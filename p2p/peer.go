@@ -7,13 +7,26 @@ import (
 	"strings"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
 )
 
 const (
 	MaxBufLen = 1024 * 16
+
+	// Max length of a message, a larger one is not buffered and the peer is disconnected
+	MaxMsgLen = 32 * 1024 * 1024
+)
+
+var (
+	bytesReceived = metrics.NewCounter("spv_p2p_received_bytes_total", "Total bytes received from peers")
+	bytesSent     = metrics.NewCounter("spv_p2p_sent_bytes_total", "Total bytes sent to peers")
 )
 
 // Peer states
@@ -22,6 +35,7 @@ const (
 	HAND
 	HANDSHAKE
 	HANDSHAKED
+	AUTHENTICATING
 	ESTABLISH
 	INACTIVITY
 )
@@ -46,7 +60,11 @@ func (ps *PeerState) State() int {
 }
 
 func (ps *PeerState) String() string {
-	switch ps.state {
+	return stateString(ps.state)
+}
+
+func stateString(state int) string {
+	switch state {
 	case INIT:
 		return "INIT"
 	case HAND:
@@ -55,6 +73,8 @@ func (ps *PeerState) String() string {
 		return "HANDSHAKE"
 	case HANDSHAKED:
 		return "HANDSHAKED"
+	case AUTHENTICATING:
+		return "AUTHENTICATING"
 	case ESTABLISH:
 		return "ESTABLISH"
 	case INACTIVITY:
@@ -73,11 +93,27 @@ type Peer struct {
 	lastActive time.Time
 	height     uint64
 	relay      uint8 // 1 for true 0 for false
+	connTime   time.Time
+
+	// traffic statistics, accessed atomically
+	bytesReceived uint64
+	bytesSent     uint64
+
+	// moving average of the request latency in nanoseconds, accessed atomically
+	latency int64
+
+	// capability flags advertised by the peer after the handshake, accessed atomically
+	capabilities uint64
 
 	PeerState
+	pm   *PeerManager
 	conn net.Conn
 
 	msgBuf MsgBuf
+
+	// auth of a pinned peer
+	authChallenge *AuthChallenge
+	authKey       []byte
 }
 
 func (peer *Peer) String() string {
@@ -94,6 +130,47 @@ func (peer *Peer) String() string {
 		"\n}"
 }
 
+// PeerStats is a snapshot of the peer info and traffic statistics
+type PeerStats struct {
+	ID            uint64    `json:"id"`
+	Addr          string    `json:"addr"`
+	Version       uint32    `json:"version"`
+	Services      uint64    `json:"services"`
+	Height        uint64    `json:"height"`
+	Relay         bool      `json:"relay"`
+	State         string    `json:"state"`
+	ConnTime      time.Time `json:"conntime"`
+	LastActive    time.Time `json:"lastactive"`
+	BytesReceived uint64    `json:"bytesreceived"`
+	BytesSent     uint64    `json:"bytessent"`
+	Latency       int64     `json:"latency"`
+	Capabilities  uint64    `json:"capabilities"`
+	SyncPeer      bool      `json:"syncpeer"`
+}
+
+// Get the snapshot of peer info and traffic statistics
+func (peer *Peer) Stats() *PeerStats {
+	peer.PeerState.RLock()
+	state := peer.PeerState.String()
+	peer.PeerState.RUnlock()
+
+	return &PeerStats{
+		ID:            peer.id,
+		Addr:          net.JoinHostPort(net.IP(peer.ip16[:]).String(), fmt.Sprint(peer.port)),
+		Version:       peer.version,
+		Services:      peer.services,
+		Height:        peer.height,
+		Relay:         peer.relay == 1,
+		State:         state,
+		ConnTime:      peer.connTime,
+		LastActive:    peer.lastActive,
+		BytesReceived: atomic.LoadUint64(&peer.bytesReceived),
+		BytesSent:     atomic.LoadUint64(&peer.bytesSent),
+		Latency:       int64(peer.Latency() / time.Millisecond),
+		Capabilities:  peer.Capabilities(),
+	}
+}
+
 type MsgBuf struct {
 	buf []byte
 	len int
@@ -112,13 +189,15 @@ func (buf *MsgBuf) Reset() {
 	buf.len = 0
 }
 
+// Create a peer belongs to the peer manager created by InitPeerManager(), use PeerManager.NewPeer()
+// instead. The connection is closed and nil returned if InitPeerManager() was not called
 func NewPeer(conn net.Conn) *Peer {
-	ip16, port := addrFromConn(conn)
-	return &Peer{
-		conn: conn,
-		ip16: ip16,
-		port: port,
+	if pm == nil {
+		log.Error("NewPeer called without InitPeerManager(), use PeerManager.NewPeer() instead")
+		conn.Close()
+		return nil
 	}
+	return pm.NewPeer(conn)
 }
 
 func addrFromConn(conn net.Conn) ([16]byte, uint16) {
@@ -194,7 +273,7 @@ func (peer *Peer) SetInfo(msg *Version) {
 	peer.id = msg.Nonce
 	peer.version = msg.Version
 	peer.services = msg.Services
-	peer.lastActive = time.Now()
+	peer.lastActive = clock.Now()
 	peer.height = msg.Height
 	peer.relay = msg.Relay
 }
@@ -207,6 +286,40 @@ func (peer *Peer) Height() uint64 {
 	return peer.height
 }
 
+// Update the moving average of the request latency with a request answered by the peer
+func (peer *Peer) UpdateLatency(latency time.Duration) {
+	average := atomic.LoadInt64(&peer.latency)
+	if average == 0 {
+		average = int64(latency)
+	} else {
+		average += (int64(latency) - average) / 8
+	}
+	atomic.StoreInt64(&peer.latency, average)
+}
+
+// Get the moving average of the request latency, 0 if no request answered by the peer yet
+func (peer *Peer) Latency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&peer.latency))
+}
+
+// Set the capability flags of the peer, the flags are defined by the protocol on top of the p2p network
+func (peer *Peer) SetCapabilities(capabilities uint64) {
+	atomic.StoreUint64(&peer.capabilities, capabilities)
+}
+
+// Get the capability flags of the peer
+func (peer *Peer) Capabilities() uint64 {
+	return atomic.LoadUint64(&peer.capabilities)
+}
+
+// Start reading messages from the peer in a supervised goroutine,
+// the peer is disconnected if the reader panicked
+func (peer *Peer) startRead() {
+	supervisor.GoOnce("peer reader "+peer.Addr().String(), peer.Read, func() {
+		peer.pm.DisconnectPeer(peer)
+	})
+}
+
 func (peer *Peer) Read() {
 	buf := make([]byte, MaxBufLen)
 	for {
@@ -214,7 +327,9 @@ func (peer *Peer) Read() {
 		buf[MaxBufLen-1] = 0 //Prevent overflow
 		switch err {
 		case nil:
-			peer.lastActive = time.Now()
+			bytesReceived.Add(uint64(len))
+			atomic.AddUint64(&peer.bytesReceived, uint64(len))
+			peer.lastActive = clock.Now()
 			peer.unpackMessage(buf[:len])
 		case io.EOF:
 			log.Error("Read peer io.EOF:", err, ", peer id is: ", peer.ID())
@@ -227,7 +342,7 @@ func (peer *Peer) Read() {
 
 DISCONNECT:
 	log.Trace("Peer IO error, disconnect peer,", peer)
-	pm.DisconnectPeer(peer)
+	peer.pm.DisconnectPeer(peer)
 }
 
 func (peer *Peer) unpackMessage(buf []byte) {
@@ -253,12 +368,24 @@ func (peer *Peer) unpackMessage(buf []byte) {
 			return
 		}
 
-		if header.Magic != Magic {
+		if header.Magic != peer.pm.Config().Magic {
 			log.Error("Magic not match, disconnect peer")
 			peer.Disconnect()
 			return
 		}
 
+		// Messages before the peer established are small, don't buffer a large one
+		if peer.State() != ESTABLISH && header.Length > MaxHandshakeMsgLen {
+			log.Error("Message too large before handshake, disconnect peer")
+			peer.pm.failHandshake(peer)
+			return
+		}
+		if header.Length > MaxMsgLen {
+			log.Error("Message too large, disconnect peer")
+			peer.Disconnect()
+			return
+		}
+
 		peer.msgBuf.len = int(header.Length)
 		buf = buf[index:]
 	}
@@ -268,7 +395,7 @@ func (peer *Peer) unpackMessage(buf []byte) {
 	if len(buf) == msgLen { // Just read the full message
 
 		peer.msgBuf.Append(buf[:])
-		go peer.decodeMessage(peer.msgBuf.Buf())
+		peer.dispatchMessage(peer.msgBuf.Buf())
 		peer.msgBuf.Reset()
 
 	} else if len(buf) < msgLen { // Read part of the message
@@ -279,25 +406,52 @@ func (peer *Peer) unpackMessage(buf []byte) {
 	} else { // Read more than the message
 
 		peer.msgBuf.Append(buf[0:msgLen])
-		go peer.decodeMessage(peer.msgBuf.Buf())
+		peer.dispatchMessage(peer.msgBuf.Buf())
 		peer.msgBuf.Reset()
 		peer.unpackMessage(buf[msgLen:])
 	}
 }
 
+// Decode and handle the message in a new goroutine, the messages before the peer established are handled
+// in order instead, so the handshake messages received together are not reordered
+func (peer *Peer) dispatchMessage(buf []byte) {
+	if peer.pm.recorder != nil {
+		peer.pm.recorder.record(peer, buf)
+	}
+	if peer.State() == ESTABLISH {
+		go peer.decodeMessage(buf)
+		return
+	}
+	peer.decodeMessage(buf)
+}
+
 func (peer *Peer) decodeMessage(buf []byte) {
+	// The message is made and handled by the message handler, don't let a panic
+	// from it take down the process
+	defer peer.pm.handlerGuard.Recover()
+
 	if len(buf) < HEADERLEN {
 		log.Error("Message length is not enough")
 		return
 	}
 
-	hdr, err := verifyHeader(buf)
+	hdr, err := verifyHeader(peer.pm.Config().Magic, buf)
 	if err != nil {
 		log.Error("Verify message header error: ", err)
 		return
 	}
 
-	msg, err := pm.makeMessage(hdr.GetCMD())
+	// Only the next handshake message is handled before the peer established
+	if err := acceptMessage(peer, hdr.GetCMD()); err != nil {
+		log.Warn("Reject message ", hdr.GetCMD(), " from peer ", peer.Addr().String(), ", ", err)
+		if errors.Is(err, errors.ErrPeerMisbehaving) {
+			peer.pm.addrManager.reputations.OnInvalid(peer.Addr().String())
+			peer.pm.failHandshake(peer)
+		}
+		return
+	}
+
+	msg, err := peer.pm.makeMessage(hdr.GetCMD())
 	if err != nil {
 		log.Error("Make message error, ", err)
 		return
@@ -309,13 +463,13 @@ func (peer *Peer) decodeMessage(buf []byte) {
 		return
 	}
 
-	pm.handleMessage(peer, msg)
+	peer.pm.handleMessage(peer, msg)
 }
 
-func verifyHeader(buf []byte) (*Header, error) {
+func verifyHeader(magic uint32, buf []byte) (*Header, error) {
 	hdr := new(Header)
 	err := hdr.Deserialize(buf)
-	if err = hdr.Verify(buf[HEADERLEN:]); err != nil {
+	if err = hdr.VerifyMagic(magic, buf[HEADERLEN:]); err != nil {
 		return nil, err
 	}
 	return hdr, nil
@@ -326,16 +480,18 @@ func (peer *Peer) Send(msg Message) {
 		return
 	}
 
-	buf, err := BuildMessage(msg)
+	buf, err := BuildMessageWithMagic(peer.pm.Config().Magic, msg)
 	if err != nil {
 		log.Error("Serialize message failed, ", err)
 		return
 	}
 
-	_, err = peer.conn.Write(buf)
+	n, err := peer.conn.Write(buf)
+	bytesSent.Add(uint64(n))
+	atomic.AddUint64(&peer.bytesSent, uint64(n))
 	if err != nil {
 		log.Error("Error sending message to peer ", err)
-		pm.DisconnectPeer(peer)
+		peer.pm.DisconnectPeer(peer)
 	}
 }
 
@@ -343,7 +499,7 @@ func (peer *Peer) NewVersionMsg() *Version {
 	version := new(Version)
 	version.Version = peer.Version()
 	version.Services = peer.Services()
-	version.TimeStamp = uint32(time.Now().UnixNano())
+	version.TimeStamp = uint32(clock.Now().Unix())
 	version.Port = peer.Port()
 	version.Nonce = peer.ID()
 	version.Height = peer.Height()
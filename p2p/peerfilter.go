@@ -0,0 +1,113 @@
+package p2p
+
+import (
+	"net"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+// Check if the peer address host:port is allowed to connect by the trusted peers and banned subnets
+func (c *Config) AllowAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if len(c.TrustedPeers) > 0 && !c.isTrustedAddr(addr, host) {
+		return false
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return !c.isBanned(ip)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if c.isBanned(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if the peer with the IP is allowed to connect, the port is not checked
+// for the port of an inbound connection is not the listening port of the peer
+func (c *Config) AllowIP(ip net.IP) bool {
+	if c.isBanned(ip) {
+		return false
+	}
+	if len(c.TrustedPeers) == 0 {
+		return true
+	}
+	for _, trusted := range c.TrustedPeers {
+		host, _, err := net.SplitHostPort(trusted)
+		if err != nil {
+			host = trusted
+		}
+		if hostHasIP(host, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) isTrustedAddr(addr, host string) bool {
+	for _, trusted := range c.TrustedPeers {
+		if trusted == addr {
+			return true
+		}
+		trustedHost, trustedPort, err := net.SplitHostPort(trusted)
+		if err != nil {
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil || port != trustedPort {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && hostHasIP(trustedHost, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) isBanned(ip net.IP) bool {
+	for _, subnet := range c.BannedSubnets {
+		subnet = strings.TrimSpace(subnet)
+		if !strings.Contains(subnet, "/") {
+			if banned := net.ParseIP(subnet); banned != nil && banned.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(subnet)
+		if err != nil {
+			log.Warn("Invalid banned subnet ", subnet)
+			continue
+		}
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the host is the IP or resolves to it
+func hostHasIP(host string, ip net.IP) bool {
+	if hostIP := net.ParseIP(host); hostIP != nil {
+		return hostIP.Equal(ip)
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+	for _, hostIP := range ips {
+		if hostIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
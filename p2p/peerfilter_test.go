@@ -0,0 +1,38 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+)
+
+func TestConfigAllowPeers(t *testing.T) {
+	config := NewConfig(1, nil)
+	config.BannedSubnets = []string{"10.0.0.0/8", "192.168.1.7"}
+
+	for addr, allowed := range map[string]bool{
+		"10.1.2.3:20866":    false,
+		"192.168.1.7:20866": false,
+		"192.168.1.8:20866": true,
+	} {
+		if config.AllowAddr(addr) != allowed {
+			t.Errorf("address %s allowed %v, expect %v", addr, !allowed, allowed)
+		}
+	}
+
+	config.TrustedPeers = []string{"127.0.0.1:20866", "10.0.0.1:20866"}
+	for addr, allowed := range map[string]bool{
+		"127.0.0.1:20866": true,
+		"127.0.0.1:20867": false,
+		"127.0.0.2:20866": false,
+		// Banned subnets take precedence over the trusted peers
+		"10.0.0.1:20866": false,
+	} {
+		if config.AllowAddr(addr) != allowed {
+			t.Errorf("address %s allowed %v with trusted peers, expect %v", addr, !allowed, allowed)
+		}
+	}
+
+	if !config.AllowIP(net.ParseIP("127.0.0.1")) || config.AllowIP(net.ParseIP("127.0.0.2")) {
+		t.Error("inbound connection not filtered by trusted peers")
+	}
+}
@@ -1,11 +1,17 @@
 package p2p
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
 
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/guard"
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
 	"time"
 )
 
@@ -16,39 +22,144 @@ const (
 	MaxOutboundCount   = 6
 )
 
+// The peer manager created by InitPeerManager()
 var pm *PeerManager
 
 type PeerManager struct {
 	*Peers
+	paused      int32 // accessed atomically
+	configLock  sync.RWMutex
+	config      *Config
 	addrManager *AddrManager
 	connManager *ConnManager
 	msgHandler  MessageHandler
+	// recovers the panics from the message handler
+	handlerGuard *guard.Guard
+	// records the inbound messages if not nil
+	recorder *Recorder
 }
 
+// Create a peer manager with the magic number set in p2p.Magic,
+// this is kept for the old callers, use NewPeerManager() instead
 func InitPeerManager(localPeer *Peer, seeds []string) *PeerManager {
-	// Initiate PeerManager
-	pm = new(PeerManager)
+	pm = NewPeerManager(NewConfig(Magic, seeds), localPeer)
+	return pm
+}
+
+func NewPeerManager(config *Config, localPeer *Peer) *PeerManager {
+	pm := new(PeerManager)
+	pm.config = config
+	localPeer.pm = pm
 	pm.Peers = newPeers(localPeer)
-	pm.addrManager = newAddrManager(seeds)
-	pm.connManager = newConnManager(pm.OnDiscardAddr)
+	pm.addrManager = newAddrManager(config.SeedList, config.DataDir)
+	pm.connManager = newConnManager(pm, pm.OnDiscardAddr)
+	pm.handlerGuard = guard.NewHandler("message handler")
 	return pm
 }
 
+// Get the config of the peer to peer network
+func (pm *PeerManager) Config() *Config {
+	pm.configLock.RLock()
+	defer pm.configLock.RUnlock()
+
+	return pm.config
+}
+
+// Update the connection limits and seed list at runtime,
+// magic number can not be changed on a running network
+func (pm *PeerManager) UpdateConfig(config *Config) {
+	pm.configLock.Lock()
+	newConfig := *config
+	newConfig.Magic = pm.config.Magic
+	pm.config = &newConfig
+	pm.configLock.Unlock()
+
+	pm.addrManager.SetSeeds(newConfig.SeedList)
+	log.Infof("Peer manager config updated, min connections %d, max outbound %d, seeds %v",
+		newConfig.MinConnCount, newConfig.MaxOutboundCount, newConfig.SeedList)
+
+	// Disconnect the peers not allowed by the new trusted peers or banned subnets
+	for _, peer := range pm.ConnectedPeers() {
+		ip16 := peer.IP16()
+		if !newConfig.AllowIP(ip16[:]) {
+			log.Info("Disconnect peer not allowed by config, ", peer.Addr().String())
+			pm.DisconnectPeer(peer)
+			continue
+		}
+		// Disconnect the peers not authenticated with the new pinned key
+		if key, ok := newConfig.PinnedKey(ip16[:]); ok && !bytes.Equal(key, peer.authKey) {
+			log.Info("Disconnect peer not authenticated with the pinned key, ", peer.Addr().String())
+			pm.DisconnectPeer(peer)
+		}
+	}
+}
+
+// Create a peer belongs to this peer manager with the connection
+func (pm *PeerManager) NewPeer(conn net.Conn) *Peer {
+	ip16, port := addrFromConn(conn)
+	return &Peer{
+		pm:       pm,
+		conn:     conn,
+		ip16:     ip16,
+		port:     port,
+		connTime: clock.Now(),
+	}
+}
+
 func (pm *PeerManager) SetMessageHandler(msgHandler MessageHandler) {
 	pm.msgHandler = msgHandler
 }
 
+// Record the inbound messages of all peers, set it before the peer manager started
+func (pm *PeerManager) SetRecorder(recorder *Recorder) {
+	pm.recorder = recorder
+}
+
 func (pm *PeerManager) Start() {
 	log.Info("PeerManager start")
-	go pm.keepConnections()
-	go pm.listenConnection()
+	supervisor.Go("peer connections", pm.keepConnections)
+	if !pm.Config().OutboundOnly {
+		supervisor.Go("peer listener", pm.listenConnection)
+	}
+}
+
+// Disconnect all the peers and stop connecting peers until resumed,
+// it's used to stop the network activity when the app is in background
+func (pm *PeerManager) Pause() {
+	if !atomic.CompareAndSwapInt32(&pm.paused, 0, 1) {
+		return
+	}
+	log.Info("PeerManager paused")
+	for _, peer := range pm.ConnectedPeers() {
+		pm.DisconnectPeer(peer)
+	}
+}
+
+// Start connecting peers again after paused
+func (pm *PeerManager) Resume() {
+	if !atomic.CompareAndSwapInt32(&pm.paused, 1, 0) {
+		return
+	}
+	log.Info("PeerManager resumed")
+	go pm.connectPeers()
+}
+
+func (pm *PeerManager) IsPaused() bool {
+	return atomic.LoadInt32(&pm.paused) == 1
 }
 
 func (pm *PeerManager) NeedMorePeers() bool {
-	return pm.PeersCount() < MinConnCount
+	return pm.PeersCount() < pm.Config().MinConnCount
 }
 
 func (pm *PeerManager) ConnectPeer(addr string) {
+	if pm.IsPaused() {
+		return
+	}
+	if !pm.Config().AllowAddr(addr) {
+		log.Debug("Peer address not allowed by config, ", addr)
+		return
+	}
 	pm.connManager.Connect(addr)
 }
 
@@ -64,6 +175,7 @@ func (pm *PeerManager) AddConnectedPeer(peer *Peer) {
 
 	// Mark addr as connected
 	pm.addrManager.AddAddr(addr)
+	pm.addrManager.reputations.OnConnected(addr)
 }
 
 func (pm *PeerManager) DisconnectPeer(peer *Peer) {
@@ -77,9 +189,15 @@ func (pm *PeerManager) DisconnectPeer(peer *Peer) {
 		peer.Disconnect()
 		pm.connManager.removeAddrFromConnectingList(addr)
 		pm.addrManager.DisconnectedAddr(addr)
+		pm.addrManager.reputations.OnDisconnected(addr)
 	}
 }
 
+// Get the reputations of the peer addresses, they decide the order to connect the addresses
+func (pm *PeerManager) Reputations() *Reputations {
+	return pm.addrManager.reputations
+}
+
 func (pm *PeerManager) OnDiscardAddr(addr string) {
 	pm.addrManager.DiscardAddr(addr)
 }
@@ -89,8 +207,9 @@ func (pm *PeerManager) RandAddrs() []Addr {
 
 	log.Info("Rand peer addrs, connected peers:", peers)
 	count := len(peers)
-	if count > MaxOutboundCount {
-		count = MaxOutboundCount
+	maxCount := pm.Config().MaxOutboundCount
+	if count > maxCount {
+		count = maxCount
 	}
 
 	addrs := make([]Addr, count)
@@ -103,8 +222,14 @@ func (pm *PeerManager) RandAddrs() []Addr {
 }
 
 func (pm *PeerManager) connectPeers() {
-	if pm.NeedMorePeers() {
-		addrs := pm.addrManager.GetIdleAddrs(MaxOutboundCount)
+	if !pm.IsPaused() && pm.NeedMorePeers() {
+		// Connect only to the trusted peers if they are set
+		var addrs []string
+		if trusted := pm.Config().TrustedPeers; len(trusted) > 0 {
+			addrs = pm.addrManager.FilterIdleAddrs(trusted)
+		} else {
+			addrs = pm.addrManager.GetIdleAddrs(pm.Config().MaxOutboundCount)
+		}
 		for _, addr := range addrs {
 			go pm.ConnectPeer(addr)
 		}
@@ -114,9 +239,9 @@ func (pm *PeerManager) connectPeers() {
 func (pm *PeerManager) keepConnections() {
 	pm.connectPeers()
 
-	ticker := time.NewTicker(time.Second * InfoUpdateDuration)
+	ticker := clock.NewTicker(time.Second * InfoUpdateDuration)
 	defer ticker.Stop()
-	for range ticker.C {
+	for range ticker.C() {
 		pm.connectPeers()
 	}
 }
@@ -135,10 +260,20 @@ func (pm *PeerManager) listenConnection() {
 			fmt.Println("Error accepting ", err.Error())
 			continue
 		}
+		if pm.IsPaused() {
+			conn.Close()
+			continue
+		}
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && !pm.Config().AllowIP(addr.IP) {
+			log.Info("Refused peer connection not allowed by config, remote: ", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
 		fmt.Printf("New peer connection accepted, remote: %s local: %s\n", conn.RemoteAddr(), conn.LocalAddr())
 
-		peer := NewPeer(conn)
-		go peer.Read()
+		peer := pm.NewPeer(conn)
+		peer.startRead()
+		pm.handshakeDeadline(peer)
 	}
 }
 
@@ -153,6 +288,8 @@ func (pm *PeerManager) makeMessage(cmd string) (Message, error) {
 		msg = new(AddrsReq)
 	case "addr":
 		msg = new(Addrs)
+	case "authresp":
+		msg = new(AuthResponse)
 	default:
 		return pm.msgHandler.MakeMessage(cmd)
 	}
@@ -167,6 +304,8 @@ func (pm *PeerManager) handleMessage(peer *Peer, msg Message) {
 		err = pm.OnVersion(peer, msg)
 	case *VerAck:
 		err = pm.OnVerAck(peer, msg)
+	case *AuthResponse:
+		err = pm.OnAuthResponse(peer, msg)
 	case *AddrsReq:
 		err = pm.OnAddrsReq(peer, msg)
 	case *Addrs:
@@ -177,6 +316,13 @@ func (pm *PeerManager) handleMessage(peer *Peer, msg Message) {
 
 	if err != nil {
 		log.Error("Handle message error,", err)
+		if errors.Is(err, errors.ErrPeerMisbehaving) {
+			pm.addrManager.reputations.OnInvalid(peer.Addr().String())
+			// A peer misbehaving in the handshake is not established
+			if state := peer.State(); state != ESTABLISH && state != INACTIVITY {
+				pm.failHandshake(peer)
+			}
+		}
 	}
 }
 
@@ -184,14 +330,20 @@ func (pm *PeerManager) OnVersion(peer *Peer, v *Version) error {
 	// Check if handshake with itself
 	if v.Nonce == pm.Local().ID() {
 		log.Error("SPV disconnect peer, peer handshake with itself")
-		pm.DisconnectPeer(peer)
+		pm.failHandshake(peer)
 		pm.OnDiscardAddr(peer.Addr().String())
-		return errors.New("Peer handshake with itself")
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Peer handshake with itself")
 	}
 
-	if peer.State() != INIT && peer.State() != HAND {
+	// An inbound peer responds with the version message, an outbound one has sent it already
+	state, next := peer.State(), HANDSHAKE
+	switch state {
+	case INIT:
+	case HAND:
+		next = HANDSHAKED
+	default:
 		log.Error("Unknow status to received version")
-		return errors.New("Unknow status to received version")
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Unknow status to received version")
 	}
 
 	// Remove duplicate peer connection
@@ -206,36 +358,76 @@ func (pm *PeerManager) OnVersion(peer *Peer, v *Version) error {
 	// Set peer info with version message
 	peer.SetInfo(v)
 
+	// Sample the peer time for the network adjusted time
+	clock.Adjusted.AddSample(v.Nonce, time.Unix(int64(v.TimeStamp), 0))
+
 	// Handle peer handshake
 	if err := pm.msgHandler.OnHandshake(v); err != nil {
-		pm.DisconnectPeer(peer)
+		pm.failHandshake(peer)
 		return err
 	}
 
-	var message Message
-	if peer.State() == INIT {
-		peer.SetState(HANDSHAKE)
-		message = peer.NewVersionMsg()
-	} else if peer.State() == HAND {
-		peer.SetState(HANDSHAKED)
-		message = new(VerAck)
+	if err := peer.transit(state, next); err != nil {
+		return err
 	}
 
+	var message Message = new(VerAck)
+	if next == HANDSHAKE {
+		message = peer.NewVersionMsg()
+	}
 	go peer.Send(message)
 
 	return nil
 }
 
 func (pm *PeerManager) OnVerAck(peer *Peer, va *VerAck) error {
-	if peer.State() != HANDSHAKE && peer.State() != HANDSHAKED {
-		return errors.New("Unknow status to received verack")
+	state := peer.State()
+	if state != HANDSHAKE && state != HANDSHAKED {
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Unknow status to received verack")
 	}
 
-	if peer.State() == HANDSHAKE {
+	if state == HANDSHAKE {
 		go peer.Send(new(VerAck))
 	}
 
-	peer.SetState(ESTABLISH)
+	// Pinned peer must be authenticated before established
+	ip16 := peer.IP16()
+	if key, ok := pm.Config().PinnedKey(ip16[:]); ok {
+		return pm.challengePeer(peer, state, key)
+	}
+
+	return pm.establishPeer(peer, state)
+}
+
+func (pm *PeerManager) OnAuthResponse(peer *Peer, resp *AuthResponse) error {
+	if peer.State() != AUTHENTICATING {
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Unknow status to received auth response")
+	}
+
+	if err := pm.verifyAuthResponse(peer, resp); err != nil {
+		log.Error("Pinned peer auth failed, disconnect peer ", peer.Addr().String())
+		pm.rejectPeer(peer)
+		return err
+	}
+
+	log.Info("Pinned peer authenticated, ", peer.Addr().String())
+	return pm.establishPeer(peer, AUTHENTICATING)
+}
+
+// Establish the peer finished the handshake in the state, the peer disconnected or timeout in the meantime
+// is not established
+func (pm *PeerManager) establishPeer(peer *Peer, state int) error {
+	// Peers finished handshake after paused are not established
+	if pm.IsPaused() {
+		addr := peer.Addr().String()
+		peer.Disconnect()
+		pm.connManager.removeAddrFromConnectingList(addr)
+		return nil
+	}
+
+	if err := peer.transit(state, ESTABLISH); err != nil {
+		return err
+	}
 
 	// Add to connected peer
 	pm.AddConnectedPeer(peer)
@@ -246,7 +438,6 @@ func (pm *PeerManager) OnVerAck(peer *Peer, va *VerAck) error {
 	if pm.NeedMorePeers() {
 		go peer.Send(new(AddrsReq))
 	}
-
 	return nil
 }
 
@@ -73,6 +73,22 @@ func (p *Peers) ConnectedPeers() []*Peer {
 	return peers
 }
 
+// Get the stats of connected peers, the sync peer is marked
+func (p *Peers) PeersStats() []*PeerStats {
+	p.syncPeerLock.Lock()
+	syncPeer := p.syncPeer
+	p.syncPeerLock.Unlock()
+
+	peers := p.ConnectedPeers()
+	stats := make([]*PeerStats, 0, len(peers))
+	for _, peer := range peers {
+		stat := peer.Stats()
+		stat.SyncPeer = peer == syncPeer
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
 func (p *Peers) EstablishedPeer(id uint64) bool {
 	p.peersLock.RLock()
 	defer p.peersLock.RUnlock()
@@ -0,0 +1,167 @@
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	// Version of the recording format, it follows the recordMagic at the beginning of a recording
+	RecordVersion = 1
+
+	// Max length of a recorded message, a larger length means the recording is corrupted
+	maxRecordLen = HEADERLEN + MaxMsgLen
+)
+
+var recordMagic = [6]byte{'S', 'P', 'V', 'R', 'E', 'C'}
+
+// Record is an inbound message in a recording, the message is in the wire format with the header
+type Record struct {
+	Time time.Time
+	// Address and the handshake state of the peer sent the message when it's received
+	IP16    [16]byte
+	Port    uint16
+	State   uint8
+	Message []byte
+}
+
+// recordingHeader is at the beginning of a recording
+type recordingHeader struct {
+	Magic   [6]byte
+	Version uint16
+	Network uint32
+}
+
+// recordHeader is the fixed size part of a record before the message
+type recordHeader struct {
+	Time   int64
+	IP16   [16]byte
+	Port   uint16
+	State  uint8
+	Length uint32
+}
+
+/*
+Recorder writes all the inbound messages with the receive time and the peer to a recording, so a sync
+reported wrong in the field can be reproduced exactly by PeerManager.Replay(). Messages are recorded in
+the order they are received, before they are decoded, so a malformed message is recorded too.
+*/
+type Recorder struct {
+	lock   sync.Mutex
+	writer *bufio.Writer
+	closer io.Closer
+	err    error
+}
+
+// Create a recorder writing to w for the network of the magic, w is closed by Close() if it's an io.Closer
+func NewRecorder(w io.Writer, magic uint32) (*Recorder, error) {
+	r := &Recorder{writer: bufio.NewWriter(w)}
+	if closer, ok := w.(io.Closer); ok {
+		r.closer = closer
+	}
+	err := binary.Write(r.writer, binary.LittleEndian, &recordingHeader{recordMagic, RecordVersion, magic})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write the message received from the peer, recording stops at the first write error
+func (r *Recorder) record(peer *Peer, message []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	header := recordHeader{
+		Time:   clock.Now().UnixNano(),
+		IP16:   peer.IP16(),
+		Port:   peer.Port(),
+		State:  uint8(peer.State()),
+		Length: uint32(len(message)),
+	}
+	if r.err = binary.Write(r.writer, binary.LittleEndian, &header); r.err == nil {
+		_, r.err = r.writer.Write(message)
+	}
+	if r.err != nil {
+		log.Error("Record peer message failed, recording stopped, ", r.err)
+	}
+}
+
+// Flush the recorded messages and close the writer
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err := r.writer.Flush()
+	if r.err == nil {
+		r.err = errors.New("recorder closed")
+	}
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// RecordReader reads the records of a recording in order
+type RecordReader struct {
+	reader *bufio.Reader
+	magic  uint32
+}
+
+// Create a reader of the recording, an error of errors.ErrCorrupted if it's not a recording
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	var header recordingHeader
+	reader := bufio.NewReader(r)
+	if err := binary.Read(reader, binary.LittleEndian, &header); err != nil {
+		return nil, errors.WrapErr(errors.ErrCorrupted, err, "read recording header failed")
+	}
+	if header.Magic != recordMagic {
+		return nil, errors.Wrap(errors.ErrCorrupted, "not a peer messages recording")
+	}
+	if header.Version != RecordVersion {
+		return nil, errors.Wrapf(errors.ErrInvalid, "recording version %d not supported", header.Version)
+	}
+	return &RecordReader{reader: reader, magic: header.Network}, nil
+}
+
+// Get the magic number of the network the messages recorded from
+func (r *RecordReader) Magic() uint32 {
+	return r.magic
+}
+
+// Read the next record, io.EOF is returned after the last record
+func (r *RecordReader) Next() (*Record, error) {
+	var header recordHeader
+	err := binary.Read(r.reader, binary.LittleEndian, &header)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.WrapErr(errors.ErrCorrupted, err, "read record failed")
+	}
+	if header.Length > maxRecordLen {
+		return nil, errors.Wrapf(errors.ErrCorrupted, "record length %d too large", header.Length)
+	}
+	record := &Record{
+		Time:    time.Unix(0, header.Time),
+		IP16:    header.IP16,
+		Port:    header.Port,
+		State:   header.State,
+		Message: make([]byte, header.Length),
+	}
+	if _, err := io.ReadFull(r.reader, record.Message); err != nil {
+		return nil, errors.WrapErr(errors.ErrCorrupted, err, "read recorded message failed")
+	}
+	return record, nil
+}
@@ -0,0 +1,103 @@
+package p2p
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+func TestRecordReplay(t *testing.T) {
+	recorded := time.Unix(1514764800, 0)
+	clock.Set(clock.NewMock(recorded))
+	defer clock.Set(clock.System)
+
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Record a handshake
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(&buf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, handler := handshakePeerManager(t, dir)
+	pm.SetRecorder(recorder)
+	peers := newHandshakePeers(t, pm)
+	defer peers.close()
+	peer := peers.peer(HAND)
+	peer.unpackMessage(buildMessage(t, &Version{Version: 1, Nonce: 1}, new(VerAck)))
+	if <-handler.established != peer {
+		t.Fatal("recorded peer not established")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	recording := buf.Bytes()
+
+	// The records are read in the received order
+	reader, err := NewRecordReader(bytes.NewReader(recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{"version", "verack"} {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr, err := verifyHeader(1, record.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.GetCMD() != cmd || !record.Time.Equal(recorded) || record.IP16 != peer.IP16() ||
+			record.Port != peer.Port() {
+			t.Errorf("record %s at %v from %v:%d, expect %s", hdr.GetCMD(), record.Time, record.IP16,
+				record.Port, cmd)
+		}
+	}
+
+	// Replay the handshake to a fresh peer manager on a mock clock behind the recording
+	mock := clock.NewMock(recorded.Add(-time.Hour))
+	clock.Set(mock)
+	reader, err = NewRecordReader(bytes.NewReader(recording))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm, handler = handshakePeerManager(t, dir)
+	if err := pm.Replay(reader, mock); err != nil {
+		t.Fatal(err)
+	}
+	replayed := <-handler.established
+	if replayed.State() != ESTABLISH || replayed.IP16() != peer.IP16() || replayed.Port() != peer.Port() {
+		t.Errorf("replayed peer %s in state %s", replayed.Addr(), stateString(replayed.State()))
+	}
+	if !mock.Now().Equal(recorded) {
+		t.Errorf("replay clock %v, expect %v", mock.Now(), recorded)
+	}
+
+	// A recording of another network is not replayed
+	reader, _ = NewRecordReader(bytes.NewReader(recording))
+	pm = NewPeerManager(NewConfig(2, nil), new(Peer))
+	if err := pm.Replay(reader, nil); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("replay recording of another network error %v", err)
+	}
+
+	// Truncated or foreign data is corrupted
+	reader, _ = NewRecordReader(bytes.NewReader(recording[:len(recording)-1]))
+	if _, err := reader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, errors.ErrCorrupted) {
+		t.Errorf("read truncated record error %v", err)
+	}
+	if _, err := NewRecordReader(bytes.NewReader(recording[1:])); !errors.Is(err, errors.ErrCorrupted) {
+		t.Errorf("read recording without magic error %v", err)
+	}
+}
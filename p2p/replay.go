@@ -0,0 +1,61 @@
+package p2p
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+/*
+Replay the recorded messages to the message handler in the recorded order, one message at a time
+through the same decoding and handshake checks as a live session. A replayed peer starts in the state
+it was in when its first message was recorded, the messages sent to it are discarded. If the mock clock
+is given it's set to the receive time before each message, so the timeouts fire as they did when recorded.
+The peer manager must not be started, replay it in a test to reproduce a sync exactly.
+*/
+func (pm *PeerManager) Replay(reader *RecordReader, mock *clock.Mock) error {
+	if reader.Magic() != pm.Config().Magic {
+		return errors.Wrapf(errors.ErrInvalid, "recording of network %d, expect %d", reader.Magic(),
+			pm.Config().Magic)
+	}
+
+	peers := make(map[string]*Peer)
+	defer func() {
+		for _, peer := range peers {
+			peer.conn.Close()
+		}
+	}()
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if mock != nil && record.Time.After(mock.Now()) {
+			mock.Set(record.Time)
+		}
+		key := string(record.IP16[:]) + string([]byte{byte(record.Port), byte(record.Port >> 8)})
+		peer, ok := peers[key]
+		if !ok {
+			peer = pm.replayPeer(record)
+			peers[key] = peer
+		}
+		peer.lastActive = clock.Now()
+		peer.decodeMessage(record.Message)
+	}
+}
+
+// Create a peer of the recorded address, what's sent to it is drained
+func (pm *PeerManager) replayPeer(record *Record) *Peer {
+	local, remote := net.Pipe()
+	go io.Copy(ioutil.Discard, remote)
+	peer := &Peer{pm: pm, conn: local, ip16: record.IP16, port: record.Port, connTime: clock.Now()}
+	peer.SetState(int(record.State))
+	return peer
+}
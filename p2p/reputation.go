@@ -0,0 +1,158 @@
+package p2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	// File the peer reputations are saved to, beside the cached addresses
+	ReputationFile = "reputation.json"
+
+	// Min interval to save the reputations changed by the delivered blocks
+	reputationSaveInterval = time.Minute
+)
+
+// Reputation is the behavior history of a peer address, kept across restarts
+type Reputation struct {
+	// Valid blocks delivered by the peer
+	Blocks uint64
+	// Invalid data received, like misbehaving messages
+	Invalid uint32
+	// Total time connected
+	Uptime time.Duration
+	// Unix time the peer was connected last time
+	LastSeen int64
+}
+
+// Score of the reputation, a peer connected longer and delivered more blocks is preferred,
+// and each invalid data received costs as much as a day of uptime
+func (r *Reputation) Score() float64 {
+	return r.Uptime.Hours() + float64(r.Blocks)/1000 - float64(r.Invalid)*24
+}
+
+/*
+Reputations keeps the reputation of the peer addresses in the reputation file, so the addresses
+are connected in the order of their scores after restarted instead of at random.
+*/
+type Reputations struct {
+	sync.Mutex
+	file      string
+	peers     map[string]*Reputation
+	connected map[string]time.Time
+	dirty     bool
+	saved     time.Time
+}
+
+// Load the reputations from the file, start with no history if it's not readable
+func newReputations(file string) *Reputations {
+	r := &Reputations{
+		file:      file,
+		peers:     make(map[string]*Reputation),
+		connected: make(map[string]time.Time),
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return r
+	}
+	if err := json.Unmarshal(data, &r.peers); err != nil {
+		log.Warn("Ignore the peer reputations not readable, ", err)
+		r.peers = make(map[string]*Reputation)
+	}
+	return r
+}
+
+// Get the reputation of the address, a zero reputation if no history
+func (r *Reputations) Get(addr string) Reputation {
+	r.Lock()
+	defer r.Unlock()
+
+	if reputation, ok := r.peers[addr]; ok {
+		return *reputation
+	}
+	return Reputation{}
+}
+
+// Get the score of the address
+func (r *Reputations) Score(addr string) float64 {
+	reputation := r.Get(addr)
+	return reputation.Score()
+}
+
+// The peer of the address connected
+func (r *Reputations) OnConnected(addr string) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := clock.Now()
+	r.connected[addr] = now
+	r.get(addr).LastSeen = now.Unix()
+	r.dirty = true
+}
+
+// The peer of the address disconnected, the uptime is accumulated and saved
+func (r *Reputations) OnDisconnected(addr string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if since, ok := r.connected[addr]; ok {
+		r.get(addr).Uptime += clock.Since(since)
+		delete(r.connected, addr)
+		r.dirty = true
+	}
+	r.save()
+}
+
+// A block delivered by the peer of the address, saved at most once a minute
+func (r *Reputations) OnBlock(addr string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.get(addr).Blocks++
+	r.dirty = true
+	if clock.Since(r.saved) >= reputationSaveInterval {
+		r.save()
+	}
+}
+
+// Invalid data received from the peer of the address
+func (r *Reputations) OnInvalid(addr string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.get(addr).Invalid++
+	r.dirty = true
+	r.save()
+}
+
+func (r *Reputations) get(addr string) *Reputation {
+	reputation, ok := r.peers[addr]
+	if !ok {
+		reputation = new(Reputation)
+		r.peers[addr] = reputation
+	}
+	return reputation
+}
+
+// Save the reputations to the file if changed, the lock must be held
+func (r *Reputations) save() {
+	if !r.dirty {
+		return
+	}
+	data, err := json.Marshal(r.peers)
+	if err != nil {
+		log.Error("Marshal peer reputations failed, ", err)
+		return
+	}
+	if err := ioutil.WriteFile(r.file, data, 0666); err != nil {
+		log.Error("Save peer reputations failed, ", err)
+		return
+	}
+	r.dirty = false
+	r.saved = clock.Now()
+}
@@ -0,0 +1,58 @@
+package p2p
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+)
+
+func TestReputations(t *testing.T) {
+	mock := clock.NewMock(time.Unix(1514764800, 0))
+	clock.Set(mock)
+	defer clock.Set(clock.System)
+
+	dir, err := ioutil.TempDir("", "reputation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, ReputationFile)
+
+	const stable, flaky = "127.0.0.1:20866", "127.0.0.2:20866"
+	reputations := newReputations(file)
+	reputations.OnConnected(stable)
+	reputations.OnConnected(flaky)
+	reputations.OnBlock(stable)
+	reputations.OnInvalid(flaky)
+	mock.Add(2 * time.Hour)
+	reputations.OnDisconnected(stable)
+	reputations.OnDisconnected(flaky)
+
+	// Loaded after restarted
+	reputations = newReputations(file)
+	reputation := reputations.Get(stable)
+	if reputation.Blocks != 1 || reputation.Uptime != 2*time.Hour || reputation.LastSeen != 1514764800 {
+		t.Errorf("unexpected reputation %+v", reputation)
+	}
+	if reputations.Get(flaky).Invalid != 1 {
+		t.Errorf("unexpected reputation %+v", reputations.Get(flaky))
+	}
+	if reputations.Score(flaky) >= reputations.Score("127.0.0.3:20866") {
+		t.Error("peer sent invalid data scored higher than an unknown peer")
+	}
+
+	// Idle addresses are connected in the order of the scores
+	am := &AddrManager{
+		seeds:       []string{flaky, "127.0.0.3:20866", stable},
+		connected:   make(map[string]byte),
+		reputations: reputations,
+	}
+	addrs := am.GetIdleAddrs(3)
+	if len(addrs) != 3 || addrs[0] != stable || addrs[2] != flaky {
+		t.Errorf("unexpected idle addresses order %v", addrs)
+	}
+}
@@ -3,7 +3,8 @@ package p2p
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
+
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
 type Version struct {
@@ -34,7 +35,7 @@ func (msg *Version) Deserialize(body []byte) error {
 	buf := bytes.NewBuffer(body)
 	err := binary.Read(buf, binary.LittleEndian, msg)
 	if err != nil {
-		return errors.New("Deserialize version message content error")
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Deserialize version message content error")
 	}
 
 	return nil
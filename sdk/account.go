@@ -4,6 +4,7 @@ import (
 	"bytes"
 
 	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	"github.com/elastos/Elastos.ELA.SPV/crypto/ecc"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 )
@@ -83,7 +84,7 @@ func (a *Account) SignTx(txn *tx.Transaction) ([]byte, error) {
 
 // Sign data with account
 func (a *Account) Sign(data []byte) ([]byte, error) {
-	signature, err := crypto.Sign(a.privateKey, data)
+	signature, err := ecc.Sign(a.privateKey, data)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,184 @@
+/*
+Package address generates and validates ELA addresses. It depends on nothing but the key
+encoding and script format of the chain, so exchanges and custodians can derive deposit
+addresses from public keys without running a wallet or a database.
+
+An address is the base58 encoding of a program hash and it's checksum, the program hash is
+the prefix of the address type followed by ripemd160(sha256(redeem script)).
+*/
+package address
+
+import (
+	"math/big"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+
+	"github.com/itchyny/base58-go"
+)
+
+// Prefix of the program hash, decides the leading letter of the address
+const (
+	PrefixStandard   = 0x21 // Standard address of a single public key, starts with "E"
+	PrefixMultiSig   = 0x12 // M of N multi-sign address, starts with "8"
+	PrefixCrossChain = 0x4B // Cross chain deposit address of a side chain, starts with "X"
+)
+
+// Max public keys of a multi-sign address, N is written as a single PUSH opcode
+const MaxMultiSignKeys = 16
+
+type Type byte
+
+const (
+	Standard   = Type(PrefixStandard)
+	MultiSig   = Type(PrefixMultiSig)
+	CrossChain = Type(PrefixCrossChain)
+)
+
+func (t Type) String() string {
+	switch t {
+	case Standard:
+		return "standard"
+	case MultiSig:
+		return "multisig"
+	case CrossChain:
+		return "crosschain"
+	default:
+		return "unknown"
+	}
+}
+
+// Get the standard redeem script of the public key,
+// the public key can be compressed or uncompressed
+func StandardRedeemScript(publicKey []byte) ([]byte, error) {
+	pubKey, err := crypto.DecodePoint(publicKey)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid public key")
+	}
+	return tx.CreateStandardRedeemScript(pubKey)
+}
+
+// Get the M of N multi-sign redeem script of the public keys, the keys are sorted
+// in the script so the order of the given public keys does not matter
+func MultiSignRedeemScript(m int, publicKeys [][]byte) ([]byte, error) {
+	n := len(publicKeys)
+	if n == 0 || n > MaxMultiSignKeys {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid public keys count, should be 1 to 16")
+	}
+	if m < 1 || m > n {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid M, should be 1 to the public keys count")
+	}
+
+	pubKeys := make([]*crypto.PublicKey, 0, n)
+	for _, publicKey := range publicKeys {
+		pubKey, err := crypto.DecodePoint(publicKey)
+		if err != nil {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid public key")
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return tx.CreateMultiSignRedeemScript(m, pubKeys)
+}
+
+// Get the cross chain redeem script of the side chain with the given genesis block hash
+func CrossChainRedeemScript(genesisHash Uint256) []byte {
+	script := make([]byte, 0, UINT256SIZE+2)
+	script = append(script, UINT256SIZE)
+	script = append(script, genesisHash[:]...)
+	return append(script, tx.CROSSCHAIN)
+}
+
+// Get the program hash of a redeem script
+func ProgramHash(redeemScript []byte) (*Uint168, error) {
+	if len(redeemScript) == 0 {
+		return nil, errors.Wrap(errors.ErrInvalid, "empty redeem script")
+	}
+	return tx.ToProgramHash(redeemScript)
+}
+
+// Get the address of a program hash
+func FromProgramHash(programHash *Uint168) (string, error) {
+	return programHash.ToAddress()
+}
+
+// Get the address of a redeem script
+func FromRedeemScript(redeemScript []byte) (string, error) {
+	programHash, err := ProgramHash(redeemScript)
+	if err != nil {
+		return "", err
+	}
+	return programHash.ToAddress()
+}
+
+// Get the standard address of the public key
+func FromPublicKey(publicKey []byte) (string, error) {
+	redeemScript, err := StandardRedeemScript(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return FromRedeemScript(redeemScript)
+}
+
+// Get the M of N multi-sign address of the public keys
+func FromMultiSign(m int, publicKeys [][]byte) (string, error) {
+	redeemScript, err := MultiSignRedeemScript(m, publicKeys)
+	if err != nil {
+		return "", err
+	}
+	return FromRedeemScript(redeemScript)
+}
+
+// Get the cross chain deposit address of the side chain with the given genesis block hash,
+// assets sent to this address on the main chain are transferred to the side chain.
+// The hash is in byte order, reverse the hex string shown by block explorers to get it
+func FromGenesisHash(genesisHash Uint256) (string, error) {
+	return FromRedeemScript(CrossChainRedeemScript(genesisHash))
+}
+
+// Decode the address into program hash, return error if the address is invalid
+func ToProgramHash(address string) (*Uint168, error) {
+	decoded, err := base58.BitcoinEncoding.Decode([]byte(address))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid address, not base58 encoded")
+	}
+
+	value, ok := new(big.Int).SetString(string(decoded), 10)
+	if !ok {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid address, not base58 encoded")
+	}
+	data := value.Bytes()
+	if len(data) != UINT168SIZE+4 {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid address length")
+	}
+
+	checksum := Sha256D(data[:UINT168SIZE])
+	for i := 0; i < 4; i++ {
+		if data[UINT168SIZE+i] != checksum[i] {
+			return nil, errors.Wrap(errors.ErrInvalid, "invalid address checksum")
+		}
+	}
+
+	return Uint168FromBytes(data[:UINT168SIZE])
+}
+
+// Get the type of the address, return error if the address is invalid
+func GetType(address string) (Type, error) {
+	programHash, err := ToProgramHash(address)
+	if err != nil {
+		return 0, err
+	}
+	return Type(programHash[0]), nil
+}
+
+// Validate the address, return nil if it's a valid address of any type
+func Validate(address string) error {
+	_, err := ToProgramHash(address)
+	return err
+}
+
+// Check if the address is valid
+func IsValid(address string) bool {
+	return Validate(address) == nil
+}
@@ -0,0 +1,92 @@
+package address
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+func TestFromGenesisHash(t *testing.T) {
+	// The DID side chain genesis block and it's deposit address on the main chain
+	hash, _ := HexStringToBytesReverse("56be936978c261b2e649d58dbfaf3f23d4a868274f5522cd2adb4308a955c4a3")
+	genesisHash, _ := Uint256FromBytes(hash)
+
+	address, err := FromGenesisHash(*genesisHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != "XKUh4GLhFJiqAMTF6HyWQrV9pK9HcGUdfJ" {
+		t.Fatalf("cross chain address %s, expect XKUh4GLhFJiqAMTF6HyWQrV9pK9HcGUdfJ", address)
+	}
+	if addrType, _ := GetType(address); addrType != CrossChain {
+		t.Fatalf("address type %s, expect crosschain", addrType)
+	}
+}
+
+func TestFromPublicKey(t *testing.T) {
+	_, pubKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, _ := pubKey.EncodePoint(true)
+	uncompressed, _ := pubKey.EncodePoint(false)
+
+	address, err := FromPublicKey(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := FromPublicKey(uncompressed); other != address {
+		t.Fatal("address of compressed and uncompressed public key are different")
+	}
+	if addrType, err := GetType(address); err != nil || addrType != Standard {
+		t.Fatalf("address type %s, expect standard, error %v", addrType, err)
+	}
+
+	programHash, err := ToProgramHash(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := FromProgramHash(programHash); decoded != address {
+		t.Fatal("address program hash round trip failed")
+	}
+}
+
+func TestFromMultiSign(t *testing.T) {
+	var publicKeys [][]byte
+	for i := 0; i < 3; i++ {
+		_, pubKey, _ := crypto.GenerateKeyPair()
+		publicKey, _ := pubKey.EncodePoint(true)
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	address, err := FromMultiSign(2, publicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reversed := [][]byte{publicKeys[2], publicKeys[1], publicKeys[0]}
+	if other, _ := FromMultiSign(2, reversed); other != address {
+		t.Fatal("multi sign address depends on the public keys order")
+	}
+	if addrType, _ := GetType(address); addrType != MultiSig {
+		t.Fatalf("address type %s, expect multisig", addrType)
+	}
+
+	if _, err := FromMultiSign(4, publicKeys); err == nil {
+		t.Fatal("M larger than N should fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for address, valid := range map[string]bool{
+		"ETBBrgotZy3993o9bH75KxjLDgQxBCib6u": true,
+		"ETBBrgotZy3993o9bH75KxjLDgQxBCib6v": false, // checksum
+		"ETBBrgotZy3993o9bH75KxjLDgQxBCib6":  false, // length
+		"0OIl":                               false, // not base58
+		"":                                   false,
+	} {
+		if IsValid(address) != valid {
+			t.Errorf("address %q valid should be %v", address, valid)
+		}
+	}
+}
@@ -9,10 +9,14 @@ import (
 /*
 This is a helper class to filter interested addresses when synchronize transactions
 or get cached addresses list to build a bloom filter instead of load addresses from database every time.
+The version of the filter increases every time the address set changed, compare it with the version
+the bloom filter was built with to skip rebuilding the bloom filter if no address changed.
 */
 type AddrFilter struct {
 	sync.Mutex
-	addrs map[Uint168]*Uint168
+	addrs   map[Uint168]*Uint168
+	keys    map[string]struct{}
+	version uint64
 }
 
 // Create a AddrFilter instance, you can pass all the addresses through this method
@@ -32,6 +36,7 @@ func (filter *AddrFilter) LoadAddrs(addrs []*Uint168) {
 	for _, addr := range addrs {
 		filter.addrs[*addr] = addr
 	}
+	filter.version++
 }
 
 // Check if addresses are loaded into this Filter
@@ -47,7 +52,11 @@ func (filter *AddrFilter) AddAddr(addr *Uint168) {
 	filter.Lock()
 	defer filter.Unlock()
 
+	if _, ok := filter.addrs[*addr]; ok {
+		return
+	}
 	filter.addrs[*addr] = addr
+	filter.version++
 }
 
 // Remove an address from this Filter
@@ -55,17 +64,39 @@ func (filter *AddrFilter) DeleteAddr(hash Uint168) {
 	filter.Lock()
 	defer filter.Unlock()
 
+	if _, ok := filter.addrs[hash]; !ok {
+		return
+	}
 	delete(filter.addrs, hash)
+	filter.version++
 }
 
 // Get addresses that were added into this Filter
 func (filter *AddrFilter) GetAddrs() []*Uint168 {
+	addrs, _ := filter.Snapshot()
+	return addrs
+}
+
+// Get the addresses in this Filter with the version of them, the version
+// changes when addresses are loaded, added or removed
+func (filter *AddrFilter) Snapshot() ([]*Uint168, uint64) {
+	filter.Lock()
+	defer filter.Unlock()
+
 	var addrs = make([]*Uint168, 0, len(filter.addrs))
 	for _, addr := range filter.addrs {
 		addrs = append(addrs, addr)
 	}
 
-	return addrs
+	return addrs, filter.version
+}
+
+// Get the version of the addresses in this Filter
+func (filter *AddrFilter) Version() uint64 {
+	filter.Lock()
+	defer filter.Unlock()
+
+	return filter.version
 }
 
 // Check if an address was added into this filter as a interested address
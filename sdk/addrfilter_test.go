@@ -0,0 +1,57 @@
+package sdk
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+func TestAddrFilterVersion(t *testing.T) {
+	filter := NewAddrFilter(nil)
+	version := filter.Version()
+
+	filter.AddAddr(&Uint168{1})
+	if filter.Version() == version {
+		t.Fatal("version not changed after address added")
+	}
+	version = filter.Version()
+
+	// Adding an existing or removing a missing address changes nothing
+	filter.AddAddr(&Uint168{1})
+	filter.DeleteAddr(Uint168{2})
+	addrs, snapshotVersion := filter.Snapshot()
+	if snapshotVersion != version || len(addrs) != 1 {
+		t.Fatalf("version %d with %d addresses, expect %d with 1", snapshotVersion, len(addrs), version)
+	}
+
+	filter.DeleteAddr(Uint168{1})
+	if filter.Version() == version || len(filter.GetAddrs()) != 0 {
+		t.Fatal("address not removed")
+	}
+}
+
+func TestAddrFilterConcurrent(t *testing.T) {
+	filter := NewAddrFilter(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				filter.AddAddr(&Uint168{byte(i), byte(j)})
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				filter.GetAddrs()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if addrs, version := filter.Snapshot(); len(addrs) != 400 || version != 401 {
+		t.Fatalf("%d addresses with version %d, expect 400 with 401", len(addrs), version)
+	}
+}
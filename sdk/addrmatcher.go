@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"errors"
+
+	"github.com/elastos/Elastos.ELA.SPV/core/contract/program"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+// Length of a compressed public key and the opcode pushing it in a redeem script
+const compressedKeyLength = 33
+
+// TxMatch is the result of matching a transaction with the AddrFilter
+type TxMatch struct {
+	// Indexes of the outputs paying to the interested addresses
+	Outputs []int
+
+	// Indexes of the programs of the interested addresses or public keys,
+	// which means the inputs of the transaction are spent by them
+	Programs []int
+}
+
+// Check if any output or program of the transaction matched
+func (m *TxMatch) IsMatched() bool {
+	return len(m.Outputs) > 0 || len(m.Programs) > 0
+}
+
+/*
+Add an interested public key into this Filter. Besides the address of the public key,
+a program is matched if the public key is one of the signers, like a multi-sign
+program the public key participating in.
+*/
+func (filter *AddrFilter) AddPublicKey(publicKey *crypto.PublicKey) error {
+	key, err := publicKey.EncodePoint(true)
+	if err != nil {
+		return errors.New("encode public key failed, " + err.Error())
+	}
+
+	filter.Lock()
+	defer filter.Unlock()
+
+	if filter.keys == nil {
+		filter.keys = make(map[string]struct{})
+	}
+	if _, ok := filter.keys[string(key)]; ok {
+		return nil
+	}
+	filter.keys[string(key)] = struct{}{}
+	filter.version++
+	return nil
+}
+
+// Check if the output pays to an interested address
+func (filter *AddrFilter) MatchOutput(output *tx.Output) bool {
+	return filter.ContainAddr(output.ProgramHash)
+}
+
+// Check if the program belongs to an interested address,
+// or the redeem script of it includes an interested public key
+func (filter *AddrFilter) MatchProgram(program *program.Program) bool {
+	if len(program.Code) == 0 {
+		return false
+	}
+
+	filter.Lock()
+	defer filter.Unlock()
+
+	if programHash, err := tx.ToProgramHash(program.Code); err == nil {
+		if _, ok := filter.addrs[*programHash]; ok {
+			return true
+		}
+	}
+
+	if len(filter.keys) == 0 {
+		return false
+	}
+	for _, key := range redeemScriptKeys(program.Code) {
+		if _, ok := filter.keys[string(key)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match the outputs and programs of the transaction
+func (filter *AddrFilter) MatchTx(txn *tx.Transaction) *TxMatch {
+	match := new(TxMatch)
+	for i, output := range txn.Outputs {
+		if filter.MatchOutput(output) {
+			match.Outputs = append(match.Outputs, i)
+		}
+	}
+	for i, program := range txn.Programs {
+		if filter.MatchProgram(program) {
+			match.Programs = append(match.Programs, i)
+		}
+	}
+	return match
+}
+
+// Get the compressed public keys pushed in the redeem script, both the standard
+// and multi-sign scripts push each public key with the opcode of it's length
+func redeemScriptKeys(code []byte) [][]byte {
+	var keys [][]byte
+	for i := 0; i < len(code); {
+		if code[i] == compressedKeyLength && i+1+compressedKeyLength <= len(code) {
+			keys = append(keys, code[i+1:i+1+compressedKeyLength])
+			i += 1 + compressedKeyLength
+			continue
+		}
+		i++
+	}
+	return keys
+}
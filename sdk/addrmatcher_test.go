@@ -0,0 +1,54 @@
+package sdk
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core/contract/program"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+)
+
+func TestAddrFilterMatchTx(t *testing.T) {
+	_, watchedKey, _ := crypto.GenerateKeyPair()
+	_, cosigner, _ := crypto.GenerateKeyPair()
+	_, otherKey, _ := crypto.GenerateKeyPair()
+
+	watchedCode, _ := tx.CreateStandardRedeemScript(watchedKey)
+	watchedHash, _ := tx.ToProgramHash(watchedCode)
+	otherCode, _ := tx.CreateStandardRedeemScript(otherKey)
+	multiSignCode, _ := tx.CreateMultiSignRedeemScript(2, []*crypto.PublicKey{watchedKey, cosigner})
+
+	filter := NewAddrFilter([]*Uint168{watchedHash})
+	txn := &tx.Transaction{
+		Outputs: []*tx.Output{{ProgramHash: Uint168{33}}, {ProgramHash: *watchedHash}},
+		Programs: []*program.Program{
+			{Code: otherCode}, {Code: watchedCode}, {Code: multiSignCode}, {},
+		},
+	}
+
+	// Multi-sign program is matched only when the public key is added
+	match := filter.MatchTx(txn)
+	if len(match.Outputs) != 1 || match.Outputs[0] != 1 {
+		t.Fatalf("matched outputs %v, expect [1]", match.Outputs)
+	}
+	if len(match.Programs) != 1 || match.Programs[0] != 1 {
+		t.Fatalf("matched programs %v, expect [1]", match.Programs)
+	}
+
+	version := filter.Version()
+	if err := filter.AddPublicKey(watchedKey); err != nil {
+		t.Fatal(err)
+	}
+	if filter.Version() == version {
+		t.Fatal("version not changed after public key added")
+	}
+	match = filter.MatchTx(txn)
+	if len(match.Programs) != 2 || match.Programs[1] != 2 {
+		t.Fatalf("matched programs %v, expect [1 2]", match.Programs)
+	}
+
+	if NewAddrFilter(nil).MatchTx(txn).IsMatched() {
+		t.Fatal("empty filter matched transaction")
+	}
+}
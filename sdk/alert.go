@@ -0,0 +1,49 @@
+package sdk
+
+import "fmt"
+
+type AlertType int
+
+const (
+	// A reorganize deeper than the max reorganize depth or below the last checkpoint was refused
+	AlertDeepReorg AlertType = iota
+
+	// A transaction of the registered addresses missed by the bloom filter was found by an audit
+	AlertAuditMismatch
+
+	// A write of the DataStore failed, the blockchain stopped committing blocks
+	AlertStorageFailure
+)
+
+func (t AlertType) String() string {
+	switch t {
+	case AlertDeepReorg:
+		return "DeepReorg"
+	case AlertAuditMismatch:
+		return "AuditMismatch"
+	case AlertStorageFailure:
+		return "StorageFailure"
+	default:
+		return fmt.Sprintf("AlertType(%d)", int(t))
+	}
+}
+
+// Alert is a critical event raised by the blockchain, it usually means the
+// connected peers are misbehaving and needs the attention of the operator
+type Alert struct {
+	Type    AlertType
+	Height  uint32
+	Message string
+}
+
+func (a *Alert) String() string {
+	return fmt.Sprintf("[%s] height %d, %s", a.Type, a.Height, a.Message)
+}
+
+/*
+AlertListener is an interface to listen critical blockchain events.
+Call Blockchain.AddAlertListener() method to register your callbacks to the notify list.
+*/
+type AlertListener interface {
+	OnAlert(alert *Alert)
+}
@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+/*
+ArbitersListener is notified when the arbiter set changed.
+Call ArbiterSet.AddListener() to register it.
+*/
+type ArbitersListener interface {
+	// The public keys of the new arbiters, sorted
+	OnArbitersChanged(arbiters [][]byte)
+}
+
+/*
+ArbiterSet is the current public key set of the DPoS arbiters, it's used to validate the
+DPoS confirms of blocks, and by the side chain arbiter rotation built on this package.
+The set is updated from a configured source, the listeners are notified on every change.
+*/
+type ArbiterSet struct {
+	lock      sync.RWMutex
+	keys      [][]byte
+	listeners []*guardedArbitersListener
+}
+
+func NewArbiterSet() *ArbiterSet {
+	return new(ArbiterSet)
+}
+
+// Register a listener of the arbiter set changes, multiple registration is supported.
+func (set *ArbiterSet) AddListener(listener ArbitersListener) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	set.listeners = append(set.listeners,
+		&guardedArbitersListener{ArbitersListener: listener, guard: newGuard("arbiters listener", listener)})
+}
+
+// Replace the arbiters with the public keys, duplicated keys are removed.
+// Return if the set changed, the listeners are notified if it did
+func (set *ArbiterSet) Set(publicKeys [][]byte) bool {
+	keys := make([][]byte, 0, len(publicKeys))
+	for _, key := range publicKeys {
+		keys = append(keys, append([]byte{}, key...))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	for i := len(keys) - 1; i > 0; i-- {
+		if bytes.Equal(keys[i], keys[i-1]) {
+			keys = append(keys[:i], keys[i+1:]...)
+		}
+	}
+
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	if equalKeys(set.keys, keys) {
+		return false
+	}
+	set.keys = keys
+	for _, listener := range set.listeners {
+		listener, keys := listener, set.copyKeys()
+		go listener.guard.Run(func() { listener.OnArbitersChanged(keys) })
+	}
+	return true
+}
+
+// Get the public keys of the arbiters, sorted
+func (set *ArbiterSet) Keys() [][]byte {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+
+	return set.copyKeys()
+}
+
+// Check if the public key is one of the arbiters
+func (set *ArbiterSet) Contains(publicKey []byte) bool {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+
+	for _, key := range set.keys {
+		if bytes.Equal(key, publicKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the count of the arbiters
+func (set *ArbiterSet) Len() int {
+	set.lock.RLock()
+	defer set.lock.RUnlock()
+
+	return len(set.keys)
+}
+
+func (set *ArbiterSet) copyKeys() [][]byte {
+	keys := make([][]byte, 0, len(set.keys))
+	for _, key := range set.keys {
+		keys = append(keys, append([]byte{}, key...))
+	}
+	return keys
+}
+
+func equalKeys(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+)
+
+type arbitersListener chan [][]byte
+
+func (l arbitersListener) OnArbitersChanged(arbiters [][]byte) {
+	l <- arbiters
+}
+
+func TestArbiterSet(t *testing.T) {
+	set := NewArbiterSet()
+	listener := make(arbitersListener, 1)
+	set.AddListener(listener)
+
+	if !set.Set([][]byte{{2}, {1}, {2}}) {
+		t.Fatal("arbiter set not changed")
+	}
+	select {
+	case arbiters := <-listener:
+		if len(arbiters) != 2 || arbiters[0][0] != 1 || arbiters[1][0] != 2 {
+			t.Error("arbiters not sorted and deduplicated,", arbiters)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listener not notified")
+	}
+	if !set.Contains([]byte{1}) || set.Contains([]byte{3}) {
+		t.Error("contains wrong arbiters")
+	}
+
+	// Same keys in different order is not a change
+	if set.Set([][]byte{{1}, {2}}) {
+		t.Error("arbiter set changed by the same keys")
+	}
+}
@@ -0,0 +1,169 @@
+package sdk
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+// Max time an audit waits for the blocks requested
+const AuditTimeout = 2 * time.Minute
+
+// AuditBlock is a block on the best chain downloaded in full by an audit
+type AuditBlock struct {
+	Block  bloom.MerkleBlock
+	Height uint32
+	// All transactions of the block in the order received
+	Txs []tx.Transaction
+	// False if the peer did not return all transactions of the block, it can not be audited
+	Complete bool
+}
+
+// An ongoing audit, blocks and transactions from the audit peer are collected here instead of the chain
+type audit struct {
+	sync.Mutex
+	peer      *p2p.Peer
+	blocks    map[Uint256]*AuditBlock
+	txs       map[Uint256]*AuditBlock
+	remaining map[*AuditBlock]int
+	done      chan struct{}
+}
+
+// A filter matches everything, so the merkleblocks carry all transactions of the blocks
+func matchAllFilter() *bloom.FilterLoad {
+	return &bloom.FilterLoad{Filter: []byte{0xff}, HashFuncs: 1}
+}
+
+/*
+Download the recent blocks on the best chain in full, to audit the transactions matched by the bloom filter.
+A filter matching everything is loaded to the best peer for the audit, and the filter of the wallet is loaded
+back when it's done. Blocks received from the peer for the audit are not committed to the chain.
+*/
+func (service *SPVServiceImpl) AuditBlocks(depth uint32) ([]*AuditBlock, error) {
+	service.auditLock.Lock()
+	defer service.auditLock.Unlock()
+
+	if service.chain.IsSyncing() {
+		return nil, errors.New("[Audit], can not audit while syncing")
+	}
+	peer := service.PeerManager().GetBestPeer()
+	if peer == nil {
+		return nil, errors.New("[Audit], no peer connected")
+	}
+
+	// Walk back from the chain tip for the blocks to download
+	var blocks []*AuditBlock
+	a := &audit{
+		peer:      peer,
+		blocks:    make(map[Uint256]*AuditBlock),
+		txs:       make(map[Uint256]*AuditBlock),
+		remaining: make(map[*AuditBlock]int),
+		done:      make(chan struct{}),
+	}
+	header := service.chain.ChainTip()
+	for i := uint32(0); i < depth && header.Height > 0; i++ {
+		block := &AuditBlock{Height: header.Height}
+		blocks = append([]*AuditBlock{block}, blocks...)
+		a.blocks[*header.Hash()] = block
+		a.remaining[block] = -1
+
+		previous, err := service.chain.GetHeader(header.Previous)
+		if err != nil {
+			break
+		}
+		header = previous
+	}
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+
+	service.auditState.Lock()
+	service.audit = a
+	service.auditState.Unlock()
+	defer func() {
+		service.auditState.Lock()
+		service.audit = nil
+		service.auditState.Unlock()
+		peer.Send(service.filterLoad(peer))
+	}()
+
+	log.Infof("Audit %d blocks from height %d with peer %d", len(blocks), blocks[0].Height, peer.ID())
+	peer.Send(matchAllFilter())
+	for hash := range a.blocks {
+		peer.Send(service.NewDataReq(BLOCK, hash))
+	}
+
+	select {
+	case <-a.done:
+		return blocks, nil
+	case <-clock.After(AuditTimeout):
+		return nil, errors.Wrapf(errors.ErrTimeout, "[Audit], blocks not received from peer %d", peer.ID())
+	}
+}
+
+// Collect the merkleblock if it's requested by the ongoing audit, return if it's collected
+func (service *SPVServiceImpl) onAuditBlock(peer *p2p.Peer, block *bloom.MerkleBlock, txIds []*Uint256) bool {
+	service.auditState.Lock()
+	a := service.audit
+	service.auditState.Unlock()
+	if a == nil || a.peer.ID() != peer.ID() {
+		return false
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	auditBlock, ok := a.blocks[*block.BlockHeader.Hash()]
+	if !ok || a.remaining[auditBlock] >= 0 {
+		return false
+	}
+	auditBlock.Block = *block
+	auditBlock.Complete = uint32(len(txIds)) == block.Transactions
+	a.remaining[auditBlock] = len(txIds)
+	for _, txId := range txIds {
+		a.txs[*txId] = auditBlock
+	}
+	a.checkDone(auditBlock)
+	return true
+}
+
+// Collect the transaction if it's in a block of the ongoing audit, return if it's collected
+func (service *SPVServiceImpl) onAuditTx(peer *p2p.Peer, txn *tx.Transaction) bool {
+	service.auditState.Lock()
+	a := service.audit
+	service.auditState.Unlock()
+	if a == nil || a.peer.ID() != peer.ID() {
+		return false
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	auditBlock, ok := a.txs[*txn.Hash()]
+	if !ok {
+		return false
+	}
+	delete(a.txs, *txn.Hash())
+	auditBlock.Txs = append(auditBlock.Txs, *txn)
+	a.remaining[auditBlock]--
+	a.checkDone(auditBlock)
+	return true
+}
+
+// Close the done channel if all blocks and their transactions are received, the lock must be held
+func (a *audit) checkDone(block *AuditBlock) {
+	if a.remaining[block] > 0 {
+		return
+	}
+	delete(a.remaining, block)
+	if len(a.remaining) == 0 {
+		close(a.done)
+	}
+}
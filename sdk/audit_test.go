@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+func TestAuditCollect(t *testing.T) {
+	peer, other := new(p2p.Peer), new(p2p.Peer)
+	peer.SetID(1)
+	other.SetID(2)
+
+	txns := make([]*tx.Transaction, 3)
+	for i := range txns {
+		txns[i] = &tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset), LockTime: uint32(i)}
+	}
+	full := &bloom.MerkleBlock{BlockHeader: core.Header{Height: 1}, Transactions: 2}
+	partial := &bloom.MerkleBlock{BlockHeader: core.Header{Height: 2}, Transactions: 3}
+
+	service := new(SPVServiceImpl)
+	service.audit = &audit{
+		peer:      peer,
+		blocks:    make(map[Uint256]*AuditBlock),
+		txs:       make(map[Uint256]*AuditBlock),
+		remaining: make(map[*AuditBlock]int),
+		done:      make(chan struct{}),
+	}
+	for _, block := range []*bloom.MerkleBlock{full, partial} {
+		auditBlock := &AuditBlock{Height: block.BlockHeader.Height}
+		service.audit.blocks[*block.BlockHeader.Hash()] = auditBlock
+		service.audit.remaining[auditBlock] = -1
+	}
+
+	// Only the blocks and transactions of the audit from the audit peer are collected
+	if service.onAuditBlock(other, full, []*Uint256{txns[0].Hash(), txns[1].Hash()}) {
+		t.Error("block from another peer collected")
+	}
+	if !service.onAuditBlock(peer, full, []*Uint256{txns[0].Hash(), txns[1].Hash()}) {
+		t.Fatal("audit block not collected")
+	}
+	if !service.onAuditBlock(peer, partial, []*Uint256{txns[2].Hash()}) {
+		t.Fatal("audit block not collected")
+	}
+	if service.onAuditBlock(peer, full, nil) {
+		t.Error("audit block collected twice")
+	}
+	if service.onAuditTx(peer, &tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset), LockTime: 100}) {
+		t.Error("transaction not in the audit blocks collected")
+	}
+	for _, txn := range txns {
+		if !service.onAuditTx(peer, txn) {
+			t.Errorf("audit transaction %d not collected", txn.LockTime)
+		}
+	}
+
+	select {
+	case <-service.audit.done:
+	default:
+		t.Fatal("audit not done after all blocks received")
+	}
+	fullBlock := service.audit.blocks[*full.BlockHeader.Hash()]
+	if !fullBlock.Complete || len(fullBlock.Txs) != 2 {
+		t.Errorf("unexpected audit block %+v", fullBlock)
+	}
+	// The peer returned only one of the three transactions
+	if service.audit.blocks[*partial.BlockHeader.Hash()].Complete {
+		t.Error("partial block is complete")
+	}
+}
@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+// Limits of the low bandwidth mode on metered connections
+const (
+	// Max blocks requested at one time
+	MeteredMaxRequests = 10
+
+	// Seconds between polling peers for new blocks
+	MeteredUpdateDuration = 60
+)
+
+/*
+Switch the service between the normal and the low bandwidth mode. On a metered connection, fewer
+blocks are downloaded at one time, peers are polled for new blocks less often, and a rescan requested
+by Resync(), ResetAndResync() or RefreshFilter() is deferred until the connection is not metered. Unconfirmed transactions
+are never requested in both modes, the local peer does not ask peers to relay them.
+*/
+func (service *SPVServiceImpl) SetMetered(metered bool) {
+	service.Lock()
+	defer service.Unlock()
+
+	if service.metered == metered {
+		return
+	}
+	service.metered = metered
+	service.queue.SetLimit(service.blocksLimit())
+	if metered {
+		log.Info("Connection metered, low bandwidth mode on")
+		return
+	}
+
+	log.Info("Connection not metered, low bandwidth mode off")
+	if service.rescanPending {
+		service.rescanPending = false
+		log.Info("Start the deferred rescan")
+		service.resync()
+	}
+}
+
+/*
+Limit the downloading to keep the memory bounded on constrained devices. maxBlocks is the max merkleblocks
+requested at one time, maxTxs the max transactions of them requested at one time, and memoryBudget the
+max bytes of the blocks and transactions downloaded but not committed yet. No more blocks are requested
+while any limit is reached, 0 means MaxRequests blocks and no limits of the others. The blocks limit of
+the low bandwidth mode applies if it's lower.
+*/
+func (service *SPVServiceImpl) SetDownloadLimits(maxBlocks, maxTxs int, memoryBudget int64) {
+	service.Lock()
+	defer service.Unlock()
+
+	service.maxBlocks = maxBlocks
+	service.queue.SetLimit(service.blocksLimit())
+	service.queue.SetBudget(maxTxs, memoryBudget)
+}
+
+// Get the max blocks requested at one time in the current mode, the service lock must be held
+func (service *SPVServiceImpl) blocksLimit() int {
+	limit := MaxRequests
+	if service.maxBlocks > 0 {
+		limit = service.maxBlocks
+	}
+	if service.metered && limit > MeteredMaxRequests {
+		limit = MeteredMaxRequests
+	}
+	return limit
+}
+
+// Check if the service is in the low bandwidth mode
+func (service *SPVServiceImpl) IsMetered() bool {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.metered
+}
+
+// Get the interval of polling peers for new blocks
+func (service *SPVServiceImpl) updateDuration() time.Duration {
+	if service.IsMetered() {
+		return time.Second * MeteredUpdateDuration
+	}
+	return time.Second * p2p.InfoUpdateDuration
+}
@@ -0,0 +1,32 @@
+package sdk
+
+import (
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+)
+
+// Seconds a block timestamp can be earlier than the real time it's mined,
+// blocks earlier than the birthday minus the margin are before the birthday
+const BirthdayMargin = 24 * 60 * 60
+
+/*
+Set the birthday of the wallet, the unix time the wallet created. Blocks before the birthday have
+no transactions of the wallet, they are synced as headers only, the transactions matched by the
+filter are neither requested nor committed, which speeds up the first sync of a new wallet a lot.
+Set it to 0 to sync all the blocks with transactions.
+*/
+func (service *SPVServiceImpl) SetBirthday(timestamp uint32) {
+	atomic.StoreUint32(&service.birthday, timestamp)
+}
+
+// Get the birthday of the wallet, 0 means unknown
+func (service *SPVServiceImpl) Birthday() uint32 {
+	return atomic.LoadUint32(&service.birthday)
+}
+
+// Check if the block is before the birthday of the wallet
+func (service *SPVServiceImpl) beforeBirthday(block *bloom.MerkleBlock) bool {
+	birthday := service.Birthday()
+	return birthday > BirthdayMargin && block.BlockHeader.Timestamp < birthday-BirthdayMargin
+}
@@ -1,17 +1,21 @@
 package sdk
 
 import (
-	"errors"
 	"math/big"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	"github.com/elastos/Elastos.ELA.SPV/core"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/verifier"
 )
 
 type ChainState int
@@ -23,9 +27,22 @@ const (
 
 const (
 	MaxBlockLocatorHashes = 100
+
+	// Max seconds a block timestamp can be ahead of the network adjusted time
+	MaxTimeOffsetSeconds = 2 * 60 * 60
 )
 
-var PowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+var PowLimit = verifier.PowLimit
+
+// Returned by CommitBlock when the block triggers a reorganize deeper than the
+// max reorganize depth or rolling back the last checkpoint
+var ErrReorgRefused = errors.New("[Blockchain], reorganize refused")
+
+// Returned by ConfirmBlock when the block is not committed yet
+var ErrBlockNotFound = errors.Wrap(errors.ErrNotFound, "[Blockchain], block not found")
+
+// Checkpoint is a block trusted to be on the best chain, the blockchain never reorganizes below it
+type Checkpoint = verifier.Checkpoint
 
 /*
 StateListener is an interface to listen blockchain data change.
@@ -53,21 +70,126 @@ type Blockchain struct {
 	lock           *sync.RWMutex
 	state          ChainState
 	db.DataStore
-	stateListeners []StateListener
+	stateListeners []*guardedStateListener
+	alertListeners []*guardedAlertListener
+	rawSubscribers []*rawSubscriber
+	maxReorgDepth  uint32
+	checkpoints    []Checkpoint
+	rules          Rules
+	genesis        *core.Header
+	consensus      Consensus
+	confirmed      *Checkpoint
+	snapshot       *db.ChainSnapshot
+	failure        error
 }
 
 // Create a instance of *Blockchain
 func NewBlockchain(dataStore db.DataStore) (*Blockchain, error) {
-	return &Blockchain{
+	bc := &Blockchain{
 		lock:      new(sync.RWMutex),
 		state:     WAITING,
 		DataStore: dataStore,
-	}, nil
+		consensus: MainChainConsensus{},
+	}
+	bc.loadSnapshot()
+	return bc, nil
 }
 
 // Register a blockchain state listener, multiple registration is supported.
 func (bc *Blockchain) AddStateListener(listener StateListener) {
-	bc.stateListeners = append(bc.stateListeners, listener)
+	bc.stateListeners = append(bc.stateListeners,
+		&guardedStateListener{StateListener: listener, guard: newGuard("state listener", listener)})
+}
+
+// Register a critical alert listener, multiple registration is supported.
+func (bc *Blockchain) AddAlertListener(listener AlertListener) {
+	bc.alertListeners = append(bc.alertListeners,
+		&guardedAlertListener{AlertListener: listener, guard: newGuard("alert listener", listener)})
+}
+
+// Set the max blocks can be wiped out by a reorganize, deeper reorganizes are refused, 0 means no limit
+func (bc *Blockchain) SetMaxReorgDepth(depth uint32) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.maxReorgDepth = depth
+}
+
+// Set the checkpoints, a reorganize rolling back the last checkpoint under the chain tip is refused
+func (bc *Blockchain) SetCheckpoints(checkpoints ...Checkpoint) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.checkpoints = append([]Checkpoint{}, checkpoints...)
+	sort.Slice(bc.checkpoints, func(i, j int) bool {
+		return bc.checkpoints[i].Height < bc.checkpoints[j].Height
+	})
+}
+
+// Set the rule table consulted by the header and transaction validation
+func (bc *Blockchain) SetRules(rules Rules) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.rules = rules
+}
+
+// Set the consensus checking the headers, like the SideChainConsensus to follow a sidechain
+func (bc *Blockchain) SetConsensus(consensus Consensus) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.consensus = consensus
+}
+
+// Set the genesis header, the first block committed must extend it and the sync of an empty
+// blockchain starts from it, nil means any first block accepted
+func (bc *Blockchain) SetGenesis(genesis *core.Header) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.genesis = genesis
+}
+
+// Mark the block confirmed by the DPoS arbiters irreversible, the blockchain never reorganizes below it.
+// Return ErrBlockNotFound if the block is not committed yet
+func (bc *Blockchain) ConfirmBlock(hash Uint256) error {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	header, err := bc.GetHeader(hash)
+	if err != nil {
+		return ErrBlockNotFound
+	}
+	if bc.confirmed != nil && header.Height <= bc.confirmed.Height {
+		return nil
+	}
+
+	// The block must be on the best chain
+	tip := bc.chainTip()
+	for tip.Height > header.Height {
+		if tip, err = bc.GetPrevious(tip); err != nil {
+			return err
+		}
+	}
+	if !tip.Hash().IsEqual(&hash) {
+		return errors.Wrapf(errors.ErrInvalid, "[Blockchain], confirmed block %s is not on the best chain", hash.String())
+	}
+
+	bc.confirmed = &Checkpoint{Height: header.Height, Hash: hash}
+	log.Info("Block confirmed by arbiters, height: ", header.Height)
+	return nil
+}
+
+// Get the height of the last block confirmed by the DPoS arbiters, 0 if none
+func (bc *Blockchain) ConfirmedHeight() uint32 {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	if bc.confirmed == nil {
+		return 0
+	}
+	return bc.confirmed.Height
 }
 
 // Close the blockchain
@@ -123,10 +245,18 @@ func (bc *Blockchain) GetBlockLocatorHashes() []*Uint256 {
 
 	var ret []*Uint256
 	parent, err := bc.GetChainTip()
-	if err != nil { // No headers stored return empty locator
+	if err != nil { // No headers stored return empty locator, or the genesis hash if set
+		if bc.genesis != nil {
+			ret = append(ret, bc.genesis.Hash())
+		}
 		return ret
 	}
 
+	// Use the locator in snapshot, it's not on the chain tip only if the data store has been changed
+	if locator := bc.snapshotLocator(parent.Hash()); locator != nil {
+		return locator
+	}
+
 	rollback := func(parent *db.StoreHeader, n int) (*db.StoreHeader, error) {
 		for i := 0; i < n; i++ {
 			parent, err = bc.GetPrevious(parent)
@@ -163,6 +293,13 @@ func (bc *Blockchain) CommitTx(tx tx.Transaction) (bool, error) {
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 
+	if bc.failure != nil {
+		return false, bc.failure
+	}
+	// Unconfirmed transaction is validated with the rules of the next block
+	if err := bc.rules.CheckTransaction(&tx, bc.chainTip().Height+1); err != nil {
+		return false, err
+	}
 	return bc.commitTx(tx, 0)
 }
 
@@ -171,7 +308,12 @@ func (bc *Blockchain) CommitBlock(block bloom.MerkleBlock, txs []tx.Transaction)
 	bc.lock.Lock()
 	defer bc.lock.Unlock()
 
+	if bc.failure != nil {
+		return false, 0, bc.failure
+	}
+	start := clock.Now()
 	header := block.BlockHeader
+	headerHash := header.Hash()
 	commitHeader := &db.StoreHeader{Header: header}
 
 	// Get current chain tip
@@ -190,9 +332,13 @@ func (bc *Blockchain) CommitBlock(block bloom.MerkleBlock, txs []tx.Transaction)
 		if err != nil {
 			// If committing header is genesis header, make an empty parent header
 			if commitHeader.Height == 1 {
+				if bc.genesis != nil && !header.Previous.IsEqual(bc.genesis.Hash()) {
+					return false, 0, errors.Wrapf(errors.ErrPeerMisbehaving, "[Blockchain], block %s does not extend the genesis %s",
+						headerHash.String(), bc.genesis.Hash().String())
+				}
 				parentHeader = &db.StoreHeader{TotalWork: new(big.Int)}
 			} else {
-				return false, 0, fmt.Errorf("Header %s does not extend any known headers", header.Hash().String())
+				return false, 0, fmt.Errorf("Header %s does not extend any known headers", headerHash.String())
 			}
 		}
 	}
@@ -200,9 +346,22 @@ func (bc *Blockchain) CommitBlock(block bloom.MerkleBlock, txs []tx.Transaction)
 	log.Debug("Find parent header height: ", parentHeader.Height)
 
 	// If this block is already the tip, return
-	if tipHash.IsEqual(header.Hash()) {
+	if tipHash.IsEqual(headerHash) {
 		return false, 0, nil
 	}
+
+	// Validate the header and transactions with the rules activated at the block height
+	if err := CheckTimestamp(&header); err != nil {
+		return false, 0, err
+	}
+	if err := bc.rules.CheckHeader(&header); err != nil {
+		return false, 0, err
+	}
+	for i := range txs {
+		if err := bc.rules.CheckTransaction(&txs[i], header.Height); err != nil {
+			return false, 0, err
+		}
+	}
 	// Add the work of this header to the total work stored at the previous header
 	cumulativeWork := new(big.Int).Add(parentHeader.TotalWork, CalcWork(header.Bits))
 	commitHeader.TotalWork = cumulativeWork
@@ -221,6 +380,9 @@ func (bc *Blockchain) CommitBlock(block bloom.MerkleBlock, txs []tx.Transaction)
 				log.Errorf("error calculating common ancestor: %s", err.Error())
 				return false, 0, err
 			}
+			if err = bc.checkReorg(tip, reorgPoint); err != nil {
+				return false, 0, err
+			}
 			fmt.Printf("Reorganize At block %d, Wiped out %d blocks\n",
 				int(tip.Height), int(tip.Height-reorgPoint.Height))
 		}
@@ -232,23 +394,25 @@ func (bc *Blockchain) CommitBlock(block bloom.MerkleBlock, txs []tx.Transaction)
 		log.Warn("Meet reorganize rollback to: ", reorgPoint.Height)
 		err := bc.rollbackTo(reorgPoint.Height)
 		if err != nil {
-			fmt.Println(err)
+			return reorg, 0, bc.fail(tip.Height, err)
 		}
 		// Save reorganize point as the new tip
 		err = bc.PutHeader(reorgPoint, newTip)
 		if err != nil {
-			return reorg, 0, err
+			return reorg, 0, bc.fail(reorgPoint.Height, err)
 		}
+		bc.updateSnapshot(reorgPoint, false)
 		return true, 0, nil
 	}
 
 	fPositives := 0
+	summary := &BlockSummary{Height: header.Height, Hash: *headerHash}
 	if newTip {
 		// Save transactions
 		for _, tx := range txs {
-			fPositive, err := bc.commitTx(tx, header.Height)
+			fPositive, err := bc.commitBlockTx(tx, summary)
 			if err != nil {
-				return reorg, 0, err
+				return reorg, 0, bc.fail(header.Height, err)
 			}
 			if fPositive {
 				fPositives++
@@ -258,21 +422,73 @@ func (bc *Blockchain) CommitBlock(block bloom.MerkleBlock, txs []tx.Transaction)
 		bc.DataStore.PutChainHeight(header.Height)
 	}
 
-	log.Debug("Commit header: ", commitHeader.Hash().String(), ", newTip: ", newTip)
+	log.Debug("Commit header: ", headerHash, ", newTip: ", newTip)
 	// Save header to db
 	err = bc.PutHeader(commitHeader, newTip)
 	if err != nil {
-		return reorg, 0, err
+		return reorg, 0, bc.fail(header.Height, err)
+	}
+	if newTip {
+		bc.updateSnapshot(commitHeader, header.Previous.IsEqual(tipHash))
 	}
 
 	// Notify block committed
 	bc.notifyBlockCommitted(block, txs)
+	if newTip {
+		bc.notifyRawBlock(&block, txs, header.Height)
+		summary.Duration = clock.Since(start)
+		bc.notifyBlockProcessed(summary)
+	}
 
 	log.Debug("Blockchain block committed height: ", bc.chainTip().Height)
 
 	return reorg, fPositives, nil
 }
 
+// Check if the reorganize from tip to the fork point is allowed, raise an alert if not
+func (bc *Blockchain) checkReorg(tip, forkPoint *db.StoreHeader) error {
+	depth := tip.Height - forkPoint.Height
+	if bc.maxReorgDepth > 0 && depth > bc.maxReorgDepth {
+		bc.notifyAlert(&Alert{
+			Type:   AlertDeepReorg,
+			Height: tip.Height,
+			Message: fmt.Sprintf("refused reorganize wiping out %d blocks from height %d, max depth %d",
+				depth, forkPoint.Height+1, bc.maxReorgDepth),
+		})
+		return ErrReorgRefused
+	}
+
+	// The block confirmed by the arbiters is irreversible
+	if bc.confirmed != nil && forkPoint.Height < bc.confirmed.Height {
+		bc.notifyAlert(&Alert{
+			Type:   AlertDeepReorg,
+			Height: tip.Height,
+			Message: fmt.Sprintf("refused reorganize from height %d below confirmed block %d %s",
+				forkPoint.Height+1, bc.confirmed.Height, bc.confirmed.Hash.String()),
+		})
+		return ErrReorgRefused
+	}
+
+	// Find the last checkpoint on the current chain
+	for i := len(bc.checkpoints) - 1; i >= 0; i-- {
+		checkpoint := bc.checkpoints[i]
+		if checkpoint.Height > tip.Height {
+			continue
+		}
+		if forkPoint.Height < checkpoint.Height {
+			bc.notifyAlert(&Alert{
+				Type:   AlertDeepReorg,
+				Height: tip.Height,
+				Message: fmt.Sprintf("refused reorganize from height %d below checkpoint %d %s",
+					forkPoint.Height+1, checkpoint.Height, checkpoint.Hash.String()),
+			})
+			return ErrReorgRefused
+		}
+		break
+	}
+	return nil
+}
+
 func (bc *Blockchain) commitTx(tx tx.Transaction, height uint32) (bool, error) {
 	fPositive, err := bc.DataStore.CommitTx(db.NewStoreTx(tx, height))
 	if err != nil {
@@ -295,6 +511,7 @@ func (bc *Blockchain) rollbackTo(forkPoint uint32) error {
 		}
 		bc.notifyChainRollback(height)
 	}
+	bc.notifyRawRollback(forkPoint)
 	// Save current chain height
 	bc.DataStore.PutChainHeight(forkPoint)
 
@@ -347,97 +564,65 @@ func (bc *Blockchain) getCommonAncestor(bestHeader, prevTip *db.StoreHeader) (*d
 
 func (bc *Blockchain) notifyBlockCommitted(block bloom.MerkleBlock, txs []tx.Transaction) {
 	for _, listener := range bc.stateListeners {
-		go listener.OnBlockCommitted(block, txs)
+		listener := listener
+		go listener.guard.Run(func() { listener.OnBlockCommitted(block, txs) })
 	}
 }
 
 func (bc *Blockchain) notifyTxCommitted(tx tx.Transaction, height uint32) {
 	for _, listener := range bc.stateListeners {
-		go listener.OnTxCommitted(tx, height)
+		listener := listener
+		go listener.guard.Run(func() { listener.OnTxCommitted(tx, height) })
+	}
+}
+
+// Raise an alert found out of the blockchain, like a mismatch found by an audit
+func (bc *Blockchain) RaiseAlert(alert *Alert) {
+	bc.notifyAlert(alert)
+}
+
+func (bc *Blockchain) notifyAlert(alert *Alert) {
+	log.Error("Blockchain alert ", alert)
+	for _, listener := range bc.alertListeners {
+		listener := listener
+		go listener.guard.Run(func() { listener.OnAlert(alert) })
 	}
 }
 
 func (bc *Blockchain) notifyChainRollback(height uint32) {
 	for _, listener := range bc.stateListeners {
-		go listener.OnChainRollback(height)
+		listener := listener
+		go listener.guard.Run(func() { listener.OnChainRollback(height) })
 	}
 }
 
 func CalcWork(bits uint32) *big.Int {
-	// Return a work value of zero if the passed difficulty bits represent
-	// a negative number. Note this should not happen in practice with valid
-	// blocks, but an invalid block could trigger it.
-	difficultyNum := CompactToBig(bits)
-	if difficultyNum.Sign() <= 0 {
-		return big.NewInt(0)
-	}
-
-	// (1 << 256) / (difficultyNum + 1)
-	denominator := new(big.Int).Add(difficultyNum, big.NewInt(1))
-	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), denominator)
+	return verifier.CalcWork(bits)
 }
 
+// Check the consensus proof of the header by the consensus set, the main chain proof of work by default
 func (bc *Blockchain) CheckProofOfWork(header *core.Header) error {
-	// The target difficulty must be larger than zero.
-	target := CompactToBig(header.Bits)
-	if target.Sign() <= 0 {
-		return errors.New("[Blockchain], block target difficulty is too low.")
-	}
-
-	// The target difficulty must be less than the maximum allowed.
-	if target.Cmp(PowLimit) > 0 {
-		return errors.New("[Blockchain], block target difficulty is higher than max of limit.")
-	}
-
-	// The block hash must be less than the claimed target.
-	var hash Uint256
+	bc.lock.RLock()
+	consensus := bc.consensus
+	bc.lock.RUnlock()
 
-	hash = header.AuxPow.ParBlockHeader.Hash()
+	return consensus.CheckProofOfWork(header)
+}
 
-	hashNum := HashToBig(&hash)
-	if hashNum.Cmp(target) > 0 {
-		return errors.New("[Blockchain], block target difficulty is higher than expected difficulty.")
+// Check the header timestamp is not too far ahead of the network adjusted time
+func CheckTimestamp(header *core.Header) error {
+	maxTime := clock.Adjusted.Now().Add(time.Second * MaxTimeOffsetSeconds)
+	if int64(header.Timestamp) > maxTime.Unix() {
+		return errors.Wrapf(errors.ErrInvalid, "[Blockchain], block timestamp %d too far in the future, max %d",
+			header.Timestamp, maxTime.Unix())
 	}
-
 	return nil
 }
 
 func HashToBig(hash *Uint256) *big.Int {
-	// A Hash is in little-endian, but the big package wants the bytes in
-	// big-endian, so reverse them.
-	buf := *hash
-	blen := len(buf)
-	for i := 0; i < blen/2; i++ {
-		buf[i], buf[blen-1-i] = buf[blen-1-i], buf[i]
-	}
-
-	return new(big.Int).SetBytes(buf[:])
+	return verifier.HashToBig(hash)
 }
 
 func CompactToBig(compact uint32) *big.Int {
-	// Extract the mantissa, sign bit, and exponent.
-	mantissa := compact & 0x007fffff
-	isNegative := compact&0x00800000 != 0
-	exponent := uint(compact >> 24)
-
-	// Since the base for the exponent is 256, the exponent can be treated
-	// as the number of bytes to represent the full 256-bit number.  So,
-	// treat the exponent as the number of bytes and shift the mantissa
-	// right or left accordingly.  This is equivalent to:
-	// N = mantissa * 256^(exponent-3)
-	var bn *big.Int
-	if exponent <= 3 {
-		mantissa >>= 8 * (3 - exponent)
-		bn = big.NewInt(int64(mantissa))
-	} else {
-		bn = big.NewInt(int64(mantissa))
-		bn.Lsh(bn, 8*(exponent-3))
-	}
-
-	// Make it negative if the sign bit is set.
-	if isNegative {
-		bn = bn.Neg(bn)
-	}
-
-	return bn
+	return verifier.CompactToBig(compact)
 }
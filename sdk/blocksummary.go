@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"time"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+)
+
+// BlockSummary is the summary of a block committed on the best chain, to feed the dashboards
+type BlockSummary struct {
+	Height uint32
+	Hash   Uint256
+	// Transactions of the block matched the wallet, the false positives are not counted
+	Txs int
+	// Values of the wallet outputs created and spent by the block,
+	// always 0 if the DataStore does not implement db.OutputValues
+	Received Fixed64
+	Spent    Fixed64
+	// Time the block took to be verified and committed
+	Duration time.Duration
+}
+
+/*
+BlockSummaryListener is the optional interface of a StateListener, a listener registered by AddStateListener()
+implementing it is also notified by OnBlockProcessed() with the summary of each block committed on the best chain.
+*/
+type BlockSummaryListener interface {
+	OnBlockProcessed(summary *BlockSummary)
+}
+
+// Commit a transaction of the block, the values of the wallet outputs it spent and created are added to the summary
+func (bc *Blockchain) commitBlockTx(txn tx.Transaction, summary *BlockSummary) (bool, error) {
+	values, ok := bc.DataStore.(db.OutputValues)
+
+	// The spent outputs are looked up before they are spent by the commit
+	var spent Fixed64
+	if ok {
+		for _, input := range txn.Inputs {
+			if value, ok := values.GetOutputValue(tx.NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex)); ok {
+				spent += value
+			}
+		}
+	}
+
+	fPositive, err := bc.commitTx(txn, summary.Height)
+	if err != nil || fPositive {
+		return fPositive, err
+	}
+
+	summary.Txs++
+	summary.Spent += spent
+	if ok {
+		txId := *txn.Hash()
+		for index := range txn.Outputs {
+			if value, ok := values.GetOutputValue(tx.NewOutPoint(txId, uint16(index))); ok {
+				summary.Received += value
+			}
+		}
+	}
+	return false, nil
+}
+
+func (bc *Blockchain) notifyBlockProcessed(summary *BlockSummary) {
+	for _, listener := range bc.stateListeners {
+		listener := listener
+		if summaryListener, ok := listener.StateListener.(BlockSummaryListener); ok {
+			go listener.guard.Run(func() { summaryListener.OnBlockProcessed(summary) })
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package sdk
 
 import (
-	"errors"
 	"sync"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
 type BlockTxsRequest struct {
@@ -15,6 +15,8 @@ type BlockTxsRequest struct {
 	Block          bloom.MerkleBlock
 	txRequestQueue map[Uint256]*Request
 	Txs            []tx.Transaction
+	// bytes of the block and transactions received
+	size int64
 }
 
 func (req *BlockTxsRequest) Finish() {
@@ -33,12 +35,12 @@ func (req *BlockTxsRequest) OnTxReceived(tx *tx.Transaction) (bool, error) {
 	var ok bool
 	var txRequest *Request
 	if txRequest, ok = req.txRequestQueue[txId]; !ok {
-		return false, errors.New("Received transaction not belong to block: " +
-			req.Block.BlockHeader.Hash().String() + ", tx: " + tx.Hash().String())
+		return false, errors.Wrap(errors.ErrPeerMisbehaving, "Received transaction not belong to block: "+
+			req.Block.BlockHeader.Hash().String()+", tx: "+tx.Hash().String())
 	}
 
 	// Remove from map
-	txRequest.Finish()
+	txRequest.Received()
 	delete(req.txRequestQueue, txId)
 
 	req.Txs = append(req.Txs, *tx)
@@ -9,7 +9,7 @@ import (
 // Create a new bloom filter instance
 // elements are how many elements will be added to this filter.
 func NewBloomFilter(elements uint32) *bloom.Filter {
-	return bloom.NewFilter(elements, 0, 0.00003)
+	return bloom.NewFilter(elements, 0, DefaultFPRate)
 }
 
 // Build a bloom filter by giving the interested addresses and outpoints
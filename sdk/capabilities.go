@@ -0,0 +1,53 @@
+package sdk
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+const (
+	// Capabilities assumed of a peer not advertising them, the SPV service flag in the version
+	// message means it serves the bloom filtered blocks, a request it doesn't have times out
+	LegacyCapabilities = msg.CapBloomFilter
+
+	// Capabilities of this client advertised to the peers
+	LocalCapabilities = msg.CapBloomFilter | msg.CapNotFound
+)
+
+// Get the protocol version both this client and the peer support
+func negotiatedVersion(local, peer uint32) uint32 {
+	if peer < local {
+		return peer
+	}
+	return local
+}
+
+/*
+Start the capabilities exchange with the established peer, the peer responds with its own capabilities
+if the negotiated protocol version supports it. The legacy capabilities are assumed until then, or all
+along for a peer of an older version, so the client degrades to the features every SPV peer serves.
+*/
+func (client *SPVClientImpl) exchangeCapabilities(peer *p2p.Peer) {
+	peer.SetCapabilities(LegacyCapabilities)
+	if negotiatedVersion(client.PeerManager().Local().Version(), peer.Version()) < CapabilitiesVersion {
+		return
+	}
+	peer.Send(&msg.Capabilities{Capabilities: LocalCapabilities, FilterVersion: msg.FilterVersion})
+}
+
+// Record the capabilities advertised by the peer, a peer not serving the bloom filter of
+// this client's filter version can not sync the wallet and is disconnected
+func (client *SPVClientImpl) OnCapabilities(peer *p2p.Peer, caps *msg.Capabilities) error {
+	capabilities := caps.Capabilities
+	if caps.FilterVersion < msg.FilterVersion {
+		capabilities &^= msg.CapBloomFilter
+	}
+	peer.SetCapabilities(capabilities)
+
+	if capabilities&msg.CapBloomFilter == 0 {
+		log.Warnf("Peer %s does not serve bloom filter version %d, disconnect it", peer.Addr(), msg.FilterVersion)
+		client.PeerManager().DisconnectPeer(peer)
+	}
+	return nil
+}
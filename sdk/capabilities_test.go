@@ -0,0 +1,36 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+func TestNegotiatedVersion(t *testing.T) {
+	if v := negotiatedVersion(CapabilitiesVersion, ProtocolVersion); v != ProtocolVersion {
+		t.Errorf("negotiated version %d with an older peer, expect %d", v, ProtocolVersion)
+	}
+	if v := negotiatedVersion(CapabilitiesVersion, CapabilitiesVersion+1); v != CapabilitiesVersion {
+		t.Errorf("negotiated version %d with a newer peer, expect %d", v, CapabilitiesVersion)
+	}
+}
+
+func TestOnCapabilities(t *testing.T) {
+	client := new(SPVClientImpl)
+	peer := new(p2p.Peer)
+	peer.SetCapabilities(LegacyCapabilities)
+
+	// The advertised capabilities replace the legacy ones
+	caps := &msg.Capabilities{Capabilities: msg.CapBloomFilter | msg.CapCompactFilter | msg.CapNotFound,
+		FilterVersion: msg.FilterVersion}
+	if err := client.OnCapabilities(peer, caps); err != nil {
+		t.Fatal("handle capabilities error,", err)
+	}
+	if peer.Capabilities() != caps.Capabilities {
+		t.Errorf("peer capabilities %b, expect %b", peer.Capabilities(), caps.Capabilities)
+	}
+	if peer.Capabilities()&msg.CapNotFound == 0 {
+		t.Error("notfound capability not recorded")
+	}
+}
@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"bytes"
+
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	"github.com/elastos/Elastos.ELA.SPV/crypto/ecc"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+// Verify the DPoS confirm is proposed by one of the arbiters, and accepted by
+// more than 2/3 of the arbiters with valid signatures
+func VerifyConfirm(confirm *msg.Confirm, arbiters [][]byte) error {
+	if len(arbiters) == 0 {
+		return errors.New("[Confirm], no arbiters to verify confirm")
+	}
+	isArbiter := func(publicKey []byte) bool {
+		for _, arbiter := range arbiters {
+			if bytes.Equal(arbiter, publicKey) {
+				return true
+			}
+		}
+		return false
+	}
+
+	proposal := &confirm.Proposal
+	if !isArbiter(proposal.Sponsor) {
+		return errors.Wrap(errors.ErrInvalid, "[Confirm], proposal sponsor is not an arbiter")
+	}
+	buf := new(bytes.Buffer)
+	proposal.SerializeUnsigned(buf)
+	if err := verifySign(proposal.Sponsor, buf.Bytes(), proposal.Sign); err != nil {
+		return errors.Wrap(errors.ErrInvalid, "[Confirm], invalid proposal signature, "+err.Error())
+	}
+
+	proposalHash := proposal.Hash()
+	signers := make(map[string]bool)
+	for _, vote := range confirm.Votes {
+		if !vote.Accept || !vote.ProposalHash.IsEqual(&proposalHash) ||
+			!isArbiter(vote.Signer) || signers[string(vote.Signer)] {
+			continue
+		}
+		buf := new(bytes.Buffer)
+		vote.SerializeUnsigned(buf)
+		if err := verifySign(vote.Signer, buf.Bytes(), vote.Sign); err != nil {
+			return errors.Wrap(errors.ErrInvalid, "[Confirm], invalid vote signature, "+err.Error())
+		}
+		signers[string(vote.Signer)] = true
+	}
+
+	if len(signers) <= len(arbiters)*2/3 {
+		return errors.Wrapf(errors.ErrInvalid, "[Confirm], accepted by %d of %d arbiters, supermajority not reached",
+			len(signers), len(arbiters))
+	}
+	return nil
+}
+
+func verifySign(publicKey, data, signature []byte) error {
+	key, err := crypto.DecodePoint(publicKey)
+	if err != nil {
+		return err
+	}
+	return ecc.Verify(key, data, signature)
+}
+
+// Set the public keys of the DPoS arbiters, confirms of blocks are not validated nor trusted if not set
+func (service *SPVServiceImpl) SetArbiters(publicKeys [][]byte) {
+	if service.arbiters.Set(publicKeys) {
+		log.Info("Arbiters changed, count: ", service.arbiters.Len())
+	}
+}
+
+// Get the arbiter set, register an ArbitersListener to it to be notified of the changes
+func (service *SPVServiceImpl) Arbiters() *ArbiterSet {
+	return service.arbiters
+}
+
+// A block confirmed by the supermajority of the arbiters is irreversible, the confirm
+// may arrive before the block, it's kept until the block committed
+func (service *SPVServiceImpl) OnConfirm(peer *p2p.Peer, confirm *msg.Confirm) error {
+	service.confirmLock.Lock()
+	defer service.confirmLock.Unlock()
+
+	blockHash := confirm.Proposal.BlockHash
+	arbiters := service.arbiters.Keys()
+	if len(arbiters) == 0 {
+		log.Debug("Ignore confirm of block ", blockHash.String(), ", no arbiters set")
+		return nil
+	}
+	if err := VerifyConfirm(confirm, arbiters); err != nil {
+		return err
+	}
+
+	err := service.chain.ConfirmBlock(blockHash)
+	if err == ErrBlockNotFound {
+		service.pendingConfirm = &blockHash
+		return nil
+	}
+	return err
+}
+
+// Apply the confirm received before the block, call it after blocks committed
+func (service *SPVServiceImpl) applyPendingConfirm() {
+	service.confirmLock.Lock()
+	defer service.confirmLock.Unlock()
+
+	if service.pendingConfirm == nil {
+		return
+	}
+	err := service.chain.ConfirmBlock(*service.pendingConfirm)
+	if err == ErrBlockNotFound {
+		return
+	}
+	if err != nil {
+		log.Warn("Confirm block failed, ", err)
+	}
+	service.pendingConfirm = nil
+}
@@ -0,0 +1,70 @@
+package sdk
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	"github.com/elastos/Elastos.ELA.SPV/crypto/ecc"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+)
+
+func TestVerifyConfirm(t *testing.T) {
+	var privateKeys, arbiters [][]byte
+	for i := 0; i < 4; i++ {
+		privateKey, publicKey, err := crypto.GenerateKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := publicKey.EncodePoint(true)
+		privateKeys = append(privateKeys, privateKey)
+		arbiters = append(arbiters, encoded)
+	}
+	sign := func(privateKey []byte, serialize func(w *bytes.Buffer)) []byte {
+		buf := new(bytes.Buffer)
+		serialize(buf)
+		signature, err := ecc.Sign(privateKey, buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+
+	confirm := new(msg.Confirm)
+	confirm.Proposal = msg.DPoSProposal{Sponsor: arbiters[0], BlockHash: Uint256{1}}
+	confirm.Proposal.Sign = sign(privateKeys[0], func(w *bytes.Buffer) { confirm.Proposal.SerializeUnsigned(w) })
+	vote := func(i int) {
+		vote := msg.DPoSVote{ProposalHash: confirm.Proposal.Hash(), Signer: arbiters[i], Accept: true}
+		vote.Sign = sign(privateKeys[i], func(w *bytes.Buffer) { vote.SerializeUnsigned(w) })
+		confirm.Votes = append(confirm.Votes, vote)
+	}
+
+	// 2 of 4 arbiters, and a duplicated vote
+	vote(0)
+	vote(1)
+	vote(1)
+	if err := VerifyConfirm(confirm, arbiters); err == nil {
+		t.Error("confirm accepted without supermajority")
+	}
+
+	// 3 of 4 arbiters, through the message serialization
+	vote(2)
+	body, err := confirm.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(msg.Confirm)
+	if err := decoded.Deserialize(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyConfirm(decoded, arbiters); err != nil {
+		t.Error("confirm rejected,", err)
+	}
+
+	// Signature of a different block
+	decoded.Proposal.BlockHash = Uint256{2}
+	if err := VerifyConfirm(decoded, arbiters); err == nil {
+		t.Error("confirm accepted with invalid proposal signature")
+	}
+}
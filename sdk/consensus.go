@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/verifier"
+)
+
+/*
+Consensus checks the consensus proof of the headers. It's set to the blockchain by the network
+params, so the same client follows the main chain or a sidechain, and a chain with other consensus
+rules can inject it's own.
+*/
+type Consensus interface {
+	// Check the consensus proof of the header, the error should be of ErrPeerMisbehaving
+	CheckProofOfWork(header *core.Header) error
+}
+
+// MainChainConsensus is the proof of work of the main chain, merge mined with bitcoin
+type MainChainConsensus struct{}
+
+func (MainChainConsensus) CheckProofOfWork(header *core.Header) error {
+	return misbehaving(verifier.CheckMainChainPoW(header))
+}
+
+// SideChainConsensus is the proof of work of a sidechain, merge mined with the main chain
+type SideChainConsensus struct{}
+
+func (SideChainConsensus) CheckProofOfWork(header *core.Header) error {
+	return misbehaving(verifier.CheckSideChainPoW(header))
+}
+
+// Check the proof of work hash meets the target difficulty bits
+func checkTarget(bits uint32, hash Uint256) error {
+	return misbehaving(verifier.CheckTarget(bits, hash))
+}
+
+// A header failed the verification is sent by a misbehaving peer
+func misbehaving(err error) error {
+	if err != nil {
+		return errors.Wrap(errors.ErrPeerMisbehaving, "[Blockchain], "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package sdk
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/core/auxpow"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// The easiest difficulty bits, a hash meets it by chance of one half
+const easyBits = 0x207fffff
+
+// Mine a sidechain header merge mined by a main chain block with only the side mining transaction
+func mineSideHeader(height uint32) *core.Header {
+	header := &core.Header{Bits: easyBits, Height: height}
+	sideMining := tx.Transaction{
+		TxType:  tx.SideMining,
+		Payload: &payload.SideMining{SideBlockHash: *header.Hash()},
+	}
+	mainHeader := core.Header{Bits: easyBits, MerkleRoot: *sideMining.Hash()}
+	mainHeader.AuxPow.ParBlockHeader = auxpow.BtcHeader{MerkleRoot: *mainHeader.Hash(), Bits: easyBits}
+	for {
+		hash := mainHeader.AuxPow.ParBlockHeader.Hash()
+		if checkTarget(easyBits, hash) == nil {
+			break
+		}
+		mainHeader.AuxPow.ParBlockHeader.Nonce++
+	}
+
+	header.SideAuxPow = &core.SideAuxPow{SideAuxBlockTx: sideMining, MainBlockHeader: mainHeader}
+	return header
+}
+
+func TestSideChainConsensus(t *testing.T) {
+	header := mineSideHeader(1)
+	if err := (SideChainConsensus{}).CheckProofOfWork(header); err != nil {
+		t.Fatal("sidechain header rejected,", err)
+	}
+
+	// The side mining transaction commits another block
+	other := mineSideHeader(2)
+	header.SideAuxPow = other.SideAuxPow
+	if err := (SideChainConsensus{}).CheckProofOfWork(header); !errors.Is(err, errors.ErrPeerMisbehaving) {
+		t.Errorf("unexpected error %v of the side aux pow of another block", err)
+	}
+	header.SideAuxPow = nil
+	if err := (SideChainConsensus{}).CheckProofOfWork(header); !errors.Is(err, errors.ErrPeerMisbehaving) {
+		t.Errorf("unexpected error %v of the header without side aux pow", err)
+	}
+}
+
+func TestUseSideChainParams(t *testing.T) {
+	params := NetworkParams{Name: "sidechain", SideChain: true}
+	UseNetworkParams(params)
+	defer UseNetworkParams(MainNetParams)
+
+	if _, ok := params.GetConsensus().(SideChainConsensus); !ok {
+		t.Error("sidechain params without sidechain consensus")
+	}
+
+	// The sidechain header carries the side aux pow of a main chain transaction
+	header := mineSideHeader(1)
+	buf := new(bytes.Buffer)
+	if err := header.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded core.Header
+	if err := decoded.Deserialize(buf); err != nil {
+		t.Fatal("decode sidechain header error,", err)
+	}
+	if err := params.GetConsensus().CheckProofOfWork(&decoded); err != nil {
+		t.Error("decoded sidechain header rejected,", err)
+	}
+
+	// The sidechain transaction types are decoded
+	recharge := tx.Transaction{
+		TxType:  tx.RechargeToSideChain,
+		Payload: &payload.RechargeToSideChain{MerkleProof: []byte{1}, MainChainTransaction: []byte{2}},
+	}
+	buf.Reset()
+	if err := recharge.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	var decodedTx tx.Transaction
+	if err := decodedTx.Deserialize(buf); err != nil {
+		t.Fatal("decode sidechain transaction error,", err)
+	}
+	if _, ok := decodedTx.Payload.(*payload.RechargeToSideChain); !ok || decodedTx.TxType.Name() != "RechargeToSideChain" {
+		t.Errorf("unexpected payload %T of %s", decodedTx.Payload, decodedTx.TxType.Name())
+	}
+}
@@ -0,0 +1,166 @@
+package sdk
+
+import (
+	"sync"
+	"sync/atomic"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+// Max peers to download blocks from at the same time
+const MaxDownloadPeers = 4
+
+/*
+BlockRanges splits the block hashes of the inventories into contiguous ranges, one for each download
+peer, and stitches the ranges downloaded from different peers at the boundaries. The first block of a
+range must link to the last hash of the range before it, and the first range of an inventory to the
+last hash of the previous inventory. The first inventory after Reset() links to the block locator, so
+it's not checked. The zero value is ready to use.
+*/
+type BlockRanges struct {
+	lock sync.Mutex
+	last *Uint256
+	// The first hash of a range to the hash it must link to
+	boundaries map[Uint256]Uint256
+}
+
+// Split the hashes of the next inventory into up to count contiguous ranges of about the same size,
+// return the range of each hash
+func (r *BlockRanges) Split(hashes []Uint256, count int) []int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.boundaries == nil {
+		r.boundaries = make(map[Uint256]Uint256)
+	}
+	if count > len(hashes) {
+		count = len(hashes)
+	}
+	if count < 1 {
+		count = 1
+	}
+	size := (len(hashes) + count - 1) / count
+	ranges := make([]int, len(hashes))
+	for i, hash := range hashes {
+		ranges[i] = i / size
+		if i%size != 0 {
+			continue
+		}
+		if i > 0 {
+			r.boundaries[hash] = hashes[i-1]
+		} else if r.last != nil {
+			r.boundaries[hash] = *r.last
+		}
+	}
+	if len(hashes) > 0 {
+		last := hashes[len(hashes)-1]
+		r.last = &last
+	}
+	return ranges
+}
+
+// Check the block links to the range before it if it's the first block of a range
+func (r *BlockRanges) Check(header *core.Header) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	hash := *header.Hash()
+	previous, ok := r.boundaries[hash]
+	if !ok {
+		return nil
+	}
+	delete(r.boundaries, hash)
+	if header.Previous != previous {
+		return errors.Wrapf(errors.ErrPeerMisbehaving, "block %s at height %d links to %s at the range boundary, expect %s",
+			hash.String(), header.Height, header.Previous.String(), previous.String())
+	}
+	return nil
+}
+
+// Forget the ranges, the next inventory is split as the first one
+func (r *BlockRanges) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.last = nil
+	r.boundaries = nil
+}
+
+/*
+Split the block hashes of the inventory from the sync peer into contiguous ranges, each downloaded
+from one of up to MaxDownloadPeers established peers not demoted, the sync peer takes the first
+range. The ranges are stitched back by the BlockRanges check when the blocks are received, and in
+order by the previous block hash when committed, so the initial sync is speeded up by the peer
+count. In single peer mode, all the blocks are downloaded from the sync peer.
+*/
+func (service *SPVServiceImpl) splitDownload(syncPeer *p2p.Peer, hashes []Uint256) {
+	peers := service.downloadPeers(syncPeer)
+	ranges := service.ranges.Split(hashes, len(peers))
+
+	service.downloadLock.Lock()
+	defer service.downloadLock.Unlock()
+	if service.download == nil {
+		service.download = make(map[Uint256]*p2p.Peer)
+	}
+	for i, hash := range hashes {
+		service.download[hash] = peers[ranges[i]]
+	}
+}
+
+// Get the peers to download the blocks of the next inventory from, the sync peer first
+func (service *SPVServiceImpl) downloadPeers(syncPeer *p2p.Peer) []*p2p.Peer {
+	peers := []*p2p.Peer{syncPeer}
+	// Only the filter peer has the filter in privacy mode
+	if atomic.LoadInt32(&service.singlePeer) == 1 || service.IsPrivacyMode() {
+		return peers
+	}
+
+	height := uint64(service.chain.Height())
+	for _, peer := range service.PeerManager().ConnectedPeers() {
+		if len(peers) >= MaxDownloadPeers {
+			break
+		}
+		if peer == syncPeer || peer.State() != p2p.ESTABLISH || peer.Height() <= height {
+			continue
+		}
+		if service.throughput.isDemoted(peer) {
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// Get the peer to download the block from by the range it's in, or the sync peer if the peer of
+// the range is gone
+func (service *SPVServiceImpl) DownloadPeer(syncPeer *p2p.Peer, hash Uint256) *p2p.Peer {
+	service.downloadLock.Lock()
+	peer := service.download[hash]
+	delete(service.download, hash)
+	service.downloadLock.Unlock()
+
+	if peer == nil || peer.State() != p2p.ESTABLISH {
+		return syncPeer
+	}
+	return peer
+}
+
+// Forget the ranges and the download peers of the blocks not requested when syncing stopped
+func (service *SPVServiceImpl) resetDownload() {
+	service.ranges.Reset()
+	service.downloadLock.Lock()
+	service.download = nil
+	service.downloadLock.Unlock()
+}
+
+// Fall back to download blocks from the sync peer only, when the download peers are inconsistent
+// with the sync peer, it's reset when the blockchain synced up
+func (service *SPVServiceImpl) fallbackSinglePeer(reason string) {
+	if atomic.CompareAndSwapInt32(&service.singlePeer, 0, 1) {
+		log.Warn("Fall back to single peer download, ", reason)
+	}
+}
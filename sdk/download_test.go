@@ -0,0 +1,87 @@
+package sdk
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Make a chain of n headers after the previous hash
+func chainHeaders(previous Uint256, height uint32, n int) ([]*core.Header, []Uint256) {
+	var headers []*core.Header
+	var hashes []Uint256
+	for i := 0; i < n; i++ {
+		header := &core.Header{Previous: previous, Height: height + uint32(i), Nonce: uint32(i)}
+		previous = *header.Hash()
+		headers = append(headers, header)
+		hashes = append(hashes, previous)
+	}
+	return headers, hashes
+}
+
+func TestBlockRangesSplit(t *testing.T) {
+	var ranges BlockRanges
+	_, hashes := chainHeaders(Uint256{1}, 1, 10)
+	for _, c := range []struct {
+		count  int
+		expect []int
+	}{
+		{1, []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{3, []int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2}},
+		{4, []int{0, 0, 0, 1, 1, 1, 2, 2, 2, 3}},
+		{20, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	} {
+		ranges.Reset()
+		got := ranges.Split(hashes, c.count)
+		for i := range c.expect {
+			if got[i] != c.expect[i] {
+				t.Errorf("%d hashes split into %d ranges %v, expect %v", len(hashes), c.count, got, c.expect)
+				break
+			}
+		}
+	}
+}
+
+func TestBlockRangesCheck(t *testing.T) {
+	var ranges BlockRanges
+	headers, hashes := chainHeaders(Uint256{1}, 1, 12)
+
+	// The boundaries of the ranges in an inventory and between the inventories are checked
+	ranges.Split(hashes[:6], 3)
+	ranges.Split(hashes[6:], 2)
+	for _, header := range headers {
+		if err := ranges.Check(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// An inventory not continuing the previous one is caught at the first block
+	ranges.Reset()
+	ranges.Split(hashes[:4], 2)
+	forked, forkedHashes := chainHeaders(hashes[1], 3, 4)
+	ranges.Split(forkedHashes, 2)
+	for _, header := range append(headers[:4], forked[1:]...) {
+		if err := ranges.Check(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ranges.Check(forked[0]); !errors.Is(err, errors.ErrPeerMisbehaving) {
+		t.Errorf("inventory not linked error %v, expect misbehaving", err)
+	}
+
+	// A range of another chain in an inventory is caught at the boundary
+	ranges.Reset()
+	inventory := append(append([]Uint256{}, hashes[:3]...), forkedHashes[1:]...)
+	ranges.Split(inventory, 2)
+	if err := ranges.Check(headers[0]); err != nil {
+		t.Fatal("first block of the first inventory checked,", err)
+	}
+	if err := ranges.Check(forked[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ranges.Check(forked[1]); !errors.Is(err, errors.ErrPeerMisbehaving) {
+		t.Errorf("range not linked error %v, expect misbehaving", err)
+	}
+}
@@ -0,0 +1,56 @@
+package sdk
+
+import (
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+/*
+Stop committing blocks after a write of the DataStore failed, like the disk is full or the database is
+damaged. The data of the block may be written in part, committing more blocks on it makes the state
+inconsistent, so the blockchain returns the failure until it's cleared. Called with the lock held.
+*/
+func (bc *Blockchain) fail(height uint32, err error) error {
+	if bc.failure == nil {
+		bc.failure = errors.WrapErr(errors.ErrStorage, err, "[Blockchain], write block %d failed", height)
+		bc.notifyAlert(&Alert{Type: AlertStorageFailure, Height: height, Message: err.Error()})
+	}
+	return bc.failure
+}
+
+// Get the write failure of the DataStore the blockchain stopped on, nil if it's committing blocks
+func (bc *Blockchain) Failure() error {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	return bc.failure
+}
+
+// Continue committing blocks after the write failure is resolved, like the damaged database is replaced
+func (bc *Blockchain) ClearFailure() {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	bc.failure = nil
+}
+
+/*
+Set the headers only mode, the transactions matched by the filter are neither requested nor committed
+like the blocks before the birthday, for the wallet data can not be stored, but the chain is still
+followed and verified. Turn it off to sync the blocks with transactions again.
+*/
+func (service *SPVServiceImpl) SetHeadersOnly(headersOnly bool) {
+	var value int32
+	if headersOnly {
+		value = 1
+		log.Warn("Enter headers only mode, transactions are not synced")
+	}
+	atomic.StoreInt32(&service.headersOnly, value)
+}
+
+// Check if the service is in the headers only mode
+func (service *SPVServiceImpl) IsHeadersOnly() bool {
+	return atomic.LoadInt32(&service.headersOnly) == 1
+}
@@ -0,0 +1,205 @@
+package sdk
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	// False positive rate of the bloom filter built if the filter tuning is off or the rate not set
+	DefaultFPRate = 0.00003
+
+	// Observed false positive rate to rebuild the bloom filter at if not set
+	DefaultFPThreshold = 0.0005
+
+	// Transactions of the merkleblocks observed to check the false positive rate if not set
+	DefaultTuningWindow = 20000
+
+	// Max times the element count of the filter is grown by, and the lowest false positive rate
+	// it's lowered to after the element count is at the max
+	maxFilterGrowth = 16
+	minFPRate       = 0.000001
+)
+
+// The parameters to build the bloom filter with, the element count is multiplied by the growth
+type FilterParams struct {
+	Growth uint32
+	Tweak  uint32
+	FPRate float64
+}
+
+// Build a bloom filter sized for the elements by the parameters
+func (p FilterParams) NewFilter(elements uint32) *bloom.Filter {
+	growth := p.Growth
+	if growth == 0 {
+		growth = 1
+	}
+	size := uint64(elements) * uint64(growth)
+	if size > math.MaxUint32 {
+		size = math.MaxUint32
+	}
+	return bloom.NewFilter(uint32(size), p.Tweak, p.FPRate)
+}
+
+/*
+Filter tuning state. The transactions of the merkleblocks committed are counted with the false positives
+in them, the transactions matched but not of the wallet. Once the window of transactions is observed the
+false positive rate is checked, above the threshold the filter is rebuilt with twice the element count
+and a new tweak, or half the false positive rate after the element count grew to the max, and loaded to
+the peers again. The filter loaded to a peer saturates as the wallet grows and the peer adds the matched
+outpoints, a filter matching too much costs the bandwidth, and the false positives of a new tweak are
+other transactions, so the peers can not tell the wallet by the transactions matched in common.
+*/
+type filterTuning struct {
+	sync.Mutex
+	threshold float64
+	window    uint32
+	params    FilterParams
+	// transactions and false positives observed since the last check
+	txs        uint32
+	fPositives uint32
+}
+
+/*
+Turn on the filter tuning with the false positive rate of the filter built, the observed false positive
+rate to rebuild the filter at, and the transactions observed to check the rate, 0 means the default values
+and a negative threshold turns it off. The bloom filter function of the service must build the filter by
+the FilterParams(), and build a new filter when they changed. The split filter is not tuned, so it can not
+be on with the split filter.
+*/
+func (service *SPVServiceImpl) SetFilterTuning(fpRate, threshold float64, window uint32) error {
+	if threshold < 0 {
+		service.tuningLock.Lock()
+		service.tuning = nil
+		service.tuningLock.Unlock()
+		log.Info("Filter tuning off")
+		service.reloadFilter()
+		return nil
+	}
+
+	if service.IsSplitFilter() {
+		return errors.Wrap(errors.ErrInvalid, "filter tuning can not be on with the split filter")
+	}
+	if fpRate == 0 {
+		fpRate = DefaultFPRate
+	}
+	if threshold == 0 {
+		threshold = DefaultFPThreshold
+	}
+	if window == 0 {
+		window = DefaultTuningWindow
+	}
+	if fpRate < 0 || fpRate >= 1 {
+		return errors.Wrapf(errors.ErrInvalid, "invalid false positive rate %g", fpRate)
+	}
+	if threshold <= fpRate {
+		return errors.Wrapf(errors.ErrInvalid, "false positive threshold %g not above the rate %g", threshold, fpRate)
+	}
+
+	tweak, err := randTweak()
+	if err != nil {
+		return err
+	}
+	service.tuningLock.Lock()
+	service.tuning = &filterTuning{
+		threshold: threshold,
+		window:    window,
+		params:    FilterParams{Growth: 1, Tweak: tweak, FPRate: fpRate},
+	}
+	service.tuningLock.Unlock()
+
+	log.Infof("Filter tuning on, false positive rate %g rebuilt above %g in %d transactions", fpRate, threshold, window)
+	service.reloadFilter()
+	return nil
+}
+
+// Check if the bloom filter is tuned by the observed false positive rate
+func (service *SPVServiceImpl) IsFilterTuning() bool {
+	return service.getTuning() != nil
+}
+
+// Get the parameters the bloom filter function must build the filter with, the parameters of
+// NewBloomFilter() if the filter tuning is off
+func (service *SPVServiceImpl) FilterParams() FilterParams {
+	t := service.getTuning()
+	if t == nil {
+		return FilterParams{Growth: 1, FPRate: DefaultFPRate}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	return t.params
+}
+
+func (service *SPVServiceImpl) getTuning() *filterTuning {
+	service.tuningLock.Lock()
+	defer service.tuningLock.Unlock()
+
+	return service.tuning
+}
+
+// Count the transactions and false positives of the merkleblocks committed, and load a filter
+// rebuilt by the new parameters to the peers if the false positive rate is above the threshold
+func (service *SPVServiceImpl) tuneFilter(txs, fPositives uint32) {
+	t := service.getTuning()
+	if t == nil {
+		return
+	}
+
+	rate, rebuilt, err := t.observe(txs, fPositives)
+	if err != nil {
+		log.Error("Rebuild bloom filter failed, ", err)
+		return
+	}
+	if rebuilt {
+		params := service.FilterParams()
+		log.Infof("False positive rate %g above %g, rebuild bloom filter with %dx elements and rate %g",
+			rate, t.threshold, params.Growth, params.FPRate)
+		service.reloadFilter()
+	}
+}
+
+// Observe the transactions and false positives, and get the false positive rate and if the
+// parameters changed when the window is observed
+func (t *filterTuning) observe(txs, fPositives uint32) (float64, bool, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.txs += txs
+	t.fPositives += fPositives
+	if t.txs < t.window {
+		return 0, false, nil
+	}
+
+	rate := float64(t.fPositives) / float64(t.txs)
+	t.txs, t.fPositives = 0, 0
+	if rate <= t.threshold {
+		return rate, false, nil
+	}
+
+	tweak, err := randTweak()
+	if err != nil {
+		return rate, false, err
+	}
+	t.params.Tweak = tweak
+	if t.params.Growth < maxFilterGrowth {
+		t.params.Growth *= 2
+	} else if t.params.FPRate/2 >= minFPRate {
+		t.params.FPRate /= 2
+	}
+	return rate, true, nil
+}
+
+func randTweak() (uint32, error) {
+	var buf [4]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
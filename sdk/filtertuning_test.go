@@ -0,0 +1,58 @@
+package sdk
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+func TestFilterTuning(t *testing.T) {
+	service := new(SPVServiceImpl)
+	if params := service.FilterParams(); params != (FilterParams{Growth: 1, FPRate: DefaultFPRate}) {
+		t.Fatalf("filter params %+v not the defaults with the filter tuning off", params)
+	}
+	wallet := Uint168{0x21, 0x01}
+	filter := service.FilterParams().NewFilter(10)
+	filter.Add(wallet.ToArray())
+	expect := NewBloomFilter(10)
+	expect.Add(wallet.ToArray())
+	if string(filter.GetFilterLoadMsg().Filter) != string(expect.GetFilterLoadMsg().Filter) {
+		t.Error("filter of the default params not the filter of NewBloomFilter()")
+	}
+
+	tuning := &filterTuning{threshold: 0.001, window: 1000, params: FilterParams{Growth: 1, FPRate: DefaultFPRate}}
+	service.tuning = tuning
+
+	// The rate is checked after the window observed
+	if _, rebuilt, _ := tuning.observe(500, 100); rebuilt {
+		t.Fatal("filter rebuilt before the window observed")
+	}
+	rate, rebuilt, err := tuning.observe(500, 0)
+	if err != nil || !rebuilt || rate != 0.1 {
+		t.Fatalf("filter not rebuilt at rate %g, %v", rate, err)
+	}
+	params := service.FilterParams()
+	if params.Growth != 2 || params.FPRate != DefaultFPRate || params.Tweak == 0 {
+		t.Errorf("filter params %+v after rebuilt", params)
+	}
+	if size, prev := len(params.NewFilter(100).GetFilterLoadMsg().Filter), len(NewBloomFilter(100).GetFilterLoadMsg().Filter); size <= prev {
+		t.Errorf("filter of %d bytes not grown from %d bytes", size, prev)
+	}
+
+	// Not rebuilt under the threshold, the counts start over
+	if rate, rebuilt, _ := tuning.observe(1000, 1); rebuilt || rate != 0.001 {
+		t.Errorf("filter rebuilt at rate %g", rate)
+	}
+	if tuning.txs != 0 || tuning.fPositives != 0 {
+		t.Error("observed counts not reset after the window")
+	}
+
+	// The false positive rate is lowered after the element count grew to the max
+	for i := 0; i < 4; i++ {
+		tuning.observe(1000, 500)
+	}
+	params = service.FilterParams()
+	if params.Growth != maxFilterGrowth || params.FPRate != DefaultFPRate/2 {
+		t.Errorf("filter params %+v after rebuilt 5 times", params)
+	}
+}
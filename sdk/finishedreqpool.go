@@ -14,6 +14,8 @@ type FinishedReqPool struct {
 	blocks   map[Uint256]*bloom.MerkleBlock
 	requests map[Uint256]*BlockTxsRequest
 	lastPop  *Uint256
+	// called with the request popped to commit
+	onPop func(*BlockTxsRequest)
 }
 
 func (pool *FinishedReqPool) Add(request *BlockTxsRequest) {
@@ -56,6 +58,9 @@ func (pool *FinishedReqPool) Next(current Uint256) (*BlockTxsRequest, bool) {
 		delete(pool.requests, current)
 		delete(pool.blocks, request.BlockHash)
 		pool.lastPop = &request.BlockHash
+		if pool.onPop != nil {
+			pool.onPop(request)
+		}
 		return request, ok
 	}
 	return nil, false
@@ -0,0 +1,213 @@
+package sdk
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+/*
+The wire compatibility tests run against a locally running ELA full node to catch the protocol drift
+between this package and the main node, they are skipped unless the node address is set like
+
+	SPV_FULLNODE_ADDR=127.0.0.1 SPV_FULLNODE_MAGIC=1234567 go test ./sdk -run FullNode
+
+The port of the address is replaced by the SPVServerPort, the magic is the MainNetMagic if not set.
+*/
+const (
+	fullNodeAddrEnv  = "SPV_FULLNODE_ADDR"
+	fullNodeMagicEnv = "SPV_FULLNODE_MAGIC"
+	fullNodeTimeout  = 30 * time.Second
+)
+
+// Collects the messages received from the full node
+type fullNodeHandler struct {
+	established chan *p2p.Peer
+	inventories chan *msg.Inventory
+	blocks      chan *bloom.MerkleBlock
+	txs         chan *msg.Txn
+	notFound    chan *msg.NotFound
+}
+
+func (h *fullNodeHandler) OnPeerEstablish(peer *p2p.Peer) { h.established <- peer }
+
+func (h *fullNodeHandler) OnInventory(peer *p2p.Peer, inv *msg.Inventory) error {
+	h.inventories <- inv
+	return nil
+}
+
+func (h *fullNodeHandler) OnMerkleBlock(peer *p2p.Peer, block *bloom.MerkleBlock) error {
+	h.blocks <- block
+	return nil
+}
+
+func (h *fullNodeHandler) OnTxn(peer *p2p.Peer, txn *msg.Txn) error {
+	h.txs <- txn
+	return nil
+}
+
+func (h *fullNodeHandler) OnNotFound(peer *p2p.Peer, notFound *msg.NotFound) error {
+	h.notFound <- notFound
+	return nil
+}
+
+func (h *fullNodeHandler) OnConfirm(peer *p2p.Peer, confirm *msg.Confirm) error { return nil }
+
+// Connect to the full node and wait for the handshake done, call done when the test finished
+func connectFullNode(t *testing.T) (client *SPVClientImpl, handler *fullNodeHandler, peer *p2p.Peer, done func()) {
+	addr := os.Getenv(fullNodeAddrEnv)
+	if addr == "" {
+		t.Skipf("%s not set, skip the full node wire compatibility test", fullNodeAddrEnv)
+	}
+	magic := uint32(MainNetMagic)
+	if value := os.Getenv(fullNodeMagicEnv); value != "" {
+		m, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			t.Fatalf("invalid %s %s", fullNodeMagicEnv, value)
+		}
+		magic = uint32(m)
+	}
+	log.Init()
+
+	// The peer manager caches addresses and reputations in the data directory
+	dir, err := ioutil.TempDir("", "fullnode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done = func() { os.RemoveAll(dir) }
+
+	config := p2p.NewConfig(magic, nil)
+	config.DataDir = dir
+	config.TrustedPeers = []string{addr}
+	config.MinConnCount = 1
+	client, err = NewSPVClientImpl(config, uint64(time.Now().UnixNano()))
+	if err != nil {
+		done()
+		t.Fatal(err)
+	}
+	handler = &fullNodeHandler{
+		established: make(chan *p2p.Peer, 1),
+		inventories: make(chan *msg.Inventory, 10),
+		blocks:      make(chan *bloom.MerkleBlock, 10),
+		txs:         make(chan *msg.Txn, 100),
+		notFound:    make(chan *msg.NotFound, 10),
+	}
+	client.SetMessageHandler(handler)
+	client.Start()
+
+	select {
+	case peer = <-handler.established:
+		return client, handler, peer, done
+	case <-time.After(fullNodeTimeout):
+		done()
+		t.Fatalf("handshake with full node %s timeout", addr)
+	}
+	return
+}
+
+func TestFullNodeHandshake(t *testing.T) {
+	_, _, peer, done := connectFullNode(t)
+	defer done()
+
+	// Decoded from the version message of the full node
+	if peer.Version() < ProtocolVersion {
+		t.Errorf("peer version %d, expect at least %d", peer.Version(), ProtocolVersion)
+	}
+	if peer.Services()/ServiveSPV&1 == 0 {
+		t.Errorf("SPV service not enabled in peer services %d", peer.Services())
+	}
+	if peer.State() != p2p.ESTABLISH {
+		t.Errorf("peer state %d, expect established", peer.State())
+	}
+}
+
+func TestFullNodeSync(t *testing.T) {
+	client, handler, peer, done := connectFullNode(t)
+	defer done()
+	if peer.Height() < 2 {
+		t.Skipf("full node height %d, at least 2 blocks needed", peer.Height())
+	}
+
+	// Header sync, an empty locator starts from the genesis block
+	peer.Send(client.NewBlocksReq(nil, Uint256{}))
+	var inv *msg.Inventory
+	select {
+	case inv = <-handler.inventories:
+	case <-time.After(fullNodeTimeout):
+		t.Fatal("inventory not received")
+	}
+	if inv.Type != msg.InvTypeBlock {
+		t.Fatalf("inventory type %s, expect block", inv.Type)
+	}
+	if inv.Count < 2 || len(inv.Data) != int(inv.Count)*UINT256SIZE {
+		t.Fatalf("inventory of %d hashes with %d bytes", inv.Count, len(inv.Data))
+	}
+	var hashes []Uint256
+	for i := uint32(0); i < 2; i++ {
+		hash, err := Uint256FromBytes(inv.Data[i*UINT256SIZE : (i+1)*UINT256SIZE])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, *hash)
+	}
+
+	// Filter load, a filter matching everything returns all transactions of the blocks
+	peer.Send(matchAllFilter())
+	var headers []*bloom.MerkleBlock
+	for _, hash := range hashes {
+		peer.Send(client.NewDataReq(BLOCK, hash))
+		select {
+		case block := <-handler.blocks:
+			headers = append(headers, block)
+		case notFound := <-handler.notFound:
+			t.Fatalf("block %s not found", notFound.Hash.String())
+		case <-time.After(fullNodeTimeout):
+			t.Fatalf("merkleblock %s not received", hash.String())
+		}
+	}
+
+	for i, block := range headers {
+		if !block.BlockHeader.Hash().IsEqual(&hashes[i]) {
+			t.Errorf("merkleblock hash %s, expect %s", block.BlockHeader.Hash().String(), hashes[i].String())
+		}
+		if i > 0 && !block.BlockHeader.Previous.IsEqual(headers[i-1].BlockHeader.Hash()) {
+			t.Errorf("merkleblock %d not connected to the previous one", i)
+		}
+		txIds, err := bloom.CheckMerkleBlock(*block)
+		if err != nil {
+			t.Fatalf("check merkleblock %d error %v", i, err)
+		}
+		if block.Transactions == 0 || uint32(len(txIds)) != block.Transactions {
+			t.Errorf("merkleblock %d matched %d of %d transactions", i, len(txIds), block.Transactions)
+		}
+
+		// Matched transactions follow the merkleblock
+		for range txIds {
+			select {
+			case txn := <-handler.txs:
+				if !inHashes(txn.Hash(), txIds) {
+					t.Errorf("unexpected transaction %s of merkleblock %d", txn.Hash().String(), i)
+				}
+			case <-time.After(fullNodeTimeout):
+				t.Fatalf("transactions of merkleblock %d not received", i)
+			}
+		}
+	}
+}
+
+func inHashes(hash *Uint256, hashes []*Uint256) bool {
+	for _, h := range hashes {
+		if h.IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
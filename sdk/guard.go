@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/guard"
+)
+
+// The listeners registered with the guards of their callbacks, a panic from a callback
+// is recovered and handled by the guard policy, so it can not take down the sync
+
+type guardedStateListener struct {
+	StateListener
+	guard *guard.Guard
+}
+
+type guardedAlertListener struct {
+	AlertListener
+	guard *guard.Guard
+}
+
+type guardedIdleListener struct {
+	IdleListener
+	guard *guard.Guard
+}
+
+type guardedStallListener struct {
+	StallListener
+	guard *guard.Guard
+}
+
+type guardedArbitersListener struct {
+	ArbitersListener
+	guard *guard.Guard
+}
+
+// Create the guard of the listener, named by the kind and the type of the listener
+func newGuard(kind string, listener interface{}) *guard.Guard {
+	return guard.New(fmt.Sprintf("%s %T", kind, listener))
+}
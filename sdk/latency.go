@@ -0,0 +1,55 @@
+package sdk
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+// Latency buckets of the requests in seconds, from 1ms doubled up to about 30s
+var LatencyBuckets = metrics.ExponentialBuckets(.001, 2, 16)
+
+const latencyHelp = "Seconds from a request sent until the peer responded"
+
+var (
+	getBlocksLatency = metrics.NewHistogram(`spv_request_latency_seconds{type="getblocks"}`, latencyHelp, LatencyBuckets)
+	blockLatency     = metrics.NewHistogram(`spv_request_latency_seconds{type="block"}`, latencyHelp, LatencyBuckets)
+	txLatency        = metrics.NewHistogram(`spv_request_latency_seconds{type="tx"}`, latencyHelp, LatencyBuckets)
+)
+
+// Record the latency of a request answered by the peer, the latency of the peer is also updated
+// so the fast peers can be preferred
+func observeLatency(peer *p2p.Peer, reqType msg.InvType, latency time.Duration) {
+	switch reqType {
+	case BLOCK:
+		blockLatency.Observe(latency.Seconds())
+	case TRANSACTION:
+		txLatency.Observe(latency.Seconds())
+	}
+	if peer != nil {
+		peer.UpdateLatency(latency)
+	}
+}
+
+// Record the time the getblocks message sent to the sync peer
+func (service *SPVServiceImpl) sendBlocksReq(peer *p2p.Peer, locator []*Uint256) {
+	request := service.NewBlocksReq(locator, Uint256{})
+	atomic.StoreInt64(&service.blocksReqSent, clock.Now().UnixNano())
+	go peer.Send(request)
+}
+
+// Record the latency of the getblocks message when the block inventory received from the sync peer
+func (service *SPVServiceImpl) onBlocksInv(peer *p2p.Peer) {
+	sent := atomic.SwapInt64(&service.blocksReqSent, 0)
+	if sent == 0 {
+		return
+	}
+	latency := time.Duration(clock.Now().UnixNano() - sent)
+	getBlocksLatency.Observe(latency.Seconds())
+	peer.UpdateLatency(latency)
+}
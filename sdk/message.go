@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"bytes"
+
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+	"github.com/elastos/Elastos.ELA.SPV/crypto"
+	"github.com/elastos/Elastos.ELA.SPV/crypto/ecc"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+/*
+A signed message proves the ownership of an address. The message is prefixed with
+MessagePrefix before signing, so a signed message can not be used as a transaction signature.
+The signed message is the compressed public key of the signer followed by the signature,
+the verifier checks the public key matches the address and the signature is valid.
+*/
+const MessagePrefix = "Elastos Signed Message:\n"
+
+// Length of the signed message, 33 bytes compressed public key and 64 bytes signature
+const SignedMessageLength = 33 + crypto.SignatureLength
+
+// Get the data to sign of the message, varstring(prefix) + varstring(message)
+func messageData(message string) []byte {
+	buf := new(bytes.Buffer)
+	serialization.WriteVarString(buf, MessagePrefix)
+	serialization.WriteVarString(buf, message)
+	return buf.Bytes()
+}
+
+// Sign a message with account, return the public key followed by the signature
+func (a *Account) SignMessage(message string) ([]byte, error) {
+	publicKey, err := a.publicKey.EncodePoint(true)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := a.Sign(messageData(message))
+	if err != nil {
+		return nil, err
+	}
+
+	return append(publicKey, signature...), nil
+}
+
+// Verify the signed message is signed by the owner of the address
+func VerifyMessage(address string, signature []byte, message string) error {
+	if len(signature) != SignedMessageLength {
+		return errors.Wrap(errors.ErrInvalid, "invalid signed message length")
+	}
+
+	publicKey, err := crypto.DecodePoint(signature[:33])
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "invalid public key in signed message")
+	}
+
+	redeemScript, err := tx.CreateStandardRedeemScript(publicKey)
+	if err != nil {
+		return err
+	}
+	programHash, err := tx.ToProgramHash(redeemScript)
+	if err != nil {
+		return err
+	}
+	signer, err := programHash.ToAddress()
+	if err != nil {
+		return err
+	}
+	if signer != address {
+		return errors.Wrap(errors.ErrInvalid, "message not signed by address "+address)
+	}
+
+	return ecc.Verify(publicKey, messageData(message), signature[33:])
+}
@@ -38,5 +38,10 @@ type P2PMessageHandler interface {
 // seeds is a list which is the other peers IP:[Port] addresses,
 // port is not necessary for it will be overwrite to SPVServerPort according to the SPV protocol
 func GetP2PClient(magic uint32, clientId uint64, seeds []string) (P2PClient, error) {
-	return NewP2PClientImpl(magic, clientId, seeds)
+	return NewP2PClientImpl(p2p.NewConfig(magic, seeds), clientId)
+}
+
+// Get a P2P client with the network config, use this to run multiple networks in one process
+func GetP2PClientWithConfig(config *p2p.Config, clientId uint64) (P2PClient, error) {
+	return NewP2PClientImpl(config, clientId)
 }
@@ -13,28 +13,30 @@ type P2PClientImpl struct {
 	peerManager *p2p.PeerManager
 }
 
-func NewP2PClientImpl(magic uint32, clientId uint64, seeds []string) (*P2PClientImpl, error) {
+func NewP2PClientImpl(config *p2p.Config, clientId uint64) (*P2PClientImpl, error) {
 	// Initialize local peer
 	local := new(p2p.Peer)
 	local.SetID(clientId)
-	local.SetVersion(ProtocolVersion)
+	local.SetVersion(CapabilitiesVersion)
 	local.SetPort(SPVClientPort)
 
-	if magic == 0 {
+	if config.Magic == 0 {
 		return nil, errors.New("Magic number has not been set ")
 	}
-	// Set Magic number of the P2P network
-	p2p.Magic = magic
 
-	if len(seeds) == 0 {
+	if len(config.SeedList) == 0 && len(config.TrustedPeers) == 0 {
 		return nil, errors.New("Seeds list is empty ")
 	}
 
 	// Create client instance
 	client := new(P2PClientImpl)
 
-	// Initialize peer manager
-	client.peerManager = p2p.InitPeerManager(local, toSPVAddr(seeds))
+	// Initialize peer manager, seeds and trusted peers are converted
+	// to SPV addresses without changing the given config
+	spvConfig := *config
+	spvConfig.SeedList = ToSPVAddr(config.SeedList)
+	spvConfig.TrustedPeers = ToSPVAddr(config.TrustedPeers)
+	client.peerManager = p2p.NewPeerManager(&spvConfig, local)
 
 	// Set message handler
 	client.peerManager.SetMessageHandler(client)
@@ -52,7 +54,7 @@ func (client *P2PClientImpl) Start() {
 }
 
 // Convert seed addresses to SPVServerPort according to the SPV protocol
-func toSPVAddr(seeds []string) []string {
+func ToSPVAddr(seeds []string) []string {
 	var addrs = make([]string, len(seeds))
 	for i, seed := range seeds {
 		portIndex := strings.LastIndex(seed, ":")
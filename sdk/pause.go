@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+/*
+IdleListener is an interface to know when the SPV service is idle, it's synced up with the
+connected peers and no blocks are being downloaded. Mobile wrappers can pause the service
+on idle when the app is in background, and resume it when the app is active again.
+Call SPVService.AddIdleListener() method to register your callbacks to the notify list.
+*/
+type IdleListener interface {
+	OnIdle()
+}
+
+// Register an idle listener, multiple registration is supported.
+func (service *SPVServiceImpl) AddIdleListener(listener IdleListener) {
+	service.idleListeners = append(service.idleListeners,
+		&guardedIdleListener{IdleListener: listener, guard: newGuard("idle listener", listener)})
+}
+
+// Stop syncing and disconnect all the peers, blocks not committed yet are dropped,
+// after resumed the sync starts over from the current chain tip
+func (service *SPVServiceImpl) Pause() {
+	service.Lock()
+	defer service.Unlock()
+
+	if service.paused {
+		return
+	}
+	service.paused = true
+	service.stopSyncing()
+	service.queue.Clear()
+	service.PeerManager().Pause()
+	log.Info("SPV service paused at height ", service.chain.Height())
+}
+
+// Connect peers again and continue syncing after paused
+func (service *SPVServiceImpl) Resume() {
+	service.Lock()
+	defer service.Unlock()
+
+	if !service.paused {
+		return
+	}
+	service.paused = false
+	atomic.StoreInt32(&service.idle, 0)
+	service.PeerManager().Resume()
+	log.Info("SPV service resumed at height ", service.chain.Height())
+}
+
+// Check if the service is paused
+func (service *SPVServiceImpl) IsPaused() bool {
+	service.Lock()
+	defer service.Unlock()
+
+	return service.paused
+}
+
+// Notify the idle listeners once when the service became idle
+func (service *SPVServiceImpl) checkIdle() {
+	if service.PeerManager().GetBestPeer() == nil || service.queue.IsRunning() {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&service.idle, 0, 1) {
+		return
+	}
+	log.Debug("SPV service idle at height ", service.chain.Height())
+	for _, listener := range service.idleListeners {
+		go listener.guard.Run(listener.OnIdle)
+	}
+}
@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+// Save the merkleblock with the matched transactions not fetched yet, if the DataStore implements
+// db.PendingStore, so they are fetched again after restart
+func (service *SPVServiceImpl) savePending(block *bloom.MerkleBlock, txIds []*Uint256) {
+	store, ok := service.chain.DataStore.(db.PendingStore)
+	if !ok || len(txIds) == 0 {
+		return
+	}
+	pending := &db.PendingBlock{Block: *block, TxIds: make([]Uint256, 0, len(txIds))}
+	for _, txId := range txIds {
+		pending.TxIds = append(pending.TxIds, *txId)
+	}
+	if err := store.PutPendingBlock(pending); err != nil {
+		log.Warn("Save pending block error: ", err)
+	}
+}
+
+// Delete the pending block after it's committed
+func (service *SPVServiceImpl) removePending(hash Uint256) {
+	if store, ok := service.chain.DataStore.(db.PendingStore); ok {
+		if err := store.DelPendingBlock(hash); err != nil {
+			log.Warn("Delete pending block error: ", err)
+		}
+	}
+}
+
+/*
+Fetch the transactions of the pending blocks saved from the peer, when the syncing started. The blocks
+are put into the finished pool when the transactions are received and committed in order, they are not
+downloaded again. Pending blocks not above the chain tip are already committed or orphaned, they are deleted.
+*/
+func (service *SPVServiceImpl) resumePending(peer *p2p.Peer) {
+	store, ok := service.chain.DataStore.(db.PendingStore)
+	if !ok {
+		return
+	}
+	blocks, err := store.GetPendingBlocks()
+	if err != nil {
+		log.Warn("Get pending blocks error: ", err)
+		return
+	}
+
+	height := service.chain.Height()
+	for _, pending := range blocks {
+		if pending.Block.BlockHeader.Height <= height {
+			service.removePending(*pending.Block.BlockHeader.Hash())
+			continue
+		}
+		txIds := make([]*Uint256, 0, len(pending.TxIds))
+		for i := range pending.TxIds {
+			txIds = append(txIds, &pending.TxIds[i])
+		}
+		log.Infof("Resume fetching %d transactions of pending block at height %d",
+			len(txIds), pending.Block.BlockHeader.Height)
+		block := pending.Block
+		service.queue.StartBlockTxsRequest(peer, &block, txIds)
+	}
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+// A DataStore saving the pending blocks serialized as they are saved on disk
+type pendingStore struct {
+	db.DataStore
+	height uint32
+	blocks map[Uint256][]byte
+}
+
+func (s *pendingStore) GetChainHeight() uint32 { return s.height }
+
+func (s *pendingStore) PutPendingBlock(block *db.PendingBlock) error {
+	buf, err := block.Serialize()
+	s.blocks[*block.Block.BlockHeader.Hash()] = buf
+	return err
+}
+
+func (s *pendingStore) DelPendingBlock(hash Uint256) error {
+	delete(s.blocks, hash)
+	return nil
+}
+
+func (s *pendingStore) GetPendingBlocks() ([]*db.PendingBlock, error) {
+	var blocks []*db.PendingBlock
+	for _, buf := range s.blocks {
+		block := new(db.PendingBlock)
+		if err := block.Deserialize(buf); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func TestPendingBlocks(t *testing.T) {
+	log.Init()
+	store := &pendingStore{height: 10, blocks: make(map[Uint256][]byte)}
+	handler := &queueHandler{newTrackerHandler(1)}
+	service := &SPVServiceImpl{
+		chain: &Blockchain{lock: new(sync.RWMutex), DataStore: store},
+		queue: NewRequestQueue(MaxRequests, handler),
+	}
+	defer service.queue.Clear()
+
+	merkleBlock := func(height uint32) *bloom.MerkleBlock {
+		return &bloom.MerkleBlock{BlockHeader: core.Header{Height: height}, Transactions: 3,
+			Hashes: []*Uint256{{byte(height)}}, Flags: []byte{0x01}}
+	}
+	pending, stale, empty := merkleBlock(11), merkleBlock(10), merkleBlock(12)
+	txIds := []*Uint256{{1}, {2}}
+	service.savePending(pending, txIds)
+	service.savePending(stale, []*Uint256{{3}})
+	service.savePending(empty, nil)
+	if len(store.blocks) != 2 {
+		t.Fatalf("%d pending blocks saved, expect 2", len(store.blocks))
+	}
+
+	// The transactions of the pending block are fetched again, the block not above the tip is deleted
+	peer := handler.peers[0]
+	service.resumePending(peer)
+	if _, ok := store.blocks[*stale.BlockHeader.Hash()]; ok {
+		t.Error("pending block not above the chain tip not deleted")
+	}
+	if !service.queue.InBlockTxsRequestQueue(*pending.BlockHeader.Hash()) {
+		t.Fatal("transactions of the pending block not requested")
+	}
+	for _, txId := range txIds {
+		if !service.queue.RequestedFrom(peer, *txId) {
+			t.Errorf("transaction %s not requested", txId.String())
+		}
+	}
+
+	service.removePending(*pending.BlockHeader.Hash())
+	if len(store.blocks) != 0 {
+		t.Error("committed block not deleted")
+	}
+}
@@ -0,0 +1,243 @@
+package sdk
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+	"github.com/elastos/Elastos.ELA.SPV/sdk/address"
+)
+
+const (
+	// Decoy addresses and outpoints added to the bloom filter in privacy mode if not set
+	DefaultDecoys = 20
+
+	// Interval to load the bloom filter to another peer in privacy mode if not set
+	DefaultFilterRotation = 30 * time.Minute
+)
+
+/*
+Privacy mode state. The bloom filter is loaded to one peer at a time with decoy addresses and outpoints
+added, the other peers are loaded a filter matching nothing, so a peer sees the filter only for a while
+and can not tell the addresses of the wallet from the decoys. The decoys are derived from a random seed
+and kept the same for all filters, so the filters loaded to different peers can not be intersected to
+remove them. The decoys are added by the bloom filter function, so the filter is sized for them.
+*/
+type privacy struct {
+	sync.Mutex
+	decoys   int
+	rotation time.Duration
+	seed     [32]byte
+	// the peer the filter is loaded to and when it's selected
+	peer    *p2p.Peer
+	rotated time.Time
+}
+
+// A filter matches nothing, loaded to the peers not selected in privacy mode
+func matchNoneFilter() *bloom.FilterLoad {
+	return &bloom.FilterLoad{Filter: []byte{0x00}, HashFuncs: 1}
+}
+
+/*
+Turn on the privacy mode with the count of decoys added to the bloom filter, and the interval to rotate the
+peer the filter is loaded to, 0 means the default values. Blocks are downloaded from the filter peer only
+in privacy mode. Turn it off with a negative decoy count. The bloom filter function of the service must add
+the FilterDecoys() to the filter, and build a new filter when they changed.
+*/
+func (service *SPVServiceImpl) SetPrivacyMode(decoys int, rotation time.Duration) error {
+	service.privacyLock.Lock()
+	if decoys < 0 {
+		service.privacy = nil
+		service.privacyLock.Unlock()
+		log.Info("Privacy mode off")
+		service.reloadFilter()
+		return nil
+	}
+
+	if service.IsSplitFilter() {
+		service.privacyLock.Unlock()
+		return errors.Wrap(errors.ErrInvalid, "privacy mode can not be on with the split filter")
+	}
+	if decoys == 0 {
+		decoys = DefaultDecoys
+	}
+	if rotation <= 0 {
+		rotation = DefaultFilterRotation
+	}
+	// Keep the decoys when the options changed, new decoys would leave the real elements in common
+	p := &privacy{decoys: decoys, rotation: rotation}
+	if service.privacy != nil {
+		p.seed = service.privacy.seed
+	} else if _, err := rand.Read(p.seed[:]); err != nil {
+		service.privacyLock.Unlock()
+		return err
+	}
+	service.privacy = p
+	service.privacyLock.Unlock()
+
+	log.Infof("Privacy mode on, %d decoys, filter peer rotated every %s", decoys, rotation)
+	service.reloadFilter()
+	return nil
+}
+
+// Check if the service is in privacy mode
+func (service *SPVServiceImpl) IsPrivacyMode() bool {
+	return service.getPrivacy() != nil
+}
+
+// Get the decoy addresses and outpoints to add to the bloom filter, nil if not in privacy mode
+func (service *SPVServiceImpl) FilterDecoys() ([]*Uint168, []*tx.OutPoint) {
+	p := service.getPrivacy()
+	if p == nil {
+		return nil, nil
+	}
+
+	var addrs []*Uint168
+	var outPoints []*tx.OutPoint
+	for i := 0; i < p.decoys; i++ {
+		if i%2 == 0 {
+			addrs = append(addrs, p.decoyAddress(i))
+		} else {
+			outPoints = append(outPoints, p.decoyOutPoint(i))
+		}
+	}
+	return addrs, outPoints
+}
+
+func (service *SPVServiceImpl) getPrivacy() *privacy {
+	service.privacyLock.Lock()
+	defer service.privacyLock.Unlock()
+
+	return service.privacy
+}
+
+// Get the filterload message for the peer, in privacy mode only the filter peer gets the filter,
+// and a peer gets the shards of the slot with the split filter
+func (service *SPVServiceImpl) filterLoad(peer *p2p.Peer) *bloom.FilterLoad {
+	if s := service.getSplit(); s != nil {
+		addrs, outPoints := s.elements()
+		return s.filterLoad(s.slot(peer, service.PeerManager().ConnectedPeers()), addrs, outPoints)
+	}
+	if service.IsPrivacyMode() && service.filterPeer() != peer {
+		return matchNoneFilter()
+	}
+	return service.getFilter().GetFilterLoadMsg()
+}
+
+// Load the bloom filter to the connected peers again, after the addresses or outpoints changed
+func (service *SPVServiceImpl) reloadFilter() {
+	if s := service.getSplit(); s != nil {
+		addrs, outPoints := s.elements()
+		connected := service.PeerManager().ConnectedPeers()
+		for _, peer := range connected {
+			peer.Send(s.filterLoad(s.slot(peer, connected), addrs, outPoints))
+		}
+		return
+	}
+	if service.getPrivacy() == nil {
+		service.PeerManager().Broadcast(service.getFilter().GetFilterLoadMsg())
+		return
+	}
+	for _, peer := range service.PeerManager().ConnectedPeers() {
+		peer.Send(service.filterLoad(peer))
+	}
+}
+
+// Get the established peer the filter is loaded to in privacy mode, nil if not selected yet or disconnected
+func (service *SPVServiceImpl) filterPeer() *p2p.Peer {
+	p := service.getPrivacy()
+	if p == nil {
+		return nil
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.peer == nil || p.peer.State() != p2p.ESTABLISH {
+		return nil
+	}
+	return p.peer
+}
+
+/*
+Select the peer to load the filter to in privacy mode. The best peer is selected if no filter peer,
+and a random peer not behind it is selected when the filter peer has got the filter for the rotation
+interval. It's not rotated while syncing, so the blocks requested are received from the same peer.
+*/
+func (service *SPVServiceImpl) rotateFilterPeer() {
+	p := service.getPrivacy()
+	if p == nil {
+		return
+	}
+	current := service.filterPeer()
+
+	p.Lock()
+	var next *p2p.Peer
+	if current == nil {
+		next = service.PeerManager().GetBestPeer()
+	} else if clock.Since(p.rotated) >= p.rotation && !service.chain.IsSyncing() {
+		var candidates []*p2p.Peer
+		for _, peer := range service.PeerManager().ConnectedPeers() {
+			if peer != current && peer.State() == p2p.ESTABLISH && peer.Height() >= current.Height() {
+				candidates = append(candidates, peer)
+			}
+		}
+		if len(candidates) > 0 {
+			next = candidates[randIndex(len(candidates))]
+		}
+		p.rotated = clock.Now()
+	}
+	if next == nil {
+		p.Unlock()
+		return
+	}
+	p.peer = next
+	p.rotated = clock.Now()
+	p.Unlock()
+
+	if current != nil {
+		log.Infof("Rotate filter peer from %d to %d", current.ID(), next.ID())
+		current.Send(matchNoneFilter())
+	}
+	next.Send(service.getFilter().GetFilterLoadMsg())
+}
+
+func randIndex(n int) int {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0
+	}
+	return int(index.Int64())
+}
+
+// The decoy of the index, derived from the seed
+func (p *privacy) decoy(index int) [32]byte {
+	data := make([]byte, len(p.seed)+4)
+	copy(data, p.seed[:])
+	binary.LittleEndian.PutUint32(data[len(p.seed):], uint32(index))
+	return sha256.Sum256(data)
+}
+
+// A decoy looks like a standard address
+func (p *privacy) decoyAddress(index int) *Uint168 {
+	decoy := p.decoy(index)
+	var programHash Uint168
+	programHash[0] = address.PrefixStandard
+	copy(programHash[1:], decoy[:])
+	return &programHash
+}
+
+func (p *privacy) decoyOutPoint(index int) *tx.OutPoint {
+	decoy := p.decoy(index)
+	return tx.NewOutPoint(Uint256(decoy), uint16(decoy[0]%2))
+}
@@ -0,0 +1,50 @@
+package sdk
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+func TestPrivacyDecoys(t *testing.T) {
+	service := new(SPVServiceImpl)
+	if addrs, outPoints := service.FilterDecoys(); addrs != nil || outPoints != nil {
+		t.Fatal("decoys returned not in privacy mode")
+	}
+
+	service.privacy = &privacy{decoys: DefaultDecoys, seed: [32]byte{0x01}}
+	addrs, outPoints := service.FilterDecoys()
+	if len(addrs)+len(outPoints) != DefaultDecoys {
+		t.Fatalf("%d decoys, expect %d", len(addrs)+len(outPoints), DefaultDecoys)
+	}
+	for _, addr := range addrs {
+		if addr[0] != 0x21 {
+			t.Errorf("decoy address %x not like a standard address", addr[:])
+		}
+	}
+
+	// The decoys are the same for every filter, and different of another seed
+	again, _ := service.FilterDecoys()
+	if *addrs[0] != *again[0] {
+		t.Error("decoys changed between filters")
+	}
+	other := &privacy{decoys: DefaultDecoys, seed: [32]byte{0x02}}
+	if *other.decoyAddress(0) == *addrs[0] {
+		t.Error("decoys of another seed are the same")
+	}
+
+	// A filter built with the decoys is sized for them
+	wallet := Uint168{0x21, 0x01}
+	filter := BuildBloomFilter(append([]*Uint168{&wallet}, addrs...), outPoints)
+	if msg := filter.GetFilterLoadMsg(); bytes.Count(msg.Filter, []byte{0xff}) == len(msg.Filter) {
+		t.Error("filter with decoys saturated")
+	}
+	if !filter.Matches(wallet.ToArray()) || !filter.MatchesOutPoint(outPoints[0]) {
+		t.Error("filter with decoys not matched the elements")
+	}
+	if bloom.LoadFilter(matchNoneFilter()).Matches(wallet.ToArray()) {
+		t.Error("match none filter matched an address")
+	}
+}
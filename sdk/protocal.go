@@ -1,5 +1,7 @@
 package sdk
 
+import "github.com/elastos/Elastos.ELA.SPV/msg"
+
 const (
 	TypeMainNet = "MainNet"
 	TypeTestNet = "TestNet"
@@ -7,11 +9,12 @@ const (
 	MainNetMagic = 7630401
 	TestNetMagic = 1234567
 
-	ProtocolVersion = 1 // The min protocol version to support spv
-	ServiveSPV      = 1 << 2
-	SPVServerPort   = 20866
-	SPVClientPort   = 20867
+	ProtocolVersion     = 1 // The min protocol version to support spv
+	CapabilitiesVersion = 2 // The protocol version of this client, peers exchange the capabilities from it
+	ServiveSPV          = 1 << 2
+	SPVServerPort       = 20866
+	SPVClientPort       = 20867
 
-	TRANSACTION = 0x01
-	BLOCK       = 0x02
+	TRANSACTION = msg.InvTypeTx
+	BLOCK       = msg.InvTypeBlock
 )
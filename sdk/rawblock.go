@@ -0,0 +1,111 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/guard"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
+)
+
+// Max committed blocks waiting to be delivered to a raw block listener,
+// the block commit waits when it's full so no block is dropped
+const RawBlockQueueSize = 100
+
+// RawBlock is a committed block in the raw wire bytes, with the decoded metadata
+type RawBlock struct {
+	Hash      Uint256
+	Height    uint32
+	Timestamp uint32
+	// The merkleblock message payload
+	MerkleBlock []byte
+	// The matched transactions in the tx message payloads, in the order of TxIds
+	Txs   [][]byte
+	TxIds []Uint256
+}
+
+/*
+RawBlockListener receives the committed blocks in raw bytes rather than parsed callbacks,
+for archival pipelines and custom verifiers. Call Blockchain.AddRawBlockListener() to register it.
+The callbacks of a listener are called in the commit order, one at a time.
+*/
+type RawBlockListener interface {
+	// A block committed on the best chain
+	OnRawBlock(block *RawBlock)
+
+	// The blocks above the height are rolled back
+	OnRawRollback(height uint32)
+}
+
+// A raw block listener with the queue of the callbacks and the guard of them
+type rawSubscriber struct {
+	listener RawBlockListener
+	queue    chan func()
+	guard    *guard.Guard
+}
+
+// Register a raw block listener, multiple registration is supported.
+func (bc *Blockchain) AddRawBlockListener(listener RawBlockListener) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	subscriber := &rawSubscriber{
+		listener: listener,
+		queue:    make(chan func(), RawBlockQueueSize),
+		guard:    newGuard("raw block listener", listener),
+	}
+	supervisor.Go(fmt.Sprintf("raw block listener %T", listener), func() {
+		for callback := range subscriber.queue {
+			subscriber.guard.Run(callback)
+		}
+	})
+	bc.rawSubscribers = append(bc.rawSubscribers, subscriber)
+}
+
+// Create the raw block from the merkleblock and the matched transactions
+func NewRawBlock(block *bloom.MerkleBlock, txs []tx.Transaction, height uint32) (*RawBlock, error) {
+	raw := &RawBlock{
+		Hash:      *block.BlockHeader.Hash(),
+		Height:    height,
+		Timestamp: block.BlockHeader.Timestamp,
+	}
+	var err error
+	if raw.MerkleBlock, err = block.Serialize(); err != nil {
+		return nil, err
+	}
+	for _, txn := range txs {
+		buf := new(bytes.Buffer)
+		if err := txn.Serialize(buf); err != nil {
+			return nil, err
+		}
+		raw.Txs = append(raw.Txs, buf.Bytes())
+		raw.TxIds = append(raw.TxIds, *txn.Hash())
+	}
+	return raw, nil
+}
+
+func (bc *Blockchain) notifyRawBlock(block *bloom.MerkleBlock, txs []tx.Transaction, height uint32) {
+	if len(bc.rawSubscribers) == 0 {
+		return
+	}
+	raw, err := NewRawBlock(block, txs, height)
+	if err != nil {
+		log.Error("Create raw block failed, ", err)
+		return
+	}
+	for _, subscriber := range bc.rawSubscribers {
+		listener := subscriber.listener
+		subscriber.queue <- func() { listener.OnRawBlock(raw) }
+	}
+}
+
+func (bc *Blockchain) notifyRawRollback(height uint32) {
+	for _, subscriber := range bc.rawSubscribers {
+		listener := subscriber.listener
+		subscriber.queue <- func() { listener.OnRawRollback(height) }
+	}
+}
@@ -0,0 +1,61 @@
+package sdk
+
+import (
+	"bytes"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// The structured JSON view of a transaction is defined with the transaction,
+// so a transaction is marshalled to JSON in the same view
+type (
+	TransactionInfo = tx.TransactionInfo
+	RecordInfo      = tx.RecordInfo
+	AttributeInfo   = tx.AttributeInfo
+	InputInfo       = tx.InputInfo
+	OutputInfo      = tx.OutputInfo
+	ProgramInfo     = tx.ProgramInfo
+)
+
+// Decode the raw transaction in hex string into the structured view
+func DecodeRawTransaction(rawHex string) (*TransactionInfo, error) {
+	data, err := HexStringToBytes(rawHex)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "invalid raw transaction hex string")
+	}
+
+	var txn tx.Transaction
+	err = txn.Deserialize(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "deserialize transaction failed, "+err.Error())
+	}
+
+	return NewTransactionInfo(&txn)
+}
+
+// Encode the structured view of a transaction into raw transaction hex string
+func EncodeTransaction(info *TransactionInfo) (string, error) {
+	txn, err := info.ToTransaction()
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	err = txn.Serialize(buf)
+	if err != nil {
+		return "", err
+	}
+	return BytesToHexString(buf.Bytes()), nil
+}
+
+// Get the structured view of the transaction
+func NewTransactionInfo(txn *tx.Transaction) (*TransactionInfo, error) {
+	return tx.NewTransactionInfo(txn)
+}
+
+// Get the record carried by the transaction, nil if it's not a record transaction
+func GetRecord(txn *tx.Transaction) *RecordInfo {
+	return tx.GetRecord(txn)
+}
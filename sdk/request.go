@@ -1,10 +1,12 @@
 package sdk
 
 import (
-	"errors"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
 	"github.com/elastos/Elastos.ELA.SPV/p2p"
 )
 
@@ -13,49 +15,71 @@ const (
 	MaxRetryTimes  = 3
 )
 
+var (
+	// The peer responded a notfound message, it does not have the requested data
+	ErrNotFound = errors.Wrap(errors.ErrNotFound, "requested data not found")
+
+	// The peer did not respond to the request after retries
+	ErrRequestTimeout = errors.Wrap(errors.ErrTimeout, "request timeout")
+)
+
+// RequestError is the error of a failed data request, Err is ErrNotFound or ErrRequestTimeout
+// so the requester can tell a peer lacking the data from a peer not responding
+type RequestError struct {
+	Type msg.InvType
+	Hash Uint256
+	Err  error
+}
+
+func (e *RequestError) Error() string {
+	return e.Type.String() + " request " + e.Hash.String() + " failed, " + e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
 type RequestHandler interface {
-	OnSendRequest(peer *p2p.Peer, reqType uint8, hash Uint256)
-	OnRequestTimeout(Uint256)
+	// Send the data request to the peer
+	OnSendRequest(peer *p2p.Peer, reqType msg.InvType, hash Uint256)
+
+	// The request failed on all the peers tried
+	OnRequestFailed(err *RequestError)
+
+	// The connected peers a failed request can be retried on
+	RetryPeers() []*p2p.Peer
 }
 
+// Request is a data request tracked by the RequestTracker
 type Request struct {
 	peer       *p2p.Peer
 	hash       Uint256
-	reqType    uint8
+	reqType    msg.InvType
 	retryTimes int
-	doneChan   chan byte
-	handler    RequestHandler
-}
-
-func (r *Request) Start() error {
-	if r.handler == nil {
-		return errors.New("RequestHandler not set")
-	}
-	r.doneChan = make(chan byte)
-	go r.sendRequest()
-	return nil
-}
-
-func (r *Request) sendRequest() {
-	r.handler.OnSendRequest(r.peer, r.reqType, r.hash)
-	timer := time.NewTimer(time.Second * RequestTimeout)
-	select {
-	case <-timer.C:
-		if r.retryTimes >= MaxRetryTimes {
-			r.Finish()
-			r.handler.OnRequestTimeout(r.hash)
-			break
-		}
-		r.retryTimes++
-		r.sendRequest()
-	case <-r.doneChan:
-		timer.Stop()
-	}
+	tried      map[uint64]bool
+	sent       time.Time
+	tracker    *RequestTracker
+}
+
+// Get the peer the request is currently sent to
+func (r *Request) Peer() *p2p.Peer {
+	r.tracker.Lock()
+	defer r.tracker.Unlock()
+
+	return r.peer
 }
 
+// Stop tracking the request, when the requested data is received or the request is abandoned
 func (r *Request) Finish() {
-	if r.doneChan != nil {
-		r.doneChan <- 1
-		r.doneChan = nil
-	}
+	r.tracker.finish(r)
+}
+
+// Stop tracking the request answered by the peer, and record the latency of it
+func (r *Request) Received() {
+	r.tracker.Lock()
+	peer, latency := r.peer, clock.Since(r.sent)
+	r.tracker.Unlock()
+
+	observeLatency(peer, r.reqType, latency)
+	r.Finish()
 }
@@ -1,25 +1,45 @@
 package sdk
 
 import (
-	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
 	"github.com/elastos/Elastos.ELA.SPV/p2p"
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
 )
 
+var bufferedBytes = metrics.NewGauge("spv_download_buffered_bytes",
+	"Bytes of the blocks and transactions downloaded and buffered before committed")
+
 type RequestQueueHandler interface {
-	OnSendRequest(peer *p2p.Peer, reqType uint8, hash Uint256)
+	OnSendRequest(peer *p2p.Peer, reqType msg.InvType, hash Uint256)
 	OnRequestError(error)
 	OnRequestFinished(*FinishedReqPool)
+	RetryPeers() []*p2p.Peer
+	DownloadPeer(syncPeer *p2p.Peer, hash Uint256) *p2p.Peer
+	TxPeer(peer *p2p.Peer, txId Uint256) *p2p.Peer
 }
 
 type RequestQueue struct {
+	// Bytes of the blocks and transactions buffered before committed, accessed
+	// atomically, keep it the first field to be 64-bit aligned
+	buffered int64
+	// Transactions requested not received, accessed atomically
+	txsInFlight int32
+
 	size             int
+	limit            int
+	txLimit          int
+	budget           int64
+	limitCond        *sync.Cond
 	peer             *p2p.Peer
 	hashesQueue      chan Uint256
 	blocksQueue      chan Uint256
@@ -30,12 +50,15 @@ type RequestQueue struct {
 	blockTxsRequests map[Uint256]*BlockTxsRequest
 	blockTxs         map[Uint256]Uint256
 	finished         *FinishedReqPool
+	tracker          *RequestTracker
 	handler          RequestQueueHandler
 }
 
 func NewRequestQueue(size int, handler RequestQueueHandler) *RequestQueue {
 	queue := new(RequestQueue)
 	queue.size = size
+	queue.limit = size
+	queue.limitCond = sync.NewCond(new(sync.Mutex))
 	queue.hashesQueue = make(chan Uint256, size)
 	queue.blocksQueue = make(chan Uint256, size)
 	queue.blockTxsQueue = make(chan Uint256, size)
@@ -47,16 +70,18 @@ func NewRequestQueue(size int, handler RequestQueueHandler) *RequestQueue {
 	queue.finished = &FinishedReqPool{
 		blocks:   make(map[Uint256]*bloom.MerkleBlock),
 		requests: make(map[Uint256]*BlockTxsRequest),
+		onPop:    queue.release,
 	}
+	queue.tracker = NewRequestTracker(queue)
 	queue.handler = handler
 
-	go queue.start()
+	supervisor.Go("request queue", queue.start)
 	return queue
 }
 
 func (queue *RequestQueue) start() {
 	for hash := range queue.hashesQueue {
-		queue.StartBlockRequest(queue.peer, hash)
+		queue.StartBlockRequest(queue.handler.DownloadPeer(queue.peer, hash), hash)
 	}
 }
 
@@ -74,22 +99,82 @@ func (queue *RequestQueue) StartBlockRequest(peer *p2p.Peer, hash Uint256) {
 		return
 	}
 	// Block the method when queue is filled
+	queue.waitLimit()
 	queue.blocksQueue <- hash
 
 	queue.blockReqsLock.Lock()
-	// Create a new block request
-	blockRequest := &Request{
-		peer:    peer,
-		hash:    hash,
-		reqType: BLOCK,
-		handler: queue,
+	// Start a block request and add to request queue
+	queue.blockRequests[hash] = queue.tracker.Track(peer, BLOCK, hash)
+	queue.blockReqsLock.Unlock()
+}
+
+// Set the max blocks requested at one time, it can not be greater than the queue size
+func (queue *RequestQueue) SetLimit(limit int) {
+	if limit > queue.size {
+		limit = queue.size
 	}
-	// Add to request queue
-	queue.blockRequests[hash] = blockRequest
-	// Start block request
-	blockRequest.Start()
+	queue.limitCond.L.Lock()
+	queue.limit = limit
+	queue.limitCond.Broadcast()
+	queue.limitCond.L.Unlock()
+}
 
-	queue.blockReqsLock.Unlock()
+/*
+Set the max transactions requested at one time and the max bytes of the blocks and transactions buffered
+before committed, 0 means not limited. Blocks are not requested while any limit is reached, the blocks
+requested before are still received, so a block of many transactions can go over the limits.
+*/
+func (queue *RequestQueue) SetBudget(txLimit int, budget int64) {
+	queue.limitCond.L.Lock()
+	queue.txLimit = txLimit
+	queue.budget = budget
+	queue.limitCond.Broadcast()
+	queue.limitCond.L.Unlock()
+}
+
+// Get the bytes of the blocks and transactions buffered before committed
+func (queue *RequestQueue) Buffered() int64 {
+	return atomic.LoadInt64(&queue.buffered)
+}
+
+// Wait until the blocks requested, the transactions requested and the data buffered are under the limits
+func (queue *RequestQueue) waitLimit() {
+	queue.limitCond.L.Lock()
+	for queue.overLimit() {
+		queue.limitCond.Wait()
+	}
+	queue.limitCond.L.Unlock()
+}
+
+// Check if any limit is reached, the limit lock must be held
+func (queue *RequestQueue) overLimit() bool {
+	if len(queue.blocksQueue) >= queue.limit {
+		return true
+	}
+	if queue.txLimit > 0 && int(atomic.LoadInt32(&queue.txsInFlight)) >= queue.txLimit {
+		return true
+	}
+	return queue.budget > 0 && atomic.LoadInt64(&queue.buffered) >= queue.budget
+}
+
+// Count the bytes buffered, a negative size when released
+func (queue *RequestQueue) addBuffered(size int64) {
+	bufferedBytes.Set(float64(atomic.AddInt64(&queue.buffered, size)))
+	if size < 0 {
+		queue.notifyLimit()
+	}
+}
+
+// Release the bytes buffered of the request popped from the finished pool to commit
+func (queue *RequestQueue) release(request *BlockTxsRequest) {
+	queue.addBuffered(-request.size)
+}
+
+// Wake up the waiting block request after a block request removed from the queue
+func (queue *RequestQueue) notifyLimit() {
+	queue.limitCond.L.Lock()
+	queue.limitCond.Broadcast()
+	queue.limitCond.L.Unlock()
 }
 
 func (queue *RequestQueue) StartBlockTxsRequest(peer *p2p.Peer, block *bloom.MerkleBlock, txIds []*Uint256) {
@@ -97,9 +182,12 @@ func (queue *RequestQueue) StartBlockTxsRequest(peer *p2p.Peer, block *bloom.Mer
 	// No block transactions to request, notify request finished.
 	if len(txIds) == 0 {
 		// Notify request finished
+		size := merkleBlockSize(block)
+		queue.addBuffered(size)
 		queue.OnRequestFinished(&BlockTxsRequest{
 			BlockHash: blockHash,
 			Block:     *block,
+			size:      size,
 		})
 		return
 	}
@@ -109,6 +197,9 @@ func (queue *RequestQueue) StartBlockTxsRequest(peer *p2p.Peer, block *bloom.Mer
 	}
 	// Block the method when queue is filled
 	queue.blockTxsQueue <- blockHash
+	size := merkleBlockSize(block)
+	queue.addBuffered(size)
+	atomic.AddInt32(&queue.txsInFlight, int32(len(txIds)))
 
 	queue.blockTxsReqsLock.Lock()
 	txRequestQueue := make(map[Uint256]*Request)
@@ -116,20 +207,14 @@ func (queue *RequestQueue) StartBlockTxsRequest(peer *p2p.Peer, block *bloom.Mer
 		// Mark txId related block
 		queue.blockTxs[*txId] = blockHash
 		// Start a tx request
-		txRequest := &Request{
-			peer:    peer,
-			hash:    *txId,
-			reqType: TRANSACTION,
-			handler: queue,
-		}
-		txRequestQueue[*txId] = txRequest
-		txRequest.Start()
+		txRequestQueue[*txId] = queue.tracker.Track(queue.handler.TxPeer(peer, *txId), TRANSACTION, *txId)
 	}
 
 	blockTxsRequest := &BlockTxsRequest{
 		BlockHash:      blockHash,
 		Block:          *block,
 		txRequestQueue: txRequestQueue,
+		size:           size,
 	}
 
 	queue.blockTxsRequests[blockHash] = blockTxsRequest
@@ -157,16 +242,32 @@ func (queue *RequestQueue) InFinishedPool(blockHash Uint256) bool {
 	return ok
 }
 
+// Check if the data of the hash is requested from the peer
+func (queue *RequestQueue) RequestedFrom(peer *p2p.Peer, hash Uint256) bool {
+	return queue.tracker.RequestedFrom(peer, hash)
+}
+
 func (queue *RequestQueue) IsRunning() bool {
 	return len(queue.hashesQueue) > 0 || len(queue.blocksQueue) > 0 || len(queue.blockTxsQueue) > 0
 }
 
-func (queue *RequestQueue) OnSendRequest(peer *p2p.Peer, reqType uint8, hash Uint256) {
+func (queue *RequestQueue) OnSendRequest(peer *p2p.Peer, reqType msg.InvType, hash Uint256) {
 	queue.handler.OnSendRequest(peer, reqType, hash)
 }
 
-func (queue *RequestQueue) OnRequestTimeout(hash Uint256) {
-	queue.handler.OnRequestError(errors.New("Request timeout with hash: " + hash.String()))
+func (queue *RequestQueue) OnRequestFailed(err *RequestError) {
+	queue.handler.OnRequestError(err)
+}
+
+func (queue *RequestQueue) RetryPeers() []*p2p.Peer {
+	return queue.handler.RetryPeers()
+}
+
+// Handle the notfound message from the peer, the request is retried on other peers
+// and reported as failed with ErrNotFound when no peer has the data.
+// Return false if the hash is not requested from the peer
+func (queue *RequestQueue) OnNotFound(peer *p2p.Peer, hash Uint256) bool {
+	return queue.tracker.OnNotFound(peer, hash)
 }
 
 func (queue *RequestQueue) OnBlockReceived(block *bloom.MerkleBlock, txIds []*Uint256) error {
@@ -183,12 +284,13 @@ func (queue *RequestQueue) OnBlockReceived(block *bloom.MerkleBlock, txIds []*Ui
 	}
 
 	// Remove from block request list
-	request.Finish()
+	request.Received()
 	delete(queue.blockRequests, blockHash)
 	<-queue.blocksQueue
+	queue.notifyLimit()
 
-	// Request block transactions
-	queue.StartBlockTxsRequest(request.peer, block, txIds)
+	// Request block transactions from the peer responded the block
+	queue.StartBlockTxsRequest(request.Peer(), block, txIds)
 
 	return nil
 }
@@ -206,11 +308,13 @@ func (queue *RequestQueue) OnTxReceived(tx *tx.Transaction) error {
 
 	// Remove from map
 	delete(queue.blockTxs, txId)
+	atomic.AddInt32(&queue.txsInFlight, -1)
+	queue.notifyLimit()
 
 	var blockTxsRequest *BlockTxsRequest
 	if blockTxsRequest, ok = queue.blockTxsRequests[blockHash]; !ok {
 		queue.blockTxsReqsLock.Unlock()
-		return errors.New("Request not exist with id: " + blockHash.String())
+		return errors.Wrap(errors.ErrNotFound, "Request not exist with id: "+blockHash.String())
 	}
 
 	finished, err := blockTxsRequest.OnTxReceived(tx)
@@ -218,6 +322,9 @@ func (queue *RequestQueue) OnTxReceived(tx *tx.Transaction) error {
 		queue.blockTxsReqsLock.Unlock()
 		return err
 	}
+	size := int64(tx.GetSize())
+	blockTxsRequest.size += size
+	queue.addBuffered(size)
 
 	if finished {
 		delete(queue.blockTxsRequests, blockHash)
@@ -249,6 +356,7 @@ func (queue *RequestQueue) Clear() {
 	for len(queue.blocksQueue) > 0 {
 		<-queue.blocksQueue
 	}
+	queue.notifyLimit()
 	// Clear block txs requests chan
 	for len(queue.blockTxsQueue) > 0 {
 		<-queue.blockTxsQueue
@@ -270,6 +378,23 @@ func (queue *RequestQueue) Clear() {
 	}
 	queue.blockTxsReqsLock.Unlock()
 
+	// Stop tracking the requests not answered
+	queue.tracker.Clear()
+
 	// Clear finished requests pool
 	queue.finished.Clear()
+
+	atomic.StoreInt32(&queue.txsInFlight, 0)
+	atomic.StoreInt64(&queue.buffered, 0)
+	bufferedBytes.Set(0)
+	queue.notifyLimit()
+}
+
+// Get the bytes of the merkleblock buffered
+func merkleBlockSize(block *bloom.MerkleBlock) int64 {
+	buf, err := block.Serialize()
+	if err != nil {
+		return 0
+	}
+	return int64(len(buf))
 }
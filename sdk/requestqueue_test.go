@@ -0,0 +1,118 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+type queueHandler struct {
+	*trackerHandler
+}
+
+func (h *queueHandler) OnRequestError(error)               {}
+func (h *queueHandler) OnRequestFinished(*FinishedReqPool) {}
+func (h *queueHandler) DownloadPeer(syncPeer *p2p.Peer, hash Uint256) *p2p.Peer {
+	return syncPeer
+}
+func (h *queueHandler) TxPeer(peer *p2p.Peer, txId Uint256) *p2p.Peer {
+	return peer
+}
+
+// Wait until the count of blocks requested reaches the expected count
+func waitRequests(queue *RequestQueue, count int) int {
+	for i := 0; i < 100 && queue.tracker.Length() != count; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	time.Sleep(time.Millisecond * 50)
+	return queue.tracker.Length()
+}
+
+func TestRequestQueueLimit(t *testing.T) {
+	handler := &queueHandler{newTrackerHandler(1)}
+	queue := NewRequestQueue(MaxRequests, handler)
+	defer queue.Clear()
+
+	queue.SetLimit(2)
+	var hashes []Uint256
+	for i := 0; i < 5; i++ {
+		hashes = append(hashes, Uint256{byte(i + 1)})
+	}
+	go queue.PushHashes(handler.peers[0], hashes)
+	if count := waitRequests(queue, 2); count != 2 {
+		t.Fatalf("%d blocks requested, expect 2 under the limit", count)
+	}
+
+	// Waiting requests are sent when the limit raised
+	queue.SetLimit(MaxRequests)
+	if count := waitRequests(queue, 5); count != 5 {
+		t.Fatalf("%d blocks requested, expect 5", count)
+	}
+}
+
+// Wait until the block is requested or timeout
+func waitBlockRequest(queue *RequestQueue, hash Uint256) bool {
+	for i := 0; i < 100 && !queue.InBlockRequestQueue(hash); i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	return queue.InBlockRequestQueue(hash)
+}
+
+func TestRequestQueueBudget(t *testing.T) {
+	log.Init()
+	handler := &queueHandler{newTrackerHandler(1)}
+	queue := NewRequestQueue(MaxRequests, handler)
+	defer queue.Clear()
+	peer := handler.peers[0]
+
+	var txns []*tx.Transaction
+	var txIds []*Uint256
+	for i := 0; i < 2; i++ {
+		txn := &tx.Transaction{TxType: tx.TransferAsset, Payload: new(payload.TransferAsset), LockTime: uint32(i)}
+		txns = append(txns, txn)
+		txIds = append(txIds, txn.Hash())
+	}
+	block := &bloom.MerkleBlock{BlockHeader: core.Header{Height: 1}, Transactions: 2, Hashes: txIds, Flags: []byte{0x01}}
+
+	// No blocks requested while the transactions requested reach the limit
+	queue.SetBudget(2, 0)
+	queue.StartBlockTxsRequest(peer, block, txIds)
+	go queue.PushHashes(peer, []Uint256{{0xff}})
+	if waitBlockRequest(queue, Uint256{0xff}) {
+		t.Fatal("block requested over the transactions limit")
+	}
+	queue.OnTxReceived(txns[0])
+	if !waitBlockRequest(queue, Uint256{0xff}) {
+		t.Fatal("block not requested under the transactions limit")
+	}
+
+	// No blocks requested while the data buffered reaches the budget
+	queue.OnTxReceived(txns[1])
+	buffered := merkleBlockSize(block) + int64(txns[0].GetSize()+txns[1].GetSize())
+	if queue.Buffered() != buffered {
+		t.Fatalf("%d bytes buffered, expect %d", queue.Buffered(), buffered)
+	}
+	queue.SetBudget(0, buffered)
+	go queue.PushHashes(peer, []Uint256{{0xfe}})
+	if waitBlockRequest(queue, Uint256{0xfe}) {
+		t.Fatal("block requested over the memory budget")
+	}
+
+	// The memory is released when the block popped to commit
+	if _, ok := queue.finished.Next(block.BlockHeader.Previous); !ok {
+		t.Fatal("finished block not in the pool")
+	}
+	if queue.Buffered() != 0 {
+		t.Errorf("%d bytes buffered after the block popped", queue.Buffered())
+	}
+	if !waitBlockRequest(queue, Uint256{0xfe}) {
+		t.Fatal("block not requested under the memory budget")
+	}
+}
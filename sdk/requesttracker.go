@@ -0,0 +1,179 @@
+package sdk
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+/*
+RequestTracker records every data request sent to peers until it's answered. A request not answered
+in RequestTimeout seconds or answered with a notfound message is sent again to another connected peer,
+after MaxRetryTimes retries the request is dropped and the error is reported to the handler,
+so no request is lost silently.
+*/
+type RequestTracker struct {
+	sync.Mutex
+	timeout  time.Duration
+	requests map[Uint256]*Request
+	timers   map[Uint256]clock.Timer
+	handler  RequestHandler
+}
+
+func NewRequestTracker(handler RequestHandler) *RequestTracker {
+	return &RequestTracker{
+		timeout:  time.Second * RequestTimeout,
+		requests: make(map[Uint256]*Request),
+		timers:   make(map[Uint256]clock.Timer),
+		handler:  handler,
+	}
+}
+
+// Send a data request to the peer and track it, return the tracked request
+func (t *RequestTracker) Track(peer *p2p.Peer, reqType msg.InvType, hash Uint256) *Request {
+	t.Lock()
+	request, ok := t.requests[hash]
+	if !ok {
+		request = &Request{
+			hash:    hash,
+			reqType: reqType,
+			tried:   make(map[uint64]bool),
+			tracker: t,
+		}
+		t.requests[hash] = request
+	}
+	t.send(request, peer)
+	t.Unlock()
+
+	t.handler.OnSendRequest(peer, reqType, hash)
+	return request
+}
+
+// Check if the data of the hash is requested from the peer
+func (t *RequestTracker) RequestedFrom(peer *p2p.Peer, hash Uint256) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	request, ok := t.requests[hash]
+	return ok && request.tried[peer.ID()]
+}
+
+// Get the count of the requests waiting for response
+func (t *RequestTracker) Length() int {
+	t.Lock()
+	defer t.Unlock()
+
+	return len(t.requests)
+}
+
+// Handle the notfound message from the peer, retry the request on another peer.
+// Return false if the hash is not requested from the peer
+func (t *RequestTracker) OnNotFound(peer *p2p.Peer, hash Uint256) bool {
+	t.Lock()
+	request, ok := t.requests[hash]
+	if !ok || !request.tried[peer.ID()] {
+		t.Unlock()
+		return false
+	}
+	t.retry(request, ErrNotFound)
+	return true
+}
+
+// Stop tracking all the requests
+func (t *RequestTracker) Clear() {
+	t.Lock()
+	defer t.Unlock()
+
+	for hash, timer := range t.timers {
+		timer.Stop()
+		delete(t.timers, hash)
+	}
+	for hash := range t.requests {
+		delete(t.requests, hash)
+	}
+}
+
+// Mark the request as sent to the peer and start the timeout timer, must be called with the lock held
+func (t *RequestTracker) send(request *Request, peer *p2p.Peer) {
+	request.peer = peer
+	request.tried[peer.ID()] = true
+	request.sent = clock.Now()
+
+	if timer, ok := t.timers[request.hash]; ok {
+		timer.Stop()
+	}
+	retryTimes := request.retryTimes
+	t.timers[request.hash] = clock.AfterFunc(t.timeout, func() {
+		t.onTimeout(request, retryTimes)
+	})
+}
+
+func (t *RequestTracker) onTimeout(request *Request, retryTimes int) {
+	t.Lock()
+	// Ignore the timer of a finished request or a previous try
+	if t.requests[request.hash] != request || request.retryTimes != retryTimes {
+		t.Unlock()
+		return
+	}
+	t.retry(request, ErrRequestTimeout)
+}
+
+// Send the request to a peer not tried yet, or the current peer again on timeout if no other peers.
+// Report the error if retries exhausted. Must be called with the lock held, the lock is released on return
+func (t *RequestTracker) retry(request *Request, reason error) {
+	var peer *p2p.Peer
+	if request.retryTimes < MaxRetryTimes {
+		peer = t.nextPeer(request)
+		if peer == nil && reason == ErrRequestTimeout {
+			peer = request.peer
+		}
+	}
+
+	if peer == nil {
+		t.remove(request)
+		t.Unlock()
+
+		log.Warnf("Request %s %s failed, %s", request.reqType, request.hash.String(), reason)
+		t.handler.OnRequestFailed(&RequestError{Type: request.reqType, Hash: request.hash, Err: reason})
+		return
+	}
+
+	request.retryTimes++
+	t.send(request, peer)
+	t.Unlock()
+
+	log.Debugf("Retry %s request %s on peer %d, %s", request.reqType, request.hash.String(), peer.ID(), reason)
+	t.handler.OnSendRequest(peer, request.reqType, request.hash)
+}
+
+// Get an established peer the request has not been sent to
+func (t *RequestTracker) nextPeer(request *Request) *p2p.Peer {
+	for _, peer := range t.handler.RetryPeers() {
+		if peer.State() == p2p.ESTABLISH && !request.tried[peer.ID()] {
+			return peer
+		}
+	}
+	return nil
+}
+
+func (t *RequestTracker) finish(request *Request) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.requests[request.hash] == request {
+		t.remove(request)
+	}
+}
+
+func (t *RequestTracker) remove(request *Request) {
+	if timer, ok := t.timers[request.hash]; ok {
+		timer.Stop()
+		delete(t.timers, request.hash)
+	}
+	delete(t.requests, request.hash)
+}
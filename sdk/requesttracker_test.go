@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+type trackerHandler struct {
+	sync.Mutex
+	peers  []*p2p.Peer
+	sent   []uint64
+	failed chan *RequestError
+}
+
+func (h *trackerHandler) OnSendRequest(peer *p2p.Peer, reqType msg.InvType, hash Uint256) {
+	h.Lock()
+	h.sent = append(h.sent, peer.ID())
+	h.Unlock()
+}
+
+func (h *trackerHandler) OnRequestFailed(err *RequestError) {
+	h.failed <- err
+}
+
+func (h *trackerHandler) RetryPeers() []*p2p.Peer {
+	return h.peers
+}
+
+func (h *trackerHandler) sentTo() []uint64 {
+	h.Lock()
+	defer h.Unlock()
+	return append([]uint64{}, h.sent...)
+}
+
+func newTrackerHandler(peers int) *trackerHandler {
+	handler := &trackerHandler{failed: make(chan *RequestError, 1)}
+	for i := 0; i < peers; i++ {
+		peer := new(p2p.Peer)
+		peer.SetID(uint64(i + 1))
+		peer.SetState(p2p.ESTABLISH)
+		handler.peers = append(handler.peers, peer)
+	}
+	return handler
+}
+
+func TestRequestTrackerNotFound(t *testing.T) {
+	handler := newTrackerHandler(2)
+	tracker := NewRequestTracker(handler)
+	hash := Uint256{1}
+
+	tracker.Track(handler.peers[0], BLOCK, hash)
+	if tracker.RequestedFrom(handler.peers[1], hash) {
+		t.Fatal("request not sent to peer 2 yet")
+	}
+
+	// Retried on the other peer
+	if !tracker.OnNotFound(handler.peers[0], hash) {
+		t.Fatal("notfound of the request not handled")
+	}
+	if !tracker.RequestedFrom(handler.peers[1], hash) {
+		t.Fatal("request not retried on peer 2")
+	}
+
+	// No more peers to try
+	tracker.OnNotFound(handler.peers[1], hash)
+	select {
+	case err := <-handler.failed:
+		if err.Err != ErrNotFound || err.Hash != hash || err.Type != BLOCK {
+			t.Fatalf("unexpected request error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request failure not reported")
+	}
+	if tracker.Length() != 0 {
+		t.Fatal("failed request still tracked")
+	}
+	if sent := handler.sentTo(); len(sent) != 2 || sent[0] != 1 || sent[1] != 2 {
+		t.Fatalf("unexpected requests sent %v", sent)
+	}
+}
+
+func TestRequestTrackerTimeout(t *testing.T) {
+	handler := newTrackerHandler(1)
+	tracker := NewRequestTracker(handler)
+	tracker.timeout = time.Millisecond * 10
+	hash := Uint256{2}
+
+	tracker.Track(handler.peers[0], TRANSACTION, hash)
+	select {
+	case err := <-handler.failed:
+		if err.Err != ErrRequestTimeout {
+			t.Fatalf("unexpected request error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request timeout not reported")
+	}
+	// Retried on the only peer
+	if sent := handler.sentTo(); len(sent) != MaxRetryTimes+1 {
+		t.Fatalf("request sent %d times, expect %d", len(sent), MaxRetryTimes+1)
+	}
+
+	// Finished request is not reported
+	request := tracker.Track(handler.peers[0], TRANSACTION, hash)
+	request.Finish()
+	select {
+	case err := <-handler.failed:
+		t.Fatalf("finished request reported %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -0,0 +1,74 @@
+package sdk
+
+import "github.com/elastos/Elastos.ELA.SPV/log"
+
+/*
+Load the bloom filter to the connected peers again after addresses are added to the wallet, like the
+addresses derived by an HD wallet, and rescan the recent blocks of the depth for the transactions paid
+to them before. The rescan rolls the chain back by the depth, the blocks are downloaded and committed
+again with the new filter. 0 means no rescan, a rescan is deferred on a metered connection.
+*/
+func (service *SPVServiceImpl) RefreshFilter(rescanDepth uint32) {
+	service.Lock()
+	defer service.Unlock()
+
+	if rescanDepth > service.rescanDepth {
+		service.rescanDepth = rescanDepth
+	}
+	if service.rescanDepth == 0 {
+		service.reloadFilter()
+		return
+	}
+	if service.metered {
+		service.reloadFilter()
+		service.rescanPending = true
+		log.Info("Connection metered, rescan deferred")
+		return
+	}
+	service.resync()
+}
+
+// Roll the chain back for the rescan requested, the service lock must be held
+func (service *SPVServiceImpl) rewind() {
+	if service.rescanDepth == 0 {
+		return
+	}
+	depth := service.rescanDepth
+	service.rescanDepth = 0
+
+	height, err := service.chain.rewind(depth)
+	if err != nil {
+		log.Error("Roll back the chain for rescan failed, ", err)
+		return
+	}
+	log.Infof("Rescan the blocks from height %d", height+1)
+}
+
+// Roll the chain tip back by the depth, not below the first header stored, the blocks above are
+// committed again by the next sync. Return the height of the new tip
+func (bc *Blockchain) rewind(depth uint32) (uint32, error) {
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	tip := bc.chainTip()
+	header := tip
+	for i := uint32(0); i < depth && header.Height > 1; i++ {
+		previous, err := bc.GetPrevious(header)
+		if err != nil {
+			break
+		}
+		header = previous
+	}
+	if header == tip {
+		return tip.Height, nil
+	}
+
+	if err := bc.rollbackTo(header.Height); err != nil {
+		return tip.Height, err
+	}
+	if err := bc.PutHeader(header, true); err != nil {
+		return tip.Height, err
+	}
+	bc.updateSnapshot(header, false)
+	return header.Height, nil
+}
@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// A DataStore keeping the headers in memory, recording the heights rolled back
+type headerStore struct {
+	db.DataStore
+	headers    map[Uint256]*db.StoreHeader
+	tip        *db.StoreHeader
+	height     uint32
+	rolledBack []uint32
+}
+
+func (s *headerStore) PutHeader(header *db.StoreHeader, newTip bool) error {
+	s.headers[*header.Hash()] = header
+	if newTip {
+		s.tip = header
+	}
+	return nil
+}
+
+func (s *headerStore) GetPrevious(header *db.StoreHeader) (*db.StoreHeader, error) {
+	return s.GetHeader(header.Previous)
+}
+
+func (s *headerStore) GetHeader(hash Uint256) (*db.StoreHeader, error) {
+	header, ok := s.headers[hash]
+	if !ok {
+		return nil, errors.Wrap(errors.ErrNotFound, "header not found")
+	}
+	return header, nil
+}
+
+func (s *headerStore) GetChainTip() (*db.StoreHeader, error) { return s.tip, nil }
+func (s *headerStore) PutChainHeight(height uint32)          { s.height = height }
+func (s *headerStore) GetChainHeight() uint32                { return s.height }
+
+func (s *headerStore) Rollback(height uint32) error {
+	s.rolledBack = append(s.rolledBack, height)
+	return nil
+}
+
+func TestChainRewind(t *testing.T) {
+	store := &headerStore{headers: make(map[Uint256]*db.StoreHeader)}
+	var previous Uint256
+	for height := uint32(1); height <= 10; height++ {
+		header := &db.StoreHeader{Header: core.Header{Height: height, Previous: previous}, TotalWork: big.NewInt(int64(height))}
+		store.PutHeader(header, true)
+		store.PutChainHeight(height)
+		previous = *header.Hash()
+	}
+	chain := &Blockchain{lock: new(sync.RWMutex), DataStore: store}
+
+	height, err := chain.rewind(3)
+	if err != nil || height != 7 {
+		t.Fatalf("rewind to height %d, %v, expect height 7", height, err)
+	}
+	if chain.Height() != 7 || chain.ChainTip().Height != 7 {
+		t.Errorf("chain at height %d, tip %d after rewind", chain.Height(), chain.ChainTip().Height)
+	}
+	if len(store.rolledBack) != 3 || store.rolledBack[0] != 10 || store.rolledBack[2] != 8 {
+		t.Errorf("heights %v rolled back, expect 10 to 8", store.rolledBack)
+	}
+
+	// Not rolled back below the first header
+	if height, err := chain.rewind(100); err != nil || height != 1 {
+		t.Errorf("rewind to height %d, %v, expect height 1", height, err)
+	}
+}
@@ -0,0 +1,230 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+/*
+Rule is a consensus or payload change activated from a block height, headers and transactions
+are validated with the rules active at their height, so the client follows the network upgrades
+by updating the rule table instead of forking the code.
+*/
+type Rule struct {
+	// Name of the rule, the activation height can be overridden by name
+	Name string
+	// Height the rule is activated from
+	Height uint32
+	// Min version of the block headers from the activation height, 0 means not checked
+	MinHeaderVersion uint32
+	// Transaction types introduced by the rule, they are invalid before the activation height
+	TxTypes []tx.TransactionType
+	// Max payload version of the transactions from the activation height, 0 means not checked
+	MaxPayloadVersion byte
+	// Replay protection, transactions from the activation height must carry
+	// a Nonce attribute of this data, so they are not valid on the other side of a fork
+	ReplayMarker []byte
+}
+
+// Rules is the activation height ordered rule table of a network
+type Rules []Rule
+
+/*
+NetworkParams are the parameters of a peer to peer network, the rules are consulted
+by the header and transaction validation of the blockchain. A private deployment or a sidechain
+with it's own genesis block sets the Genesis header, so the blockchain syncs only the chain extends it.
+*/
+type NetworkParams struct {
+	Name  string
+	Magic uint32
+	Rules Rules
+	// Genesis block header, the first block synced must extend it, nil means any first block accepted
+	Genesis *core.Header
+	// Follow a sidechain merge mined with the main chain, the headers carry the SideAuxPow
+	SideChain bool
+	// Consensus checking the headers, nil means the proof of work of the main chain or the sidechain
+	Consensus Consensus
+	// Transaction types of the chain, nil means the ones of the main chain or the sidechains
+	Payloads tx.PayloadSet
+}
+
+// Get the consensus checking the headers of the network
+func (params *NetworkParams) GetConsensus() Consensus {
+	switch {
+	case params.Consensus != nil:
+		return params.Consensus
+	case params.SideChain:
+		return SideChainConsensus{}
+	}
+	return MainChainConsensus{}
+}
+
+// Get the transaction types of the network
+func (params *NetworkParams) GetPayloads() tx.PayloadSet {
+	switch {
+	case params.Payloads != nil:
+		return params.Payloads
+	case params.SideChain:
+		return tx.SideChainPayloads
+	}
+	return tx.MainChainPayloads
+}
+
+/*
+Decode the headers and transactions in the format of the network, the main chain format by default.
+It's process wide, call it before any data stored or received is decoded, then set the consensus
+and genesis of the params to the blockchain.
+*/
+func UseNetworkParams(params NetworkParams) {
+	core.SetSideChain(params.SideChain)
+	tx.SetPayloadSet(params.GetPayloads())
+}
+
+// The main net and test net have no built-in rules, the rules of an upgrade are added by Rules.Merge(),
+// like the wallet adds the rules in the config file
+var MainNetParams = NetworkParams{
+	Name:  TypeMainNet,
+	Magic: MainNetMagic,
+}
+
+var TestNetParams = NetworkParams{
+	Name:  TypeTestNet,
+	Magic: TestNetMagic,
+}
+
+var (
+	networksLock sync.RWMutex
+	networks     = make(map[uint32]NetworkParams)
+)
+
+// Register the params of a private network or a sidechain, GetNetworkParams() returns them by the magic
+func RegisterNetworkParams(params NetworkParams) {
+	networksLock.Lock()
+	defer networksLock.Unlock()
+
+	networks[params.Magic] = params
+}
+
+// Get the network params of the magic number, a private network not registered has no rules
+func GetNetworkParams(magic uint32) NetworkParams {
+	networksLock.RLock()
+	params, ok := networks[magic]
+	networksLock.RUnlock()
+	if ok {
+		return params
+	}
+
+	switch magic {
+	case 0, MainNetMagic:
+		return MainNetParams
+	case TestNetMagic:
+		return TestNetParams
+	}
+	return NetworkParams{Name: fmt.Sprintf("Magic%d", magic), Magic: magic}
+}
+
+// Return a copy of the rules with the activation heights overridden by rule names
+func (rules Rules) WithHeights(heights map[string]uint32) Rules {
+	copied := make(Rules, len(rules))
+	copy(copied, rules)
+	for i, rule := range copied {
+		if height, ok := heights[rule.Name]; ok {
+			copied[i].Height = height
+		}
+	}
+	sort.SliceStable(copied, func(i, j int) bool {
+		return copied[i].Height < copied[j].Height
+	})
+	return copied
+}
+
+// Return the rules with the added ones, an added rule replaces the rule of the same name
+func (rules Rules) Merge(added Rules) Rules {
+	merged := make(Rules, 0, len(rules)+len(added))
+	for _, rule := range rules {
+		replaced := false
+		for _, a := range added {
+			if a.Name == rule.Name {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, rule)
+		}
+	}
+	merged = append(merged, added...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Height < merged[j].Height
+	})
+	return merged
+}
+
+// Check if the rule of the name is active at the height
+func (rules Rules) Active(name string, height uint32) bool {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return height >= rule.Height
+		}
+	}
+	return false
+}
+
+// Get the replay marker transactions at the height must carry, nil if not required
+func (rules Rules) ReplayMarker(height uint32) []byte {
+	var marker []byte
+	for _, rule := range rules {
+		if height >= rule.Height && rule.ReplayMarker != nil {
+			marker = rule.ReplayMarker
+		}
+	}
+	return marker
+}
+
+// Check the header with the rules active at it's height
+func (rules Rules) CheckHeader(header *core.Header) error {
+	for _, rule := range rules {
+		if header.Height < rule.Height {
+			continue
+		}
+		if rule.MinHeaderVersion > 0 && header.Version < rule.MinHeaderVersion {
+			return errors.Wrapf(errors.ErrInvalid, "[Rules], header version %d lower than %d required by %s from height %d",
+				header.Version, rule.MinHeaderVersion, rule.Name, rule.Height)
+		}
+	}
+	return nil
+}
+
+// Check the transaction with the rules active at the height
+func (rules Rules) CheckTransaction(txn *tx.Transaction, height uint32) error {
+	for _, rule := range rules {
+		if height < rule.Height {
+			for _, txType := range rule.TxTypes {
+				if txn.TxType == txType {
+					return errors.Wrapf(errors.ErrInvalid, "[Rules], transaction type %s not activated until %s at height %d",
+						txType.Name(), rule.Name, rule.Height)
+				}
+			}
+			continue
+		}
+		if rule.MaxPayloadVersion > 0 && txn.PayloadVersion > rule.MaxPayloadVersion {
+			return errors.Wrapf(errors.ErrInvalid, "[Rules], payload version %d higher than %d allowed by %s",
+				txn.PayloadVersion, rule.MaxPayloadVersion, rule.Name)
+		}
+	}
+	if marker := rules.ReplayMarker(height); marker != nil && txn.TxType != tx.CoinBase {
+		for _, attr := range txn.Attributes {
+			if attr.Usage == tx.Nonce && bytes.Equal(attr.Data, marker) {
+				return nil
+			}
+		}
+		return errors.Wrapf(errors.ErrInvalid, "[Rules], transaction without replay marker at height %d", height)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+)
+
+func TestRules(t *testing.T) {
+	rules := Rules{
+		{Name: "crosschain", Height: 100, TxTypes: []tx.TransactionType{tx.TransferCrossChainAsset}},
+		{Name: "headerv1", Height: 200, MinHeaderVersion: 1},
+		{Name: "replay", Height: 300, ReplayMarker: []byte("fork")},
+	}
+
+	crossChain := &tx.Transaction{TxType: tx.TransferCrossChainAsset}
+	if err := rules.CheckTransaction(crossChain, 99); err == nil {
+		t.Error("transaction type accepted before activation")
+	}
+	if err := rules.CheckTransaction(crossChain, 100); err != nil {
+		t.Error("transaction type rejected after activation,", err)
+	}
+
+	if err := rules.CheckHeader(&core.Header{Height: 199}); err != nil {
+		t.Error("header rejected before activation,", err)
+	}
+	if err := rules.CheckHeader(&core.Header{Height: 200}); err == nil {
+		t.Error("header version not checked after activation")
+	}
+
+	transfer := &tx.Transaction{TxType: tx.TransferAsset}
+	if err := rules.CheckTransaction(transfer, 300); err == nil {
+		t.Error("transaction without replay marker accepted")
+	}
+	marker := tx.NewAttribute(tx.Nonce, []byte("fork"))
+	transfer.Attributes = append(transfer.Attributes, &marker)
+	if err := rules.CheckTransaction(transfer, 300); err != nil {
+		t.Error("transaction with replay marker rejected,", err)
+	}
+
+	// Override the activation height by name
+	rules = rules.WithHeights(map[string]uint32{"crosschain": 400})
+	if rules.Active("crosschain", 300) || !rules.Active("crosschain", 400) {
+		t.Error("activation height not overridden")
+	}
+	if rules[len(rules)-1].Name != "crosschain" {
+		t.Error("rules not ordered by activation height")
+	}
+
+	// Added rules replace the rules of the same name
+	rules = rules.Merge(Rules{{Name: "headerv1", Height: 50, MinHeaderVersion: 2}, {Name: "payloadv1", Height: 500, MaxPayloadVersion: 1}})
+	if len(rules) != 4 || rules[0].Name != "headerv1" || rules[len(rules)-1].Name != "payloadv1" {
+		t.Fatalf("rules %+v not merged", rules)
+	}
+	if err := rules.CheckHeader(&core.Header{Height: 50, Version: 1}); err == nil {
+		t.Error("header version not checked by the replaced rule")
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Unix(1514764800, 0)
+	clock.Set(clock.NewMock(now))
+	defer clock.Set(clock.System)
+
+	maxTime := uint32(now.Unix() + MaxTimeOffsetSeconds)
+	if err := CheckTimestamp(&core.Header{Timestamp: maxTime}); err != nil {
+		t.Error("header within the max offset rejected,", err)
+	}
+	if err := CheckTimestamp(&core.Header{Timestamp: maxTime + 1}); err == nil {
+		t.Error("header too far in the future accepted")
+	}
+}
+
+func TestRegisterNetworkParams(t *testing.T) {
+	genesis := &core.Header{Timestamp: 1514764800, Bits: 0x207fffff}
+	RegisterNetworkParams(NetworkParams{Name: "private", Magic: 7630401, Genesis: genesis})
+
+	params := GetNetworkParams(7630401)
+	if params.Name != "private" || params.Genesis != genesis {
+		t.Errorf("registered params not returned, got %+v", params)
+	}
+	if params := GetNetworkParams(7630402); params.Genesis != nil || params.Name != "Magic7630402" {
+		t.Errorf("unexpected params %+v of a network not registered", params)
+	}
+}
@@ -0,0 +1,139 @@
+package sdk
+
+import (
+	"math/big"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+// The block locator is updated from the previous one when a block extends the chain tip, it's rebuilt
+// from the headers every this many blocks to keep the exponential spacing of the hashes
+const LocatorRebuildInterval = 1000
+
+// Get a copy of the current chain snapshot, nil if the chain is empty
+func (bc *Blockchain) Snapshot() *db.ChainSnapshot {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	if bc.snapshot == nil {
+		return nil
+	}
+	snapshot := *bc.snapshot
+	snapshot.TotalWork = new(big.Int).Set(bc.snapshot.TotalWork)
+	snapshot.Locator = append([]db.LocatorEntry{}, bc.snapshot.Locator...)
+	return &snapshot
+}
+
+// Load the saved snapshot if it matches the chain tip, otherwise build and save a new one
+func (bc *Blockchain) loadSnapshot() {
+	tip, err := bc.GetChainTip()
+	if err != nil {
+		return
+	}
+
+	if store, ok := bc.DataStore.(db.SnapshotStore); ok {
+		snapshot, err := store.GetChainSnapshot()
+		if err == nil && snapshot.Tip.IsEqual(tip.Hash()) && len(snapshot.Locator) > 0 {
+			bc.snapshot = snapshot
+			return
+		}
+	}
+	bc.updateSnapshot(tip, false)
+}
+
+// Update the snapshot with the new chain tip and save it, extending is true if
+// the new tip is the child of the previous tip
+func (bc *Blockchain) updateSnapshot(tip *db.StoreHeader, extending bool) {
+	tipHash := tip.Hash()
+	var locator []db.LocatorEntry
+	if extending && bc.snapshot != nil && tip.Height%LocatorRebuildInterval != 0 {
+		locator = extendLocator(bc.snapshot.Locator, db.LocatorEntry{Height: tip.Height, Hash: *tipHash})
+	} else {
+		locator = bc.buildLocator(tip)
+	}
+
+	bc.snapshot = &db.ChainSnapshot{
+		Tip:       *tipHash,
+		Height:    tip.Height,
+		TotalWork: tip.TotalWork,
+		Locator:   locator,
+	}
+
+	if store, ok := bc.DataStore.(db.SnapshotStore); ok {
+		if err := store.PutChainSnapshot(bc.snapshot); err != nil {
+			log.Error("Save chain snapshot failed, ", err)
+		}
+	}
+}
+
+// Build the block locator by walking back the headers from the tip
+func (bc *Blockchain) buildLocator(tip *db.StoreHeader) []db.LocatorEntry {
+	var locator []db.LocatorEntry
+	header := tip
+	step := 1
+	start := 0
+	for {
+		if start >= 9 {
+			step *= 2
+			start = 0
+		}
+		locator = append(locator, db.LocatorEntry{Height: header.Height, Hash: *header.Hash()})
+		if len(locator) >= MaxBlockLocatorHashes {
+			break
+		}
+		var err error
+		for i := 0; i < step && err == nil; i++ {
+			header, err = bc.GetPrevious(header)
+		}
+		if err != nil {
+			break
+		}
+		start += 1
+	}
+	return locator
+}
+
+/*
+Extend the block locator with the new tip. The hashes of the locator built by buildLocator are
+dense near the tip and the spacing doubles every 9 hashes, for each height the new locator
+should include, the nearest hash of the previous locator at or below it is taken, so the new
+locator is built without reading any header.
+*/
+func extendLocator(locator []db.LocatorEntry, tip db.LocatorEntry) []db.LocatorEntry {
+	entries := append([]db.LocatorEntry{tip}, locator...)
+	lowest := entries[len(entries)-1].Height
+
+	extended := make([]db.LocatorEntry, 0, MaxBlockLocatorHashes)
+	next := 0
+	height := int64(tip.Height)
+	step := int64(1)
+	start := 0
+	for height >= int64(lowest) && len(extended) < MaxBlockLocatorHashes {
+		if start >= 9 {
+			step *= 2
+			start = 0
+		}
+		// Skip the entries higher than the target height
+		for next < len(entries) && int64(entries[next].Height) > height {
+			next++
+		}
+		if next == len(entries) {
+			break
+		}
+		extended = append(extended, entries[next])
+		height = int64(entries[next].Height) - step
+		next++
+		start += 1
+	}
+	return extended
+}
+
+// Get the block locator hashes from the snapshot if it's on the chain tip
+func (bc *Blockchain) snapshotLocator(tipHash *Uint256) []*Uint256 {
+	if bc.snapshot == nil || !bc.snapshot.Tip.IsEqual(tipHash) {
+		return nil
+	}
+	return bc.snapshot.LocatorHashes()
+}
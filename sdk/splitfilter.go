@@ -0,0 +1,360 @@
+package sdk
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+const (
+	// Shards the addresses and outpoints are split to if not set
+	DefaultFilterShards = 4
+
+	// Peers each shard is loaded to if not set
+	DefaultFilterRedundancy = 2
+)
+
+/*
+Split filter state. The addresses and outpoints of the wallet are split to shards by their hash, and each
+peer is assigned a slot, it's loaded a filter of the redundancy count of shards in a row from the slot,
+so no single peer learns the full wallet. A block is requested from the download peer and the peers
+covering the other shards, it's committed with the transactions matched by all of them.
+*/
+type splitFilter struct {
+	sync.Mutex
+	shards     int
+	redundancy int
+	elements   func() ([]*Uint168, []*tx.OutPoint)
+	// the slot of the peers by the peer ID
+	slots map[uint64]int
+	// the blocks requested and the peers matched the transactions of them
+	blocks  map[Uint256]*splitBlock
+	txPeers map[Uint256]*p2p.Peer
+}
+
+// A block requested from the peers of the shards, until the merkleblocks of all shards are received
+type splitBlock struct {
+	peers   map[uint64]bool
+	covered []bool
+	txIds   []*Uint256
+	matched map[Uint256]bool
+}
+
+/*
+Split the bloom filter to the shards loaded to the redundancy count of peers each, a peer learns
+redundancy/shards of the wallet. 0 means the default values and a negative shard count turns it off.
+The elements function returns the addresses and outpoints of the wallet to split, the filter function
+of the service is not used while it's on. Blocks are not synced until the connected peers cover all
+shards, and it can not be on in privacy mode.
+*/
+func (service *SPVServiceImpl) SetSplitFilter(shards, redundancy int, elements func() ([]*Uint168, []*tx.OutPoint)) error {
+	if shards < 0 {
+		service.splitLock.Lock()
+		service.split = nil
+		service.splitLock.Unlock()
+		log.Info("Split filter off")
+		service.reloadFilter()
+		return nil
+	}
+
+	if service.IsPrivacyMode() {
+		return errors.Wrap(errors.ErrInvalid, "split filter can not be on in privacy mode")
+	}
+	if service.IsFilterTuning() {
+		return errors.Wrap(errors.ErrInvalid, "split filter can not be on with the filter tuning")
+	}
+	if elements == nil {
+		return errors.Wrap(errors.ErrInvalid, "split filter elements not set")
+	}
+	if shards == 0 {
+		shards = DefaultFilterShards
+	}
+	if redundancy <= 0 {
+		redundancy = DefaultFilterRedundancy
+	}
+	if redundancy > shards {
+		return errors.Wrapf(errors.ErrInvalid, "filter redundancy %d greater than %d shards", redundancy, shards)
+	}
+
+	service.splitLock.Lock()
+	service.split = &splitFilter{
+		shards:     shards,
+		redundancy: redundancy,
+		elements:   elements,
+		slots:      make(map[uint64]int),
+		blocks:     make(map[Uint256]*splitBlock),
+		txPeers:    make(map[Uint256]*p2p.Peer),
+	}
+	service.splitLock.Unlock()
+
+	log.Infof("Split filter on, %d shards loaded to %d peers each", shards, redundancy)
+	service.reloadFilter()
+	return nil
+}
+
+// Check if the bloom filter is split across the peers
+func (service *SPVServiceImpl) IsSplitFilter() bool {
+	return service.getSplit() != nil
+}
+
+func (service *SPVServiceImpl) getSplit() *splitFilter {
+	service.splitLock.Lock()
+	defer service.splitLock.Unlock()
+
+	return service.split
+}
+
+// Get the peer to request the matched transaction of a block from, the peer matched it in split filter mode
+func (service *SPVServiceImpl) TxPeer(peer *p2p.Peer, txId Uint256) *p2p.Peer {
+	if s := service.getSplit(); s != nil {
+		s.Lock()
+		defer s.Unlock()
+
+		if matched, ok := s.txPeers[txId]; ok {
+			delete(s.txPeers, txId)
+			return matched
+		}
+	}
+	return peer
+}
+
+// Request the block also from the peers covering the shards not loaded to the peer, in split filter mode
+func (service *SPVServiceImpl) requestShards(peer *p2p.Peer, hash Uint256) {
+	s := service.getSplit()
+	if s == nil {
+		return
+	}
+	for _, cover := range s.coverPeers(peer, hash, service.PeerManager().ConnectedPeers()) {
+		cover.Send(service.NewDataReq(BLOCK, hash))
+	}
+}
+
+/*
+Merge the merkleblock from a peer of the shards, the transactions matched by all shards are returned when
+the merkleblocks covering all shards are received. Blocks not requested are dropped, and it's an error if
+the block is not requested from the peer.
+*/
+func (service *SPVServiceImpl) reconcileBlock(peer *p2p.Peer, block *bloom.MerkleBlock, txIds []*Uint256) ([]*Uint256, bool, error) {
+	s := service.getSplit()
+	if s == nil {
+		return txIds, true, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	hash := *block.BlockHeader.Hash()
+	b, ok := s.blocks[hash]
+	if !ok {
+		log.Debug("Merkle block not requested: ", hash.String())
+		return nil, false, nil
+	}
+	if !b.peers[peer.ID()] {
+		return nil, false, errors.Wrapf(errors.ErrPeerMisbehaving, "merkle block %s not requested from peer %d",
+			hash.String(), peer.ID())
+	}
+
+	if slot, ok := s.slots[peer.ID()]; ok {
+		for shard := range b.covered {
+			if s.covers(slot, shard) {
+				b.covered[shard] = true
+			}
+		}
+	}
+	for _, txId := range txIds {
+		if !b.matched[*txId] {
+			b.matched[*txId] = true
+			b.txIds = append(b.txIds, txId)
+			s.txPeers[*txId] = peer
+		}
+	}
+	for _, covered := range b.covered {
+		if !covered {
+			return nil, false, nil
+		}
+	}
+	delete(s.blocks, hash)
+	return b.txIds, true, nil
+}
+
+// Check if all shards are loaded to the connected peers, blocks can not be reconciled otherwise
+func (service *SPVServiceImpl) shardsCovered() bool {
+	s := service.getSplit()
+	if s == nil {
+		return true
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	covered := make([]bool, s.shards)
+	for _, peer := range service.PeerManager().ConnectedPeers() {
+		slot, ok := s.slots[peer.ID()]
+		if !ok || peer.State() != p2p.ESTABLISH {
+			continue
+		}
+		for shard := range covered {
+			covered[shard] = covered[shard] || s.covers(slot, shard)
+		}
+	}
+	for _, c := range covered {
+		if !c {
+			return false
+		}
+	}
+	return true
+}
+
+// Drop the blocks requested, when the syncing stopped
+func (service *SPVServiceImpl) clearShards() {
+	if s := service.getSplit(); s != nil {
+		s.Lock()
+		s.blocks = make(map[Uint256]*splitBlock)
+		s.txPeers = make(map[Uint256]*p2p.Peer)
+		s.Unlock()
+	}
+}
+
+// Get the filterload message of the shards loaded to the slot
+func (s *splitFilter) filterLoad(slot int, addrs []*Uint168, outPoints []*tx.OutPoint) *bloom.FilterLoad {
+	var shardAddrs []*Uint168
+	var shardOutPoints []*tx.OutPoint
+	for _, addr := range addrs {
+		if s.covers(slot, s.shard(addr.ToArray())) {
+			shardAddrs = append(shardAddrs, addr)
+		}
+	}
+	for _, op := range outPoints {
+		if s.covers(slot, s.shard(op.Bytes())) {
+			shardOutPoints = append(shardOutPoints, op)
+		}
+	}
+	if len(shardAddrs)+len(shardOutPoints) == 0 {
+		return matchNoneFilter()
+	}
+	return BuildBloomFilter(shardAddrs, shardOutPoints).GetFilterLoadMsg()
+}
+
+// The shard of an address or outpoint, by the hash of it
+func (s *splitFilter) shard(data []byte) int {
+	hash := sha256.Sum256(data)
+	return int(binary.LittleEndian.Uint32(hash[:]) % uint32(s.shards))
+}
+
+// Check if the shard is loaded to the peers of the slot
+func (s *splitFilter) covers(slot, shard int) bool {
+	return (shard-slot+s.shards)%s.shards < s.redundancy
+}
+
+/*
+Get the slot of the peer. A new peer is assigned the slot whose shards are loaded to the fewest peers,
+so the shards are covered by as few peers as possible and loaded to more peers as they are connected.
+The slots of the peers disconnected are released.
+*/
+func (s *splitFilter) slot(peer *p2p.Peer, connected []*p2p.Peer) int {
+	s.Lock()
+	defer s.Unlock()
+
+	if slot, ok := s.slots[peer.ID()]; ok {
+		return slot
+	}
+
+	alive := make(map[uint64]bool)
+	for _, p := range connected {
+		alive[p.ID()] = true
+	}
+	loaded := make([]int, s.shards)
+	for id, slot := range s.slots {
+		if !alive[id] {
+			delete(s.slots, id)
+			continue
+		}
+		for shard := range loaded {
+			if s.covers(slot, shard) {
+				loaded[shard]++
+			}
+		}
+	}
+
+	best, bestLoaded := 0, -1
+	for slot := 0; slot < s.shards; slot++ {
+		var count int
+		for shard := range loaded {
+			if s.covers(slot, shard) {
+				count += loaded[shard]
+			}
+		}
+		if bestLoaded < 0 || count < bestLoaded {
+			best, bestLoaded = slot, count
+		}
+	}
+	s.slots[peer.ID()] = best
+	return best
+}
+
+// Get the peers to request the block from for the shards not received or loaded to the peer,
+// the peer the block is requested from is counted as well
+func (s *splitFilter) coverPeers(peer *p2p.Peer, hash Uint256, connected []*p2p.Peer) []*p2p.Peer {
+	s.Lock()
+	defer s.Unlock()
+
+	b, ok := s.blocks[hash]
+	if !ok {
+		b = &splitBlock{
+			peers:   make(map[uint64]bool),
+			covered: make([]bool, s.shards),
+			matched: make(map[Uint256]bool),
+		}
+		s.blocks[hash] = b
+	}
+	b.peers[peer.ID()] = true
+
+	missing := make(map[int]bool)
+	slot, ok := s.slots[peer.ID()]
+	for shard, covered := range b.covered {
+		if !covered && (!ok || !s.covers(slot, shard)) {
+			missing[shard] = true
+		}
+	}
+
+	// Pick the peer covering the most missing shards until all covered
+	var covers []*p2p.Peer
+	for len(missing) > 0 {
+		var best *p2p.Peer
+		var bestCount int
+		for _, p := range connected {
+			slot, ok := s.slots[p.ID()]
+			if !ok || p.ID() == peer.ID() || p.State() != p2p.ESTABLISH {
+				continue
+			}
+			var count int
+			for shard := range missing {
+				if s.covers(slot, shard) {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = p, count
+			}
+		}
+		if best == nil {
+			log.Warnf("Shards of block %s not covered by the connected peers", hash.String())
+			break
+		}
+		for shard := range missing {
+			if s.covers(s.slots[best.ID()], shard) {
+				delete(missing, shard)
+			}
+		}
+		b.peers[best.ID()] = true
+		covers = append(covers, best)
+	}
+	return covers
+}
@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+func newSplitFilter(shards, redundancy int) *splitFilter {
+	return &splitFilter{
+		shards:     shards,
+		redundancy: redundancy,
+		slots:      make(map[uint64]int),
+		blocks:     make(map[Uint256]*splitBlock),
+		txPeers:    make(map[Uint256]*p2p.Peer),
+	}
+}
+
+func splitPeers(count int) []*p2p.Peer {
+	var peers []*p2p.Peer
+	for i := 0; i < count; i++ {
+		peer := new(p2p.Peer)
+		peer.SetID(uint64(i + 1))
+		peer.SetState(p2p.ESTABLISH)
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+func TestSplitFilterSlots(t *testing.T) {
+	s := newSplitFilter(4, 2)
+	peers := splitPeers(5)
+
+	// The shards are covered by the fewest peers, then loaded to more peers
+	for i, expect := range []int{0, 2, 0, 2} {
+		if slot := s.slot(peers[i], peers); slot != expect {
+			t.Errorf("peer %d assigned slot %d, expect %d", i, slot, expect)
+		}
+	}
+	if s.slot(peers[1], peers) != 2 {
+		t.Error("slot of the peer changed")
+	}
+
+	// The slots of the disconnected peers are released
+	s.slot(peers[4], peers[1:])
+	if _, ok := s.slots[peers[0].ID()]; ok {
+		t.Error("slot of the disconnected peer not released")
+	}
+
+	// A peer gets only the elements of its shards
+	var addrs []*Uint168
+	var outPoints []*tx.OutPoint
+	for i := 0; i < 20; i++ {
+		addrs = append(addrs, &Uint168{0x21, byte(i)})
+		outPoints = append(outPoints, tx.NewOutPoint(Uint256{byte(i)}, 0))
+	}
+	filter := bloom.LoadFilter(s.filterLoad(0, addrs, outPoints))
+	for _, addr := range addrs {
+		if shard := s.shard(addr.ToArray()); shard < 2 && !filter.Matches(addr.ToArray()) {
+			t.Errorf("address of shard %d not matched by slot 0", shard)
+		}
+	}
+	for _, op := range outPoints {
+		if shard := s.shard(op.Bytes()); shard < 2 && !filter.MatchesOutPoint(op) {
+			t.Errorf("outpoint of shard %d not matched by slot 0", shard)
+		}
+	}
+	if bloom.LoadFilter(s.filterLoad(0, nil, nil)).Matches(addrs[0].ToArray()) {
+		t.Error("filter of an empty shard matched an address")
+	}
+}
+
+func TestSplitFilterReconcile(t *testing.T) {
+	service := new(SPVServiceImpl)
+	s := newSplitFilter(4, 2)
+	service.split = s
+	peers := splitPeers(3)
+	for _, peer := range peers {
+		s.slot(peer, peers)
+	}
+
+	// The block is requested from a peer covering the other shards
+	block := &bloom.MerkleBlock{BlockHeader: core.Header{Height: 1}}
+	hash := *block.BlockHeader.Hash()
+	covers := s.coverPeers(peers[0], hash, peers)
+	if len(covers) != 1 || covers[0] != peers[1] {
+		t.Fatalf("unexpected cover peers %v", covers)
+	}
+
+	txA, txB := &Uint256{1}, &Uint256{2}
+	if _, _, err := service.reconcileBlock(peers[2], block, []*Uint256{txA}); err == nil {
+		t.Error("merkle block not requested from the peer accepted")
+	}
+	if _, covered, err := service.reconcileBlock(peers[0], block, []*Uint256{txA}); err != nil || covered {
+		t.Fatalf("block covered by one peer, error %v", err)
+	}
+	txIds, covered, err := service.reconcileBlock(peers[1], block, []*Uint256{txA, txB})
+	if err != nil || !covered {
+		t.Fatalf("block not covered by the peers, error %v", err)
+	}
+	if len(txIds) != 2 || *txIds[0] != *txA || *txIds[1] != *txB {
+		t.Errorf("unexpected reconciled transactions %v", txIds)
+	}
+
+	// Transactions are requested from the peer matched them first
+	if service.TxPeer(peers[2], *txA) != peers[0] || service.TxPeer(peers[2], *txB) != peers[1] {
+		t.Error("transaction not requested from the peer matched it")
+	}
+	if service.TxPeer(peers[2], *txA) != peers[2] {
+		t.Error("transaction peer not released")
+	}
+
+	// A block reconciled is not accepted again
+	if _, covered, err := service.reconcileBlock(peers[1], block, nil); err != nil || covered {
+		t.Error("block reconciled twice")
+	}
+}
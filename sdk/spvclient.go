@@ -30,7 +30,7 @@ type SPVClient interface {
 
 	// Create a data request message, invType is TRANSACTION or BLOCK according to the SPV protocol
 	// the inv type constant is in the protocol file
-	NewDataReq(invType uint8, hash Uint256) *msg.DataReq
+	NewDataReq(invType msg.InvType, hash Uint256) *msg.DataReq
 }
 
 // The message handler to extend the SDK
@@ -62,6 +62,9 @@ type SPVMessageHandler interface {
 	// If the BLOCK or TRANSACTION requested by the data request message can not be found,
 	// notfound message with requested data hash will return through this method.
 	OnNotFound(*p2p.Peer, *msg.NotFound) error
+
+	// DPoS confirm message of a block, the proposal of the block with the votes of the arbiters
+	OnConfirm(*p2p.Peer, *msg.Confirm) error
 }
 
 /*
@@ -80,5 +83,10 @@ func GetSPVClient(netType string, clientId uint64, seeds []string) (SPVClient, e
 	default:
 		return nil, errors.New("Unknown net type ")
 	}
-	return NewSPVClientImpl(magic, clientId, seeds)
+	return NewSPVClientImpl(p2p.NewConfig(magic, seeds), clientId)
+}
+
+// Get the SPV client with the network config, use this to run multiple networks in one process
+func GetSPVClientWithConfig(config *p2p.Config, clientId uint64) (SPVClient, error) {
+	return NewSPVClientImpl(config, clientId)
 }
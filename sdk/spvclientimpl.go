@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/elastos/Elastos.ELA.SPV/p2p"
 	"github.com/elastos/Elastos.ELA.SPV/msg"
@@ -15,9 +16,9 @@ type SPVClientImpl struct {
 	msgHandler SPVMessageHandler
 }
 
-func NewSPVClientImpl(magic uint32, clientId uint64, seeds []string) (*SPVClientImpl, error) {
+func NewSPVClientImpl(config *p2p.Config, clientId uint64) (*SPVClientImpl, error) {
 	// Initialize P2P client
-	p2p, err := GetP2PClient(magic, clientId, seeds)
+	p2p, err := GetP2PClientWithConfig(config, clientId)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +55,10 @@ func (client *SPVClientImpl) MakeMessage(cmd string) (message p2p.Message, err e
 		message = new(bloom.MerkleBlock)
 	case "notfound":
 		message = new(msg.NotFound)
+	case "confirm":
+		message = new(msg.Confirm)
+	case "capabilities":
+		message = new(msg.Capabilities)
 	default:
 		return nil, errors.New("Received unsupported message, CMD " + cmd)
 	}
@@ -74,6 +79,10 @@ func (client *SPVClientImpl) HandleMessage(peer *p2p.Peer, message p2p.Message)
 		return client.msgHandler.OnTxn(peer, msg)
 	case *msg.NotFound:
 		return client.msgHandler.OnNotFound(peer, msg)
+	case *msg.Confirm:
+		return client.msgHandler.OnConfirm(peer, msg)
+	case *msg.Capabilities:
+		return client.OnCapabilities(peer, msg)
 	default:
 		return errors.New("handle message unknown type")
 	}
@@ -99,7 +108,7 @@ func (client *SPVClientImpl) NewBlocksReq(locator []*Uint256, hashStop Uint256)
 	return blocksReq
 }
 
-func (client *SPVClientImpl) NewDataReq(invType uint8, hash Uint256) *msg.DataReq {
+func (client *SPVClientImpl) NewDataReq(invType msg.InvType, hash Uint256) *msg.DataReq {
 	dataReq := new(msg.DataReq)
 	dataReq.Type = invType
 	dataReq.Hash = hash
@@ -107,6 +116,7 @@ func (client *SPVClientImpl) NewDataReq(invType uint8, hash Uint256) *msg.DataRe
 }
 
 func (client *SPVClientImpl) OnPeerEstablish(peer *p2p.Peer) {
+	client.exchangeCapabilities(peer)
 	client.msgHandler.OnPeerEstablish(peer)
 }
 
@@ -123,9 +133,9 @@ func (client *SPVClientImpl) OnPong(peer *p2p.Peer, p *msg.Pong) error {
 }
 
 func (client *SPVClientImpl) keepUpdate() {
-	ticker := time.NewTicker(time.Second * p2p.InfoUpdateDuration)
+	ticker := clock.NewTicker(time.Second * p2p.InfoUpdateDuration)
 	defer ticker.Stop()
-	for range ticker.C {
+	for range ticker.C() {
 
 		// Update peers info
 		for _, peer := range client.PeerManager().ConnectedPeers() {
@@ -133,7 +143,7 @@ func (client *SPVClientImpl) keepUpdate() {
 
 				// Disconnect inactive peer
 				if peer.LastActive().Before(
-					time.Now().Add(-time.Second * p2p.InfoUpdateDuration * p2p.KeepAliveTimeout)) {
+					clock.Now().Add(-time.Second * p2p.InfoUpdateDuration * p2p.KeepAliveTimeout)) {
 					client.PeerManager().DisconnectPeer(peer)
 					continue
 				}
@@ -1,8 +1,12 @@
 package sdk
 
 import (
+	"time"
+
 	"github.com/elastos/Elastos.ELA.SPV/db"
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 	"github.com/elastos/Elastos.ELA.SPV/p2p"
 )
 
@@ -27,6 +31,98 @@ type SPVService interface {
 
 	// Broadcast a message to the peer to peer network.
 	BroadCastMessage(message p2p.Message)
+
+	// Drop the ongoing synchronization and start over from the current chain tip,
+	// call it after the chain data was reset to trigger a full rescan.
+	Resync()
+
+	// Stop the synchronization, reset the chain data by the reset function while no blocks are
+	// committed, then start over from the chain tip. Return if the rescan is deferred on a metered
+	// connection, the synchronization is not restarted if the reset failed.
+	ResetAndResync(reset func() error) (bool, error)
+
+	// Signal the connection is metered or not, the service runs in the low bandwidth
+	// mode on a metered connection, and a rescan by Resync() is deferred until it's not
+	SetMetered(metered bool)
+
+	// Limit the blocks and transactions requested at one time and the bytes of the downloaded data
+	// buffered before committed, 0 means the default blocks limit and no limits of the others
+	SetDownloadLimits(maxBlocks, maxTxs int, memoryBudget int64)
+
+	// Load the bloom filter to the peers again after addresses added, and rescan the recent blocks of
+	// the depth for the transactions of them, 0 means no rescan
+	RefreshFilter(rescanDepth uint32)
+
+	// Stop the network activity, syncing and peer connections, until resumed.
+	// Blocks not committed are dropped, the sync continues from the chain tip after resumed
+	Pause()
+
+	// Continue the network activity after paused
+	Resume()
+
+	// Set the unix time the wallet created, blocks before it are synced as headers only
+	SetBirthday(timestamp uint32)
+
+	// Set the headers only mode, all blocks are synced as headers only while it's on,
+	// for the wallet data can not be stored after a write failure
+	SetHeadersOnly(headersOnly bool)
+
+	// Check if the service is in the headers only mode
+	IsHeadersOnly() bool
+
+	// Set the public keys of the DPoS arbiters, a block confirmed by the supermajority
+	// of the arbiters is irreversible. Confirms are ignored if not set
+	SetArbiters(publicKeys [][]byte)
+
+	// Get the current arbiter set, register an ArbitersListener to it to be notified of the changes
+	Arbiters() *ArbiterSet
+
+	// Download the recent blocks of the depth on the best chain in full from the best peer,
+	// to audit the transactions matched by the bloom filter. It can not run while syncing
+	AuditBlocks(depth uint32) ([]*AuditBlock, error)
+
+	// Set the time without a new block while peers are connected for the chain to be stalled,
+	// 0 means DefaultStallTimeout and a negative timeout disables the stall check
+	SetStallTimeout(timeout time.Duration)
+
+	// Check if the chain is stalled, return the reason if it is
+	CheckStalled() error
+
+	// Register a stall listener, it's notified when the chain is stalled
+	AddStallListener(listener StallListener)
+
+	// Turn on the privacy mode, decoys are added to the bloom filter and it's loaded to one peer at a time,
+	// rotated in the interval. 0 means the default values and a negative decoy count turns it off
+	SetPrivacyMode(decoys int, rotation time.Duration) error
+
+	// Check if the service is in privacy mode
+	IsPrivacyMode() bool
+
+	// Get the decoy addresses and outpoints the bloom filter function must add in privacy mode
+	FilterDecoys() ([]*Uint168, []*tx.OutPoint)
+
+	// Split the addresses and outpoints returned by elements to shards loaded to the redundancy count of
+	// peers each, so no single peer learns the full wallet. 0 means the default values and a negative
+	// shard count turns it off, it can not be on in privacy mode
+	SetSplitFilter(shards, redundancy int, elements func() ([]*Uint168, []*tx.OutPoint)) error
+
+	// Check if the bloom filter is split across the peers
+	IsSplitFilter() bool
+
+	// Turn on the filter tuning, the bloom filter is rebuilt by new parameters and loaded to the peers
+	// when the false positive rate observed in the window of transactions is above the threshold.
+	// 0 means the default values and a negative threshold turns it off, it can not be on with the split filter
+	SetFilterTuning(fpRate, threshold float64, window uint32) error
+
+	// Check if the bloom filter is tuned by the observed false positive rate
+	IsFilterTuning() bool
+
+	// Get the parameters the bloom filter function must build the filter with
+	FilterParams() FilterParams
+
+	// Register an idle listener, it's notified when the service synced up
+	// with the peers, so the network activity can be paused
+	AddIdleListener(listener IdleListener)
 }
 
 /*
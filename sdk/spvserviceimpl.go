@@ -2,17 +2,19 @@ package sdk
 
 import (
 	"bytes"
-	"errors"
 	"fmt"
-	"time"
 	"sync"
+	"sync/atomic"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/p2p"
 	"github.com/elastos/Elastos.ELA.SPV/msg"
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
 )
 
 const (
@@ -22,12 +24,72 @@ const (
 
 // The SPV service implementation
 type SPVServiceImpl struct {
+	// Unix nano time the last getblocks message sent. Accessed atomically,
+	// keep it the first field to be 64-bit aligned
+	blocksReqSent int64
+	// Unix nano time the last block committed, and the stall timeout, accessed atomically
+	lastBlock    int64
+	stallTimeout int64
+
 	sync.Mutex
 	SPVClient
 	chain      *Blockchain
 	queue      *RequestQueue
+	throughput *throughput
 	getFilter  func() *bloom.Filter
 	fPositives int
+
+	// low bandwidth mode
+	metered       bool
+	rescanPending bool
+	// blocks to roll back for the rescan after addresses added
+	rescanDepth uint32
+
+	// max blocks requested at one time, 0 means MaxRequests
+	maxBlocks int
+
+	// unix time the wallet created, accessed atomically
+	birthday uint32
+	// headers only mode after the wallet data failed to store, accessed atomically
+	headersOnly int32
+
+	// multiple peers download, single peer mode accessed atomically
+	singlePeer   int32
+	ranges       BlockRanges
+	downloadLock sync.Mutex
+	download     map[Uint256]*p2p.Peer
+
+	// DPoS arbiters and the confirm received before the block
+	arbiters       *ArbiterSet
+	confirmLock    sync.Mutex
+	pendingConfirm *Uint256
+
+	// privacy mode, nil if off
+	privacyLock sync.Mutex
+	privacy     *privacy
+
+	// split filter mode, nil if off
+	splitLock sync.Mutex
+	split     *splitFilter
+
+	// filter tuning, nil if off
+	tuningLock sync.Mutex
+	tuning     *filterTuning
+
+	// the ongoing audit, one at a time
+	auditLock  sync.Mutex
+	auditState sync.Mutex
+	audit      *audit
+
+	// paused and idle state
+	paused        bool
+	idle          int32 // accessed atomically
+	idleListeners []*guardedIdleListener
+
+	// chain stalled state, accessed atomically
+	stalled        int32
+	lastHeight     uint32
+	stallListeners []*guardedStallListener
 }
 
 // Create a instance of SPV service implementation.
@@ -49,6 +111,9 @@ func NewSPVServiceImpl(client SPVClient, database db.DataStore, getBloomFilter f
 
 	// Initialize request queue
 	service.queue = NewRequestQueue(MaxRequests, service)
+	service.throughput = newThroughput()
+	service.arbiters = NewArbiterSet()
+	service.SetStallTimeout(DefaultStallTimeout)
 
 	// Set get bloom filter method
 	service.getFilter = getBloomFilter
@@ -58,12 +123,16 @@ func NewSPVServiceImpl(client SPVClient, database db.DataStore, getBloomFilter f
 
 func (service *SPVServiceImpl) OnPeerEstablish(peer *p2p.Peer) {
 	// Send filterload message
-	peer.Send(service.getFilter().GetFilterLoadMsg())
+	peer.Send(service.filterLoad(peer))
+	if service.IsPrivacyMode() && service.filterPeer() == nil {
+		service.rotateFilterPeer()
+	}
 }
 
 func (service *SPVServiceImpl) Start() {
 	service.SPVClient.Start()
-	go service.keepUpdate()
+	service.onBlockCommitted(service.chain.Height())
+	supervisor.Go("sync loop", service.keepUpdate)
 	log.Info("SPV service started...")
 }
 
@@ -81,12 +150,59 @@ func (service *SPVServiceImpl) BroadCastMessage(message p2p.Message) {
 	service.PeerManager().Broadcast(message)
 }
 
+func (service *SPVServiceImpl) Resync() {
+	service.Lock()
+	defer service.Unlock()
+
+	// Historical rescan is deferred on a metered connection
+	if service.metered {
+		service.rescanPending = true
+		log.Info("Connection metered, rescan deferred")
+		return
+	}
+	service.resync()
+}
+
+func (service *SPVServiceImpl) ResetAndResync(reset func() error) (bool, error) {
+	service.Lock()
+	defer service.Unlock()
+
+	service.stopSyncing()
+	service.queue.Clear()
+	if err := reset(); err != nil {
+		return false, err
+	}
+
+	if service.metered {
+		service.rescanPending = true
+		service.updateLocalHeight()
+		log.Info("Connection metered, rescan deferred")
+		return true, nil
+	}
+	service.resync()
+	return false, nil
+}
+
+func (service *SPVServiceImpl) resync() {
+	service.stopSyncing()
+	service.queue.Clear()
+	service.rewind()
+	service.updateLocalHeight()
+	// Addresses may have changed, reload bloom filter on connected peers
+	service.reloadFilter()
+	service.syncBlocks()
+}
+
 func (service *SPVServiceImpl) keepUpdate() {
-	ticker := time.NewTicker(time.Second * p2p.InfoUpdateDuration)
-	defer ticker.Stop()
-	for range ticker.C {
+	for {
+		clock.Sleep(service.updateDuration())
+		if service.IsPaused() {
+			continue
+		}
 		// Keep synchronizing blocks
+		service.rotateFilterPeer()
 		service.syncBlocks()
+		service.checkStall()
 	}
 }
 
@@ -105,16 +221,35 @@ func (service *SPVServiceImpl) needSync() bool {
 func (service *SPVServiceImpl) syncBlocks() {
 	// Check if blockchain need sync
 	if service.needSync() {
+		// No blocks can be committed until the write failure is cleared
+		if service.chain.Failure() != nil {
+			return
+		}
 		// Check if blockchain is in syncing state
 		if service.chain.IsSyncing() || service.queue.IsRunning() {
 			return
 		}
+		// The blocks can not be reconciled until all shards of the split filter are loaded
+		if !service.shardsCovered() {
+			log.Warn("Split filter shards not covered by the connected peers, wait for more peers")
+			return
+		}
+		// Not idle until synced up again
+		atomic.StoreInt32(&service.idle, 0)
 		// Set blockchain state to syncing
 		service.chain.SetChainState(SYNCING)
+		service.throughput.reset()
 		// Request blocks
 		service.requestBlocks()
+		// Fetch the transactions of the blocks pending before restart
+		if syncPeer := service.PeerManager().GetSyncPeer(); syncPeer != nil {
+			service.resumePending(syncPeer)
+		}
 	} else {
 		service.stopSyncing()
+		service.checkIdle()
+		// Synced up, try multiple peers download again next time
+		atomic.StoreInt32(&service.singlePeer, 0)
 	}
 }
 
@@ -122,6 +257,8 @@ func (service *SPVServiceImpl) stopSyncing() {
 	if service.chain.IsSyncing() {
 		// Clear request queue
 		service.queue.Clear()
+		service.resetDownload()
+		service.clearShards()
 		// Set blockchain state to waiting
 		service.chain.SetChainState(WAITING)
 		// Remove sync peer
@@ -130,17 +267,16 @@ func (service *SPVServiceImpl) stopSyncing() {
 }
 
 func (service *SPVServiceImpl) requestBlocks() {
-	// Get sync peer
-	syncPeer := service.PeerManager().GetSyncPeer()
+	// Select sync peer by the measured throughput
+	syncPeer := service.selectSyncPeer()
+	service.PeerManager().SetSyncPeer(syncPeer)
 	if syncPeer == nil {
 		// If sync peer is nil at this point, that meas no peer connected
 		fmt.Println("SyncManager no sync peer connected")
 		return
 	}
 	// Request blocks returns a inventory message which contains block hashes
-	request := service.NewBlocksReq(service.chain.GetBlockLocatorHashes(), Uint256{})
-
-	go syncPeer.Send(request)
+	service.sendBlocksReq(syncPeer, service.chain.GetBlockLocatorHashes())
 }
 
 func (service *SPVServiceImpl) changeSyncPeerAndRestart() {
@@ -153,14 +289,33 @@ func (service *SPVServiceImpl) changeSyncPeerAndRestart() {
 	service.syncBlocks()
 }
 
-func (service *SPVServiceImpl) OnSendRequest(peer *p2p.Peer, reqType uint8, hash Uint256) {
+func (service *SPVServiceImpl) OnSendRequest(peer *p2p.Peer, reqType msg.InvType, hash Uint256) {
+	if reqType == BLOCK {
+		service.throughput.onRequested(peer)
+	}
 	peer.Send(service.NewDataReq(reqType, hash))
+	if reqType == BLOCK {
+		service.requestShards(peer, hash)
+	}
+}
+
+// Failed requests are retried on the connected peers, or the filter peer only in privacy mode
+func (service *SPVServiceImpl) RetryPeers() []*p2p.Peer {
+	if service.IsPrivacyMode() {
+		if peer := service.filterPeer(); peer != nil {
+			return []*p2p.Peer{peer}
+		}
+		return nil
+	}
+	return service.PeerManager().ConnectedPeers()
 }
 
 func (service *SPVServiceImpl) OnRequestError(err error) {
+	log.Warn("Request error: ", err)
 	service.Lock()
 	defer service.Unlock()
 
+	service.fallbackSinglePeer(err.Error())
 	service.changeSyncPeerAndRestart()
 }
 
@@ -175,16 +330,28 @@ func (service *SPVServiceImpl) OnRequestFinished(pool *FinishedReqPool) {
 	}
 
 	var fPositives int
+	var observed uint32
 	for request, ok := pool.Next(*current); ok; request, ok = pool.Next(*request.Block.BlockHeader.Hash()) {
 		// Try to commit next block
 		reorg, fp, err := service.chain.CommitBlock(request.Block, request.Txs)
+		if errors.Is(err, errors.ErrStorage) {
+			// Not the fault of the sync peer, stop syncing until the failure is cleared
+			log.Error("Stop syncing, ", err)
+			service.stopSyncing()
+			return
+		}
 		if err != nil {
 			fmt.Println(err)
+			service.fallbackSinglePeer(err.Error())
 			service.changeSyncPeerAndRestart()
 			return
 		}
+		if len(request.Txs) > 0 {
+			service.removePending(*request.Block.BlockHeader.Hash())
+		}
 		// Update local height after block committed
 		service.updateLocalHeight()
+		service.onBlockCommitted(request.Block.BlockHeader.Height)
 
 		// If we meet a reorganize, restart sync process
 		if reorg {
@@ -194,16 +361,22 @@ func (service *SPVServiceImpl) OnRequestFinished(pool *FinishedReqPool) {
 			return
 		}
 		fPositives += fp
+		// The transactions of the blocks committed as headers only are not matched by the filter
+		if !service.beforeBirthday(&request.Block) && !service.IsHeadersOnly() {
+			observed += request.Block.Transactions
+		}
 	}
+	service.applyPendingConfirm()
 
 	go service.handleFPositive(fPositives)
+	go service.tuneFilter(observed, uint32(fPositives))
 }
 
 func (service *SPVServiceImpl) handleFPositive(fPositives int) {
 	service.fPositives += fPositives
 	if service.fPositives > MaxFalsePositives {
-		// Broadcast filterload message to connected peers
-		service.PeerManager().Broadcast(service.getFilter().GetFilterLoadMsg())
+		// Reload filter on connected peers
+		service.reloadFilter()
 		service.fPositives = 0
 	}
 }
@@ -211,7 +384,7 @@ func (service *SPVServiceImpl) handleFPositive(fPositives int) {
 func (service *SPVServiceImpl) OnInventory(peer *p2p.Peer, inv *msg.Inventory) error {
 	switch inv.Type {
 	case TRANSACTION:
-		// Do nothing, transaction inventory is not supported
+		// Do nothing, unconfirmed transactions are never requested
 	case BLOCK:
 		return service.HandleBlockInvMsg(peer, inv)
 	}
@@ -221,9 +394,11 @@ func (service *SPVServiceImpl) OnInventory(peer *p2p.Peer, inv *msg.Inventory) e
 func (service *SPVServiceImpl) HandleBlockInvMsg(peer *p2p.Peer, inv *msg.Inventory) error {
 	if !service.chain.IsSyncing() {
 		peer.Disconnect()
-		return errors.New("receive inventory message in non syncing mode")
+		return errors.Wrap(errors.ErrPeerMisbehaving, "receive inventory message in non syncing mode")
 	}
 
+	service.onBlocksInv(peer)
+
 	// If no more blocks, return
 	if inv.Count == 0 {
 		return nil
@@ -233,7 +408,7 @@ func (service *SPVServiceImpl) HandleBlockInvMsg(peer *p2p.Peer, inv *msg.Invent
 	if dataLen != int(inv.Count)*UINT256SIZE {
 
 		service.changeSyncPeerAndRestart()
-		return fmt.Errorf("invalid block inventory data size: %d\n", dataLen)
+		return errors.Wrapf(errors.ErrPeerMisbehaving, "invalid block inventory data size: %d\n", dataLen)
 	}
 
 	var hashes = make([]Uint256, 0, inv.Count)
@@ -242,19 +417,17 @@ func (service *SPVServiceImpl) HandleBlockInvMsg(peer *p2p.Peer, inv *msg.Invent
 		err := blockHash.Deserialize(bytes.NewReader(inv.Data[i:i+UINT256SIZE]))
 		if err != nil {
 			service.changeSyncPeerAndRestart()
-			return fmt.Errorf("deserialize block hash error %s\n", err.Error())
+			return errors.Wrapf(errors.ErrPeerMisbehaving, "deserialize block hash error %s\n", err.Error())
 		}
 		hashes = append(hashes, blockHash)
 	}
 
-	// Put hashes to request queue
+	// Put hashes to request queue, downloaded in ranges from the download peers
+	service.splitDownload(peer, hashes)
 	service.queue.PushHashes(peer, hashes)
 
 	// Request more blocks
-	locator := []*Uint256{&hashes[len(hashes)-1]}
-	request := service.NewBlocksReq(locator, Uint256{})
-
-	go peer.Send(request)
+	service.sendBlocksReq(peer, []*Uint256{&hashes[len(hashes)-1]})
 
 	return nil
 }
@@ -270,25 +443,57 @@ func (service *SPVServiceImpl) OnMerkleBlock(peer *p2p.Peer, block *bloom.Merkle
 
 	txIds, err := bloom.CheckMerkleBlock(*block)
 	if err != nil {
-		return errors.New("Invalid merkle block received: " + err.Error())
+		return errors.Wrap(errors.ErrPeerMisbehaving, "Invalid merkle block received: "+err.Error())
+	}
+
+	service.PeerManager().Reputations().OnBlock(peer.Addr().String())
+
+	// Blocks requested by an audit are not committed
+	if service.onAuditBlock(peer, block, txIds) {
+		return nil
+	}
+
+	// Blocks before the wallet birthday are committed as headers only, and all blocks in the headers only mode
+	if service.beforeBirthday(block) || service.IsHeadersOnly() {
+		txIds = nil
 	}
 
 	if service.chain.IsSyncing() { // When blockchain in syncing mode
-		if service.PeerManager().GetSyncPeer() != nil && service.PeerManager().GetSyncPeer().ID() != peer.ID() {
+		if service.IsSplitFilter() {
+			// The merkleblocks of the shards are reconciled before the block is received
+			var covered bool
+			if txIds, covered, err = service.reconcileBlock(peer, block, txIds); err != nil {
+				peer.Disconnect()
+				return err
+			} else if !covered {
+				return nil
+			}
+		} else if !service.isSyncPeer(peer) && !service.queue.RequestedFrom(peer, *blockHash) {
+			// Failed requests are retried on other peers, accept the block if it's requested from the peer
 			peer.Disconnect()
-			return fmt.Errorf("receive message from non sync peer: %d\n", peer.ID())
+			return errors.Wrapf(errors.ErrPeerMisbehaving, "receive message from non sync peer: %d\n", peer.ID())
+		}
+
+		// The ranges downloaded from different peers must link at the boundaries
+		if err := service.ranges.Check(&block.BlockHeader); err != nil {
+			service.fallbackSinglePeer(err.Error())
+			service.changeSyncPeerAndRestart()
+			return err
 		}
 
-		// Add block to sync queue
+		// Keep the matched transactions to fetch after restart, and add block to sync queue
+		service.savePending(block, txIds)
 		err = service.queue.OnBlockReceived(block, txIds)
 		if err != nil {
 			service.changeSyncPeerAndRestart()
 			return err
 		}
+		service.onBlockDelivered(peer)
 	} else {
 
 		// Just request block transactions.
 		// After transactions are received, the block will be put into finished blocks pool
+		service.savePending(block, txIds)
 		service.queue.StartBlockTxsRequest(peer, block, txIds)
 	}
 
@@ -298,11 +503,14 @@ func (service *SPVServiceImpl) OnMerkleBlock(peer *p2p.Peer, block *bloom.Merkle
 func (service *SPVServiceImpl) OnTxn(peer *p2p.Peer, txn *msg.Txn) error {
 	log.Debug("Receive transaction hash: ", txn.Hash().String())
 
-	if service.chain.IsSyncing() && service.PeerManager().GetSyncPeer() != nil &&
-		service.PeerManager().GetSyncPeer().ID() != peer.ID() {
+	if service.onAuditTx(peer, &txn.Transaction) {
+		return nil
+	}
+
+	if service.chain.IsSyncing() && !service.isSyncPeer(peer) && !service.queue.RequestedFrom(peer, *txn.Hash()) {
 
 		peer.Disconnect()
-		return fmt.Errorf("receive message from non sync peer: %d\n", peer.ID())
+		return errors.Wrapf(errors.ErrPeerMisbehaving, "receive message from non sync peer: %d\n", peer.ID())
 	}
 
 	if service.chain.IsSyncing() || service.queue.IsRunning() {
@@ -327,10 +535,25 @@ func (service *SPVServiceImpl) OnTxn(peer *p2p.Peer, txn *msg.Txn) error {
 }
 
 func (service *SPVServiceImpl) OnNotFound(peer *p2p.Peer, msg *msg.NotFound) error {
-	service.changeSyncPeerAndRestart()
+	// The request is retried on other peers, if no peer has the data
+	// the request queue reports it as a request error and restarts syncing
+	if !service.queue.OnNotFound(peer, msg.Hash) {
+		log.Debug("Not found data not requested: ", msg.Hash.String())
+		return nil
+	}
+	// The download peer does not have the block announced by the sync peer
+	if service.chain.IsSyncing() && !service.isSyncPeer(peer) {
+		service.fallbackSinglePeer(fmt.Sprintf("peer %d does not have %s", peer.ID(), msg.Hash.String()))
+	}
 	return nil
 }
 
+// Check if the peer is the sync peer, or no sync peer is set
+func (service *SPVServiceImpl) isSyncPeer(peer *p2p.Peer) bool {
+	syncPeer := service.PeerManager().GetSyncPeer()
+	return syncPeer == nil || syncPeer.ID() == peer.ID()
+}
+
 // Update local peer height with current chain height
 func (service *SPVServiceImpl) updateLocalHeight() {
 	service.PeerManager().Local().SetHeight(uint64(service.chain.Height()))
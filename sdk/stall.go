@@ -0,0 +1,98 @@
+package sdk
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+)
+
+const (
+	// Expected interval between two blocks
+	BlockInterval = 2 * time.Minute
+
+	// Default time without a new block for the chain to be stalled
+	DefaultStallTimeout = 3 * BlockInterval
+)
+
+var chainStalled = metrics.NewGauge("spv_chain_stalled", "1 if no new block was received for the stall timeout while peers are connected")
+
+/*
+StallListener is an interface to know when the chain is stalled, no new block was received for the stall
+timeout while peers are connected, so an oracle can not fall behind the network silently.
+Call SPVService.AddStallListener() method to register your callbacks to the notify list.
+*/
+type StallListener interface {
+	// The chain tip at the height was committed at the time, and not extended since
+	OnChainStalled(height uint32, since time.Time)
+}
+
+// Register a stall listener, multiple registration is supported.
+func (service *SPVServiceImpl) AddStallListener(listener StallListener) {
+	service.stallListeners = append(service.stallListeners,
+		&guardedStallListener{StallListener: listener, guard: newGuard("stall listener", listener)})
+}
+
+// Set the time without a new block for the chain to be stalled, 0 means DefaultStallTimeout,
+// a negative timeout disables the stall check
+func (service *SPVServiceImpl) SetStallTimeout(timeout time.Duration) {
+	if timeout == 0 {
+		timeout = DefaultStallTimeout
+	}
+	atomic.StoreInt64(&service.stallTimeout, int64(timeout))
+}
+
+// Check if the chain is stalled, return the reason if it is
+func (service *SPVServiceImpl) CheckStalled() error {
+	if atomic.LoadInt32(&service.stalled) == 0 {
+		return nil
+	}
+	since := time.Unix(0, atomic.LoadInt64(&service.lastBlock))
+	return errors.New(fmt.Sprintf("No new block since %s at height %d",
+		since.Format(time.RFC3339), atomic.LoadUint32(&service.lastHeight)))
+}
+
+// Reset the stall check when the block of the height committed
+func (service *SPVServiceImpl) onBlockCommitted(height uint32) {
+	atomic.StoreInt64(&service.lastBlock, clock.Now().UnixNano())
+	atomic.StoreUint32(&service.lastHeight, height)
+	if atomic.CompareAndSwapInt32(&service.stalled, 1, 0) {
+		chainStalled.Set(0)
+		log.Info("Chain resumed at height ", height)
+	}
+}
+
+// Check the chain stall while peers are connected, the chain is not stalled without peers
+func (service *SPVServiceImpl) checkStall() {
+	if service.PeerManager().GetBestPeer() == nil {
+		return
+	}
+	service.detectStall()
+}
+
+// Notify the stall listeners once when no new block was received for the stall timeout
+func (service *SPVServiceImpl) detectStall() {
+	timeout := time.Duration(atomic.LoadInt64(&service.stallTimeout))
+	if timeout < 0 {
+		return
+	}
+	since := time.Unix(0, atomic.LoadInt64(&service.lastBlock))
+	if clock.Since(since) < timeout {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&service.stalled, 0, 1) {
+		return
+	}
+
+	height := atomic.LoadUint32(&service.lastHeight)
+	chainStalled.Set(1)
+	log.Warnf("Chain stalled at height %d, no new block since %s", height, since.Format(time.RFC3339))
+	for _, listener := range service.stallListeners {
+		listener := listener
+		go listener.guard.Run(func() { listener.OnChainStalled(height, since) })
+	}
+}
@@ -0,0 +1,67 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+type stallListener chan uint32
+
+func (l stallListener) OnChainStalled(height uint32, since time.Time) { l <- height }
+
+func TestChainStall(t *testing.T) {
+	log.Init()
+	mock := clock.NewMock(time.Unix(1514764800, 0))
+	clock.Set(mock)
+	defer clock.Set(clock.System)
+
+	service := new(SPVServiceImpl)
+	service.SetStallTimeout(0)
+	listener := make(stallListener, 1)
+	service.AddStallListener(listener)
+	service.onBlockCommitted(100)
+
+	mock.Add(DefaultStallTimeout - time.Second)
+	service.detectStall()
+	if err := service.CheckStalled(); err != nil {
+		t.Fatal("chain stalled before the timeout,", err)
+	}
+
+	// Notified once when stalled
+	mock.Add(time.Second)
+	service.detectStall()
+	service.detectStall()
+	if err := service.CheckStalled(); err == nil {
+		t.Fatal("chain not stalled after the timeout")
+	}
+	select {
+	case height := <-listener:
+		if height != 100 {
+			t.Errorf("unexpected stalled height %d", height)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stall listener not notified")
+	}
+	select {
+	case <-listener:
+		t.Error("stall listener notified twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// A new block resumes the chain
+	service.onBlockCommitted(101)
+	if err := service.CheckStalled(); err != nil {
+		t.Error("chain stalled after a new block,", err)
+	}
+
+	// Disabled by a negative timeout
+	service.SetStallTimeout(-1)
+	mock.Add(time.Hour)
+	service.detectStall()
+	if err := service.CheckStalled(); err != nil {
+		t.Error("chain stalled with the stall check disabled,", err)
+	}
+}
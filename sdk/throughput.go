@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+const (
+	// Peers delivering blocks slower than this many blocks per second are demoted
+	MinBlockRate = 1
+	// Seconds of the window the block delivery rate is measured in
+	ThroughputWindow = 30
+	// Min block requests sent to a peer in the window to rate the block delivery rate of it
+	MinRatedRequests = 10
+	// Seconds a demoted peer is not selected as the sync peer or a download peer
+	DemoteDuration = 600
+)
+
+// throughput measures the block delivery rate of the peers in a window
+type throughput struct {
+	sync.Mutex
+	start     time.Time
+	requested map[uint64]int
+	delivered map[uint64]int
+	demoted   map[uint64]time.Time
+}
+
+func newThroughput() *throughput {
+	t := &throughput{demoted: make(map[uint64]time.Time)}
+	t.newWindow()
+	return t
+}
+
+// Start a new window, when syncing started
+func (t *throughput) reset() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.newWindow()
+}
+
+func (t *throughput) newWindow() {
+	t.start = clock.Now()
+	t.requested = make(map[uint64]int)
+	t.delivered = make(map[uint64]int)
+}
+
+func (t *throughput) onRequested(peer *p2p.Peer) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.requested[peer.ID()]++
+}
+
+// Count the block delivered by the peer, when the window elapsed the peers with enough
+// requests and a delivery rate below MinBlockRate are demoted and returned
+func (t *throughput) onDelivered(peer *p2p.Peer) []uint64 {
+	t.Lock()
+	defer t.Unlock()
+
+	t.delivered[peer.ID()]++
+
+	elapsed := clock.Since(t.start)
+	if elapsed < time.Second*ThroughputWindow {
+		return nil
+	}
+
+	var slow []uint64
+	for id, requested := range t.requested {
+		if requested < MinRatedRequests {
+			continue
+		}
+		rate := float64(t.delivered[id]) / elapsed.Seconds()
+		if rate < MinBlockRate {
+			log.Warnf("Peer %d delivered %d of %d blocks in %v, demoted", id, t.delivered[id], requested, elapsed)
+			t.demoted[id] = clock.Now()
+			slow = append(slow, id)
+		}
+	}
+
+	t.newWindow()
+	return slow
+}
+
+// Check if the peer is demoted in DemoteDuration
+func (t *throughput) isDemoted(peer *p2p.Peer) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	demoted, ok := t.demoted[peer.ID()]
+	if ok && clock.Since(demoted) > time.Second*DemoteDuration {
+		delete(t.demoted, peer.ID())
+		return false
+	}
+	return ok
+}
+
+/*
+Select the sync peer from the established peers with the best height, the peers not demoted
+are preferred and the one with the lowest request latency is selected, a peer not measured yet
+has a zero latency so it gets a chance to be measured. The best peer is returned if all the
+peers are demoted.
+*/
+func (service *SPVServiceImpl) selectSyncPeer() *p2p.Peer {
+	// Blocks are downloaded from the filter peer in privacy mode
+	if service.IsPrivacyMode() {
+		service.rotateFilterPeer()
+		return service.filterPeer()
+	}
+
+	bestPeer := service.PeerManager().GetBestPeer()
+	if bestPeer == nil {
+		return nil
+	}
+
+	var selected *p2p.Peer
+	for _, peer := range service.PeerManager().ConnectedPeers() {
+		if peer.State() != p2p.ESTABLISH || peer.Height() < bestPeer.Height() {
+			continue
+		}
+		if service.throughput.isDemoted(peer) {
+			continue
+		}
+		if selected == nil || peer.Latency() < selected.Latency() {
+			selected = peer
+		}
+	}
+	if selected == nil {
+		return bestPeer
+	}
+	return selected
+}
+
+// Count the block delivered by the peer, switch to another sync peer if the sync peer is demoted
+func (service *SPVServiceImpl) onBlockDelivered(peer *p2p.Peer) {
+	for _, id := range service.throughput.onDelivered(peer) {
+		syncPeer := service.PeerManager().GetSyncPeer()
+		if syncPeer != nil && syncPeer.ID() == id {
+			log.Info("Sync peer too slow, select another sync peer")
+			service.stopSyncing()
+			service.syncBlocks()
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+func TestThroughputDemote(t *testing.T) {
+	mock := clock.NewMock(time.Unix(1514764800, 0))
+	clock.Set(mock)
+	defer clock.Set(clock.System)
+
+	fast, slow, idle := new(p2p.Peer), new(p2p.Peer), new(p2p.Peer)
+	fast.SetID(1)
+	slow.SetID(2)
+	idle.SetID(3)
+
+	tp := newThroughput()
+	for i := 0; i < MinRatedRequests; i++ {
+		tp.onRequested(fast)
+		tp.onRequested(slow)
+	}
+	// Too few requests to be rated
+	tp.onRequested(idle)
+	for i := 0; i < MinBlockRate*ThroughputWindow; i++ {
+		tp.onDelivered(fast)
+	}
+
+	mock.Add(time.Second * ThroughputWindow)
+	demoted := tp.onDelivered(fast)
+	if len(demoted) != 1 || demoted[0] != slow.ID() {
+		t.Fatalf("unexpected demoted peers %v", demoted)
+	}
+	if tp.isDemoted(fast) || !tp.isDemoted(slow) || tp.isDemoted(idle) {
+		t.Fatal("unexpected demoted state")
+	}
+
+	// Demotion expires
+	mock.Add(time.Second * (DemoteDuration + 1))
+	if tp.isDemoted(slow) {
+		t.Fatal("demotion not expired")
+	}
+}
@@ -0,0 +1,84 @@
+package sdk
+
+import (
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SPV/db"
+)
+
+// Headers the median timestamp is taken over, block timestamps are not in order
+// but the median of the previous blocks only goes forward
+const MedianTimeSpan = 11
+
+/*
+Get the height of the first block on the best chain with the median timestamp not earlier
+than the given unix time, or the chain height if all of them are earlier. It's a binary search
+over the median timestamps, with a DataStore implementing db.HeightIndex it takes a few dozens
+of header lookups, otherwise the headers are walked back from the tip.
+*/
+func (bc *Blockchain) GetHeightByTime(timestamp uint32) (uint32, error) {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	tip, err := bc.GetChainTip()
+	if err != nil {
+		return 0, ErrBlockNotFound
+	}
+
+	headerAt := func(height uint32) (*db.StoreHeader, error) {
+		if index, ok := bc.DataStore.(db.HeightIndex); ok {
+			return index.GetHeaderByHeight(height)
+		}
+		header := tip
+		for header.Height > height {
+			if header, err = bc.GetPrevious(header); err != nil {
+				return nil, err
+			}
+		}
+		return header, nil
+	}
+
+	var searchErr error
+	height := sort.Search(int(tip.Height), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		header, err := headerAt(uint32(i) + 1)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		median, err := bc.medianTime(header)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return median >= timestamp
+	})
+	if searchErr != nil {
+		return 0, searchErr
+	}
+	if uint32(height) == tip.Height {
+		return tip.Height, nil
+	}
+	return uint32(height) + 1, nil
+}
+
+// Get the median timestamp of the header and the previous headers in the median time span
+func (bc *Blockchain) medianTime(header *db.StoreHeader) (uint32, error) {
+	timestamps := make([]uint32, 0, MedianTimeSpan)
+	var err error
+	for {
+		timestamps = append(timestamps, header.Timestamp)
+		if len(timestamps) == MedianTimeSpan || header.Height <= 1 {
+			break
+		}
+		if header, err = bc.GetPrevious(header); err != nil {
+			return 0, err
+		}
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i] < timestamps[j]
+	})
+	return timestamps[len(timestamps)/2], nil
+}
@@ -0,0 +1,122 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+)
+
+// Mint n blocks with txs transactions each, one of them pays to the watched address.
+// More addresses are watched to keep the bloom filter from saturating by the added outpoints
+func benchBlocks(h *Harness, n, txs int) []*Block {
+	for i := 0; i < 1000; i++ {
+		programHash := Uint168{33, 0xff}
+		binary.LittleEndian.PutUint32(programHash[2:], uint32(i))
+		h.Watch(programHash)
+	}
+
+	blocks := make([]*Block, 0, n)
+	for i := 0; i < n; i++ {
+		blocks = append(blocks, benchBlock(h, i, txs))
+	}
+	return blocks
+}
+
+func benchBlock(h *Harness, i, txs int) *Block {
+	var txns []*tx.Transaction
+	for j := 0; j < txs; j++ {
+		payTo := Uint168{33}
+		binary.LittleEndian.PutUint32(payTo[1:], uint32(i*txs+j))
+		if j == 0 {
+			payTo = watched
+		}
+		funding := tx.NewOutPoint(Uint256{byte(j), byte(j >> 8)}, uint16(i))
+		txns = append(txns, h.Chain.NewTransfer(uint32(i*txs+j),
+			[]*tx.OutPoint{funding}, h.Chain.NewOutput(payTo, 100)))
+	}
+	return h.Chain.Mint(txns...)
+}
+
+func BenchmarkCheckProofOfWork(b *testing.B) {
+	h := newHarness(b, miner)
+	blocks := h.Chain.Generate(100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := h.Blockchain.CheckProofOfWork(&blocks[i%len(blocks)].Header)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Commit blocks to the blockchain as the SPV service does after the merkle blocks checked
+func BenchmarkCommitBlock(b *testing.B) {
+	h := newHarness(b, miner)
+	blocks := benchBlocks(h, b.N, 100)
+
+	merkleBlocks := make([]*bloom.MerkleBlock, 0, b.N)
+	matches := make([][]tx.Transaction, 0, b.N)
+	for _, block := range blocks {
+		merkleBlock, txs := block.MerkleBlock(h.BloomFilter())
+		merkleBlocks = append(merkleBlocks, merkleBlock)
+		matches = append(matches, txs)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, err := h.Blockchain.CommitBlock(*merkleBlocks[i], matches[i])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Sync blocks end to end, filter the blocks, check the merkle blocks and commit them.
+// Blocks are synced in rounds of 10 blocks, the bloom filter is rebuilt every round
+func BenchmarkSync(b *testing.B) {
+	h := newHarness(b, miner)
+	benchBlocks(h, 0, 0)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i += 10 {
+		b.StopTimer()
+		for j := i; j < i+10 && j < b.N; j++ {
+			benchBlock(h, j, 100)
+		}
+		b.StartTimer()
+
+		if _, err := h.Sync(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Hash the transactions and the header of a block, as done for every block synced
+func BenchmarkHash(b *testing.B) {
+	h := newHarness(b, miner)
+	block := benchBlock(h, 0, 100)
+
+	// Hashed while serialized, the same as hashing the serialized bytes
+	buf := new(bytes.Buffer)
+	block.Txs[1].SerializeUnsigned(buf)
+	if *block.Txs[1].Hash() != Uint256(Sha256D(buf.Bytes())) {
+		b.Fatal("transaction hash not match the hash of the serialized bytes")
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		block.Header.Hash()
+		for _, txn := range block.Txs {
+			txn.Hash()
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package sim
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/core/auxpow"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+)
+
+// Block is a block mined on the simulated chain
+type Block struct {
+	Header core.Header
+	Txs    []*tx.Transaction
+}
+
+func (b *Block) Hash() *Uint256 {
+	return b.Header.Hash()
+}
+
+// Get the merkle block of this block filtered by the given bloom filter,
+// and the transactions matched by the filter
+func (b *Block) MerkleBlock(filter *bloom.Filter) (*bloom.MerkleBlock, []tx.Transaction) {
+	mBlock := newMBlock(b.Txs)
+
+	var matches []tx.Transaction
+	for _, txn := range b.Txs {
+		if filter.MatchTxAndUpdate(txn) {
+			mBlock.MatchedBits = append(mBlock.MatchedBits, 0x01)
+			matches = append(matches, *txn)
+		} else {
+			mBlock.MatchedBits = append(mBlock.MatchedBits, 0x00)
+		}
+	}
+
+	// Build the depth-first partial merkle tree
+	mBlock.TraverseAndBuild(treeHeight(mBlock), 0)
+
+	merkleBlock := &bloom.MerkleBlock{
+		BlockHeader:  b.Header,
+		Transactions: mBlock.NumTx,
+		Hashes:       mBlock.FinalHashes,
+		Flags:        make([]byte, (len(mBlock.Bits)+7)/8),
+	}
+	for i := uint32(0); i < uint32(len(mBlock.Bits)); i++ {
+		merkleBlock.Flags[i/8] |= mBlock.Bits[i] << (i % 8)
+	}
+
+	return merkleBlock, matches
+}
+
+/*
+Chain is a simulated blockchain on a private network. Blocks are minted on demand with the
+given transactions, any block can be extended to make a fork, and the best chain is the one
+with most work, like a full node does. Everything is deterministic, minting the same blocks
+with the same transactions always gives the same hashes.
+*/
+type Chain struct {
+	params  Params
+	payTo   Uint168
+	genesis *Block
+	tip     *Block
+	blocks  map[Uint256]*Block
+	work    map[Uint256]*big.Int
+}
+
+// Create a chain with only the genesis block, the coinbase outputs of new blocks
+// are paid to the given program hash
+func NewChain(params Params, payTo Uint168) *Chain {
+	chain := &Chain{
+		params: params,
+		payTo:  payTo,
+		blocks: make(map[Uint256]*Block),
+		work:   make(map[Uint256]*big.Int),
+	}
+
+	chain.genesis = chain.mine(core.Header{
+		Version:   0,
+		Timestamp: params.GenesisTime,
+		Bits:      params.Bits,
+		Height:    0,
+	}, nil)
+	chain.add(chain.genesis, new(big.Int))
+
+	return chain
+}
+
+// Set the program hash the coinbase outputs of new blocks are paid to
+func (c *Chain) PayTo(programHash Uint168) {
+	c.payTo = programHash
+}
+
+// Get the genesis block
+func (c *Chain) Genesis() *Block {
+	return c.genesis
+}
+
+// Get the block on the best chain tip
+func (c *Chain) Tip() *Block {
+	return c.tip
+}
+
+// Get the height of the best chain
+func (c *Chain) Height() uint32 {
+	return c.tip.Header.Height
+}
+
+// Get a block by it's hash, either on the best chain or a fork
+func (c *Chain) GetBlock(hash Uint256) (*Block, bool) {
+	block, ok := c.blocks[hash]
+	return block, ok
+}
+
+// Get the block on the best chain at the given height
+func (c *Chain) BlockAt(height uint32) (*Block, error) {
+	if height > c.tip.Header.Height {
+		return nil, errors.New("height is higher than the best chain")
+	}
+	block := c.tip
+	for block.Header.Height > height {
+		block = c.blocks[block.Header.Previous]
+	}
+	return block, nil
+}
+
+// Mint n empty blocks on the best chain tip
+func (c *Chain) Generate(n int) []*Block {
+	var blocks []*Block
+	for i := 0; i < n; i++ {
+		blocks = append(blocks, c.Mint())
+	}
+	return blocks
+}
+
+// Mint a block with the given transactions on the best chain tip
+func (c *Chain) Mint(txs ...*tx.Transaction) *Block {
+	return c.MintOn(c.tip, txs...)
+}
+
+// Mint a block with the given transactions on the given parent, if the parent
+// is not the best chain tip, the new block makes a fork
+func (c *Chain) MintOn(parent *Block, txs ...*tx.Transaction) *Block {
+	height := parent.Header.Height + 1
+	block := c.mine(core.Header{
+		Version:   0,
+		Previous:  *parent.Hash(),
+		Timestamp: c.params.GenesisTime + height*c.params.BlockInterval,
+		Bits:      c.params.Bits,
+		Height:    height,
+	}, txs)
+	c.add(block, c.work[*parent.Hash()])
+	return block
+}
+
+// Mint n empty blocks on the given parent, return the blocks in order
+func (c *Chain) Fork(parent *Block, n int) []*Block {
+	var blocks []*Block
+	for i := 0; i < n; i++ {
+		parent = c.MintOn(parent)
+		blocks = append(blocks, parent)
+	}
+	return blocks
+}
+
+/*
+Locate the blocks a node would send for the given block locator, that are the blocks on the
+best chain after the first locator hash found on the best chain. An empty locator or a locator
+with no known hashes locates from the genesis block, the genesis block itself is never returned
+as the SPV client does not store it.
+*/
+func (c *Chain) Locate(locator []*Uint256) []*Block {
+	start := c.genesis.Header.Height
+	for _, hash := range locator {
+		block, ok := c.blocks[*hash]
+		if !ok {
+			continue
+		}
+		onBest, err := c.BlockAt(block.Header.Height)
+		if err == nil && onBest.Hash().IsEqual(hash) {
+			start = block.Header.Height
+			break
+		}
+	}
+
+	var blocks []*Block
+	for block := c.tip; block.Header.Height > start; block = c.blocks[block.Header.Previous] {
+		blocks = append([]*Block{block}, blocks...)
+	}
+	return blocks
+}
+
+func (c *Chain) add(block *Block, parentWork *big.Int) {
+	hash := *block.Hash()
+	work := new(big.Int).Add(parentWork, sdk.CalcWork(block.Header.Bits))
+	c.blocks[hash] = block
+	c.work[hash] = work
+
+	// First seen block wins when the work is the same
+	if c.tip == nil || work.Cmp(c.work[*c.tip.Hash()]) > 0 {
+		c.tip = block
+	}
+}
+
+// Put the coinbase and transactions into the block and solve the proof of work
+func (c *Chain) mine(header core.Header, txs []*tx.Transaction) *Block {
+	block := &Block{
+		Header: header,
+		Txs:    append([]*tx.Transaction{c.coinbase(header.Height)}, txs...),
+	}
+
+	mBlock := newMBlock(block.Txs)
+	block.Header.MerkleRoot = *mBlock.CalcHash(treeHeight(mBlock), 0)
+
+	target := sdk.CompactToBig(header.Bits)
+	block.Header.AuxPow = auxpow.AuxPow{
+		ParBlockHeader: auxpow.BtcHeader{
+			MerkleRoot: *block.Hash(),
+			Timestamp:  header.Timestamp,
+			Bits:       header.Bits,
+		},
+	}
+	for {
+		parHash := block.Header.AuxPow.ParBlockHeader.Hash()
+		if sdk.HashToBig(&parHash).Cmp(target) <= 0 {
+			break
+		}
+		block.Header.AuxPow.ParBlockHeader.Nonce++
+	}
+	block.Header.AuxPow.ParentHash = block.Header.AuxPow.ParBlockHeader.Hash()
+
+	return block
+}
+
+// The coinbase data is the height and the sequence of the block on the chain,
+// so coinbase transactions of blocks at the same height on forks are different
+func (c *Chain) coinbase(height uint32) *tx.Transaction {
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data, height)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(c.blocks)))
+	return &tx.Transaction{
+		TxType:  tx.CoinBase,
+		Payload: &payload.CoinBase{CoinbaseData: data},
+		Inputs: []*tx.Input{
+			{ReferTxOutputIndex: 0xffff, Sequence: 0xffffffff},
+		},
+		Outputs:  []*tx.Output{c.NewOutput(c.payTo, c.params.CoinbaseValue)},
+		LockTime: height,
+	}
+}
+
+func newMBlock(txs []*tx.Transaction) *bloom.MBlock {
+	mBlock := &bloom.MBlock{
+		NumTx:       uint32(len(txs)),
+		AllHashes:   make([]*Uint256, 0, len(txs)),
+		MatchedBits: make([]byte, 0, len(txs)),
+	}
+	for _, txn := range txs {
+		mBlock.AllHashes = append(mBlock.AllHashes, txn.Hash())
+	}
+	return mBlock
+}
+
+// Calculate the number of merkle branches (height) in the tree
+func treeHeight(mBlock *bloom.MBlock) uint32 {
+	height := uint32(0)
+	for mBlock.CalcTreeWidth(height) > 1 {
+		height++
+	}
+	return height
+}
+
+// Create an output of the chain asset pays value to the given program hash
+func (c *Chain) NewOutput(programHash Uint168, value Fixed64) *tx.Output {
+	return &tx.Output{AssetID: c.params.AssetID, Value: value, ProgramHash: programHash}
+}
+
+/*
+Create a transfer transaction spends the given outpoints to the given outputs. The nonce
+attribute makes transactions with the same inputs and outputs different, so conflicting
+transactions can be created to simulate double spends. The transaction is not signed,
+the SPV client does not verify signatures.
+*/
+func (c *Chain) NewTransfer(nonce uint32, inputs []*tx.OutPoint, outputs ...*tx.Output) *tx.Transaction {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, nonce)
+	nonceAttr := tx.NewAttribute(tx.Nonce, data)
+
+	txn := &tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		Attributes: []*tx.Attribute{&nonceAttr},
+		Outputs:    outputs,
+	}
+	for _, op := range inputs {
+		txn.Inputs = append(txn.Inputs, &tx.Input{
+			ReferTxID:          op.TxID,
+			ReferTxOutputIndex: op.Index,
+			Sequence:           0xffffffff,
+		})
+	}
+	return txn
+}
@@ -0,0 +1,202 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+)
+
+// Max sync rounds in one Sync() call, reached only if the chain keeps reorganizing
+const MaxSyncRounds = 100
+
+/*
+Harness drives a SPV blockchain with blocks from the simulated chain. It does what the SPV
+service does with a sync peer, send the block locator, receive the filtered merkle blocks,
+check them and commit them to the blockchain, and restart sync on reorganize. So sync, reorganize
+and double spend scenarios can be tested end to end without network.
+*/
+type Harness struct {
+	Chain      *Chain
+	Store      *MemStore
+	Blockchain *sdk.Blockchain
+}
+
+// Create a harness with a new simulated chain, the coinbase outputs are paid to the given program hash
+func NewHarness(params Params, payTo Uint168) (*Harness, error) {
+	store := NewMemStore()
+	blockchain, err := sdk.NewBlockchain(store)
+	if err != nil {
+		return nil, err
+	}
+	chain := NewChain(params, payTo)
+	blockchain.SetGenesis(&chain.Genesis().Header)
+
+	return &Harness{
+		Chain:      chain,
+		Store:      store,
+		Blockchain: blockchain,
+	}, nil
+}
+
+// Add an address for the SPV client to watch
+func (h *Harness) Watch(programHash Uint168) {
+	h.Store.AddAddr(programHash)
+}
+
+// Build the bloom filter of the watched addresses and unspent outputs, as the SPV service does
+func (h *Harness) BloomFilter() *bloom.Filter {
+	return sdk.BuildBloomFilter(h.Store.GetAddrs(), h.Store.GetOutPoints())
+}
+
+// Sync the SPV blockchain to the best chain tip of the simulated chain,
+// return the number of false positive transactions committed
+func (h *Harness) Sync() (int, error) {
+	h.Blockchain.SetChainState(sdk.SYNCING)
+	defer h.Blockchain.SetChainState(sdk.WAITING)
+
+	fPositives := 0
+	for round := 0; round < MaxSyncRounds; round++ {
+		reorg, fp, err := h.syncRound()
+		fPositives += fp
+		if err != nil {
+			return fPositives, err
+		}
+		if !reorg {
+			return fPositives, nil
+		}
+	}
+	return fPositives, errors.New("sync not finished after max rounds")
+}
+
+// Send the block locator and commit the located blocks, return if a reorganize happened
+func (h *Harness) syncRound() (bool, int, error) {
+	filter := h.BloomFilter()
+	blocks := h.Chain.Locate(h.Blockchain.GetBlockLocatorHashes())
+
+	fPositives := 0
+	for _, block := range blocks {
+		merkleBlock, txs, err := h.filterBlock(block, filter)
+		if err != nil {
+			return false, fPositives, err
+		}
+
+		reorg, fp, err := h.Blockchain.CommitBlock(*merkleBlock, txs)
+		if err != nil {
+			return false, fPositives, err
+		}
+		fPositives += fp
+
+		// If we meet a reorganize, restart sync process
+		if reorg {
+			return true, fPositives, nil
+		}
+	}
+	return false, fPositives, nil
+}
+
+/*
+Sync the SPV blockchain from the simulated peers the way the SPV service downloads the blocks during
+sync. The inventory of the sync peer, the first one, is read in batches of the given size, each split
+by sdk.BlockRanges into ranges downloaded from the peers, stitched at the range boundaries. When a peer
+does not have a block of its range or a boundary does not link, it falls back to download all blocks
+from the sync peer and restarts from the block locator. Return the blocks downloaded from each peer
+and if it fell back to the single peer.
+*/
+func (h *Harness) SyncRanges(peers []*Chain, batch int) ([]int, bool, error) {
+	h.Blockchain.SetChainState(sdk.SYNCING)
+	defer h.Blockchain.SetChainState(sdk.WAITING)
+
+	downloaded := make([]int, len(peers))
+	single := false
+	for round := 0; round < MaxSyncRounds; round++ {
+		err := h.syncRanges(peers, batch, single, downloaded)
+		if err == nil {
+			return downloaded, single, nil
+		}
+		if single {
+			return downloaded, single, err
+		}
+		single = true
+	}
+	return downloaded, single, errors.New("sync not finished after max rounds")
+}
+
+// Download the inventory of the sync peer in ranges from the peers, or the sync peer only in single mode
+func (h *Harness) syncRanges(peers []*Chain, batch int, single bool, downloaded []int) error {
+	filter := h.BloomFilter()
+	var ranges sdk.BlockRanges
+	locator := h.Blockchain.GetBlockLocatorHashes()
+inventory:
+	for {
+		blocks := peers[0].Locate(locator)
+		if len(blocks) == 0 {
+			return nil
+		}
+		if len(blocks) > batch {
+			blocks = blocks[:batch]
+		}
+		hashes := make([]Uint256, 0, len(blocks))
+		for _, block := range blocks {
+			hashes = append(hashes, *block.Hash())
+		}
+		count := len(peers)
+		if single {
+			count = 1
+		}
+
+		for i, peer := range ranges.Split(hashes, count) {
+			block, ok := peers[peer].GetBlock(hashes[i])
+			if !ok {
+				return fmt.Errorf("peer %d does not have block %s", peer, hashes[i].String())
+			}
+			downloaded[peer]++
+			merkleBlock, txs, err := h.filterBlock(block, filter)
+			if err != nil {
+				return err
+			}
+			if err := ranges.Check(&merkleBlock.BlockHeader); err != nil {
+				return err
+			}
+			reorg, _, err := h.Blockchain.CommitBlock(*merkleBlock, txs)
+			if err != nil {
+				return err
+			}
+			// If we meet a reorganize, restart from the block locator
+			if reorg {
+				ranges.Reset()
+				locator = h.Blockchain.GetBlockLocatorHashes()
+				continue inventory
+			}
+		}
+		locator = []*Uint256{&hashes[len(hashes)-1]}
+	}
+}
+
+// Filter the block and check the merkle block as the SPV service does when receiving it
+func (h *Harness) filterBlock(block *Block, filter *bloom.Filter) (*bloom.MerkleBlock, []tx.Transaction, error) {
+	merkleBlock, txs := block.MerkleBlock(filter)
+
+	err := h.Blockchain.CheckProofOfWork(&merkleBlock.BlockHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txIds, err := bloom.CheckMerkleBlock(*merkleBlock)
+	if err != nil {
+		return nil, nil, errors.New("Invalid merkle block: " + err.Error())
+	}
+	if len(txIds) != len(txs) {
+		return nil, nil, fmt.Errorf("merkle block matched %d transactions, received %d", len(txIds), len(txs))
+	}
+	for i, txId := range txIds {
+		if !txId.IsEqual(txs[i].Hash()) {
+			return nil, nil, fmt.Errorf("merkle block transaction %s not received", txId.String())
+		}
+	}
+
+	return merkleBlock, txs, nil
+}
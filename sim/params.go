@@ -0,0 +1,33 @@
+package sim
+
+import (
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+// Params is the network parameters of a simulated private chain
+type Params struct {
+	// Magic number of the private network
+	Magic uint32
+
+	// Difficulty bits of all blocks, the easier the faster blocks are mined
+	Bits uint32
+
+	// Timestamp of the genesis block, blocks after it are BlockInterval seconds apart
+	GenesisTime   uint32
+	BlockInterval uint32
+
+	// Asset id and value of the coinbase outputs
+	AssetID       Uint256
+	CoinbaseValue Fixed64
+}
+
+// Parameters of the regression test network, the blocks are mined with the
+// easiest difficulty and timestamps are fixed, so the chain is the same every run
+var RegTestParams = Params{
+	Magic:         0x7274,
+	Bits:          0x207fffff,
+	GenesisTime:   1514764800,
+	BlockInterval: 120,
+	AssetID:       Uint256{0x01},
+	CoinbaseValue: 50 * 100000000,
+}
@@ -0,0 +1,450 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+)
+
+var (
+	miner   = Uint168{33, 0x01}
+	watched = Uint168{33, 0x02}
+	other   = Uint168{33, 0x03}
+)
+
+func newHarness(t testing.TB, payTo Uint168) *Harness {
+	h, err := NewHarness(RegTestParams, payTo)
+	if err != nil {
+		t.Fatal("create harness error:", err)
+	}
+	h.Watch(watched)
+	return h
+}
+
+func syncChain(t *testing.T, h *Harness) {
+	if _, err := h.Sync(); err != nil {
+		t.Fatal("sync error:", err)
+	}
+	tip := h.Blockchain.ChainTip()
+	if !tip.Hash().IsEqual(h.Chain.Tip().Hash()) {
+		t.Fatalf("chain tip %s, expect %s", tip.Hash().String(), h.Chain.Tip().Hash().String())
+	}
+	if h.Blockchain.Height() != h.Chain.Height() {
+		t.Fatalf("chain height %d, expect %d", h.Blockchain.Height(), h.Chain.Height())
+	}
+}
+
+func coinbaseOutPoint(block *Block) *tx.OutPoint {
+	return tx.NewOutPoint(*block.Txs[0].Hash(), 0)
+}
+
+func TestDeterministic(t *testing.T) {
+	a := NewChain(RegTestParams, miner)
+	b := NewChain(RegTestParams, miner)
+	a.Generate(10)
+	b.Generate(10)
+	if !a.Tip().Hash().IsEqual(b.Tip().Hash()) {
+		t.Fatal("chains minted with the same params are different")
+	}
+}
+
+func TestSync(t *testing.T) {
+	h := newHarness(t, watched)
+	h.Chain.Generate(20)
+	syncChain(t, h)
+
+	if balance := h.Store.Balance(watched); balance != 20*RegTestParams.CoinbaseValue {
+		t.Fatalf("balance %s, expect %s", balance.String(), (20 * RegTestParams.CoinbaseValue).String())
+	}
+
+	// Sync again with new blocks
+	h.Chain.PayTo(miner)
+	h.Chain.Generate(5)
+	syncChain(t, h)
+
+	if balance := h.Store.Balance(watched); balance != 20*RegTestParams.CoinbaseValue {
+		t.Fatalf("balance %s, expect %s", balance.String(), (20 * RegTestParams.CoinbaseValue).String())
+	}
+}
+
+func TestGenesis(t *testing.T) {
+	h := newHarness(t, watched)
+	h.Chain.Generate(5)
+
+	// A chain of another genesis block is not synced
+	params := RegTestParams
+	params.GenesisTime++
+	other := NewChain(params, miner)
+	h.Blockchain.SetGenesis(&other.Genesis().Header)
+	if _, err := h.Sync(); !errors.Is(err, errors.ErrPeerMisbehaving) {
+		t.Fatalf("sync error %v, expect the chain of another genesis refused", err)
+	}
+	if h.Blockchain.Height() != 0 {
+		t.Fatalf("chain height %d, expect nothing committed", h.Blockchain.Height())
+	}
+
+	h.Blockchain.SetGenesis(&h.Chain.Genesis().Header)
+	syncChain(t, h)
+}
+
+func TestReorganize(t *testing.T) {
+	h := newHarness(t, miner)
+	h.Chain.Generate(10)
+	forkPoint, _ := h.Chain.BlockAt(5)
+
+	// Pay to the watched address on the main chain
+	h.Chain.PayTo(watched)
+	paid := h.Chain.Generate(2)
+	syncChain(t, h)
+
+	if balance := h.Store.Balance(watched); balance != 2*RegTestParams.CoinbaseValue {
+		t.Fatalf("balance %s, expect %s", balance.String(), (2 * RegTestParams.CoinbaseValue).String())
+	}
+
+	// A longer fork from height 5 wipes out the payments
+	h.Chain.PayTo(miner)
+	fork := h.Chain.Fork(forkPoint, 10)
+	if !h.Chain.Tip().Hash().IsEqual(fork[len(fork)-1].Hash()) {
+		t.Fatal("longer fork is not the best chain")
+	}
+	syncChain(t, h)
+
+	if balance := h.Store.Balance(watched); balance != 0 {
+		t.Fatalf("balance %s after reorganize, expect 0", balance.String())
+	}
+	for _, block := range paid {
+		if _, ok := h.Store.GetTx(*block.Txs[0].Hash()); ok {
+			t.Fatal("transaction on the wiped out chain not rolled back")
+		}
+	}
+}
+
+func TestDoubleSpend(t *testing.T) {
+	h := newHarness(t, watched)
+	funding := h.Chain.Generate(1)[0]
+	h.Chain.PayTo(miner)
+	h.Chain.Generate(2)
+	forkPoint := h.Chain.Tip()
+
+	// Spend the coinbase to other address on the main chain
+	value := RegTestParams.CoinbaseValue - 100
+	spend := h.Chain.NewTransfer(1, []*tx.OutPoint{coinbaseOutPoint(funding)},
+		h.Chain.NewOutput(other, value))
+	h.Chain.Mint(spend)
+	h.Chain.Generate(1)
+	syncChain(t, h)
+
+	if _, ok := h.Store.GetTx(*spend.Hash()); !ok {
+		t.Fatal("spend transaction not committed")
+	}
+	if balance := h.Store.Balance(watched); balance != 0 {
+		t.Fatalf("balance %s after spend, expect 0", balance.String())
+	}
+
+	// Double spend the coinbase back to the watched address on a longer fork
+	doubleSpend := h.Chain.NewTransfer(2, []*tx.OutPoint{coinbaseOutPoint(funding)},
+		h.Chain.NewOutput(watched, value))
+	block := h.Chain.MintOn(forkPoint, doubleSpend)
+	h.Chain.Fork(block, 2)
+	syncChain(t, h)
+
+	if _, ok := h.Store.GetTx(*spend.Hash()); ok {
+		t.Fatal("double spent transaction not rolled back")
+	}
+	if _, ok := h.Store.GetTx(*doubleSpend.Hash()); !ok {
+		t.Fatal("double spend transaction not committed")
+	}
+	if balance := h.Store.Balance(watched); balance != value {
+		t.Fatalf("balance %s after double spend, expect %s", balance.String(), value.String())
+	}
+}
+
+type alerts chan *sdk.Alert
+
+func (a alerts) OnAlert(alert *sdk.Alert) { a <- alert }
+
+func TestMaxReorgDepth(t *testing.T) {
+	h := newHarness(t, miner)
+	h.Chain.Generate(10)
+	forkPoint, _ := h.Chain.BlockAt(5)
+	syncChain(t, h)
+
+	alerted := make(alerts, 1)
+	h.Blockchain.AddAlertListener(alerted)
+	h.Blockchain.SetMaxReorgDepth(3)
+
+	// The fork wipes out 5 blocks
+	h.Chain.Fork(forkPoint, 10)
+	if _, err := h.Sync(); err != sdk.ErrReorgRefused {
+		t.Fatalf("sync error %v, expect %v", err, sdk.ErrReorgRefused)
+	}
+	if height := h.Blockchain.Height(); height != 10 {
+		t.Fatalf("chain height %d after refused reorganize, expect 10", height)
+	}
+	select {
+	case alert := <-alerted:
+		if alert.Type != sdk.AlertDeepReorg {
+			t.Fatalf("unexpected alert %s", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("refused reorganize not alerted")
+	}
+
+	// Reorganize below the checkpoint is refused without depth limit
+	h.Blockchain.SetMaxReorgDepth(0)
+	checkpoint, _ := h.Chain.BlockAt(6)
+	h.Blockchain.SetCheckpoints(sdk.Checkpoint{Height: 6, Hash: *checkpoint.Hash()})
+	if _, err := h.Sync(); err != sdk.ErrReorgRefused {
+		t.Fatalf("sync error %v below checkpoint, expect %v", err, sdk.ErrReorgRefused)
+	}
+
+	// Checkpoint on the fork point does not refuse it
+	h.Blockchain.SetCheckpoints(sdk.Checkpoint{Height: 5, Hash: *forkPoint.Hash()})
+	syncChain(t, h)
+	if !h.Blockchain.ChainTip().Hash().IsEqual(h.Chain.Tip().Hash()) {
+		t.Fatal("fork above the checkpoint not synced")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	h := newHarness(t, miner)
+	// Sync in rounds so the locator is extended across a rebuild
+	for i := 0; i < 5; i++ {
+		h.Chain.Generate(250)
+		syncChain(t, h)
+	}
+
+	snapshot := h.Blockchain.Snapshot()
+	if !snapshot.Tip.IsEqual(h.Chain.Tip().Hash()) || snapshot.Height != h.Chain.Height() {
+		t.Fatal("snapshot is not on the chain tip")
+	}
+	if len(snapshot.Locator) < 20 || len(snapshot.Locator) > sdk.MaxBlockLocatorHashes {
+		t.Fatalf("unexpected locator length %d", len(snapshot.Locator))
+	}
+	for i, entry := range snapshot.Locator {
+		if i > 0 && entry.Height >= snapshot.Locator[i-1].Height {
+			t.Fatalf("locator heights not descending at %d", i)
+		}
+		block, err := h.Chain.BlockAt(entry.Height)
+		if err != nil || !block.Hash().IsEqual(&entry.Hash) {
+			t.Fatalf("locator hash at height %d is not on the best chain", entry.Height)
+		}
+	}
+
+	// A restarted blockchain loads the saved snapshot
+	restarted, err := sdk.NewBlockchain(h.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := restarted.Snapshot()
+	if !loaded.Tip.IsEqual(&snapshot.Tip) || loaded.TotalWork.Cmp(snapshot.TotalWork) != 0 ||
+		len(loaded.Locator) != len(snapshot.Locator) {
+		t.Fatal("saved snapshot not loaded")
+	}
+}
+
+func TestGetHeightByTime(t *testing.T) {
+	h := newHarness(t, miner)
+	h.Chain.Generate(30)
+	syncChain(t, h)
+
+	// The median timestamp of a block is the timestamp of 5 blocks before it
+	params := RegTestParams
+	height, err := h.Blockchain.GetHeightByTime(params.GenesisTime + 20*params.BlockInterval)
+	if err != nil {
+		t.Fatal("get height by time error:", err)
+	}
+	if height != 25 {
+		t.Fatalf("height %d, expect 25", height)
+	}
+
+	// Earlier than the genesis block, and later than the chain tip
+	if height, _ := h.Blockchain.GetHeightByTime(0); height != 1 {
+		t.Fatalf("height %d, expect 1", height)
+	}
+	if height, _ := h.Blockchain.GetHeightByTime(params.GenesisTime + 100*params.BlockInterval); height != h.Chain.Height() {
+		t.Fatalf("height %d, expect %d", height, h.Chain.Height())
+	}
+}
+
+type rawBlocks chan *sdk.RawBlock
+
+func (c rawBlocks) OnRawBlock(block *sdk.RawBlock) { c <- block }
+
+func (c rawBlocks) OnRawRollback(height uint32) {}
+
+func TestRawBlockListener(t *testing.T) {
+	h := newHarness(t, watched)
+	listener := make(rawBlocks, 100)
+	h.Blockchain.AddRawBlockListener(listener)
+	h.Chain.Generate(10)
+	syncChain(t, h)
+
+	for height := uint32(1); height <= h.Chain.Height(); height++ {
+		var raw *sdk.RawBlock
+		select {
+		case raw = <-listener:
+		case <-time.After(time.Second):
+			t.Fatalf("raw block %d not delivered", height)
+		}
+		block, _ := h.Chain.BlockAt(height)
+		if raw.Height != height || !raw.Hash.IsEqual(block.Hash()) {
+			t.Fatalf("raw block %d %s, expect %d %s", raw.Height, raw.Hash.String(), height, block.Hash().String())
+		}
+
+		// The raw bytes decode to the same block and transactions
+		merkleBlock := new(bloom.MerkleBlock)
+		if err := merkleBlock.Deserialize(raw.MerkleBlock); err != nil {
+			t.Fatal("deserialize raw merkleblock error:", err)
+		}
+		if !merkleBlock.BlockHeader.Hash().IsEqual(block.Hash()) {
+			t.Fatal("raw merkleblock hash mismatch")
+		}
+		if len(raw.Txs) == 0 {
+			t.Fatal("matched coinbase not delivered")
+		}
+		for i, txBytes := range raw.Txs {
+			var txn tx.Transaction
+			if err := txn.Deserialize(bytes.NewReader(txBytes)); err != nil {
+				t.Fatal("deserialize raw transaction error:", err)
+			}
+			if !txn.Hash().IsEqual(&raw.TxIds[i]) {
+				t.Fatal("raw transaction hash mismatch")
+			}
+		}
+	}
+}
+
+type summaries chan *sdk.BlockSummary
+
+func (c summaries) OnTxCommitted(tx tx.Transaction, height uint32)       {}
+func (c summaries) OnBlockCommitted(bloom.MerkleBlock, []tx.Transaction) {}
+func (c summaries) OnChainRollback(height uint32)                        {}
+func (c summaries) OnBlockProcessed(summary *sdk.BlockSummary)           { c <- summary }
+
+func TestBlockSummary(t *testing.T) {
+	h := newHarness(t, watched)
+	listener := make(summaries, 100)
+	h.Blockchain.AddStateListener(listener)
+	funding := h.Chain.Generate(1)[0]
+	h.Chain.PayTo(miner)
+	value := RegTestParams.CoinbaseValue - 100
+	spend := h.Chain.NewTransfer(1, []*tx.OutPoint{coinbaseOutPoint(funding)},
+		h.Chain.NewOutput(other, value))
+	h.Chain.Mint(spend)
+	syncChain(t, h)
+
+	// Summaries are notified concurrently, collect them by height
+	received := make(map[uint32]*sdk.BlockSummary)
+	for len(received) < int(h.Chain.Height()) {
+		select {
+		case summary := <-listener:
+			received[summary.Height] = summary
+		case <-time.After(time.Second):
+			t.Fatalf("%d of %d block summaries notified", len(received), h.Chain.Height())
+		}
+	}
+
+	first := received[1]
+	if !first.Hash.IsEqual(funding.Hash()) || first.Txs != 1 ||
+		first.Received != RegTestParams.CoinbaseValue || first.Spent != 0 {
+		t.Errorf("unexpected summary of the funding block %+v", first)
+	}
+	second := received[2]
+	if second.Txs != 1 || second.Received != 0 || second.Spent != RegTestParams.CoinbaseValue {
+		t.Errorf("unexpected summary of the spending block %+v", second)
+	}
+}
+
+func TestStorageFailure(t *testing.T) {
+	h := newHarness(t, watched)
+	listener := make(alerts, 10)
+	h.Blockchain.AddAlertListener(listener)
+	h.Chain.Generate(5)
+	syncChain(t, h)
+
+	// The blocks are not committed after a write failed, even if the writes recovered
+	h.Chain.Generate(2)
+	h.Store.FailWrites(errors.New("disk full"))
+	if _, err := h.Sync(); !errors.Is(err, errors.ErrStorage) {
+		t.Fatalf("sync error %v, expect a storage failure", err)
+	}
+	select {
+	case alert := <-listener:
+		if alert.Type != sdk.AlertStorageFailure || alert.Height != 6 {
+			t.Errorf("unexpected alert %s", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("storage failure not alerted")
+	}
+	h.Store.FailWrites(nil)
+	if _, err := h.Sync(); !errors.Is(err, errors.ErrStorage) || h.Blockchain.Height() != 5 {
+		t.Fatalf("sync error %v at height %d, expect stopped at height 5", err, h.Blockchain.Height())
+	}
+
+	// Committing again after the failure cleared
+	h.Blockchain.ClearFailure()
+	syncChain(t, h)
+	if h.Blockchain.Failure() != nil {
+		t.Error("failure not cleared,", h.Blockchain.Failure())
+	}
+}
+
+func TestSyncRanges(t *testing.T) {
+	h := newHarness(t, watched)
+	h.Chain.Generate(25)
+	peers := []*Chain{h.Chain, NewChain(RegTestParams, watched), NewChain(RegTestParams, watched)}
+	peers[1].Generate(25)
+	peers[2].Generate(25)
+
+	// The batches of 10 hashes are split into ranges of 4, 4 and 2 blocks
+	downloaded, single, err := h.SyncRanges(peers, 10)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if single {
+		t.Error("fell back to single peer with the peers consistent")
+	}
+	if downloaded[0] != 10 || downloaded[1] != 10 || downloaded[2] != 5 {
+		t.Errorf("blocks downloaded from the peers %v, expect [10 10 5]", downloaded)
+	}
+	if !h.Blockchain.ChainTip().Hash().IsEqual(h.Chain.Tip().Hash()) {
+		t.Fatal("chain tip not the tip of the sync peer")
+	}
+	if balance := h.Store.Balance(watched); balance != 25*RegTestParams.CoinbaseValue {
+		t.Errorf("balance %s, expect %s", balance.String(), (25 * RegTestParams.CoinbaseValue).String())
+	}
+}
+
+func TestSyncRangesFallback(t *testing.T) {
+	h := newHarness(t, watched)
+	h.Chain.Generate(20)
+	// The last peer forked at height 5, it does not have the blocks of its range
+	forked := NewChain(RegTestParams, watched)
+	forked.Generate(5)
+	forked.PayTo(other)
+	forked.Generate(20)
+	peers := []*Chain{h.Chain, NewChain(RegTestParams, watched), forked}
+	peers[1].Generate(20)
+
+	downloaded, single, err := h.SyncRanges(peers, 10)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !single {
+		t.Error("not fell back to single peer with a peer inconsistent")
+	}
+	if !h.Blockchain.ChainTip().Hash().IsEqual(h.Chain.Tip().Hash()) {
+		t.Fatal("chain tip not the tip of the sync peer")
+	}
+	// The first 8 blocks of the ranges before the forked one are kept, the rest from the sync peer
+	if downloaded[0] != 16 || downloaded[1] != 4 || downloaded[2] != 0 {
+		t.Errorf("blocks downloaded from the peers %v, expect [16 4 0]", downloaded)
+	}
+}
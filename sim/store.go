@@ -0,0 +1,305 @@
+package sim
+
+import (
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Output is an unspent or spent output of the watched addresses
+type Output struct {
+	Op          tx.OutPoint
+	ProgramHash Uint168
+	Value       Fixed64
+	Height      uint32
+
+	// Spent by and spent height, empty for unspent outputs
+	SpendTxId   *Uint256
+	SpendHeight uint32
+}
+
+/*
+MemStore is an in memory implementation of db.DataStore, it saves headers and
+the transactions of the watched addresses like the SPV wallet does, so the
+simulated SPV client can be checked without touching the disk.
+*/
+type MemStore struct {
+	lock     sync.RWMutex
+	headers  map[Uint256]*db.StoreHeader
+	tip      *db.StoreHeader
+	height   uint32
+	addrs    map[Uint168]struct{}
+	outputs  map[tx.OutPoint]*Output
+	txs      map[Uint256]*db.StoreTx
+	snapshot []byte
+	// error returned by the writes of headers and transactions, to simulate a failed disk
+	writeErr error
+}
+
+func NewMemStore() *MemStore {
+	store := new(MemStore)
+	store.clear()
+	return store
+}
+
+func (s *MemStore) clear() {
+	s.headers = make(map[Uint256]*db.StoreHeader)
+	s.tip = nil
+	s.height = 0
+	s.addrs = make(map[Uint168]struct{})
+	s.outputs = make(map[tx.OutPoint]*Output)
+	s.txs = make(map[Uint256]*db.StoreTx)
+	s.snapshot = nil
+}
+
+// Add an address to watch
+func (s *MemStore) AddAddr(programHash Uint168) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addrs[programHash] = struct{}{}
+}
+
+// Fail the writes of headers and transactions with the error, nil to write again
+func (s *MemStore) FailWrites(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.writeErr = err
+}
+
+// Get the watched addresses
+func (s *MemStore) GetAddrs() []*Uint168 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var addrs []*Uint168
+	for programHash := range s.addrs {
+		hash := programHash
+		addrs = append(addrs, &hash)
+	}
+	return addrs
+}
+
+// Get the outpoints of unspent outputs
+func (s *MemStore) GetOutPoints() []*tx.OutPoint {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var ops []*tx.OutPoint
+	for _, output := range s.outputs {
+		if output.SpendTxId == nil {
+			op := output.Op
+			ops = append(ops, &op)
+		}
+	}
+	return ops
+}
+
+// Get the unspent outputs of the given address
+func (s *MemStore) GetUTXOs(programHash Uint168) []*Output {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	var utxos []*Output
+	for _, output := range s.outputs {
+		if output.SpendTxId == nil && output.ProgramHash == programHash {
+			utxos = append(utxos, output)
+		}
+	}
+	return utxos
+}
+
+// Get the balance of the given address
+func (s *MemStore) Balance(programHash Uint168) Fixed64 {
+	var balance Fixed64
+	for _, utxo := range s.GetUTXOs(programHash) {
+		balance += utxo.Value
+	}
+	return balance
+}
+
+// Get a saved transaction by it's id
+func (s *MemStore) GetTx(txId Uint256) (*db.StoreTx, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	txn, ok := s.txs[txId]
+	return txn, ok
+}
+
+// Save a header to database
+func (s *MemStore) PutHeader(header *db.StoreHeader, newTip bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	s.headers[*header.Hash()] = header
+	if newTip {
+		s.tip = header
+	}
+	return nil
+}
+
+// Get previous block of the given header
+func (s *MemStore) GetPrevious(header *db.StoreHeader) (*db.StoreHeader, error) {
+	return s.GetHeader(header.Previous)
+}
+
+// Get full header with it's hash
+func (s *MemStore) GetHeader(hash Uint256) (*db.StoreHeader, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	header, ok := s.headers[hash]
+	if !ok {
+		return nil, errors.Wrap(errors.ErrNotFound, "header not exist")
+	}
+	return header, nil
+}
+
+// Get the header on chain tip
+func (s *MemStore) GetChainTip() (*db.StoreHeader, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.tip == nil {
+		return nil, errors.Wrap(errors.ErrNotFound, "no chain tip")
+	}
+	return s.tip, nil
+}
+
+// Save chain height to database
+func (s *MemStore) PutChainHeight(height uint32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.height = height
+}
+
+// Get chain height from database
+func (s *MemStore) GetChainHeight() uint32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.height
+}
+
+// Commit a transaction return if this is a false positive and error
+func (s *MemStore) CommitTx(storeTx *db.StoreTx) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.writeErr != nil {
+		return false, s.writeErr
+	}
+	hits := 0
+	for index, output := range storeTx.Data.Outputs {
+		if _, ok := s.addrs[output.ProgramHash]; ok {
+			op := tx.NewOutPoint(storeTx.TxId, uint16(index))
+			s.outputs[*op] = &Output{
+				Op:          *op,
+				ProgramHash: output.ProgramHash,
+				Value:       output.Value,
+				Height:      storeTx.Height,
+			}
+			hits++
+		}
+	}
+
+	for _, input := range storeTx.Data.Inputs {
+		op := tx.NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex)
+		if output, ok := s.outputs[*op]; ok && output.SpendTxId == nil {
+			txId := storeTx.TxId
+			output.SpendTxId = &txId
+			output.SpendHeight = storeTx.Height
+			hits++
+		}
+	}
+
+	if hits == 0 {
+		return true, nil
+	}
+
+	s.txs[storeTx.TxId] = storeTx
+	return false, nil
+}
+
+// Get the value of the unspent output of the wallet, false if it's not an unspent wallet output
+func (s *MemStore) GetOutputValue(op *tx.OutPoint) (Fixed64, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	output, ok := s.outputs[*op]
+	if !ok || output.SpendTxId != nil {
+		return 0, false
+	}
+	return output.Value, true
+}
+
+// Rollback chain data on the given height
+func (s *MemStore) Rollback(height uint32) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for op, output := range s.outputs {
+		if output.Height == height {
+			delete(s.outputs, op)
+			continue
+		}
+		if output.SpendTxId != nil && output.SpendHeight == height {
+			output.SpendTxId = nil
+			output.SpendHeight = 0
+		}
+	}
+
+	for txId, txn := range s.txs {
+		if txn.Height == height {
+			delete(s.txs, txId)
+		}
+	}
+	return nil
+}
+
+// Save the chain snapshot serialized as it's saved on disk
+func (s *MemStore) PutChainSnapshot(snapshot *db.ChainSnapshot) error {
+	buf, err := snapshot.Serialize()
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.snapshot = buf
+	return nil
+}
+
+// Get the saved chain snapshot
+func (s *MemStore) GetChainSnapshot() (*db.ChainSnapshot, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.snapshot == nil {
+		return nil, errors.Wrap(errors.ErrNotFound, "no chain snapshot")
+	}
+	snapshot := new(db.ChainSnapshot)
+	return snapshot, snapshot.Deserialize(s.snapshot)
+}
+
+// Reset database, clear all data
+func (s *MemStore) Reset() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.clear()
+	return nil
+}
+
+// Close the database
+func (s *MemStore) Close() {}
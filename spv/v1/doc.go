@@ -0,0 +1,18 @@
+/*
+Package v1 is the stable public API of the SPV SDK, import it as
+
+	spv "github.com/elastos/Elastos.ELA.SPV/spv/v1"
+
+It wraps the SPV service, the sync manager and the wallet with types of its own, hashes, transactions and
+proofs are plain values decoupled from the internal structs, so the internal packages can be refactored
+without breaking the downstream projects like the sidechains.
+
+The API follows semantic versioning. Within the major version 1, exported names are never removed or
+changed in a way that breaks a caller, new functions, methods and struct fields may be added in minor
+versions. The interfaces are implemented by this package only, do not implement them outside, methods may
+be added to them. A breaking change goes to a new package spv/v2, so both can be imported side by side.
+*/
+package v1
+
+// Version is the semantic version of the API, the major version matches the package path
+const Version = "1.0.0"
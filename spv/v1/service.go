@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/interface"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
+)
+
+// Config is the settings of the service, the settings not given are read from the config file
+type Config struct {
+	// Magic number of the peer to peer network, 0 means main net
+	Magic uint32
+	// Full node addresses to connect, like "127.0.0.1:20866"
+	Seeds []string
+	// Directory to store databases, keystore and logs, empty means the work directory
+	DataDir string
+}
+
+/*
+Service is the SPV service running background, register the addresses you are interested in
+and listen to the transactions paid to them, each transaction comes with the merkle proof.
+*/
+type Service interface {
+	// Register the address under the account, empty account means the default account.
+	// Registered addresses are persisted and reloaded when the service starts
+	RegisterAddress(account, address string) error
+
+	// Listen to the transactions of the registered addresses accepted by the filter
+	Listen(filter Filter, listener Listener)
+
+	// Confirm the notified transaction was handled, so it's not notified again
+	SubmitReceipt(txHash Hash) error
+
+	// Verify the serialized transaction with the merkle proof
+	VerifyTransaction(proof *Proof, raw []byte) error
+
+	// Get the merkle proof of a received transaction
+	GetTransactionProof(txHash Hash) (*Proof, error)
+
+	// Send the serialized signed transaction to the network
+	SendTransaction(raw []byte) error
+
+	// Send the serialized signed transaction with an idempotency key, a call retried
+	// with the same key and transaction does not send it again
+	SendTransactionWithKey(key string, raw []byte) error
+
+	// Get the sync manager of the service
+	Sync() SyncManager
+
+	// Start the service, it blocks until the service stopped
+	Start() error
+
+	// Stop the service, Start() returns after stopped
+	Stop()
+}
+
+// Filter selects the transactions notified to a listener
+type Filter struct {
+	// Transaction type, like 0x02 for the transfer asset transactions
+	Type uint8
+	// Notify the transactions after they confirmed instead of at once
+	Confirmed bool
+}
+
+// Listener is notified of the transactions accepted by the filter it's registered with,
+// call Service.SubmitReceipt() after the transaction is handled, or it will be notified again
+type Listener interface {
+	Notify(txn *Transaction, proof *Proof)
+}
+
+type service struct {
+	service _interface.SPVService
+	sync    *syncManager
+}
+
+// NewService creates the SPV service with the config, nil means the config file
+func NewService(clientId uint64, cfg *Config) (Service, error) {
+	values := *config.Values()
+	if cfg != nil {
+		values.Magic = cfg.Magic
+		values.SeedList = cfg.Seeds
+		values.DataDir = cfg.DataDir
+	}
+	if err := spvwallet.Setup(&values); err != nil {
+		return nil, err
+	}
+	log.InitWithConfig(&values)
+
+	s := _interface.NewSPVServiceWithConfig(clientId, &values)
+	return &service{service: s, sync: &syncManager{service: s}}, nil
+}
+
+func (s *service) RegisterAddress(account, address string) error {
+	if account == "" {
+		return s.service.RegisterAccount(address)
+	}
+	return s.service.RegisterAccountAddress(account, address)
+}
+
+func (s *service) Listen(filter Filter, listener Listener) {
+	s.service.RegisterTransactionListener(&txListener{filter: filter, listener: listener})
+}
+
+func (s *service) SubmitReceipt(txHash Hash) error {
+	return s.service.SubmitTransactionReceipt(common.Uint256(txHash))
+}
+
+func (s *service) VerifyTransaction(proof *Proof, raw []byte) error {
+	txn, err := deserializeTx(raw)
+	if err != nil {
+		return err
+	}
+	return s.service.VerifyTransaction(*proof.internal(), *txn)
+}
+
+func (s *service) GetTransactionProof(txHash Hash) (*Proof, error) {
+	proof, err := s.service.GetTransactionProof(common.Uint256(txHash))
+	if err != nil {
+		return nil, err
+	}
+	return newProof(proof), nil
+}
+
+func (s *service) SendTransaction(raw []byte) error {
+	txn, err := deserializeTx(raw)
+	if err != nil {
+		return err
+	}
+	return s.service.SendTransaction(*txn)
+}
+
+func (s *service) SendTransactionWithKey(key string, raw []byte) error {
+	txn, err := deserializeTx(raw)
+	if err != nil {
+		return err
+	}
+	return s.service.SendTransactionWithKey(key, *txn)
+}
+
+func (s *service) Sync() SyncManager {
+	return s.sync
+}
+
+func (s *service) Start() error {
+	return s.service.Start()
+}
+
+func (s *service) Stop() {
+	s.service.Stop()
+}
+
+// txListener adapts the Listener to the TransactionListener of the service
+type txListener struct {
+	filter   Filter
+	listener Listener
+}
+
+func (l *txListener) Type() tx.TransactionType {
+	return tx.TransactionType(l.filter.Type)
+}
+
+func (l *txListener) Confirmed() bool {
+	return l.filter.Confirmed
+}
+
+func (l *txListener) Notify(proof _interface.Proof, txn tx.Transaction) {
+	raw, err := serializeTx(&txn)
+	if err != nil {
+		log.Error("Serialize notified transaction failed, ", err)
+		return
+	}
+	l.listener.Notify(&Transaction{Hash: Hash(*txn.Hash()), Type: uint8(txn.TxType), Raw: raw}, newProof(&proof))
+}
@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/interface"
+)
+
+// SyncManager follows the chain with the peers, the chain is empty until the service started
+type SyncManager interface {
+	// Get the height of the synced chain, 0 if the service not started
+	Height() uint32
+
+	// Get the header on the chain tip
+	ChainTip() (*Header, error)
+
+	// Check if the service has enough connected peers,
+	// a service not healthy for a long time should be restarted
+	IsHealthy() bool
+
+	// Check if the service is healthy and synced up with the network,
+	// transactions are not notified in time when it's not ready
+	IsReady() bool
+
+	// Signal the connection is metered or not, the sync runs in the low bandwidth mode on a metered connection
+	SetMetered(metered bool)
+
+	// Stop the network activity when the app is in background, the sync continues
+	// from the last committed block when resumed
+	Pause()
+
+	// Continue the network activity after paused
+	Resume()
+}
+
+type syncManager struct {
+	service _interface.SPVService
+}
+
+func (s *syncManager) Height() uint32 {
+	if chain := s.service.Blockchain(); chain != nil {
+		return chain.Height()
+	}
+	return 0
+}
+
+func (s *syncManager) ChainTip() (*Header, error) {
+	chain := s.service.Blockchain()
+	if chain == nil {
+		return nil, errors.Wrap(errors.ErrNotStarted, "[SyncManager], service not started")
+	}
+	// The tip is an empty header if no header synced
+	tip := chain.ChainTip()
+	if tip.Timestamp == 0 {
+		return nil, errors.Wrap(errors.ErrNotFound, "[SyncManager], no header synced")
+	}
+	return newHeader(&tip.Header), nil
+}
+
+func (s *syncManager) IsHealthy() bool {
+	return s.service.IsHealthy()
+}
+
+func (s *syncManager) IsReady() bool {
+	return s.service.IsReady()
+}
+
+func (s *syncManager) SetMetered(metered bool) {
+	s.service.SetMetered(metered)
+}
+
+func (s *syncManager) Pause() {
+	s.service.Pause()
+}
+
+func (s *syncManager) Resume() {
+	s.service.Resume()
+}
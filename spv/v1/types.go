@@ -0,0 +1,184 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/interface"
+	walletdb "github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// Hash is a transaction or block hash in byte order
+type Hash [32]byte
+
+// ParseHash parses a hash from the reversed hex string, as it's shown by the block explorers
+func ParseHash(str string) (Hash, error) {
+	var hash Hash
+	data, err := common.HexStringToBytesReverse(str)
+	if err != nil || len(data) != len(hash) {
+		return hash, errors.New("invalid hash string " + str)
+	}
+	copy(hash[:], data)
+	return hash, nil
+}
+
+// String returns the reversed hex string of the hash, as it's shown by the block explorers
+func (h Hash) String() string {
+	hash := common.Uint256(h)
+	return common.BytesToHexString(hash.BytesReverse())
+}
+
+// Transaction is a transaction in the serialized form with the hash and type of it
+type Transaction struct {
+	Hash Hash
+	Type uint8
+	Raw  []byte
+}
+
+// ParseTransaction decodes the serialized transaction
+func ParseTransaction(raw []byte) (*Transaction, error) {
+	txn, err := deserializeTx(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{Hash: Hash(*txn.Hash()), Type: uint8(txn.TxType), Raw: raw}, nil
+}
+
+// Proof is the merkle proof of a transaction in a block, verify it with Service.VerifyTransaction()
+type Proof struct {
+	BlockHash    Hash
+	Height       uint32
+	Transactions uint32
+	Hashes       []Hash
+	Flags        []byte
+}
+
+// Bytes returns the serialized proof, it's the merkle proof of a recharging transaction on a sidechain
+func (p *Proof) Bytes() ([]byte, error) {
+	proof := p.internal()
+	buf := new(bytes.Buffer)
+	if err := proof.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseProof decodes the serialized proof
+func ParseProof(data []byte) (*Proof, error) {
+	var proof _interface.Proof
+	if err := proof.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return newProof(&proof), nil
+}
+
+func newProof(proof *_interface.Proof) *Proof {
+	p := &Proof{
+		BlockHash:    Hash(proof.BlockHash),
+		Height:       proof.Height,
+		Transactions: proof.Transactions,
+		Flags:        proof.Flags,
+	}
+	for _, hash := range proof.Hashes {
+		p.Hashes = append(p.Hashes, Hash(*hash))
+	}
+	return p
+}
+
+func (p *Proof) internal() *_interface.Proof {
+	proof := &_interface.Proof{
+		BlockHash:    common.Uint256(p.BlockHash),
+		Height:       p.Height,
+		Transactions: p.Transactions,
+		Flags:        p.Flags,
+	}
+	for _, hash := range p.Hashes {
+		h := common.Uint256(hash)
+		proof.Hashes = append(proof.Hashes, &h)
+	}
+	return proof
+}
+
+// Header is a block header on the synced chain
+type Header struct {
+	Hash      Hash
+	Previous  Hash
+	Height    uint32
+	Timestamp time.Time
+}
+
+func newHeader(header *core.Header) *Header {
+	return &Header{
+		Hash:      Hash(*header.Hash()),
+		Previous:  Hash(header.Previous),
+		Height:    header.Height,
+		Timestamp: time.Unix(int64(header.Timestamp), 0),
+	}
+}
+
+// UTXO is an unspent output of a wallet address, values are in the smallest unit, 1 ELA is 100000000
+type UTXO struct {
+	TxHash   Hash
+	Index    uint16
+	Value    int64
+	Height   uint32
+	LockTime uint32
+}
+
+func newUTXO(utxo *walletdb.UTXO) *UTXO {
+	return &UTXO{
+		TxHash:   Hash(utxo.Op.TxID),
+		Index:    utxo.Op.Index,
+		Value:    int64(utxo.Value),
+		Height:   utxo.AtHeight,
+		LockTime: utxo.LockTime,
+	}
+}
+
+// JournalEntry is an event changed the wallet, Type is one of "connect", "disconnect", "credit",
+// "debit" and "reset". Replay the entries in the order of Seq to rebuild the wallet state
+type JournalEntry struct {
+	Seq       uint64
+	Type      string
+	Height    uint32
+	BlockHash Hash
+	TxHash    Hash
+	Index     uint16
+	Address   string
+	Value     int64
+	Time      time.Time
+}
+
+func newJournalEntry(entry *walletdb.JournalEntry) *JournalEntry {
+	return &JournalEntry{
+		Seq:       entry.Seq,
+		Type:      entry.Type.String(),
+		Height:    entry.Height,
+		BlockHash: Hash(entry.BlockHash),
+		TxHash:    Hash(entry.TxId),
+		Index:     entry.Op.Index,
+		Address:   entry.Address,
+		Value:     int64(entry.Value),
+		Time:      time.Unix(entry.Time, 0),
+	}
+}
+
+func serializeTx(txn *tx.Transaction) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := txn.Serialize(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func deserializeTx(raw []byte) (*tx.Transaction, error) {
+	txn := new(tx.Transaction)
+	if err := txn.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/interface"
+)
+
+func TestHash(t *testing.T) {
+	var hash Hash
+	hash[0], hash[31] = 0x01, 0xff
+
+	// The string is reversed as the block explorers show
+	str := hash.String()
+	if str[:2] != "ff" || str[len(str)-2:] != "01" {
+		t.Errorf("hash string %s not reversed", str)
+	}
+	parsed, err := ParseHash(str)
+	if err != nil || parsed != hash {
+		t.Error("hash not parsed from the string,", err)
+	}
+	if _, err := ParseHash("0102"); err == nil {
+		t.Error("short hash string parsed")
+	}
+}
+
+func TestProof(t *testing.T) {
+	txHash := common.Uint256{1}
+	internal := &_interface.Proof{BlockHash: common.Uint256{2}, Height: 100, Transactions: 3,
+		Hashes: []*common.Uint256{&txHash}, Flags: []byte{1}}
+
+	proof := newProof(internal)
+	if proof.Height != 100 || proof.BlockHash != (Hash{2}) || len(proof.Hashes) != 1 || proof.Hashes[0] != (Hash{1}) {
+		t.Fatalf("unexpected proof %+v", proof)
+	}
+
+	// The serialized proof is the same as the internal one, so it's accepted by the sidechains
+	data, err := proof.Bytes()
+	if err != nil {
+		t.Fatal("serialize proof error,", err)
+	}
+	buf := new(bytes.Buffer)
+	internal.Serialize(buf)
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Error("serialized proof differs from the internal one")
+	}
+	parsed, err := ParseProof(data)
+	if err != nil || parsed.Transactions != 3 || parsed.Hashes[0] != (Hash{1}) || !bytes.Equal(parsed.Flags, []byte{1}) {
+		t.Errorf("proof not parsed, %+v %v", parsed, err)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	txn := &tx.Transaction{
+		TxType:  tx.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Outputs: []*tx.Output{{Value: 100000000}},
+	}
+	raw, err := serializeTx(txn)
+	if err != nil {
+		t.Fatal("serialize transaction error,", err)
+	}
+	parsed, err := ParseTransaction(raw)
+	if err != nil {
+		t.Fatal("parse transaction error,", err)
+	}
+	if parsed.Hash != Hash(*txn.Hash()) || parsed.Type != uint8(tx.TransferAsset) || !bytes.Equal(parsed.Raw, raw) {
+		t.Errorf("unexpected transaction %+v", parsed)
+	}
+	if _, err := ParseTransaction(raw[:len(raw)/2]); err == nil {
+		t.Error("truncated transaction parsed")
+	}
+}
@@ -0,0 +1,156 @@
+package v1
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet"
+)
+
+/*
+Wallet is the SPV wallet with the keystore and database in the data directory of the service.
+Transactions are created and signed in the serialized form, send them by Service.SendTransaction()
+or Wallet.SendTransaction(). Amounts are in the smallest unit, 1 ELA is 100000000.
+*/
+type Wallet interface {
+	// Get the addresses of the accounts in the wallet
+	Addresses() ([]string, error)
+
+	// Create a new sub account and return the address of it
+	NewAddress(password []byte) (string, error)
+
+	// Get the balance of the address, including the locked and unconfirmed UTXOs
+	Balance(address string) (int64, error)
+
+	// Get the unspent outputs of the address
+	UTXOs(address string) ([]*UTXO, error)
+
+	// Get up to limit entries of the wallet journal after the sequence number,
+	// 0 gets from the first entry, limit is at most 1000
+	Journal(seq uint64, limit int) ([]*JournalEntry, error)
+
+	// Get the height of the chain the wallet synced to
+	ChainHeight() uint32
+
+	// Create a serialized unsigned transaction to transfer the amount from one address to another
+	CreateTransaction(fromAddress, toAddress string, amount, fee int64) ([]byte, error)
+
+	// Sign the serialized transaction and return the serialized signed transaction
+	Sign(password []byte, raw []byte) ([]byte, error)
+
+	// Send the serialized signed transaction through the running service
+	SendTransaction(raw []byte) error
+}
+
+type wallet struct {
+	wallet spvwallet.Wallet
+}
+
+// CreateWallet creates a new wallet protected by the password
+func CreateWallet(password []byte) (Wallet, error) {
+	w, err := spvwallet.Create(password)
+	if err != nil {
+		return nil, err
+	}
+	return &wallet{wallet: w}, nil
+}
+
+// OpenWallet opens the wallet created before
+func OpenWallet() (Wallet, error) {
+	w, err := spvwallet.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &wallet{wallet: w}, nil
+}
+
+func (w *wallet) Addresses() ([]string, error) {
+	addrs, err := w.wallet.GetAddrs()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		addresses = append(addresses, addr.String())
+	}
+	return addresses, nil
+}
+
+func (w *wallet) NewAddress(password []byte) (string, error) {
+	programHash, err := w.wallet.NewSubAccount(password)
+	if err != nil {
+		return "", err
+	}
+	return programHash.ToAddress()
+}
+
+func (w *wallet) Balance(address string) (int64, error) {
+	utxos, err := w.UTXOs(address)
+	if err != nil {
+		return 0, err
+	}
+	var balance int64
+	for _, utxo := range utxos {
+		balance += utxo.Value
+	}
+	return balance, nil
+}
+
+func (w *wallet) UTXOs(address string) ([]*UTXO, error) {
+	programHash, err := common.Uint168FromAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := w.wallet.GetAddressUTXOs(programHash)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*UTXO, 0, len(utxos))
+	for _, utxo := range utxos {
+		result = append(result, newUTXO(utxo))
+	}
+	return result, nil
+}
+
+func (w *wallet) Journal(seq uint64, limit int) ([]*JournalEntry, error) {
+	entries, err := w.wallet.GetJournal(seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*JournalEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, newJournalEntry(entry))
+	}
+	return result, nil
+}
+
+func (w *wallet) ChainHeight() uint32 {
+	return w.wallet.ChainHeight()
+}
+
+func (w *wallet) CreateTransaction(fromAddress, toAddress string, amount, fee int64) ([]byte, error) {
+	value, txFee := common.Fixed64(amount), common.Fixed64(fee)
+	txn, err := w.wallet.CreateTransaction(fromAddress, toAddress, &value, &txFee)
+	if err != nil {
+		return nil, err
+	}
+	return serializeTx(txn)
+}
+
+func (w *wallet) Sign(password []byte, raw []byte) ([]byte, error) {
+	txn, err := deserializeTx(raw)
+	if err != nil {
+		return nil, err
+	}
+	txn, err = w.wallet.Sign(password, txn)
+	if err != nil {
+		return nil, err
+	}
+	return serializeTx(txn)
+}
+
+func (w *wallet) SendTransaction(raw []byte) error {
+	txn, err := deserializeTx(raw)
+	if err != nil {
+		return err
+	}
+	return w.wallet.SendTransaction(txn)
+}
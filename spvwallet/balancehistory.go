@@ -0,0 +1,134 @@
+package spvwallet
+
+import (
+	"errors"
+	"sort"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+// Max points of a balance history returned at one time
+const MaxBalancePoints = 1000
+
+/*
+Get the wallet balance every granularity seconds from the unix time from to the unix time to. A point is
+the balance after the last block with the timestamp not later than the point time, so a chart drawn from
+the points changes at the block boundaries. The balance changes are replayed from the journal, the part
+of the balance not in the journal, received before the journal kept, is counted from the beginning.
+*/
+func (wallet *SPVWallet) GetBalanceHistory(granularity uint32, from, to int64) ([]*rpc.BalancePoint, error) {
+	if granularity == 0 || from > to {
+		return nil, errors.New("invalid balance history range")
+	}
+	if (to-from)/int64(granularity) >= MaxBalancePoints {
+		return nil, errors.New("too many balance history points")
+	}
+
+	changes, err := wallet.balanceChanges()
+	if err != nil {
+		return nil, err
+	}
+	tip, err := wallet.headers.GetTip()
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocks before the first balance change have the opening balance, search the heights after it
+	heights := make([]uint32, 0, len(changes))
+	for height := range changes {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	start := tip.Height
+	for _, height := range heights {
+		if height > 0 && height < start {
+			start = height
+			break
+		}
+	}
+
+	var points []*rpc.BalancePoint
+	var balance Fixed64
+	next := 0
+	for t := from; t <= to; t += int64(granularity) {
+		height, err := wallet.heightAtTime(start, tip.Height, t)
+		if err != nil {
+			return nil, err
+		}
+		for ; next < len(heights) && heights[next] <= height; next++ {
+			balance += changes[heights[next]]
+		}
+		points = append(points, &rpc.BalancePoint{Time: t, Height: height, Balance: balance})
+	}
+	return points, nil
+}
+
+// Replay the journal to the balance changes by height, the opening balance is at height 0
+func (wallet *SPVWallet) balanceChanges() (map[uint32]Fixed64, error) {
+	changes := make(map[uint32]Fixed64)
+	var seq uint64
+	for {
+		entries, err := readJournal(wallet.dataStore, seq, MaxJournalEntries)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch entry.Type {
+			case db.JournalCredit:
+				changes[entry.Height] += entry.Value
+			case db.JournalDebit:
+				changes[entry.Height] -= entry.Value
+			case db.JournalDisconnect:
+				delete(changes, entry.Height)
+			case db.JournalReset:
+				changes = make(map[uint32]Fixed64)
+			}
+			seq = entry.Seq
+		}
+		if len(entries) < MaxJournalEntries {
+			break
+		}
+	}
+
+	utxos, err := wallet.dataStore.UTXOs().GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var opening Fixed64
+	for _, utxo := range utxos {
+		opening += utxo.Value
+	}
+	for _, change := range changes {
+		opening -= change
+	}
+	if opening != 0 {
+		changes[0] += opening
+	}
+	return changes, nil
+}
+
+// Get the height of the last block from start to tip with the timestamp not later than the time,
+// 0 if the block on start is later. The timestamps of the best chain are taken as increasing
+func (wallet *SPVWallet) heightAtTime(start, tip uint32, t int64) (uint32, error) {
+	var err error
+	i := sort.Search(int(tip-start)+1, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		header, e := wallet.headers.GetByHeight(start + uint32(i))
+		if e != nil {
+			err = e
+			return true
+		}
+		return int64(header.Timestamp) > t
+	})
+	if err != nil {
+		return 0, err
+	}
+	if i == 0 {
+		return 0, nil
+	}
+	return start + uint32(i) - 1, nil
+}
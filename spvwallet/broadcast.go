@@ -0,0 +1,229 @@
+package spvwallet
+
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+const (
+	// Seconds to wait before the first rebroadcast, doubled after each rebroadcast
+	RebroadcastDelay = 60
+	// Max seconds between two rebroadcasts
+	MaxRebroadcastDelay = 3600
+	// A transaction is reported as stuck after this many rebroadcasts
+	StuckRebroadcasts = 5
+	// Seconds between two runs of the rebroadcast job
+	rebroadcastCheckInterval = 10
+)
+
+// Latency buckets of the transaction confirmations in seconds, from 1s doubled up to about 4.5h
+var confirmLatency = metrics.NewHistogram("spv_tx_confirm_latency_seconds",
+	"Seconds from a transaction broadcasted until it's confirmed", metrics.ExponentialBuckets(1, 2, 15))
+
+// broadcast is a transaction sent by the wallet and not confirmed yet
+type broadcast struct {
+	tx       tx.Transaction
+	sent     time.Time
+	last     time.Time
+	next     time.Time
+	attempts int
+	// the transaction replaced this one with a higher fee, it's not rebroadcasted anymore
+	replacedBy *common.Uint256
+}
+
+// Record the transaction broadcasted, it's rebroadcasted until confirmed or conflicted
+func (wallet *SPVWallet) trackBroadcast(txn tx.Transaction) {
+	wallet.broadcastsLock.Lock()
+	defer wallet.broadcastsLock.Unlock()
+
+	if wallet.broadcasts == nil {
+		wallet.broadcasts = make(map[common.Uint256]*broadcast)
+	}
+	txId := *txn.Hash()
+	if _, ok := wallet.broadcasts[txId]; ok {
+		return
+	}
+	now := clock.Now()
+	wallet.broadcasts[txId] = &broadcast{
+		tx:   txn,
+		sent: now,
+		last: now,
+		next: now.Add(time.Second * RebroadcastDelay),
+	}
+}
+
+// Stop tracking the broadcasted transaction when it's confirmed, or the broadcasted
+// transactions spending the same outputs of the confirmed one as conflicted
+func (wallet *SPVWallet) onTxConfirmed(txn *tx.Transaction) {
+	wallet.broadcastsLock.Lock()
+	defer wallet.broadcastsLock.Unlock()
+
+	txId := *txn.Hash()
+	if b, ok := wallet.broadcasts[txId]; ok {
+		confirmLatency.Observe(clock.Since(b.sent).Seconds())
+		delete(wallet.broadcasts, txId)
+		return
+	}
+
+	for hash, b := range wallet.broadcasts {
+		if spendsSameInput(&b.tx, txn) {
+			log.Warnf("Broadcasted transaction %s conflicted with %s", hash.String(), txId.String())
+			delete(wallet.broadcasts, hash)
+		}
+	}
+}
+
+// Rebroadcast the transactions due on the backoff schedule, it's run by the scheduler as the rebroadcast job
+func (wallet *SPVWallet) rebroadcast() error {
+	var due []tx.Transaction
+	now := clock.Now()
+	wallet.broadcastsLock.Lock()
+	for _, b := range wallet.broadcasts {
+		if b.replacedBy != nil || now.Before(b.next) {
+			continue
+		}
+		b.attempts++
+		b.last = now
+		delay := time.Second * RebroadcastDelay << uint(b.attempts)
+		if delay > time.Second*MaxRebroadcastDelay || delay <= 0 {
+			delay = time.Second * MaxRebroadcastDelay
+		}
+		b.next = now.Add(delay)
+		due = append(due, b.tx)
+	}
+	wallet.broadcastsLock.Unlock()
+
+	for _, txn := range due {
+		log.Debug("Rebroadcast transaction ", txn.Hash().String())
+		wallet.BroadCastMessage(wallet.newTxnMsg(txn))
+	}
+	return nil
+}
+
+// Get the broadcasted transactions not confirmed yet
+func (wallet *SPVWallet) GetPendingTxs() []*rpc.PendingTxInfo {
+	wallet.broadcastsLock.Lock()
+	defer wallet.broadcastsLock.Unlock()
+
+	pending := make([]*rpc.PendingTxInfo, 0, len(wallet.broadcasts))
+	for hash, b := range wallet.broadcasts {
+		info := &rpc.PendingTxInfo{
+			TxId:        common.BytesToHexString(hash.BytesReverse()),
+			Sent:        b.sent,
+			LastSent:    b.last,
+			Rebroadcast: b.attempts,
+			Stuck:       b.replacedBy == nil && b.attempts >= StuckRebroadcasts,
+		}
+		if b.replacedBy != nil {
+			info.ReplacedBy = common.BytesToHexString(b.replacedBy.BytesReverse())
+		}
+		pending = append(pending, info)
+	}
+	return pending
+}
+
+// Get the broadcasted transaction not confirmed yet
+func (wallet *SPVWallet) GetPendingTx(txId common.Uint256) (*tx.Transaction, error) {
+	wallet.broadcastsLock.Lock()
+	defer wallet.broadcastsLock.Unlock()
+
+	b, ok := wallet.broadcasts[txId]
+	if !ok {
+		return nil, errors.New("transaction not pending")
+	}
+	txn := b.tx
+	return &txn, nil
+}
+
+/*
+Replace the pending transaction with a new one spending the same inputs, usually with a higher fee.
+The replaced transaction is not rebroadcasted anymore and the new one is broadcasted, whichever
+confirmed first the other one is dropped as conflicted.
+*/
+func (wallet *SPVWallet) ReplaceTransaction(txId common.Uint256, txn tx.Transaction) error {
+	wallet.broadcastsLock.Lock()
+	b, ok := wallet.broadcasts[txId]
+	if !ok {
+		wallet.broadcastsLock.Unlock()
+		return errors.Wrap(errors.ErrNotFound, "transaction not pending")
+	}
+	if b.replacedBy != nil {
+		wallet.broadcastsLock.Unlock()
+		return errors.New("transaction already replaced")
+	}
+	if !spendsSameInput(&b.tx, &txn) {
+		wallet.broadcastsLock.Unlock()
+		return errors.Wrap(errors.ErrInvalid, "replacement does not spend the inputs of the transaction")
+	}
+	b.replacedBy = txn.Hash()
+	wallet.broadcastsLock.Unlock()
+
+	log.Infof("Transaction %s replaced by %s", txId.String(), txn.Hash().String())
+	return wallet.SendTransaction(txn)
+}
+
+// Check the fee rate of the transaction is in the range of MinFeeRate and MaxFeeRate of the config,
+// a transaction spending outputs not in the wallet can not be checked and is sent as it is
+func (wallet *SPVWallet) checkFeePolicy(txn *tx.Transaction) error {
+	cfg := wallet.Config()
+	if cfg.MinFeeRate == 0 && cfg.MaxFeeRate == 0 {
+		return nil
+	}
+
+	feeRate, err := txn.FeeRate(func(op *tx.OutPoint) (*tx.Output, error) {
+		utxo, err := wallet.dataStore.UTXOs().Get(op)
+		if err != nil {
+			return nil, err
+		}
+		return &tx.Output{Value: utxo.Value}, nil
+	})
+	if err != nil {
+		log.Debugf("Fee policy not checked for transaction %s, %s", txn.Hash().String(), err)
+		return nil
+	}
+	if cfg.MinFeeRate > 0 && int64(feeRate) < cfg.MinFeeRate {
+		return errors.Wrapf(errors.ErrInvalid, "fee rate %d below the min fee rate %d", feeRate, cfg.MinFeeRate)
+	}
+	if cfg.MaxFeeRate > 0 && int64(feeRate) > cfg.MaxFeeRate {
+		return errors.Wrapf(errors.ErrInvalid, "fee rate %d above the max fee rate %d", feeRate, cfg.MaxFeeRate)
+	}
+	return nil
+}
+
+// Check if the transaction spends any wallet output already spent by another committed transaction
+func (wallet *SPVWallet) checkDoubleSpend(txn *tx.Transaction) error {
+	txId := txn.Hash()
+	for _, input := range txn.Inputs {
+		stxo, err := wallet.dataStore.STXOs().Get(tx.NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex))
+		if errors.Is(err, errors.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !stxo.SpendTxId.IsEqual(txId) {
+			return errors.Wrapf(errors.ErrDoubleSpend, "input %s:%d already spent by transaction %s",
+				input.ReferTxID.String(), input.ReferTxOutputIndex, stxo.SpendTxId.String())
+		}
+	}
+	return nil
+}
+
+// Check if the two transactions spend any same output
+func spendsSameInput(txn, other *tx.Transaction) bool {
+	for _, input := range txn.Inputs {
+		for _, otherInput := range other.Inputs {
+			if input.ReferTxID == otherInput.ReferTxID && input.ReferTxOutputIndex == otherInput.ReferTxOutputIndex {
+				return true
+			}
+		}
+	}
+	return false
+}
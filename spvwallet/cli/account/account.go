@@ -20,17 +20,51 @@ const (
 	MinMultiSignKeys = 3
 )
 
-func listBalanceInfo(wallet Wallet) error {
+func listAccountInfo(password []byte, asJSON bool) error {
+	if !asJSON {
+		return ShowAccountInfo(password)
+	}
+
+	infos, err := GetAccountsInfo(password)
+	if err != nil {
+		return err
+	}
+	return PrintJSON(infos)
+}
+
+func listBalanceInfo(wallet Wallet, asJSON bool) error {
 	addrs, err := wallet.GetAddrs()
 	if err != nil {
 		log.Error("Get addresses error:", err)
 		return errors.New("get wallet addresses failed")
 	}
 
-	return ShowAccount(addrs, wallet)
+	if !asJSON {
+		return ShowAccount(addrs, wallet)
+	}
+
+	infos, err := GetAddressesInfo(addrs, wallet)
+	if err != nil {
+		return err
+	}
+	return PrintJSON(infos)
+}
+
+// Print the address, in JSON format like {"address": "..."} if asJSON is set
+func printAddress(programHash *Uint168, asJSON bool) error {
+	address, err := programHash.ToAddress()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return PrintJSON(map[string]string{"address": address})
+	}
+	fmt.Println(address)
+	return nil
 }
 
-func newSubAccount(password []byte, wallet Wallet) error {
+func newSubAccount(password []byte, wallet Wallet, asJSON bool) error {
 	var err error
 	password, err = GetPassword(password, false)
 	if err != nil {
@@ -42,12 +76,90 @@ func newSubAccount(password []byte, wallet Wallet) error {
 		return err
 	}
 
-	address, err := account.ToAddress()
+	return printAddress(account, asJSON)
+}
+
+func setLabel(wallet Wallet, address, label string) error {
+	if address == "" {
+		return errors.New("use --address to specify the address to label")
+	}
+
+	programHash, err := Uint168FromAddress(address)
+	if err != nil {
+		return errors.New("invalid address " + address)
+	}
+
+	err = wallet.SetAddressLabel(programHash, label)
+	if err != nil {
+		return errors.New("address " + address + " not found in wallet")
+	}
+	return nil
+}
+
+func importPrivateKey(password []byte, wallet Wallet, keyHex string, asJSON bool) error {
+	privateKey, err := HexStringToBytes(strings.TrimSpace(keyHex))
+	if err != nil {
+		return errors.New("invalid private key hex string")
+	}
+	defer ClearBytes(privateKey)
+
+	password, err = GetPassword(password, false)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(address)
+	programHash, err := wallet.ImportPrivateKey(password, privateKey)
+	if err != nil {
+		return err
+	}
+
+	return printAddress(programHash, asJSON)
+}
+
+func exportPrivateKey(password []byte, wallet Wallet, address string, asJSON bool) error {
+	programHash, err := Uint168FromAddress(address)
+	if err != nil {
+		return errors.New("invalid address " + address)
+	}
+
+	password, err = GetPassword(password, false)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := wallet.ExportPrivateKey(password, programHash)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return PrintJSON(map[string]string{"address": address, "privatekey": BytesToHexString(privateKey)})
+	}
+	fmt.Println(BytesToHexString(privateKey))
+	return nil
+}
+
+func showMasterPublicKey(password []byte, wallet Wallet, asJSON bool) error {
+	var err error
+	password, err = GetPassword(password, false)
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := wallet.MasterPublicKey(password)
+	if err != nil {
+		return err
+	}
+
+	publicKeyBytes, err := publicKey.EncodePoint(true)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		return PrintJSON(map[string]string{"publickey": BytesToHexString(publicKeyBytes)})
+	}
+	fmt.Println(BytesToHexString(publicKeyBytes))
 	return nil
 }
 
@@ -76,12 +188,7 @@ func addMultiSignAccount(context *cli.Context, wallet Wallet, content string) er
 		return err
 	}
 
-	address, err := programHash.ToAddress()
-	if err != nil {
-		return err
-	}
-	fmt.Println(address)
-	return nil
+	return printAddress(programHash, context.Bool("json"))
 }
 
 func getPublicKeys(content string) ([]*crypto.PublicKey, error) {
@@ -140,6 +247,7 @@ func accountAction(context *cli.Context) {
 		os.Exit(0)
 	}
 	pass := context.String("password")
+	asJSON := context.Bool("json")
 
 	wallet, err := Open()
 	if err != nil {
@@ -149,7 +257,7 @@ func accountAction(context *cli.Context) {
 
 	// list accounts
 	if context.Bool("list") {
-		if err := ShowAccountInfo([]byte(pass)); err != nil {
+		if err := listAccountInfo([]byte(pass), asJSON); err != nil {
 			fmt.Println("error: list accounts info failed, ", err)
 			cli.ShowCommandHelpAndExit(context, "list", 3)
 		}
@@ -158,7 +266,7 @@ func accountAction(context *cli.Context) {
 
 	// new sub account
 	if context.Bool("new") {
-		if err := newSubAccount([]byte(pass), wallet); err != nil {
+		if err := newSubAccount([]byte(pass), wallet, asJSON); err != nil {
 			fmt.Println("error: new sub account failed, ", err)
 			cli.ShowCommandHelpAndExit(context, "new", 5)
 		}
@@ -176,12 +284,48 @@ func accountAction(context *cli.Context) {
 
 	// show addresses balance in this wallet
 	if context.Bool("balance") {
-		if err := listBalanceInfo(wallet); err != nil {
+		if err := listBalanceInfo(wallet, asJSON); err != nil {
 			fmt.Println("error: list balance info failed,", err)
 			cli.ShowCommandHelpAndExit(context, "balance", 6)
 		}
 		return
 	}
+
+	// set label of an address
+	if context.IsSet("label") {
+		if err := setLabel(wallet, context.String("address"), context.String("label")); err != nil {
+			fmt.Println("error: set address label failed,", err)
+			cli.ShowCommandHelpAndExit(context, "label", 7)
+		}
+		return
+	}
+
+	// import a private key
+	if keyHex := context.String("import"); keyHex != "" {
+		if err := importPrivateKey([]byte(pass), wallet, keyHex, asJSON); err != nil {
+			fmt.Println("error: import private key failed,", err)
+			cli.ShowCommandHelpAndExit(context, "import", 8)
+		}
+		return
+	}
+
+	// export the private key of an address
+	if address := context.String("export"); address != "" {
+		if err := exportPrivateKey([]byte(pass), wallet, address, asJSON); err != nil {
+			fmt.Println("error: export private key failed,", err)
+			cli.ShowCommandHelpAndExit(context, "export", 9)
+		}
+		return
+	}
+
+	// show the master public key
+	if context.Bool("xpub") {
+		if err := showMasterPublicKey([]byte(pass), wallet, asJSON); err != nil {
+			fmt.Println("error: show master public key failed,", err)
+			cli.ShowCommandHelpAndExit(context, "xpub", 10)
+		}
+		return
+	}
 }
 
 func NewCommand() cli.Command {
@@ -213,7 +357,32 @@ func NewCommand() cli.Command {
 			},
 			cli.BoolFlag{
 				Name:  "balance, b",
-				Usage: "show accounts balances",
+				Usage: "show accounts balances and labels",
+			},
+			cli.StringFlag{
+				Name:  "label",
+				Usage: "set the label of the address specified by --address, use empty string to remove the label",
+			},
+			cli.StringFlag{
+				Name:  "address",
+				Usage: "the address to set label",
+			},
+			cli.StringFlag{
+				Name:  "import",
+				Usage: "import a private key in hex string into the wallet",
+			},
+			cli.StringFlag{
+				Name:  "export",
+				Usage: "export the private key in hex string of the given address",
+			},
+			cli.BoolFlag{
+				Name: "xpub",
+				Usage: "show the master public key of the wallet\n" +
+					"\tsub accounts are derived with private key, so addresses can not be derived from this public key",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the result in JSON format",
 			},
 		),
 		Action: accountAction,
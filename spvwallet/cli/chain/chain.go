@@ -0,0 +1,171 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/cli"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+
+	"github.com/urfave/cli"
+)
+
+var jsonFlag = cli.BoolFlag{
+	Name:  "json",
+	Usage: "print the result in JSON format",
+}
+
+func getInfo(context *cli.Context) error {
+	info, err := rpc.GetClient().GetInfo()
+	if err != nil {
+		return err
+	}
+
+	if context.Bool("json") {
+		return PrintJSON(info)
+	}
+
+	fmt.Printf("%-18s %d\n", "MAGIC:", info.Magic)
+	fmt.Printf("%-18s %d\n", "CHAIN HEIGHT:", info.ChainHeight)
+	fmt.Printf("%-18s %d\n", "HEADER HEIGHT:", info.HeaderHeight)
+	fmt.Printf("%-18s %s\n", "BEST HASH:", info.BestHash)
+	fmt.Printf("%-18s %d\n", "BEST PEER HEIGHT:", info.BestPeerHeight)
+	fmt.Printf("%-18s %d (%d established)\n", "PEERS:", info.PeersConnected, info.PeersEstablished)
+	fmt.Printf("%-18s %d\n", "ADDRESSES:", info.Addresses)
+	fmt.Printf("%-18s %t\n", "HEALTHY:", info.Healthy)
+	if info.Ready {
+		fmt.Printf("%-18s %t\n", "READY:", info.Ready)
+	} else {
+		fmt.Printf("%-18s %t (%s)\n", "READY:", info.Ready, info.Reason)
+	}
+	return nil
+}
+
+func getPeers(context *cli.Context) error {
+	peers, err := rpc.GetClient().GetPeers()
+	if err != nil {
+		return err
+	}
+
+	if context.Bool("json") {
+		return PrintJSON(peers)
+	}
+
+	// print header
+	fmt.Printf("%-20s %-24s %10s %-10s %12s %12s %10s %s\n",
+		"ID", "ADDRESS", "HEIGHT", "STATE", "RECEIVED", "SENT", "CONNECTED", "SYNC")
+	fmt.Println(strings.Repeat("-", 20), strings.Repeat("-", 24), strings.Repeat("-", 10), strings.Repeat("-", 10),
+		strings.Repeat("-", 12), strings.Repeat("-", 12), strings.Repeat("-", 10), "----")
+
+	for _, peer := range peers {
+		sync := ""
+		if peer.SyncPeer {
+			sync = "*"
+		}
+		connected := time.Since(peer.ConnTime).Truncate(time.Second)
+		fmt.Printf("%-20d %-24s %10d %-10s %12d %12d %10s %s\n",
+			peer.ID, peer.Addr, peer.Height, peer.State, peer.BytesReceived, peer.BytesSent, connected, sync)
+	}
+	return nil
+}
+
+func getPending(context *cli.Context) error {
+	pending, err := rpc.GetClient().GetPendingTxs()
+	if err != nil {
+		return err
+	}
+
+	if context.Bool("json") {
+		return PrintJSON(pending)
+	}
+
+	// print header
+	fmt.Printf("%-64s %10s %12s %s\n", "TXID", "SENT", "REBROADCAST", "STUCK")
+	fmt.Println(strings.Repeat("-", 64), strings.Repeat("-", 10), strings.Repeat("-", 12), "-----")
+
+	for _, tx := range pending {
+		stuck := ""
+		if tx.Stuck {
+			stuck = "*"
+		}
+		sent := time.Since(tx.Sent).Truncate(time.Second)
+		fmt.Printf("%-64s %10s %12d %s\n", tx.TxId, sent, tx.Rebroadcast, stuck)
+	}
+	return nil
+}
+
+func getHeader(context *cli.Context) error {
+	if context.NArg() == 0 {
+		return errors.New("use block hash, height or date to specify the header")
+	}
+
+	header, err := rpc.GetClient().GetHeader(context.Args().First())
+	if err != nil {
+		return err
+	}
+
+	if context.Bool("json") {
+		return PrintJSON(header)
+	}
+
+	fmt.Printf("%-12s %s\n", "HASH:", header.Hash)
+	fmt.Printf("%-12s %d\n", "HEIGHT:", header.Height)
+	fmt.Printf("%-12s %d\n", "VERSION:", header.Version)
+	fmt.Printf("%-12s %s\n", "PREVIOUS:", header.Previous)
+	fmt.Printf("%-12s %s\n", "MERKLEROOT:", header.MerkleRoot)
+	fmt.Printf("%-12s %d (%s)\n", "TIMESTAMP:", header.Timestamp, time.Unix(int64(header.Timestamp), 0).UTC())
+	fmt.Printf("%-12s %d\n", "BITS:", header.Bits)
+	fmt.Printf("%-12s %d\n", "NONCE:", header.Nonce)
+	fmt.Printf("%-12s %s\n", "TOTALWORK:", header.TotalWork)
+	return nil
+}
+
+// Run the action and exit with error if it failed, the wallet service must be running
+func run(action func(*cli.Context) error) func(*cli.Context) {
+	return func(context *cli.Context) {
+		if err := action(context); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func NewGetInfoCommand() cli.Command {
+	return cli.Command{
+		Name:   "getinfo",
+		Usage:  "show the chain height, peers and sync status of the running wallet service",
+		Flags:  []cli.Flag{jsonFlag},
+		Action: run(getInfo),
+	}
+}
+
+func NewGetPeersCommand() cli.Command {
+	return cli.Command{
+		Name:   "getpeers",
+		Usage:  "show the connected peers and their traffic statistics of the running wallet service",
+		Flags:  []cli.Flag{jsonFlag},
+		Action: run(getPeers),
+	}
+}
+
+func NewGetPendingCommand() cli.Command {
+	return cli.Command{
+		Name:   "getpending",
+		Usage:  "show the transactions sent and not confirmed yet, and their rebroadcast status",
+		Flags:  []cli.Flag{jsonFlag},
+		Action: run(getPending),
+	}
+}
+
+func NewGetHeaderCommand() cli.Command {
+	return cli.Command{
+		Name:      "getheader",
+		Usage:     "show the block header by hash, height or date like 2018-06-01 from the running wallet service",
+		ArgsUsage: "<hash|height|date>",
+		Flags:     []cli.Flag{jsonFlag},
+		Action:    run(getHeader),
+	}
+}
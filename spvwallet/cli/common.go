@@ -7,9 +7,11 @@ import (
 	"errors"
 	"strings"
 	"strconv"
+	"encoding/json"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	walt "github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 
 	"github.com/AlexpanXX/gopass"
@@ -54,14 +56,94 @@ func GetPassword(password []byte, confirmed bool) ([]byte, error) {
 	return password, nil
 }
 
-func ShowAccountInfo(password []byte) error {
+// Print the value in indented JSON format for machine reading
+func PrintJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+type AccountInfo struct {
+	Index     int    `json:"index"`
+	Address   string `json:"address"`
+	PublicKey string `json:"publickey"`
+	Type      string `json:"type"`
+}
+
+type AddressInfo struct {
+	Index   int    `json:"index"`
+	Address string `json:"address"`
+	Balance string `json:"balance"`
+	Locked  string `json:"locked"`
+	Type    string `json:"type"`
+	Label   string `json:"label"`
+}
+
+// Get the accounts in keystore
+func GetAccountsInfo(password []byte) ([]*AccountInfo, error) {
 	var err error
 	password, err = GetPassword(password, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	keyStore, err := walt.OpenKeystore(password)
+	keyStore, err := walt.OpenKeystore(config.Values().DataDir, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*AccountInfo
+	for i, account := range keyStore.GetAccounts() {
+		accountType := "SUB"
+		if i == 0 {
+			accountType = "MASTER"
+		}
+		publicKeyBytes, _ := account.PublicKey().EncodePoint(true)
+		infos = append(infos, &AccountInfo{
+			Index:     i + 1,
+			Address:   account.Address(),
+			PublicKey: BytesToHexString(publicKeyBytes),
+			Type:      accountType,
+		})
+	}
+	return infos, nil
+}
+
+// Get the addresses in wallet with balances and labels
+func GetAddressesInfo(addrs []*db.Addr, wallet walt.Wallet) ([]*AddressInfo, error) {
+	var infos []*AddressInfo
+	currentHeight := wallet.ChainHeight()
+	for i, addr := range addrs {
+		available := Fixed64(0)
+		locked := Fixed64(0)
+		UTXOs, err := wallet.GetAddressUTXOs(addr.Hash())
+		if err != nil {
+			return nil, errors.New("get " + addr.String() + " UTXOs failed")
+		}
+		for _, utxo := range UTXOs {
+			if utxo.LockTime <= currentHeight {
+				available += utxo.Value
+			} else {
+				locked += utxo.Value
+			}
+		}
+		infos = append(infos, &AddressInfo{
+			Index:   i + 1,
+			Address: addr.String(),
+			Balance: available.String(),
+			Locked:  locked.String(),
+			Type:    addr.TypeName(),
+			Label:   addr.Label(),
+		})
+	}
+	return infos, nil
+}
+
+func ShowAccountInfo(password []byte) error {
+	infos, err := GetAccountsInfo(password)
 	if err != nil {
 		return err
 	}
@@ -71,15 +153,8 @@ func ShowAccountInfo(password []byte) error {
 	fmt.Println("-----", strings.Repeat("-", 34), strings.Repeat("-", 66), "------")
 
 	// print accounts
-	for i, account := range keyStore.GetAccounts() {
-		accountType := "SUB"
-		if i == 0 {
-			accountType = "MASTER"
-		}
-		// print content
-		publicKey := account.PublicKey()
-		publicKeyBytes, _ := publicKey.EncodePoint(true)
-		fmt.Printf("%5d %-34s %-66s %6s\n", i+1, account.Address(), BytesToHexString(publicKeyBytes), accountType)
+	for _, info := range infos {
+		fmt.Printf("%5d %-34s %-66s %6s\n", info.Index, info.Address, info.PublicKey, info.Type)
 		// print divider line
 		fmt.Println("-----", strings.Repeat("-", 34), strings.Repeat("-", 66), "------")
 	}
@@ -116,28 +191,18 @@ func SelectAccount(wallet walt.Wallet) (string, error) {
 }
 
 func ShowAccount(addrs []*db.Addr, wallet walt.Wallet) error {
-	// print header
-	fmt.Printf("%5s %34s %-20s%22s %6s\n", "INDEX", "ADDRESS", "BALANCE", "(LOCKED)", "TYPE")
-	fmt.Println("-----", strings.Repeat("-", 34), strings.Repeat("-", 42), "------")
+	infos, err := GetAddressesInfo(addrs, wallet)
+	if err != nil {
+		return err
+	}
 
-	currentHeight := wallet.ChainHeight()
-	for i, addr := range addrs {
-		available := Fixed64(0)
-		locked := Fixed64(0)
-		UTXOs, err := wallet.GetAddressUTXOs(addr.Hash())
-		if err != nil {
-			return errors.New("get " + addr.String() + " UTXOs failed")
-		}
-		for _, utxo := range UTXOs {
-			if utxo.LockTime <= currentHeight {
-				available += utxo.Value
-			} else {
-				locked += utxo.Value
-			}
-		}
+	// print header
+	fmt.Printf("%5s %34s %-20s%22s %8s %s\n", "INDEX", "ADDRESS", "BALANCE", "(LOCKED)", "TYPE", "LABEL")
+	fmt.Println("-----", strings.Repeat("-", 34), strings.Repeat("-", 42), "--------", "-----")
 
-		fmt.Printf("%5d %34s %-20s%22s %6s\n", i+1, addr.String(), available.String(), "("+locked.String()+")", addr.TypeName())
-		fmt.Println("-----", strings.Repeat("-", 34), strings.Repeat("-", 42), "------")
+	for _, info := range infos {
+		fmt.Printf("%5d %34s %-20s%22s %8s %s\n", info.Index, info.Address, info.Balance, "("+info.Locked+")", info.Type, info.Label)
+		fmt.Println("-----", strings.Repeat("-", 34), strings.Repeat("-", 42), "--------", "-----")
 	}
 
 	return nil
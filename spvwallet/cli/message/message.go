@@ -0,0 +1,110 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	walt "github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/cli"
+
+	"github.com/urfave/cli"
+)
+
+var (
+	addressFlag = cli.StringFlag{
+		Name:  "address",
+		Usage: "the address to sign or verify the message with",
+	}
+	messageFlag = cli.StringFlag{
+		Name:  "message, m",
+		Usage: "the message to sign or verify",
+	}
+)
+
+func signMessage(context *cli.Context) error {
+	address := context.String("address")
+	if address == "" {
+		return errors.New("use --address to specify the address to sign with")
+	}
+	if !context.IsSet("message") {
+		return errors.New("use --message to specify the message to sign")
+	}
+
+	password, err := GetPassword([]byte(context.String("password")), false)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := walt.Open()
+	if err != nil {
+		return err
+	}
+
+	signature, err := wallet.SignMessage(password, address, context.String("message"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(BytesToHexString(signature))
+	return nil
+}
+
+func verifyMessage(context *cli.Context) error {
+	address := context.String("address")
+	if address == "" {
+		return errors.New("use --address to specify the address signed the message")
+	}
+	if !context.IsSet("message") {
+		return errors.New("use --message to specify the signed message")
+	}
+
+	signature, err := HexStringToBytes(context.String("signature"))
+	if err != nil || len(signature) == 0 {
+		return errors.New("use --signature to specify the signature in hex string")
+	}
+
+	err = sdk.VerifyMessage(address, signature, context.String("message"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("signature verified, the message is signed by", address)
+	return nil
+}
+
+func run(action func(*cli.Context) error) func(*cli.Context) {
+	return func(context *cli.Context) {
+		if err := action(context); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func NewSignCommand() cli.Command {
+	return cli.Command{
+		Name:        "signmessage",
+		Usage:       "sign a message with the key of an address to prove the ownership of the address",
+		Description: "use --address and --message to sign a message, the signature is printed in hex string",
+		Flags:       append(CommonFlags, addressFlag, messageFlag),
+		Action:      run(signMessage),
+	}
+}
+
+func NewVerifyCommand() cli.Command {
+	return cli.Command{
+		Name:        "verifymessage",
+		Usage:       "verify a message is signed by the owner of an address",
+		Description: "use --address, --message and --signature to verify a signed message",
+		Flags: []cli.Flag{addressFlag, messageFlag,
+			cli.StringFlag{
+				Name:  "signature, s",
+				Usage: "the signature in hex string",
+			},
+		},
+		Action: run(verifyMessage),
+	}
+}
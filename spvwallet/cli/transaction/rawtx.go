@@ -0,0 +1,104 @@
+package transaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/cli"
+
+	"github.com/urfave/cli"
+)
+
+func decodeRawTransaction(context *cli.Context) error {
+	content, err := getContent(context)
+	if err != nil {
+		return err
+	}
+
+	info, err := sdk.DecodeRawTransaction(*content)
+	if err != nil {
+		return err
+	}
+
+	return PrintJSON(info)
+}
+
+func encodeTransaction(context *cli.Context) error {
+	content := strings.TrimSpace(context.String("json"))
+	if path := strings.TrimSpace(context.String("file")); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.New("read transaction file failed")
+		}
+		content = string(data)
+	}
+	if content == "" {
+		return errors.New("use --json or --file to specify the transaction in JSON format")
+	}
+
+	info := new(sdk.TransactionInfo)
+	err := json.Unmarshal([]byte(content), info)
+	if err != nil {
+		return errors.New("invalid transaction JSON, " + err.Error())
+	}
+
+	rawHex, err := sdk.EncodeTransaction(info)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(rawHex)
+	return nil
+}
+
+func run(action func(*cli.Context) error) func(*cli.Context) {
+	return func(context *cli.Context) {
+		if err := action(context); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func NewDecodeCommand() cli.Command {
+	return cli.Command{
+		Name:        "decoderawtx",
+		Usage:       "decode a raw transaction into JSON format",
+		Description: "use --hex or --file to specify the raw transaction in hex string",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "hex",
+				Usage: "the raw transaction in hex string",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "the file path of the raw transaction in hex string",
+			},
+		},
+		Action: run(decodeRawTransaction),
+	}
+}
+
+func NewEncodeCommand() cli.Command {
+	return cli.Command{
+		Name:        "encoderawtx",
+		Usage:       "encode a transaction in JSON format, as printed by decoderawtx, into raw transaction hex string",
+		Description: "use --json or --file to specify the transaction in JSON format",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "json",
+				Usage: "the transaction in JSON format",
+			},
+			cli.StringFlag{
+				Name:  "file, f",
+				Usage: "the file path of the transaction in JSON format",
+			},
+		},
+		Action: run(encodeTransaction),
+	}
+}
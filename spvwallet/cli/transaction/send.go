@@ -0,0 +1,291 @@
+package transaction
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	walt "github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/cli"
+
+	"github.com/urfave/cli"
+)
+
+func SendAction(password []byte, context *cli.Context, wallet walt.Wallet) error {
+	from := context.String("from-address")
+	var err error
+	if from == "" {
+		from, err = SelectAccount(wallet)
+		if err != nil {
+			return err
+		}
+	}
+
+	options, err := getTxOptions(context)
+	if err != nil {
+		return err
+	}
+
+	outputs, err := getOutputs(context)
+	if err != nil {
+		return err
+	}
+
+	txn, err := wallet.CreateTransactionWithOptions(from, options, outputs...)
+	if err != nil {
+		return errors.New("create transaction failed: " + err.Error())
+	}
+
+	err = preview(wallet, from, txn, len(outputs))
+	if err != nil {
+		return err
+	}
+
+	txn, err = signTransaction(password, wallet, txn)
+	if err != nil {
+		return err
+	}
+
+	// Print the raw transaction without sending it
+	if context.Bool("dry-run") {
+		buf := new(bytes.Buffer)
+		txn.Serialize(buf)
+		fmt.Println(BytesToHexString(buf.Bytes()))
+		return nil
+	}
+
+	if !context.Bool("yes") && !confirm("Send this transaction?") {
+		return errors.New("transaction canceled")
+	}
+
+	// Keep the transaction in the SPV service until the chain passes the lock time
+	if options.LockTime > 0 {
+		err = wallet.ScheduleTransaction(txn)
+		if err != nil {
+			return err
+		}
+		fmt.Println(BytesToHexString(BytesReverse(txn.Hash().Bytes())), "scheduled at lock time", options.LockTime)
+		return nil
+	}
+
+	err = wallet.SendTransaction(txn)
+	if err != nil {
+		return err
+	}
+
+	// Return reversed hex string
+	fmt.Println(BytesToHexString(BytesReverse(txn.Hash().Bytes())))
+	return nil
+}
+
+func getTxOptions(context *cli.Context) (*walt.TxOptions, error) {
+	options := new(walt.TxOptions)
+
+	feeStr, feeRateStr := context.String("fee"), context.String("fee-rate")
+	if feeStr != "" && feeRateStr != "" {
+		return nil, errors.New("use --fee or --fee-rate, not both")
+	}
+	if feeStr == "" && feeRateStr == "" {
+		return nil, errors.New("use --fee to specify transfer fee or --fee-rate to specify fee per KB")
+	}
+	if feeStr != "" {
+		fee, err := StringToFixed64(feeStr)
+		if err != nil {
+			return nil, errors.New("invalid transaction fee")
+		}
+		options.Fee = fee
+	} else {
+		feeRate, err := StringToFixed64(feeRateStr)
+		if err != nil {
+			return nil, errors.New("invalid transaction fee rate")
+		}
+		options.FeeRate = feeRate
+	}
+
+	if lockStr := context.String("lock"); lockStr != "" {
+		lock, err := strconv.ParseUint(lockStr, 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid lock height")
+		}
+		options.LockedUntil = uint32(lock)
+	}
+
+	if lockTimeStr := context.String("locktime"); lockTimeStr != "" {
+		lockTime, err := strconv.ParseUint(lockTimeStr, 10, 32)
+		if err != nil || lockTime == 0 {
+			return nil, errors.New("invalid lock time")
+		}
+		options.LockTime = uint32(lockTime)
+	}
+
+	for _, utxo := range context.StringSlice("utxo") {
+		op, err := walt.ParseOutPoint(utxo)
+		if err != nil {
+			return nil, err
+		}
+		options.UTXOs = append(options.UTXOs, op)
+	}
+
+	return options, nil
+}
+
+func getOutputs(context *cli.Context) ([]*walt.Output, error) {
+	if path := context.String("file"); path != "" {
+		return readMultiOutput(path)
+	}
+
+	to := context.String("to")
+	if to == "" {
+		return nil, errors.New("use --to to specify receiver address")
+	}
+
+	amountStr := context.String("amount")
+	if amountStr == "" {
+		return nil, errors.New("use --amount to specify transfer amount")
+	}
+
+	amount, err := StringToFixed64(amountStr)
+	if err != nil {
+		return nil, errors.New("invalid transaction amount")
+	}
+
+	return []*walt.Output{{Address: to, Value: amount}}, nil
+}
+
+// Print the inputs, outputs, change and fee of the transaction
+func preview(wallet walt.Wallet, from string, txn *tx.Transaction, outputCount int) error {
+	spender, err := Uint168FromAddress(from)
+	if err != nil {
+		return err
+	}
+	utxos, err := wallet.GetAddressUTXOs(spender)
+	if err != nil {
+		return errors.New("get " + from + " UTXOs failed")
+	}
+
+	fmt.Println("INPUTS:")
+	var totalInput Fixed64
+	for _, input := range txn.Inputs {
+		var value Fixed64
+		for _, utxo := range utxos {
+			if utxo.Op.TxID.IsEqual(&input.ReferTxID) && utxo.Op.Index == input.ReferTxOutputIndex {
+				value = utxo.Value
+				break
+			}
+		}
+		totalInput += value
+		fmt.Printf("  %s:%d %s\n", BytesToHexString(input.ReferTxID.BytesReverse()), input.ReferTxOutputIndex, value.String())
+	}
+
+	fmt.Println("OUTPUTS:")
+	var totalOutput Fixed64
+	for i, output := range txn.Outputs {
+		totalOutput += output.Value
+		address, _ := output.ProgramHash.ToAddress()
+		// Change output is appended after the outputs to send
+		if i >= outputCount {
+			fmt.Printf("  %s %s (change)\n", address, output.Value.String())
+			continue
+		}
+		fmt.Printf("  %s %s\n", address, output.Value.String())
+	}
+
+	fee := totalInput - totalOutput
+	fmt.Println("FEE:", fee.String(), "SIZE:", walt.EstimateSignedSize(txn))
+	return nil
+}
+
+func confirm(question string) bool {
+	fmt.Print(question, " [y/N]: ")
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	input = strings.ToLower(strings.TrimSpace(input))
+	return input == "y" || input == "yes"
+}
+
+func sendAction(context *cli.Context) {
+	if context.NumFlags() == 0 {
+		cli.ShowSubcommandHelp(context)
+		os.Exit(0)
+	}
+	pass := context.String("password")
+
+	wallet, err := walt.Open()
+	if err != nil {
+		fmt.Println("error: open wallet failed, ", err)
+		os.Exit(2)
+	}
+
+	if err := SendAction([]byte(pass), context, wallet); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+}
+
+func NewSendCommand() cli.Command {
+	return cli.Command{
+		Name:        "send",
+		Usage:       "build, sign, preview and send a transaction",
+		Description: "use [--from-address] --to --amount or [--from-address] --file, with --fee or --fee-rate to send a transaction",
+		ArgsUsage:   "[args]",
+		Flags: append(CommonFlags,
+			cli.StringFlag{
+				Name:  "from-address",
+				Usage: "the spend address of the transaction, select from the wallet addresses if not set",
+			},
+			cli.StringFlag{
+				Name:  "to",
+				Usage: "the receive address of the transaction",
+			},
+			cli.StringFlag{
+				Name:  "amount",
+				Usage: "the transfer amount of the transaction",
+			},
+			cli.StringFlag{
+				Name:  "file",
+				Usage: "the file path to specify a CSV format file path with [address,amount] as multi output content",
+			},
+			cli.StringFlag{
+				Name:  "fee",
+				Usage: "the transfer fee of the transaction",
+			},
+			cli.StringFlag{
+				Name:  "fee-rate",
+				Usage: "the fee per KB of the transaction size, the fee is calculated by the signed transaction size",
+			},
+			cli.StringSliceFlag{
+				Name:  "utxo",
+				Usage: "the UTXO in format txid:index to spend, can be set multiple times for coin control",
+			},
+			cli.StringFlag{
+				Name:  "lock",
+				Usage: "the lock time to specify when the received asset can be spent",
+			},
+			cli.StringFlag{
+				Name: "locktime",
+				Usage: "the block height, or the unix time if not less than 500000000, to broadcast the transaction at,\n" +
+					"\tit's kept by the SPV service until then and can be canceled by transaction --cancel",
+			},
+			cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "print the signed transaction in hex string without sending it",
+			},
+			cli.BoolFlag{
+				Name:  "yes, y",
+				Usage: "send the transaction without confirmation",
+			},
+		),
+		Action: sendAction,
+		OnUsageError: func(c *cli.Context, err error, subCommand bool) error {
+			return cli.NewExitError(err, 1)
+		},
+	}
+}
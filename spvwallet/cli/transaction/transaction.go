@@ -9,12 +9,14 @@ import (
 	"strings"
 	"strconv"
 	"io/ioutil"
+	"time"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/cli"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 	walt "github.com/elastos/Elastos.ELA.SPV/spvwallet"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 
 	"github.com/urfave/cli"
 )
@@ -48,6 +50,18 @@ func createTransaction(c *cli.Context, wallet walt.Wallet) (*tx.Transaction, err
 
 	var txn *tx.Transaction
 
+	if recordType := c.String("recordtype"); recordType != "" {
+		recordData, err := HexStringToBytes(c.String("recorddata"))
+		if err != nil {
+			return nil, errors.New("invalid record data, expect hex string")
+		}
+		txn, err = wallet.CreateRecordTransaction(from, fee, recordType, recordData)
+		if err != nil {
+			return nil, errors.New("create transaction failed: " + err.Error())
+		}
+		return txn, nil
+	}
+
 	multiOutput := c.String("file")
 	if multiOutput != "" {
 		txn, err = createMultiOutputTransaction(c, wallet, multiOutput, from, fee)
@@ -93,6 +107,34 @@ func createTransaction(c *cli.Context, wallet walt.Wallet) (*tx.Transaction, err
 }
 
 func createMultiOutputTransaction(c *cli.Context, wallet walt.Wallet, path, from string, fee *Fixed64) (*tx.Transaction, error) {
+	multiOutput, err := readMultiOutput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lockStr := c.String("lock")
+	var txn *tx.Transaction
+	if lockStr == "" {
+		txn, err = wallet.CreateMultiOutputTransaction(from, fee, multiOutput...)
+		if err != nil {
+			return nil, errors.New("create multi output transaction failed: " + err.Error())
+		}
+	} else {
+		lock, err := strconv.ParseUint(lockStr, 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid lock height")
+		}
+		txn, err = wallet.CreateLockedMultiOutputTransaction(from, fee, uint32(lock), multiOutput...)
+		if err != nil {
+			return nil, errors.New("create multi output transaction failed: " + err.Error())
+		}
+	}
+
+	return txn, nil
+}
+
+// Read multi output from a CSV format file with [address,amount] lines
+func readMultiOutput(path string) ([]*walt.Output, error) {
 	if _, err := os.Stat(path); err != nil {
 		return nil, errors.New("invalid multi output file path")
 	}
@@ -100,6 +142,7 @@ func createMultiOutputTransaction(c *cli.Context, wallet walt.Wallet, path, from
 	if err != nil {
 		return nil, errors.New("open multi output file failed")
 	}
+	defer file.Close()
 
 	scanner := bufio.NewScanner(file)
 	var multiOutput []*walt.Output
@@ -118,25 +161,7 @@ func createMultiOutputTransaction(c *cli.Context, wallet walt.Wallet, path, from
 		log.Trace("Multi output address:", address, ", amount:", amountStr)
 	}
 
-	lockStr := c.String("lock")
-	var txn *tx.Transaction
-	if lockStr == "" {
-		txn, err = wallet.CreateMultiOutputTransaction(from, fee, multiOutput...)
-		if err != nil {
-			return nil, errors.New("create multi output transaction failed: " + err.Error())
-		}
-	} else {
-		lock, err := strconv.ParseUint(lockStr, 10, 32)
-		if err != nil {
-			return nil, errors.New("invalid lock height")
-		}
-		txn, err = wallet.CreateLockedMultiOutputTransaction(from, fee, uint32(lock), multiOutput...)
-		if err != nil {
-			return nil, errors.New("create multi output transaction failed: " + err.Error())
-		}
-	}
-
-	return txn, nil
+	return multiOutput, nil
 }
 
 func SignTransaction(password []byte, context *cli.Context, wallet walt.Wallet) error {
@@ -199,6 +224,111 @@ func SendTransaction(password []byte, context *cli.Context, wallet walt.Wallet)
 	return nil
 }
 
+func BumpFee(password []byte, context *cli.Context, wallet walt.Wallet) error {
+	txId := context.String("bumpfee")
+	feeRateStr := context.String("feerate")
+	if feeRateStr == "" {
+		return errors.New("use --feerate to specify the new fee per KB")
+	}
+	feeRate, err := StringToFixed64(feeRateStr)
+	if err != nil {
+		return errors.New("invalid fee rate")
+	}
+
+	password, err = GetPassword(password, false)
+	if err != nil {
+		return err
+	}
+
+	txn, err := wallet.BumpFee(password, txId, feeRate)
+	if err != nil {
+		return err
+	}
+
+	// Return reversed hex string
+	fmt.Println(BytesToHexString(BytesReverse(txn.Hash().Bytes())))
+	return nil
+}
+
+func LabelTransaction(context *cli.Context, wallet walt.Wallet) error {
+	txId, err := txIdFromString(context.String("label"))
+	if err != nil {
+		return err
+	}
+	label := &db.TxLabel{Label: context.String("text"), Category: context.String("category")}
+	if tags := context.String("tags"); tags != "" {
+		label.Tags = strings.Split(tags, ",")
+	}
+	if err := wallet.SetTxLabel(txId, label); err != nil {
+		return err
+	}
+	fmt.Println("Transaction label set")
+	return nil
+}
+
+func SearchTransactions(context *cli.Context, wallet walt.Wallet) error {
+	results, err := wallet.SearchTxs(context.String("search"))
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		fmt.Println(result.TxId, "height:", result.Height)
+		if result.Label != nil {
+			fmt.Printf("\tlabel: %s, category: %s, tags: %s\n", result.Label.Label, result.Label.Category,
+				strings.Join(result.Label.Tags, ","))
+		}
+		if result.Memo != "" {
+			fmt.Println("\tmemo:", result.Memo)
+		}
+		fmt.Println("\taddresses:", strings.Join(result.Addresses, ", "))
+	}
+	fmt.Println(len(results), "transactions found")
+	return nil
+}
+
+// List the transactions scheduled in the SPV service and not broadcasted yet
+func ListScheduledTransactions(wallet walt.Wallet) error {
+	scheduled, err := wallet.GetScheduledTxs()
+	if err != nil {
+		return err
+	}
+	for _, stx := range scheduled {
+		if stx.LockTime < db.LockTimeThreshold {
+			fmt.Println(stx.TxId, "at height:", stx.LockTime)
+		} else {
+			fmt.Println(stx.TxId, "at time:", time.Unix(int64(stx.LockTime), 0).Format(time.RFC3339))
+		}
+	}
+	fmt.Println(len(scheduled), "transactions scheduled")
+	return nil
+}
+
+func CancelScheduledTransaction(context *cli.Context, wallet walt.Wallet) error {
+	txId := context.String("cancel")
+	if _, err := txIdFromString(txId); err != nil {
+		return err
+	}
+	err := wallet.CancelScheduledTx(txId)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Scheduled transaction", txId, "canceled")
+	return nil
+}
+
+// Parse the transaction hash in reversed hex string
+func txIdFromString(txId string) (*Uint256, error) {
+	hashBytes, err := HexStringToBytesReverse(txId)
+	if err != nil {
+		return nil, errors.New("invalid transaction hash")
+	}
+	hash, err := Uint256FromBytes(hashBytes)
+	if err != nil {
+		return nil, errors.New("invalid transaction hash")
+	}
+	return hash, nil
+}
+
 func getContent(context *cli.Context) (*string, error) {
 	var content string
 	// If parameter with file path is not empty, read content from file
@@ -325,13 +455,53 @@ func transactionAction(context *cli.Context) {
 
 		}
 	}
+
+	// bump fee of a pending transaction
+	if context.String("bumpfee") != "" {
+		if err := BumpFee([]byte(pass), context, wallet); err != nil {
+			fmt.Println("error:", err)
+			cli.ShowCommandHelpAndExit(context, "bumpfee", 704)
+		}
+	}
+
+	// set the label of a transaction
+	if context.String("label") != "" {
+		if err := LabelTransaction(context, wallet); err != nil {
+			fmt.Println("error: set transaction label failed,", err)
+			cli.ShowCommandHelpAndExit(context, "label", 705)
+		}
+	}
+
+	// search transactions
+	if context.IsSet("search") {
+		if err := SearchTransactions(context, wallet); err != nil {
+			fmt.Println("error: search transactions failed,", err)
+			os.Exit(706)
+		}
+	}
+
+	// list the scheduled transactions
+	if context.Bool("scheduled") {
+		if err := ListScheduledTransactions(wallet); err != nil {
+			fmt.Println("error: list scheduled transactions failed,", err)
+			os.Exit(707)
+		}
+	}
+
+	// cancel a scheduled transaction
+	if context.String("cancel") != "" {
+		if err := CancelScheduledTransaction(context, wallet); err != nil {
+			fmt.Println("error: cancel scheduled transaction failed,", err)
+			os.Exit(708)
+		}
+	}
 }
 
 func NewCommand() cli.Command {
 	return cli.Command{
 		Name:        "transaction",
 		ShortName:   "tx",
-		Usage:       "use [--create, --sign, --send], to create, sign or send a transaction",
+		Usage:       "use [--create, --sign, --send, --bumpfee, --label, --search, --scheduled, --cancel], to create, sign, send a transaction, bump it's fee, label or search transactions, list or cancel scheduled transactions",
 		Description: "create, sign or send transaction",
 		ArgsUsage:   "[args]",
 		Flags: append(CommonFlags,
@@ -368,6 +538,53 @@ func NewCommand() cli.Command {
 				Name:  "fee",
 				Usage: "the transfer fee of the transaction",
 			},
+			cli.StringFlag{
+				Name:  "recordtype",
+				Usage: "use [--from] --recordtype --recorddata --fee to create a record transaction carrying the data",
+			},
+			cli.StringFlag{
+				Name:  "recorddata",
+				Usage: "the record data in hex string of the record transaction",
+			},
+			cli.StringFlag{
+				Name: "bumpfee",
+				Usage: "use --bumpfee <txid> --feerate to replace a pending transaction with a higher fee\n" +
+					"\tthe fee increased is taken from the change of the transaction",
+			},
+			cli.StringFlag{
+				Name:  "feerate",
+				Usage: "the new fee per KB of the transaction to bump fee",
+			},
+			cli.StringFlag{
+				Name: "label",
+				Usage: "use --label <txid> [--text] [--category] [--tags] to set the label, category and tags of a transaction\n" +
+					"\tleave them all empty to remove the label",
+			},
+			cli.StringFlag{
+				Name:  "text",
+				Usage: "the label text of the transaction",
+			},
+			cli.StringFlag{
+				Name:  "category",
+				Usage: "the category of the transaction, like salary or rent",
+			},
+			cli.StringFlag{
+				Name:  "tags",
+				Usage: "the comma separated tags of the transaction",
+			},
+			cli.StringFlag{
+				Name: "search",
+				Usage: "use --search <query> to search the transactions by the txid, label, category, tags, memo or addresses\n" +
+					"\tthe terms separated by spaces must all match, use tag:<tag> or category:<category> to match them exactly",
+			},
+			cli.BoolFlag{
+				Name:  "scheduled",
+				Usage: "list the transactions scheduled by send --locktime and not broadcasted yet",
+			},
+			cli.StringFlag{
+				Name:  "cancel",
+				Usage: "use --cancel <txid> to cancel a scheduled transaction before it's broadcasted",
+			},
 			cli.StringFlag{
 				Name:  "lock",
 				Usage: "the lock time to specify when the received asset can be spent",
@@ -88,13 +88,13 @@ func resetDatabase(context *cli.Context) {
 		return
 	}
 
-	err = wallet.Reset()
+	err = wallet.ResetChainData()
 	if err != nil {
 		fmt.Println("--WALLET DATABASE RESET FAILED--")
 		return
 	}
 
-	fmt.Println("--WALLET DATABASE HAS BEEN RESET--")
+	fmt.Println("--WALLET DATABASE HAS BEEN RESET, RESCAN REQUESTED--")
 }
 
 func NewCreateCommand() cli.Command {
@@ -124,7 +124,7 @@ func NewChangePasswordCommand() cli.Command {
 func NewResetCommand() cli.Command {
 	return cli.Command{
 		Name:   "reset",
-		Usage:  "reset wallet database including transactions, utxos and stxos",
+		Usage:  "reset wallet database including headers, transactions, utxos and stxos, keys and addresses are preserved",
 		Flags:  append(CommonFlags),
 		Action: resetDatabase,
 		OnUsageError: func(c *cli.Context, err error, subCommand bool) error {
@@ -5,21 +5,185 @@ import (
 	"io/ioutil"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 )
 
 const (
 	ConfigFilename = "./config.json"
+
+	// Default max blocks a reorganize can wipe out
+	DefaultMaxReorgDepth = 100
 )
 
 var config *Config // The single instance of config
 
 type Config struct {
+	// Magic number of the peer to peer network, 0 means main net
+	Magic uint32
+	// Hex encoded genesis block header of a private network or sidechain, the first block synced must
+	// extend it, empty means the genesis of the registered network params if any
+	Genesis string
+	// Follow a sidechain merge mined with the main chain instead of the main chain
+	SideChain  bool
 	PrintLevel uint8
 	SeedList   []string
+	// Connect only to these full nodes if not empty, the seeds are ignored
+	TrustedPeers []string
+	// Never connect to peers in these subnets, like "10.0.0.0/8", or IP addresses
+	BannedSubnets []string
+	// Full nodes pinned to public keys, peer address or host to the hex encoded public key,
+	// a peer on the host must sign a challenge with the key to be connected
+	PinnedPeers map[string]string
+	// Port of the RPC server, 0 means default
+	RPCPort uint16
+	// Path of the unix socket the RPC server listens on, empty means localhost TCP on RPCPort
+	RPCSocket string
+	// Serve the RPC server with TLS, the clients trust the certificate file of the server
+	RPCTLS bool
+	// Directory to store databases, keystore and logs, empty means the work directory
+	DataDir string
+	// Address to serve the metrics endpoint, like ":20878", empty means disabled
+	MetricsAddr string
+	// Serve the metrics endpoint with TLS
+	MetricsTLS bool
+	// Address to serve the pprof and expvar debug endpoints, like "127.0.0.1:20879", empty means disabled
+	DebugAddr string
+	// Serve the debug endpoints with TLS
+	DebugTLS bool
+	// Address to serve the health and readiness probes, like ":20880", empty means disabled
+	HealthAddr string
+	// Serve the health and readiness probes with TLS
+	HealthTLS bool
+	// Address to serve the read-only REST API, like ":20881", empty means disabled
+	APIAddr string
+	// API keys a REST API request must present one of, empty means no key required
+	APIKeys []string
+	// Max requests per minute of a client to the REST API, 0 means default
+	APIRateLimit int
+	// Serve the REST API with TLS
+	APITLS bool
+	// PEM certificate and key files of the TLS servers, a self-signed certificate is generated if they
+	// don't exist, empty means tls.cert and tls.key in the data directory. Replaced files are reloaded
+	TLSCert string
+	TLSKey  string
+	// PEM file of the CAs of the client certificates, the TLS servers require a client certificate
+	// signed by one of them if it's set
+	TLSClientCA string
+	// PEM certificate and key files the RPC client presents to a server requiring client certificates
+	TLSClientCert string
+	TLSClientKey  string
+	// Min established peers for the service to be healthy, 0 means default
+	HealthMinPeers int
+	// Max blocks behind the best peer for the service to be ready, 0 means default
+	ReadyMaxSyncLag uint32
+	// Minutes without a new block while peers connected for the chain to be stalled,
+	// the service is not ready when stalled, 0 means default and negative means disabled
+	StallTimeout int
+	// Keep connecting peers until connected peers reach this count, 0 means default
+	MinConnCount int
+	// Max peer addresses to connect at one time, 0 means default
+	MaxOutboundCount int
+	// Run in the low bandwidth mode for a metered connection
+	Metered bool
+	// Max blocks downloading at one time, 0 means default
+	MaxInFlightBlocks int
+	// Max transactions of the blocks downloading at one time, 0 means no limit
+	MaxInFlightTxs int
+	// Max megabytes of the blocks and transactions downloaded but not committed, 0 means no limit
+	MemoryBudget uint32
+	// Min and max fee per KB in sela of the transactions sent through the service, a transaction out of
+	// the range is refused, 0 means no limit
+	MinFeeRate int64
+	MaxFeeRate int64
+	// Recent blocks rescanned for the transactions of the addresses added to the wallet, 0 means no rescan
+	RescanDepth uint32
+	// Accept no inbound connections, add decoys to the bloom filter and load it to one peer at a time
+	PrivacyMode bool
+	// Decoy addresses and outpoints added to the bloom filter in privacy mode, 0 means default
+	PrivacyDecoys int
+	// Minutes to load the bloom filter to another peer in privacy mode, 0 means default
+	FilterRotation uint32
+	// Split the addresses and outpoints to shards loaded to different peers, not with PrivacyMode
+	SplitFilter bool
+	// Shards the bloom filter is split to, 0 means default
+	FilterShards int
+	// Peers each shard is loaded to, 0 means default
+	FilterRedundancy int
+	// Rebuild the bloom filter when the observed false positive rate is above FilterFPThreshold, not with SplitFilter
+	FilterTuning bool
+	// False positive rate of the bloom filter built with FilterTuning, 0 means default
+	FilterFPRate float64
+	// Observed false positive rate to rebuild the bloom filter at with FilterTuning, 0 means default
+	FilterFPThreshold float64
+	// URLs to post the wallet events to, empty means disabled
+	Webhooks []string
+	// Secret to sign the webhook requests with HMAC-SHA256, empty means not signed
+	WebhookSecret string
+	// Confirmations for the tx.confirmed webhook event, 0 means default
+	WebhookConfirmations uint32
+	// Max blocks a reorganize can wipe out, deeper reorganizes are refused, 0 means no limit
+	MaxReorgDepth uint32
+	// Minutes between the audits of the registered addresses, 0 means disabled
+	AuditInterval uint32
+	// Recent blocks downloaded in full by an address audit, 0 means default
+	AuditDepth uint32
+	// How to handle a panic recovered from a listener or message handler, "log", "disable" or "crash"
+	PanicPolicy string
+	// File to record the inbound peer messages to for a deterministic replay, empty means disabled
+	RecordFile string
+	// Hex encoded public keys of the DPoS arbiters, blocks confirmed by the supermajority
+	// of them are irreversible, empty means DPoS confirms are ignored
+	Arbiters []string
+	// Rules added to the rules of the network, a rule of the same name replaces the built-in one
+	Rules []Rule
+	// Activation heights of the network rules overridden by rule name
+	ActivationHeights map[string]uint32
+	// STXOs spent deeper than this confirmations will be pruned, 0 means never
+	STXOPruneDepth uint32
+	// How to prune STXOs, "archive" or "drop"
+	STXOPrunePolicy string
+	// When to flush database writes, "always", "block" or "async"
+	Durability string
+	// Move the wallet database aside and continue in the headers only mode when a write to it failed,
+	// otherwise the wallet stops syncing until restarted
+	QuarantineDB bool
+	// Max size in megabytes of a log file before it rotated, 0 means no limit
+	LogMaxSize int
+	// Max days to keep the rotated log files, 0 means no limit
+	LogMaxAge int
+	// Max rotated log files to keep, 0 means no limit
+	LogMaxBackups int
+	// Compress the rotated log files with gzip
+	LogCompress bool
+	// Take over the data directory locked by another process, set by the -force flag only
+	Force bool `json:"-"`
+}
+
+// A consensus or payload change of the network activated from the height, see sdk.Rule
+type Rule struct {
+	Name   string
+	Height uint32
+	// Min version of the block headers from the activation height, 0 means not checked
+	MinHeaderVersion uint32
+	// Names of the transaction types introduced by the rule, like "TransferCrossChainAsset"
+	TxTypes []string
+	// Max payload version of the transactions from the activation height, 0 means not checked
+	MaxPayloadVersion byte
+	// Hex encoded data of the Nonce attribute transactions must carry from the activation height,
+	// empty means no replay marker
+	ReplayMarker string
+}
+
+// Get the path of the file in the data directory, an absolute path is returned as it is
+func (config *Config) Path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(config.DataDir, name)
 }
 
 func (config *Config) readConfigFile() error {
-	data, err := ioutil.ReadFile(ConfigFilename)
+	data, err := ioutil.ReadFile(configFile)
 	if err != nil {
 		return err
 	}
@@ -34,11 +198,15 @@ func (config *Config) readConfigFile() error {
 }
 
 func Values() *Config {
+	lock.Lock()
+	defer lock.Unlock()
+
 	if config == nil {
-		config = new(Config)
-		err := config.readConfigFile()
+		var err error
+		config, err = load()
 		if err != nil {
 			fmt.Println("Read config file error:", err)
+			config = defaultConfig()
 		}
 	}
 	return config
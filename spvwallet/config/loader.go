@@ -0,0 +1,502 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the environment variables to override settings, like SPV_MAGIC
+const EnvPrefix = "SPV_"
+
+// setting is a config item can be overridden by environment variable and command-line flag
+type setting struct {
+	// Flag name, the environment variable name is EnvPrefix followed by the upper case name
+	name  string
+	usage string
+	set   func(config *Config, value string) error
+}
+
+func (s *setting) env() string {
+	return EnvPrefix + strings.ToUpper(s.name)
+}
+
+var settings = []setting{
+	{"magic", "magic number of the peer to peer network", func(config *Config, value string) error {
+		magic, err := strconv.ParseUint(value, 10, 32)
+		config.Magic = uint32(magic)
+		return err
+	}},
+	{"genesis", "hex encoded genesis block header of a private network or sidechain", func(config *Config, value string) error {
+		config.Genesis = value
+		return nil
+	}},
+	{"sidechain", "follow a sidechain merge mined with the main chain", func(config *Config, value string) error {
+		sideChain, err := strconv.ParseBool(value)
+		config.SideChain = sideChain
+		return err
+	}},
+	{"seedlist", "comma separated seed peer addresses", func(config *Config, value string) error {
+		config.SeedList = splitList(value)
+		return nil
+	}},
+	{"trustedpeers", "comma separated full node addresses to connect only to", func(config *Config, value string) error {
+		config.TrustedPeers = splitList(value)
+		return nil
+	}},
+	{"bannedsubnets", "comma separated subnets or IP addresses never to connect, like 10.0.0.0/8", func(config *Config, value string) error {
+		config.BannedSubnets = splitList(value)
+		return nil
+	}},
+	{"pinnedpeers", "comma separated host=publickey pairs of the full nodes pinned to public keys", func(config *Config, value string) error {
+		config.PinnedPeers = make(map[string]string)
+		for _, item := range splitList(value) {
+			i := strings.LastIndex(item, "=")
+			if i <= 0 {
+				return errors.New("invalid pinned peer " + item + ", expect host=publickey")
+			}
+			config.PinnedPeers[strings.TrimSpace(item[:i])] = strings.TrimSpace(item[i+1:])
+		}
+		return nil
+	}},
+	{"rpcport", "port of the RPC server", func(config *Config, value string) error {
+		port, err := strconv.ParseUint(value, 10, 16)
+		config.RPCPort = uint16(port)
+		return err
+	}},
+	{"rpcsocket", "path of the unix socket the RPC server listens on, empty means localhost TCP", func(config *Config, value string) error {
+		config.RPCSocket = value
+		return nil
+	}},
+	{"rpctls", "serve the RPC server with TLS, true or false", func(config *Config, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		config.RPCTLS = enabled
+		return err
+	}},
+	{"datadir", "directory to store databases, keystore and logs", func(config *Config, value string) error {
+		config.DataDir = value
+		return nil
+	}},
+	{"metricsaddr", "address to serve the metrics endpoint, like :20878", func(config *Config, value string) error {
+		config.MetricsAddr = value
+		return nil
+	}},
+	{"metricstls", "serve the metrics endpoint with TLS, true or false", func(config *Config, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		config.MetricsTLS = enabled
+		return err
+	}},
+	{"debugaddr", "address to serve the pprof and expvar debug endpoints, like 127.0.0.1:20879", func(config *Config, value string) error {
+		config.DebugAddr = value
+		return nil
+	}},
+	{"debugtls", "serve the debug endpoints with TLS, true or false", func(config *Config, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		config.DebugTLS = enabled
+		return err
+	}},
+	{"healthaddr", "address to serve the health and readiness probes, like :20880", func(config *Config, value string) error {
+		config.HealthAddr = value
+		return nil
+	}},
+	{"healthtls", "serve the health and readiness probes with TLS, true or false", func(config *Config, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		config.HealthTLS = enabled
+		return err
+	}},
+	{"apiaddr", "address to serve the read-only REST API, like :20881", func(config *Config, value string) error {
+		config.APIAddr = value
+		return nil
+	}},
+	{"apikeys", "comma separated API keys of the REST API", func(config *Config, value string) error {
+		config.APIKeys = splitList(value)
+		return nil
+	}},
+	{"apiratelimit", "max requests per minute of a client to the REST API", func(config *Config, value string) error {
+		limit, err := strconv.Atoi(value)
+		config.APIRateLimit = limit
+		return err
+	}},
+	{"apitls", "serve the REST API with TLS, true or false", func(config *Config, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		config.APITLS = enabled
+		return err
+	}},
+	{"tlscert", "PEM certificate file of the TLS servers, generated self-signed if not exist", func(config *Config, value string) error {
+		config.TLSCert = value
+		return nil
+	}},
+	{"tlskey", "PEM key file of the TLS servers", func(config *Config, value string) error {
+		config.TLSKey = value
+		return nil
+	}},
+	{"tlsclientca", "PEM file of the CAs of the client certificates the TLS servers require", func(config *Config, value string) error {
+		config.TLSClientCA = value
+		return nil
+	}},
+	{"tlsclientcert", "PEM certificate file the RPC client presents to the server", func(config *Config, value string) error {
+		config.TLSClientCert = value
+		return nil
+	}},
+	{"tlsclientkey", "PEM key file the RPC client presents to the server", func(config *Config, value string) error {
+		config.TLSClientKey = value
+		return nil
+	}},
+	{"healthminpeers", "min established peers for the service to be healthy", func(config *Config, value string) error {
+		peers, err := strconv.Atoi(value)
+		config.HealthMinPeers = peers
+		return err
+	}},
+	{"readymaxsynclag", "max blocks behind the best peer for the service to be ready", func(config *Config, value string) error {
+		lag, err := strconv.ParseUint(value, 10, 32)
+		config.ReadyMaxSyncLag = uint32(lag)
+		return err
+	}},
+	{"printlevel", "print level of logs, 0~5", func(config *Config, value string) error {
+		level, err := strconv.ParseUint(value, 10, 8)
+		config.PrintLevel = uint8(level)
+		return err
+	}},
+	{"minconncount", "keep connecting peers until connected peers reach this count", func(config *Config, value string) error {
+		count, err := strconv.Atoi(value)
+		config.MinConnCount = count
+		return err
+	}},
+	{"maxoutboundcount", "max peer addresses to connect at one time", func(config *Config, value string) error {
+		count, err := strconv.Atoi(value)
+		config.MaxOutboundCount = count
+		return err
+	}},
+	{"metered", "run in the low bandwidth mode for a metered connection, true or false", func(config *Config, value string) error {
+		metered, err := strconv.ParseBool(value)
+		config.Metered = metered
+		return err
+	}},
+	{"maxinflightblocks", "max blocks downloading at one time", func(config *Config, value string) error {
+		count, err := strconv.Atoi(value)
+		config.MaxInFlightBlocks = count
+		return err
+	}},
+	{"maxinflighttxs", "max transactions of the blocks downloading at one time", func(config *Config, value string) error {
+		count, err := strconv.Atoi(value)
+		config.MaxInFlightTxs = count
+		return err
+	}},
+	{"memorybudget", "max megabytes of the downloaded blocks and transactions not committed", func(config *Config, value string) error {
+		budget, err := strconv.ParseUint(value, 10, 32)
+		config.MemoryBudget = uint32(budget)
+		return err
+	}},
+	{"minfeerate", "min fee per KB in sela of the transactions sent, 0 means no limit", func(config *Config, value string) error {
+		rate, err := strconv.ParseInt(value, 10, 64)
+		config.MinFeeRate = rate
+		return err
+	}},
+	{"maxfeerate", "max fee per KB in sela of the transactions sent, 0 means no limit", func(config *Config, value string) error {
+		rate, err := strconv.ParseInt(value, 10, 64)
+		config.MaxFeeRate = rate
+		return err
+	}},
+	{"rescandepth", "recent blocks rescanned for the transactions of the addresses added to the wallet", func(config *Config, value string) error {
+		depth, err := strconv.ParseUint(value, 10, 32)
+		config.RescanDepth = uint32(depth)
+		return err
+	}},
+	{"privacymode", "accept no inbound connections and load the bloom filter with decoys to one peer at a time, true or false", func(config *Config, value string) error {
+		privacy, err := strconv.ParseBool(value)
+		config.PrivacyMode = privacy
+		return err
+	}},
+	{"privacydecoys", "decoy addresses and outpoints added to the bloom filter in privacy mode", func(config *Config, value string) error {
+		decoys, err := strconv.Atoi(value)
+		config.PrivacyDecoys = decoys
+		return err
+	}},
+	{"filterrotation", "minutes to load the bloom filter to another peer in privacy mode", func(config *Config, value string) error {
+		rotation, err := strconv.ParseUint(value, 10, 32)
+		config.FilterRotation = uint32(rotation)
+		return err
+	}},
+	{"splitfilter", "split the addresses and outpoints to shards loaded to different peers, true or false", func(config *Config, value string) error {
+		split, err := strconv.ParseBool(value)
+		config.SplitFilter = split
+		return err
+	}},
+	{"filtershards", "shards the bloom filter is split to", func(config *Config, value string) error {
+		shards, err := strconv.Atoi(value)
+		config.FilterShards = shards
+		return err
+	}},
+	{"filterredundancy", "peers each shard of the bloom filter is loaded to", func(config *Config, value string) error {
+		redundancy, err := strconv.Atoi(value)
+		config.FilterRedundancy = redundancy
+		return err
+	}},
+	{"filtertuning", "rebuild the bloom filter when the observed false positive rate is above the threshold, true or false", func(config *Config, value string) error {
+		tuning, err := strconv.ParseBool(value)
+		config.FilterTuning = tuning
+		return err
+	}},
+	{"filterfprate", "false positive rate of the bloom filter built with the filter tuning", func(config *Config, value string) error {
+		rate, err := strconv.ParseFloat(value, 64)
+		config.FilterFPRate = rate
+		return err
+	}},
+	{"filterfpthreshold", "observed false positive rate to rebuild the bloom filter at with the filter tuning", func(config *Config, value string) error {
+		threshold, err := strconv.ParseFloat(value, 64)
+		config.FilterFPThreshold = threshold
+		return err
+	}},
+	{"webhooks", "comma separated URLs to post the wallet events to", func(config *Config, value string) error {
+		config.Webhooks = splitList(value)
+		return nil
+	}},
+	{"webhooksecret", "secret to sign the webhook requests with HMAC-SHA256", func(config *Config, value string) error {
+		config.WebhookSecret = value
+		return nil
+	}},
+	{"webhookconfirmations", "confirmations for the tx.confirmed webhook event", func(config *Config, value string) error {
+		confirmations, err := strconv.ParseUint(value, 10, 32)
+		config.WebhookConfirmations = uint32(confirmations)
+		return err
+	}},
+	{"maxreorgdepth", "max blocks a reorganize can wipe out, 0 means no limit", func(config *Config, value string) error {
+		depth, err := strconv.ParseUint(value, 10, 32)
+		config.MaxReorgDepth = uint32(depth)
+		return err
+	}},
+	{"stalltimeout", "minutes without a new block for the chain to be stalled, negative means disabled", func(config *Config, value string) error {
+		timeout, err := strconv.Atoi(value)
+		config.StallTimeout = timeout
+		return err
+	}},
+	{"auditinterval", "minutes between the audits of the registered addresses, 0 means disabled", func(config *Config, value string) error {
+		interval, err := strconv.ParseUint(value, 10, 32)
+		config.AuditInterval = uint32(interval)
+		return err
+	}},
+	{"auditdepth", "recent blocks downloaded in full by an address audit", func(config *Config, value string) error {
+		depth, err := strconv.ParseUint(value, 10, 32)
+		config.AuditDepth = uint32(depth)
+		return err
+	}},
+	{"panicpolicy", "how to handle a panic of a listener or message handler, log, disable or crash", func(config *Config, value string) error {
+		config.PanicPolicy = value
+		return nil
+	}},
+	{"recordfile", "file to record the inbound peer messages to for replay", func(config *Config, value string) error {
+		config.RecordFile = value
+		return nil
+	}},
+	{"arbiters", "comma separated public keys of the DPoS arbiters", func(config *Config, value string) error {
+		config.Arbiters = splitList(value)
+		return nil
+	}},
+	{"activationheights", "comma separated rule=height pairs to override the activation heights of the network rules", func(config *Config, value string) error {
+		config.ActivationHeights = make(map[string]uint32)
+		for _, item := range splitList(value) {
+			i := strings.LastIndex(item, "=")
+			if i <= 0 {
+				return errors.New("invalid activation height " + item + ", expect rule=height")
+			}
+			height, err := strconv.ParseUint(strings.TrimSpace(item[i+1:]), 10, 32)
+			if err != nil {
+				return err
+			}
+			config.ActivationHeights[strings.TrimSpace(item[:i])] = uint32(height)
+		}
+		return nil
+	}},
+	{"stxoprunedepth", "STXOs spent deeper than this confirmations will be pruned", func(config *Config, value string) error {
+		depth, err := strconv.ParseUint(value, 10, 32)
+		config.STXOPruneDepth = uint32(depth)
+		return err
+	}},
+	{"stxoprunepolicy", "how to prune STXOs, archive or drop", func(config *Config, value string) error {
+		config.STXOPrunePolicy = value
+		return nil
+	}},
+	{"durability", "when to flush database writes, always, block or async", func(config *Config, value string) error {
+		config.Durability = value
+		return nil
+	}},
+	{"quarantinedb", "move the wallet database aside on a write failure and sync headers only, true or false", func(config *Config, value string) error {
+		quarantine, err := strconv.ParseBool(value)
+		config.QuarantineDB = quarantine
+		return err
+	}},
+	{"logmaxsize", "max size in megabytes of a log file before it rotated", func(config *Config, value string) error {
+		size, err := strconv.Atoi(value)
+		config.LogMaxSize = size
+		return err
+	}},
+	{"logmaxage", "max days to keep the rotated log files", func(config *Config, value string) error {
+		age, err := strconv.Atoi(value)
+		config.LogMaxAge = age
+		return err
+	}},
+	{"logmaxbackups", "max rotated log files to keep", func(config *Config, value string) error {
+		backups, err := strconv.Atoi(value)
+		config.LogMaxBackups = backups
+		return err
+	}},
+	{"logcompress", "compress the rotated log files, true or false", func(config *Config, value string) error {
+		compress, err := strconv.ParseBool(value)
+		config.LogCompress = compress
+		return err
+	}},
+}
+
+// Split the comma separated list, empty items are removed
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+var (
+	// Config file path, can be changed by the -config flag or SPV_CONFIG environment variable
+	configFile = ConfigFilename
+	// Command-line arguments the config loaded with, they are applied again when reload
+	arguments []string
+)
+
+// Create a config with the default values
+func defaultConfig() *Config {
+	return &Config{
+		MaxReorgDepth:   DefaultMaxReorgDepth,
+		STXOPrunePolicy: "archive",
+		Durability:      "always",
+	}
+}
+
+// Load config with the settings layered as defaults < config file < environment variables
+// < command-line flags, the config returned by Values() is replaced with the loaded one
+func Load(args []string) (*Config, error) {
+	flags := newFlagSet()
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, errors.New(fmt.Sprint("Unknown arguments ", flags.Args()))
+	}
+
+	path := ConfigFilename
+	if env, ok := os.LookupEnv(EnvPrefix + "CONFIG"); ok {
+		path = env
+	}
+	if file := flags.Lookup("config"); file.Value.String() != "" {
+		path = file.Value.String()
+	}
+	// Config file will be read again when reload, keep it's path if work directory changed
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	oldFile, oldArgs := configFile, arguments
+	configFile, arguments = path, args
+	newConfig, err := load()
+	if err != nil {
+		configFile, arguments = oldFile, oldArgs
+		return nil, err
+	}
+	config = newConfig
+	return newConfig, nil
+}
+
+func newFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	flags.String("config", "", "path of the config file")
+	flags.Bool("force", false, "take over the data directory locked by another process")
+	for _, s := range settings {
+		flags.String(s.name, "", fmt.Sprintf("%s (env %s)", s.usage, s.env()))
+	}
+	return flags
+}
+
+// Load config from the config file, environment variables and command-line arguments
+func load() (*Config, error) {
+	newConfig := defaultConfig()
+
+	// Config file is not necessary, settings can be given all by environment variables or flags
+	err := newConfig.readConfigFile()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env())
+		if !ok {
+			continue
+		}
+		err = s.set(newConfig, value)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid %s %s, %s", s.env(), value, err))
+		}
+	}
+
+	flags := newFlagSet()
+	flags.SetOutput(ioutil.Discard)
+	err = flags.Parse(arguments)
+	if err != nil {
+		return nil, err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+		for _, s := range settings {
+			if s.name == f.Name {
+				if e := s.set(newConfig, f.Value.String()); e != nil {
+					err = errors.New(fmt.Sprintf("Invalid -%s %s, %s", f.Name, f.Value.String(), e))
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	newConfig.Force = flags.Lookup("force").Value.String() == "true"
+
+	err = newConfig.validate()
+	if err != nil {
+		return nil, err
+	}
+	return newConfig, nil
+}
+
+// Check the settings of a fixed set of values, an unknown value is refused rather than taken as the default
+func (config *Config) validate() error {
+	switch config.STXOPrunePolicy {
+	case "none", "archive", "drop":
+	default:
+		return errors.New("Invalid STXOPrunePolicy " + config.STXOPrunePolicy + ", expect none, archive or drop")
+	}
+	if config.MinFeeRate < 0 || config.MaxFeeRate < 0 || config.MaxFeeRate > 0 && config.MaxFeeRate < config.MinFeeRate {
+		return errors.New(fmt.Sprint("Invalid fee policy, MinFeeRate ", config.MinFeeRate, " MaxFeeRate ", config.MaxFeeRate))
+	}
+	for _, rule := range config.Rules {
+		if rule.Name == "" {
+			return errors.New(fmt.Sprint("Invalid rule at height ", rule.Height, ", name is empty"))
+		}
+		if _, err := hex.DecodeString(rule.ReplayMarker); err != nil {
+			return errors.New("Invalid replay marker of rule " + rule.Name + ", " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How often the config file is checked for changes
+const WatchInterval = 5 * time.Second
+
+// ChangeListener is called with the old and the new config after config reloaded
+type ChangeListener func(old, new *Config)
+
+var (
+	lock      sync.Mutex
+	listeners []ChangeListener
+	watching  bool
+)
+
+// Register a listener to receive config-changed events
+func AddChangeListener(listener ChangeListener) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	listeners = append(listeners, listener)
+}
+
+// Load config again and notify the listeners, the config returned by
+// Values() is replaced with the new one
+func Reload() error {
+	lock.Lock()
+	newConfig, err := load()
+	if err != nil {
+		lock.Unlock()
+		return err
+	}
+	oldConfig := config
+	if oldConfig == nil {
+		oldConfig = new(Config)
+	}
+	config = newConfig
+	changeListeners := listeners
+	lock.Unlock()
+
+	for _, listener := range changeListeners {
+		listener(oldConfig, newConfig)
+	}
+	return nil
+}
+
+// Reload config when SIGHUP received or the config file changed, the reload errors are passed to onError,
+// the log package can not be used here as it reads the config
+func Watch(onError func(err error)) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if watching {
+		return
+	}
+	watching = true
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+
+		ticker := time.NewTicker(WatchInterval)
+		defer ticker.Stop()
+
+		modTime := fileModTime()
+		for {
+			select {
+			case <-signals:
+			case <-ticker.C:
+				current := fileModTime()
+				if current.Equal(modTime) {
+					continue
+				}
+				modTime = current
+			}
+
+			if err := Reload(); err != nil {
+				onError(err)
+			}
+		}
+	}()
+}
+
+func fileModTime() time.Time {
+	lock.Lock()
+	path := configFile
+	lock.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
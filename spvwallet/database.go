@@ -1,10 +1,12 @@
 package spvwallet
 
 import (
+	"errors"
 	"sync"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
 )
 
 type Database interface {
@@ -12,17 +14,34 @@ type Database interface {
 	GetAddress(address *Uint168) (*Addr, error)
 	GetAddrs() ([]*Addr, error)
 	DeleteAddress(address *Uint168) error
+	SetAddressLabel(address *Uint168, label string) error
 	GetAddressUTXOs(address *Uint168) ([]*UTXO, error)
 	GetAddressSTXOs(address *Uint168) ([]*STXO, error)
+	SetTxLabel(txId *Uint256, label *TxLabel) error
+	GetTxLabel(txId *Uint256) (*TxLabel, error)
+	SetTxPayments(txId *Uint256, payments []*Payment) error
+	GetTxPayments(txId *Uint256) ([]*Payment, error)
+	SearchTxs(query string) ([]*rpc.TxSearchResult, error)
+	GetJournal(seq uint64, limit int) ([]*JournalEntry, error)
+	AuditKey(operation, result string) error
+	GetKeyAudit(seq uint64, limit int) ([]*KeyAuditEntry, error)
+	GetKeyFailures() (*rpc.KeyFailures, error)
 	ChainHeight() uint32
 	Reset() error
+	ResetChainData() error
 }
 
 var instance Database
 
+// Get the wallet database, if the SPV service is running the database is accessed through
+// the service RPC, otherwise the database is opened directly
 func GetDatabase() (Database, error) {
+	if instance == nil && rpc.IsServiceRunning() {
+		instance = &RemoteDatabase{Client: rpc.GetClient()}
+	}
+
 	if instance == nil {
-		dataStore, err := NewSQLiteDB()
+		dataStore, err := NewSQLiteDB(dataDir(), durability())
 		if err != nil {
 			return nil, err
 		}
@@ -69,6 +88,13 @@ func (db *DatabaseImpl) DeleteAddress(address *Uint168) error {
 	return db.DataStore.Addrs().Delete(address)
 }
 
+func (db *DatabaseImpl) SetAddressLabel(address *Uint168, label string) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return db.DataStore.Addrs().SetLabel(address, label)
+}
+
 func (db *DatabaseImpl) GetAddressUTXOs(address *Uint168) ([]*UTXO, error) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
@@ -80,7 +106,82 @@ func (db *DatabaseImpl) GetAddressSTXOs(address *Uint168) ([]*STXO, error) {
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
-	return db.DataStore.STXOs().GetAddrAll(address)
+	return db.DataStore.STXOs().GetAddrHistory(address)
+}
+
+func (db *DatabaseImpl) SetTxLabel(txId *Uint256, label *TxLabel) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return db.DataStore.TxLabels().Put(txId, label)
+}
+
+func (db *DatabaseImpl) GetTxLabel(txId *Uint256) (*TxLabel, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.DataStore.TxLabels().Get(txId)
+}
+
+func (db *DatabaseImpl) SetTxPayments(txId *Uint256, payments []*Payment) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return db.DataStore.TxPayments().Put(txId, payments)
+}
+
+func (db *DatabaseImpl) GetTxPayments(txId *Uint256) ([]*Payment, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return db.DataStore.TxPayments().Get(txId)
+}
+
+func (db *DatabaseImpl) SearchTxs(query string) ([]*rpc.TxSearchResult, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return searchTxs(db.DataStore, query)
+}
+
+func (db *DatabaseImpl) GetJournal(seq uint64, limit int) ([]*JournalEntry, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	return readJournal(db.DataStore, seq, limit)
+}
+
+func (db *DatabaseImpl) AddKeyAudit(entry *KeyAuditEntry) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	return db.DataStore.KeyAudit().Append(entry)
+}
+
+// Append the key operation done in this process to the key audit log with the result
+func (db *DatabaseImpl) AuditKey(operation, result string) error {
+	return db.AddKeyAudit(&KeyAuditEntry{Source: auditSource, Operation: operation, Result: result})
+}
+
+func (db *DatabaseImpl) GetKeyAudit(seq uint64, limit int) ([]*KeyAuditEntry, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if limit <= 0 || limit > MaxKeyAuditEntries {
+		limit = MaxKeyAuditEntries
+	}
+	return db.DataStore.KeyAudit().After(seq, limit)
+}
+
+func (db *DatabaseImpl) GetKeyFailures() (*rpc.KeyFailures, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	failures, last, err := db.DataStore.KeyAudit().Failures()
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.KeyFailures{Failures: failures, Last: last}, nil
 }
 
 func (db *DatabaseImpl) ChainHeight() uint32 {
@@ -94,7 +195,7 @@ func (db *DatabaseImpl) Reset() error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
-	headers, err := NewHeadersDB()
+	headers, err := NewHeadersDB(dataDir(), durability())
 	if err != nil {
 		return err
 	}
@@ -111,3 +212,50 @@ func (db *DatabaseImpl) Reset() error {
 
 	return nil
 }
+
+func (db *DatabaseImpl) ResetChainData() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	// Reset chain data in the running SPV service, it will rescan the blockchain
+	err := rpc.GetClient().ResetChainData()
+	if err == nil || err.Error() != rpc.PostRequestError.Result {
+		return err
+	}
+
+	// SPV service not running, reset database directly,
+	// the rescan will start when SPV service start
+	headers, err := NewHeadersDB(dataDir(), durability())
+	if err != nil {
+		return err
+	}
+	defer headers.Close()
+
+	err = headers.Reset()
+	if err != nil {
+		return err
+	}
+
+	return db.DataStore.ResetChainData()
+}
+
+// RemoteDatabase accesses the database of the running SPV service through RPC
+type RemoteDatabase struct {
+	*rpc.Client
+}
+
+func (db *RemoteDatabase) ChainHeight() uint32 {
+	height, _ := db.Client.GetChainHeight()
+	return height
+}
+
+// Report the key operation to the service with the result checked in this process, the password is
+// not sent
+func (db *RemoteDatabase) AuditKey(operation, result string) error {
+	_, err := db.Client.AuditKey(operation, result)
+	return err
+}
+
+func (db *RemoteDatabase) Reset() error {
+	return errors.New("[Wallet], SPV service is running, stop it before reset database")
+}
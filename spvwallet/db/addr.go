@@ -7,12 +7,15 @@ const (
 	TypeSub    = 1 << 1
 	TypeMulti  = 1 << 2
 	TypeNotify = 1 << 3
+	// Address of a private key imported into the wallet
+	TypeImported = 1 << 4
 )
 
 type Addr struct {
 	hash     *Uint168
 	script   []byte
 	addrType int
+	label    string
 }
 
 func NewAddr(hash *Uint168, script []byte, addrType int) *Addr {
@@ -36,6 +39,14 @@ func (addr *Addr) Type() int {
 	return addr.addrType
 }
 
+func (addr *Addr) Label() string {
+	return addr.label
+}
+
+func (addr *Addr) SetLabel(label string) {
+	addr.label = label
+}
+
 func (addr *Addr) TypeName() string {
 	switch addr.addrType {
 	case TypeMaster:
@@ -46,6 +57,8 @@ func (addr *Addr) TypeName() string {
 		return "MULTI"
 	case TypeNotify:
 		return "NOTIFY"
+	case TypeImported:
+		return "IMPORTED"
 	default:
 		return ""
 	}
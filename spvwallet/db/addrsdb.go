@@ -5,14 +5,21 @@ import (
 	"sync"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
-const CreateAddrsDB = `CREATE TABLE IF NOT EXISTS Addrs(
+const (
+	CreateAddrsDB = `CREATE TABLE IF NOT EXISTS Addrs(
 				Hash BLOB NOT NULL PRIMARY KEY,
 				Script BLOB,
-				Type INTEGER NOT NULL
+				Type INTEGER NOT NULL,
+				Label TEXT NOT NULL DEFAULT ''
 			);`
 
+	// Addrs created by older versions do not have the Label column
+	AddAddrsLabelColumn = `ALTER TABLE Addrs ADD COLUMN Label TEXT NOT NULL DEFAULT '';`
+)
+
 type AddrsDB struct {
 	*sync.RWMutex
 	*sql.DB
@@ -23,6 +30,10 @@ func NewAddrsDB(db *sql.DB, lock *sync.RWMutex) (Addrs, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	// Ignore the duplicate column error if Label column exists
+	db.Exec(AddAddrsLabelColumn)
+
 	return &AddrsDB{RWMutex: lock, DB: db}, nil
 }
 
@@ -31,11 +42,13 @@ func (db *AddrsDB) Put(hash *Uint168, script []byte, addrType int) error {
 	db.Lock()
 	defer db.Unlock()
 
-	stmt, err := db.Prepare("INSERT OR REPLACE INTO Addrs(Hash, Script, Type) VALUES(?,?,?)")
+	// Keep the label if the address exists
+	stmt, err := db.Prepare(`INSERT OR REPLACE INTO Addrs(Hash, Script, Type, Label)
+		VALUES(?,?,?,(SELECT IFNULL((SELECT Label FROM Addrs WHERE Hash=?),'')))`)
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(hash.ToArray(), script, addrType)
+	_, err = stmt.Exec(hash.ToArray(), script, addrType, hash.ToArray())
 	if err != nil {
 		return err
 	}
@@ -48,15 +61,18 @@ func (db *AddrsDB) Get(hash *Uint168) (*Addr, error) {
 	db.RLock()
 	defer db.RUnlock()
 
-	row := db.QueryRow(`SELECT Script, Type FROM Addrs WHERE Hash=?`, hash.ToArray())
+	row := db.QueryRow(`SELECT Script, Type, Label FROM Addrs WHERE Hash=?`, hash.ToArray())
 	var script []byte
 	var addrType int
-	err := row.Scan(&script, &addrType)
+	var label string
+	err := row.Scan(&script, &addrType, &label)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "address %s does not exist in database", hash.String())
 	}
 
-	return NewAddr(hash, script, addrType), nil
+	addr := NewAddr(hash, script, addrType)
+	addr.label = label
+	return addr, nil
 }
 
 // get all Addrs from database
@@ -65,7 +81,7 @@ func (db *AddrsDB) GetAll() ([]*Addr, error) {
 	defer db.RUnlock()
 
 	var addrs []*Addr
-	rows, err := db.Query("SELECT Hash, Script, Type FROM Addrs")
+	rows, err := db.Query("SELECT Hash, Script, Type, Label FROM Addrs")
 	if err != nil {
 		return addrs, err
 	}
@@ -75,7 +91,8 @@ func (db *AddrsDB) GetAll() ([]*Addr, error) {
 		var hashBytes []byte
 		var script []byte
 		var addrType int
-		err = rows.Scan(&hashBytes, &script, &addrType)
+		var label string
+		err = rows.Scan(&hashBytes, &script, &addrType, &label)
 		if err != nil {
 			return addrs, err
 		}
@@ -83,12 +100,34 @@ func (db *AddrsDB) GetAll() ([]*Addr, error) {
 		if err != nil {
 			return addrs, err
 		}
-		addrs = append(addrs, NewAddr(hash, script, addrType))
+		addr := NewAddr(hash, script, addrType)
+		addr.label = label
+		addrs = append(addrs, addr)
 	}
 
 	return addrs, nil
 }
 
+// set the label of an address
+func (db *AddrsDB) SetLabel(hash *Uint168, label string) error {
+	db.Lock()
+	defer db.Unlock()
+
+	result, err := db.Exec("UPDATE Addrs SET Label=? WHERE Hash=?", label, hash.ToArray())
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.Wrapf(errors.ErrNotFound, "address %s does not exist in database", hash.String())
+	}
+
+	return nil
+}
+
 // delete a script from database
 func (db *AddrsDB) Delete(hash *Uint168) error {
 	db.Lock()
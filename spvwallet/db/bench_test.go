@@ -0,0 +1,107 @@
+package db
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+)
+
+// Create a temporary directory for the databases of the benchmark, removed by the returned function
+func tempDir(b *testing.B) (string, func()) {
+	dir, err := ioutil.TempDir("", "spvbench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func randHash() Uint256 {
+	var hash Uint256
+	rand.Read(hash[:])
+	return hash
+}
+
+// Commit a block with txs wallet transactions as the SPV wallet does,
+// put the header, the UTXOs and transactions, then flush the writes
+func commitBlock(headers Headers, store DataStore, header *db.StoreHeader, txs int) error {
+	err := headers.Put(header, true)
+	if err != nil {
+		return err
+	}
+
+	programHash := Uint168{33}
+	for i := 0; i < txs; i++ {
+		txn := tx.Transaction{
+			TxType:  tx.TransferAsset,
+			Payload: &payload.TransferAsset{},
+			Inputs:  []*tx.Input{{ReferTxID: randHash()}},
+			Outputs: []*tx.Output{{Value: 100000000, ProgramHash: programHash}},
+		}
+		storeTx := db.NewStoreTx(txn, header.Height)
+		utxo := &UTXO{Op: *tx.NewOutPoint(storeTx.TxId, 0), Value: 100000000, AtHeight: storeTx.Height}
+		err = store.UTXOs().Put(&programHash, utxo)
+		if err != nil {
+			return err
+		}
+		err = store.Txs().Put(storeTx)
+		if err != nil {
+			return err
+		}
+	}
+	store.Info().SaveChainHeight(header.Height)
+
+	err = headers.Sync()
+	if err != nil {
+		return err
+	}
+	return store.Sync()
+}
+
+func benchmarkCommitBlock(b *testing.B, durability Durability) {
+	dir, remove := tempDir(b)
+	defer remove()
+
+	headers, err := NewHeadersDB(dir, durability)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer headers.Close()
+	store, err := NewSQLiteDB(dir, durability)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		header := &db.StoreHeader{
+			Header:    core.Header{Previous: randHash(), Height: uint32(i + 1)},
+			TotalWork: big.NewInt(int64(i + 1)),
+		}
+		err := commitBlock(headers, store, header, 5)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCommitBlock_Always(b *testing.B) {
+	benchmarkCommitBlock(b, DurabilityAlways)
+}
+
+func BenchmarkCommitBlock_PerBlock(b *testing.B) {
+	benchmarkCommitBlock(b, DurabilityPerBlock)
+}
+
+func BenchmarkCommitBlock_Async(b *testing.B) {
+	benchmarkCommitBlock(b, DurabilityAsync)
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"sync"
+
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+
+	"github.com/cevaris/ordered_map"
+)
+
+const (
+	// Recent headers kept in memory
+	HeaderCacheSize = 10000
+	// Wallet UTXOs kept in memory
+	UTXOCacheSize = 10000
+)
+
+// CacheStats reports how many lookups were served by a cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// Get the ratio of lookups served by the cache, 0 if there was no lookup
+func (stats CacheStats) HitRate() float64 {
+	total := stats.Hits + stats.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(stats.Hits) / float64(total)
+}
+
+// lruCache is a least recently used cache, the least recently used item
+// is the first one in the ordered map
+type lruCache struct {
+	sync.Mutex
+	size   int
+	items  *ordered_map.OrderedMap
+	hits   uint64
+	misses uint64
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		items: ordered_map.NewOrderedMap(),
+	}
+}
+
+func (cache *lruCache) get(key string) (interface{}, bool) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	value, ok := cache.items.Get(key)
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+	cache.hits++
+
+	// Move item to the end as the most recently used one
+	cache.items.Delete(key)
+	cache.items.Set(key, value)
+	return value, true
+}
+
+// Put an item into cache, return true if an item was evicted
+func (cache *lruCache) set(key string, value interface{}) bool {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.items.Delete(key)
+	cache.items.Set(key, value)
+
+	if cache.items.Len() <= cache.size {
+		return false
+	}
+
+	iter := cache.items.IterFunc()
+	kv, ok := iter()
+	if ok {
+		cache.items.Delete(kv.Key)
+	}
+	return true
+}
+
+func (cache *lruCache) delete(key string) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.items.Delete(key)
+}
+
+func (cache *lruCache) purge() {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.items = ordered_map.NewOrderedMap()
+}
+
+func (cache *lruCache) resetStats() {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.hits, cache.misses = 0, 0
+}
+
+func (cache *lruCache) stats() CacheStats {
+	cache.Lock()
+	defer cache.Unlock()
+
+	return CacheStats{Hits: cache.hits, Misses: cache.misses}
+}
+
+// utxoCache keeps the wallet UTXOs in memory. While all UTXOs fit in the cache
+// it is complete, and a miss means the outpoint does not belong to the wallet,
+// so the database do not need to be touched.
+type utxoCache struct {
+	*lruCache
+	complete bool
+}
+
+func newUTXOCache(size int) *utxoCache {
+	return &utxoCache{lruCache: newLRUCache(size)}
+}
+
+// Reload cache with all UTXOs in database
+func (cache *utxoCache) load(utxos []*UTXO) {
+	cache.purge()
+	cache.complete = true
+	for _, utxo := range utxos {
+		cache.put(utxo)
+	}
+}
+
+func (cache *utxoCache) put(utxo *UTXO) {
+	if cache.set(string(utxo.Op.Bytes()), utxo) {
+		cache.complete = false
+	}
+}
+
+// Get a UTXO from cache, known is true if the result can be trusted without
+// looking up the database
+func (cache *utxoCache) get(outPoint *tx.OutPoint) (utxo *UTXO, known bool) {
+	value, ok := cache.lruCache.get(string(outPoint.Bytes()))
+	if !ok {
+		return nil, cache.complete
+	}
+	return value.(*UTXO), true
+}
+
+func (cache *utxoCache) remove(outPoint *tx.OutPoint) {
+	cache.delete(string(outPoint.Bytes()))
+}
@@ -10,6 +10,11 @@ type DataStore interface {
 	Info() Info
 	Addrs() Addrs
 	Txs() Txs
+	TxLabels() TxLabels
+	TxPayments() TxPayments
+	ScheduledTxs() ScheduledTxs
+	Journal() Journal
+	KeyAudit() KeyAudit
 	UTXOs() UTXOs
 	STXOs() STXOs
 
@@ -17,9 +22,32 @@ type DataStore interface {
 	// Reset database, clear all data
 	Reset() error
 
+	// Clear chain data including UTXOs, STXOs and transactions,
+	// addresses are preserved
+	ResetChainData() error
+
+	// Flush writes to disk according to the durability level
+	Sync() error
+
+	// Move the damaged database aside to the path and continue with a new one,
+	// the addresses and labels are copied to it if they can be read
+	Quarantine(path string) error
+
 	Close()
 }
 
+// Journal is the append-only log of the events changed the wallet
+type Journal interface {
+	// Append an entry to the journal, the sequence number and time are set to it
+	Append(entry *JournalEntry) error
+
+	// Get up to limit entries after the sequence number in order, 0 gets from the first entry
+	After(seq uint64, limit int) ([]*JournalEntry, error)
+
+	// Get the sequence number of the last entry ever appended, 0 if none
+	LastSeq() (uint64, error)
+}
+
 type Info interface {
 	// get chain height
 	ChainHeight() uint32
@@ -47,6 +75,9 @@ type Addrs interface {
 	// get all addresss from database
 	GetAll() ([]*Addr, error)
 
+	// set the label of an address
+	SetLabel(hash *Uint168, label string) error
+
 	// delete a address from database
 	Delete(hash *Uint168) error
 }
@@ -71,6 +102,55 @@ type Txs interface {
 	Delete(txId *Uint256) error
 }
 
+type TxLabels interface {
+	// Set the label of a transaction, an empty label deletes it
+	Put(txId *Uint256, label *TxLabel) error
+
+	// Get the label of a transaction
+	Get(txId *Uint256) (*TxLabel, error)
+
+	// Get the labels of all transactions by the transaction hash
+	GetAll() (map[Uint256]*TxLabel, error)
+}
+
+type TxPayments interface {
+	// Set the payments of a batch transaction, empty payments delete them
+	Put(txId *Uint256, payments []*Payment) error
+
+	// Get the payments of a batch transaction
+	Get(txId *Uint256) ([]*Payment, error)
+
+	// Get the payments of all batch transactions by the transaction hash
+	GetAll() (map[Uint256][]*Payment, error)
+}
+
+type ScheduledTxs interface {
+	// Put a scheduled transaction to database
+	Put(stx *ScheduledTx) error
+
+	// Get a scheduled transaction by the hash
+	Get(txId *Uint256) (*ScheduledTx, error)
+
+	// Get all scheduled transactions ordered by the lock time
+	GetAll() ([]*ScheduledTx, error)
+
+	// Delete a scheduled transaction, ErrNotFound is returned if it's not in database
+	Delete(txId *Uint256) error
+}
+
+// KeyAudit is the append-only log of the operations on the keys of the wallet
+type KeyAudit interface {
+	// Append an entry to the key audit log, the sequence number and time are set to it
+	Append(entry *KeyAuditEntry) error
+
+	// Get up to limit entries after the sequence number in order, 0 gets from the first entry
+	After(seq uint64, limit int) ([]*KeyAuditEntry, error)
+
+	// Get the count of the failed attempts since the last operation opened the keys succeeded,
+	// and the unix time of the last one
+	Failures() (uint32, int64, error)
+}
+
 type UTXOs interface {
 	// put a utxo to database
 	Put(hash *Uint168, utxo *UTXO) error
@@ -86,6 +166,9 @@ type UTXOs interface {
 
 	// delete a utxo from database
 	Delete(outPoint *tx.OutPoint) error
+
+	// Get the hit rate statistics of the UTXO cache
+	CacheStats() CacheStats
 }
 
 type STXOs interface {
@@ -98,9 +181,16 @@ type STXOs interface {
 	// get stxos of the given address hash from database
 	GetAddrAll(hash *Uint168) ([]*STXO, error)
 
+	// get stxos of the given address hash including the archived ones
+	GetAddrHistory(hash *Uint168) ([]*STXO, error)
+
 	// Get all STXOs in database
 	GetAll() ([]*STXO, error)
 
 	// delete a stxo from database
 	Delete(outPoint *tx.OutPoint) error
+
+	// Archive or drop STXOs spent at or below the given height,
+	// return the count of pruned STXOs
+	Prune(height uint32, policy PrunePolicy) (int, error)
 }
@@ -0,0 +1,59 @@
+package db
+
+// Durability decides when the database writes are flushed to disk
+type Durability uint8
+
+const (
+	// Flush every write to disk, the safest and the most IO consuming level
+	DurabilityAlways Durability = iota
+	// Flush writes to disk once per block, writes of the last block
+	// may be lost on power failure, but the database will not be corrupted
+	DurabilityPerBlock
+	// Never flush writes explicitly and leave it to the OS, suitable for
+	// mobile devices which favor battery and IO
+	DurabilityAsync
+)
+
+// The durability used if nothing configured, server deployments should keep it
+const DefaultDurability = DurabilityAlways
+
+func (d Durability) String() string {
+	switch d {
+	case DurabilityAlways:
+		return "always"
+	case DurabilityPerBlock:
+		return "block"
+	case DurabilityAsync:
+		return "async"
+	default:
+		return "unknown"
+	}
+}
+
+// Get durability by it's name, return DefaultDurability if the name is unknown
+func DurabilityFromString(name string) Durability {
+	switch name {
+	case "always":
+		return DurabilityAlways
+	case "block":
+		return DurabilityPerBlock
+	case "async":
+		return DurabilityAsync
+	default:
+		return DefaultDurability
+	}
+}
+
+// SQLite connection parameters of the durability level, they are applied
+// to every connection opened by the driver
+func (d Durability) sqliteParams() string {
+	switch d {
+	case DurabilityPerBlock:
+		// Commits are written to WAL and synced on checkpoint
+		return "_journal_mode=WAL&_sync=NORMAL"
+	case DurabilityAsync:
+		return "_sync=OFF"
+	default:
+		return "_sync=FULL"
+	}
+}
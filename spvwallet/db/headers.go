@@ -1,18 +1,19 @@
 package db
 
 import (
-	"errors"
+	"bytes"
+	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"math/big"
+	"path/filepath"
 	"sync"
 
 	"github.com/elastos/Elastos.ELA.SPV/common"
 	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 
 	"github.com/boltdb/bolt"
-	"github.com/cevaris/ordered_map"
 )
 
 type Headers interface {
@@ -28,9 +29,33 @@ type Headers interface {
 	// Get the header on chain tip
 	GetTip() (*db.StoreHeader, error)
 
+	// Get the header on the best chain at the height
+	GetByHeight(height uint32) (*db.StoreHeader, error)
+
+	// Save the chain snapshot, replace the previous one
+	PutChainSnapshot(snapshot *db.ChainSnapshot) error
+
+	// Get the saved chain snapshot
+	GetChainSnapshot() (*db.ChainSnapshot, error)
+
+	// Save a block with the matched transactions not fetched yet
+	PutPendingBlock(block *db.PendingBlock) error
+
+	// Delete the pending block of the block hash
+	DelPendingBlock(hash common.Uint256) error
+
+	// Get all pending blocks saved
+	GetPendingBlocks() ([]*db.PendingBlock, error)
+
+	// Get the hit rate statistics of the header cache
+	CacheStats() CacheStats
+
 	// Reset database, clear all data
 	Reset() error
 
+	// Flush writes to disk according to the durability level
+	Sync() error
+
 	// Close db
 	Close()
 }
@@ -39,20 +64,29 @@ type Headers interface {
 type HeadersDB struct {
 	*sync.RWMutex
 	*bolt.DB
-	cache *HeaderCache
+	cache      *HeaderCache
+	durability Durability
 }
 
+const HeadersDBName = "headers.bin"
+
 var (
 	BKTHeaders  = []byte("Headers")
 	BKTChainTip = []byte("ChainTip")
+	BKTHeights  = []byte("Heights")
+	BKTPending  = []byte("Pending")
 	KEYChainTip = []byte("ChainTip")
+
+	KEYChainSnapshot = []byte("ChainSnapshot")
 )
 
-func NewHeadersDB() (Headers, error) {
-	db, err := bolt.Open("headers.bin", 0644, &bolt.Options{InitialMmapSize: 5000000})
+// Open the headers database in the data directory, empty dir means the work directory
+func NewHeadersDB(dir string, durability Durability) (Headers, error) {
+	db, err := bolt.Open(filepath.Join(dir, HeadersDBName), 0644, &bolt.Options{InitialMmapSize: 5000000})
 	if err != nil {
 		return nil, err
 	}
+	db.NoSync = durability != DurabilityAlways
 
 	db.Update(func(btx *bolt.Tx) error {
 		_, err := btx.CreateBucketIfNotExists(BKTHeaders)
@@ -63,20 +97,68 @@ func NewHeadersDB() (Headers, error) {
 		if err != nil {
 			return err
 		}
+		_, err = btx.CreateBucketIfNotExists(BKTHeights)
+		if err != nil {
+			return err
+		}
+		_, err = btx.CreateBucketIfNotExists(BKTPending)
+		if err != nil {
+			return err
+		}
 		return nil
 	})
 
 	headers := &HeadersDB{
 		RWMutex: new(sync.RWMutex),
 		DB:      db,
-		cache:   newHeaderCache(100),
+		cache:      newHeaderCache(HeaderCacheSize),
+		durability: durability,
 	}
 
 	headers.initCache()
+	if err := headers.initHeights(); err != nil {
+		log.Error("Headers db index heights err,", err)
+	}
 
 	return headers, nil
 }
 
+// Index the heights of the best chain headers not indexed yet, a database created
+// before the height index is indexed once by walking back from the tip
+func (h *HeadersDB) initHeights() error {
+	header, err := h.GetTip()
+	if err != nil {
+		return nil
+	}
+	return h.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(BKTHeights)
+		for header.Height > 0 {
+			hash := header.Hash()
+			if bytes.Equal(bucket.Get(heightKey(header.Height)), hash.Bytes()) {
+				return nil
+			}
+			if err := bucket.Put(heightKey(header.Height), hash.Bytes()); err != nil {
+				return err
+			}
+			if header.Height == 1 {
+				return nil
+			}
+			header, err = getHeader(tx, BKTHeaders, header.Previous.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Big endian height as the key of the height index
+func heightKey(height uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, height)
+	return key
+}
+
 func (h *HeadersDB) initCache() {
 	best, err := h.GetTip()
 	if err != nil {
@@ -84,16 +166,18 @@ func (h *HeadersDB) initCache() {
 	}
 	h.cache.tip = best
 	headers := []*db.StoreHeader{best}
-	for i := 0; i < 99; i++ {
-		sh, err := h.GetPrevious(best)
+	for i := 0; i < HeaderCacheSize-1 && best.Height > 1; i++ {
+		best, err = h.GetPrevious(best)
 		if err != nil {
 			break
 		}
-		headers = append(headers, sh)
+		headers = append(headers, best)
 	}
 	for i := len(headers) - 1; i >= 0; i-- {
 		h.cache.Set(headers[i])
 	}
+	// Do not count the lookups of loading cache
+	h.cache.resetStats()
 }
 
 // Add a new header to blockchain
@@ -122,6 +206,10 @@ func (h *HeadersDB) Put(header *db.StoreHeader, newTip bool) error {
 			if err != nil {
 				return err
 			}
+			err = tx.Bucket(BKTHeights).Put(heightKey(header.Height), header.Hash().Bytes())
+			if err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -190,20 +278,180 @@ func (h *HeadersDB) GetTip() (header *db.StoreHeader, err error) {
 	return header, err
 }
 
+// Get the header on the best chain at the height, the index above the tip is left by a
+// reorganize to a shorter chain, it's not on the best chain
+func (h *HeadersDB) GetByHeight(height uint32) (*db.StoreHeader, error) {
+	tip, err := h.GetTip()
+	if err != nil {
+		return nil, err
+	}
+	if height > tip.Height {
+		return nil, errors.Wrapf(errors.ErrNotFound, "Header on height %d does not exist in database", height)
+	}
+	if height == tip.Height {
+		return tip, nil
+	}
+
+	var hash []byte
+	err = h.View(func(tx *bolt.Tx) error {
+		hash = tx.Bucket(BKTHeights).Get(heightKey(height))
+		if hash == nil {
+			return errors.Wrapf(errors.ErrNotFound, "Header on height %d does not exist in database", height)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var headerHash common.Uint256
+	copy(headerHash[:], hash)
+	return h.GetHeader(headerHash)
+}
+
+// Save the chain snapshot, replace the previous one
+func (h *HeadersDB) PutChainSnapshot(snapshot *db.ChainSnapshot) error {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.Update(func(tx *bolt.Tx) error {
+		bytes, err := snapshot.Serialize()
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(BKTChainTip).Put(KEYChainSnapshot, bytes)
+	})
+}
+
+// Get the saved chain snapshot
+func (h *HeadersDB) GetChainSnapshot() (snapshot *db.ChainSnapshot, err error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	err = h.View(func(tx *bolt.Tx) error {
+		bytes := tx.Bucket(BKTChainTip).Get(KEYChainSnapshot)
+		if bytes == nil {
+			return errors.Wrap(errors.ErrNotFound, "chain snapshot does not exist in database")
+		}
+		snapshot = new(db.ChainSnapshot)
+		return snapshot.Deserialize(bytes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Save a block with the matched transactions not fetched yet, replace the one of the same hash
+func (h *HeadersDB) PutPendingBlock(block *db.PendingBlock) error {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.Update(func(tx *bolt.Tx) error {
+		bytes, err := block.Serialize()
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(BKTPending).Put(block.Block.BlockHeader.Hash().Bytes(), bytes)
+	})
+}
+
+// Delete the pending block of the block hash, it's not an error if not exist
+func (h *HeadersDB) DelPendingBlock(hash common.Uint256) error {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTPending).Delete(hash.Bytes())
+	})
+}
+
+// Get all pending blocks saved, the corrupted ones are skipped
+func (h *HeadersDB) GetPendingBlocks() (blocks []*db.PendingBlock, err error) {
+	h.RLock()
+	defer h.RUnlock()
+
+	err = h.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTPending).ForEach(func(key, value []byte) error {
+			block := new(db.PendingBlock)
+			if err := block.Deserialize(value); err != nil {
+				log.Errorf("Decode pending block %s failed, %s", hex.EncodeToString(key), err)
+				return nil
+			}
+			blocks = append(blocks, block)
+			return nil
+		})
+	})
+	return blocks, err
+}
+
+// Get the hit rate statistics of the header cache
+func (h *HeadersDB) CacheStats() CacheStats {
+	return h.cache.stats()
+}
+
 func (h *HeadersDB) Reset() error {
 	h.Lock()
 	defer h.Unlock()
 
+	h.cache.purge()
+	h.cache.tip = nil
+
 	return h.Update(func(tx *bolt.Tx) error {
 		err := tx.DeleteBucket(BKTHeaders)
 		if err != nil {
 			return err
 		}
 
-		return tx.DeleteBucket(BKTChainTip)
+		err = tx.DeleteBucket(BKTChainTip)
+		if err != nil {
+			return err
+		}
+
+		err = tx.DeleteBucket(BKTHeights)
+		if err != nil {
+			return err
+		}
+
+		err = tx.DeleteBucket(BKTPending)
+		if err != nil {
+			return err
+		}
+
+		// Recreate buckets so headers db can be used after reset
+		_, err = tx.CreateBucket(BKTHeaders)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucket(BKTChainTip)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucket(BKTHeights)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.CreateBucket(BKTPending)
+		return err
 	})
 }
 
+// Flush writes to disk if the durability is DurabilityPerBlock
+func (h *HeadersDB) Sync() error {
+	if h.durability != DurabilityPerBlock {
+		return nil
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	return h.DB.Sync()
+}
+
 // Close db
 func (h *HeadersDB) Close() {
 	h.Lock()
@@ -214,57 +462,35 @@ func (h *HeadersDB) Close() {
 func getHeader(tx *bolt.Tx, bucket []byte, key []byte) (*db.StoreHeader, error) {
 	headerBytes := tx.Bucket(bucket).Get(key)
 	if headerBytes == nil {
-		return nil, errors.New(fmt.Sprintf("Header %s does not exist in database", hex.EncodeToString(key)))
+		return nil, errors.Wrapf(errors.ErrNotFound, "Header %s does not exist in database", hex.EncodeToString(key))
 	}
 
 	var header db.StoreHeader
 	err := header.Deserialize(headerBytes)
 	if err != nil {
-		return nil, err
+		return nil, errors.WrapErr(errors.ErrCorrupted, err, "decode header %s failed", hex.EncodeToString(key))
 	}
 
 	return &header, nil
 }
 
 type HeaderCache struct {
-	sync.RWMutex
-	size    int
-	tip     *db.StoreHeader
-	headers *ordered_map.OrderedMap
+	*lruCache
+	tip *db.StoreHeader
 }
 
 func newHeaderCache(size int) *HeaderCache {
-	return &HeaderCache{
-		size:    size,
-		headers: ordered_map.NewOrderedMap(),
-	}
-}
-
-func (cache *HeaderCache) pop() {
-	iter := cache.headers.IterFunc()
-	k, ok := iter()
-	if ok {
-		cache.headers.Delete(k.Key)
-	}
+	return &HeaderCache{lruCache: newLRUCache(size)}
 }
 
 func (cache *HeaderCache) Set(header *db.StoreHeader) {
-	cache.Lock()
-	defer cache.Unlock()
-
-	if cache.headers.Len() > cache.size {
-		cache.pop()
-	}
-	cache.headers.Set(header.Hash().String(), header)
+	cache.set(header.Hash().String(), header)
 }
 
 func (cache *HeaderCache) Get(hash common.Uint256) (*db.StoreHeader, error) {
-	cache.RLock()
-	defer cache.RUnlock()
-
-	sh, ok := cache.headers.Get(hash.String())
+	sh, ok := cache.get(hash.String())
 	if !ok {
-		return nil, errors.New("Header not found in cache ")
+		return nil, errors.Wrap(errors.ErrNotFound, "Header not found in cache ")
 	}
 	return sh.(*db.StoreHeader), nil
 }
@@ -71,7 +71,7 @@ func (db *InfoDB) Get(key string) ([]byte, error) {
 	var value []byte
 	err := row.Scan(&value)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "info %s does not exist in database", key)
 	}
 
 	return value, nil
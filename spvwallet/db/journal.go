@@ -0,0 +1,60 @@
+package db
+
+import (
+	"fmt"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+)
+
+type JournalType uint8
+
+const (
+	// A block connected on the best chain, after the credits and debits of it
+	JournalConnect JournalType = iota + 1
+	// The block on the height disconnected, the credits and debits of it are undone
+	JournalDisconnect
+	// An output paid to a wallet address
+	JournalCredit
+	// An output of the wallet spent
+	JournalDebit
+	// The chain data reset, the state built from the former entries is dropped and rescanned
+	JournalReset
+)
+
+func (t JournalType) String() string {
+	switch t {
+	case JournalConnect:
+		return "connect"
+	case JournalDisconnect:
+		return "disconnect"
+	case JournalCredit:
+		return "credit"
+	case JournalDebit:
+		return "debit"
+	case JournalReset:
+		return "reset"
+	default:
+		return fmt.Sprintf("JournalType(%d)", t)
+	}
+}
+
+/*
+JournalEntry is an event changed the wallet in the journal. The entries are appended in the order
+they happened with increasing sequence numbers never reused, so a downstream system can rebuild the
+wallet state by replaying them and resume after the last sequence number it handled.
+*/
+type JournalEntry struct {
+	Seq    uint64
+	Type   JournalType
+	Height uint32
+	// Hash of the connected block
+	BlockHash Uint256
+	// The transaction paid or spent the output, and the output credited or debited
+	TxId    Uint256
+	Op      tx.OutPoint
+	Address string
+	Value   Fixed64
+	// Unix time the entry appended
+	Time int64
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+)
+
+// The sequence numbers are assigned by AUTOINCREMENT, so they are never reused. The journal is
+// append-only, it's kept when the database is reset and a reset entry is appended instead
+const CreateJournalDB = `CREATE TABLE IF NOT EXISTS Journal(
+				Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+				Type INTEGER NOT NULL,
+				Height INTEGER NOT NULL,
+				BlockHash BLOB NOT NULL,
+				TxId BLOB NOT NULL,
+				OutPoint BLOB NOT NULL,
+				Address TEXT NOT NULL DEFAULT '',
+				Value INTEGER NOT NULL DEFAULT 0,
+				Time INTEGER NOT NULL
+			);`
+
+type JournalDB struct {
+	*sync.RWMutex
+	*sql.DB
+}
+
+func NewJournalDB(db *sql.DB, lock *sync.RWMutex) (Journal, error) {
+	_, err := db.Exec(CreateJournalDB)
+	if err != nil {
+		return nil, err
+	}
+	return &JournalDB{RWMutex: lock, DB: db}, nil
+}
+
+// Append an entry to the journal, the sequence number and time are set to it
+func (db *JournalDB) Append(entry *JournalEntry) error {
+	db.Lock()
+	defer db.Unlock()
+
+	return appendJournal(db.DB, entry)
+}
+
+// Get up to limit entries after the sequence number in order, 0 gets from the first entry
+func (db *JournalDB) After(seq uint64, limit int) ([]*JournalEntry, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query(`SELECT Seq, Type, Height, BlockHash, TxId, OutPoint, Address, Value, Time
+					FROM Journal WHERE Seq>? ORDER BY Seq LIMIT ?`, int64(seq), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*JournalEntry
+	for rows.Next() {
+		var seq, value int64
+		var entryType uint8
+		var blockHash, txId, opBytes []byte
+		entry := new(JournalEntry)
+		err = rows.Scan(&seq, &entryType, &entry.Height, &blockHash, &txId, &opBytes, &entry.Address, &value, &entry.Time)
+		if err != nil {
+			return nil, err
+		}
+		entry.Seq = uint64(seq)
+		entry.Type = JournalType(entryType)
+		entry.Value = Fixed64(value)
+		copy(entry.BlockHash[:], blockHash)
+		copy(entry.TxId[:], txId)
+		if op, err := tx.OutPointFromBytes(opBytes); err == nil {
+			entry.Op = *op
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Get the sequence number of the last entry ever appended, 0 if none
+func (db *JournalDB) LastSeq() (uint64, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	return lastJournalSeq(db.DB)
+}
+
+// The journal is appended in the transactions of the database changes, like the rollback
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func appendJournal(db execer, entry *JournalEntry) error {
+	entry.Time = clock.Now().Unix()
+	result, err := db.Exec(`INSERT INTO Journal(Type, Height, BlockHash, TxId, OutPoint, Address, Value, Time)
+					VALUES(?,?,?,?,?,?,?,?)`, uint8(entry.Type), entry.Height, entry.BlockHash.Bytes(),
+		entry.TxId.Bytes(), entry.Op.Bytes(), entry.Address, int64(entry.Value), entry.Time)
+	if err != nil {
+		return err
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.Seq = uint64(seq)
+	return nil
+}
+
+// The last sequence number is kept by AUTOINCREMENT in the sqlite_sequence table
+func lastJournalSeq(db *sql.DB) (uint64, error) {
+	var seq int64
+	err := db.QueryRow(`SELECT seq FROM sqlite_sequence WHERE name='Journal'`).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return uint64(seq), err
+}
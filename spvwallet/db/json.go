@@ -0,0 +1,96 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+)
+
+// utxoInfo is the JSON view of an UTXO, txid is reversed hex string and value is in ELA like 1.50000000
+type utxoInfo struct {
+	TxID     string `json:"txid"`
+	Index    uint16 `json:"index"`
+	Value    string `json:"value"`
+	LockTime uint32 `json:"locktime"`
+	Height   uint32 `json:"height"`
+}
+
+// stxoInfo is the JSON view of a STXO, the UTXO it used to be with the spending txid and height
+type stxoInfo struct {
+	utxoInfo
+	SpendHeight uint32 `json:"spendheight"`
+	SpendTxID   string `json:"spendtxid"`
+}
+
+func newUTXOInfo(utxo *UTXO) utxoInfo {
+	return utxoInfo{
+		TxID:     BytesToHexString(utxo.Op.TxID.BytesReverse()),
+		Index:    utxo.Op.Index,
+		Value:    utxo.Value.String(),
+		LockTime: utxo.LockTime,
+		Height:   utxo.AtHeight,
+	}
+}
+
+func (info *utxoInfo) toUTXO() (*UTXO, error) {
+	txId, err := Uint256FromReversedHex(info.TxID)
+	if err != nil {
+		return nil, errors.New("invalid utxo txid " + info.TxID)
+	}
+	value, err := StringToFixed64(info.Value)
+	if err != nil {
+		return nil, errors.New("invalid utxo value " + info.Value)
+	}
+	return &UTXO{
+		Op:       *tx.NewOutPoint(*txId, info.Index),
+		Value:    *value,
+		LockTime: info.LockTime,
+		AtHeight: info.Height,
+	}, nil
+}
+
+func (utxo UTXO) MarshalJSON() ([]byte, error) {
+	info := newUTXOInfo(&utxo)
+	return json.Marshal(&info)
+}
+
+func (utxo *UTXO) UnmarshalJSON(data []byte) error {
+	info := new(utxoInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	decoded, err := info.toUTXO()
+	if err != nil {
+		return err
+	}
+	*utxo = *decoded
+	return nil
+}
+
+// The methods of the embedded UTXO would marshal the STXO as an UTXO, the spending is added here
+func (stxo STXO) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&stxoInfo{
+		utxoInfo:    newUTXOInfo(&stxo.UTXO),
+		SpendHeight: stxo.SpendHeight,
+		SpendTxID:   BytesToHexString(stxo.SpendTxId.BytesReverse()),
+	})
+}
+
+func (stxo *STXO) UnmarshalJSON(data []byte) error {
+	info := new(stxoInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return err
+	}
+	utxo, err := info.toUTXO()
+	if err != nil {
+		return err
+	}
+	spendTxId, err := Uint256FromReversedHex(info.SpendTxID)
+	if err != nil {
+		return errors.New("invalid stxo spend txid " + info.SpendTxID)
+	}
+	*stxo = STXO{UTXO: *utxo, SpendHeight: info.SpendHeight, SpendTxId: *spendTxId}
+	return nil
+}
@@ -0,0 +1,64 @@
+package db
+
+// The operations on the keys in the key audit log
+const (
+	KeyOpUnlock         = "unlock"
+	KeyOpLock           = "lock"
+	KeyOpSign           = "sign"
+	KeyOpSignMessage    = "signmessage"
+	KeyOpVerifyPassword = "verifypassword"
+	KeyOpChangePassword = "changepassword"
+	KeyOpNewAccount     = "newaccount"
+	KeyOpImportKey      = "importkey"
+	KeyOpExportKey      = "exportkey"
+	KeyOpMasterKey      = "masterkey"
+)
+
+// The results of the key operations in the key audit log
+const (
+	// The keys are opened by the password
+	KeyResultOK = "ok"
+	// The keys of the unlocked session are used, no password is checked
+	KeyResultUnlocked = "unlocked"
+	// The password is wrong
+	KeyResultFailed = "failed"
+	// No password given while the wallet is locked
+	KeyResultLocked = "locked"
+	// The password is not checked for too many failed attempts
+	KeyResultThrottled = "throttled"
+	// The unlocked session expired
+	KeyResultExpired = "expired"
+)
+
+// Check if the operation is one of the operations on the keys
+func IsKeyOperation(operation string) bool {
+	switch operation {
+	case KeyOpUnlock, KeyOpLock, KeyOpSign, KeyOpSignMessage, KeyOpVerifyPassword, KeyOpChangePassword,
+		KeyOpNewAccount, KeyOpImportKey, KeyOpExportKey, KeyOpMasterKey:
+		return true
+	}
+	return false
+}
+
+// Check if the result is one of the results of the key operations
+func IsKeyResult(result string) bool {
+	switch result {
+	case KeyResultOK, KeyResultUnlocked, KeyResultFailed, KeyResultLocked, KeyResultThrottled, KeyResultExpired:
+		return true
+	}
+	return false
+}
+
+/*
+KeyAuditEntry is an operation on the keys of the wallet in the key audit log, like unlock, lock and
+sign, with where it's from, like the CLI, and the result. The entries are appended in the order they
+happened with increasing sequence numbers never reused, and kept when the database is reset.
+*/
+type KeyAuditEntry struct {
+	Seq uint64
+	// Unix time the operation happened
+	Time      int64
+	Source    string
+	Operation string
+	Result    string
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+)
+
+// The key audit log is append-only like the journal, it's not chain data and is kept when the database is reset
+const CreateKeyAuditDB = `CREATE TABLE IF NOT EXISTS KeyAudit(
+				Seq INTEGER PRIMARY KEY AUTOINCREMENT,
+				Time INTEGER NOT NULL,
+				Source TEXT NOT NULL,
+				Operation TEXT NOT NULL,
+				Result TEXT NOT NULL
+			);`
+
+type KeyAuditDB struct {
+	*sync.RWMutex
+	*sql.DB
+}
+
+func NewKeyAuditDB(db *sql.DB, lock *sync.RWMutex) (KeyAudit, error) {
+	_, err := db.Exec(CreateKeyAuditDB)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyAuditDB{RWMutex: lock, DB: db}, nil
+}
+
+// Append an entry to the key audit log, the sequence number and time are set to it
+func (db *KeyAuditDB) Append(entry *KeyAuditEntry) error {
+	db.Lock()
+	defer db.Unlock()
+
+	entry.Time = clock.Now().Unix()
+	result, err := db.Exec(`INSERT INTO KeyAudit(Time, Source, Operation, Result) VALUES(?,?,?,?)`,
+		entry.Time, entry.Source, entry.Operation, entry.Result)
+	if err != nil {
+		return err
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.Seq = uint64(seq)
+	return nil
+}
+
+// Get up to limit entries after the sequence number in order, 0 gets from the first entry
+func (db *KeyAuditDB) After(seq uint64, limit int) ([]*KeyAuditEntry, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query(`SELECT Seq, Time, Source, Operation, Result FROM KeyAudit
+					WHERE Seq>? ORDER BY Seq LIMIT ?`, int64(seq), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*KeyAuditEntry
+	for rows.Next() {
+		var seq int64
+		entry := new(KeyAuditEntry)
+		err = rows.Scan(&seq, &entry.Time, &entry.Source, &entry.Operation, &entry.Result)
+		if err != nil {
+			return nil, err
+		}
+		entry.Seq = uint64(seq)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Get the count of the failed attempts since the last operation opened the keys succeeded, and the unix time of
+// the last one, locking the wallet needs no password so it does not clear the failures
+func (db *KeyAuditDB) Failures() (uint32, int64, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	var failures uint32
+	var last sql.NullInt64
+	err := db.QueryRow(`SELECT COUNT(*), MAX(Time) FROM KeyAudit WHERE Result=? AND Seq>
+					(SELECT IFNULL(MAX(Seq), 0) FROM KeyAudit WHERE Result=? AND Operation!=?)`,
+		KeyResultFailed, KeyResultOK, KeyOpLock).Scan(&failures, &last)
+	if err != nil {
+		return 0, 0, err
+	}
+	return failures, last.Int64, nil
+}
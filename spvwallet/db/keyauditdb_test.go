@@ -0,0 +1,70 @@
+package db
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+)
+
+func TestKeyAuditFailures(t *testing.T) {
+	sqlDB := openTestDB(t)
+	defer sqlDB.Close()
+	audit, err := NewKeyAuditDB(sqlDB, new(sync.RWMutex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	system := clock.Get()
+	mock := clock.NewMock(time.Unix(1500000000, 0))
+	clock.Set(mock)
+	defer clock.Set(system)
+
+	add := func(operation, result string) {
+		mock.Add(time.Second)
+		if err := audit.Append(&KeyAuditEntry{Source: "cli", Operation: operation, Result: result}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(expect uint32, expectLast int64) {
+		failures, last, err := audit.Failures()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if failures != expect || last != expectLast {
+			t.Errorf("%d failures last at %d, expect %d at %d", failures, last, expect, expectLast)
+		}
+	}
+
+	check(0, 0)
+	add(KeyOpUnlock, KeyResultFailed)
+	add(KeyOpSign, KeyResultFailed)
+	check(2, mock.Now().Unix())
+	lastFailure := mock.Now().Unix()
+
+	// Operations not checking the password do not clear the failures
+	add(KeyOpLock, KeyResultOK)
+	add(KeyOpSign, KeyResultUnlocked)
+	add(KeyOpSign, KeyResultLocked)
+	add(KeyOpLock, KeyResultExpired)
+	check(2, lastFailure)
+	add(KeyOpUnlock, KeyResultThrottled)
+	add(KeyOpSign, KeyResultFailed)
+	check(3, mock.Now().Unix())
+
+	// The password checked succeeded clears them
+	add(KeyOpSign, KeyResultOK)
+	check(0, 0)
+	add(KeyOpExportKey, KeyResultFailed)
+	check(1, mock.Now().Unix())
+
+	entries, err := audit.After(0, 100)
+	if err != nil || len(entries) != 10 {
+		t.Fatalf("%d entries, error %v", len(entries), err)
+	}
+	for i, entry := range entries {
+		if entry.Seq != uint64(i+1) || entry.Time != 1500000000+int64(i+1) {
+			t.Errorf("entry %d sequence %d time %d", i, entry.Seq, entry.Time)
+		}
+	}
+}
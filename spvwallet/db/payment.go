@@ -0,0 +1,11 @@
+package db
+
+import . "github.com/elastos/Elastos.ELA.SPV/common"
+
+// Payment is a recipient paid by a batch transaction, fee is the part of the
+// transaction fee attributed to the payment in proportion to the amount
+type Payment struct {
+	Address string  `json:"address"`
+	Amount  Fixed64 `json:"amount"`
+	Fee     Fixed64 `json:"fee"`
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"os"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+// Key of the path the damaged database moved to, saved in the info of the new database
+// until the chain data is reset and rescanned
+const QuarantinedKey = "Quarantined"
+
+/*
+Move the damaged database aside to the path and continue with a new empty one. The addresses, address
+labels, transaction labels, payments, scheduled transactions and the journal are copied to the new database if they
+can be read from the damaged one, the chain height is kept so the chain continues from the tip, and the
+sequence numbers of the journal continue from the last one.
+*/
+func (db *SQLiteDB) Quarantine(path string) error {
+	height := db.Info().ChainHeight()
+	addrs, err := db.Addrs().GetAll()
+	if err != nil {
+		log.Error("Read addresses of the damaged database failed, ", err)
+	}
+	labels, err := db.TxLabels().GetAll()
+	if err != nil {
+		log.Error("Read transaction labels of the damaged database failed, ", err)
+	}
+	payments, err := db.TxPayments().GetAll()
+	if err != nil {
+		log.Error("Read transaction payments of the damaged database failed, ", err)
+	}
+	scheduled, err := db.ScheduledTxs().GetAll()
+	if err != nil {
+		log.Error("Read scheduled transactions of the damaged database failed, ", err)
+	}
+	lastSeq, err := db.Journal().LastSeq()
+	if err != nil {
+		log.Error("Read journal of the damaged database failed, ", err)
+	}
+
+	if err := db.reopen(path); err != nil {
+		return err
+	}
+	if err := db.copyJournal(path, lastSeq); err != nil {
+		return err
+	}
+
+	db.Info().SaveChainHeight(height)
+	if err := db.Info().Put(QuarantinedKey, []byte(path)); err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := db.Addrs().Put(addr.Hash(), addr.Script(), addr.Type()); err != nil {
+			return err
+		}
+		if addr.Label() != "" {
+			if err := db.Addrs().SetLabel(addr.Hash(), addr.Label()); err != nil {
+				return err
+			}
+		}
+	}
+	for txId, label := range labels {
+		txId := txId
+		if err := db.TxLabels().Put(&txId, label); err != nil {
+			return err
+		}
+	}
+	for txId, txPayments := range payments {
+		txId := txId
+		if err := db.TxPayments().Put(&txId, txPayments); err != nil {
+			return err
+		}
+	}
+	for _, stx := range scheduled {
+		if err := db.ScheduledTxs().Put(stx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close the database, move the files to the path and open a new one,
+// the database is opened again where it is if it can not be moved
+func (db *SQLiteDB) reopen(path string) error {
+	db.Lock()
+	defer db.Unlock()
+
+	db.DB.Close()
+	// The journal files of the WAL mode are moved with the database
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Rename(db.path+suffix, path+suffix); err != nil && !os.IsNotExist(err) {
+			if err := db.open(); err != nil {
+				log.Error("Open the damaged database again failed, ", err)
+			}
+			return err
+		}
+	}
+	db.utxoCache.load(nil)
+	return db.open()
+}
+
+// Copy the journal of the damaged database at the path, or continue the sequence numbers
+// after the last one if it can not be read
+func (db *SQLiteDB) copyJournal(path string, lastSeq uint64) error {
+	db.Lock()
+	defer db.Unlock()
+
+	_, err := db.Exec(`ATTACH DATABASE ? AS damaged;
+						INSERT INTO Journal SELECT * FROM damaged.Journal;
+						DETACH DATABASE damaged;`, path)
+	if err == nil {
+		return nil
+	}
+	log.Error("Copy journal of the damaged database failed, ", err)
+	_, err = db.Exec(`INSERT INTO sqlite_sequence(name, seq) VALUES('Journal', ?)`, int64(lastSeq))
+	return err
+}
@@ -0,0 +1,22 @@
+package db
+
+import tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+
+// A lock time less than the threshold is a block height, otherwise it's a unix time
+const LockTimeThreshold = 500000000
+
+// ScheduledTx is a signed transaction kept in the wallet until the chain passes it's lock time
+type ScheduledTx struct {
+	Tx tx.Transaction
+
+	// Unix time when the transaction was scheduled
+	Created uint32
+}
+
+// Check if the transaction can be broadcasted on the chain of the height and the tip timestamp
+func (stx *ScheduledTx) IsDue(height, timestamp uint32) bool {
+	if stx.Tx.LockTime < LockTimeThreshold {
+		return height >= stx.Tx.LockTime
+	}
+	return timestamp >= stx.Tx.LockTime
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+// Scheduled transactions are kept in their own table, they are not chain data
+// and are not cleared when the chain data is reset
+const CreateScheduledTxsDB = `CREATE TABLE IF NOT EXISTS ScheduledTxs(
+				Hash BLOB NOT NULL PRIMARY KEY,
+				LockTime INTEGER NOT NULL,
+				Created INTEGER NOT NULL,
+				RawData BLOB NOT NULL
+			);`
+
+type ScheduledTxsDB struct {
+	*sync.RWMutex
+	*sql.DB
+}
+
+func NewScheduledTxsDB(db *sql.DB, lock *sync.RWMutex) (ScheduledTxs, error) {
+	_, err := db.Exec(CreateScheduledTxsDB)
+	if err != nil {
+		return nil, err
+	}
+	return &ScheduledTxsDB{RWMutex: lock, DB: db}, nil
+}
+
+// Put a scheduled transaction to database
+func (db *ScheduledTxsDB) Put(stx *ScheduledTx) error {
+	buf := new(bytes.Buffer)
+	if err := stx.Tx.Serialize(buf); err != nil {
+		return err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	_, err := db.Exec(`INSERT OR REPLACE INTO ScheduledTxs(Hash, LockTime, Created, RawData) VALUES(?,?,?,?)`,
+		stx.Tx.Hash().Bytes(), stx.Tx.LockTime, stx.Created, buf.Bytes())
+	return err
+}
+
+// Get a scheduled transaction by the hash
+func (db *ScheduledTxsDB) Get(txId *Uint256) (*ScheduledTx, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	row := db.QueryRow(`SELECT Created, RawData FROM ScheduledTxs WHERE Hash=?`, txId.Bytes())
+	var created uint32
+	var rawData []byte
+	err := row.Scan(&created, &rawData)
+	if err != nil {
+		return nil, notFound(err, "scheduled transaction %s does not exist in database", txId.String())
+	}
+	stx := &ScheduledTx{Created: created}
+	err = stx.Tx.Deserialize(bytes.NewReader(rawData))
+	if err != nil {
+		return nil, err
+	}
+	return stx, nil
+}
+
+// Get all scheduled transactions ordered by the lock time
+func (db *ScheduledTxsDB) GetAll() ([]*ScheduledTx, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query("SELECT Created, RawData FROM ScheduledTxs ORDER BY LockTime, Created")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stxs []*ScheduledTx
+	for rows.Next() {
+		var created uint32
+		var rawData []byte
+		err = rows.Scan(&created, &rawData)
+		if err != nil {
+			return nil, err
+		}
+		stx := &ScheduledTx{Created: created}
+		err = stx.Tx.Deserialize(bytes.NewReader(rawData))
+		if err != nil {
+			return nil, err
+		}
+		stxs = append(stxs, stx)
+	}
+
+	return stxs, nil
+}
+
+// Delete a scheduled transaction, ErrNotFound is returned if it's not in database
+func (db *ScheduledTxsDB) Delete(txId *Uint256) error {
+	db.Lock()
+	defer db.Unlock()
+
+	result, err := db.Exec("DELETE FROM ScheduledTxs WHERE Hash=?", txId.Bytes())
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return notFound(sql.ErrNoRows, "scheduled transaction %s does not exist in database", txId.String())
+	}
+	return nil
+}
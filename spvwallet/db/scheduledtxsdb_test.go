@@ -0,0 +1,112 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+func scheduledTx(lockTime, created uint32) *ScheduledTx {
+	return &ScheduledTx{
+		Tx: tx.Transaction{
+			TxType:   tx.TransferAsset,
+			Payload:  &payload.TransferAsset{},
+			Inputs:   []*tx.Input{{ReferTxID: randHash()}},
+			Outputs:  []*tx.Output{{Value: 100000000, ProgramHash: Uint168{33}}},
+			LockTime: lockTime,
+		},
+		Created: created,
+	}
+}
+
+func TestScheduledTxIsDue(t *testing.T) {
+	// A lock time under the threshold is a block height
+	byHeight := scheduledTx(1000, 0)
+	if byHeight.IsDue(999, LockTimeThreshold+1000) {
+		t.Error("transaction due before the lock height")
+	}
+	if !byHeight.IsDue(1000, 0) {
+		t.Error("transaction not due at the lock height")
+	}
+
+	// Otherwise it's a unix time compared with the tip timestamp
+	byTime := scheduledTx(LockTimeThreshold+1000, 0)
+	if byTime.IsDue(LockTimeThreshold+1000, LockTimeThreshold+999) {
+		t.Error("transaction due before the lock time")
+	}
+	if !byTime.IsDue(0, LockTimeThreshold+1000) {
+		t.Error("transaction not due at the lock time")
+	}
+}
+
+func TestScheduledTxsDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewSQLiteDB(dir, DurabilityAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later, sooner := scheduledTx(2000, 1), scheduledTx(1000, 2)
+	for _, stx := range []*ScheduledTx{later, sooner} {
+		if err := store.ScheduledTxs().Put(stx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	// The schedule survives reopening the database, ordered by the lock time
+	store, err = NewSQLiteDB(dir, DurabilityAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stxs, err := store.ScheduledTxs().GetAll()
+	if err != nil || len(stxs) != 2 {
+		t.Fatalf("scheduled transactions %v after reopened, error %v", stxs, err)
+	}
+	if *stxs[0].Tx.Hash() != *sooner.Tx.Hash() || stxs[0].Created != sooner.Created ||
+		*stxs[1].Tx.Hash() != *later.Tx.Hash() {
+		t.Error("scheduled transactions not read in the lock time order")
+	}
+
+	// A cancel racing the broadcast, both delete the transaction and only one of them gets it
+	txId := sooner.Tx.Hash()
+	var wg sync.WaitGroup
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- store.ScheduledTxs().Delete(txId)
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var deleted, notFound int
+	for err := range results {
+		switch {
+		case err == nil:
+			deleted++
+		case errors.Is(err, errors.ErrNotFound):
+			notFound++
+		default:
+			t.Fatal(err)
+		}
+	}
+	if deleted != 1 || notFound != 1 {
+		t.Errorf("scheduled transaction deleted by %d and not found by %d of the racing deletes", deleted, notFound)
+	}
+	if _, err := store.ScheduledTxs().Get(txId); !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("deleted scheduled transaction error %v, expect not found", err)
+	}
+}
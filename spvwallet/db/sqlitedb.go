@@ -3,8 +3,10 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"path/filepath"
 	"sync"
 
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -19,77 +21,169 @@ type SQLiteDB struct {
 	*sync.RWMutex
 	*sql.DB
 
-	info  Info
-	addrs Addrs
-	txs   Txs
-	utxos UTXOs
-	stxos STXOs
+	info      Info
+	addrs     Addrs
+	txs       Txs
+	txLabels  TxLabels
+	payments  TxPayments
+	scheduled ScheduledTxs
+	journal   Journal
+	keyAudit  KeyAudit
+	utxos     UTXOs
+	stxos     STXOs
+
+	utxoCache  *utxoCache
+	durability Durability
+	// Path of the database file, DBName in the data directory
+	path string
 }
 
-func NewSQLiteDB() (*SQLiteDB, error) {
-	db, err := sql.Open(DriverName, DBName)
+// Open the wallet database in the data directory, empty dir means the work directory
+func NewSQLiteDB(dir string, durability Durability) (*SQLiteDB, error) {
+	db := &SQLiteDB{
+		// Use the same lock
+		RWMutex: new(sync.RWMutex),
+		path:    filepath.Join(dir, DBName),
+		// UTXOs and STXOs share the UTXO cache
+		utxoCache:  newUTXOCache(UTXOCacheSize),
+		durability: durability,
+	}
+	if err := db.open(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Open the database file and create the tables, the lock is held by the caller if the database is in use
+func (db *SQLiteDB) open() error {
+	sqlDB, err := sql.Open(DriverName, db.path+"?"+db.durability.sqliteParams())
 	if err != nil {
 		fmt.Println("Open sqlite db error:", err)
-		return nil, err
+		return err
 	}
-	// Use the same lock
-	lock := new(sync.RWMutex)
 
 	// Create info db
-	infoDB, err := NewInfoDB(db, lock)
+	infoDB, err := NewInfoDB(sqlDB, db.RWMutex)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	// Create addrs db
-	addrsDB, err := NewAddrsDB(db, lock)
+	addrsDB, err := NewAddrsDB(sqlDB, db.RWMutex)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	// Create UTXOs db
-	utxosDB, err := NewUTXOsDB(db, lock)
+	utxosDB, err := NewUTXOsDB(sqlDB, db.RWMutex, db.utxoCache)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	// Create STXOs db
-	stxosDB, err := NewSTXOsDB(db, lock)
+	stxosDB, err := NewSTXOsDB(sqlDB, db.RWMutex, db.utxoCache)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	// Create Txs db
-	txnsDB, err := NewTxsDB(db, lock)
+	txnsDB, err := NewTxsDB(sqlDB, db.RWMutex)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	// Create transaction labels db
+	txLabelsDB, err := NewTxLabelsDB(sqlDB, db.RWMutex)
+	if err != nil {
+		return err
+	}
+	// Create transaction payments db, after the info db the earlier payments are moved from
+	txPaymentsDB, err := NewTxPaymentsDB(sqlDB, db.RWMutex)
+	if err != nil {
+		return err
+	}
+	// Create scheduled transactions db
+	scheduledTxsDB, err := NewScheduledTxsDB(sqlDB, db.RWMutex)
+	if err != nil {
+		return err
+	}
+	// Create journal db
+	journalDB, err := NewJournalDB(sqlDB, db.RWMutex)
+	if err != nil {
+		return err
+	}
+	// Create key audit db
+	keyAuditDB, err := NewKeyAuditDB(sqlDB, db.RWMutex)
+	if err != nil {
+		return err
 	}
 
-	return &SQLiteDB{
-		RWMutex: lock,
-		DB:      db,
-
-		info:  infoDB,
-		addrs: addrsDB,
-		utxos: utxosDB,
-		stxos: stxosDB,
-		txs:   txnsDB,
-	}, nil
+	db.DB = sqlDB
+	db.info = infoDB
+	db.addrs = addrsDB
+	db.utxos = utxosDB
+	db.stxos = stxosDB
+	db.txs = txnsDB
+	db.txLabels = txLabelsDB
+	db.payments = txPaymentsDB
+	db.scheduled = scheduledTxsDB
+	db.journal = journalDB
+	db.keyAudit = keyAuditDB
+	return nil
 }
 
 func (db *SQLiteDB) Info() Info {
+	db.RLock()
+	defer db.RUnlock()
 	return db.info
 }
 
 func (db *SQLiteDB) Addrs() Addrs {
+	db.RLock()
+	defer db.RUnlock()
 	return db.addrs
 }
 
 func (db *SQLiteDB) Txs() Txs {
+	db.RLock()
+	defer db.RUnlock()
 	return db.txs
 }
 
+func (db *SQLiteDB) TxLabels() TxLabels {
+	db.RLock()
+	defer db.RUnlock()
+	return db.txLabels
+}
+
+func (db *SQLiteDB) TxPayments() TxPayments {
+	db.RLock()
+	defer db.RUnlock()
+	return db.payments
+}
+
+func (db *SQLiteDB) ScheduledTxs() ScheduledTxs {
+	db.RLock()
+	defer db.RUnlock()
+	return db.scheduled
+}
+
+func (db *SQLiteDB) Journal() Journal {
+	db.RLock()
+	defer db.RUnlock()
+	return db.journal
+}
+
+func (db *SQLiteDB) KeyAudit() KeyAudit {
+	db.RLock()
+	defer db.RUnlock()
+	return db.keyAudit
+}
+
 func (db *SQLiteDB) UTXOs() UTXOs {
+	db.RLock()
+	defer db.RUnlock()
 	return db.utxos
 }
 
 func (db *SQLiteDB) STXOs() STXOs {
+	db.RLock()
+	defer db.RUnlock()
 	return db.stxos
 }
 
@@ -131,7 +225,29 @@ func (db *SQLiteDB) Rollback(height uint32) error {
 		return err
 	}
 
-	return tx.Commit()
+	err = appendJournal(tx, &JournalEntry{Type: JournalDisconnect, Height: height})
+	if err != nil {
+		return err
+	}
+
+	// Reload UTXO cache
+	rows, err := tx.Query("SELECT OutPoint, Value, LockTime, AtHeight FROM UTXOs")
+	if err != nil {
+		return err
+	}
+	utxos, err := getUTXOs(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	db.utxoCache.load(utxos)
+
+	return nil
 }
 
 func (db *SQLiteDB) Reset() error {
@@ -144,13 +260,79 @@ func (db *SQLiteDB) Reset() error {
 	_, err = tx.Exec(`DROP TABLE IF EXISTS Info;
 							DROP TABLE IF EXISTS UTXOs;
 							DROP TABLE IF EXISTS STXOs;
+							DROP TABLE IF EXISTS ArchivedSTXOs;
 							DROP TABLE IF EXISTS TXNs;
 							DROP TABLE IF EXISTS Queue;`)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	err = appendJournal(tx, &JournalEntry{Type: JournalReset})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	db.utxoCache.load(nil)
+
+	return nil
+}
+
+// Clear chain data including UTXOs, STXOs and transactions, addresses are preserved
+func (db *SQLiteDB) ResetChainData() error {
+	db.Lock()
+	defer db.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM UTXOs;
+						DELETE FROM STXOs;
+						DELETE FROM ArchivedSTXOs;
+						DELETE FROM TXNs;`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The chain data rescanned is not damaged any more
+	_, err = tx.Exec("DELETE FROM Info WHERE Key IN (?,?)", ChainHeightKey, QuarantinedKey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = appendJournal(tx, &JournalEntry{Type: JournalReset})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	db.utxoCache.load(nil)
+
+	return nil
+}
+
+// Flush writes to disk if the durability is DurabilityPerBlock
+func (db *SQLiteDB) Sync() error {
+	if db.durability != DurabilityPerBlock {
+		return nil
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	_, err := db.Exec("PRAGMA wal_checkpoint(PASSIVE);")
+	return err
 }
 
 func (db *SQLiteDB) Close() {
@@ -158,3 +340,11 @@ func (db *SQLiteDB) Close() {
 	db.DB.Close()
 	log.Debug("SQLite DB closed")
 }
+
+// Return ErrNotFound with the message for the no rows error of a query, other errors are returned as they are
+func notFound(err error, format string, args ...interface{}) error {
+	if err == sql.ErrNoRows {
+		return errors.Wrapf(errors.ErrNotFound, format, args...)
+	}
+	return err
+}
@@ -2,9 +2,49 @@ package db
 
 import (
 	"fmt"
+	"io"
+
 	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/common/serialization"
+)
+
+// The policy applied to STXOs which have been buried deep enough
+type PrunePolicy uint8
+
+const (
+	// Keep STXOs in the STXOs table forever
+	PruneNone PrunePolicy = iota
+	// Move STXOs into the compressed archive table
+	PruneArchive
+	// Drop STXOs, history of them will be lost
+	PruneDrop
 )
 
+func (policy PrunePolicy) String() string {
+	switch policy {
+	case PruneNone:
+		return "none"
+	case PruneArchive:
+		return "archive"
+	case PruneDrop:
+		return "drop"
+	default:
+		return "unknown"
+	}
+}
+
+// Get prune policy by it's name, return PruneNone if the name is unknown, the config loader refuses unknown names
+func PrunePolicyFromString(name string) PrunePolicy {
+	switch name {
+	case "archive":
+		return PruneArchive
+	case "drop":
+		return PruneDrop
+	default:
+		return PruneNone
+	}
+}
+
 type STXO struct {
 	// When it used to be a UTXO
 	UTXO
@@ -46,3 +86,14 @@ func (stxo *STXO) IsEqual(alt *STXO) bool {
 
 	return true
 }
+
+// Serialize the STXO fields except the outpoint, which is used as the record key
+func (stxo *STXO) Serialize(w io.Writer) error {
+	return serialization.WriteElements(w, stxo.Value, stxo.LockTime, stxo.AtHeight,
+		stxo.SpendTxId, stxo.SpendHeight)
+}
+
+func (stxo *STXO) Deserialize(r io.Reader) error {
+	return serialization.ReadElements(r, &stxo.Value, &stxo.LockTime, &stxo.AtHeight,
+		&stxo.SpendTxId, &stxo.SpendHeight)
+}
@@ -1,11 +1,15 @@
 package db
 
 import (
+	"bytes"
+	"compress/zlib"
 	"database/sql"
+	"io/ioutil"
 	"sync"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
 const CreateSTXOsDB = `CREATE TABLE IF NOT EXISTS STXOs(
@@ -18,17 +22,37 @@ const CreateSTXOsDB = `CREATE TABLE IF NOT EXISTS STXOs(
 				ScriptHash BLOB NOT NULL
 			);`
 
+// STXOs pruned by PruneArchive policy, Data is the zlib compressed STXO
+const CreateArchivedSTXOsDB = `CREATE TABLE IF NOT EXISTS ArchivedSTXOs(
+				OutPoint BLOB NOT NULL PRIMARY KEY,
+				SpendHeight INTEGER NOT NULL,
+				ScriptHash BLOB NOT NULL,
+				Data BLOB NOT NULL
+			);`
+
+// STXOs are pruned by the spend height on every block, the index keeps it a range seek
+const CreateSTXOsIndexes = `CREATE INDEX IF NOT EXISTS STXOsSpendHeight ON STXOs(SpendHeight);`
+
 type STXOsDB struct {
 	*sync.RWMutex
 	*sql.DB
+	utxos *utxoCache
 }
 
-func NewSTXOsDB(db *sql.DB, lock *sync.RWMutex) (STXOs, error) {
+func NewSTXOsDB(db *sql.DB, lock *sync.RWMutex, utxos *utxoCache) (STXOs, error) {
 	_, err := db.Exec(CreateSTXOsDB)
 	if err != nil {
 		return nil, err
 	}
-	return &STXOsDB{RWMutex: lock, DB: db}, nil
+	_, err = db.Exec(CreateSTXOsIndexes)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(CreateArchivedSTXOsDB)
+	if err != nil {
+		return nil, err
+	}
+	return &STXOsDB{RWMutex: lock, DB: db, utxos: utxos}, nil
 }
 
 // Move a UTXO to STXO
@@ -36,6 +60,11 @@ func (db *STXOsDB) FromUTXO(outPoint *tx.OutPoint, spendTxId *Uint256, spendHeig
 	db.Lock()
 	defer db.Unlock()
 
+	// Not a wallet UTXO, nothing to move
+	if utxo, known := db.utxos.get(outPoint); known && utxo == nil {
+		return errors.Wrapf(errors.ErrNotFound, "UTXO %s:%d does not exist in database", outPoint.TxID.String(), outPoint.Index)
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -60,7 +89,13 @@ func (db *STXOsDB) FromUTXO(outPoint *tx.OutPoint, spendTxId *Uint256, spendHeig
 		return err
 	}
 
-	return tx.Commit()
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	db.utxos.remove(outPoint)
+
+	return nil
 }
 
 // get a stxo from database
@@ -68,14 +103,18 @@ func (db *STXOsDB) Get(outPoint *tx.OutPoint) (*STXO, error) {
 	db.RLock()
 	defer db.RUnlock()
 
-	sql := `SELECT Value, LockTime, AtHeight, SpendHash, SpendHeight FROM STXOs WHERE OutPoint=?`
-	row := db.QueryRow(sql, outPoint.Bytes())
+	query := `SELECT Value, LockTime, AtHeight, SpendHash, SpendHeight FROM STXOs WHERE OutPoint=?`
+	row := db.QueryRow(query, outPoint.Bytes())
 	var valueBytes []byte
 	var lockTime uint32
 	var atHeight uint32
 	var spendHashBytes []byte
 	var spendHeight uint32
 	err := row.Scan(&valueBytes, &lockTime, &atHeight, &spendHashBytes, &spendHeight)
+	if err == sql.ErrNoRows {
+		// Fall back to the archive
+		return db.getArchived(outPoint)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -110,6 +149,51 @@ func (db *STXOsDB) GetAddrAll(hash *Uint168) ([]*STXO, error) {
 	return db.getSTXOs(rows)
 }
 
+// get stxos of the given script hash including the archived ones
+func (db *STXOsDB) GetAddrHistory(hash *Uint168) ([]*STXO, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	query := "SELECT OutPoint, Value, LockTime, AtHeight, SpendHash, SpendHeight FROM STXOs WHERE ScriptHash=?"
+	rows, err := db.Query(query, hash.ToArray())
+	if err != nil {
+		return []*STXO{}, err
+	}
+	defer rows.Close()
+
+	stxos, err := db.getSTXOs(rows)
+	if err != nil {
+		return stxos, err
+	}
+
+	archived, err := db.Query("SELECT OutPoint, Data FROM ArchivedSTXOs WHERE ScriptHash=?", hash.ToArray())
+	if err != nil {
+		return stxos, err
+	}
+	defer archived.Close()
+
+	for archived.Next() {
+		var opBytes []byte
+		var data []byte
+		err := archived.Scan(&opBytes, &data)
+		if err != nil {
+			return stxos, err
+		}
+
+		outPoint, err := tx.OutPointFromBytes(opBytes)
+		if err != nil {
+			return stxos, err
+		}
+		stxo, err := decompressSTXO(outPoint, data)
+		if err != nil {
+			return stxos, err
+		}
+		stxos = append(stxos, stxo)
+	}
+
+	return stxos, nil
+}
+
 func (db *STXOsDB) GetAll() ([]*STXO, error) {
 	db.RLock()
 	defer db.RUnlock()
@@ -174,3 +258,150 @@ func (db *STXOsDB) Delete(outPoint *tx.OutPoint) error {
 
 	return nil
 }
+
+// Archive or drop STXOs spent at or below the given height
+func (db *STXOsDB) Prune(height uint32, policy PrunePolicy) (int, error) {
+	if policy == PruneNone {
+		return 0, nil
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	if policy == PruneArchive {
+		rows, err := tx.Query(`SELECT OutPoint, Value, LockTime, AtHeight, SpendHash, SpendHeight, ScriptHash
+				FROM STXOs WHERE SpendHeight<=?`, height)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		type archive struct {
+			outPoint   []byte
+			scriptHash []byte
+			stxo       *STXO
+		}
+		var archives []archive
+		for rows.Next() {
+			var opBytes []byte
+			var valueBytes []byte
+			var lockTime uint32
+			var atHeight uint32
+			var spendHashBytes []byte
+			var spendHeight uint32
+			var scriptHash []byte
+			var value *Fixed64
+			var spendHash *Uint256
+			err = rows.Scan(&opBytes, &valueBytes, &lockTime, &atHeight, &spendHashBytes, &spendHeight, &scriptHash)
+			if err != nil {
+				break
+			}
+			value, err = Fixed64FromBytes(valueBytes)
+			if err != nil {
+				break
+			}
+			spendHash, err = Uint256FromBytes(spendHashBytes)
+			if err != nil {
+				break
+			}
+			stxo := &STXO{
+				UTXO:        UTXO{Value: *value, LockTime: lockTime, AtHeight: atHeight},
+				SpendTxId:   *spendHash,
+				SpendHeight: spendHeight,
+			}
+			archives = append(archives, archive{outPoint: opBytes, scriptHash: scriptHash, stxo: stxo})
+		}
+		rows.Close()
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		stmt, err := tx.Prepare(`INSERT OR REPLACE INTO ArchivedSTXOs(OutPoint, SpendHeight, ScriptHash, Data)
+				VALUES(?,?,?,?)`)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		defer stmt.Close()
+
+		for _, a := range archives {
+			data, err := compressSTXO(a.stxo)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			_, err = stmt.Exec(a.outPoint, a.stxo.SpendHeight, a.scriptHash, data)
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM STXOs WHERE SpendHeight<=?", height)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	pruned, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return int(pruned), tx.Commit()
+}
+
+// get an archived stxo, must be called with the lock held
+func (db *STXOsDB) getArchived(outPoint *tx.OutPoint) (*STXO, error) {
+	var data []byte
+	row := db.QueryRow("SELECT Data FROM ArchivedSTXOs WHERE OutPoint=?", outPoint.Bytes())
+	err := row.Scan(&data)
+	if err != nil {
+		return nil, notFound(err, "STXO %s:%d does not exist in database", outPoint.TxID.String(), outPoint.Index)
+	}
+
+	return decompressSTXO(outPoint, data)
+}
+
+func compressSTXO(stxo *STXO) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := zlib.NewWriter(buf)
+	err := stxo.Serialize(writer)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressSTXO(outPoint *tx.OutPoint, data []byte) (*STXO, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	stxo := new(STXO)
+	err = stxo.Deserialize(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	stxo.Op = *outPoint
+
+	return stxo, nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Open an in-memory database for the tests, on one connection so all the statements see the same database
+func openTestDB(t *testing.T) *sql.DB {
+	sqlDB, err := sql.Open(DriverName, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return sqlDB
+}
+
+func newTestSTXOs(t *testing.T) (UTXOs, STXOs) {
+	sqlDB := openTestDB(t)
+	lock := new(sync.RWMutex)
+	cache := newUTXOCache(UTXOCacheSize)
+	utxos, err := NewUTXOsDB(sqlDB, lock, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stxos, err := NewSTXOsDB(sqlDB, lock, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return utxos, stxos
+}
+
+// Spend a new UTXO of the address at the spend height
+func spendUTXO(t *testing.T, utxos UTXOs, stxos STXOs, hash *Uint168, spendHeight uint32) *tx.OutPoint {
+	utxo := &UTXO{Op: *tx.NewOutPoint(randHash(), 0), Value: 100000000, AtHeight: spendHeight - 1}
+	if err := utxos.Put(hash, utxo); err != nil {
+		t.Fatal(err)
+	}
+	spendTxId := randHash()
+	if err := stxos.FromUTXO(&utxo.Op, &spendTxId, spendHeight); err != nil {
+		t.Fatal(err)
+	}
+	return &utxo.Op
+}
+
+func TestSTXOsDB_Prune(t *testing.T) {
+	for _, policy := range []PrunePolicy{PruneNone, PruneArchive, PruneDrop} {
+		utxos, stxos := newTestSTXOs(t)
+		hash := &Uint168{33}
+		var ops []*tx.OutPoint
+		for height := uint32(10); height <= 50; height += 10 {
+			ops = append(ops, spendUTXO(t, utxos, stxos, hash, height))
+		}
+
+		pruned, err := stxos.Prune(30, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect := 3
+		if policy == PruneNone {
+			expect = 0
+		}
+		if pruned != expect {
+			t.Errorf("policy %s pruned %d STXOs, expect %d", policy, pruned, expect)
+		}
+
+		// STXOs spent above the height are kept
+		all, err := stxos.GetAddrAll(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 5-expect {
+			t.Errorf("policy %s kept %d STXOs, expect %d", policy, len(all), 5-expect)
+		}
+
+		// Pruned STXOs are found in the archive, or not at all if dropped
+		for i, op := range ops {
+			stxo, err := stxos.Get(op)
+			if policy == PruneDrop && i < 3 {
+				if !errors.Is(err, errors.ErrNotFound) {
+					t.Errorf("dropped STXO %d got %v, expect not found", i, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("policy %s STXO %d, %s", policy, i, err)
+			}
+			if stxo.SpendHeight != uint32(i+1)*10 || stxo.Value != 100000000 || stxo.Op != *op {
+				t.Errorf("policy %s STXO %d got %+v", policy, i, stxo)
+			}
+		}
+
+		history, err := stxos.GetAddrHistory(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expect = 5
+		if policy == PruneDrop {
+			expect = 2
+		}
+		if len(history) != expect {
+			t.Errorf("policy %s address history of %d STXOs, expect %d", policy, len(history), expect)
+		}
+	}
+}
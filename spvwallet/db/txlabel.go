@@ -0,0 +1,50 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// TxLabel is the bookkeeping data assigned to a transaction by the user
+type TxLabel struct {
+	Label    string   `json:"label"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
+}
+
+// Check if the label, category and tags are all empty
+func (label *TxLabel) IsEmpty() bool {
+	return label.Label == "" && label.Category == "" && len(label.Tags) == 0
+}
+
+// Check if the transaction is tagged with the tag, case insensitive
+func (label *TxLabel) HasTag(tag string) bool {
+	for _, t := range label.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Trim the spaces of the category and tags and remove the empty and duplicated tags,
+// a tag can not contain a comma
+func (label *TxLabel) normalize() error {
+	label.Category = strings.TrimSpace(label.Category)
+	tags := make([]string, 0, len(label.Tags))
+	seen := make(map[string]bool)
+	for _, tag := range label.Tags {
+		tag = strings.TrimSpace(tag)
+		if strings.Contains(tag, ",") {
+			return errors.Wrapf(errors.ErrInvalid, "tag %q contains a comma", tag)
+		}
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	label.Tags = tags
+	return nil
+}
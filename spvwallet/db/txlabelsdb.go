@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+)
+
+// Labels are kept in their own table, so they are not lost when the transactions are
+// rolled back or the chain data is reset and the transactions are committed again
+const CreateTxLabelsDB = `CREATE TABLE IF NOT EXISTS TxLabels(
+				Hash BLOB NOT NULL PRIMARY KEY,
+				Label TEXT NOT NULL DEFAULT '',
+				Category TEXT NOT NULL DEFAULT '',
+				Tags TEXT NOT NULL DEFAULT ''
+			);`
+
+type TxLabelsDB struct {
+	*sync.RWMutex
+	*sql.DB
+}
+
+func NewTxLabelsDB(db *sql.DB, lock *sync.RWMutex) (TxLabels, error) {
+	_, err := db.Exec(CreateTxLabelsDB)
+	if err != nil {
+		return nil, err
+	}
+	return &TxLabelsDB{RWMutex: lock, DB: db}, nil
+}
+
+// Set the label of a transaction, an empty label deletes it
+func (db *TxLabelsDB) Put(txId *Uint256, label *TxLabel) error {
+	normalized := *label
+	if err := normalized.normalize(); err != nil {
+		return err
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	if normalized.IsEmpty() {
+		_, err := db.Exec("DELETE FROM TxLabels WHERE Hash=?", txId.Bytes())
+		return err
+	}
+	_, err := db.Exec(`INSERT OR REPLACE INTO TxLabels(Hash, Label, Category, Tags) VALUES(?,?,?,?)`,
+		txId.Bytes(), normalized.Label, normalized.Category, strings.Join(normalized.Tags, ","))
+	return err
+}
+
+// Get the label of a transaction
+func (db *TxLabelsDB) Get(txId *Uint256) (*TxLabel, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	row := db.QueryRow(`SELECT Label, Category, Tags FROM TxLabels WHERE Hash=?`, txId.Bytes())
+	var label TxLabel
+	var tags string
+	err := row.Scan(&label.Label, &label.Category, &tags)
+	if err != nil {
+		return nil, notFound(err, "label of transaction %s does not exist in database", txId.String())
+	}
+	label.Tags = splitTags(tags)
+	return &label, nil
+}
+
+// Get the labels of all transactions by the transaction hash
+func (db *TxLabelsDB) GetAll() (map[Uint256]*TxLabel, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query("SELECT Hash, Label, Category, Tags FROM TxLabels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := make(map[Uint256]*TxLabel)
+	for rows.Next() {
+		var hashBytes []byte
+		var label TxLabel
+		var tags string
+		err = rows.Scan(&hashBytes, &label.Label, &label.Category, &tags)
+		if err != nil {
+			return nil, err
+		}
+		txId, err := Uint256FromBytes(hashBytes)
+		if err != nil {
+			return nil, err
+		}
+		label.Tags = splitTags(tags)
+		labels[*txId] = &label
+	}
+
+	return labels, nil
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
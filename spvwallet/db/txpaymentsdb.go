@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Payments of the batch transactions are kept in their own table like the labels, so they are
+// not lost when the transactions are rolled back or the chain data is reset
+const CreateTxPaymentsDB = `CREATE TABLE IF NOT EXISTS TxPayments(
+				Hash BLOB NOT NULL,
+				Seq INTEGER NOT NULL,
+				Address TEXT NOT NULL,
+				Amount INTEGER NOT NULL,
+				Fee INTEGER NOT NULL,
+				PRIMARY KEY(Hash, Seq)
+			);`
+
+// Prefix of the payments stored in the info table by the earlier versions, moved to the table on open
+const legacyPaymentsKeyPrefix = "payments_"
+
+type TxPaymentsDB struct {
+	*sync.RWMutex
+	*sql.DB
+}
+
+func NewTxPaymentsDB(db *sql.DB, lock *sync.RWMutex) (TxPayments, error) {
+	_, err := db.Exec(CreateTxPaymentsDB)
+	if err != nil {
+		return nil, err
+	}
+	payments := &TxPaymentsDB{RWMutex: lock, DB: db}
+	if err := payments.migrate(); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// Move the payments stored in the info table to the table
+func (db *TxPaymentsDB) migrate() error {
+	rows, err := db.Query("SELECT Key, Value FROM Info WHERE substr(Key, 1, ?)=?",
+		len(legacyPaymentsKeyPrefix), legacyPaymentsKeyPrefix)
+	if err != nil {
+		return err
+	}
+	legacy := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		legacy[key] = value
+	}
+	rows.Close()
+
+	for key, value := range legacy {
+		hashBytes, err := HexStringToBytes(strings.TrimPrefix(key, legacyPaymentsKeyPrefix))
+		if err != nil {
+			return err
+		}
+		txId, err := Uint256FromBytes(hashBytes)
+		if err != nil {
+			return err
+		}
+		var payments []*Payment
+		if err := json.Unmarshal(value, &payments); err != nil {
+			return err
+		}
+		if err := db.Put(txId, payments); err != nil {
+			return err
+		}
+		if _, err := db.Exec("DELETE FROM Info WHERE Key=?", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Set the payments of a transaction, empty payments delete them
+func (db *TxPaymentsDB) Put(txId *Uint256, payments []*Payment) error {
+	db.Lock()
+	defer db.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM TxPayments WHERE Hash=?", txId.Bytes())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, payment := range payments {
+		_, err = tx.Exec(`INSERT INTO TxPayments(Hash, Seq, Address, Amount, Fee) VALUES(?,?,?,?,?)`,
+			txId.Bytes(), i, payment.Address, int64(payment.Amount), int64(payment.Fee))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Get the payments of a transaction in the order they were set
+func (db *TxPaymentsDB) Get(txId *Uint256) ([]*Payment, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query(`SELECT Address, Amount, Fee FROM TxPayments WHERE Hash=? ORDER BY Seq`, txId.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*Payment
+	for rows.Next() {
+		var payment Payment
+		err = rows.Scan(&payment.Address, &payment.Amount, &payment.Fee)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, &payment)
+	}
+	if len(payments) == 0 {
+		return nil, errors.Wrapf(errors.ErrNotFound, "payments of transaction %s do not exist in database", txId.String())
+	}
+	return payments, nil
+}
+
+// Get the payments of all transactions by the transaction hash
+func (db *TxPaymentsDB) GetAll() (map[Uint256][]*Payment, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	rows, err := db.Query("SELECT Hash, Address, Amount, Fee FROM TxPayments ORDER BY Hash, Seq")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make(map[Uint256][]*Payment)
+	for rows.Next() {
+		var hashBytes []byte
+		var payment Payment
+		err = rows.Scan(&hashBytes, &payment.Address, &payment.Amount, &payment.Fee)
+		if err != nil {
+			return nil, err
+		}
+		txId, err := Uint256FromBytes(hashBytes)
+		if err != nil {
+			return nil, err
+		}
+		payments[*txId] = append(payments[*txId], &payment)
+	}
+	return payments, nil
+}
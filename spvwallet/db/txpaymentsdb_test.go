@@ -0,0 +1,68 @@
+package db
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+func TestTxPaymentsDB(t *testing.T) {
+	sqlDB := openTestDB(t)
+	defer sqlDB.Close()
+	lock := new(sync.RWMutex)
+	info, err := NewInfoDB(sqlDB, lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Payments stored in the info table are moved to the table
+	legacyId := randHash()
+	legacy := []*Payment{{Address: "ETBBrgotZy3993o9bH75KxjLDgQxBCib6u", Amount: 100, Fee: 1}}
+	data, _ := json.Marshal(legacy)
+	if err := info.Put(legacyPaymentsKeyPrefix+legacyId.String(), data); err != nil {
+		t.Fatal(err)
+	}
+	payments, err := NewTxPaymentsDB(sqlDB, lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := payments.Get(&legacyId); err != nil || len(got) != 1 || *got[0] != *legacy[0] {
+		t.Fatalf("migrated payments %v, error %v", got, err)
+	}
+	if _, err := info.Get(legacyPaymentsKeyPrefix + legacyId.String()); err == nil {
+		t.Error("migrated payments left in the info table")
+	}
+
+	// Payments are read in the order set, and replaced by the next set
+	txId := randHash()
+	set := []*Payment{
+		{Address: "EUyNwnAh5SzzTtAPV1HkXzjUEbw2YqKsUM", Amount: 300, Fee: 3},
+		{Address: "ETBBrgotZy3993o9bH75KxjLDgQxBCib6u", Amount: 200, Fee: 2},
+	}
+	if err := payments.Put(&txId, set); err != nil {
+		t.Fatal(err)
+	}
+	got, err := payments.Get(&txId)
+	if err != nil || len(got) != 2 || *got[0] != *set[0] || *got[1] != *set[1] {
+		t.Fatalf("payments %v, error %v", got, err)
+	}
+	if err := payments.Put(&txId, set[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := payments.Get(&txId); len(got) != 1 || *got[0] != *set[1] {
+		t.Errorf("payments %v not replaced", got)
+	}
+	if all, err := payments.GetAll(); err != nil || len(all) != 2 || len(all[txId]) != 1 {
+		t.Errorf("all payments %v, error %v", all, err)
+	}
+
+	// Empty payments delete them
+	if err := payments.Put(&txId, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Get(&txId); !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("deleted payments error %v, expect not found", err)
+	}
+}
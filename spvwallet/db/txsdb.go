@@ -9,6 +9,7 @@ import (
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
 const CreateTXNDB = `CREATE TABLE IF NOT EXISTS TXNs(
@@ -35,18 +36,15 @@ func (t *TxsDB) Put(storeTx *db.StoreTx) error {
 	t.Lock()
 	defer t.Unlock()
 
-	stmt, err := t.Prepare(`INSERT OR REPLACE INTO TXNs(Hash, Height, RawData) VALUES(?,?,?)`)
-	if err != nil {
-		return err
-	}
-
 	buf := new(bytes.Buffer)
-	err = storeTx.Data.SerializeUnsigned(buf)
+	err := storeTx.Data.SerializeUnsigned(buf)
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(storeTx.TxId.Bytes(), storeTx.Height, buf.Bytes())
+	// Exec directly, a prepared statement per put is never reused and leaks until closed
+	_, err = t.Exec(`INSERT OR REPLACE INTO TXNs(Hash, Height, RawData) VALUES(?,?,?)`,
+		storeTx.TxId.Bytes(), storeTx.Height, buf.Bytes())
 	if err != nil {
 		return err
 	}
@@ -64,12 +62,12 @@ func (t *TxsDB) Get(txId *Uint256) (*db.StoreTx, error) {
 	var rawData []byte
 	err := row.Scan(&height, &rawData)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "transaction %s does not exist in database", txId.String())
 	}
 	var tx tx.Transaction
 	err = tx.DeserializeUnsigned(bytes.NewReader(rawData))
 	if err != nil {
-		return nil, err
+		return nil, errors.WrapErr(errors.ErrCorrupted, err, "decode transaction %s failed", txId.String())
 	}
 
 	return &db.StoreTx{TxId: *txId, Height: height, Data: tx}, nil
@@ -6,6 +6,7 @@ import (
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 )
 
 const CreateUTXOsDB = `CREATE TABLE IF NOT EXISTS UTXOs(
@@ -19,14 +20,23 @@ const CreateUTXOsDB = `CREATE TABLE IF NOT EXISTS UTXOs(
 type UTXOsDB struct {
 	*sync.RWMutex
 	*sql.DB
+	cache *utxoCache
 }
 
-func NewUTXOsDB(db *sql.DB, lock *sync.RWMutex) (UTXOs, error) {
+func NewUTXOsDB(db *sql.DB, lock *sync.RWMutex, cache *utxoCache) (UTXOs, error) {
 	_, err := db.Exec(CreateUTXOsDB)
 	if err != nil {
 		return nil, err
 	}
-	return &UTXOsDB{RWMutex: lock, DB: db}, nil
+	utxosDB := &UTXOsDB{RWMutex: lock, DB: db, cache: cache}
+
+	utxos, err := utxosDB.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	cache.load(utxos)
+
+	return utxosDB, nil
 }
 
 // put a utxo to database
@@ -34,21 +44,18 @@ func (db *UTXOsDB) Put(hash *Uint168, utxo *UTXO) error {
 	db.Lock()
 	defer db.Unlock()
 
-	stmt, err := db.Prepare(`INSERT OR REPLACE INTO UTXOs(OutPoint, Value, LockTime, AtHeight, ScriptHash)
-								  	VALUES(?,?,?,?,?)`)
-	if err != nil {
-		return err
-	}
-
-	var valueBytes []byte
-	valueBytes, err = utxo.Value.Bytes()
+	valueBytes, err := utxo.Value.Bytes()
 	if err != nil {
 		return err
 	}
-	_, err = stmt.Exec(utxo.Op.Bytes(), valueBytes, utxo.LockTime, utxo.AtHeight, hash.ToArray())
+	// Exec directly, a prepared statement per put is never reused and leaks until closed
+	_, err = db.Exec(`INSERT OR REPLACE INTO UTXOs(OutPoint, Value, LockTime, AtHeight, ScriptHash)
+								  	VALUES(?,?,?,?,?)`,
+		utxo.Op.Bytes(), valueBytes, utxo.LockTime, utxo.AtHeight, hash.ToArray())
 	if err != nil {
 		return err
 	}
+	db.cache.put(utxo)
 
 	return nil
 }
@@ -58,13 +65,20 @@ func (db *UTXOsDB) Get(outPoint *tx.OutPoint) (*UTXO, error) {
 	db.RLock()
 	defer db.RUnlock()
 
+	if utxo, known := db.cache.get(outPoint); known {
+		if utxo == nil {
+			return nil, errors.Wrapf(errors.ErrNotFound, "UTXO %s:%d does not exist in database", outPoint.TxID.String(), outPoint.Index)
+		}
+		return utxo, nil
+	}
+
 	row := db.QueryRow(`SELECT Value, LockTime, AtHeight FROM UTXOs WHERE OutPoint=?`, outPoint.Bytes())
 	var valueBytes []byte
 	var lockTime uint32
 	var atHeight uint32
 	err := row.Scan(&valueBytes, &lockTime, &atHeight)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, "UTXO %s:%d does not exist in database", outPoint.TxID.String(), outPoint.Index)
 	}
 
 	var value *Fixed64
@@ -76,6 +90,11 @@ func (db *UTXOsDB) Get(outPoint *tx.OutPoint) (*UTXO, error) {
 	return &UTXO{Op: *outPoint, Value: *value, LockTime: lockTime, AtHeight: atHeight}, nil
 }
 
+// Get the hit rate statistics of the UTXO cache
+func (db *UTXOsDB) CacheStats() CacheStats {
+	return db.cache.stats()
+}
+
 // get utxos of the given script hash from database
 func (db *UTXOsDB) GetAddrAll(hash *Uint168) ([]*UTXO, error) {
 	db.RLock()
@@ -88,7 +107,7 @@ func (db *UTXOsDB) GetAddrAll(hash *Uint168) ([]*UTXO, error) {
 	}
 	defer rows.Close()
 
-	return db.getUTXOs(rows)
+	return getUTXOs(rows)
 }
 
 func (db *UTXOsDB) GetAll() ([]*UTXO, error) {
@@ -101,10 +120,10 @@ func (db *UTXOsDB) GetAll() ([]*UTXO, error) {
 	}
 	defer rows.Close()
 
-	return db.getUTXOs(rows)
+	return getUTXOs(rows)
 }
 
-func (db *UTXOsDB) getUTXOs(rows *sql.Rows) ([]*UTXO, error) {
+func getUTXOs(rows *sql.Rows) ([]*UTXO, error) {
 	var utxos []*UTXO
 	for rows.Next() {
 		var opBytes []byte
@@ -144,6 +163,7 @@ func (db *UTXOsDB) Delete(outPoint *tx.OutPoint) error {
 	if err != nil {
 		return err
 	}
+	db.cache.remove(outPoint)
 
 	return nil
 }
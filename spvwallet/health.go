@@ -0,0 +1,119 @@
+package spvwallet
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+)
+
+const (
+	// Default min established peers for the service to be healthy
+	DefaultHealthMinPeers = 1
+	// Default max blocks the chain height behind the best peer for the service to be ready
+	DefaultReadyMaxSyncLag = 6
+
+	HealthPath = "/healthz"
+	ReadyPath  = "/readyz"
+)
+
+// Check if the service is healthy, which means it has enough established peers and the blocks can be committed
+func (wallet *SPVWallet) CheckHealth() error {
+	if err := wallet.Blockchain().Failure(); err != nil {
+		return err
+	}
+
+	minPeers := wallet.Config().HealthMinPeers
+	if minPeers <= 0 {
+		minPeers = DefaultHealthMinPeers
+	}
+
+	established := 0
+	for _, peer := range wallet.client.PeerManager().ConnectedPeers() {
+		if peer.State() == p2p.ESTABLISH {
+			established++
+		}
+	}
+	if established < minPeers {
+		return errors.New(fmt.Sprintf("Established peers %d less than %d", established, minPeers))
+	}
+
+	return nil
+}
+
+// Check if the service is ready, which means it is healthy, synced up with the best peer and the chain is not stalled
+func (wallet *SPVWallet) CheckReady() error {
+	err := wallet.CheckHealth()
+	if err != nil {
+		return err
+	}
+
+	maxLag := wallet.Config().ReadyMaxSyncLag
+	if maxLag == 0 {
+		maxLag = DefaultReadyMaxSyncLag
+	}
+
+	bestPeer := wallet.client.PeerManager().GetBestPeer()
+	if bestPeer == nil {
+		return errors.New("No best peer to sync with")
+	}
+
+	bestHeight := uint32(bestPeer.Height())
+	height := wallet.GetChainHeight()
+	if bestHeight > height && bestHeight-height > maxLag {
+		return errors.New(fmt.Sprintf("Chain height %d is %d blocks behind the best peer", height, bestHeight-height))
+	}
+
+	// The best peer may be stalled with us
+	return wallet.CheckStalled()
+}
+
+func (wallet *SPVWallet) IsHealthy() bool {
+	return wallet.CheckHealth() == nil
+}
+
+func (wallet *SPVWallet) IsReady() bool {
+	return wallet.CheckReady() == nil
+}
+
+// Start the HTTP server serving health and readiness probes, it responds 200 with "ok"
+// or 503 with the reason when the check failed
+func (wallet *SPVWallet) startHealthServer(addr string, tlsConfig *tls.Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthPath, probe(wallet.CheckHealth))
+	mux.HandleFunc(ReadyPath, probe(wallet.CheckReady))
+	server := &http.Server{Addr: addr, Handler: mux, TLSConfig: tlsConfig}
+	serveHTTP(server, "Health")
+	log.Info("Health server started on", addr)
+	return server
+}
+
+// Serve the server in a goroutine, with TLS if the server has the TLS config
+func serveHTTP(server *http.Server, name string) {
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			// The certificate is served by the TLS config, so it's reloaded when replaced
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Error(name+" server stopped,", err)
+		}
+	}()
+}
+
+func probe(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := check()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+}
@@ -0,0 +1,60 @@
+package spvwallet
+
+import (
+	"bytes"
+
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	// Max length of an idempotency key
+	MaxIdempotencyKeyLength = 64
+
+	// Prefix of the idempotency keys stored in the info table
+	idempotencyKeyPrefix = "idempotency_"
+)
+
+var ErrIdempotencyKeyUsed = errors.New("idempotency key used by another transaction")
+
+/*
+Send the transaction with an idempotency key supplied by the client. The key is stored with the
+txid in the wallet database, so a call retried after a network error with the same key and
+transaction is not broadcasted again, even after the service restarted. The key is stored only
+after the transaction is sent, so a call retried after the send failed sends it again. A key can
+not be used by another transaction. An empty key is the same as SendTransaction().
+*/
+func (wallet *SPVWallet) SendTransactionWithKey(key string, txn tx.Transaction) error {
+	return wallet.sendWithKey(key, txn, wallet.SendTransaction)
+}
+
+func (wallet *SPVWallet) sendWithKey(key string, txn tx.Transaction, send func(tx.Transaction) error) error {
+	if key == "" {
+		return send(txn)
+	}
+	if len(key) > MaxIdempotencyKeyLength {
+		return errors.New("idempotency key too long")
+	}
+
+	wallet.idempotencyLock.Lock()
+	defer wallet.idempotencyLock.Unlock()
+
+	txId := txn.Hash()
+	stored, err := wallet.dataStore.Info().Get(idempotencyKeyPrefix + key)
+	if err == nil {
+		if !bytes.Equal(stored, txId.Bytes()) {
+			return ErrIdempotencyKeyUsed
+		}
+		log.Debug("Transaction ", txId.String(), " already sent with idempotency key ", key)
+		return nil
+	}
+	if !errors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+
+	if err := send(txn); err != nil {
+		return err
+	}
+	return wallet.dataStore.Info().Put(idempotencyKeyPrefix+key, txId.Bytes())
+}
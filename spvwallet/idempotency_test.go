@@ -0,0 +1,69 @@
+package spvwallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+func TestSendWithKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idempotency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := db.NewSQLiteDB(dir, db.DurabilityAlways)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	wallet := &SPVWallet{dataStore: store}
+
+	newTx := func(value Fixed64) tx.Transaction {
+		return tx.Transaction{
+			TxType:  tx.TransferAsset,
+			Payload: &payload.TransferAsset{},
+			Outputs: []*tx.Output{{Value: value, ProgramHash: Uint168{33}}},
+		}
+	}
+	var sent int
+	sendErr := errors.Wrap(errors.ErrInvalid, "fee below the policy")
+	send := func(tx.Transaction) error {
+		sent++
+		return sendErr
+	}
+
+	// A failed send does not store the key, the retry sends again
+	txn := newTx(100)
+	if err := wallet.sendWithKey("key", txn, send); err != sendErr {
+		t.Fatalf("failed send error %v", err)
+	}
+	sendErr = nil
+	if err := wallet.sendWithKey("key", txn, send); err != nil || sent != 2 {
+		t.Fatalf("retry after the failed send error %v, sent %d times", err, sent)
+	}
+
+	// The retry after sent is not sent again, and the key can not be used by another transaction
+	if err := wallet.sendWithKey("key", txn, send); err != nil || sent != 2 {
+		t.Fatalf("retry after sent error %v, sent %d times", err, sent)
+	}
+	if err := wallet.sendWithKey("key", newTx(200), send); err != ErrIdempotencyKeyUsed || sent != 2 {
+		t.Fatalf("key used by another transaction error %v, sent %d times", err, sent)
+	}
+
+	// An empty key always sends
+	for i := 0; i < 2; i++ {
+		if err := wallet.sendWithKey("", txn, send); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent != 4 {
+		t.Errorf("sent %d times, expect 4", sent)
+	}
+}
@@ -0,0 +1,75 @@
+package spvwallet
+
+import (
+	"sync/atomic"
+
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	. "github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// Max entries of the journal read at one time
+const MaxJournalEntries = 1000
+
+// Append the connect entry of the block saved as the new chain tip, if it's higher than the last connected one,
+// the tip saved after a rollback or rescan is connected already
+func (wallet *SPVWallet) journalConnect(header *StoreHeader) error {
+	if header.Height <= atomic.LoadUint32(&wallet.connected) {
+		return nil
+	}
+	err := wallet.dataStore.Journal().Append(&db.JournalEntry{
+		Type:      db.JournalConnect,
+		Height:    header.Height,
+		BlockHash: *header.Hash(),
+	})
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&wallet.connected, header.Height)
+	return nil
+}
+
+// Append the credit entry of the output paid to the wallet
+func (wallet *SPVWallet) journalCredit(storeTx *StoreTx, index int) error {
+	output := storeTx.Data.Outputs[index]
+	address, _ := output.ProgramHash.ToAddress()
+	return wallet.dataStore.Journal().Append(&db.JournalEntry{
+		Type:    db.JournalCredit,
+		Height:  storeTx.Height,
+		TxId:    storeTx.TxId,
+		Op:      *tx.NewOutPoint(storeTx.TxId, uint16(index)),
+		Address: address,
+		Value:   output.Value,
+	})
+}
+
+// Append the debit entry of the wallet output spent by the transaction
+func (wallet *SPVWallet) journalDebit(storeTx *StoreTx, utxo *db.UTXO) error {
+	return wallet.dataStore.Journal().Append(&db.JournalEntry{
+		Type:    db.JournalDebit,
+		Height:  storeTx.Height,
+		TxId:    storeTx.TxId,
+		Op:      utxo.Op,
+		Address: wallet.outputAddress(&utxo.Op),
+		Value:   utxo.Value,
+	})
+}
+
+// Get the address of the wallet output from the transaction paid it, empty if it's not found
+func (wallet *SPVWallet) outputAddress(op *tx.OutPoint) string {
+	storeTx, err := wallet.dataStore.Txs().Get(&op.TxID)
+	if err != nil || int(op.Index) >= len(storeTx.Data.Outputs) {
+		return ""
+	}
+	address, _ := storeTx.Data.Outputs[op.Index].ProgramHash.ToAddress()
+	return address
+}
+
+// Get up to limit entries of the journal after the sequence number,
+// limit is MaxJournalEntries if it's not positive or larger
+func readJournal(store db.DataStore, seq uint64, limit int) ([]*db.JournalEntry, error) {
+	if limit <= 0 || limit > MaxJournalEntries {
+		limit = MaxJournalEntries
+	}
+	return store.Journal().After(seq, limit)
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 
+	"github.com/elastos/Elastos.ELA.SPV/clock"
 	"github.com/elastos/Elastos.ELA.SPV/crypto"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 	. "github.com/elastos/Elastos.ELA.SPV/sdk"
@@ -16,11 +17,16 @@ const (
 	KeystoreVersion = "1.0"
 )
 
+// The error opening the keystore by a wrong password
+var ErrPasswordWrong = errors.New("password wrong")
+
 type Keystore interface {
+	VerifyPassword(password []byte) error
 	ChangePassword(old, new []byte) error
 
 	MainAccount() *Account
 	NewAccount() *Account
+	ImportAccount(privateKey []byte) (*Account, error)
 	GetAccounts() []*Account
 	GetAccountByIndex(index int) *Account
 	GetAccountByProgramHash(programHash *Uint168) *Account
@@ -36,8 +42,9 @@ type KeystoreImpl struct {
 	accounts []*Account
 }
 
-func CreateKeystore(password []byte) (Keystore, error) {
-	keystoreFile, err := CreateKeystoreFile()
+// Create the keystore in the data directory with the password, empty dir means the work directory
+func CreateKeystore(dir string, password []byte) (Keystore, error) {
+	keystoreFile, err := CreateKeystoreFile(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +52,8 @@ func CreateKeystore(password []byte) (Keystore, error) {
 	keystore := &KeystoreImpl{
 		KeystoreFile: keystoreFile,
 	}
+	// Set birthday, a new wallet has no history
+	keystoreFile.Birthday = clock.Now().Unix()
 
 	iv := make([]byte, 16)
 	_, err = rand.Read(iv)
@@ -96,8 +105,9 @@ func CreateKeystore(password []byte) (Keystore, error) {
 	return keystore, nil
 }
 
-func OpenKeystore(password []byte) (Keystore, error) {
-	keystoreFile, err := OpenKeystoreFile()
+// Open the keystore in the data directory by the password, empty dir means the work directory
+func OpenKeystore(dir string, password []byte) (Keystore, error) {
+	keystoreFile, err := OpenKeystoreFile(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -154,6 +164,23 @@ func (store *KeystoreImpl) initAccounts(masterKey, privateKey []byte, publicKey
 		store.accounts = append(store.accounts, childAccount)
 	}
 
+	// initiate imported accounts
+	for _, keyEncrypted := range store.ImportedKeysEncrypted {
+		keyEncryptedBytes, err := HexStringToBytes(keyEncrypted)
+		if err != nil {
+			return err
+		}
+		privateKey, publicKey, err := store.decryptKeyPair(masterKey, keyEncryptedBytes)
+		if err != nil {
+			return err
+		}
+		importedAccount, err := NewAccount(privateKey, publicKey)
+		if err != nil {
+			return err
+		}
+		store.accounts = append(store.accounts, importedAccount)
+	}
+
 	return nil
 }
 
@@ -168,10 +195,21 @@ func (store *KeystoreImpl) verifyPassword(password []byte) error {
 	if IsEqualBytes(origin, passwordHash[:]) {
 		return nil
 	}
-	return errors.New("password wrong")
+	return ErrPasswordWrong
+}
+
+// Check the password of the opened keystore, returns ErrPasswordWrong if it does not match
+func (store *KeystoreImpl) VerifyPassword(password []byte) error {
+	store.Lock()
+	defer store.Unlock()
+
+	return store.verifyPassword(password)
 }
 
 func (store *KeystoreImpl) ChangePassword(oldPassword, newPassword []byte) error {
+	store.Lock()
+	defer store.Unlock()
+
 	// Get old passwordKey
 	oldPasswordKey := crypto.ToAesKey(oldPassword)
 
@@ -223,6 +261,9 @@ func (store *KeystoreImpl) MainAccount() *Account {
 }
 
 func (store *KeystoreImpl) NewAccount() *Account {
+	store.Lock()
+	defer store.Unlock()
+
 	// create sub account
 	privateKey, publicKey, err := crypto.GenerateSubKeyPair(
 		store.SubAccountsCount+1, store.masterKey, store.accounts[0].PrivateKey())
@@ -246,11 +287,52 @@ func (store *KeystoreImpl) NewAccount() *Account {
 	return account
 }
 
+func (store *KeystoreImpl) ImportAccount(privateKey []byte) (*Account, error) {
+	if len(privateKey) != 32 {
+		return nil, errors.New("invalid private key length")
+	}
+
+	store.Lock()
+	defer store.Unlock()
+
+	account, err := NewAccount(privateKey, crypto.NewPubKey(privateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	if store.accountByProgramHash(account.ProgramHash()) != nil {
+		return nil, errors.New("account already exist")
+	}
+
+	privateKeyEncrypted, err := store.encryptPrivateKey(store.masterKey, nil, privateKey, account.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+
+	store.AddImportedKeyEncrypted(privateKeyEncrypted)
+	// The imported key may have history before the wallet created
+	store.Birthday = 0
+	err = store.SaveToFile()
+	if err != nil {
+		return nil, err
+	}
+
+	store.accounts = append(store.accounts, account)
+
+	return account, nil
+}
+
 func (store *KeystoreImpl) GetAccounts() []*Account {
-	return store.accounts
+	store.Lock()
+	defer store.Unlock()
+
+	return append([]*Account(nil), store.accounts...)
 }
 
 func (store *KeystoreImpl) GetAccountByIndex(index int) *Account {
+	store.Lock()
+	defer store.Unlock()
+
 	if index < 0 || index > len(store.accounts)-1 {
 		return nil
 	}
@@ -258,6 +340,13 @@ func (store *KeystoreImpl) GetAccountByIndex(index int) *Account {
 }
 
 func (store *KeystoreImpl) GetAccountByProgramHash(programHash *Uint168) *Account {
+	store.Lock()
+	defer store.Unlock()
+
+	return store.accountByProgramHash(programHash)
+}
+
+func (store *KeystoreImpl) accountByProgramHash(programHash *Uint168) *Account {
 	if programHash == nil {
 		return nil
 	}
@@ -333,6 +422,11 @@ func (store *KeystoreImpl) decryptPrivateKey(masterKey, passwordKey []byte) ([]b
 	if err != nil {
 		return nil, nil, err
 	}
+
+	return store.decryptKeyPair(masterKey, privateKeyEncrypted)
+}
+
+func (store *KeystoreImpl) decryptKeyPair(masterKey, privateKeyEncrypted []byte) ([]byte, *crypto.PublicKey, error) {
 	if len(privateKeyEncrypted) != 96 {
 		return nil, nil, errors.New("invalid encrypted private key")
 	}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"encoding/json"
+	"path/filepath"
 
 	. "github.com/elastos/Elastos.ELA.SPV/common"
 )
@@ -16,6 +17,8 @@ const (
 
 type KeystoreFile struct {
 	sync.Mutex
+	// Path of the file, KeystoreFilename in the data directory
+	path string
 
 	Version string
 
@@ -25,24 +28,34 @@ type KeystoreFile struct {
 	PrivateKeyEncrypted string
 
 	SubAccountsCount int
-}
 
-func CreateKeystoreFile() (*KeystoreFile, error) {
+	// Private keys imported into the wallet, encrypted like the main private key
+	ImportedKeysEncrypted []string `json:",omitempty"`
+
+	// Unix time the wallet created, blocks before it have no transactions of the wallet,
+	// 0 means unknown, it's cleared when a private key imported
+	Birthday int64 `json:",omitempty"`
+}
 
-	if FileExisted(KeystoreFilename) {
+// Create the keystore file in the data directory, empty dir means the work directory
+func CreateKeystoreFile(dir string) (*KeystoreFile, error) {
+	path := filepath.Join(dir, KeystoreFilename)
+	if FileExisted(path) {
 		return nil, errors.New("key store file already exist")
 	}
 
 	file := &KeystoreFile{
 		Version: KeystoreVersion,
+		path:    path,
 	}
 
 	return file, nil
 }
 
-func OpenKeystoreFile() (*KeystoreFile, error) {
+// Open the keystore file in the data directory, empty dir means the work directory
+func OpenKeystoreFile(dir string) (*KeystoreFile, error) {
 
-	file := &KeystoreFile{}
+	file := &KeystoreFile{path: filepath.Join(dir, KeystoreFilename)}
 
 	err := file.LoadFromFile()
 	if err != nil {
@@ -52,6 +65,16 @@ func OpenKeystoreFile() (*KeystoreFile, error) {
 	return file, nil
 }
 
+// Get the birthday of the wallet in the keystore file, the password is not needed.
+// Return 0 if the keystore file not exist or the birthday is unknown
+func KeystoreBirthday(dir string) int64 {
+	file, err := OpenKeystoreFile(dir)
+	if err != nil {
+		return 0
+	}
+	return file.Birthday
+}
+
 func (store *KeystoreFile) SetIV(iv []byte) {
 	store.IV = BytesToHexString(iv)
 }
@@ -68,6 +91,10 @@ func (store *KeystoreFile) SetPrivateKeyEncrypted(privateKeyEncrypted []byte) {
 	store.PrivateKeyEncrypted = BytesToHexString(privateKeyEncrypted)
 }
 
+func (store *KeystoreFile) AddImportedKeyEncrypted(privateKeyEncrypted []byte) {
+	store.ImportedKeysEncrypted = append(store.ImportedKeysEncrypted, BytesToHexString(privateKeyEncrypted))
+}
+
 func (store *KeystoreFile) GetIV() ([]byte, error) {
 
 	iv, err := HexStringToBytes(store.IV)
@@ -112,11 +139,11 @@ func (store *KeystoreFile) LoadFromFile() error {
 	store.Lock()
 	defer store.Unlock()
 
-	if _, err := os.Stat(KeystoreFilename); err != nil {
+	if _, err := os.Stat(store.path); err != nil {
 		return errors.New("keystore file not exist")
 	}
 
-	file, err := os.OpenFile(KeystoreFilename, os.O_RDONLY, 0666)
+	file, err := os.OpenFile(store.path, os.O_RDONLY, 0666)
 	if err != nil {
 		return err
 	}
@@ -138,7 +165,7 @@ func (store *KeystoreFile) SaveToFile() error {
 	store.Lock()
 	defer store.Unlock()
 
-	file, err := os.OpenFile(KeystoreFilename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	file, err := os.OpenFile(store.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,82 @@
+package spvwallet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+// Lock file in the data directory, it holds the pid of the process using the directory
+const LockFilename = "spv.lock"
+
+// DataDirLockedError is returned when the data directory is used by another process,
+// run with the -force flag to take it over if the other process is known to be gone
+type DataDirLockedError struct {
+	Dir string
+	Pid int
+}
+
+func (e *DataDirLockedError) Error() string {
+	if e.Pid > 0 {
+		return fmt.Sprintf("[Wallet], data directory %s is locked by process %d, "+
+			"stop it or run with -force to take over", e.Dir, e.Pid)
+	}
+	return fmt.Sprintf("[Wallet], data directory %s is locked by another process, "+
+		"stop it or run with -force to take over", e.Dir)
+}
+
+// dirLock is the advisory lock of the data directory held by this process
+type dirLock struct {
+	file *os.File
+}
+
+// Lock the data directory, empty dir means the work directory, so two processes can not corrupt one store.
+// With force the directory is taken over even if it's locked
+func lockDataDir(dir string, force bool) (*dirLock, error) {
+	path := filepath.Join(dir, LockFilename)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		if !force {
+			file.Close()
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
+			}
+			return nil, &DataDirLockedError{Dir: dir, Pid: lockOwner(path)}
+		}
+		log.Warn("Take over the data directory locked by process ", lockOwner(path))
+	}
+
+	file.Truncate(0)
+	file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	file.Sync()
+	return &dirLock{file: file}, nil
+}
+
+// Get the pid of the process holding the lock, 0 if unknown
+func lockOwner(path string) int {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// Release the lock, the lock file is kept to not race with another process locking it
+func (l *dirLock) release() {
+	if l == nil || l.file == nil {
+		return
+	}
+	l.file.Truncate(0)
+	unlockFile(l.file)
+	l.file.Close()
+	l.file = nil
+}
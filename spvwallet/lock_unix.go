@@ -0,0 +1,17 @@
+// +build !windows
+
+package spvwallet
+
+import (
+	"os"
+	"syscall"
+)
+
+// Take the exclusive advisory lock of the file without blocking, it's released when the process exits
+func lockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,12 @@
+package spvwallet
+
+import "os"
+
+// File locking is not supported on Windows, the data directory is not locked
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package spvwallet
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+/*
+Keep the signed transaction in the wallet database and broadcast it once the chain passes it's lock time,
+a block height, or a unix time compared with the timestamp of the chain tip if not less than
+db.LockTimeThreshold. A transaction already due is sent at once. The scheduled transaction survives
+restarts of the service and can be canceled before it's broadcasted, it's inputs are not reserved, so
+spending them in another transaction makes it fail when broadcasted.
+*/
+func (wallet *SPVWallet) ScheduleTransaction(txn tx.Transaction) error {
+	stx := &db.ScheduledTx{Tx: txn, Created: uint32(clock.Now().Unix())}
+	if height, timestamp := wallet.lockClock(); stx.IsDue(height, timestamp) {
+		return wallet.SendTransaction(txn)
+	}
+	if err := wallet.checkDoubleSpend(&txn); err != nil {
+		return err
+	}
+
+	err := wallet.dataStore.ScheduledTxs().Put(stx)
+	if err != nil {
+		return err
+	}
+	log.Infof("Transaction %s scheduled at lock time %d", txn.Hash().String(), txn.LockTime)
+	return nil
+}
+
+// Get the transactions scheduled and not broadcasted yet, ordered by the lock time
+func (wallet *SPVWallet) GetScheduledTxs() ([]*rpc.ScheduledTxInfo, error) {
+	stxs, err := wallet.dataStore.ScheduledTxs().GetAll()
+	if err != nil {
+		return nil, err
+	}
+	scheduled := make([]*rpc.ScheduledTxInfo, 0, len(stxs))
+	for _, stx := range stxs {
+		scheduled = append(scheduled, &rpc.ScheduledTxInfo{
+			TxId:     common.BytesToHexString(stx.Tx.Hash().BytesReverse()),
+			LockTime: stx.Tx.LockTime,
+			Created:  stx.Created,
+		})
+	}
+	return scheduled, nil
+}
+
+// Cancel a scheduled transaction before it's broadcasted
+func (wallet *SPVWallet) CancelScheduledTx(txId common.Uint256) error {
+	err := wallet.dataStore.ScheduledTxs().Delete(&txId)
+	if errors.Is(err, errors.ErrNotFound) {
+		return errors.Wrap(errors.ErrNotFound, "transaction not scheduled or already broadcasted")
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("Scheduled transaction ", txId.String(), " canceled")
+	return nil
+}
+
+// Broadcast the scheduled transactions due on the chain of the height, it's called after a block committed
+func (wallet *SPVWallet) broadcastScheduled(height uint32) {
+	stxs, err := wallet.dataStore.ScheduledTxs().GetAll()
+	if err != nil {
+		log.Error("Get scheduled transactions failed, ", err)
+		return
+	}
+	if len(stxs) == 0 {
+		return
+	}
+
+	_, timestamp := wallet.lockClock()
+	for _, stx := range stxs {
+		if !stx.IsDue(height, timestamp) {
+			continue
+		}
+		// Remove it first, so a transaction canceled at the same time is not broadcasted
+		txId := stx.Tx.Hash()
+		if err := wallet.dataStore.ScheduledTxs().Delete(txId); err != nil {
+			continue
+		}
+		if err := wallet.SendTransaction(stx.Tx); err != nil {
+			log.Warnf("Scheduled transaction %s dropped, %s", txId.String(), err)
+			continue
+		}
+		log.Info("Scheduled transaction ", txId.String(), " broadcasted")
+	}
+}
+
+// Get the chain height and the timestamp of the chain tip to check the lock times with
+func (wallet *SPVWallet) lockClock() (uint32, uint32) {
+	height := wallet.dataStore.Info().ChainHeight()
+	tip, err := wallet.headers.GetTip()
+	if err != nil {
+		return height, 0
+	}
+	return height, tip.Timestamp
+}
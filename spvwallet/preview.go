@@ -0,0 +1,146 @@
+package spvwallet
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+// Max signed size of a transaction previewed without warning, larger transactions
+// take a large part of a block and may not be relayed by all nodes
+const MaxStandardTxSize = 100 * 1024
+
+/*
+Create the transaction as CreateTransactionWithOptions() without signing or sending it, and get the inputs
+selected, the fee, the change and the effective fee rate per KB of the signed size, so a user can confirm
+it before it's signed. Policy warnings are reported for the outputs and the change worth less than the fee
+to spend them, which are called dust, the transaction larger than MaxStandardTxSize, and the fee more than
+the value sent.
+*/
+func (wallet *WalletImpl) PreviewTransaction(fromAddress string, options *TxOptions, outputs ...*Output) (*rpc.TxPreview, error) {
+	txn, err := wallet.CreateTransactionWithOptions(fromAddress, options, outputs...)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.preview(fromAddress, options, txn, len(outputs))
+}
+
+// Get the preview of the transaction created by the from address, the outputs to send are followed by the change
+func (wallet *WalletImpl) preview(fromAddress string, options *TxOptions, txn *tx.Transaction, outputCount int) (*rpc.TxPreview, error) {
+	spender, err := Uint168FromAddress(fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := wallet.GetAddressUTXOs(spender)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[tx.OutPoint]Fixed64, len(utxos))
+	for _, utxo := range utxos {
+		values[utxo.Op] = utxo.Value
+	}
+
+	preview := new(rpc.TxPreview)
+	var totalInput, totalOutput, sent Fixed64
+	for _, input := range txn.Inputs {
+		value := values[*tx.NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex)]
+		totalInput += value
+		preview.Inputs = append(preview.Inputs, &rpc.PreviewInput{
+			TxId:  BytesToHexString(input.ReferTxID.BytesReverse()),
+			Index: input.ReferTxOutputIndex,
+			Value: value,
+		})
+	}
+	for i, output := range txn.Outputs {
+		totalOutput += output.Value
+		address, err := output.ProgramHash.ToAddress()
+		if err != nil {
+			return nil, err
+		}
+		// Change output is appended after the outputs to send
+		if i >= outputCount {
+			preview.Change = &rpc.PreviewOutput{Address: address, Value: output.Value}
+			continue
+		}
+		sent += output.Value
+		preview.Outputs = append(preview.Outputs, &rpc.PreviewOutput{Address: address, Value: output.Value})
+	}
+	preview.Fee = totalInput - totalOutput
+	preview.Size = EstimateSignedSize(txn)
+	preview.FeeRate = preview.Fee * 1000 / Fixed64(preview.Size)
+
+	// Spending an output adds an input to the transaction, the dust is worth less than the fee of it
+	dust := FeeBySize(preview.FeeRate, tx.InputSize)
+	if options.FeeRate != nil {
+		dust = FeeBySize(*options.FeeRate, tx.InputSize)
+	}
+	for _, output := range preview.Outputs {
+		if output.Value <= 0 || output.Value < dust {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("dust output %s to %s, less than %s to spend it",
+				output.Value.String(), output.Address, dust.String()))
+		}
+	}
+	if preview.Change != nil && preview.Change.Value < dust {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("dust change %s, less than %s to spend it",
+			preview.Change.Value.String(), dust.String()))
+	}
+	if preview.Size > MaxStandardTxSize {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("oversized transaction of %d bytes, more than %d",
+			preview.Size, MaxStandardTxSize))
+	}
+	if preview.Fee > sent {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("fee %s more than the value sent %s",
+			preview.Fee.String(), sent.String()))
+	}
+	return preview, nil
+}
+
+// Preview the transaction requested by the RPC client on the wallet database of the service
+func (wallet *SPVWallet) PreviewTransaction(req *rpc.PreviewRequest) (*rpc.TxPreview, error) {
+	if req.Fee == nil && req.FeeRate == nil {
+		return nil, errors.New("transaction fee or fee rate not set")
+	}
+	options := &TxOptions{Fee: req.Fee, FeeRate: req.FeeRate, LockedUntil: req.LockedUntil}
+	for _, utxo := range req.UTXOs {
+		op, err := ParseOutPoint(utxo)
+		if err != nil {
+			return nil, err
+		}
+		options.UTXOs = append(options.UTXOs, op)
+	}
+	var outputs []*Output
+	for _, output := range req.Outputs {
+		value := output.Value
+		outputs = append(outputs, &Output{Address: output.Address, Value: &value})
+	}
+	return (&WalletImpl{Database: wallet.database}).PreviewTransaction(req.From, options, outputs...)
+}
+
+// Parse UTXO in format txid:index, the txid is the reversed hex string as printed by send command
+func ParseOutPoint(value string) (*tx.OutPoint, error) {
+	columns := strings.Split(value, ":")
+	if len(columns) != 2 {
+		return nil, errors.New("invalid utxo " + value + ", use format txid:index")
+	}
+
+	txIdBytes, err := HexStringToBytesReverse(columns[0])
+	if err != nil {
+		return nil, errors.New("invalid utxo txid " + columns[0])
+	}
+	txId, err := Uint256FromBytes(txIdBytes)
+	if err != nil {
+		return nil, errors.New("invalid utxo txid " + columns[0])
+	}
+
+	index, err := strconv.ParseUint(columns[1], 10, 16)
+	if err != nil {
+		return nil, errors.New("invalid utxo index " + columns[1])
+	}
+
+	return tx.NewOutPoint(*txId, uint16(index)), nil
+}
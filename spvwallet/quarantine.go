@@ -0,0 +1,71 @@
+package spvwallet
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// The damaged wallet database is moved to the path of it followed by the suffix and the unix time
+const quarantineSuffix = ".damaged."
+
+// storageFailures handles the storage failure alerts of the blockchain, the wallet stops syncing
+// or the wallet database is quarantined and the wallet continues in the headers only mode
+type storageFailures struct {
+	wallet *SPVWallet
+}
+
+func (s *storageFailures) OnAlert(alert *sdk.Alert) {
+	if alert.Type != sdk.AlertStorageFailure {
+		return
+	}
+
+	event := &DBFailureEvent{Height: alert.Height, Error: alert.Message}
+	// A write failed in the headers only mode is not of the wallet database, the headers database
+	// can not be moved aside, and the wallet database is moved only once
+	if s.wallet.Config().QuarantineDB && !s.wallet.IsHeadersOnly() {
+		path, err := s.wallet.quarantine()
+		if err != nil {
+			log.Error("Quarantine wallet database failed, ", err)
+		} else {
+			event.Quarantined = path
+		}
+	}
+	if event.Quarantined == "" {
+		log.Error("Wallet stopped syncing for the database write failure, restart it after the failure is resolved")
+	}
+	s.wallet.webhooks.emit(EventDBFailure, event)
+}
+
+/*
+Move the damaged wallet database aside and continue in the headers only mode, the chain is followed from the
+tip without the transactions. The addresses and labels are copied to the new database if they can be read, the
+wallet is in the headers only mode after restarted until the chain data is reset and rescanned.
+*/
+func (wallet *SPVWallet) quarantine() (string, error) {
+	path := wallet.Config().Path(db.DBName) + quarantineSuffix + strconv.FormatInt(clock.Now().Unix(), 10)
+	if err := wallet.dataStore.Quarantine(path); err != nil {
+		return "", err
+	}
+	atomic.AddUint64(&wallet.outPointsVersion, 1)
+	log.Warnf("Wallet database moved to %s, sync headers only until the chain data is reset", path)
+
+	wallet.SetHeadersOnly(true)
+	wallet.Blockchain().ClearFailure()
+	wallet.Resync()
+	return path, nil
+}
+
+// Enter the headers only mode if the wallet database was quarantined, until the chain data is reset
+func (wallet *SPVWallet) checkQuarantined() {
+	path, err := wallet.dataStore.Info().Get(db.QuarantinedKey)
+	if err != nil {
+		return
+	}
+	log.Warnf("Wallet database was damaged and moved to %s, sync headers only until the chain data is reset", path)
+	wallet.SetHeadersOnly(true)
+}
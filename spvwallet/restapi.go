@@ -0,0 +1,510 @@
+package spvwallet
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+const (
+	// Default max requests per minute of a client to the REST API
+	DefaultAPIRateLimit = 60
+	// Entries of a page of the address history
+	HistoryPageSize = 50
+	// Header of the API key
+	APIKeyHeader = "X-API-Key"
+	// Max clients the rate limiter keeps the buckets of, the least recently seen is dropped for a new one
+	MaxAPIClients = 10000
+)
+
+// UTXOInfo is an unspent output of an address, txid is reversed hex string
+type UTXOInfo struct {
+	TxId     string `json:"txid"`
+	Index    uint16 `json:"index"`
+	Value    string `json:"value"`
+	Height   uint32 `json:"height"`
+	LockTime uint32 `json:"locktime"`
+}
+
+// HistoryEntry is a transaction changed the balance of an address, value is negative if spent,
+// the record is included if it's a record transaction
+type HistoryEntry struct {
+	TxId   string          `json:"txid"`
+	Height uint32          `json:"height"`
+	Value  string          `json:"value"`
+	Record *sdk.RecordInfo `json:"record,omitempty"`
+
+	hash common.Uint256
+}
+
+// JournalInfo is an entry of the wallet journal, hashes are reversed hex strings
+// and the fields not of the entry type are omitted
+type JournalInfo struct {
+	Seq       uint64 `json:"seq"`
+	Type      string `json:"type"`
+	Height    uint32 `json:"height"`
+	BlockHash string `json:"blockhash,omitempty"`
+	TxId      string `json:"txid,omitempty"`
+	Index     uint16 `json:"index,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Time      int64  `json:"time"`
+}
+
+// BalancePointInfo is the wallet balance at the unix time, after the block of the height
+type BalancePointInfo struct {
+	Time    int64  `json:"time"`
+	Height  uint32 `json:"height"`
+	Balance string `json:"balance"`
+}
+
+// rateLimiter is a token bucket per client, refilled to the limit in a minute, with the buckets
+// of up to max clients
+type rateLimiter struct {
+	sync.Mutex
+	limit   int
+	max     int
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Take a token of the client, return false if the client exceeded the limit
+func (l *rateLimiter) allow(client string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	now := clock.Now()
+	b, ok := l.buckets[client]
+	if !ok {
+		// Forget the idle clients, a bucket full again is the same as a new one
+		for name, b := range l.buckets {
+			if now.Sub(b.last) > time.Minute {
+				delete(l.buckets, name)
+			}
+		}
+		if len(l.buckets) >= l.max {
+			l.dropLeastRecent()
+		}
+		b = &bucket{tokens: float64(l.limit), last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens += now.Sub(b.last).Minutes() * float64(l.limit)
+	if b.tokens > float64(l.limit) {
+		b.tokens = float64(l.limit)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Drop the bucket of the client seen least recently
+func (l *rateLimiter) dropLeastRecent() {
+	var oldest string
+	var last time.Time
+	for name, b := range l.buckets {
+		if oldest == "" || b.last.Before(last) {
+			oldest, last = name, b.last
+		}
+	}
+	delete(l.buckets, oldest)
+}
+
+/*
+restAPI serves the read-only queries of the wallet database over HTTP, the balance, UTXOs and
+history of an address, the transactions with their labels and the transaction search. If API keys
+are configured a request must present one of them in the header, requests are rate limited per
+API key checked, or per remote IP if no key is configured.
+*/
+type restAPI struct {
+	wallet  *SPVWallet
+	keys    []string
+	limiter *rateLimiter
+}
+
+// Start the HTTP server serving the REST API, with TLS if the TLS config is not nil
+func (wallet *SPVWallet) startRESTServer(addr string, tlsConfig *tls.Config) *http.Server {
+	cfg := wallet.Config()
+	limit := cfg.APIRateLimit
+	if limit <= 0 {
+		limit = DefaultAPIRateLimit
+	}
+	api := &restAPI{
+		wallet:  wallet,
+		keys:    cfg.APIKeys,
+		limiter: &rateLimiter{limit: limit, max: MaxAPIClients, buckets: make(map[string]*bucket)},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/balance/", api.handle("/balance/", api.balance))
+	mux.HandleFunc("/utxos/", api.handle("/utxos/", api.utxos))
+	mux.HandleFunc("/tx/", api.handle("/tx/", api.tx))
+	mux.HandleFunc("/history/", api.handle("/history/", api.history))
+	mux.HandleFunc("/search/", api.handle("/search/", api.search))
+	mux.HandleFunc("/journal/", api.handle("/journal/", api.journal))
+	mux.HandleFunc("/balancehistory/", api.handle("/balancehistory/", api.balanceHistory))
+	server := &http.Server{Addr: addr, Handler: mux, TLSConfig: tlsConfig}
+	serveHTTP(server, "REST API")
+	log.Info("REST API server started on", addr)
+	return server
+}
+
+// Check the method, API key and rate limit before the query, the query gets the path
+// parameter after the prefix and returns the result to be written in JSON
+func (api *restAPI) handle(prefix string, query func(param string, r *http.Request) (interface{}, int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeJSON(w, http.StatusMethodNotAllowed, apiError("method not allowed"))
+			return
+		}
+
+		// The key given is not trusted as the client until it's checked
+		client, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if len(api.keys) > 0 {
+			key := r.Header.Get(APIKeyHeader)
+			if !api.validKey(key) {
+				writeJSON(w, http.StatusUnauthorized, apiError("invalid API key"))
+				return
+			}
+			client = key
+		}
+		if !api.limiter.allow(client) {
+			w.Header().Set("Retry-After", fmt.Sprint(60/api.limiter.limit+1))
+			writeJSON(w, http.StatusTooManyRequests, apiError("rate limit exceeded"))
+			return
+		}
+
+		param := strings.TrimPrefix(r.URL.Path, prefix)
+		if param == "" || strings.Contains(param, "/") {
+			writeJSON(w, http.StatusNotFound, apiError("not found"))
+			return
+		}
+		result, status, err := query(param, r)
+		if err != nil {
+			writeJSON(w, status, apiError(err.Error()))
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (api *restAPI) validKey(key string) bool {
+	valid := false
+	for _, k := range api.keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func (api *restAPI) balance(address string, r *http.Request) (interface{}, int, error) {
+	utxos, status, err := api.addressUTXOs(address)
+	if err != nil {
+		return nil, status, err
+	}
+	var balance common.Fixed64
+	for _, utxo := range utxos {
+		balance += utxo.Value
+	}
+	return map[string]interface{}{
+		"address": address,
+		"balance": balance.String(),
+		"height":  api.wallet.GetChainHeight(),
+	}, http.StatusOK, nil
+}
+
+func (api *restAPI) utxos(address string, r *http.Request) (interface{}, int, error) {
+	utxos, status, err := api.addressUTXOs(address)
+	if err != nil {
+		return nil, status, err
+	}
+	infos := make([]*UTXOInfo, 0, len(utxos))
+	for _, utxo := range utxos {
+		infos = append(infos, &UTXOInfo{
+			TxId:     common.BytesToHexString(utxo.Op.TxID.BytesReverse()),
+			Index:    utxo.Op.Index,
+			Value:    utxo.Value.String(),
+			Height:   utxo.AtHeight,
+			LockTime: utxo.LockTime,
+		})
+	}
+	return infos, http.StatusOK, nil
+}
+
+func (api *restAPI) tx(txId string, r *http.Request) (interface{}, int, error) {
+	hashBytes, err := common.HexStringToBytesReverse(txId)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid transaction hash")
+	}
+	hash, err := common.Uint256FromBytes(hashBytes)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid transaction hash")
+	}
+	storeTx, err := api.wallet.dataStore.Txs().Get(hash)
+	if errors.Is(err, errors.ErrNotFound) {
+		return nil, http.StatusNotFound, fmt.Errorf("transaction not found")
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	info, err := sdk.NewTransactionInfo(&storeTx.Data)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	// The transaction may be above the chain height while the chain is rolled back
+	var confirmations uint32
+	if height := api.wallet.GetChainHeight(); height >= storeTx.Height {
+		confirmations = height - storeTx.Height + 1
+	}
+	result := map[string]interface{}{
+		"height":        storeTx.Height,
+		"confirmations": confirmations,
+		"transaction":   info,
+	}
+	if label, err := api.wallet.dataStore.TxLabels().Get(hash); err == nil {
+		result["label"] = label
+	}
+	if payments, err := api.wallet.database.GetTxPayments(hash); err == nil {
+		result["payments"] = payments
+	}
+	return result, http.StatusOK, nil
+}
+
+// Search the transactions of the wallet by the query, the terms are separated by spaces
+func (api *restAPI) search(query string, r *http.Request) (interface{}, int, error) {
+	results, err := searchTxs(api.wallet.dataStore, query)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if results == nil {
+		results = []*rpc.TxSearchResult{}
+	}
+	return results, http.StatusOK, nil
+}
+
+// Get the journal entries after the sequence number, up to the limit query parameter
+func (api *restAPI) journal(seq string, r *http.Request) (interface{}, int, error) {
+	after, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid sequence number")
+	}
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid limit")
+		}
+	}
+	entries, err := readJournal(api.wallet.dataStore, after, limit)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	infos := make([]*JournalInfo, 0, len(entries))
+	for _, entry := range entries {
+		info := &JournalInfo{
+			Seq:     entry.Seq,
+			Type:    entry.Type.String(),
+			Height:  entry.Height,
+			Address: entry.Address,
+			Time:    entry.Time,
+		}
+		switch entry.Type {
+		case db.JournalConnect:
+			info.BlockHash = common.BytesToHexString(entry.BlockHash.BytesReverse())
+		case db.JournalCredit, db.JournalDebit:
+			info.TxId = common.BytesToHexString(entry.TxId.BytesReverse())
+			info.Index = entry.Op.Index
+			info.Value = entry.Value.String()
+		}
+		infos = append(infos, info)
+	}
+	return infos, http.StatusOK, nil
+}
+
+// Get the wallet balance every granularity seconds, from and to query parameters are the unix times
+// of the first and the last point, to is now and from is MaxBalancePoints points before if not set
+func (api *restAPI) balanceHistory(granularity string, r *http.Request) (interface{}, int, error) {
+	seconds, err := strconv.ParseUint(granularity, 10, 32)
+	if err != nil || seconds == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid granularity")
+	}
+	to := clock.Now().Unix()
+	if value := r.URL.Query().Get("to"); value != "" {
+		to, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid to")
+		}
+	}
+	from := to - int64(seconds)*(MaxBalancePoints-1)
+	if value := r.URL.Query().Get("from"); value != "" {
+		from, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || from > to {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid from")
+		}
+	}
+	if (to-from)/int64(seconds) >= MaxBalancePoints {
+		return nil, http.StatusBadRequest, fmt.Errorf("too many points, at most %d", MaxBalancePoints)
+	}
+
+	points, err := api.wallet.GetBalanceHistory(uint32(seconds), from, to)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	infos := make([]*BalancePointInfo, 0, len(points))
+	for _, point := range points {
+		infos = append(infos, &BalancePointInfo{Time: point.Time, Height: point.Height, Balance: point.Balance.String()})
+	}
+	return infos, http.StatusOK, nil
+}
+
+func (api *restAPI) history(address string, r *http.Request) (interface{}, int, error) {
+	hash, err := common.Uint168FromAddress(address)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid address")
+	}
+	page := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid page")
+		}
+	}
+
+	// Entries since the date, unix time or like 2018-06-01
+	var since uint32
+	if value := r.URL.Query().Get("since"); value != "" {
+		timestamp, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			date, err := rpc.ParseDate(value)
+			if err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("invalid since")
+			}
+			timestamp = uint64(date)
+		}
+		if since, err = api.wallet.GetHeightByTime(uint32(timestamp)); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	utxos, err := api.wallet.dataStore.UTXOs().GetAddrAll(hash)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	stxos, err := api.wallet.dataStore.STXOs().GetAddrHistory(hash)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	entries := addressHistory(utxos, stxos)
+	if since > 0 {
+		filtered := make([]*HistoryEntry, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Height >= since {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	total := len(entries)
+	start := (page - 1) * HistoryPageSize
+	if start > total {
+		start = total
+	}
+	end := start + HistoryPageSize
+	if end > total {
+		end = total
+	}
+	// Extract the records of the transactions in the page
+	for _, entry := range entries[start:end] {
+		if storeTx, err := api.wallet.dataStore.Txs().Get(&entry.hash); err == nil {
+			entry.Record = sdk.GetRecord(&storeTx.Data)
+		}
+	}
+	return map[string]interface{}{
+		"address": address,
+		"page":    page,
+		"pages":   (total + HistoryPageSize - 1) / HistoryPageSize,
+		"total":   total,
+		"entries": entries[start:end],
+	}, http.StatusOK, nil
+}
+
+func (api *restAPI) addressUTXOs(address string) ([]*db.UTXO, int, error) {
+	hash, err := common.Uint168FromAddress(address)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid address")
+	}
+	utxos, err := api.wallet.dataStore.UTXOs().GetAddrAll(hash)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return utxos, http.StatusOK, nil
+}
+
+// Get the history entries of the received and spent outputs of an address, the value of a transaction
+// is the sum of the outputs it received minus the outputs it spent, the latest first
+func addressHistory(utxos []*db.UTXO, stxos []*db.STXO) []*HistoryEntry {
+	values := make(map[common.Uint256]common.Fixed64)
+	heights := make(map[common.Uint256]uint32)
+	for _, utxo := range utxos {
+		values[utxo.Op.TxID] += utxo.Value
+		heights[utxo.Op.TxID] = utxo.AtHeight
+	}
+	for _, stxo := range stxos {
+		values[stxo.Op.TxID] += stxo.Value
+		heights[stxo.Op.TxID] = stxo.AtHeight
+		values[stxo.SpendTxId] -= stxo.Value
+		heights[stxo.SpendTxId] = stxo.SpendHeight
+	}
+
+	entries := make([]*HistoryEntry, 0, len(values))
+	for txId, value := range values {
+		entries = append(entries, &HistoryEntry{
+			TxId:   common.BytesToHexString(txId.BytesReverse()),
+			Height: heights[txId],
+			Value:  value.String(),
+			hash:   txId,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Height != entries[j].Height {
+			return entries[i].Height > entries[j].Height
+		}
+		return entries[i].TxId < entries[j].TxId
+	})
+	return entries
+}
+
+func apiError(message string) map[string]string {
+	return map[string]string{"error": message}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,199 @@
+package spvwallet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
+	. "github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// Create the REST API on a wallet database in a temp directory, with the limit of requests per minute
+func newTestAPI(t *testing.T, keys []string, limit int) (*restAPI, func()) {
+	dir, err := ioutil.TempDir("", "restapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := db.NewSQLiteDB(dir, db.DurabilityAlways)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	wallet := &SPVWallet{dataStore: store, database: &DatabaseImpl{lock: new(sync.RWMutex), DataStore: store}}
+	api := &restAPI{
+		wallet:  wallet,
+		keys:    keys,
+		limiter: &rateLimiter{limit: limit, max: MaxAPIClients, buckets: make(map[string]*bucket)},
+	}
+	return api, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// Get the path from the remote address with the API key header if not empty
+func (api *restAPI) get(path, remoteAddr, key string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = remoteAddr
+	if key != "" {
+		r.Header.Set(APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	api.handle("/tx/", api.tx)(w, r)
+	return w
+}
+
+func TestRESTAPITx(t *testing.T) {
+	api, cleanup := newTestAPI(t, nil, 100)
+	defer cleanup()
+
+	txn := tx.Transaction{
+		TxType:  tx.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Outputs: []*tx.Output{{Value: 100000000, ProgramHash: Uint168{33}}},
+	}
+	txId := txn.Hash()
+	path := "/tx/" + BytesToHexString(txId.BytesReverse())
+	if w := api.get(path, "192.0.2.1:1000", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown transaction responded %d", w.Code)
+	}
+	if err := api.wallet.dataStore.Txs().Put(&StoreTx{TxId: *txId, Height: 100, Data: txn}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The transaction above the chain height while rolled back has no confirmations
+	for _, c := range []struct {
+		height        uint32
+		confirmations uint32
+	}{{109, 10}, {100, 1}, {99, 0}} {
+		api.wallet.dataStore.Info().SaveChainHeight(c.height)
+		w := api.get(path, "192.0.2.1:1000", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("transaction responded %d %s", w.Code, w.Body.String())
+		}
+		var result struct {
+			Height        uint32 `json:"height"`
+			Confirmations uint32 `json:"confirmations"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Height != 100 || result.Confirmations != c.confirmations {
+			t.Errorf("transaction at %d with %d confirmations at chain height %d, expect 100 with %d",
+				result.Height, result.Confirmations, c.height, c.confirmations)
+		}
+	}
+	if w := api.get("/tx/"+BytesToHexString(txId.BytesReverse())+"/x", "192.0.2.1:1000", ""); w.Code != http.StatusNotFound {
+		t.Errorf("nested path responded %d", w.Code)
+	}
+}
+
+func TestRESTAPIKeys(t *testing.T) {
+	api, cleanup := newTestAPI(t, []string{"key1", "key2"}, 100)
+	defer cleanup()
+
+	for _, c := range []struct {
+		key    string
+		path   string
+		status int
+	}{
+		{"", "/tx/00", http.StatusUnauthorized},
+		{"wrong", "/tx/00", http.StatusUnauthorized},
+		// The key is not taken from the query string
+		{"", "/tx/00?apikey=key1", http.StatusUnauthorized},
+		{"key1", "/tx/00", http.StatusBadRequest},
+		{"key2", "/tx/00", http.StatusBadRequest},
+	} {
+		if w := api.get(c.path, "192.0.2.1:1000", c.key); w.Code != c.status {
+			t.Errorf("%s with key %q responded %d, expect %d", c.path, c.key, w.Code, c.status)
+		}
+	}
+	r := httptest.NewRequest(http.MethodPost, "/tx/00", nil)
+	r.Header.Set(APIKeyHeader, "key1")
+	w := httptest.NewRecorder()
+	api.handle("/tx/", api.tx)(w, r)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST responded %d", w.Code)
+	}
+}
+
+func TestRESTAPIRateLimit(t *testing.T) {
+	system := clock.Get()
+	mock := clock.NewMock(time.Unix(1500000000, 0))
+	clock.Set(mock)
+	defer clock.Set(system)
+
+	// Without the keys configured, the keys given do not make new clients
+	api, cleanup := newTestAPI(t, nil, 2)
+	defer cleanup()
+	for i := 0; i < 2; i++ {
+		if w := api.get("/tx/00", "192.0.2.1:1000", fmt.Sprint("key", i)); w.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d limited", i)
+		}
+	}
+	if w := api.get("/tx/00", "192.0.2.1:2000", "key3"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit with a new key responded %d", w.Code)
+	}
+	if w := api.get("/tx/00", "192.0.2.2:1000", ""); w.Code == http.StatusTooManyRequests {
+		t.Fatal("request of another remote IP limited")
+	}
+	// The bucket is refilled in a minute
+	mock.Add(30 * time.Second)
+	if w := api.get("/tx/00", "192.0.2.1:1000", ""); w.Code == http.StatusTooManyRequests {
+		t.Fatal("request limited after refilled")
+	}
+	if w := api.get("/tx/00", "192.0.2.1:1000", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatal("request over the refilled limit responded", w.Code)
+	}
+
+	// With the keys configured, the requests are limited by the keys checked
+	api, cleanup = newTestAPI(t, []string{"key1", "key2"}, 1)
+	defer cleanup()
+	if w := api.get("/tx/00", "192.0.2.1:1000", "key1"); w.Code == http.StatusTooManyRequests {
+		t.Fatal("request of key1 limited")
+	}
+	if w := api.get("/tx/00", "192.0.2.1:1000", "key2"); w.Code == http.StatusTooManyRequests {
+		t.Fatal("request of key2 limited")
+	}
+	if w := api.get("/tx/00", "192.0.2.2:1000", "key1"); w.Code != http.StatusTooManyRequests {
+		t.Fatal("request of key1 over the limit responded", w.Code)
+	}
+	for i := 0; i < 10; i++ {
+		api.get("/tx/00", "192.0.2.3:1000", fmt.Sprint("wrong", i))
+	}
+	if len(api.limiter.buckets) != 2 {
+		t.Errorf("%d buckets, the unchecked keys make buckets", len(api.limiter.buckets))
+	}
+}
+
+func TestRateLimiterMaxClients(t *testing.T) {
+	system := clock.Get()
+	mock := clock.NewMock(time.Unix(1500000000, 0))
+	clock.Set(mock)
+	defer clock.Set(system)
+
+	limiter := &rateLimiter{limit: 1, max: 2, buckets: make(map[string]*bucket)}
+	for _, client := range []string{"a", "b", "c"} {
+		if !limiter.allow(client) {
+			t.Fatalf("first request of %s limited", client)
+		}
+		mock.Add(time.Second)
+	}
+	if len(limiter.buckets) != 2 || limiter.buckets["a"] != nil {
+		t.Fatalf("buckets %v, expect the least recent dropped", limiter.buckets)
+	}
+	if limiter.allow("c") {
+		t.Error("request over the limit allowed")
+	}
+}
@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// The cookie file holding the token to access the RPC server, it is created in the data directory
+	// when the server started and removed when the server closed
+	CookieFile = ".cookie"
+
+	// Length in bytes of the random token
+	tokenLength = 32
+	// Prefix of the token in Authorization header
+	bearerPrefix = "Bearer "
+)
+
+// Path of the cookie file, CookieFile in the data directory set by the setup
+var CookiePath = CookieFile
+
+// Create a new random token and save it into the cookie file, only the owner can read it
+func newCookie() (string, error) {
+	buf := make([]byte, tokenLength)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	os.Remove(CookiePath)
+	err = ioutil.WriteFile(CookiePath, []byte(token), 0600)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Read the token from the cookie file, empty string is returned if the SPV service is not running
+func readCookie() string {
+	data, err := ioutil.ReadFile(CookiePath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Check the token in Authorization header of the request
+func authorized(r *http.Request, token string) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, bearerPrefix) {
+		return false
+	}
+	given := strings.TrimPrefix(auth, bearerPrefix)
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
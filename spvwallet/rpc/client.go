@@ -2,21 +2,76 @@ package rpc
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"net"
 	"net/http"
 	"io/ioutil"
 	"errors"
 
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
 	"encoding/hex"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 )
 
 type Client struct {
-	url string
+	url    string
+	token  string
+	client *http.Client
 }
 
+// Get a client to the running SPV service, through unix socket if RPCSocket is set,
+// the token is read from the cookie file created by the SPV service
 func GetClient() *Client {
-	return &Client{url: RPCAddr}
+	if RPCTLS != nil {
+		return getTLSClient()
+	}
+	if RPCSocket == "" {
+		return &Client{url: RPCHost + RPCPort, token: readCookie(), client: http.DefaultClient}
+	}
+
+	transport := &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", RPCSocket)
+		},
+	}
+	return &Client{url: SocketHost, token: readCookie(), client: &http.Client{Transport: transport}}
+}
+
+// Get a client to the RPC server with TLS, if the server certificate can not be read
+// the server is not trusted and the requests fail like the service is not running
+func getTLSClient() *Client {
+	config, err := RPCTLS.ClientConfig()
+	if err != nil {
+		log.Debug("RPC client load TLS certificate failed:", err)
+		config = &tls.Config{RootCAs: x509.NewCertPool(), ServerName: tlsServerName}
+	}
+	transport := &http.Transport{TLSClientConfig: config}
+	url := RPCTLSHost + RPCPort
+	if RPCSocket != "" {
+		transport.Dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", RPCSocket)
+		}
+		url = SocketTLSHost
+	}
+	return &Client{url: url, token: readCookie(), client: &http.Client{Transport: transport}}
+}
+
+// Check if the SPV service is running and the client is authorized to access it
+func IsServiceRunning() bool {
+	client := GetClient()
+	if client.token == "" {
+		return false
+	}
+	_, err := client.GetChainHeight()
+	return err == nil
 }
 
 func (client *Client) NotifyNewAddress(hash []byte) error {
@@ -33,12 +88,22 @@ func (client *Client) NotifyNewAddress(hash []byte) error {
 }
 
 func (client *Client) SendTransaction(tx *tx.Transaction) error {
+	return client.SendTransactionWithKey("", tx)
+}
+
+// Send the transaction with an idempotency key, retry the call with the same key
+// after a network error so the transaction is not sent twice
+func (client *Client) SendTransactionWithKey(key string, tx *tx.Transaction) error {
 	buf := new(bytes.Buffer)
 	tx.Serialize(buf)
+	params := []interface{}{hex.EncodeToString(buf.Bytes())}
+	if key != "" {
+		params = append(params, key)
+	}
 	resp := client.send(
 		&Req{
 			Method: "sendtransaction",
-			Params: []interface{}{hex.EncodeToString(buf.Bytes())},
+			Params: params,
 		},
 	)
 	if resp.Code != 0 {
@@ -47,13 +112,342 @@ func (client *Client) SendTransaction(tx *tx.Transaction) error {
 	return nil
 }
 
+func (client *Client) ResetChainData() error {
+	resp := client.send(&Req{Method: "resetchaindata"})
+	if resp.Code != 0 {
+		return errors.New(resp.Result.(string))
+	}
+	return nil
+}
+
+func (client *Client) GetInfo() (*Info, error) {
+	info := new(Info)
+	err := client.call(&Req{Method: "getinfo"}, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (client *Client) GetPeers() ([]*p2p.PeerStats, error) {
+	var peers []*p2p.PeerStats
+	err := client.call(&Req{Method: "getpeers"}, &peers)
+	if err != nil {
+		return nil, err
+	}
+	return peers, nil
+}
+
+func (client *Client) GetPendingTxs() ([]*PendingTxInfo, error) {
+	var pending []*PendingTxInfo
+	err := client.call(&Req{Method: "getpendingtxs"}, &pending)
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// Get the pending transaction by the txid in reversed hex string
+func (client *Client) GetPendingTx(txId string) (*tx.Transaction, error) {
+	var rawHex string
+	err := client.call(&Req{Method: "getpendingtx", Params: []interface{}{txId}}, &rawHex)
+	if err != nil {
+		return nil, err
+	}
+	rawData, err := hex.DecodeString(rawHex)
+	if err != nil {
+		return nil, err
+	}
+	txn := new(tx.Transaction)
+	err = txn.Deserialize(bytes.NewReader(rawData))
+	if err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// Replace the pending transaction of the txid in reversed hex string with the new transaction
+func (client *Client) ReplaceTransaction(txId string, txn *tx.Transaction) error {
+	buf := new(bytes.Buffer)
+	err := txn.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return client.call(&Req{
+		Method: "replacetransaction",
+		Params: []interface{}{txId, hex.EncodeToString(buf.Bytes())},
+	}, nil)
+}
+
+// Keep the signed transaction in the SPV service and broadcast it when the chain passes it's lock time
+func (client *Client) ScheduleTransaction(txn *tx.Transaction) error {
+	buf := new(bytes.Buffer)
+	err := txn.Serialize(buf)
+	if err != nil {
+		return err
+	}
+	return client.call(&Req{
+		Method: "scheduletransaction",
+		Params: []interface{}{hex.EncodeToString(buf.Bytes())},
+	}, nil)
+}
+
+func (client *Client) GetScheduledTxs() ([]*ScheduledTxInfo, error) {
+	var scheduled []*ScheduledTxInfo
+	err := client.call(&Req{Method: "getscheduledtxs"}, &scheduled)
+	if err != nil {
+		return nil, err
+	}
+	return scheduled, nil
+}
+
+// Cancel the scheduled transaction by the txid in reversed hex string
+func (client *Client) CancelScheduledTx(txId string) error {
+	return client.call(&Req{Method: "cancelscheduledtx", Params: []interface{}{txId}}, nil)
+}
+
+// Get header by the block hash in reversed hex string or by the height
+func (client *Client) GetHeader(hashOrHeight string) (*HeaderInfo, error) {
+	header := new(HeaderInfo)
+	err := client.call(&Req{Method: "getheader", Params: []interface{}{hashOrHeight}}, header)
+	if err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (client *Client) AddAddress(address *common.Uint168, script []byte, addrType int) error {
+	return client.call(&Req{
+		Method: "addaddress",
+		Params: []interface{}{hex.EncodeToString(address.ToArray()), hex.EncodeToString(script), addrType},
+	}, nil)
+}
+
+func (client *Client) GetAddress(address *common.Uint168) (*db.Addr, error) {
+	info := new(AddrInfo)
+	err := client.call(&Req{
+		Method: "getaddress",
+		Params: []interface{}{hex.EncodeToString(address.ToArray())},
+	}, info)
+	if err != nil {
+		return nil, err
+	}
+	return FromAddrInfo(info)
+}
+
+func (client *Client) GetAddrs() ([]*db.Addr, error) {
+	var infos []*AddrInfo
+	err := client.call(&Req{Method: "getaddrs"}, &infos)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]*db.Addr, 0, len(infos))
+	for _, info := range infos {
+		addr, err := FromAddrInfo(info)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func (client *Client) DeleteAddress(address *common.Uint168) error {
+	return client.call(&Req{
+		Method: "deleteaddress",
+		Params: []interface{}{hex.EncodeToString(address.ToArray())},
+	}, nil)
+}
+
+func (client *Client) SetAddressLabel(address *common.Uint168, label string) error {
+	return client.call(&Req{
+		Method: "setaddresslabel",
+		Params: []interface{}{hex.EncodeToString(address.ToArray()), label},
+	}, nil)
+}
+
+func (client *Client) GetAddressUTXOs(address *common.Uint168) ([]*db.UTXO, error) {
+	var utxos []*db.UTXO
+	err := client.call(&Req{
+		Method: "getaddressutxos",
+		Params: []interface{}{hex.EncodeToString(address.ToArray())},
+	}, &utxos)
+	if err != nil {
+		return nil, err
+	}
+	return utxos, nil
+}
+
+func (client *Client) GetAddressSTXOs(address *common.Uint168) ([]*db.STXO, error) {
+	var stxos []*db.STXO
+	err := client.call(&Req{
+		Method: "getaddressstxos",
+		Params: []interface{}{hex.EncodeToString(address.ToArray())},
+	}, &stxos)
+	if err != nil {
+		return nil, err
+	}
+	return stxos, nil
+}
+
+func (client *Client) GetChainHeight() (uint32, error) {
+	var height uint32
+	err := client.call(&Req{Method: "getchainheight"}, &height)
+	return height, err
+}
+
+func (client *Client) DecodeRawTransaction(rawHex string) (*sdk.TransactionInfo, error) {
+	info := new(sdk.TransactionInfo)
+	err := client.call(&Req{Method: "decoderawtransaction", Params: []interface{}{rawHex}}, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (client *Client) EncodeTransaction(info *sdk.TransactionInfo) (string, error) {
+	var rawHex string
+	err := client.call(&Req{Method: "encodetransaction", Params: []interface{}{info}}, &rawHex)
+	return rawHex, err
+}
+
+func (client *Client) SetTxLabel(txId *common.Uint256, label *db.TxLabel) error {
+	tags := make([]interface{}, 0, len(label.Tags))
+	for _, tag := range label.Tags {
+		tags = append(tags, tag)
+	}
+	return client.call(&Req{
+		Method: "settxlabel",
+		Params: []interface{}{common.BytesToHexString(txId.BytesReverse()), label.Label, label.Category, tags},
+	}, nil)
+}
+
+func (client *Client) GetTxLabel(txId *common.Uint256) (*db.TxLabel, error) {
+	label := new(db.TxLabel)
+	err := client.call(&Req{
+		Method: "gettxlabel",
+		Params: []interface{}{common.BytesToHexString(txId.BytesReverse())},
+	}, label)
+	if err != nil {
+		return nil, err
+	}
+	return label, nil
+}
+
+func (client *Client) SetTxPayments(txId *common.Uint256, payments []*db.Payment) error {
+	return client.call(&Req{
+		Method: "settxpayments",
+		Params: []interface{}{common.BytesToHexString(txId.BytesReverse()), payments},
+	}, nil)
+}
+
+func (client *Client) GetTxPayments(txId *common.Uint256) ([]*db.Payment, error) {
+	var payments []*db.Payment
+	err := client.call(&Req{
+		Method: "gettxpayments",
+		Params: []interface{}{common.BytesToHexString(txId.BytesReverse())},
+	}, &payments)
+	return payments, err
+}
+
+func (client *Client) SearchTxs(query string) ([]*TxSearchResult, error) {
+	var results []*TxSearchResult
+	err := client.call(&Req{Method: "searchtxs", Params: []interface{}{query}}, &results)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (client *Client) GetJournal(seq uint64, limit int) ([]*db.JournalEntry, error) {
+	var entries []*db.JournalEntry
+	err := client.call(&Req{Method: "getjournal", Params: []interface{}{seq, limit}}, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Report the key operation with the result, the password is checked by the client and never sent, the
+// service sets the source of the entry
+func (client *Client) AuditKey(operation, result string) (*db.KeyAuditEntry, error) {
+	entry := new(db.KeyAuditEntry)
+	params := []interface{}{operation, result}
+	err := client.call(&Req{Method: "addkeyaudit", Params: params}, entry)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (client *Client) GetKeyAudit(seq uint64, limit int) ([]*db.KeyAuditEntry, error) {
+	var entries []*db.KeyAuditEntry
+	err := client.call(&Req{Method: "getkeyaudit", Params: []interface{}{seq, limit}}, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (client *Client) GetKeyFailures() (*KeyFailures, error) {
+	failures := new(KeyFailures)
+	err := client.call(&Req{Method: "getkeyfailures"}, failures)
+	if err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+func (client *Client) GetBalanceHistory(granularity uint32, from, to int64) ([]*BalancePoint, error) {
+	var points []*BalancePoint
+	err := client.call(&Req{Method: "getbalancehistory", Params: []interface{}{granularity, from, to}}, &points)
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+func (client *Client) PreviewTransaction(req *PreviewRequest) (*TxPreview, error) {
+	preview := new(TxPreview)
+	err := client.call(&Req{Method: "previewtransaction", Params: []interface{}{req}}, preview)
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// Send the request and decode the result into the given value, the result is ignored if value is nil
+func (client *Client) call(req *Req, result interface{}) error {
+	resp := client.send(req)
+	if resp.Code != 0 {
+		return errors.New(fmt.Sprint(resp.Result))
+	}
+	if result == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(resp.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
 func (client *Client) send(req *Req) (ret Resp) {
 	data, err := json.Marshal(req)
 	if err != nil {
 		return MarshalRequestError
 	}
 
-	resp, err := http.Post(client.url, "application/json", bytes.NewReader(data))
+	request, err := http.NewRequest("POST", client.url, bytes.NewReader(data))
+	if err != nil {
+		return PostRequestError
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", bearerPrefix+client.token)
+
+	resp, err := client.client.Do(request)
 	if err != nil {
 		return PostRequestError
 	}
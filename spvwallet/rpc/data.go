@@ -0,0 +1,346 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// Parse the date like "2018-06-01" or "2018-06-01T08:00:00Z" into unix time, in UTC if no zone given
+func ParseDate(value string) (uint32, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil && t.Unix() >= 0 {
+			return uint32(t.Unix()), nil
+		}
+	}
+	return 0, errors.New("invalid date " + value)
+}
+
+// Get the string parameter at the given index
+func stringParam(req Req, index int) (string, bool) {
+	if len(req.Params) <= index {
+		return "", false
+	}
+	value, ok := req.Params[index].(string)
+	return value, ok
+}
+
+// Get the program hash parameter in hex string at the given index
+func hashParam(req Req, index int) (*common.Uint168, bool) {
+	data, ok := stringParam(req, index)
+	if !ok {
+		return nil, false
+	}
+	hashBytes, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, false
+	}
+	hash, err := common.Uint168FromBytes(hashBytes)
+	if err != nil {
+		return nil, false
+	}
+	return hash, true
+}
+
+func ToAddrInfo(addr *db.Addr) *AddrInfo {
+	return &AddrInfo{
+		Hash:   hex.EncodeToString(addr.Hash().ToArray()),
+		Script: hex.EncodeToString(addr.Script()),
+		Type:   addr.Type(),
+		Label:  addr.Label(),
+	}
+}
+
+func FromAddrInfo(info *AddrInfo) (*db.Addr, error) {
+	hashBytes, err := hex.DecodeString(info.Hash)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := common.Uint168FromBytes(hashBytes)
+	if err != nil {
+		return nil, err
+	}
+	script, err := hex.DecodeString(info.Script)
+	if err != nil {
+		return nil, err
+	}
+	addr := db.NewAddr(hash, script, info.Type)
+	addr.SetLabel(info.Label)
+	return addr, nil
+}
+
+func (server *Server) AddAddress(req Req) Resp {
+	hash, ok := hashParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	data, ok := stringParam(req, 1)
+	if !ok {
+		return InvalidParameter
+	}
+	script, err := hex.DecodeString(data)
+	if err != nil {
+		return InvalidParameter
+	}
+	if len(req.Params) < 3 {
+		return InvalidParameter
+	}
+	// Numbers are decoded as float64 from JSON
+	addrType, ok := req.Params[2].(float64)
+	if !ok {
+		return InvalidParameter
+	}
+	err = server.data.AddAddress(hash, script, int(addrType))
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Address added")
+}
+
+func (server *Server) GetAddress(req Req) Resp {
+	hash, ok := hashParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	addr, err := server.data.GetAddress(hash)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(ToAddrInfo(addr))
+}
+
+func (server *Server) GetAddrs(req Req) Resp {
+	addrs, err := server.data.GetAddrs()
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	infos := make([]*AddrInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		infos = append(infos, ToAddrInfo(addr))
+	}
+	return Success(infos)
+}
+
+func (server *Server) DeleteAddress(req Req) Resp {
+	hash, ok := hashParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	err := server.data.DeleteAddress(hash)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Address deleted")
+}
+
+func (server *Server) SetAddressLabel(req Req) Resp {
+	hash, ok := hashParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	label, ok := stringParam(req, 1)
+	if !ok {
+		return InvalidParameter
+	}
+	err := server.data.SetAddressLabel(hash, label)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Address label set")
+}
+
+func (server *Server) GetAddressUTXOs(req Req) Resp {
+	hash, ok := hashParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	utxos, err := server.data.GetAddressUTXOs(hash)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(utxos)
+}
+
+func (server *Server) GetAddressSTXOs(req Req) Resp {
+	hash, ok := hashParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	stxos, err := server.data.GetAddressSTXOs(hash)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(stxos)
+}
+
+func (server *Server) SetTxLabel(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	label, ok := stringParam(req, 1)
+	if !ok {
+		return InvalidParameter
+	}
+	category, ok := stringParam(req, 2)
+	if !ok {
+		return InvalidParameter
+	}
+	txLabel := &db.TxLabel{Label: label, Category: category}
+	if len(req.Params) > 3 {
+		tags, ok := req.Params[3].([]interface{})
+		if !ok {
+			return InvalidParameter
+		}
+		for _, t := range tags {
+			tag, ok := t.(string)
+			if !ok {
+				return InvalidParameter
+			}
+			txLabel.Tags = append(txLabel.Tags, tag)
+		}
+	}
+	err := server.data.SetTxLabel(txId, txLabel)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Transaction label set")
+}
+
+func (server *Server) GetTxLabel(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	label, err := server.data.GetTxLabel(txId)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(label)
+}
+
+// Params are the txid and the payments of the batch transaction
+func (server *Server) SetTxPayments(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok || len(req.Params) < 2 {
+		return InvalidParameter
+	}
+	// The payments are decoded as maps, convert them to the structures
+	data, err := json.Marshal(req.Params[1])
+	if err != nil {
+		return InvalidParameter
+	}
+	var payments []*db.Payment
+	err = json.Unmarshal(data, &payments)
+	if err != nil {
+		return InvalidParameter
+	}
+	err = server.data.SetTxPayments(txId, payments)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Transaction payments set")
+}
+
+func (server *Server) GetTxPayments(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	payments, err := server.data.GetTxPayments(txId)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(payments)
+}
+
+func (server *Server) SearchTxs(req Req) Resp {
+	query, ok := stringParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	results, err := server.data.SearchTxs(query)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(results)
+}
+
+// Params are the sequence number to read after and the optional limit of entries
+func (server *Server) GetJournal(req Req) Resp {
+	if len(req.Params) < 1 {
+		return InvalidParameter
+	}
+	seq, ok := req.Params[0].(float64)
+	if !ok || seq < 0 {
+		return InvalidParameter
+	}
+	var limit float64
+	if len(req.Params) > 1 {
+		limit, ok = req.Params[1].(float64)
+		if !ok {
+			return InvalidParameter
+		}
+	}
+	entries, err := server.data.GetJournal(uint64(seq), int(limit))
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(entries)
+}
+
+// Params are the operation and the result, the source and time of the entry are set by the server
+func (server *Server) AddKeyAudit(req Req) Resp {
+	operation, ok := stringParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	result, ok := stringParam(req, 1)
+	if !ok {
+		return InvalidParameter
+	}
+	entry, err := server.handler.AuditKey(operation, result)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(entry)
+}
+
+// Params are the sequence number to read after and the optional limit of entries
+func (server *Server) GetKeyAudit(req Req) Resp {
+	if len(req.Params) < 1 {
+		return InvalidParameter
+	}
+	seq, ok := req.Params[0].(float64)
+	if !ok || seq < 0 {
+		return InvalidParameter
+	}
+	var limit float64
+	if len(req.Params) > 1 {
+		limit, ok = req.Params[1].(float64)
+		if !ok {
+			return InvalidParameter
+		}
+	}
+	entries, err := server.data.GetKeyAudit(uint64(seq), int(limit))
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(entries)
+}
+
+func (server *Server) GetKeyFailures(req Req) Resp {
+	failures, err := server.data.GetKeyFailures()
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(failures)
+}
+
+func (server *Server) GetChainHeight(req Req) Resp {
+	return Success(server.data.ChainHeight())
+}
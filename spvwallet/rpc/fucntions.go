@@ -3,8 +3,13 @@ package rpc
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"strconv"
 
+	"github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
 )
 
 func (server *Server) NotifyNewAddress(req Req) Resp {
@@ -37,9 +42,276 @@ func (server *Server) SendTransaction(req Req) Resp {
 	if err != nil {
 		return FunctionError("Deserialize transaction failed")
 	}
-	err = server.handler.SendTransaction(tx)
+	// The optional idempotency key of the client
+	key, _ := stringParam(req, 1)
+	err = server.handler.SendTransactionWithKey(key, tx)
 	if err != nil {
 		return FunctionError(err.Error())
 	}
 	return Success(tx.Hash().String())
 }
+
+func (server *Server) ResetChainData(req Req) Resp {
+	err := server.handler.ResetChainData()
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Chain data reset, rescan started")
+}
+
+func (server *Server) GetInfo(req Req) Resp {
+	info, err := server.handler.GetInfo()
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(info)
+}
+
+func (server *Server) GetPeers(req Req) Resp {
+	return Success(server.handler.GetPeersStats())
+}
+
+// Get header by the block hash in reversed hex string, by the height, or by the date
+// which gets the first block with the median timestamp not earlier than it
+func (server *Server) GetHeader(req Req) Resp {
+	if len(req.Params) == 0 {
+		return InvalidParameter
+	}
+	data, ok := req.Params[0].(string)
+	if !ok {
+		return InvalidParameter
+	}
+
+	var header *db.StoreHeader
+	if height, err := strconv.ParseUint(data, 10, 32); err == nil {
+		header, err = server.handler.GetHeaderByHeight(uint32(height))
+		if err != nil {
+			return FunctionError(err.Error())
+		}
+	} else if timestamp, err := ParseDate(data); err == nil {
+		height, err := server.handler.GetHeightByTime(timestamp)
+		if err != nil {
+			return FunctionError(err.Error())
+		}
+		header, err = server.handler.GetHeaderByHeight(height)
+		if err != nil {
+			return FunctionError(err.Error())
+		}
+	} else {
+		hashBytes, err := common.HexStringToBytesReverse(data)
+		if err != nil {
+			return InvalidParameter
+		}
+		hash, err := common.Uint256FromBytes(hashBytes)
+		if err != nil {
+			return InvalidParameter
+		}
+		header, err = server.handler.GetHeader(*hash)
+		if err != nil {
+			return FunctionError(err.Error())
+		}
+	}
+
+	return Success(ToHeaderInfo(header))
+}
+
+func (server *Server) GetPendingTxs(req Req) Resp {
+	return Success(server.handler.GetPendingTxs())
+}
+
+// Get the raw transaction in hex string of the pending transaction by the reversed hex txid
+func (server *Server) GetPendingTx(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	txn, err := server.handler.GetPendingTx(*txId)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	buf := new(bytes.Buffer)
+	err = txn.Serialize(buf)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(hex.EncodeToString(buf.Bytes()))
+}
+
+// Params are the seconds between the points, and the unix times of the first and the last point
+func (server *Server) GetBalanceHistory(req Req) Resp {
+	if len(req.Params) < 3 {
+		return InvalidParameter
+	}
+	granularity, ok := req.Params[0].(float64)
+	if !ok || granularity < 1 {
+		return InvalidParameter
+	}
+	from, ok := req.Params[1].(float64)
+	if !ok {
+		return InvalidParameter
+	}
+	to, ok := req.Params[2].(float64)
+	if !ok {
+		return InvalidParameter
+	}
+	points, err := server.handler.GetBalanceHistory(uint32(granularity), int64(from), int64(to))
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(points)
+}
+
+// The param is the PreviewRequest object
+func (server *Server) PreviewTransaction(req Req) Resp {
+	if len(req.Params) == 0 {
+		return InvalidParameter
+	}
+	// The request is decoded as a map, convert it to the structure
+	data, err := json.Marshal(req.Params[0])
+	if err != nil {
+		return InvalidParameter
+	}
+	previewReq := new(PreviewRequest)
+	err = json.Unmarshal(data, previewReq)
+	if err != nil {
+		return InvalidParameter
+	}
+	preview, err := server.handler.PreviewTransaction(previewReq)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(preview)
+}
+
+// Replace the pending transaction of the reversed hex txid with the raw transaction in hex string
+func (server *Server) ReplaceTransaction(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	data, ok := stringParam(req, 1)
+	if !ok {
+		return InvalidParameter
+	}
+	txBytes, err := hex.DecodeString(data)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	var txn tx.Transaction
+	err = txn.Deserialize(bytes.NewReader(txBytes))
+	if err != nil {
+		return FunctionError("Deserialize transaction failed")
+	}
+	err = server.handler.ReplaceTransaction(*txId, txn)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(common.BytesToHexString(txn.Hash().BytesReverse()))
+}
+
+// The param is the raw transaction in hex string with a lock time to broadcast it at
+func (server *Server) ScheduleTransaction(req Req) Resp {
+	data, ok := stringParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	txBytes, err := hex.DecodeString(data)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	var txn tx.Transaction
+	err = txn.Deserialize(bytes.NewReader(txBytes))
+	if err != nil {
+		return FunctionError("Deserialize transaction failed")
+	}
+	err = server.handler.ScheduleTransaction(txn)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(common.BytesToHexString(txn.Hash().BytesReverse()))
+}
+
+func (server *Server) GetScheduledTxs(req Req) Resp {
+	scheduled, err := server.handler.GetScheduledTxs()
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(scheduled)
+}
+
+// Cancel the scheduled transaction by the reversed hex txid before it's broadcasted
+func (server *Server) CancelScheduledTx(req Req) Resp {
+	txId, ok := txIdParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	err := server.handler.CancelScheduledTx(*txId)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success("Scheduled transaction canceled")
+}
+
+// Get the txid parameter in reversed hex string
+func txIdParam(req Req, index int) (*common.Uint256, bool) {
+	data, ok := stringParam(req, index)
+	if !ok {
+		return nil, false
+	}
+	hashBytes, err := common.HexStringToBytesReverse(data)
+	if err != nil {
+		return nil, false
+	}
+	hash, err := common.Uint256FromBytes(hashBytes)
+	if err != nil {
+		return nil, false
+	}
+	return hash, true
+}
+
+func ToHeaderInfo(header *db.StoreHeader) *HeaderInfo {
+	return &HeaderInfo{
+		Hash:       common.BytesToHexString(header.Hash().BytesReverse()),
+		Height:     header.Height,
+		Version:    header.Version,
+		Previous:   common.BytesToHexString(header.Previous.BytesReverse()),
+		MerkleRoot: common.BytesToHexString(header.MerkleRoot.BytesReverse()),
+		Timestamp:  header.Timestamp,
+		Bits:       header.Bits,
+		Nonce:      header.Nonce,
+		TotalWork:  header.TotalWork.String(),
+	}
+}
+
+func (server *Server) DecodeRawTransaction(req Req) Resp {
+	data, ok := stringParam(req, 0)
+	if !ok {
+		return InvalidParameter
+	}
+	info, err := sdk.DecodeRawTransaction(data)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(info)
+}
+
+func (server *Server) EncodeTransaction(req Req) Resp {
+	if len(req.Params) == 0 {
+		return InvalidParameter
+	}
+	// The transaction info is decoded as a map, convert it to the structure
+	data, err := json.Marshal(req.Params[0])
+	if err != nil {
+		return InvalidParameter
+	}
+	info := new(sdk.TransactionInfo)
+	err = json.Unmarshal(data, info)
+	if err != nil {
+		return InvalidParameter
+	}
+	rawHex, err := sdk.EncodeTransaction(info)
+	if err != nil {
+		return FunctionError(err.Error())
+	}
+	return Success(rawHex)
+}
@@ -1,8 +1,26 @@
 package rpc
 
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
 const (
-	RPCPort = "20877"
-	RPCAddr = "http://127.0.0.1:" + RPCPort
+	DefaultRPCPort = "20877"
+	RPCHost        = "http://127.0.0.1:"
+	RPCTLSHost     = "https://127.0.0.1:"
+	// Host in the URL of requests sent through unix socket, it is not used to dial
+	SocketHost    = "http://unix/"
+	SocketTLSHost = "https://unix/"
+)
+
+var (
+	// Port of the RPC server and client, set it before the server or client created
+	RPCPort = DefaultRPCPort
+	// Path of the unix socket the RPC server listens on, empty means localhost TCP on RPCPort
+	RPCSocket string
 )
 
 type Req struct {
@@ -29,8 +47,125 @@ var (
 	UnmarshalRequestError = Resp{404, "UnmarshalRequestError"}
 	InvalidMethod         = Resp{405, "InvalidMethod"}
 	InvalidParameter      = Resp{406, "InvalidParameter"}
+	Unauthorized          = Resp{408, "Unauthorized"}
 )
 
+// Info is the running status of the wallet service returned by getinfo
+type Info struct {
+	Magic            uint32 `json:"magic"`
+	ChainHeight      uint32 `json:"chainheight"`
+	HeaderHeight     uint32 `json:"headerheight"`
+	BestHash         string `json:"besthash"`
+	BestPeerHeight   uint64 `json:"bestpeerheight"`
+	PeersConnected   int    `json:"peersconnected"`
+	PeersEstablished int    `json:"peersestablished"`
+	Addresses        int    `json:"addresses"`
+	Healthy          bool   `json:"healthy"`
+	Ready            bool   `json:"ready"`
+	// The reason why the service is not ready, empty when it is ready
+	Reason string `json:"reason,omitempty"`
+}
+
+// HeaderInfo is the block header returned by getheader, hashes are reversed hex strings
+type HeaderInfo struct {
+	Hash       string `json:"hash"`
+	Height     uint32 `json:"height"`
+	Version    uint32 `json:"version"`
+	Previous   string `json:"previous"`
+	MerkleRoot string `json:"merkleroot"`
+	Timestamp  uint32 `json:"timestamp"`
+	Bits       uint32 `json:"bits"`
+	Nonce      uint32 `json:"nonce"`
+	TotalWork  string `json:"totalwork"`
+}
+
+// PendingTxInfo is a transaction sent by the wallet and not confirmed yet, it's rebroadcasted
+// on a backoff schedule and reported as stuck after some rebroadcasts
+type PendingTxInfo struct {
+	TxId        string    `json:"txid"`
+	Sent        time.Time `json:"sent"`
+	LastSent    time.Time `json:"lastsent"`
+	Rebroadcast int       `json:"rebroadcast"`
+	Stuck       bool      `json:"stuck"`
+	ReplacedBy  string    `json:"replacedby,omitempty"`
+}
+
+// ScheduledTxInfo is a signed transaction kept by the SPV service until the chain passes it's lock time,
+// the lock time is a block height, or a unix time if not less than 500000000
+type ScheduledTxInfo struct {
+	TxId     string `json:"txid"`
+	LockTime uint32 `json:"locktime"`
+	Created  uint32 `json:"created"`
+}
+
+// KeyFailures is the count of the failed password attempts since the last succeeded and the unix time of the last one
+type KeyFailures struct {
+	Failures uint32 `json:"failures"`
+	Last     int64  `json:"last"`
+}
+
+// AddrInfo is a wallet address transferred between the SPV service and clients,
+// hash and script are hex strings
+type AddrInfo struct {
+	Hash   string `json:"hash"`
+	Script string `json:"script"`
+	Type   int    `json:"type"`
+	Label  string `json:"label"`
+}
+
+// TxSearchResult is a transaction of the wallet matched by a search, txid is reversed hex string,
+// memo is the description attribute of the transaction and addresses are of the outputs
+type TxSearchResult struct {
+	TxId      string      `json:"txid"`
+	Height    uint32      `json:"height"`
+	Label     *db.TxLabel `json:"label,omitempty"`
+	Memo      string      `json:"memo,omitempty"`
+	Addresses []string    `json:"addresses"`
+}
+
+// BalancePoint is the wallet balance at the unix time, after the block of the height,
+// the height is 0 if the time is before the first block changed the balance
+type BalancePoint struct {
+	Time    int64          `json:"time"`
+	Height  uint32         `json:"height"`
+	Balance common.Fixed64 `json:"balance"`
+}
+
+// PreviewRequest is the transaction to preview by previewtransaction, values are in sela,
+// set fee or feerate per KB, utxos to spend are in format txid:index
+type PreviewRequest struct {
+	From        string           `json:"from"`
+	Outputs     []*PreviewOutput `json:"outputs"`
+	Fee         *common.Fixed64  `json:"fee,omitempty"`
+	FeeRate     *common.Fixed64  `json:"feerate,omitempty"`
+	LockedUntil uint32           `json:"lockeduntil,omitempty"`
+	UTXOs       []string         `json:"utxos,omitempty"`
+}
+
+// PreviewInput is an UTXO selected to spend, txid is reversed hex string
+type PreviewInput struct {
+	TxId  string         `json:"txid"`
+	Index uint16         `json:"index"`
+	Value common.Fixed64 `json:"value"`
+}
+
+type PreviewOutput struct {
+	Address string         `json:"address"`
+	Value   common.Fixed64 `json:"value"`
+}
+
+// TxPreview is a transaction created without signing or sending it, size is the signed size,
+// feerate is the fee per KB of it, warnings are the wallet policies the transaction breaks
+type TxPreview struct {
+	Inputs   []*PreviewInput  `json:"inputs"`
+	Outputs  []*PreviewOutput `json:"outputs"`
+	Change   *PreviewOutput   `json:"change,omitempty"`
+	Fee      common.Fixed64   `json:"fee"`
+	Size     int              `json:"size"`
+	FeeRate  common.Fixed64   `json:"feerate"`
+	Warnings []string         `json:"warnings"`
+}
+
 func Success(result interface{}) Resp {
 	return Resp{0, result}
 }
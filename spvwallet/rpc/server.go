@@ -1,29 +1,106 @@
 package rpc
 
 import (
+	"crypto/tls"
+	"net"
 	"net/http"
 	"io/ioutil"
 	"encoding/json"
 
+	"github.com/elastos/Elastos.ELA.SPV/common"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/db"
 	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+	walletdb "github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 	"os"
 )
 
 type RequestHandler interface {
 	NotifyNewAddress(hash []byte) error
 	SendTransaction(tx.Transaction) error
+	SendTransactionWithKey(key string, txn tx.Transaction) error
+	ResetChainData() error
+	GetInfo() (*Info, error)
+	GetPeersStats() []*p2p.PeerStats
+	GetHeader(hash common.Uint256) (*db.StoreHeader, error)
+	GetHeaderByHeight(height uint32) (*db.StoreHeader, error)
+	GetHeightByTime(timestamp uint32) (uint32, error)
+	GetPendingTxs() []*PendingTxInfo
+	GetPendingTx(txId common.Uint256) (*tx.Transaction, error)
+	ReplaceTransaction(txId common.Uint256, txn tx.Transaction) error
+	GetBalanceHistory(granularity uint32, from, to int64) ([]*BalancePoint, error)
+	PreviewTransaction(req *PreviewRequest) (*TxPreview, error)
+	ScheduleTransaction(txn tx.Transaction) error
+	GetScheduledTxs() ([]*ScheduledTxInfo, error)
+	CancelScheduledTx(txId common.Uint256) error
+	// Append the key operation of the client to the key audit log, the result is set by checking the password
+	AuditKey(operation, result string) (*walletdb.KeyAuditEntry, error)
 }
 
-func InitServer(handler RequestHandler) *Server {
+// DataHandler serves the wallet database to the clients, so the clients
+// operate on the running SPV service instead of opening the database directly
+type DataHandler interface {
+	AddAddress(address *common.Uint168, script []byte, addrType int) error
+	GetAddress(address *common.Uint168) (*walletdb.Addr, error)
+	GetAddrs() ([]*walletdb.Addr, error)
+	DeleteAddress(address *common.Uint168) error
+	SetAddressLabel(address *common.Uint168, label string) error
+	GetAddressUTXOs(address *common.Uint168) ([]*walletdb.UTXO, error)
+	GetAddressSTXOs(address *common.Uint168) ([]*walletdb.STXO, error)
+	SetTxLabel(txId *common.Uint256, label *walletdb.TxLabel) error
+	GetTxLabel(txId *common.Uint256) (*walletdb.TxLabel, error)
+	SetTxPayments(txId *common.Uint256, payments []*walletdb.Payment) error
+	GetTxPayments(txId *common.Uint256) ([]*walletdb.Payment, error)
+	SearchTxs(query string) ([]*TxSearchResult, error)
+	GetJournal(seq uint64, limit int) ([]*walletdb.JournalEntry, error)
+	GetKeyAudit(seq uint64, limit int) ([]*walletdb.KeyAuditEntry, error)
+	GetKeyFailures() (*KeyFailures, error)
+	ChainHeight() uint32
+}
+
+func InitServer(handler RequestHandler, data DataHandler) *Server {
 	server := new(Server)
-	server.Server = http.Server{Addr: ":" + RPCPort}
 	server.methods = map[string]func(Req) Resp{
-		"notifynewaddress": server.NotifyNewAddress,
-		"sendtransaction":  server.SendTransaction,
+		"notifynewaddress":     server.NotifyNewAddress,
+		"sendtransaction":      server.SendTransaction,
+		"resetchaindata":       server.ResetChainData,
+		"getinfo":              server.GetInfo,
+		"getpeers":             server.GetPeers,
+		"getheader":            server.GetHeader,
+		"getpendingtxs":        server.GetPendingTxs,
+		"getpendingtx":         server.GetPendingTx,
+		"replacetransaction":   server.ReplaceTransaction,
+		"getbalancehistory":    server.GetBalanceHistory,
+		"previewtransaction":   server.PreviewTransaction,
+		"scheduletransaction":  server.ScheduleTransaction,
+		"getscheduledtxs":      server.GetScheduledTxs,
+		"cancelscheduledtx":    server.CancelScheduledTx,
+		"addaddress":           server.AddAddress,
+		"getaddress":           server.GetAddress,
+		"getaddrs":             server.GetAddrs,
+		"deleteaddress":        server.DeleteAddress,
+		"setaddresslabel":      server.SetAddressLabel,
+		"getaddressutxos":      server.GetAddressUTXOs,
+		"getaddressstxos":      server.GetAddressSTXOs,
+		"settxlabel":           server.SetTxLabel,
+		"gettxlabel":           server.GetTxLabel,
+		"settxpayments":        server.SetTxPayments,
+		"gettxpayments":        server.GetTxPayments,
+		"searchtxs":            server.SearchTxs,
+		"getjournal":           server.GetJournal,
+		"addkeyaudit":          server.AddKeyAudit,
+		"getkeyaudit":          server.GetKeyAudit,
+		"getkeyfailures":       server.GetKeyFailures,
+		"getchainheight":       server.GetChainHeight,
+		"decoderawtransaction": server.DecodeRawTransaction,
+		"encodetransaction":    server.EncodeTransaction,
 	}
 	server.handler = handler
-	http.HandleFunc("/", server.handle)
+	server.data = data
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", server.handle)
+	server.Handler = mux
 	return server
 }
 
@@ -31,17 +108,72 @@ type Server struct {
 	http.Server
 	methods map[string]func(Req) Resp
 	handler RequestHandler
+	data    DataHandler
+	// Token clients must present, saved in the cookie file
+	token string
+}
+
+// Listen on the unix socket if RPCSocket is set, otherwise on localhost TCP
+func listen() (net.Listener, error) {
+	if RPCSocket == "" {
+		return net.Listen("tcp", "127.0.0.1:"+RPCPort)
+	}
+
+	// Remove the socket file left by last running
+	os.Remove(RPCSocket)
+	listener, err := net.Listen("unix", RPCSocket)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(RPCSocket, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
 }
 
 func (server *Server) Start() {
-	go func() {
-		err := server.ListenAndServe()
+	token, err := newCookie()
+	if err != nil {
+		log.Error("RPC service create cookie failed:", err)
+		os.Exit(800)
+	}
+	server.token = token
+
+	listener, err := listen()
+	if err != nil {
+		log.Error("RPC service start failed:", err)
+		os.Exit(800)
+	}
+	if RPCTLS != nil {
+		config, err := RPCTLS.ServerConfig()
 		if err != nil {
-			log.Error("RPC service start failed:", err)
+			listener.Close()
+			log.Error("RPC service load TLS certificate failed:", err)
+			os.Exit(800)
+		}
+		listener = tls.NewListener(listener, config)
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			log.Error("RPC service stopped:", err)
 			os.Exit(800)
 		}
 	}()
-	log.Debug("RPC server started...")
+	log.Debug("RPC server started on", listener.Addr())
+}
+
+// Close the server and remove the cookie file and unix socket
+func (server *Server) Close() error {
+	err := server.Server.Close()
+	os.Remove(CookiePath)
+	if RPCSocket != "" {
+		os.Remove(RPCSocket)
+	}
+	return err
 }
 
 func (server *Server) handle(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +190,10 @@ func (server *Server) getResp(r *http.Request) Resp {
 		return NonPostRequest
 	}
 
+	if !authorized(r, server.token) {
+		return Unauthorized
+	}
+
 	if r.Body == nil {
 		return EmptyRequestBody
 	}
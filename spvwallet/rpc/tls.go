@@ -0,0 +1,215 @@
+package rpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+const (
+	// Certificate and key files of the TLS servers in the data directory if not given
+	DefaultTLSCert = "tls.cert"
+	DefaultTLSKey  = "tls.key"
+
+	// Validity of the generated self-signed certificate
+	selfSignedValidity = 365 * 24 * time.Hour
+	// The certificate files are checked for replacement at most once in the interval
+	tlsReloadInterval = 10 * time.Second
+	// Name the RPC client verifies the server certificate for
+	tlsServerName = "localhost"
+)
+
+// TLS of the RPC server and client, nil means plain HTTP. Set it before the server or client created
+var RPCTLS *TLSConfig
+
+// TLSConfig is the certificate files of the TLS servers and the RPC client
+type TLSConfig struct {
+	// PEM certificate and key of the server, a self-signed certificate is generated if they don't exist
+	CertFile string
+	KeyFile  string
+	// PEM CAs of the client certificates, a client certificate signed by one of them is required if it's set
+	ClientCAFile string
+	// PEM certificate and key the client presents to the server requiring client certificates
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+/*
+Get the tls.Config of a server, the self-signed certificate is generated if the certificate and key files
+don't exist. The files are reloaded when they are replaced, so a renewed certificate or client CA takes
+effect on the new connections without restarting the server.
+*/
+func (c *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if !fileExists(c.CertFile) && !fileExists(c.KeyFile) {
+		if err := generateCert(c.CertFile, c.KeyFile); err != nil {
+			return nil, err
+		}
+		log.Infof("Self-signed TLS certificate generated to %s", c.CertFile)
+	}
+
+	r := &tlsReloader{settings: c}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		GetConfigForClient: r.configForClient,
+		GetCertificate:     r.certificate,
+		MinVersion:         tls.VersionTLS12,
+	}, nil
+}
+
+// Get the tls.Config of the RPC client, the server is trusted by its certificate file
+func (c *TLSConfig) ClientConfig() (*tls.Config, error) {
+	data, err := ioutil.ReadFile(c.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificate found in " + c.CertFile)
+	}
+	config := &tls.Config{RootCAs: roots, ServerName: tlsServerName, MinVersion: tls.VersionTLS12}
+	if c.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// tlsReloader serves the certificate and client CAs loaded from the files, and loads them again
+// after the files modified
+type tlsReloader struct {
+	settings *TLSConfig
+
+	lock    sync.Mutex
+	config  *tls.Config
+	modTime time.Time
+	checked time.Time
+}
+
+func (r *tlsReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := clock.Now()
+	if now.Sub(r.checked) >= tlsReloadInterval {
+		r.checked = now
+		if r.filesModTime().After(r.modTime) {
+			// A file may be in the middle of replacing, keep serving the loaded one
+			if err := r.load(); err != nil {
+				log.Warn("Reload TLS certificate failed, ", err)
+			} else {
+				log.Info("TLS certificate reloaded from", r.settings.CertFile)
+			}
+		}
+	}
+	return r.config, nil
+}
+
+func (r *tlsReloader) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	config, _ := r.configForClient(hello)
+	return &config.Certificates[0], nil
+}
+
+// Load the files, called with the lock held or before the reloader used
+func (r *tlsReloader) load() error {
+	modTime := r.filesModTime()
+	cert, err := tls.LoadX509KeyPair(r.settings.CertFile, r.settings.KeyFile)
+	if err != nil {
+		return err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if r.settings.ClientCAFile != "" {
+		data, err := ioutil.ReadFile(r.settings.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		cas := x509.NewCertPool()
+		if !cas.AppendCertsFromPEM(data) {
+			return errors.New("no certificate found in " + r.settings.ClientCAFile)
+		}
+		config.ClientCAs = cas
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	r.config = config
+	r.modTime = modTime
+	return nil
+}
+
+// Get the latest modification time of the files
+func (r *tlsReloader) filesModTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{r.settings.CertFile, r.settings.KeyFile, r.settings.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// Generate a self-signed certificate for the localhost and the host name, only the owner can read the key
+func generateCert(certFile, keyFile string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Elastos SPV"}, CommonName: tlsServerName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{tlsServerName},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, err := os.Hostname(); err == nil && host != tlsServerName {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/log"
+)
+
+// Write a self-signed client certificate, it's also the CA the server verifies the client by
+func writeClientCert(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSClientCA(t *testing.T) {
+	log.Init()
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := &TLSConfig{
+		CertFile:     filepath.Join(dir, DefaultTLSCert),
+		KeyFile:      filepath.Join(dir, DefaultTLSKey),
+		ClientCAFile: filepath.Join(dir, "client.cert"),
+	}
+	writeClientCert(t, settings.ClientCAFile, filepath.Join(dir, "client.key"))
+	serverConfig, err := settings.ServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }),
+		TLSConfig: serverConfig,
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+	url := "https://" + listener.Addr().String()
+
+	get := func(settings TLSConfig) error {
+		clientConfig, err := settings.ClientConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}, Timeout: time.Second * 5}
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	// A client without a certificate is rejected
+	if err := get(TLSConfig{CertFile: settings.CertFile}); err == nil {
+		t.Error("client without a certificate accepted")
+	}
+
+	// A client with the certificate signed by the CA is accepted
+	withCert := TLSConfig{
+		CertFile:       settings.CertFile,
+		ClientCertFile: settings.ClientCAFile,
+		ClientKeyFile:  filepath.Join(dir, "client.key"),
+	}
+	if err := get(withCert); err != nil {
+		t.Error("client with the certificate rejected,", err)
+	}
+}
@@ -0,0 +1,224 @@
+package spvwallet
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
+)
+
+// Default jitter of the job intervals, a run is delayed or advanced by up to this fraction of the interval
+const DefaultJitter = 0.1
+
+var jobBuckets = metrics.ExponentialBuckets(0.001, 4, 8)
+
+// A periodic background job of the scheduler
+type job struct {
+	name     string
+	interval time.Duration
+	jitter   float64
+	run      func() error
+	paused   bool
+	last     time.Time
+	err      error
+
+	runs     *metrics.Counter
+	failures *metrics.Counter
+	duration *metrics.Histogram
+}
+
+// JobInfo is the state of a scheduled job
+type JobInfo struct {
+	Name     string
+	Interval time.Duration
+	Paused   bool
+	LastRun  time.Time
+	LastErr  error
+}
+
+/*
+Scheduler runs the periodic background jobs of the wallet, like rebroadcast and peer checks,
+each job runs in it's own goroutine at the interval with jitter, so jobs of many wallets
+do not hit the network at the same time. Jobs can be paused and resumed by name, the runs,
+failures and duration of each job are collected as metrics labeled by the job name.
+*/
+type Scheduler struct {
+	lock    sync.Mutex
+	jobs    map[string]*job
+	stop    chan struct{}
+	running bool
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*job)}
+}
+
+// Add a job running at the interval with the jitter fraction, a job added after
+// the scheduler started is started at once, a job with the same name is replaced
+func (s *Scheduler) Add(name string, interval time.Duration, jitter float64, run func() error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	j := &job{
+		name:     name,
+		interval: interval,
+		jitter:   jitter,
+		run:      run,
+		runs:     metrics.NewCounter(`spv_job_runs_total{job="`+name+`"}`, "Runs of the scheduled jobs"),
+		failures: metrics.NewCounter(`spv_job_failures_total{job="`+name+`"}`, "Failed runs of the scheduled jobs"),
+		duration: metrics.NewHistogram(`spv_job_duration_seconds{job="`+name+`"}`, "Seconds a scheduled job run takes", jobBuckets),
+	}
+	s.jobs[name] = j
+	if s.running {
+		s.startLoop(j)
+	}
+}
+
+// Start running the jobs
+func (s *Scheduler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	for _, j := range s.jobs {
+		s.startLoop(j)
+	}
+}
+
+// Stop all the jobs, a running job is not interrupted
+func (s *Scheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stop)
+}
+
+// Pause the jobs of the names, or all jobs if no name given
+func (s *Scheduler) Pause(names ...string) {
+	s.setPaused(true, names)
+}
+
+// Resume the jobs of the names, or all jobs if no name given
+func (s *Scheduler) Resume(names ...string) {
+	s.setPaused(false, names)
+}
+
+func (s *Scheduler) setPaused(paused bool, names []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(names) == 0 {
+		for _, j := range s.jobs {
+			j.paused = paused
+		}
+		return
+	}
+	for _, name := range names {
+		if j, ok := s.jobs[name]; ok {
+			j.paused = paused
+		}
+	}
+}
+
+// Get the state of the jobs, sorted by name
+func (s *Scheduler) Jobs() []*JobInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobs := make([]*JobInfo, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, &JobInfo{
+			Name:     j.name,
+			Interval: j.interval,
+			Paused:   j.paused,
+			LastRun:  j.last,
+			LastErr:  j.err,
+		})
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs
+}
+
+// Start the loop of the job in a supervised goroutine, it's restarted if the job panicked
+func (s *Scheduler) startLoop(j *job) {
+	stop := s.stop
+	supervisor.Go("job "+j.name, func() { s.loop(j, stop) })
+}
+
+// Run the job at the interval with jitter until stopped or the job replaced
+func (s *Scheduler) loop(j *job, stop chan struct{}) {
+	for {
+		timer := clock.NewTimer(j.nextDelay())
+		select {
+		case <-timer.C():
+		case <-stop:
+			timer.Stop()
+			return
+		}
+
+		s.lock.Lock()
+		current := s.jobs[j.name] == j
+		paused := j.paused
+		s.lock.Unlock()
+		if !current {
+			return
+		}
+		if paused {
+			continue
+		}
+
+		start := clock.Now()
+		err := j.run()
+		j.duration.Observe(clock.Since(start).Seconds())
+		j.runs.Inc()
+		if err != nil {
+			j.failures.Inc()
+			log.Warnf("Scheduled job %s failed, %s", j.name, err)
+		}
+
+		s.lock.Lock()
+		j.last, j.err = start, err
+		s.lock.Unlock()
+	}
+}
+
+// Get the delay to the next run, the interval with the random jitter
+func (j *job) nextDelay() time.Duration {
+	if j.jitter <= 0 {
+		return j.interval
+	}
+	offset := (rand.Float64()*2 - 1) * j.jitter * float64(j.interval)
+	return j.interval + time.Duration(offset)
+}
+
+// Get the scheduler of the wallet background jobs, add a job to it to run it with the wallet
+func (wallet *SPVWallet) Scheduler() *Scheduler {
+	return wallet.scheduler
+}
+
+// Pause the network activity and the background jobs
+func (wallet *SPVWallet) Pause() {
+	wallet.scheduler.Pause()
+	wallet.SPVService.Pause()
+}
+
+// Resume the network activity and the background jobs
+func (wallet *SPVWallet) Resume() {
+	wallet.SPVService.Resume()
+	wallet.scheduler.Resume()
+}
@@ -0,0 +1,104 @@
+package spvwallet
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+/*
+Search the transactions of the wallet. The terms of the query separated by spaces must all match, a term
+like tag:<tag> or category:<category> matches the tag or the category exactly, other terms match a part of
+the txid, the label, category, tags or memo of the transaction, or the addresses of the outputs and their
+labels, case insensitive. An empty query matches all transactions, the latest first.
+*/
+func searchTxs(store db.DataStore, query string) ([]*rpc.TxSearchResult, error) {
+	txs, err := store.Txs().GetAll()
+	if err != nil {
+		return nil, err
+	}
+	labels, err := store.TxLabels().GetAll()
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := store.Addrs().GetAll()
+	if err != nil {
+		return nil, err
+	}
+	addrLabels := make(map[common.Uint168]string, len(addrs))
+	for _, addr := range addrs {
+		addrLabels[*addr.Hash()] = addr.Label()
+	}
+
+	terms := strings.Fields(strings.ToLower(query))
+	var results []*rpc.TxSearchResult
+	for _, storeTx := range txs {
+		result := &rpc.TxSearchResult{
+			TxId:   common.BytesToHexString(storeTx.TxId.BytesReverse()),
+			Height: storeTx.Height,
+			Label:  labels[storeTx.TxId],
+			Memo:   txMemo(&storeTx.Data),
+		}
+		// Text of the transaction matched by the terms without a qualifier
+		text := []string{result.TxId, result.Memo}
+		if result.Label != nil {
+			text = append(text, result.Label.Label, result.Label.Category, strings.Join(result.Label.Tags, " "))
+		}
+		for _, output := range storeTx.Data.Outputs {
+			address, err := output.ProgramHash.ToAddress()
+			if err != nil {
+				continue
+			}
+			result.Addresses = append(result.Addresses, address)
+			text = append(text, address, addrLabels[output.ProgramHash])
+		}
+
+		if matchTerms(terms, result.Label, strings.ToLower(strings.Join(text, "\n"))) {
+			results = append(results, result)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Height != results[j].Height {
+			return results[i].Height > results[j].Height
+		}
+		return results[i].TxId < results[j].TxId
+	})
+	return results, nil
+}
+
+// Check if all the lower case terms match the label or the text of a transaction
+func matchTerms(terms []string, label *db.TxLabel, text string) bool {
+	for _, term := range terms {
+		switch {
+		case strings.HasPrefix(term, "tag:"):
+			if label == nil || !label.HasTag(strings.TrimPrefix(term, "tag:")) {
+				return false
+			}
+		case strings.HasPrefix(term, "category:"):
+			if label == nil || !strings.EqualFold(label.Category, strings.TrimPrefix(term, "category:")) {
+				return false
+			}
+		default:
+			if !strings.Contains(text, term) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Get the memo of the transaction in the description attributes
+func txMemo(txn *tx.Transaction) string {
+	var memos []string
+	for _, attr := range txn.Attributes {
+		if attr.Usage == tx.Description {
+			memos = append(memos, string(attr.Data))
+		}
+	}
+	return strings.Join(memos, " ")
+}
@@ -0,0 +1,110 @@
+package spvwallet
+
+import (
+	"math/big"
+	"sort"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// Max recipients paid by one batch transaction
+const MaxBatchRecipients = 500
+
+/*
+Pay the recipients of the address and amount map in one transaction, for payout and payroll.
+The transaction is created with the options like CreateTransactionWithOptions(), the outputs
+are ordered by address and followed by a single change output back to the from address. It's
+signed and sent, and the payments are saved as the history records of the transaction, each
+one attributed a part of the fee in proportion to its amount.
+*/
+func (wallet *WalletImpl) SendMany(password []byte, fromAddress string, amounts map[string]*Fixed64, options *TxOptions) (*tx.Transaction, []*Payment, error) {
+	if len(amounts) == 0 {
+		return nil, nil, errors.Wrap(errors.ErrInvalid, "[Wallet], No recipients to pay")
+	}
+	if len(amounts) > MaxBatchRecipients {
+		return nil, nil, errors.Wrapf(errors.ErrInvalid, "[Wallet], Recipients more than %d", MaxBatchRecipients)
+	}
+
+	// Order the outputs by address, so the same batch creates the same transaction
+	addresses := make([]string, 0, len(amounts))
+	for address := range amounts {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	outputs := make([]*Output, 0, len(addresses))
+	payments := make([]*Payment, 0, len(addresses))
+	for _, address := range addresses {
+		amount := amounts[address]
+		if amount == nil || *amount <= 0 {
+			return nil, nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid amount to "+address)
+		}
+		outputs = append(outputs, &Output{Address: address, Value: amount})
+		payments = append(payments, &Payment{Address: address, Amount: *amount})
+	}
+
+	txn, err := wallet.CreateTransactionWithOptions(fromAddress, options, outputs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	preview, err := wallet.preview(fromAddress, options, txn, len(outputs))
+	if err != nil {
+		return nil, nil, err
+	}
+	attributeFee(preview.Fee, payments)
+
+	txn, err = wallet.Sign(password, txn)
+	if err != nil {
+		return nil, nil, err
+	}
+	haveSign, needSign, err := txn.GetSignStatus()
+	if err != nil {
+		return nil, nil, err
+	}
+	if haveSign < needSign {
+		return nil, nil, errors.New("[Wallet], Batch transaction needs more signatures to send")
+	}
+
+	// Save the payments before sending, so the history records exist once the transaction is seen,
+	// and delete them if the transaction is not sent
+	txId := txn.Hash()
+	err = wallet.SetTxPayments(txId, payments)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = wallet.SendTransaction(txn)
+	if err != nil {
+		if e := wallet.SetTxPayments(txId, nil); e != nil {
+			log.Errorf("Delete payments of transaction %s not sent failed, %s", txId.String(), e)
+		}
+		return nil, nil, err
+	}
+	return txn, payments, nil
+}
+
+// Split the fee over the payments in proportion to the amounts, the sela left by rounding
+// down are attributed one each to the first payments, so the parts sum up to the fee
+func attributeFee(fee Fixed64, payments []*Payment) {
+	total := new(big.Int)
+	for _, payment := range payments {
+		total.Add(total, big.NewInt(int64(payment.Amount)))
+	}
+	if total.Sign() <= 0 {
+		return
+	}
+
+	left := fee
+	for _, payment := range payments {
+		part := new(big.Int).Mul(big.NewInt(int64(fee)), big.NewInt(int64(payment.Amount)))
+		part.Quo(part, total)
+		payment.Fee = Fixed64(part.Int64())
+		left -= payment.Fee
+	}
+	for i := 0; left > 0; i = (i + 1) % len(payments) {
+		payments[i].Fee++
+		left--
+	}
+}
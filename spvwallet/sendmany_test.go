@@ -0,0 +1,43 @@
+package spvwallet
+
+import (
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+func TestAttributeFee(t *testing.T) {
+	for _, c := range []struct {
+		fee     Fixed64
+		amounts []Fixed64
+		expect  []Fixed64
+	}{
+		// In proportion to the amounts
+		{100, []Fixed64{300, 100}, []Fixed64{75, 25}},
+		// The sela left by rounding down go one each to the first payments
+		{10, []Fixed64{1, 1, 1}, []Fixed64{4, 3, 3}},
+		{2, []Fixed64{1, 1, 1}, []Fixed64{1, 1, 0}},
+		{7, []Fixed64{5, 3, 1}, []Fixed64{4, 3, 0}},
+		// Large amounts don't overflow
+		{1000, []Fixed64{1 << 62, 1 << 62}, []Fixed64{500, 500}},
+	} {
+		var payments []*Payment
+		for _, amount := range c.amounts {
+			payments = append(payments, &Payment{Amount: amount})
+		}
+		attributeFee(c.fee, payments)
+
+		var sum Fixed64
+		for i, payment := range payments {
+			sum += payment.Fee
+			if payment.Fee != c.expect[i] {
+				t.Errorf("fee %d of amounts %v attributed %d to payment %d, expect %d", c.fee, c.amounts,
+					payment.Fee, i, c.expect[i])
+			}
+		}
+		if sum != c.fee {
+			t.Errorf("fee %d of amounts %v attributed %d in total", c.fee, c.amounts, sum)
+		}
+	}
+}
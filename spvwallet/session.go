@@ -0,0 +1,229 @@
+package spvwallet
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+)
+
+// Where the key operations are from in the key audit log
+const (
+	AuditSourceCLI = "cli"
+	AuditSourceAPI = "api"
+)
+
+const (
+	// Max entries of the key audit log read at one time
+	MaxKeyAuditEntries = 1000
+
+	// Failed password attempts allowed without delay
+	FreeKeyAttempts = 3
+
+	// Delay of the next attempt after the free attempts failed, doubled by each more failure up to the max
+	KeyRetryDelay    = time.Second
+	MaxKeyRetryDelay = time.Hour
+)
+
+// The source of the key operations in this process, the CLI sets it to AuditSourceCLI
+var auditSource = AuditSourceAPI
+
+// Set where the key operations of the wallets in this process are from, recorded in the key audit log
+func SetAuditSource(source string) {
+	auditSource = source
+}
+
+/*
+The spending session of the wallet. Viewing the wallet, like the addresses, balances and
+transactions, needs no password, while the keys stay encrypted in the keystore file until the
+wallet is unlocked. Unlock() opens the keystore by the password for a duration, in which the
+signing methods given an empty password sign with the opened keys, and the keys are dropped
+when the duration expires or Lock() is called. Signing with an empty password while locked
+returns an error of errors.ErrLocked. Given the password, a signing method opens the keys for
+that call only and the wallet stays locked. While unlocked, the operations given the password
+check it against the opened keys and use them, so the accounts and the password they change are
+not overwritten by a later write of the session.
+
+Every operation on the keys is appended to the key audit log of the wallet database with the
+source and the result. Only the operations opening the keys by the password succeeded clear the
+failures, not the ones signing with the keys of the unlocked session. When the SPV service is
+running, the passwords are checked in this process and only the operations and the results are
+reported to it, the passwords are never sent. After FreeKeyAttempts wrong passwords in a row, the
+next attempt is refused with an error of errors.ErrThrottled until the retry delay passed since
+the last failure, the failures are counted in the database, so the delay holds across the CLI
+runs. The attempts in the process are serialized from the retry delay check to the audit, so
+the concurrent ones do not pass the check before the failures of each other are counted.
+*/
+type session struct {
+	database Database
+	// Directory of the keystore file, empty means the work directory
+	dir  string
+	lock sync.Mutex
+	// Held by a password attempt from the retry delay check to the audit
+	keyLock  sync.Mutex
+	unlocked Keystore
+	timer    clock.Timer
+	// Increased by each unlock, so the timer of an expired session does not lock a later one
+	serial uint64
+}
+
+// Unlock the wallet by the password for the duration, unlocking an unlocked wallet checks the
+// password and restarts the duration
+func (s *session) Unlock(password []byte, duration time.Duration) error {
+	if duration <= 0 {
+		return errors.Wrap(errors.ErrInvalid, "[Wallet], Unlock duration must be positive")
+	}
+	keyStore, err := s.openKeystore(password, db.KeyOpUnlock)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.close()
+	s.serial++
+	serial := s.serial
+	s.unlocked = keyStore
+	s.timer = clock.AfterFunc(duration, func() { s.expire(serial) })
+	log.Infof("Wallet unlocked for %s", duration)
+	return nil
+}
+
+// Lock the wallet and drop the opened keys, it does nothing but the audit if the wallet is locked
+func (s *session) Lock() {
+	s.lock.Lock()
+	s.close()
+	s.lock.Unlock()
+
+	s.audit(db.KeyOpLock, db.KeyResultOK)
+}
+
+func (s *session) IsLocked() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.unlocked == nil
+}
+
+// Get the keys opened by the password, or the keys of the unlocked session if the password is empty
+func (s *session) keystore(password []byte, operation string) (Keystore, error) {
+	if len(password) > 0 {
+		return s.openKeystore(password, operation)
+	}
+
+	s.lock.Lock()
+	keyStore := s.unlocked
+	s.lock.Unlock()
+	if keyStore == nil {
+		s.audit(operation, db.KeyResultLocked)
+		return nil, errors.Wrap(errors.ErrLocked, "[Wallet], Wallet is locked, unlock it or give the password")
+	}
+	// No password is checked, so it does not clear the failures
+	s.audit(operation, db.KeyResultUnlocked)
+	return keyStore, nil
+}
+
+// Open the keystore by the password for the operation, or check the password against the keys of the
+// unlocked session and return them. The password is not checked until the retry delay of the failed
+// attempts passed
+func (s *session) openKeystore(password []byte, operation string) (Keystore, error) {
+	s.keyLock.Lock()
+	defer s.keyLock.Unlock()
+
+	if err := s.checkRetryDelay(); err != nil {
+		s.audit(operation, db.KeyResultThrottled)
+		return nil, err
+	}
+
+	s.lock.Lock()
+	keyStore := s.unlocked
+	s.lock.Unlock()
+	var err error
+	if keyStore != nil {
+		err = keyStore.VerifyPassword(password)
+	} else {
+		keyStore, err = OpenKeystore(s.dir, password)
+	}
+	if err == ErrPasswordWrong {
+		s.audit(operation, db.KeyResultFailed)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.audit(operation, db.KeyResultOK)
+	return keyStore, nil
+}
+
+// Check the retry delay after the failed attempts passed, the attempt is refused if the
+// failures can not be read
+func (s *session) checkRetryDelay() error {
+	failures, err := s.database.GetKeyFailures()
+	if err != nil {
+		return err
+	}
+	if failures.Failures < FreeKeyAttempts {
+		return nil
+	}
+
+	delay := MaxKeyRetryDelay
+	if shift := failures.Failures - FreeKeyAttempts; shift < 32 && KeyRetryDelay<<shift < MaxKeyRetryDelay {
+		delay = KeyRetryDelay << shift
+	}
+	wait := time.Unix(failures.Last, 0).Add(delay).Sub(clock.Now())
+	if wait > 0 {
+		return errors.Wrapf(errors.ErrThrottled, "[Wallet], %d failed password attempts, retry after %s",
+			failures.Failures, wait.Truncate(time.Second)+time.Second)
+	}
+	return nil
+}
+
+// Lock the session of the serial when the unlock duration expired
+func (s *session) expire(serial uint64) {
+	s.lock.Lock()
+	expired := s.serial == serial && s.unlocked != nil
+	if expired {
+		s.close()
+	}
+	s.lock.Unlock()
+
+	if expired {
+		s.audit(db.KeyOpLock, db.KeyResultExpired)
+		log.Info("Wallet locked, unlock duration expired")
+	}
+}
+
+func (s *session) close() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.unlocked = nil
+}
+
+// Append the operation to the key audit log with the result, a failed write is logged and does not fail
+// the operation
+func (s *session) audit(operation, result string) {
+	if err := s.database.AuditKey(operation, result); err != nil {
+		log.Errorf("Append %s %s to key audit log failed, %s", operation, result, err)
+	}
+}
+
+// Append the key operation reported by an RPC client to the key audit log. The client checks the
+// password and reports only the result, the source is set here. Nothing is checked against the
+// keystore for the client, so the service answers no password attempts
+func (wallet *SPVWallet) AuditKey(operation, result string) (*db.KeyAuditEntry, error) {
+	if !db.IsKeyOperation(operation) {
+		return nil, errors.Wrapf(errors.ErrInvalid, "[Wallet], Unknown key operation %s", operation)
+	}
+	if !db.IsKeyResult(result) {
+		return nil, errors.Wrapf(errors.ErrInvalid, "[Wallet], Unknown key operation result %s", result)
+	}
+	entry := &db.KeyAuditEntry{Source: AuditSourceCLI, Operation: operation, Result: result}
+	if err := wallet.database.AddKeyAudit(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,347 @@
+package spvwallet
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+var testPassword = []byte("password")
+
+func TestMain(m *testing.M) {
+	log.Init()
+	os.Exit(m.Run())
+}
+
+// Create a keystore and a wallet database in a temp directory, the session runs on a mock clock
+func newTestSession(t *testing.T) (*session, *clock.Mock, func()) {
+	dir, err := ioutil.TempDir("", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CreateKeystore(dir, testPassword); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	store, err := db.NewSQLiteDB(dir, db.DurabilityAlways)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	system := clock.Get()
+	mock := clock.NewMock(time.Unix(1500000000, 0))
+	clock.Set(mock)
+
+	s := &session{database: &DatabaseImpl{lock: new(sync.RWMutex), DataStore: store}, dir: dir}
+	return s, mock, func() {
+		clock.Set(system)
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// Get the results of the key audit log in order
+func auditResults(t *testing.T, s *session) []string {
+	entries, err := s.database.GetKeyAudit(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, entry := range entries {
+		results = append(results, entry.Operation+" "+entry.Result)
+	}
+	return results
+}
+
+func TestSessionUnlock(t *testing.T) {
+	s, mock, cleanup := newTestSession(t)
+	defer cleanup()
+
+	// Signing with an empty password while locked
+	if _, err := s.keystore(nil, db.KeyOpSign); !errors.Is(err, errors.ErrLocked) {
+		t.Fatalf("locked keystore error %v, expect locked", err)
+	}
+	if err := s.Unlock([]byte("wrong"), time.Minute); err != ErrPasswordWrong {
+		t.Fatalf("unlock by wrong password error %v", err)
+	}
+	if err := s.Unlock(testPassword, 0); !errors.Is(err, errors.ErrInvalid) {
+		t.Fatalf("unlock for no duration error %v, expect invalid", err)
+	}
+
+	// The keys are opened until the duration expires
+	if err := s.Unlock(testPassword, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if s.IsLocked() {
+		t.Fatal("wallet locked after unlocked")
+	}
+	if keyStore, err := s.keystore(nil, db.KeyOpSign); err != nil || keyStore.MainAccount() == nil {
+		t.Fatalf("unlocked keystore %v, error %v", keyStore, err)
+	}
+	mock.Add(time.Minute - time.Second)
+	if s.IsLocked() {
+		t.Fatal("wallet locked before the duration expired")
+	}
+	mock.Add(time.Second)
+	if !s.IsLocked() {
+		t.Fatal("wallet unlocked after the duration expired")
+	}
+	if _, err := s.keystore(nil, db.KeyOpSign); !errors.Is(err, errors.ErrLocked) {
+		t.Fatalf("expired keystore error %v, expect locked", err)
+	}
+
+	// Lock drops the keys, and the timer of the locked session does not lock a later one
+	if err := s.Unlock(testPassword, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	s.Lock()
+	if !s.IsLocked() {
+		t.Fatal("wallet unlocked after locked")
+	}
+	if err := s.Unlock(testPassword, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	mock.Add(time.Minute)
+	if s.IsLocked() {
+		t.Fatal("wallet locked by the timer of an earlier unlock")
+	}
+
+	expect := []string{
+		"sign locked", "unlock failed", "unlock ok", "sign unlocked", "lock expired", "sign locked",
+		"unlock ok", "lock ok", "unlock ok",
+	}
+	results := auditResults(t, s)
+	if len(results) != len(expect) {
+		t.Fatalf("key audit log %v, expect %v", results, expect)
+	}
+	for i := range expect {
+		if results[i] != expect[i] {
+			t.Errorf("key audit log %v, expect %v", results, expect)
+			break
+		}
+	}
+}
+
+func TestSessionSharedKeystore(t *testing.T) {
+	s, _, cleanup := newTestSession(t)
+	defer cleanup()
+
+	if err := s.Unlock(testPassword, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	unlocked, err := s.keystore(nil, db.KeyOpNewAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The operations given the password while unlocked use the keys of the session
+	byPassword, err := s.keystore(testPassword, db.KeyOpNewAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byPassword != unlocked {
+		t.Fatal("keystore opened by the password is not the one of the session")
+	}
+	if _, err := s.keystore([]byte("wrong"), db.KeyOpNewAccount); err != ErrPasswordWrong {
+		t.Fatalf("wrong password error %v while unlocked", err)
+	}
+
+	// Interleaved writes by the password and by the session are all kept
+	first := byPassword.NewAccount()
+	second := unlocked.NewAccount()
+	newPassword := []byte("new password")
+	if err := byPassword.ChangePassword(testPassword, newPassword); err != nil {
+		t.Fatal(err)
+	}
+	third := unlocked.NewAccount()
+
+	if _, err := OpenKeystore(s.dir, testPassword); err != ErrPasswordWrong {
+		t.Fatalf("open by the old password error %v, the password change overwritten", err)
+	}
+	reopened, err := OpenKeystore(s.dir, newPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := reopened.GetAccounts()
+	if len(accounts) != 4 {
+		t.Fatalf("%d accounts in the reopened keystore, expect 4", len(accounts))
+	}
+	for i, account := range []*sdk.Account{first, second, third} {
+		if *accounts[i+1].ProgramHash() != *account.ProgramHash() {
+			t.Errorf("account %d %s in the reopened keystore, expect %s", i+1,
+				accounts[i+1].ProgramHash().String(), account.ProgramHash().String())
+		}
+	}
+}
+
+func TestSessionRetryDelay(t *testing.T) {
+	s, mock, cleanup := newTestSession(t)
+	defer cleanup()
+
+	wrong := []byte("wrong")
+	for i := 0; i < FreeKeyAttempts; i++ {
+		if err := s.Unlock(wrong, time.Minute); err != ErrPasswordWrong {
+			t.Fatalf("free attempt %d error %v", i, err)
+		}
+	}
+
+	// The delay after the free attempts doubles by each more failure
+	for i, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		mock.Add(delay - time.Second)
+		if err := s.Unlock(testPassword, time.Minute); !errors.Is(err, errors.ErrThrottled) {
+			t.Fatalf("attempt %d before the delay %s error %v, expect throttled", i, delay, err)
+		}
+		mock.Add(time.Second)
+		if err := s.Unlock(wrong, time.Minute); err != ErrPasswordWrong {
+			t.Fatalf("attempt %d after the delay %s error %v", i, delay, err)
+		}
+	}
+
+	// Signing with the keys of the unlocked session does not clear the failures
+	failures, err := s.database.GetKeyFailures()
+	if err != nil || failures.Failures != FreeKeyAttempts+3 {
+		t.Fatalf("key failures %v, error %v", failures, err)
+	}
+	mock.Add(8 * time.Second)
+	if err := s.Unlock(testPassword, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < FreeKeyAttempts; i++ {
+		if _, err := s.keystore(wrong, db.KeyOpSign); err != ErrPasswordWrong {
+			t.Fatalf("attempt %d error %v", i, err)
+		}
+		if _, err := s.keystore(nil, db.KeyOpSign); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.keystore(testPassword, db.KeyOpSign); !errors.Is(err, errors.ErrThrottled) {
+		t.Fatalf("attempt after signing unlocked error %v, expect throttled", err)
+	}
+
+	// The delay is capped
+	for i := 0; i < 40; i++ {
+		s.audit(db.KeyOpSign, db.KeyResultFailed)
+	}
+	mock.Add(MaxKeyRetryDelay - time.Second)
+	if err := s.Unlock(testPassword, time.Minute); !errors.Is(err, errors.ErrThrottled) {
+		t.Fatalf("attempt before the max delay error %v, expect throttled", err)
+	}
+	mock.Add(time.Second)
+	if err := s.Unlock(testPassword, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if failures, _ := s.database.GetKeyFailures(); failures.Failures != 0 {
+		t.Errorf("%d key failures after the password succeeded", failures.Failures)
+	}
+}
+
+// Database waiting a while after reading the failures for the other attempts to read them too
+type overlapDatabase struct {
+	Database
+	lock     sync.Mutex
+	arrived  int
+	attempts int
+	release  chan struct{}
+}
+
+func (db *overlapDatabase) GetKeyFailures() (*rpc.KeyFailures, error) {
+	failures, err := db.Database.GetKeyFailures()
+	db.lock.Lock()
+	db.arrived++
+	if db.arrived == db.attempts {
+		close(db.release)
+	}
+	db.lock.Unlock()
+
+	select {
+	case <-db.release:
+	case <-time.After(20 * time.Millisecond):
+	}
+	return failures, err
+}
+
+func TestSessionConcurrentAttempts(t *testing.T) {
+	s, mock, cleanup := newTestSession(t)
+	defer cleanup()
+
+	wrong := []byte("wrong")
+	for i := 0; i < FreeKeyAttempts; i++ {
+		if err := s.Unlock(wrong, time.Minute); err != ErrPasswordWrong {
+			t.Fatalf("free attempt %d error %v", i, err)
+		}
+	}
+	mock.Add(KeyRetryDelay)
+
+	// Only one of the concurrent attempts after the delay checks the password
+	const attempts = 8
+	s.database = &overlapDatabase{Database: s.database, attempts: attempts, release: make(chan struct{})}
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.keystore(wrong, db.KeyOpSign)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var failed, throttled int
+	for err := range errs {
+		switch {
+		case err == ErrPasswordWrong:
+			failed++
+		case errors.Is(err, errors.ErrThrottled):
+			throttled++
+		default:
+			t.Errorf("concurrent attempt error %v", err)
+		}
+	}
+	if failed != 1 || throttled != attempts-1 {
+		t.Errorf("%d attempts failed and %d throttled, expect 1 and %d", failed, throttled, attempts-1)
+	}
+}
+
+func TestWalletAuditKey(t *testing.T) {
+	s, _, cleanup := newTestSession(t)
+	defer cleanup()
+	wallet := &SPVWallet{database: s.database.(*DatabaseImpl)}
+
+	// The result reported by the client is recorded with the source set by the service
+	for _, c := range []struct {
+		operation string
+		result    string
+	}{
+		{db.KeyOpUnlock, db.KeyResultFailed},
+		{db.KeyOpSign, db.KeyResultThrottled},
+		{db.KeyOpLock, db.KeyResultOK},
+	} {
+		entry, err := wallet.AuditKey(c.operation, c.result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Source != AuditSourceCLI || entry.Operation != c.operation || entry.Result != c.result {
+			t.Errorf("%s %s audited %s %s %s", c.operation, c.result, entry.Source, entry.Operation, entry.Result)
+		}
+	}
+	if _, err := wallet.AuditKey("forged", db.KeyResultOK); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unknown operation error %v, expect invalid", err)
+	}
+	if _, err := wallet.AuditKey(db.KeyOpSign, "password"); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unknown result error %v, expect invalid", err)
+	}
+	expect := []string{"unlock failed", "sign throttled", "lock ok"}
+	if results := auditResults(t, s); len(results) != len(expect) {
+		t.Errorf("key audit log %v, expect %v", results, expect)
+	}
+}
@@ -1,69 +1,363 @@
 package spvwallet
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
 	"sync"
+	"time"
+	"sync/atomic"
 
 	"github.com/elastos/Elastos.ELA.SPV/bloom"
 	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/guard"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
 	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 	. "github.com/elastos/Elastos.ELA.SPV/db"
 	"github.com/elastos/Elastos.ELA.SPV/msg"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
 )
 
+// Apply the process wide settings in config, the data directory and RPC address,
+// it should be called before any database or log file opened. The files in the data
+// directory are opened by the paths of it, the work directory is not changed
+func Setup(cfg *config.Config) error {
+	if cfg.RPCPort != 0 {
+		rpc.RPCPort = fmt.Sprint(cfg.RPCPort)
+	}
+	rpc.RPCSocket = cfg.RPCSocket
+	rpc.RPCTLS = nil
+	if cfg.RPCTLS {
+		rpc.RPCTLS = tlsSettings(cfg)
+	}
+
+	rpc.CookiePath = cfg.Path(rpc.CookieFile)
+
+	if cfg.DataDir != "" {
+		return os.MkdirAll(cfg.DataDir, 0700)
+	}
+
+	return nil
+}
+
+// Get the TLS certificate files in config, the default files in the data directory if not set
+func tlsSettings(cfg *config.Config) *rpc.TLSConfig {
+	settings := &rpc.TLSConfig{
+		CertFile:       cfg.TLSCert,
+		KeyFile:        cfg.TLSKey,
+		ClientCAFile:   cfg.TLSClientCA,
+		ClientCertFile: cfg.TLSClientCert,
+		ClientKeyFile:  cfg.TLSClientKey,
+	}
+	if settings.CertFile == "" {
+		settings.CertFile = rpc.DefaultTLSCert
+	}
+	if settings.KeyFile == "" {
+		settings.KeyFile = rpc.DefaultTLSKey
+	}
+	for _, file := range []*string{&settings.CertFile, &settings.KeyFile, &settings.ClientCAFile,
+		&settings.ClientCertFile, &settings.ClientKeyFile} {
+		if *file != "" {
+			*file = cfg.Path(*file)
+		}
+	}
+	return settings
+}
+
+// Get the TLS config of the HTTP server on the address, nil if it's served without TLS. The server
+// is not started if the address is empty or the TLS certificate failed to load
+func serverTLS(cfg *config.Config, addr string, enabled bool, name string) (*tls.Config, bool) {
+	if addr == "" {
+		return nil, false
+	}
+	if !enabled {
+		return nil, true
+	}
+	tlsConfig, err := tlsSettings(cfg).ServerConfig()
+	if err != nil {
+		log.Error(name+" server load TLS certificate failed,", err)
+		return nil, false
+	}
+	return tlsConfig, true
+}
+
+// Initialize SPV wallet with the config read from config file and the given seeds
 func Init(clientId uint64, seeds []string) (*SPVWallet, error) {
+	cfg := *config.Values()
+	cfg.SeedList = seeds
+	wallet, err := InitWithConfig(clientId, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the changes when config file reloaded
+	config.AddChangeListener(wallet.onConfigChanged)
+
+	return wallet, nil
+}
+
+// Initialize SPV wallet with the given config
+func InitWithConfig(clientId uint64, cfg *config.Config) (*SPVWallet, error) {
 	var err error
 	wallet := new(SPVWallet)
+	wallet.config = cfg
+
+	panicPolicy, err := guard.ParsePolicy(cfg.PanicPolicy)
+	if err != nil {
+		return nil, err
+	}
+	guard.SetPolicy(panicPolicy)
+
+	// Lock the data directory before any database opened
+	wallet.dirLock, err = lockDataDir(cfg.DataDir, cfg.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the stored and received data in the format of the main chain or the sidechain
+	params, err := networkParams(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sdk.UseNetworkParams(params)
 
 	// Initialize headers db
-	wallet.headers, err = db.NewHeadersDB()
+	durability := db.DurabilityFromString(cfg.Durability)
+	wallet.headers, err = db.NewHeadersDB(cfg.DataDir, durability)
 	if err != nil {
 		return nil, err
 	}
 
 	// Initialize wallet database
-	wallet.dataStore, err = db.NewSQLiteDB()
+	wallet.dataStore, err = db.NewSQLiteDB(cfg.DataDir, durability)
 	if err != nil {
 		return nil, err
 	}
+	wallet.connected = wallet.dataStore.Info().ChainHeight()
+	wallet.filter = sdk.NewAddrFilter(nil)
+	wallet.webhooks = newWebhooks(wallet)
+	wallet.scheduler = NewScheduler()
+	wallet.scheduler.Add("rebroadcast", time.Second*rebroadcastCheckInterval, DefaultJitter, wallet.rebroadcast)
+	wallet.scheduler.Add("peers", time.Second*peersCheckInterval, DefaultJitter, wallet.webhooks.checkPeers)
+	wallet.scheduler.Add("addrs", time.Second*addrsCheckInterval, DefaultJitter, wallet.refreshAddrs)
 
 	// Initialize P2P network client
-	client, err := sdk.GetSPVClient(sdk.TypeMainNet, clientId, seeds)
+	magic := cfg.Magic
+	if magic == 0 {
+		magic = sdk.MainNetMagic
+	}
+	client, err := sdk.GetSPVClientWithConfig(p2pConfig(magic, cfg), clientId)
 	if err != nil {
 		return nil, err
 	}
+	wallet.client = client
+	if cfg.RecordFile != "" {
+		if err := wallet.startRecording(cfg.Path(cfg.RecordFile), magic); err != nil {
+			return nil, err
+		}
+	}
 
 	// Initialize spv service
 	wallet.SPVService, err = sdk.GetSPVService(client, wallet, wallet.getBloomFilter)
 	if err != nil {
 		return nil, err
 	}
+	wallet.Blockchain().SetMaxReorgDepth(cfg.MaxReorgDepth)
+	rules, err := networkRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	wallet.Blockchain().SetRules(rules)
+	wallet.Blockchain().SetConsensus(params.GetConsensus())
+	wallet.Blockchain().SetGenesis(params.Genesis)
+	arbiters, err := arbiterKeys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	wallet.SetArbiters(arbiters)
+	wallet.Arbiters().AddListener(wallet.webhooks)
+	wallet.SetStallTimeout(time.Minute * time.Duration(cfg.StallTimeout))
+	wallet.AddStallListener(wallet.webhooks)
+	if cfg.Metered {
+		wallet.SetMetered(true)
+	}
+	wallet.setDownloadLimits(cfg)
+	if cfg.PrivacyMode {
+		decoys := cfg.PrivacyDecoys
+		if decoys < 0 {
+			decoys = 0
+		}
+		if err := wallet.SetPrivacyMode(decoys, time.Minute*time.Duration(cfg.FilterRotation)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SplitFilter {
+		shards := cfg.FilterShards
+		if shards < 0 {
+			shards = 0
+		}
+		if err := wallet.SetSplitFilter(shards, cfg.FilterRedundancy, wallet.filterElements); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.FilterTuning {
+		threshold := cfg.FilterFPThreshold
+		if threshold < 0 {
+			threshold = 0
+		}
+		if err := wallet.SetFilterTuning(cfg.FilterFPRate, threshold, 0); err != nil {
+			return nil, err
+		}
+	}
+	if birthday := KeystoreBirthday(cfg.DataDir); birthday > 0 {
+		wallet.SetBirthday(uint32(birthday))
+	}
+	wallet.Blockchain().AddAlertListener(&storageFailures{wallet: wallet})
+	wallet.checkQuarantined()
 
 	// Initialize RPC server
-	wallet.rpcServer = rpc.InitServer(wallet)
+	wallet.database = &DatabaseImpl{lock: new(sync.RWMutex), DataStore: wallet.dataStore}
+	wallet.rpcServer = rpc.InitServer(wallet, wallet.database)
 
 	return wallet, nil
 }
 
 type SPVWallet struct {
+	// Increased when UTXOs or STXOs changed, with the address filter version
+	// to check if the cached bloom filter is outdated. Accessed atomically,
+	// keep it the first field to be 64-bit aligned
+	outPointsVersion uint64
+	// Height of the last block connected in the journal, accessed atomically
+	connected uint32
+
 	sync.Mutex
 	sdk.SPVService
-	rpcServer *rpc.Server
-	headers   db.Headers
-	dataStore db.DataStore
-	filter    *sdk.AddrFilter
+	configLock   sync.RWMutex
+	config       *config.Config
+	client       sdk.SPVClient
+	rpcServer    *rpc.Server
+	headers      db.Headers
+	dataStore    db.DataStore
+	database     *DatabaseImpl
+	filter       *sdk.AddrFilter
+	bloomFilter  *bloom.Filter
+	bloomVersion [3]uint64
+	bloomParams  sdk.FilterParams
+	metrics      *http.Server
+	debug        *http.Server
+	health       *http.Server
+	api          *http.Server
+	dirLock      *dirLock
+	recorder     *p2p.Recorder
+
+	// broadcasted transactions not confirmed yet
+	broadcastsLock  sync.Mutex
+	broadcasts      map[common.Uint256]*broadcast
+
+	webhooks  *webhooks
+	scheduler *Scheduler
+
+	idempotencyLock sync.Mutex
 }
 
+// Seconds between two checks of the addresses added to the database
+const addrsCheckInterval = 10
+
+var (
+	bloomElements  = metrics.NewGauge("spv_bloom_filter_elements", "Elements added to the bloom filter")
+	bloomSize      = metrics.NewGauge("spv_bloom_filter_size_bytes", "Size of the bloom filter")
+	bloomHashFuncs = metrics.NewGauge("spv_bloom_filter_hash_funcs", "Hash functions of the bloom filter")
+)
+
 func (wallet *SPVWallet) Start() {
+	cfg := wallet.Config()
+	if tlsConfig, ok := serverTLS(cfg, cfg.MetricsAddr, cfg.MetricsTLS, "Metrics"); ok {
+		wallet.registerMetrics()
+		wallet.metrics = metrics.Start(cfg.MetricsAddr, tlsConfig)
+	}
+	if tlsConfig, ok := serverTLS(cfg, cfg.DebugAddr, cfg.DebugTLS, "Debug"); ok {
+		wallet.debug = metrics.StartDebug(cfg.DebugAddr, tlsConfig)
+	}
+	if tlsConfig, ok := serverTLS(cfg, cfg.HealthAddr, cfg.HealthTLS, "Health"); ok {
+		wallet.health = wallet.startHealthServer(cfg.HealthAddr, tlsConfig)
+	}
+	if tlsConfig, ok := serverTLS(cfg, cfg.APIAddr, cfg.APITLS, "REST API"); ok {
+		wallet.api = wallet.startRESTServer(cfg.APIAddr, tlsConfig)
+	}
 	wallet.SPVService.Start()
 	wallet.rpcServer.Start()
+	wallet.webhooks.start()
+	wallet.scheduler.Start()
 }
 
 func (wallet *SPVWallet) Stop() {
+	wallet.scheduler.Stop()
+	wallet.webhooks.close()
 	wallet.SPVService.Stop()
 	wallet.rpcServer.Close()
+	if wallet.metrics != nil {
+		wallet.metrics.Close()
+	}
+	if wallet.debug != nil {
+		wallet.debug.Close()
+	}
+	if wallet.health != nil {
+		wallet.health.Close()
+	}
+	if wallet.api != nil {
+		wallet.api.Close()
+	}
+	if wallet.recorder != nil {
+		wallet.recorder.Close()
+	}
+	wallet.dirLock.release()
+}
+
+// Record the inbound peer messages to the file, a previous recording in it is replaced
+func (wallet *SPVWallet) startRecording(file string, magic uint32) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	wallet.recorder, err = p2p.NewRecorder(f, magic)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	wallet.client.PeerManager().SetRecorder(wallet.recorder)
+	return nil
+}
+
+// Register the metrics collected from the wallet status
+func (wallet *SPVWallet) registerMetrics() {
+	metrics.NewGaugeFunc("spv_chain_height", "Height of the synced chain", func() float64 {
+		return float64(wallet.GetChainHeight())
+	})
+	metrics.NewGaugeFunc("spv_header_height", "Height of the best header", func() float64 {
+		tip, err := wallet.headers.GetTip()
+		if err != nil {
+			return 0
+		}
+		return float64(tip.Height)
+	})
+	metrics.NewGaugeFunc("spv_peers_connected", "Connected peers", func() float64 {
+		return float64(wallet.client.PeerManager().PeersCount())
+	})
+	metrics.NewGaugeFunc(`spv_db_size_bytes{db="wallet"}`, "Size of the database files", func() float64 {
+		return metrics.FileSize(wallet.Config().Path(db.DBName))
+	})
+	metrics.NewGaugeFunc(`spv_db_size_bytes{db="headers"}`, "Size of the database files", func() float64 {
+		return metrics.FileSize(wallet.Config().Path(db.HeadersDBName))
+	})
 }
 
 func (wallet *SPVWallet) Headers() db.Headers {
@@ -76,7 +370,11 @@ func (wallet *SPVWallet) DataStore() db.DataStore {
 
 // Save a header to database
 func (wallet *SPVWallet) PutHeader(header *StoreHeader, newTip bool) error {
-	return wallet.headers.Put(header, newTip)
+	err := wallet.headers.Put(header, newTip)
+	if err != nil || !newTip {
+		return err
+	}
+	return wallet.journalConnect(header)
 }
 
 // Get previous block of the given header
@@ -94,9 +392,271 @@ func (wallet *SPVWallet) GetChainTip() (*StoreHeader, error) {
 	return wallet.headers.GetTip()
 }
 
+// Save the chain snapshot to headers database
+func (wallet *SPVWallet) PutChainSnapshot(snapshot *ChainSnapshot) error {
+	return wallet.headers.PutChainSnapshot(snapshot)
+}
+
+// Get the chain snapshot from headers database
+func (wallet *SPVWallet) GetChainSnapshot() (*ChainSnapshot, error) {
+	return wallet.headers.GetChainSnapshot()
+}
+
+// Save a pending block to headers database
+func (wallet *SPVWallet) PutPendingBlock(block *PendingBlock) error {
+	return wallet.headers.PutPendingBlock(block)
+}
+
+// Delete a pending block from headers database
+func (wallet *SPVWallet) DelPendingBlock(hash common.Uint256) error {
+	return wallet.headers.DelPendingBlock(hash)
+}
+
+// Get the pending blocks from headers database
+func (wallet *SPVWallet) GetPendingBlocks() ([]*PendingBlock, error) {
+	return wallet.headers.GetPendingBlocks()
+}
+
 // Save chain height to database
 func (wallet *SPVWallet) PutChainHeight(height uint32) {
 	wallet.dataStore.Info().SaveChainHeight(height)
+	wallet.pruneSTXOs(height)
+	wallet.webhooks.onChainHeight(height)
+	wallet.broadcastScheduled(height)
+
+	// Chain height is saved after a block committed, flush the block to disk
+	if err := wallet.headers.Sync(); err != nil {
+		log.Error("Sync headers db failed,", err)
+	}
+	if err := wallet.dataStore.Sync(); err != nil {
+		log.Error("Sync wallet db failed,", err)
+	}
+}
+
+// Create the peer to peer network config, connection limits not set in config use the default values
+func p2pConfig(magic uint32, cfg *config.Config) *p2p.Config {
+	p2pConfig := p2p.NewConfig(magic, cfg.SeedList)
+	if cfg.MinConnCount > 0 {
+		p2pConfig.MinConnCount = cfg.MinConnCount
+	}
+	if cfg.MaxOutboundCount > 0 {
+		p2pConfig.MaxOutboundCount = cfg.MaxOutboundCount
+	}
+	p2pConfig.TrustedPeers = cfg.TrustedPeers
+	p2pConfig.BannedSubnets = cfg.BannedSubnets
+	p2pConfig.OutboundOnly = cfg.PrivacyMode
+	p2pConfig.DataDir = cfg.DataDir
+	if len(cfg.PinnedPeers) > 0 {
+		p2pConfig.PinnedPeers = make(map[string][]byte)
+		for host, key := range cfg.PinnedPeers {
+			// Keep the peer pinned with an invalid key, so it fails the auth and is never connected
+			publicKey, err := hex.DecodeString(key)
+			if err != nil {
+				log.Error("Invalid public key of pinned peer ", host)
+			}
+			p2pConfig.PinnedPeers[host] = publicKey
+		}
+	}
+	return p2pConfig
+}
+
+// Get the config the wallet running with
+func (wallet *SPVWallet) Config() *config.Config {
+	wallet.configLock.RLock()
+	defer wallet.configLock.RUnlock()
+
+	return wallet.config
+}
+
+// Get the rules of the network in config with the rules in config added, and the activation heights overridden
+func networkRules(cfg *config.Config) (sdk.Rules, error) {
+	params := sdk.GetNetworkParams(cfg.Magic)
+	if cfg.SideChain {
+		params.SideChain = true
+	}
+	payloads := params.GetPayloads()
+
+	var added sdk.Rules
+	for _, r := range cfg.Rules {
+		rule := sdk.Rule{
+			Name:              r.Name,
+			Height:            r.Height,
+			MinHeaderVersion:  r.MinHeaderVersion,
+			MaxPayloadVersion: r.MaxPayloadVersion,
+		}
+	types:
+		for _, name := range r.TxTypes {
+			for txType, payloadType := range payloads {
+				if payloadType.Name == name {
+					rule.TxTypes = append(rule.TxTypes, txType)
+					continue types
+				}
+			}
+			return nil, fmt.Errorf("unknown transaction type %s of rule %s", name, r.Name)
+		}
+		if r.ReplayMarker != "" {
+			marker, err := hex.DecodeString(r.ReplayMarker)
+			if err != nil {
+				return nil, fmt.Errorf("invalid replay marker of rule %s, %s", r.Name, err)
+			}
+			rule.ReplayMarker = marker
+		}
+		added = append(added, rule)
+	}
+	return params.Rules.Merge(added).WithHeights(cfg.ActivationHeights), nil
+}
+
+// Get the network params of the magic number, with the genesis header and sidechain mode in config
+func networkParams(cfg *config.Config) (sdk.NetworkParams, error) {
+	params := sdk.GetNetworkParams(cfg.Magic)
+	if cfg.SideChain {
+		params.SideChain = true
+	}
+	if cfg.Genesis == "" {
+		return params, nil
+	}
+
+	data, err := hex.DecodeString(cfg.Genesis)
+	if err != nil {
+		return params, fmt.Errorf("invalid genesis header, %s", err)
+	}
+	// The genesis header is in the format of the chain
+	sdk.UseNetworkParams(params)
+	var genesis core.Header
+	if err := genesis.Deserialize(bytes.NewReader(data)); err != nil {
+		return params, fmt.Errorf("invalid genesis header, %s", err)
+	}
+	params.Genesis = &genesis
+	return params, nil
+}
+
+// Decode the public keys of the DPoS arbiters in config
+func arbiterKeys(cfg *config.Config) ([][]byte, error) {
+	var keys [][]byte
+	for _, arbiter := range cfg.Arbiters {
+		key, err := hex.DecodeString(arbiter)
+		if err != nil || len(key) != 33 {
+			return nil, fmt.Errorf("invalid arbiter public key %s", arbiter)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Set the limits of the blocks and transactions downloading and the memory buffering them
+func (wallet *SPVWallet) setDownloadLimits(cfg *config.Config) {
+	wallet.SetDownloadLimits(cfg.MaxInFlightBlocks, cfg.MaxInFlightTxs, int64(cfg.MemoryBudget)<<20)
+}
+
+// Apply the reloadable settings, log level, max reorganize depth, arbiters, metered connection, download limits, stall timeout, panic policy, peer limits and seed list, when config file changed.
+// The webhook settings and the fee policy are read from the config every time they are used
+func (wallet *SPVWallet) onConfigChanged(old, new *config.Config) {
+	wallet.configLock.Lock()
+	cfg := *new
+	// Settings can not be changed at runtime
+	cfg.Magic = wallet.config.Magic
+	cfg.Durability = wallet.config.Durability
+	cfg.Rules = wallet.config.Rules
+	cfg.ActivationHeights = wallet.config.ActivationHeights
+	cfg.PrivacyMode = wallet.config.PrivacyMode
+	cfg.PrivacyDecoys = wallet.config.PrivacyDecoys
+	cfg.FilterRotation = wallet.config.FilterRotation
+	cfg.SplitFilter = wallet.config.SplitFilter
+	cfg.FilterShards = wallet.config.FilterShards
+	cfg.FilterRedundancy = wallet.config.FilterRedundancy
+	cfg.FilterTuning = wallet.config.FilterTuning
+	cfg.FilterFPRate = wallet.config.FilterFPRate
+	cfg.FilterFPThreshold = wallet.config.FilterFPThreshold
+	wallet.config = &cfg
+	wallet.configLock.Unlock()
+
+	if new.MaxReorgDepth != old.MaxReorgDepth {
+		wallet.Blockchain().SetMaxReorgDepth(new.MaxReorgDepth)
+		log.Info("Max reorganize depth changed to", new.MaxReorgDepth)
+	}
+
+	if fmt.Sprint(new.Arbiters) != fmt.Sprint(old.Arbiters) {
+		if arbiters, err := arbiterKeys(new); err != nil {
+			log.Error("Keep the current arbiters, ", err)
+		} else {
+			wallet.SetArbiters(arbiters)
+		}
+	}
+
+	if new.Metered != old.Metered {
+		wallet.SetMetered(new.Metered)
+	}
+
+	if new.MaxInFlightBlocks != old.MaxInFlightBlocks || new.MaxInFlightTxs != old.MaxInFlightTxs ||
+		new.MemoryBudget != old.MemoryBudget {
+		wallet.setDownloadLimits(new)
+		log.Info("Download limits changed to", new.MaxInFlightBlocks, "blocks,", new.MaxInFlightTxs,
+			"transactions and", new.MemoryBudget, "MB")
+	}
+
+	if new.StallTimeout != old.StallTimeout {
+		wallet.SetStallTimeout(time.Minute * time.Duration(new.StallTimeout))
+		log.Info("Stall timeout changed to", new.StallTimeout, "minutes")
+	}
+
+	if new.PanicPolicy != old.PanicPolicy {
+		if policy, err := guard.ParsePolicy(new.PanicPolicy); err != nil {
+			log.Error("Keep the current panic policy, ", err)
+		} else {
+			guard.SetPolicy(policy)
+			log.Info("Panic policy changed to ", policy)
+		}
+	}
+
+	if new.MinFeeRate != old.MinFeeRate || new.MaxFeeRate != old.MaxFeeRate {
+		log.Info("Fee policy changed to min fee rate", new.MinFeeRate, "and max fee rate", new.MaxFeeRate)
+	}
+
+	if new.PrintLevel != old.PrintLevel {
+		log.SetLevel(new.PrintLevel)
+		log.Info("Print level changed to", new.PrintLevel)
+	}
+
+	peerManager := wallet.client.PeerManager()
+	peerConfig := p2pConfig(cfg.Magic, &cfg)
+	if len(peerConfig.SeedList) == 0 {
+		log.Warn("Seed list is empty in config file, keep the current seeds")
+		peerConfig.SeedList = peerManager.Config().SeedList
+	} else {
+		peerConfig.SeedList = sdk.ToSPVAddr(peerConfig.SeedList)
+	}
+	peerConfig.TrustedPeers = sdk.ToSPVAddr(peerConfig.TrustedPeers)
+	peerManager.UpdateConfig(peerConfig)
+}
+
+// Get the database durability configured in config file
+func durability() db.Durability {
+	return db.DurabilityFromString(config.Values().Durability)
+}
+
+// The data directory of the wallet opened without the SPV service, like by the CLI
+func dataDir() string {
+	return config.Values().DataDir
+}
+
+// Prune STXOs buried deeper than the configured depth
+func (wallet *SPVWallet) pruneSTXOs(height uint32) {
+	cfg := wallet.Config()
+	depth := cfg.STXOPruneDepth
+	if depth == 0 || height <= depth {
+		return
+	}
+
+	policy := db.PrunePolicyFromString(cfg.STXOPrunePolicy)
+	pruned, err := wallet.dataStore.STXOs().Prune(height-depth, policy)
+	if err != nil {
+		log.Error("Prune STXOs failed,", err)
+		return
+	}
+	if pruned > 0 {
+		atomic.AddUint64(&wallet.outPointsVersion, 1)
+		log.Debugf("Pruned %d STXOs with policy %s", pruned, policy)
+	}
 }
 
 // Get chain height from database
@@ -106,11 +666,13 @@ func (wallet *SPVWallet) GetChainHeight() uint32 {
 
 // Commit a transaction return if this is a false positive and error
 func (wallet *SPVWallet) CommitTx(storeTx *StoreTx) (bool, error) {
+	wallet.onTxConfirmed(&storeTx.Data)
+
 	hits := 0
 	// Save UTXOs
 	for index, output := range storeTx.Data.Outputs {
 		// Filter address
-		if wallet.getAddrFilter().ContainAddr(output.ProgramHash) {
+		if wallet.getAddrFilter().MatchOutput(output) {
 			var lockTime uint32
 			if storeTx.Data.TxType == tx.CoinBase {
 				lockTime = storeTx.Height + 100
@@ -120,6 +682,9 @@ func (wallet *SPVWallet) CommitTx(storeTx *StoreTx) (bool, error) {
 			if err != nil {
 				return false, err
 			}
+			if err := wallet.journalCredit(storeTx, index); err != nil {
+				return false, err
+			}
 			hits++
 		}
 	}
@@ -129,8 +694,15 @@ func (wallet *SPVWallet) CommitTx(storeTx *StoreTx) (bool, error) {
 		// Create output
 		outpoint := tx.NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex)
 		// Try to move UTXO to STXO, if a UTXO in database was spent, it will be moved to STXO
-		err := wallet.dataStore.STXOs().FromUTXO(outpoint, &storeTx.TxId, storeTx.Height)
+		utxo, err := wallet.dataStore.UTXOs().Get(outpoint)
+		if err != nil {
+			continue
+		}
+		err = wallet.dataStore.STXOs().FromUTXO(outpoint, &storeTx.TxId, storeTx.Height)
 		if err == nil {
+			if err := wallet.journalDebit(storeTx, utxo); err != nil {
+				return false, err
+			}
 			hits++
 		}
 	}
@@ -139,19 +711,36 @@ func (wallet *SPVWallet) CommitTx(storeTx *StoreTx) (bool, error) {
 	if hits == 0 {
 		return true, nil
 	}
+	atomic.AddUint64(&wallet.outPointsVersion, 1)
 
 	// Save transaction
 	err := wallet.dataStore.Txs().Put(storeTx)
 	if err != nil {
 		return false, err
 	}
+	wallet.webhooks.onTxCommitted(storeTx.TxId, storeTx.Height)
 
 	return false, nil
 }
 
+// Get the value of the unspent output of the wallet, false if it's not an unspent wallet output
+func (wallet *SPVWallet) GetOutputValue(op *tx.OutPoint) (common.Fixed64, bool) {
+	utxo, err := wallet.dataStore.UTXOs().Get(op)
+	if err != nil {
+		return 0, false
+	}
+	return utxo.Value, true
+}
+
 // Rollback chain data on the given height
 func (wallet *SPVWallet) Rollback(height uint32) error {
-	return wallet.dataStore.Rollback(height)
+	atomic.AddUint64(&wallet.outPointsVersion, 1)
+	err := wallet.dataStore.Rollback(height)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&wallet.connected, height-1)
+	return nil
 }
 
 // Reset database, clear all data
@@ -164,6 +753,39 @@ func (wallet *SPVWallet) Reset() error {
 	if err != nil {
 		return err
 	}
+	atomic.AddUint64(&wallet.outPointsVersion, 1)
+	atomic.StoreUint32(&wallet.connected, 0)
+	return nil
+}
+
+// Wipe headers, UTXOs, STXOs and transactions but keep the addresses,
+// then rescan the blockchain from the beginning
+func (wallet *SPVWallet) ResetChainData() error {
+	deferred, err := wallet.SPVService.ResetAndResync(func() error {
+		err := wallet.headers.Reset()
+		if err != nil {
+			return err
+		}
+		err = wallet.dataStore.ResetChainData()
+		if err != nil {
+			return err
+		}
+		atomic.AddUint64(&wallet.outPointsVersion, 1)
+		atomic.StoreUint32(&wallet.connected, 0)
+
+		// The transactions are synced again after a quarantine
+		wallet.SetHeadersOnly(false)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if deferred {
+		log.Info("Chain data reset, rescan deferred until the connection is not metered")
+	} else {
+		log.Info("Chain data reset, rescan started")
+	}
 	return nil
 }
 
@@ -184,20 +806,62 @@ func ToUTXO(txId common.Uint256, height uint32, index int, value common.Fixed64,
 
 func (wallet *SPVWallet) NotifyNewAddress(hash []byte) error {
 	// Reload address filter to include new address
+	return wallet.refreshAddrs()
+}
+
+/*
+Reload the address filter and the bloom filter on the peers if the addresses in the database changed, the
+addresses created by the wallet are refreshed by the periodic check without being notified. The recent blocks
+of RescanDepth are rescanned if any address of the wallet keys is added, addresses registered to be notified
+are new and not rescanned.
+*/
+func (wallet *SPVWallet) refreshAddrs() error {
+	addrs, err := wallet.dataStore.Addrs().GetAll()
+	if err != nil {
+		return err
+	}
+
+	filter := wallet.getAddrFilter()
+	var added, keys int
+	for _, addr := range addrs {
+		if filter.ContainAddr(*addr.Hash()) {
+			continue
+		}
+		added++
+		if addr.Type() != db.TypeNotify {
+			keys++
+		}
+	}
+	if added == 0 && len(addrs) == len(filter.GetAddrs()) {
+		return nil
+	}
+
 	wallet.loadAddrFilter()
-	// Broadcast filterload message to connected peers
-	wallet.BroadCastMessage(wallet.getBloomFilter().GetFilterLoadMsg())
+	var depth uint32
+	if keys > 0 {
+		depth = wallet.Config().RescanDepth
+	}
+	log.Infof("%d addresses added, %d of the wallet keys, reload the bloom filter", added, keys)
+	wallet.RefreshFilter(depth)
 	return nil
 }
 
 func (wallet *SPVWallet) SendTransaction(tx tx.Transaction) error {
+	if err := wallet.checkDoubleSpend(&tx); err != nil {
+		return err
+	}
+	if err := wallet.checkFeePolicy(&tx); err != nil {
+		return err
+	}
+
 	// Broadcast transaction to connected peers
+	wallet.trackBroadcast(tx)
 	wallet.BroadCastMessage(wallet.newTxnMsg(tx))
 	return nil
 }
 
 func (wallet *SPVWallet) getAddrFilter() *sdk.AddrFilter {
-	if wallet.filter == nil {
+	if !wallet.filter.IsLoaded() {
 		wallet.loadAddrFilter()
 	}
 	return wallet.filter
@@ -205,28 +869,48 @@ func (wallet *SPVWallet) getAddrFilter() *sdk.AddrFilter {
 
 func (wallet *SPVWallet) loadAddrFilter() *sdk.AddrFilter {
 	addrs, _ := wallet.dataStore.Addrs().GetAll()
-	wallet.filter = sdk.NewAddrFilter(nil)
+	hashes := make([]*common.Uint168, 0, len(addrs))
 	for _, addr := range addrs {
-		wallet.filter.AddAddr(addr.Hash())
+		hashes = append(hashes, addr.Hash())
 	}
+	wallet.filter.LoadAddrs(hashes)
 	return wallet.filter
 }
 
+// Get the bloom filter of the addresses and outpoints with the decoys of the privacy mode, built by the
+// parameters of the filter tuning, the last built bloom filter is returned if no address, outpoint or
+// parameter changed since then
 func (wallet *SPVWallet) getBloomFilter() *bloom.Filter {
 	wallet.Lock()
 	defer wallet.Unlock()
 
-	addrs := wallet.getAddrFilter().GetAddrs()
+	addrs, addrsVersion := wallet.getAddrFilter().Snapshot()
+	decoyAddrs, decoyOutPoints := wallet.FilterDecoys()
+	version := [3]uint64{addrsVersion, atomic.LoadUint64(&wallet.outPointsVersion),
+		uint64(len(decoyAddrs) + len(decoyOutPoints))}
+	params := wallet.FilterParams()
+	if wallet.bloomFilter != nil && version == wallet.bloomVersion && params == wallet.bloomParams {
+		return wallet.bloomFilter
+	}
+
 	utxos, _ := wallet.dataStore.UTXOs().GetAll()
 	stxos, _ := wallet.dataStore.STXOs().GetAll()
 
-	elements := uint32(len(addrs) + len(utxos) + len(stxos))
-	filter := sdk.NewBloomFilter(elements)
+	elements := uint32(len(addrs) + len(utxos) + len(stxos) + len(decoyAddrs) + len(decoyOutPoints))
+	filter := params.NewFilter(elements)
 
 	for _, addr := range addrs {
 		filter.Add(addr.ToArray())
 	}
 
+	for _, addr := range decoyAddrs {
+		filter.Add(addr.ToArray())
+	}
+
+	for _, op := range decoyOutPoints {
+		filter.AddOutPoint(op)
+	}
+
 	for _, utxo := range utxos {
 		filter.AddOutPoint(&utxo.Op)
 	}
@@ -235,9 +919,36 @@ func (wallet *SPVWallet) getBloomFilter() *bloom.Filter {
 		filter.AddOutPoint(&stxo.Op)
 	}
 
+	msg := filter.GetFilterLoadMsg()
+	bloomElements.Set(float64(elements))
+	bloomSize.Set(float64(len(msg.Filter)))
+	bloomHashFuncs.Set(float64(msg.HashFuncs))
+
+	wallet.bloomFilter = filter
+	wallet.bloomVersion = version
+	wallet.bloomParams = params
 	return filter
 }
 
+// Get the addresses and outpoints of the wallet, split to the shards of the bloom filter in split filter mode
+func (wallet *SPVWallet) filterElements() ([]*common.Uint168, []*tx.OutPoint) {
+	wallet.Lock()
+	addrs, _ := wallet.getAddrFilter().Snapshot()
+	wallet.Unlock()
+
+	utxos, _ := wallet.dataStore.UTXOs().GetAll()
+	stxos, _ := wallet.dataStore.STXOs().GetAll()
+
+	outPoints := make([]*tx.OutPoint, 0, len(utxos)+len(stxos))
+	for _, utxo := range utxos {
+		outPoints = append(outPoints, &utxo.Op)
+	}
+	for _, stxo := range stxos {
+		outPoints = append(outPoints, &stxo.Op)
+	}
+	return addrs, outPoints
+}
+
 func (wallet *SPVWallet) newTxnMsg(tx tx.Transaction) *msg.Txn {
 	return &msg.Txn{Transaction: tx}
 }
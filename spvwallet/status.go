@@ -0,0 +1,77 @@
+package spvwallet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	. "github.com/elastos/Elastos.ELA.SPV/db"
+	"github.com/elastos/Elastos.ELA.SPV/p2p"
+	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
+)
+
+// Get the running status of the wallet, chain heights, peers and sync state
+func (wallet *SPVWallet) GetInfo() (*rpc.Info, error) {
+	info := new(rpc.Info)
+	info.Magic = wallet.Config().Magic
+	if info.Magic == 0 {
+		info.Magic = sdk.MainNetMagic
+	}
+	info.ChainHeight = wallet.GetChainHeight()
+
+	tip, err := wallet.headers.GetTip()
+	if err == nil {
+		info.HeaderHeight = tip.Height
+		info.BestHash = common.BytesToHexString(tip.Hash().BytesReverse())
+	}
+
+	peerManager := wallet.client.PeerManager()
+	for _, peer := range peerManager.ConnectedPeers() {
+		info.PeersConnected++
+		if peer.State() == p2p.ESTABLISH {
+			info.PeersEstablished++
+		}
+	}
+	if bestPeer := peerManager.GetBestPeer(); bestPeer != nil {
+		info.BestPeerHeight = bestPeer.Height()
+	}
+
+	addrs, err := wallet.dataStore.Addrs().GetAll()
+	if err != nil {
+		return nil, err
+	}
+	info.Addresses = len(addrs)
+
+	info.Healthy = wallet.IsHealthy()
+	if err := wallet.CheckReady(); err != nil {
+		info.Reason = err.Error()
+	} else {
+		info.Ready = true
+	}
+
+	return info, nil
+}
+
+// Get the stats of connected peers
+func (wallet *SPVWallet) GetPeersStats() []*p2p.PeerStats {
+	return wallet.client.PeerManager().PeersStats()
+}
+
+// Get the header on the best chain with the given height
+func (wallet *SPVWallet) GetHeaderByHeight(height uint32) (*StoreHeader, error) {
+	header, err := wallet.headers.GetTip()
+	if err != nil {
+		return nil, err
+	}
+	if height > header.Height {
+		return nil, errors.New(fmt.Sprintf("Height %d is higher than the chain tip %d", height, header.Height))
+	}
+
+	return wallet.headers.GetByHeight(height)
+}
+
+// Get the height of the first block with the median timestamp not earlier than the unix time
+func (wallet *SPVWallet) GetHeightByTime(timestamp uint32) (uint32, error) {
+	return wallet.Blockchain().GetHeightByTime(timestamp)
+}
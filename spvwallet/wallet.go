@@ -3,9 +3,9 @@ package spvwallet
 import (
 	"math"
 	"bytes"
-	"errors"
 	"strconv"
 	"math/rand"
+	"time"
 
 	"github.com/elastos/Elastos.ELA.SPV/core/asset"
 	. "github.com/elastos/Elastos.ELA.SPV/common"
@@ -13,10 +13,12 @@ import (
 	"github.com/elastos/Elastos.ELA.SPV/core/transaction/payload"
 	pg "github.com/elastos/Elastos.ELA.SPV/core/contract/program"
 	tx "github.com/elastos/Elastos.ELA.SPV/core/transaction"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
 	. "github.com/elastos/Elastos.ELA.SPV/spvwallet/db"
 	"github.com/elastos/Elastos.ELA.SPV/log"
 	"github.com/elastos/Elastos.ELA.SPV/spvwallet/rpc"
 	"github.com/elastos/Elastos.ELA.SPV/sdk"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
 )
 
 var SystemAssetId = *getSystemAssetId()
@@ -26,6 +28,24 @@ type Output struct {
 	Value   *Fixed64
 }
 
+// Max times to create the transaction until the fee covers the transaction size
+const MaxFeeIterations = 5
+
+// Options to create a transaction with fee rate and coin control
+type TxOptions struct {
+	// Fixed fee of the transaction
+	Fee *Fixed64
+	// Fee per KB of the signed transaction size, used when Fee is not set
+	FeeRate *Fixed64
+	// The outputs can not be spent until this height
+	LockedUntil uint32
+	// The transaction can not be packed until the chain passes this height, or this unix time
+	// if not less than db.LockTimeThreshold, 0 means the current height
+	LockTime uint32
+	// Select inputs from these UTXOs only, nil means all available UTXOs of the spender
+	UTXOs []*tx.OutPoint
+}
+
 var wallet Wallet // Single instance of wallet
 
 type Wallet interface {
@@ -34,24 +54,42 @@ type Wallet interface {
 	VerifyPassword(password []byte) error
 	ChangePassword(oldPassword, newPassword []byte) error
 
+	Unlock(password []byte, duration time.Duration) error
+	Lock()
+	IsLocked() bool
+
 	NewSubAccount(password []byte) (*Uint168, error)
 	AddMultiSignAccount(M int, publicKey ...*crypto.PublicKey) (*Uint168, error)
+	ImportPrivateKey(password, privateKey []byte) (*Uint168, error)
+	ExportPrivateKey(password []byte, address *Uint168) ([]byte, error)
+	MasterPublicKey(password []byte) (*crypto.PublicKey, error)
+
+	SignMessage(password []byte, address string, message string) ([]byte, error)
+	VerifyMessage(address string, signature []byte, message string) error
 
 	CreateTransaction(fromAddress, toAddress string, amount, fee *Fixed64) (*tx.Transaction, error)
 	CreateLockedTransaction(fromAddress, toAddress string, amount, fee *Fixed64, lockedUntil uint32) (*tx.Transaction, error)
 	CreateMultiOutputTransaction(fromAddress string, fee *Fixed64, output ...*Output) (*tx.Transaction, error)
 	CreateLockedMultiOutputTransaction(fromAddress string, fee *Fixed64, lockedUntil uint32, output ...*Output) (*tx.Transaction, error)
+	CreateTransactionWithOptions(fromAddress string, options *TxOptions, output ...*Output) (*tx.Transaction, error)
+	PreviewTransaction(fromAddress string, options *TxOptions, output ...*Output) (*rpc.TxPreview, error)
+	CreateRecordTransaction(fromAddress string, fee *Fixed64, recordType string, recordData []byte) (*tx.Transaction, error)
 	Sign(password []byte, transaction *tx.Transaction) (*tx.Transaction, error)
 	SendTransaction(txn *tx.Transaction) error
+	ScheduleTransaction(txn *tx.Transaction) error
+	GetScheduledTxs() ([]*rpc.ScheduledTxInfo, error)
+	CancelScheduledTx(txId string) error
+	BumpFee(password []byte, txId string, feeRate *Fixed64) (*tx.Transaction, error)
+	SendMany(password []byte, fromAddress string, amounts map[string]*Fixed64, options *TxOptions) (*tx.Transaction, []*Payment, error)
 }
 
 type WalletImpl struct {
 	Database
-	Keystore
+	session
 }
 
 func Create(password []byte) (Wallet, error) {
-	keyStore, err := CreateKeystore(password)
+	keyStore, err := CreateKeystore(dataDir(), password)
 	if err != nil {
 		log.Error("Wallet create keystore failed:", err)
 		return nil, err
@@ -68,7 +106,7 @@ func Create(password []byte) (Wallet, error) {
 
 	wallet = &WalletImpl{
 		Database: database,
-		Keystore: keyStore,
+		session:  session{database: database, dir: dataDir()},
 	}
 	return wallet, nil
 }
@@ -83,27 +121,33 @@ func Open() (Wallet, error) {
 
 		wallet = &WalletImpl{
 			Database: database,
+			session:  session{database: database, dir: dataDir()},
 		}
 	}
 	return wallet, nil
 }
 
+// Verify the password of the keystore, the wallet is not unlocked by it
 func (wallet *WalletImpl) VerifyPassword(password []byte) error {
-	keyStore, err := OpenKeystore(password)
+	_, err := wallet.openKeystore(password, KeyOpVerifyPassword)
+	return err
+}
+
+func (wallet *WalletImpl) ChangePassword(oldPassword, newPassword []byte) error {
+	keyStore, err := wallet.openKeystore(oldPassword, KeyOpChangePassword)
 	if err != nil {
 		return err
 	}
-	wallet.Keystore = keyStore
-	return nil
+	return keyStore.ChangePassword(oldPassword, newPassword)
 }
 
 func (wallet *WalletImpl) NewSubAccount(password []byte) (*Uint168, error) {
-	err := wallet.VerifyPassword(password)
+	keyStore, err := wallet.keystore(password, KeyOpNewAccount)
 	if err != nil {
 		return nil, err
 	}
 
-	account := wallet.Keystore.NewAccount()
+	account := keyStore.NewAccount()
 	err = wallet.AddAddress(account.ProgramHash(), account.RedeemScript(), TypeSub)
 	if err != nil {
 		return nil, err
@@ -137,6 +181,77 @@ func (wallet *WalletImpl) AddMultiSignAccount(M int, publicKeys ...*crypto.Publi
 	return programHash, nil
 }
 
+func (wallet *WalletImpl) ImportPrivateKey(password, privateKey []byte) (*Uint168, error) {
+	keyStore, err := wallet.keystore(password, KeyOpImportKey)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := keyStore.ImportAccount(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	err = wallet.AddAddress(account.ProgramHash(), account.RedeemScript(), TypeImported)
+	if err != nil {
+		return nil, err
+	}
+
+	// Notify SPV service to reload bloom filter with the new address
+	rpc.GetClient().NotifyNewAddress(account.ProgramHash().ToArray())
+
+	return account.ProgramHash(), nil
+}
+
+func (wallet *WalletImpl) ExportPrivateKey(password []byte, address *Uint168) ([]byte, error) {
+	keyStore, err := wallet.keystore(password, KeyOpExportKey)
+	if err != nil {
+		return nil, err
+	}
+
+	account := keyStore.GetAccountByProgramHash(address)
+	if account == nil {
+		return nil, errors.Wrap(errors.ErrNotFound, "[Wallet], Account of the address not found in keystore")
+	}
+
+	return account.PrivateKey(), nil
+}
+
+// Get the public key of the main account, sub accounts are derived with the private key,
+// so there is no extended public key to derive addresses without the password
+func (wallet *WalletImpl) MasterPublicKey(password []byte) (*crypto.PublicKey, error) {
+	keyStore, err := wallet.keystore(password, KeyOpMasterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyStore.MainAccount().PublicKey(), nil
+}
+
+// Sign a message with the key of the address to prove the ownership of the address
+func (wallet *WalletImpl) SignMessage(password []byte, address string, message string) ([]byte, error) {
+	programHash, err := Uint168FromAddress(address)
+	if err != nil {
+		return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid address "+address)
+	}
+
+	keyStore, err := wallet.keystore(password, KeyOpSignMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	account := keyStore.GetAccountByProgramHash(programHash)
+	if account == nil {
+		return nil, errors.Wrap(errors.ErrNotFound, "[Wallet], Account of the address not found in keystore")
+	}
+
+	return account.SignMessage(message)
+}
+
+// Verify the message is signed by the owner of the address
+func (wallet *WalletImpl) VerifyMessage(address string, signature []byte, message string) error {
+	return sdk.VerifyMessage(address, signature, message)
+}
+
 func (wallet *WalletImpl) CreateTransaction(fromAddress, toAddress string, amount, fee *Fixed64) (*tx.Transaction, error) {
 	return wallet.CreateLockedTransaction(fromAddress, toAddress, amount, fee, uint32(0))
 }
@@ -150,19 +265,93 @@ func (wallet *WalletImpl) CreateMultiOutputTransaction(fromAddress string, fee *
 }
 
 func (wallet *WalletImpl) CreateLockedMultiOutputTransaction(fromAddress string, fee *Fixed64, lockedUntil uint32, outputs ...*Output) (*tx.Transaction, error) {
-	return wallet.createTransaction(fromAddress, fee, lockedUntil, outputs...)
+	// Check if output is valid
+	if len(outputs) == 0 {
+		return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid transaction target")
+	}
+	return wallet.createTransaction(fromAddress, fee, lockedUntil, nil, outputs...)
 }
 
-func (wallet *WalletImpl) createTransaction(fromAddress string, fee *Fixed64, lockedUntil uint32, outputs ...*Output) (*tx.Transaction, error) {
+func (wallet *WalletImpl) CreateTransactionWithOptions(fromAddress string, options *TxOptions, outputs ...*Output) (*tx.Transaction, error) {
 	// Check if output is valid
-	if outputs == nil || len(outputs) == 0 {
-		return nil, errors.New("[Wallet], Invalid transaction target")
+	if len(outputs) == 0 {
+		return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid transaction target")
+	}
+
+	if options.Fee != nil {
+		txn, err := wallet.createTransaction(fromAddress, options.Fee, options.LockedUntil, options.UTXOs, outputs...)
+		if err != nil {
+			return nil, err
+		}
+		setLockTime(txn, options.LockTime)
+		return txn, nil
 	}
 
+	if options.FeeRate == nil {
+		return nil, errors.New("[Wallet], Transaction fee or fee rate not set")
+	}
+
+	// Fee depends on the transaction size, and the size depends on the inputs selected by fee,
+	// start from the size of one input and create the transaction again until the fee covers the size
+	fee := FeeBySize(*options.FeeRate, tx.EstimateSize(1, len(outputs)+1, tx.StandardSig))
+	for i := 0; i < MaxFeeIterations; i++ {
+		txn, err := wallet.createTransaction(fromAddress, &fee, options.LockedUntil, options.UTXOs, outputs...)
+		if err != nil {
+			return nil, err
+		}
+		setLockTime(txn, options.LockTime)
+		required := FeeBySize(*options.FeeRate, EstimateSignedSize(txn))
+		if fee >= required {
+			return txn, nil
+		}
+		fee = required
+	}
+
+	return nil, errors.New("[Wallet], Calculate transaction fee failed")
+}
+
+// Set the lock time of the transaction, 0 keeps the current height set when it's created
+func setLockTime(txn *tx.Transaction, lockTime uint32) {
+	if lockTime > 0 {
+		txn.LockTime = lockTime
+	}
+}
+
+// Calculate the fee of the given size in bytes by the fee rate per KB
+func FeeBySize(feeRate Fixed64, size int) Fixed64 {
+	return tx.FeeBySize(feeRate, size)
+}
+
+// Estimate the transaction size after it fully signed
+func EstimateSignedSize(txn *tx.Transaction) int {
+	return txn.EstimateSignedSize()
+}
+
+// Create a record transaction carrying the record type and data, the fee is paid by the from address
+// and the change is sent back to it, the record can be used to notarize data on the chain
+func (wallet *WalletImpl) CreateRecordTransaction(fromAddress string, fee *Fixed64, recordType string, recordData []byte) (*tx.Transaction, error) {
+	record, err := payload.NewRecord(recordType, recordData)
+	if err != nil {
+		return nil, err
+	}
+
+	txn, err := wallet.createTransaction(fromAddress, fee, uint32(0), nil)
+	if err != nil {
+		return nil, err
+	}
+	txn.TxType = tx.Record
+	txn.PayloadVersion = payload.RecordPayloadVersion
+	txn.Payload = record
+	return txn, nil
+}
+
+// Create a transaction spending the UTXOs of the from address to the outputs with the fee,
+// the change is sent back to the from address
+func (wallet *WalletImpl) createTransaction(fromAddress string, fee *Fixed64, lockedUntil uint32, selected []*tx.OutPoint, outputs ...*Output) (*tx.Transaction, error) {
 	// Check if from address is valid
 	spender, err := Uint168FromAddress(fromAddress)
 	if err != nil {
-		return nil, errors.New("[Wallet], Invalid spender address")
+		return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid spender address")
 	}
 	// Create transaction outputs
 	var totalOutputValue = Fixed64(0) // The total value will be spend
@@ -172,7 +361,7 @@ func (wallet *WalletImpl) createTransaction(fromAddress string, fee *Fixed64, lo
 	for _, output := range outputs {
 		receiver, err := Uint168FromAddress(output.Address)
 		if err != nil {
-			return nil, errors.New("[Wallet], Invalid receiver address")
+			return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid receiver address")
 		}
 		txOutput := &tx.Output{
 			AssetID:     SystemAssetId,
@@ -189,7 +378,13 @@ func (wallet *WalletImpl) createTransaction(fromAddress string, fee *Fixed64, lo
 		return nil, errors.New("[Wallet], Get spender's UTXOs failed")
 	}
 	availableUTXOs := wallet.removeLockedUTXOs(utxos) // Remove locked UTXOs
-	availableUTXOs = SortUTXOs(availableUTXOs)        // Sort available UTXOs by value ASC
+	if selected != nil {
+		availableUTXOs, err = selectUTXOs(availableUTXOs, selected)
+		if err != nil {
+			return nil, err
+		}
+	}
+	availableUTXOs = SortUTXOs(availableUTXOs) // Sort available UTXOs by value ASC
 
 	// Create transaction inputs
 	var txInputs []*tx.Input // The inputs in transaction
@@ -213,7 +408,7 @@ func (wallet *WalletImpl) createTransaction(fromAddress string, fee *Fixed64, lo
 		}
 	}
 	if totalOutputValue > 0 {
-		return nil, errors.New("[Wallet], Available token is not enough")
+		return nil, errors.Wrap(errors.ErrInsufficientFunds, "[Wallet], Available token is not enough")
 	}
 
 	addr, err := wallet.GetAddress(spender)
@@ -221,15 +416,20 @@ func (wallet *WalletImpl) createTransaction(fromAddress string, fee *Fixed64, lo
 		return nil, errors.New("[Wallet], Get spenders redeem script failed")
 	}
 
-	return wallet.newTransaction(addr.Script(), txInputs, txOutputs), nil
+	return wallet.newTransaction(addr.Script(), txInputs, txOutputs)
 }
 
+// Sign the transaction with the keys opened by the password, or with the keys of the unlocked
+// session if the password is empty
 func (wallet *WalletImpl) Sign(password []byte, txn *tx.Transaction) (*tx.Transaction, error) {
-	// Verify password
-	err := wallet.VerifyPassword(password)
+	keyStore, err := wallet.keystore(password, KeyOpSign)
 	if err != nil {
 		return nil, err
 	}
+	return wallet.sign(keyStore, txn)
+}
+
+func (wallet *WalletImpl) sign(keyStore Keystore, txn *tx.Transaction) (*tx.Transaction, error) {
 	// Get sign type
 	signType, err := txn.GetTransactionType()
 	if err != nil {
@@ -239,7 +439,7 @@ func (wallet *WalletImpl) Sign(password []byte, txn *tx.Transaction) (*tx.Transa
 	if signType == tx.STANDARD {
 
 		// Sign single transaction
-		txn, err = wallet.signStandardTransaction(txn)
+		txn, err = wallet.signStandardTransaction(keyStore, txn)
 		if err != nil {
 			return nil, err
 		}
@@ -247,7 +447,7 @@ func (wallet *WalletImpl) Sign(password []byte, txn *tx.Transaction) (*tx.Transa
 	} else if signType == tx.MULTISIG {
 
 		// Sign multi sign transaction
-		txn, err = wallet.signMultiSigTransaction(txn)
+		txn, err = wallet.signMultiSigTransaction(keyStore, txn)
 		if err != nil {
 			return nil, err
 		}
@@ -256,13 +456,13 @@ func (wallet *WalletImpl) Sign(password []byte, txn *tx.Transaction) (*tx.Transa
 	return txn, nil
 }
 
-func (wallet *WalletImpl) signStandardTransaction(txn *tx.Transaction) (*tx.Transaction, error) {
+func (wallet *WalletImpl) signStandardTransaction(keyStore Keystore, txn *tx.Transaction) (*tx.Transaction, error) {
 	// Get signer
 	programHash, err := txn.GetStandardSigner()
 	// Check if current user is a valid signer
-	account := wallet.Keystore.GetAccountByProgramHash(programHash)
+	account := keyStore.GetAccountByProgramHash(programHash)
 	if account == nil {
-		return nil, errors.New("[Wallet], Invalid signer")
+		return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid signer")
 	}
 	// Sign transaction
 	signedTx, err := account.SignTx(txn)
@@ -281,7 +481,7 @@ func (wallet *WalletImpl) signStandardTransaction(txn *tx.Transaction) (*tx.Tran
 	return txn, nil
 }
 
-func (wallet *WalletImpl) signMultiSigTransaction(txn *tx.Transaction) (*tx.Transaction, error) {
+func (wallet *WalletImpl) signMultiSigTransaction(keyStore Keystore, txn *tx.Transaction) (*tx.Transaction, error) {
 	// Check if current user is a valid signer
 	var signerIndex = -1
 	programHashes, err := txn.GetMultiSignSigners()
@@ -290,14 +490,14 @@ func (wallet *WalletImpl) signMultiSigTransaction(txn *tx.Transaction) (*tx.Tran
 	}
 	var account *sdk.Account
 	for i, programHash := range programHashes {
-		account = wallet.Keystore.GetAccountByProgramHash(programHash)
+		account = keyStore.GetAccountByProgramHash(programHash)
 		if account != nil {
 			signerIndex = i
 			break
 		}
 	}
 	if signerIndex == -1 {
-		return nil, errors.New("[Wallet], Invalid multi sign signer")
+		return nil, errors.Wrap(errors.ErrInvalid, "[Wallet], Invalid multi sign signer")
 	}
 	// Sign transaction
 	signedTx, err := account.SignTx(txn)
@@ -321,6 +521,131 @@ func (wallet *WalletImpl) SendTransaction(txn *tx.Transaction) error {
 	return nil
 }
 
+// Keep the signed transaction in the running SPV service and broadcast it when the chain passes it's lock time
+func (wallet *WalletImpl) ScheduleTransaction(txn *tx.Transaction) error {
+	return rpc.GetClient().ScheduleTransaction(txn)
+}
+
+// Get the transactions scheduled in the running SPV service
+func (wallet *WalletImpl) GetScheduledTxs() ([]*rpc.ScheduledTxInfo, error) {
+	return rpc.GetClient().GetScheduledTxs()
+}
+
+// Cancel the scheduled transaction by the txid in reversed hex string before it's broadcasted
+func (wallet *WalletImpl) CancelScheduledTx(txId string) error {
+	return rpc.GetClient().CancelScheduledTx(txId)
+}
+
+/*
+Replace a pending transaction sent by the wallet with a higher fee. The transaction is rebuilt
+spending the same inputs with the same outputs, the fee increased by the fee rate per KB is taken
+from the change output, then it's signed and sent to replace the pending one. The pending
+transaction must be sent through the running SPV service.
+*/
+func (wallet *WalletImpl) BumpFee(password []byte, txId string, feeRate *Fixed64) (*tx.Transaction, error) {
+	keyStore, err := wallet.keystore(password, KeyOpSign)
+	if err != nil {
+		return nil, err
+	}
+
+	client := rpc.GetClient()
+	pending, err := client.GetPendingTx(txId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the UTXOs spent by the pending transaction to get the fee and the spender
+	addrs, err := wallet.GetAddrs()
+	if err != nil {
+		return nil, err
+	}
+	utxos := make(map[tx.OutPoint]*UTXO)
+	owners := make(map[tx.OutPoint]*Addr)
+	for _, addr := range addrs {
+		addrUTXOs, err := wallet.GetAddressUTXOs(addr.Hash())
+		if err != nil {
+			return nil, err
+		}
+		for _, utxo := range addrUTXOs {
+			utxos[utxo.Op] = utxo
+			owners[utxo.Op] = addr
+		}
+	}
+	var spender *Addr
+	var inputs []*tx.Input
+	var oldFee Fixed64
+	for _, input := range pending.Inputs {
+		op := tx.NewOutPoint(input.ReferTxID, input.ReferTxOutputIndex)
+		utxo, ok := utxos[*op]
+		if !ok {
+			return nil, errors.Wrap(errors.ErrNotFound, "[Wallet], Input of the transaction not found in wallet")
+		}
+		if spender == nil {
+			spender = owners[*op]
+		}
+		oldFee += utxo.Value
+		inputs = append(inputs, input)
+	}
+	if spender == nil {
+		return nil, errors.New("[Wallet], Transaction has no inputs")
+	}
+
+	// Keep the outputs and find the change output back to the spender
+	var outputs []*tx.Output
+	var change *tx.Output
+	for _, output := range pending.Outputs {
+		oldFee -= output.Value
+		copied := *output
+		outputs = append(outputs, &copied)
+		if output.ProgramHash == *spender.Hash() {
+			change = &copied
+		}
+	}
+
+	txn, err := wallet.newTransaction(spender.Script(), inputs, outputs)
+	if err != nil {
+		return nil, err
+	}
+	newFee := FeeBySize(*feeRate, EstimateSignedSize(txn))
+	if newFee <= oldFee {
+		return nil, errors.New("[Wallet], Fee rate not higher than the transaction")
+	}
+	if change == nil || change.Value < newFee-oldFee {
+		return nil, errors.Wrap(errors.ErrInsufficientFunds, "[Wallet], Change of the transaction not enough to bump fee")
+	}
+	change.Value -= newFee - oldFee
+	if change.Value == 0 {
+		txn.Outputs = removeOutput(txn.Outputs, change)
+	}
+
+	txn, err = wallet.sign(keyStore, txn)
+	if err != nil {
+		return nil, err
+	}
+	haveSign, needSign, err := txn.GetSignStatus()
+	if err != nil {
+		return nil, err
+	}
+	if haveSign < needSign {
+		return nil, errors.New("[Wallet], Transaction needs more signatures to replace the pending one")
+	}
+
+	err = client.ReplaceTransaction(txId, txn)
+	if err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+func removeOutput(outputs []*tx.Output, removed *tx.Output) []*tx.Output {
+	for i, output := range outputs {
+		if output == removed {
+			return append(outputs[:i], outputs[i+1:]...)
+		}
+	}
+	return outputs
+}
+
 func getSystemAssetId() *Uint256 {
 	systemToken := &tx.Transaction{
 		TxType:         tx.RegisterAsset,
@@ -357,6 +682,26 @@ func (wallet *WalletImpl) removeLockedUTXOs(utxos []*UTXO) []*UTXO {
 	return availableUTXOs
 }
 
+// Pick out the selected UTXOs, all of the selected must be available
+func selectUTXOs(utxos []*UTXO, selected []*tx.OutPoint) ([]*UTXO, error) {
+	var selectedUTXOs []*UTXO
+	for _, op := range selected {
+		var found *UTXO
+		for _, utxo := range utxos {
+			if utxo.Op.TxID.IsEqual(&op.TxID) && utxo.Op.Index == op.Index {
+				found = utxo
+				break
+			}
+		}
+		if found == nil {
+			return nil, errors.Wrap(errors.ErrNotFound, "[Wallet], Selected UTXO "+op.TxID.String()+":"+
+				strconv.Itoa(int(op.Index)) + " is not available")
+		}
+		selectedUTXOs = append(selectedUTXOs, found)
+	}
+	return selectedUTXOs, nil
+}
+
 func InputFromUTXO(utxo *UTXO) *tx.Input {
 	input := new(tx.Input)
 	input.ReferTxID = utxo.Op.TxID
@@ -365,13 +710,22 @@ func InputFromUTXO(utxo *UTXO) *tx.Input {
 	return input
 }
 
-func (wallet *WalletImpl) newTransaction(redeemScript []byte, inputs []*tx.Input, outputs []*tx.Output) *tx.Transaction {
+func (wallet *WalletImpl) newTransaction(redeemScript []byte, inputs []*tx.Input, outputs []*tx.Output) (*tx.Transaction, error) {
 	// Create payload
 	txPayload := &payload.TransferAsset{}
 	// Create attributes
 	txAttr := tx.NewAttribute(tx.Nonce, []byte(strconv.FormatInt(rand.Int63(), 10)))
 	attributes := make([]*tx.Attribute, 0)
 	attributes = append(attributes, &txAttr)
+	// Add the replay marker if required by the rules of the next block
+	rules, err := networkRules(config.Values())
+	if err != nil {
+		return nil, err
+	}
+	if marker := rules.ReplayMarker(wallet.ChainHeight() + 1); marker != nil {
+		markerAttr := tx.NewAttribute(tx.Nonce, marker)
+		attributes = append(attributes, &markerAttr)
+	}
 	// Create program
 	var program = &pg.Program{redeemScript, nil}
 	// Create transaction
@@ -383,5 +737,5 @@ func (wallet *WalletImpl) newTransaction(redeemScript []byte, inputs []*tx.Input
 		Outputs:    outputs,
 		Programs:   []*pg.Program{program},
 		LockTime:   wallet.ChainHeight(),
-	}
+	}, nil
 }
@@ -0,0 +1,307 @@
+package spvwallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/supervisor"
+)
+
+const (
+	// Webhook event types
+	EventTxReceived  = "tx.received"
+	EventTxConfirmed = "tx.confirmed"
+	EventChainReorg  = "chain.reorg"
+	EventPeersLow    = "peers.low"
+	EventArbiters    = "arbiters.changed"
+	EventChainStall  = "chain.stalled"
+	EventDBFailure   = "db.failed"
+
+	// Default confirmations for the tx.confirmed event
+	DefaultWebhookConfirmations = 6
+	// Max times to post an event to a webhook URL
+	WebhookRetries = 5
+	// Seconds to wait before the first retry, doubled after each retry
+	WebhookRetryDelay = 1
+	// Header of the HMAC-SHA256 signature of the request body in hex, prefixed with "sha256="
+	WebhookSignatureHeader = "X-SPV-Signature"
+	// Header of the event type
+	WebhookEventHeader = "X-SPV-Event"
+
+	// Max events waiting to be posted, new events are dropped when it's full
+	webhookQueueSize = 256
+	// Seconds between two checks of the peer count
+	peersCheckInterval = 30
+)
+
+// WebhookEvent is the JSON body posted to the webhook URLs, the id is the random nonce of the service
+// run and the sequence number of the event in the run, unique across the restarts
+type WebhookEvent struct {
+	Id   string      `json:"id"`
+	Type string      `json:"type"`
+	Time int64       `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// TxEvent is the data of the tx.received and tx.confirmed events, txid is reversed hex string
+type TxEvent struct {
+	TxId          string `json:"txid"`
+	Height        uint32 `json:"height"`
+	Confirmations uint32 `json:"confirmations"`
+}
+
+// ReorgEvent is the data of the chain.reorg event
+type ReorgEvent struct {
+	From uint32 `json:"from"`
+	To   uint32 `json:"to"`
+}
+
+// PeersEvent is the data of the peers.low event
+type PeersEvent struct {
+	Reason string `json:"reason"`
+}
+
+// StallEvent is the data of the chain.stalled event, since is the unix time the tip committed
+type StallEvent struct {
+	Height uint32 `json:"height"`
+	Since  int64  `json:"since"`
+}
+
+// DBFailureEvent is the data of the db.failed event, quarantined is the path
+// the damaged wallet database moved to, empty if the wallet stopped syncing
+type DBFailureEvent struct {
+	Height      uint32 `json:"height"`
+	Error       string `json:"error"`
+	Quarantined string `json:"quarantined,omitempty"`
+}
+
+// ArbitersEvent is the data of the arbiters.changed event, public keys are hex strings
+type ArbitersEvent struct {
+	Arbiters []string `json:"arbiters"`
+}
+
+/*
+webhooks posts the wallet events to the webhook URLs in config, signed with the webhook secret
+by HMAC-SHA256. The events are posted in order, a failed post is retried with backoff.
+*/
+type webhooks struct {
+	sync.Mutex
+	wallet  *SPVWallet
+	client  *http.Client
+	queue   chan *WebhookEvent
+	stop    chan struct{}
+	nonce   string
+	nextId  uint64
+	height  uint32
+	pending map[common.Uint256]uint32 // transactions waiting for confirmations, to the height
+	low     bool
+}
+
+func newWebhooks(wallet *SPVWallet) *webhooks {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		nonce = []byte(strconv.FormatInt(clock.Now().UnixNano(), 16))
+	}
+	return &webhooks{
+		wallet:  wallet,
+		nonce:   hex.EncodeToString(nonce),
+		client:  &http.Client{Timeout: time.Second * 10},
+		queue:   make(chan *WebhookEvent, webhookQueueSize),
+		pending: make(map[common.Uint256]uint32),
+	}
+}
+
+func (w *webhooks) start() {
+	w.height = w.wallet.GetChainHeight()
+	w.stop = make(chan struct{})
+	stop := w.stop
+	supervisor.Go("webhook dispatcher", func() { w.dispatch(stop) })
+}
+
+func (w *webhooks) close() {
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Check if any webhook URL configured
+func (w *webhooks) enabled() bool {
+	return len(w.wallet.Config().Webhooks) > 0
+}
+
+// Put the event into the queue to be posted
+func (w *webhooks) emit(eventType string, data interface{}) {
+	if !w.enabled() {
+		return
+	}
+	w.Lock()
+	w.nextId++
+	id := w.nonce + "-" + strconv.FormatUint(w.nextId, 10)
+	event := &WebhookEvent{Id: id, Type: eventType, Time: clock.Now().Unix(), Data: data}
+	w.Unlock()
+
+	select {
+	case w.queue <- event:
+	default:
+		log.Warn("Webhook queue full, drop event ", eventType)
+	}
+}
+
+// A wallet transaction committed in the block of the height
+func (w *webhooks) onTxCommitted(txId common.Uint256, height uint32) {
+	if !w.enabled() {
+		return
+	}
+	w.emit(EventTxReceived, &TxEvent{
+		TxId:          common.BytesToHexString(txId.BytesReverse()),
+		Height:        height,
+		Confirmations: 1,
+	})
+
+	w.Lock()
+	w.pending[txId] = height
+	w.Unlock()
+	w.onChainHeight(height)
+}
+
+// Emit the confirmed transactions when the chain height changed, or the reorg if it's lower
+func (w *webhooks) onChainHeight(height uint32) {
+	confirmations := w.wallet.Config().WebhookConfirmations
+	if confirmations == 0 {
+		confirmations = DefaultWebhookConfirmations
+	}
+
+	w.Lock()
+	var confirmed []*TxEvent
+	previous := w.height
+	w.height = height
+	for txId, txHeight := range w.pending {
+		if txHeight > height {
+			// The transaction is rolled back
+			delete(w.pending, txId)
+			continue
+		}
+		if height-txHeight+1 >= confirmations {
+			confirmed = append(confirmed, &TxEvent{
+				TxId:          common.BytesToHexString(txId.BytesReverse()),
+				Height:        txHeight,
+				Confirmations: height - txHeight + 1,
+			})
+			delete(w.pending, txId)
+		}
+	}
+	w.Unlock()
+
+	if height < previous {
+		w.emit(EventChainReorg, &ReorgEvent{From: previous, To: height})
+	}
+	for _, event := range confirmed {
+		w.emit(EventTxConfirmed, event)
+	}
+}
+
+// Emit the arbiters.changed event when the arbiter set changed
+func (w *webhooks) OnArbitersChanged(arbiters [][]byte) {
+	event := &ArbitersEvent{Arbiters: make([]string, 0, len(arbiters))}
+	for _, arbiter := range arbiters {
+		event.Arbiters = append(event.Arbiters, hex.EncodeToString(arbiter))
+	}
+	w.emit(EventArbiters, event)
+}
+
+// Emit the chain.stalled event when no new block received for the stall timeout
+func (w *webhooks) OnChainStalled(height uint32, since time.Time) {
+	w.emit(EventChainStall, &StallEvent{Height: height, Since: since.Unix()})
+}
+
+// Emit the peers.low event when the wallet becomes unhealthy for lack of peers,
+// it's run by the scheduler as the peers job
+func (w *webhooks) checkPeers() error {
+	err := w.wallet.CheckHealth()
+	w.Lock()
+	becameLow := err != nil && !w.low
+	w.low = err != nil
+	w.Unlock()
+
+	if becameLow {
+		w.emit(EventPeersLow, &PeersEvent{Reason: err.Error()})
+	}
+	return nil
+}
+
+func (w *webhooks) dispatch(stop chan struct{}) {
+	for {
+		select {
+		case event := <-w.queue:
+			body, err := json.Marshal(event)
+			if err != nil {
+				log.Error("Marshal webhook event failed, ", err)
+				continue
+			}
+			cfg := w.wallet.Config()
+			for _, url := range cfg.Webhooks {
+				w.post(url, event.Type, body, cfg.WebhookSecret, stop)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Post the event to the URL, retry with backoff until succeeded or retries exhausted
+func (w *webhooks) post(url, eventType string, body []byte, secret string, stop chan struct{}) {
+	delay := time.Second * WebhookRetryDelay
+	for i := 0; i < WebhookRetries; i++ {
+		if i > 0 {
+			select {
+			case <-clock.After(delay):
+				delay *= 2
+			case <-stop:
+				return
+			}
+		}
+
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			log.Error("Invalid webhook URL ", url, ", ", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(WebhookEventHeader, eventType)
+		if secret != "" {
+			req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, body))
+		}
+
+		resp, err := w.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return
+			}
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		log.Warnf("Post %s event to webhook %s failed, %s", eventType, url, err)
+	}
+	log.Error("Drop ", eventType, " event to webhook ", url, " after ", WebhookRetries, " tries")
+}
+
+// Get the HMAC-SHA256 signature of the webhook request body in hex string,
+// the receiver verifies the X-SPV-Signature header with it
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package spvwallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/spvwallet/config"
+)
+
+// Webhook receiver failing the first requests of each event, it checks the signature of the requests
+type webhookReceiver struct {
+	t        *testing.T
+	secret   string
+	failures int
+	lock     sync.Mutex
+	requests map[string]int
+	received chan *WebhookEvent
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	mac := hmac.New(sha256.New, []byte(r.secret))
+	mac.Write(body)
+	if signature := req.Header.Get(WebhookSignatureHeader); signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		r.t.Errorf("webhook signature %s of body %s", signature, body)
+	}
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.t.Error(err)
+		return
+	}
+	if eventType := req.Header.Get(WebhookEventHeader); eventType != event.Type {
+		r.t.Errorf("event type header %s of %s event", eventType, event.Type)
+	}
+
+	r.lock.Lock()
+	r.requests[event.Id]++
+	failed := r.requests[event.Id] <= r.failures
+	r.lock.Unlock()
+	r.received <- &event
+	if failed {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// Get the count of the requests of the event
+func (r *webhookReceiver) count(id string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.requests[id]
+}
+
+// Wait for the next request until the timeout, moving the mock clock for the retry delay if it's waited on
+func (r *webhookReceiver) next(mock *clock.Mock, timeout time.Duration) *WebhookEvent {
+	expired := time.After(timeout)
+	for {
+		select {
+		case event := <-r.received:
+			return event
+		case <-expired:
+			return nil
+		case <-time.After(time.Millisecond):
+			if mock.Timers() > 0 {
+				mock.Add(time.Minute)
+			}
+		}
+	}
+}
+
+func TestWebhookPost(t *testing.T) {
+	system := clock.Get()
+	mock := clock.NewMock(time.Unix(1500000000, 0))
+	clock.Set(mock)
+	defer clock.Set(system)
+
+	receiver := &webhookReceiver{t: t, secret: "secret", failures: 2, requests: make(map[string]int),
+		received: make(chan *WebhookEvent, WebhookRetries)}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	wallet := &SPVWallet{config: &config.Config{Webhooks: []string{server.URL}, WebhookSecret: receiver.secret}}
+	w := newWebhooks(wallet)
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.dispatch(stop)
+
+	// The event is posted again after the failed requests until accepted
+	w.emit(EventChainReorg, &ReorgEvent{From: 10, To: 8})
+	w.emit(EventChainStall, &StallEvent{Height: 8, Since: 1500000000})
+	var ids []string
+	for i := 0; i < 2*3; i++ {
+		event := receiver.next(mock, 5*time.Second)
+		if event == nil {
+			t.Fatal("webhook request not received")
+		}
+		if len(ids) == 0 || ids[len(ids)-1] != event.Id {
+			ids = append(ids, event.Id)
+		}
+	}
+	if len(ids) != 2 || !strings.HasPrefix(ids[0], w.nonce+"-") || ids[0] == ids[1] {
+		t.Fatalf("event ids %v posted in order, expect 2 with the nonce %s", ids, w.nonce)
+	}
+	for _, id := range ids {
+		if count := receiver.count(id); count != 3 {
+			t.Errorf("event %s posted %d times, expect 3", id, count)
+		}
+	}
+
+	// The event is dropped after the retries, the ids of another run are not the same
+	receiver.lock.Lock()
+	receiver.failures = WebhookRetries
+	receiver.lock.Unlock()
+	w.emit(EventChainReorg, &ReorgEvent{From: 8, To: 7})
+	var requests int
+	for receiver.next(mock, 200*time.Millisecond) != nil {
+		requests++
+	}
+	if requests != WebhookRetries {
+		t.Errorf("event posted %d times, expect %d", requests, WebhookRetries)
+	}
+	if restarted := newWebhooks(wallet); restarted.nonce == w.nonce {
+		t.Errorf("nonce %s of the restarted webhooks not changed", w.nonce)
+	}
+}
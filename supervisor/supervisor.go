@@ -0,0 +1,202 @@
+/*
+Package supervisor owns the long-running goroutines of the process, like the peer readers, the sync
+loop and the scheduled jobs. A component started by Go() is restarted with backoff when it panics,
+so a bug hit by one component does not stop it for good, and the live components can be dumped
+for diagnostics, they are published as the "components" expvar on the debug server.
+*/
+package supervisor
+
+import (
+	"expvar"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SPV/clock"
+	"github.com/elastos/Elastos.ELA.SPV/log"
+	"github.com/elastos/Elastos.ELA.SPV/metrics"
+)
+
+const (
+	// Delay before a crashed component restarted, doubled on each crash in a row
+	RestartDelay = time.Second
+
+	// Max delay before a crashed component restarted, a component ran longer than
+	// this before it crashed is restarted after RestartDelay again
+	MaxRestartDelay = time.Minute
+)
+
+// States of a component
+const (
+	Running    = "running"
+	Restarting = "restarting"
+)
+
+var restarts = metrics.NewCounter("spv_component_restarts_total", "Restarts of the crashed components")
+
+// ComponentInfo is the state of a live component
+type ComponentInfo struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	Started   time.Time `json:"started"`
+	Restarts  int       `json:"restarts"`
+	LastPanic string    `json:"lastpanic,omitempty"`
+}
+
+type component struct {
+	ComponentInfo
+	id uint64
+}
+
+// Supervisor runs the components and keeps the states of them
+type Supervisor struct {
+	lock       sync.Mutex
+	components map[uint64]*component
+	nextId     uint64
+}
+
+func New() *Supervisor {
+	return &Supervisor{components: make(map[uint64]*component)}
+}
+
+// The supervisor of the process wide components
+var Default = New()
+
+func init() {
+	expvar.Publish("components", expvar.Func(func() interface{} {
+		return Default.Dump()
+	}))
+	metrics.NewGaugeFunc("spv_components", "Live components owned by the supervisor", func() float64 {
+		return float64(Default.Len())
+	})
+}
+
+// Run the component in a goroutine of the default supervisor, see Supervisor.Go()
+func Go(name string, run func()) {
+	Default.Go(name, run)
+}
+
+// Run the component in a goroutine of the default supervisor, see Supervisor.GoOnce()
+func GoOnce(name string, run func(), cleanup func()) {
+	Default.GoOnce(name, run, cleanup)
+}
+
+// Get the live components of the default supervisor
+func Dump() []*ComponentInfo {
+	return Default.Dump()
+}
+
+// Run the component in a goroutine until it returns, it's restarted with backoff when it panics
+func (s *Supervisor) Go(name string, run func()) {
+	c := s.add(name)
+	go func() {
+		defer s.remove(c)
+
+		delay := RestartDelay
+		for {
+			started := clock.Now()
+			if !s.run(c, run) {
+				return
+			}
+			if clock.Since(started) > MaxRestartDelay {
+				delay = RestartDelay
+			}
+			log.Warnf("Restart component %s in %s", name, delay)
+			restarts.Inc()
+			s.lock.Lock()
+			c.Restarts++
+			c.State = Restarting
+			s.lock.Unlock()
+			clock.Sleep(delay)
+			s.setState(c, Running)
+			if delay *= 2; delay > MaxRestartDelay {
+				delay = MaxRestartDelay
+			}
+		}
+	}()
+}
+
+// Run the component in a goroutine, it's not restarted when it panics, like a peer reader
+// of a connection in an unknown state, the cleanup is called after the panic if not nil
+func (s *Supervisor) GoOnce(name string, run func(), cleanup func()) {
+	c := s.add(name)
+	go func() {
+		defer s.remove(c)
+
+		if s.run(c, run) && cleanup != nil {
+			cleanup()
+		}
+	}()
+}
+
+// Get the live components, sorted by name then start time
+func (s *Supervisor) Dump() []*ComponentInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	components := make([]*ComponentInfo, 0, len(s.components))
+	for _, c := range s.components {
+		info := c.ComponentInfo
+		components = append(components, &info)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if components[i].Name != components[j].Name {
+			return components[i].Name < components[j].Name
+		}
+		return components[i].Started.Before(components[j].Started)
+	})
+	return components
+}
+
+// Get the count of the live components
+func (s *Supervisor) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.components)
+}
+
+// Run the component once, return true if it panicked
+func (s *Supervisor) run(c *component, run func()) (crashed bool) {
+	defer func() {
+		if value := recover(); value != nil {
+			log.Errorf("Component %s panicked, %v\n%s", c.Name, value, debug.Stack())
+			s.lock.Lock()
+			c.LastPanic = fmt.Sprint(value)
+			s.lock.Unlock()
+			crashed = true
+		}
+	}()
+
+	run()
+	return false
+}
+
+func (s *Supervisor) add(name string) *component {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nextId++
+	c := &component{
+		ComponentInfo: ComponentInfo{Name: name, State: Running, Started: clock.Now()},
+		id:            s.nextId,
+	}
+	s.components[c.id] = c
+	return c
+}
+
+func (s *Supervisor) remove(c *component) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.components, c.id)
+}
+
+func (s *Supervisor) setState(c *component, state string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c.State = state
+}
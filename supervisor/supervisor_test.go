@@ -0,0 +1,56 @@
+package supervisor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	s := New()
+
+	// A crashed component is restarted
+	runs := make(chan int, 2)
+	done := make(chan struct{})
+	count := 0
+	s.Go("crashing", func() {
+		count++
+		runs <- count
+		if count == 1 {
+			panic("component bug")
+		}
+		<-done
+	})
+	<-runs
+	select {
+	case <-runs:
+	case <-time.After(RestartDelay * 3):
+		t.Fatal("crashed component not restarted")
+	}
+
+	// The once component is cleaned up and not restarted
+	cleaned := make(chan struct{})
+	s.GoOnce("once", func() { panic("reader bug") }, func() { close(cleaned) })
+	select {
+	case <-cleaned:
+	case <-time.After(time.Second):
+		t.Fatal("once component not cleaned up")
+	}
+
+	components := s.Dump()
+	if len(components) != 1 {
+		t.Fatalf("%d live components, expect 1", len(components))
+	}
+	c := components[0]
+	if c.Name != "crashing" || c.State != Running || c.Restarts != 1 || c.LastPanic != "component bug" {
+		t.Errorf("unexpected component info %+v", *c)
+	}
+
+	// A returned component is removed
+	close(done)
+	for i := 0; s.Len() > 0; i++ {
+		if i == 100 {
+			t.Fatal("returned component not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,105 @@
+package verifier
+
+import (
+	"math/big"
+
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// The max target difficulty of a block
+var PowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+
+// Check the proof of work of a main chain header, merge mined with bitcoin
+func CheckMainChainPoW(header *core.Header) error {
+	return CheckTarget(header.Bits, header.AuxPow.ParBlockHeader.Hash())
+}
+
+// Check the proof of work of a sidechain header, merge mined with the main chain
+func CheckSideChainPoW(header *core.Header) error {
+	if header.SideAuxPow == nil {
+		return errors.Wrap(errors.ErrInvalid, "sidechain block without side aux pow.")
+	}
+	if !header.SideAuxPow.Check(header.Hash()) {
+		return errors.Wrap(errors.ErrInvalid, "side aux pow not committed by the main chain block.")
+	}
+	return CheckTarget(header.Bits, header.SideAuxPow.MainBlockHeader.AuxPow.ParBlockHeader.Hash())
+}
+
+// Check the proof of work hash meets the target difficulty bits
+func CheckTarget(bits uint32, hash Uint256) error {
+	// The target difficulty must be larger than zero.
+	target := CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return errors.Wrap(errors.ErrInvalid, "block target difficulty is too low.")
+	}
+
+	// The target difficulty must be less than the maximum allowed.
+	if target.Cmp(PowLimit) > 0 {
+		return errors.Wrap(errors.ErrInvalid, "block target difficulty is higher than max of limit.")
+	}
+
+	// The block hash must be less than the claimed target.
+	hashNum := HashToBig(&hash)
+	if hashNum.Cmp(target) > 0 {
+		return errors.Wrap(errors.ErrInvalid, "block target difficulty is higher than expected difficulty.")
+	}
+
+	return nil
+}
+
+// Get the work of a block of the difficulty bits, the expected hashes to find the block
+func CalcWork(bits uint32) *big.Int {
+	// Return a work value of zero if the passed difficulty bits represent
+	// a negative number. Note this should not happen in practice with valid
+	// blocks, but an invalid block could trigger it.
+	difficultyNum := CompactToBig(bits)
+	if difficultyNum.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	// (1 << 256) / (difficultyNum + 1)
+	denominator := new(big.Int).Add(difficultyNum, big.NewInt(1))
+	return new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), denominator)
+}
+
+func HashToBig(hash *Uint256) *big.Int {
+	// A Hash is in little-endian, but the big package wants the bytes in
+	// big-endian, so reverse them.
+	buf := *hash
+	blen := len(buf)
+	for i := 0; i < blen/2; i++ {
+		buf[i], buf[blen-1-i] = buf[blen-1-i], buf[i]
+	}
+
+	return new(big.Int).SetBytes(buf[:])
+}
+
+func CompactToBig(compact uint32) *big.Int {
+	// Extract the mantissa, sign bit, and exponent.
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	// Since the base for the exponent is 256, the exponent can be treated
+	// as the number of bytes to represent the full 256-bit number.  So,
+	// treat the exponent as the number of bytes and shift the mantissa
+	// right or left accordingly.  This is equivalent to:
+	// N = mantissa * 256^(exponent-3)
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+
+	// Make it negative if the sign bit is set.
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+
+	return bn
+}
@@ -0,0 +1,140 @@
+/*
+Package verifier verifies the Elastos block headers and the merkle proofs of the transactions in them,
+the proof of work by the aux pow of the main chain or the side aux pow of a sidechain, a chain of headers
+connected to a trusted checkpoint, and a transaction proved in a block. It does no networking and keeps
+no database, the checkpoints and params are injected, so a service like a smart contract oracle can
+verify the SPV proofs by importing this package alone.
+
+	v := verifier.New(verifier.Params{Checkpoints: checkpoints})
+	if err := v.VerifyHeaders(headers); err != nil {
+		// the headers are not on the chain of the checkpoints
+	}
+	err := verifier.VerifyProof(headers[len(headers)-1], proof, txId)
+*/
+package verifier
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// Checkpoint is a block trusted to be on the best chain
+type Checkpoint struct {
+	Height uint32
+	Hash   Uint256
+}
+
+// Params of the chain the headers are verified on
+type Params struct {
+	// Verify the side aux pow of sidechain headers instead of the aux pow of main chain headers
+	SideChain bool
+
+	// The chain ID committed by the aux pow of the main chain headers, 0 means the commitment
+	// is not checked, like the SPV client does
+	AuxPowChainID int
+
+	// Blocks trusted to be on the best chain, the headers verified must start from one of them
+	Checkpoints []Checkpoint
+}
+
+// Proof is the merkle proof of the transactions in a block, in the format of a merkleblock
+type Proof struct {
+	Transactions uint32
+	Hashes       []*Uint256
+	Flags        []byte
+}
+
+// Verifier verifies the headers with the params, it's safe for concurrent use
+type Verifier struct {
+	params      Params
+	checkpoints map[uint32]Uint256
+}
+
+// Create a verifier with the params
+func New(params Params) *Verifier {
+	v := &Verifier{params: params, checkpoints: make(map[uint32]Uint256)}
+	for _, checkpoint := range params.Checkpoints {
+		v.checkpoints[checkpoint.Height] = checkpoint.Hash
+	}
+	return v
+}
+
+// Verify the proof of work of the header, and the aux pow commitment if the chain ID is set
+func (v *Verifier) VerifyHeader(header *core.Header) error {
+	if v.params.SideChain {
+		return CheckSideChainPoW(header)
+	}
+	if v.params.AuxPowChainID != 0 && !header.AuxPow.Check(header.Hash(), v.params.AuxPowChainID) {
+		return errors.Wrapf(errors.ErrInvalid, "aux pow not committed to header at height %d", header.Height)
+	}
+	return CheckMainChainPoW(header)
+}
+
+/*
+Verify a chain of headers in ascending order of height. The first header must be a checkpoint or the
+child of one, each header must be the child of the header before it with a valid proof of work, and the
+headers on the heights of the checkpoints must be the checkpoints.
+*/
+func (v *Verifier) VerifyHeaders(headers []*core.Header) error {
+	if len(headers) == 0 {
+		return errors.Wrap(errors.ErrInvalid, "no headers to verify")
+	}
+
+	first := headers[0]
+	checkpoint, ok := v.checkpoints[first.Height]
+	if !ok || checkpoint != *first.Hash() {
+		checkpoint, ok = v.checkpoints[first.Height-1]
+		if first.Height == 0 || !ok || checkpoint != first.Previous {
+			return errors.Wrapf(errors.ErrInvalid, "header at height %d not started from a checkpoint", first.Height)
+		}
+	}
+
+	for i, header := range headers {
+		if i > 0 {
+			previous := headers[i-1]
+			if header.Height != previous.Height+1 || header.Previous != *previous.Hash() {
+				return errors.Wrapf(errors.ErrInvalid, "header at height %d not connected to the header before it",
+					header.Height)
+			}
+		}
+		if checkpoint, ok := v.checkpoints[header.Height]; ok && checkpoint != *header.Hash() {
+			return errors.Wrapf(errors.ErrInvalid, "header at height %d not the checkpoint", header.Height)
+		}
+		if err := v.VerifyHeader(header); err != nil {
+			return errors.WrapErr(errors.ErrInvalid, err, "header at height %d", header.Height)
+		}
+	}
+	return nil
+}
+
+// Verify the transaction is in the block of the header by the merkle proof
+func VerifyProof(header *core.Header, proof *Proof, txId Uint256) error {
+	return VerifyProofs(header, proof, []Uint256{txId})
+}
+
+// Verify the transactions are all in the block of the header by the merkle proof,
+// the partial merkle tree is traversed once for all of them
+func VerifyProofs(header *core.Header, proof *Proof, txIds []Uint256) error {
+	block := bloom.MerkleBlock{
+		BlockHeader:  *header,
+		Transactions: proof.Transactions,
+		Hashes:       proof.Hashes,
+		Flags:        proof.Flags,
+	}
+	matched, err := bloom.CheckMerkleBlock(block)
+	if err != nil {
+		return errors.Wrap(errors.ErrInvalid, "check merkle branch failed, "+err.Error())
+	}
+	matchedIds := make(map[Uint256]bool, len(matched))
+	for _, id := range matched {
+		matchedIds[*id] = true
+	}
+	for _, txId := range txIds {
+		if !matchedIds[txId] {
+			return errors.Wrapf(errors.ErrInvalid, "transaction hash %s not match proof", txId.String())
+		}
+	}
+	return nil
+}
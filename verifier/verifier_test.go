@@ -0,0 +1,106 @@
+package verifier
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	. "github.com/elastos/Elastos.ELA.SPV/common"
+	"github.com/elastos/Elastos.ELA.SPV/core"
+	"github.com/elastos/Elastos.ELA.SPV/core/auxpow"
+	"github.com/elastos/Elastos.ELA.SPV/errors"
+)
+
+// The easiest difficulty bits, a hash meets it by chance of one half
+const easyBits = 0x207fffff
+
+// Mine a main chain header on the previous one with the merkle root
+func mineHeader(previous *core.Header, merkleRoot Uint256) *core.Header {
+	header := &core.Header{Bits: easyBits, MerkleRoot: merkleRoot}
+	if previous != nil {
+		header.Previous = *previous.Hash()
+		header.Height = previous.Height + 1
+	}
+	header.AuxPow.ParBlockHeader = auxpow.BtcHeader{MerkleRoot: *header.Hash(), Bits: easyBits}
+	for CheckTarget(easyBits, header.AuxPow.ParBlockHeader.Hash()) != nil {
+		header.AuxPow.ParBlockHeader.Nonce++
+	}
+	return header
+}
+
+func mineHeaders(count int) []*core.Header {
+	headers := []*core.Header{mineHeader(nil, Uint256{})}
+	for i := 1; i < count; i++ {
+		headers = append(headers, mineHeader(headers[i-1], Uint256{byte(i)}))
+	}
+	return headers
+}
+
+func TestVerifyHeaders(t *testing.T) {
+	headers := mineHeaders(6)
+	v := New(Params{Checkpoints: []Checkpoint{
+		{Height: 0, Hash: *headers[0].Hash()},
+		{Height: 3, Hash: *headers[3].Hash()},
+	}})
+
+	// From a checkpoint, or the child of one
+	for _, chain := range [][]*core.Header{headers, headers[1:], headers[3:], headers[4:]} {
+		if err := v.VerifyHeaders(chain); err != nil {
+			t.Errorf("headers from height %d rejected, %v", chain[0].Height, err)
+		}
+	}
+
+	fork := mineHeader(headers[2], Uint256{0xff})
+	for _, c := range []struct {
+		name    string
+		headers []*core.Header
+	}{
+		{"no headers", nil},
+		{"not from a checkpoint", headers[2:]},
+		{"not connected", []*core.Header{headers[0], headers[2]}},
+		{"not the checkpoint", []*core.Header{headers[1], headers[2], fork}},
+	} {
+		if err := v.VerifyHeaders(c.headers); !errors.Is(err, errors.ErrInvalid) {
+			t.Errorf("unexpected error %v of the headers %s", err, c.name)
+		}
+	}
+
+	// The proof of work must meet the difficulty
+	hard := *headers[1]
+	hard.Bits = 0x1d00ffff
+	if err := v.VerifyHeaders([]*core.Header{headers[0], &hard}); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of the header not meeting the difficulty", err)
+	}
+}
+
+func TestVerifyProof(t *testing.T) {
+	// A block of a single transaction, the merkle root is the transaction hash
+	txId := Uint256{0x01, 0x02}
+	header := mineHeader(nil, txId)
+	proof := &Proof{Transactions: 1, Hashes: []*Uint256{&txId}, Flags: []byte{0x01}}
+	if err := VerifyProof(header, proof, txId); err != nil {
+		t.Fatal("transaction proof rejected,", err)
+	}
+
+	if err := VerifyProof(header, proof, Uint256{0x03}); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of another transaction", err)
+	}
+	other := mineHeader(nil, Uint256{0x03})
+	if err := VerifyProof(other, proof, txId); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of the proof of another block", err)
+	}
+}
+
+func TestVerifyProofs(t *testing.T) {
+	// A block of two transactions both matched
+	txIds := []Uint256{{0x01}, {0x02}}
+	root := bloom.HashMerkleBranches(&txIds[0], &txIds[1])
+	header := mineHeader(nil, *root)
+	proof := &Proof{Transactions: 2, Hashes: []*Uint256{&txIds[0], &txIds[1]}, Flags: []byte{0x07}}
+	if err := VerifyProofs(header, proof, txIds); err != nil {
+		t.Fatal("transactions proof rejected,", err)
+	}
+
+	if err := VerifyProofs(header, proof, []Uint256{txIds[0], {0x03}}); !errors.Is(err, errors.ErrInvalid) {
+		t.Errorf("unexpected error %v of another transaction", err)
+	}
+}